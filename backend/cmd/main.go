@@ -2,22 +2,31 @@ package main
 
 import (
 	"context"
-	"fmt"
+	"flag"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
-	"time"
 	
 	"github.com/gin-gonic/gin"
 	"iot-platform-backend/internal/api"
 	"iot-platform-backend/internal/config"
 	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/ingest"
+	"iot-platform-backend/internal/jobs"
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/shutdown"
+	"iot-platform-backend/internal/webhook"
 	"iot-platform-backend/internal/websocket"
 )
 
 func main() {
+	skipMigrate := flag.Bool("skip-migrate", false, "跳过数据库迁移（只读副本）")
+	migrateDryRun := flag.Bool("migrate-dry-run", false, "只输出数据库迁移将要执行的SQL，不实际修改")
+	flag.Parse()
+	
 	// 加载配置
 	cfg, err := config.Load()
 	if err != nil {
@@ -41,6 +50,8 @@ func main() {
 		DBName:   cfg.Database.DBName,
 		SSLMode:  cfg.Database.SSLMode,
 		TimeZone: cfg.Database.TimeZone,
+		JSONUseNumber: cfg.Database.JSONUseNumber,
+		SensorTimeIndex: cfg.Database.SensorTimeIndex,
 	}
 	
 	if err := database.Connect(dbConfig); err != nil {
@@ -48,9 +59,19 @@ func main() {
 	}
 	
 	// 自动迁移数据库
-	if err := database.Migrate(); err != nil {
+	migrateMode := cfg.Database.MigrateMode
+	switch {
+	case *skipMigrate:
+		migrateMode = database.MigrateSkip
+	case *migrateDryRun:
+		migrateMode = database.MigrateDryRun
+	}
+	if err := database.MigrateWithMode(migrateMode); err != nil {
 		log.Fatalf("Database migration failed: %v", err)
 	}
+	if err := database.CheckIndexes(cfg.Database.IndexCheck); err != nil {
+		log.Fatalf("Database index check failed: %v", err)
+	}
 	
 	// 连接Redis
 	redisConfig := &database.RedisConfig{
@@ -58,6 +79,7 @@ func main() {
 		Port:     cfg.Redis.Port,
 		Password: cfg.Redis.Password,
 		DB:       cfg.Redis.DB,
+		KeyPrefix: cfg.Redis.KeyPrefix,
 	}
 	
 	if err := database.ConnectRedis(redisConfig); err != nil {
@@ -67,9 +89,37 @@ func main() {
 	// 初始化WebSocket管理器
 	websocket.Init()
 	
+	// 加载设备数据转换、派生字段和设备默认配置
+	if err := ingest.InitTransforms(cfg.Ingestion.Transforms); err != nil {
+		log.Fatalf("Invalid ingestion transforms: %v", err)
+	}
+	if err := ingest.InitComputedFields(cfg.Ingestion.ComputedFields); err != nil {
+		log.Fatalf("Invalid computed fields: %v", err)
+	}
+	if err := models.InitDeviceConfigDefaults(cfg.Devices.DefaultConfigs); err != nil {
+		log.Fatalf("Invalid device default configs: %v", err)
+	}
+	if err := models.InitProjectDefaultSort(cfg.Projects.DefaultSort); err != nil {
+		log.Fatalf("Invalid project default sort: %v", err)
+	}
+	
+	// 初始化设备数据写入管道（异步模式）
+	ingest.Init(cfg.Ingestion)
+	
+	// 初始化Webhook投递器
+	webhook.Init(cfg.Webhook)
+	
+	// 启动后台任务
+	scheduler := jobs.Start(cfg)
+	
 	// 创建Gin引擎
 	r := gin.New()
 	
+	// 仅信任配置的代理转发的X-Forwarded-For/X-Real-IP，防止客户端伪造IP
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
+	
 	// 设置路由
 	api.SetupRoutes(r)
 	
@@ -84,7 +134,8 @@ func main() {
 	// 在goroutine中启动服务器
 	go func() {
 		log.Printf("Server starting on port %s", cfg.Server.Port)
-		log.Printf("Health check available at: http://localhost:%s/health", cfg.Server.Port)
+		log.Printf("Liveness check available at: http://localhost:%s/healthz", cfg.Server.Port)
+		log.Printf("Readiness check available at: http://localhost:%s/readyz", cfg.Server.Port)
 		log.Printf("API documentation available at: http://localhost:%s/api/v1", cfg.Server.Port)
 		
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -99,8 +150,8 @@ func main() {
 	
 	log.Println("Shutting down server...")
 	
-	// 创建一个超时上下文，给服务器30秒时间完成现有请求
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// 进行中的请求、写入队列和后台任务共用同一个关闭超时
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 	
 	// 优雅关闭服务器
@@ -108,6 +159,19 @@ func main() {
 		log.Printf("Server forced to shutdown: %v", err)
 	}
 	
+	// 写入队列中剩余的设备数据
+	if ingest.Default != nil {
+		if err := ingest.Default.Stop(ctx); err != nil {
+			log.Printf("Failed to flush ingestion queue: %v", err)
+		}
+	}
+	
+	// 停止后台任务，等待正在执行的任务和其他登记的后台goroutine结束后再关闭连接
+	scheduler.Stop()
+	if unfinished := shutdown.Default.Wait(ctx); len(unfinished) > 0 {
+		log.Printf("Shutdown timed out waiting for background workers: %s", strings.Join(unfinished, ", "))
+	}
+	
 	// 关闭数据库连接
 	if err := database.Close(); err != nil {
 		log.Printf("Failed to close database connection: %v", err)
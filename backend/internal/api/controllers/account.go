@@ -0,0 +1,353 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"iot-platform-backend/internal/config"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+)
+
+// DeleteAccountRequest 注销账号请求
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"` // 需再次确认密码
+}
+
+// AccountDeletionSummary 注销账号时的数据处理结果
+type AccountDeletionSummary struct {
+	Policy              string `json:"policy"`
+	ProjectsTransferred int64  `json:"projects_transferred"`
+	ProjectsDeleted     int64  `json:"projects_deleted"`
+	DevicesTransferred  int64  `json:"devices_transferred"`
+	DevicesDeleted      int64  `json:"devices_deleted"`
+	SensorDataDeleted   int64  `json:"sensor_data_deleted"`
+}
+
+// DeleteAccount 注销当前账号
+// @Summary 注销账号
+// @Description 确认密码后注销当前账号：公开项目转移给占位账号，其余项目、设备和传感器数据按配置删除或匿名化，并吊销所有token
+// @Tags 认证
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body DeleteAccountRequest true "密码确认"
+// @Success 200 {object} AccountDeletionSummary
+// @Failure 400 {object} map[string]interface{}
+// @Router /auth/me [delete]
+func (ctrl *AuthController) DeleteAccount(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+		})
+		return
+	}
+	
+	var req DeleteAccountRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "User not found",
+		})
+		return
+	}
+	
+	if user.Ghost {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "This account cannot be deleted",
+		})
+		return
+	}
+	
+	if !user.CheckPassword(req.Password) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Password is incorrect",
+		})
+		return
+	}
+	
+	// 事务外记录设备标识，用于事务提交后清理缓存
+	var deviceIDs []string
+	db.Model(&models.Device{}).Where("owner_id = ?", user.ID).Pluck("device_id", &deviceIDs)
+	
+	policy := config.AppConfig.Account.DeletionPolicy
+	var summary AccountDeletionSummary
+	err := database.TransactionWithContext(c.Request.Context(), func(tx *gorm.DB) error {
+		var err error
+		summary, err = deleteAccountData(tx, &user, policy)
+		if err != nil {
+			return err
+		}
+		
+		audit := models.AuditLog{
+			ActorID:    user.ID,
+			Action:     "account_delete",
+			Resource:   "user",
+			ResourceID: user.ID,
+			Details: models.JSONB{
+				"policy":               summary.Policy,
+				"projects_transferred": summary.ProjectsTransferred,
+				"projects_deleted":     summary.ProjectsDeleted,
+				"devices_transferred":  summary.DevicesTransferred,
+				"devices_deleted":      summary.DevicesDeleted,
+				"sensor_data_deleted":  summary.SensorDataDeleted,
+			},
+			IPAddress: c.ClientIP(),
+			UserAgent: c.GetHeader("User-Agent"),
+		}
+		return tx.Create(&audit).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete account",
+		})
+		return
+	}
+	
+	// 吊销所有已签发的token并清理缓存
+	database.RevokeUserTokens(c, user.ID, config.AppConfig.JWT.RefreshExpires)
+	database.DeleteUserSessions(c, user.ID)
+	
+	cache := database.NewCache()
+	keys := []string{
+		database.Keys.User(user.ID),
+		database.Keys.DeviceList(user.ID),
+		database.Keys.ProjectList(user.ID),
+		database.Keys.QuotaSensorRows(user.ID),
+	}
+	for _, deviceID := range deviceIDs {
+		keys = append(keys, database.Keys.Device(deviceID))
+	}
+	cache.Delete(c, keys...)
+	if policy == "delete" {
+		database.ResetDeviceSummary(c, deviceIDs...)
+	}
+	invalidatePublicProjects(c)
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"msg":    "账号已注销",
+		"data":   summary,
+	})
+}
+
+// deleteAccountData 在事务中处理用户的项目、设备和关联数据，最后匿名化并软删除用户
+func deleteAccountData(tx *gorm.DB, user *models.User, policy string) (AccountDeletionSummary, error) {
+	summary := AccountDeletionSummary{Policy: policy}
+	
+	ghost, err := ensureGhostUser(tx)
+	if err != nil {
+		return summary, err
+	}
+	
+	// 公开项目始终转移给占位账号，避免其Fork失去来源
+	projectQuery := tx.Model(&models.Project{}).Where("owner_id = ?", user.ID)
+	if policy == "delete" {
+		projectQuery = projectQuery.Where("public = ?", true)
+	}
+	result := projectQuery.Update("owner_id", ghost.ID)
+	if result.Error != nil {
+		return summary, result.Error
+	}
+	summary.ProjectsTransferred = result.RowsAffected
+	
+	if policy == "delete" {
+		var projectIDs []uint
+		if err := tx.Model(&models.Project{}).Where("owner_id = ?", user.ID).Pluck("id", &projectIDs).Error; err != nil {
+			return summary, err
+		}
+		if len(projectIDs) > 0 {
+			for _, model := range []interface{}{&models.ForkHistory{}, &models.ProjectStar{}, &models.Fork{}, &models.ProjectCollaborator{}} {
+				if err := tx.Where("project_id IN ?", projectIDs).Delete(model).Error; err != nil {
+					return summary, err
+				}
+			}
+			if err := tx.Model(&models.Project{}).Where("parent_id IN ?", projectIDs).Update("parent_id", nil).Error; err != nil {
+				return summary, err
+			}
+			
+			result = tx.Where("id IN ?", projectIDs).Delete(&models.Project{})
+			if result.Error != nil {
+				return summary, result.Error
+			}
+			summary.ProjectsDeleted = result.RowsAffected
+		}
+	}
+	
+	// 设备及传感器数据、告警、命令记录和设备分组
+	if policy == "delete" {
+		var devices []models.Device
+		if err := tx.Model(&models.Device{}).Where("owner_id = ?", user.ID).Select("id", "device_id").Find(&devices).Error; err != nil {
+			return summary, err
+		}
+		if len(devices) > 0 {
+			ids := make([]uint, len(devices))
+			deviceIDs := make([]string, len(devices))
+			for i, device := range devices {
+				ids[i] = device.ID
+				deviceIDs[i] = device.DeviceID
+			}
+			
+			result = tx.Where("device_id IN ?", deviceIDs).Delete(&models.SensorData{})
+			if result.Error != nil {
+				return summary, result.Error
+			}
+			summary.SensorDataDeleted = result.RowsAffected
+			if err := tx.Where("device_id IN ?", deviceIDs).Delete(&models.Alert{}).Error; err != nil {
+				return summary, err
+			}
+			if err := tx.Where("device_id IN ?", ids).Delete(&models.DeviceCommand{}).Error; err != nil {
+				return summary, err
+			}
+			
+			result = tx.Where("owner_id = ?", user.ID).Delete(&models.Device{})
+			if result.Error != nil {
+				return summary, result.Error
+			}
+			summary.DevicesDeleted = result.RowsAffected
+		}
+		
+		// 软删除的设备不再引用即将删除的分组
+		if err := tx.Unscoped().Model(&models.Device{}).Where("owner_id = ? AND group_id IS NOT NULL", user.ID).Update("group_id", nil).Error; err != nil {
+			return summary, err
+		}
+		for _, model := range []interface{}{&models.DeviceGroup{}, &models.DeviceConfigTemplate{}} {
+			if err := tx.Where("owner_id = ?", user.ID).Delete(model).Error; err != nil {
+				return summary, err
+			}
+		}
+	} else {
+		result = tx.Model(&models.Device{}).Where("owner_id = ?", user.ID).
+			Updates(map[string]interface{}{"owner_id": ghost.ID, "status": "offline"})
+		if result.Error != nil {
+			return summary, result.Error
+		}
+		summary.DevicesTransferred = result.RowsAffected
+		
+		// 分组随设备一起转移，设备的group_id保持有效；告警按device_id关联，随设备保留
+		for _, model := range []interface{}{&models.DeviceGroup{}, &models.DeviceConfigTemplate{}} {
+			if err := tx.Model(model).Where("owner_id = ?", user.ID).Update("owner_id", ghost.ID).Error; err != nil {
+				return summary, err
+			}
+		}
+	}
+	
+	// Webhook包含目标地址和签名密钥，连同投递记录一起删除
+	webhookIDs := tx.Model(&models.Webhook{}).Select("id").Where("owner_id = ?", user.ID)
+	if err := tx.Where("webhook_id IN (?)", webhookIDs).Delete(&models.WebhookDelivery{}).Error; err != nil {
+		return summary, err
+	}
+	if err := tx.Where("owner_id = ?", user.ID).Delete(&models.Webhook{}).Error; err != nil {
+		return summary, err
+	}
+	
+	// 该用户确认的告警、下发的命令和创建的邀请码归属到占位账号，未失效的邀请码一并撤销
+	if err := tx.Model(&models.Alert{}).Where("acknowledged_by = ?", user.ID).Update("acknowledged_by", ghost.ID).Error; err != nil {
+		return summary, err
+	}
+	if err := tx.Model(&models.DeviceCommand{}).Where("issued_by = ?", user.ID).Update("issued_by", ghost.ID).Error; err != nil {
+		return summary, err
+	}
+	if err := tx.Model(&models.Invite{}).Where("created_by = ? AND revoked_at IS NULL", user.ID).Update("revoked_at", time.Now()).Error; err != nil {
+		return summary, err
+	}
+	if err := tx.Model(&models.Invite{}).Where("created_by = ?", user.ID).Update("created_by", ghost.ID).Error; err != nil {
+		return summary, err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserQuota{}).Error; err != nil {
+		return summary, err
+	}
+	
+	// 撤销该用户的点赞
+	if err := tx.Model(&models.Project{}).
+		Where("id IN (?) AND star_count > 0", tx.Model(&models.ProjectStar{}).Select("project_id").Where("user_id = ?", user.ID)).
+		Update("star_count", gorm.Expr("star_count - 1")).Error; err != nil {
+		return summary, err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.ProjectStar{}).Error; err != nil {
+		return summary, err
+	}
+	
+	// 保留的Fork记录和历史归属到占位账号，并清除个人信息
+	if err := tx.Model(&models.Fork{}).Where("user_id = ?", user.ID).Update("user_id", ghost.ID).Error; err != nil {
+		return summary, err
+	}
+	if err := tx.Model(&models.ForkHistory{}).Where("user_id = ?", user.ID).
+		Updates(map[string]interface{}{"user_id": ghost.ID, "ip_address": "", "user_agent": ""}).Error; err != nil {
+		return summary, err
+	}
+	
+	// 退出参与协作的项目
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.ProjectCollaborator{}).Error; err != nil {
+		return summary, err
+	}
+	
+	// 站内通知属于个人数据，直接删除
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.Notification{}).Error; err != nil {
+		return summary, err
+	}
+	
+	// 匿名化后软删除用户，释放用户名、邮箱和手机号
+	if err := tx.Model(user).Updates(map[string]interface{}{
+		"username": fmt.Sprintf("deleted_user_%d", user.ID),
+		"email":    fmt.Sprintf("deleted_user_%d@users.invalid", user.ID),
+		"phone":    fmt.Sprintf("deleted_user_%d", user.ID),
+		"avatar":   "",
+		"active":   false,
+	}).Error; err != nil {
+		return summary, err
+	}
+	
+	return summary, tx.Delete(user).Error
+}
+
+// ensureGhostUser 获取或创建占位账号（不可登录），按Ghost标记查找，不依赖可被注册的用户名
+func ensureGhostUser(tx *gorm.DB) (models.User, error) {
+	var ghost models.User
+	err := tx.Where("ghost = ?", true).First(&ghost).Error
+	if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+		return ghost, err
+	}
+	
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return ghost, err
+	}
+	
+	// 保留用户名之前已有账号注册了该用户名时，改用带随机后缀的用户名
+	username := models.GhostUsername
+	var taken int64
+	if err := tx.Unscoped().Model(&models.User{}).Where("username = ?", username).Count(&taken).Error; err != nil {
+		return ghost, err
+	}
+	if taken > 0 {
+		username = fmt.Sprintf("%s_%s", models.GhostUsername, hex.EncodeToString(secret[:4]))
+	}
+	
+	ghost = models.User{
+		Username: username,
+		Ghost:    true,
+		Email:    username + "@users.invalid",
+		Phone:    username,
+		Password: hex.EncodeToString(secret),
+	}
+	if err := tx.Create(&ghost).Error; err != nil {
+		return ghost, err
+	}
+	
+	// active列有默认值true，创建时无法直接写入false，需单独更新
+	return ghost, tx.Model(&ghost).Update("active", false).Error
+}
@@ -0,0 +1,275 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/testutil"
+)
+
+func TestRegisterRejectsReservedUsername(t *testing.T) {
+	testutil.LoadConfig(t)
+	ctrl := NewAuthController()
+
+	for _, username := range []string{"ghost", "Ghost", "GHOST"} {
+		t.Run(username, func(t *testing.T) {
+			c, w := newJSONContext(t, "POST", "/api/v1/auth/register", RegisterRequest{
+				Username: username,
+				Password: "secret123",
+			}, nil)
+			ctrl.Register(c)
+
+			if w.Code != http.StatusConflict {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusConflict, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	cfg := testutil.LoadConfig(t)
+	cfg.Account.DeletionPolicy = "delete"
+	testutil.UseRedis(t)
+	db := testutil.UseDB(t)
+
+	owner := createTestUser(t, "alice")
+	other := createTestUser(t, "bob")
+
+	public := models.Project{Name: "shared", OwnerID: owner.ID, Public: true}
+	private := models.Project{Name: "secret", OwnerID: owner.ID}
+	for _, project := range []*models.Project{&public, &private} {
+		if err := db.Create(project).Error; err != nil {
+			t.Fatalf("create project: %v", err)
+		}
+	}
+	fork := models.Project{Name: "shared-fork", OwnerID: other.ID, ParentID: &public.ID, Public: true}
+	if err := db.Create(&fork).Error; err != nil {
+		t.Fatalf("create fork: %v", err)
+	}
+	device := models.Device{DeviceID: "alice-dev", Name: "sensor", Type: models.SoilMoisture, OwnerID: owner.ID}
+	if err := db.Create(&device).Error; err != nil {
+		t.Fatalf("create device: %v", err)
+	}
+	if err := db.Create(&models.SensorData{DeviceID: device.DeviceID, Data: models.JSONB{"moisture": 30}, Timestamp: time.Now()}).Error; err != nil {
+		t.Fatalf("create sensor data: %v", err)
+	}
+	related := createAccountFixtures(t, owner, &device)
+
+	issuedAt := time.Now().Add(-time.Minute)
+	c, w := newJSONContext(t, "DELETE", "/api/v1/auth/me", DeleteAccountRequest{Password: "secret123"}, owner)
+	NewAuthController().DeleteAccount(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+
+	var remaining int64
+	db.Model(&models.User{}).Where("id = ?", owner.ID).Count(&remaining)
+	if remaining != 0 {
+		t.Error("deleted user is still visible")
+	}
+
+	// 公开项目转移给占位账号，其Fork仍能解析到来源
+	var ghost models.User
+	if err := db.Where("ghost = ?", true).First(&ghost).Error; err != nil {
+		t.Fatalf("ghost user not created: %v", err)
+	}
+	if ghost.Active {
+		t.Error("ghost user must not be able to log in")
+	}
+	var parent models.Project
+	if err := db.Joins("JOIN projects AS forks ON forks.parent_id = projects.id").
+		Where("forks.id = ?", fork.ID).First(&parent).Error; err != nil {
+		t.Fatalf("fork no longer resolves its parent: %v", err)
+	}
+	if parent.ID != public.ID || parent.OwnerID != ghost.ID {
+		t.Errorf("parent = {id:%d owner:%d}, want {id:%d owner:%d}", parent.ID, parent.OwnerID, public.ID, ghost.ID)
+	}
+
+	var count int64
+	db.Model(&models.Project{}).Where("id = ?", private.ID).Count(&count)
+	if count != 0 {
+		t.Error("private project was not deleted")
+	}
+	db.Model(&models.SensorData{}).Where("device_id = ?", device.DeviceID).Count(&count)
+	if count != 0 {
+		t.Error("sensor data was not deleted")
+	}
+
+	if !database.IsTokenRevoked(c, "token", owner.ID, issuedAt) {
+		t.Error("tokens issued before deletion are still valid")
+	}
+
+	// 设备的告警、命令和分组随设备删除
+	for name, model := range map[string]interface{}{
+		"alert":   &models.Alert{},
+		"command": &models.DeviceCommand{},
+		"group":   &models.DeviceGroup{},
+	} {
+		db.Model(model).Where("id = ?", related.ids[name]).Count(&count)
+		if count != 0 {
+			t.Errorf("%s of a deleted device was kept", name)
+		}
+	}
+	related.assertPersonalDataRemoved(t, db, ghost)
+}
+
+func TestDeleteAccountAnonymizeTransfersGroups(t *testing.T) {
+	cfg := testutil.LoadConfig(t)
+	cfg.Account.DeletionPolicy = "anonymize"
+	testutil.UseRedis(t)
+	db := testutil.UseDB(t)
+
+	owner := createTestUser(t, "alice")
+	device := createTestDevice(t, models.Device{DeviceID: "alice-dev", OwnerID: owner.ID})
+	related := createAccountFixtures(t, owner, &device)
+
+	c, w := newJSONContext(t, "DELETE", "/api/v1/auth/me", DeleteAccountRequest{Password: "secret123"}, owner)
+	NewAuthController().DeleteAccount(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+
+	var ghost models.User
+	if err := db.Where("ghost = ?", true).First(&ghost).Error; err != nil {
+		t.Fatalf("ghost user not created: %v", err)
+	}
+
+	// 设备和所在分组一起转移给占位账号，分组关系保持有效
+	var transferred models.Device
+	if err := db.First(&transferred, device.ID).Error; err != nil {
+		t.Fatalf("transferred device not found: %v", err)
+	}
+	var group models.DeviceGroup
+	if err := db.First(&group, related.ids["group"]).Error; err != nil {
+		t.Fatalf("device group not found: %v", err)
+	}
+	if transferred.OwnerID != ghost.ID || group.OwnerID != ghost.ID {
+		t.Errorf("device owner = %d, group owner = %d, want both transferred to %d", transferred.OwnerID, group.OwnerID, ghost.ID)
+	}
+	if transferred.GroupID == nil || *transferred.GroupID != group.ID {
+		t.Errorf("device group = %v, want %d", transferred.GroupID, group.ID)
+	}
+
+	var count int64
+	db.Model(&models.Alert{}).Where("id = ?", related.ids["alert"]).Count(&count)
+	if count != 1 {
+		t.Error("alert of a transferred device was deleted")
+	}
+	related.assertPersonalDataRemoved(t, db, ghost)
+}
+
+// accountFixtures 注销账号时需要一并处理的关联数据
+type accountFixtures struct {
+	ids map[string]uint
+}
+
+// createAccountFixtures 为用户创建Webhook、设备分组、告警、命令记录、邀请码和配额等关联数据，设备加入新建的分组
+func createAccountFixtures(t *testing.T, user *models.User, device *models.Device) accountFixtures {
+	t.Helper()
+	db := database.DB
+	group := models.DeviceGroup{Name: "field", OwnerID: user.ID}
+	if err := db.Create(&group).Error; err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+	if err := db.Model(device).Update("group_id", group.ID).Error; err != nil {
+		t.Fatalf("assign group: %v", err)
+	}
+	webhook := models.Webhook{OwnerID: user.ID, URL: "https://example.com/hook", Secret: "hmac-secret", Enabled: true}
+	if err := db.Create(&webhook).Error; err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+	delivery := models.WebhookDelivery{WebhookID: webhook.ID, Event: "reading.created", Status: "pending"}
+	if err := db.Create(&delivery).Error; err != nil {
+		t.Fatalf("create delivery: %v", err)
+	}
+	alert := models.Alert{DeviceID: device.DeviceID, Severity: models.AlertSeverityWarning, ReadingAt: time.Now(), AcknowledgedBy: &user.ID}
+	if err := db.Create(&alert).Error; err != nil {
+		t.Fatalf("create alert: %v", err)
+	}
+	command := models.DeviceCommand{DeviceID: device.ID, Command: "reboot", Status: models.CommandPending, IssuedBy: user.ID}
+	if err := db.Create(&command).Error; err != nil {
+		t.Fatalf("create command: %v", err)
+	}
+	invite := models.Invite{Code: "alice-invite", MaxUses: 1, CreatedBy: user.ID}
+	if err := db.Create(&invite).Error; err != nil {
+		t.Fatalf("create invite: %v", err)
+	}
+	limit := 5
+	if err := db.Create(&models.UserQuota{UserID: user.ID, MaxDevices: &limit}).Error; err != nil {
+		t.Fatalf("create quota: %v", err)
+	}
+	return accountFixtures{ids: map[string]uint{
+		"user":     user.ID,
+		"group":    group.ID,
+		"webhook":  webhook.ID,
+		"delivery": delivery.ID,
+		"alert":    alert.ID,
+		"command":  command.ID,
+		"invite":   invite.ID,
+	}}
+}
+
+// assertPersonalDataRemoved 检查两种注销策略下都不会留下归属于已删除用户的数据
+func (f accountFixtures) assertPersonalDataRemoved(t *testing.T, db *gorm.DB, ghost models.User) {
+	t.Helper()
+	var count int64
+	db.Model(&models.Webhook{}).Where("id = ?", f.ids["webhook"]).Count(&count)
+	if count != 0 {
+		t.Error("webhook with its secret was kept")
+	}
+	db.Model(&models.WebhookDelivery{}).Where("id = ?", f.ids["delivery"]).Count(&count)
+	if count != 0 {
+		t.Error("webhook delivery log was kept")
+	}
+	db.Model(&models.UserQuota{}).Where("user_id = ?", f.ids["user"]).Count(&count)
+	if count != 0 {
+		t.Error("quota override was kept")
+	}
+	for _, table := range []struct{ name, column string }{
+		{"device_groups", "owner_id"},
+		{"alerts", "acknowledged_by"},
+		{"device_commands", "issued_by"},
+		{"invites", "created_by"},
+	} {
+		db.Table(table.name).Where(table.column+" = ?", f.ids["user"]).Count(&count)
+		if count != 0 {
+			t.Errorf("%d row(s) in %s still reference the deleted user", count, table.name)
+		}
+	}
+
+	var invite models.Invite
+	if err := db.First(&invite, f.ids["invite"]).Error; err != nil {
+		t.Fatalf("invite not found: %v", err)
+	}
+	if invite.RevokedAt == nil || invite.CreatedBy != ghost.ID {
+		t.Errorf("invite = {revoked:%v created_by:%d}, want revoked and attributed to the ghost user", invite.RevokedAt, invite.CreatedBy)
+	}
+}
+
+func TestGhostUserIgnoresRegisteredGhostName(t *testing.T) {
+	testutil.LoadConfig(t)
+	db := testutil.UseDB(t)
+
+	// 保留用户名之前注册的真实账号不能被当作占位账号
+	impostor := createTestUser(t, models.GhostUsername)
+
+	ghost, err := ensureGhostUser(db)
+	if err != nil {
+		t.Fatalf("ensureGhostUser: %v", err)
+	}
+	if ghost.ID == impostor.ID || !ghost.Ghost {
+		t.Fatalf("ensureGhostUser returned the registered user %q", ghost.Username)
+	}
+
+	again, err := ensureGhostUser(db)
+	if err != nil {
+		t.Fatalf("ensureGhostUser: %v", err)
+	}
+	if again.ID != ghost.ID {
+		t.Errorf("second call created another ghost user (%d != %d)", again.ID, ghost.ID)
+	}
+}
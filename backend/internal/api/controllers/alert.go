@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/websocket"
+)
+
+// AlertController 设备告警控制器
+type AlertController struct{}
+
+// NewAlertController 创建告警控制器
+func NewAlertController() *AlertController {
+	return &AlertController{}
+}
+
+// AlertListResponse 告警列表响应
+type AlertListResponse struct {
+	Alerts         []models.Alert `json:"alerts"`
+	Total          int64          `json:"total"`
+	Unacknowledged int64          `json:"unacknowledged"` // 当前用户设备上未确认的告警总数，不受筛选条件影响
+	Page           int            `json:"page"`
+	Limit          int            `json:"limit"`
+}
+
+// AckAllAlertsRequest 批量确认告警请求，条件为空时确认所有未确认的告警
+type AckAllAlertsRequest struct {
+	DeviceID string     `json:"device_id"`
+	Severity string     `json:"severity" binding:"omitempty,oneof=warning critical"`
+	Before   *time.Time `json:"before"` // 只确认该时间之前产生的告警，避免误确认刚产生的告警
+}
+
+// userAlertsScope 限定为用户当前拥有的设备上的告警，设备转移后告警随设备归属新所有者
+func userAlertsScope(userID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("device_id IN (SELECT device_id FROM devices WHERE owner_id = ? AND deleted_at IS NULL)", userID)
+	}
+}
+
+// GetAlerts 获取告警列表
+// @Summary 获取告警列表
+// @Description 分页获取当前用户设备上的告警，按产生时间倒序
+// @Tags 告警
+// @Security BearerAuth
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param limit query int false "每页数量" default(10)
+// @Param device_id query string false "设备标识"
+// @Param severity query string false "告警级别（warning、critical）"
+// @Param acknowledged query bool false "true只显示已确认，false只显示未确认"
+// @Param since query string false "产生时间不早于（RFC3339）" format(date-time)
+// @Param until query string false "产生时间早于（RFC3339）" format(date-time)
+// @Success 200 {object} AlertListResponse
+// @Failure 400 {object} map[string]interface{}
+// @Router /alerts [get]
+func (ctrl *AlertController) GetAlerts(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	pagination := parseListPagination(c)
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	query := db.Model(&models.Alert{}).Scopes(userAlertsScope(userID))
+	
+	if deviceID := c.Query("device_id"); deviceID != "" {
+		query = query.Where("device_id = ?", deviceID)
+	}
+	if severity := c.Query("severity"); severity != "" {
+		if severity != models.AlertSeverityWarning && severity != models.AlertSeverityCritical {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "severity must be warning or critical",
+			})
+			return
+		}
+		query = query.Where("severity = ?", severity)
+	}
+	if raw := c.Query("acknowledged"); raw != "" {
+		acknowledged, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "acknowledged must be true or false",
+			})
+			return
+		}
+		if acknowledged {
+			query = query.Where("acknowledged_at IS NOT NULL")
+		} else {
+			query = query.Where("acknowledged_at IS NULL")
+		}
+	}
+	for param, condition := range map[string]string{"since": "created_at >= ?", "until": "created_at < ?"} {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": param + " must be an RFC3339 time",
+			})
+			return
+		}
+		query = query.Where(condition, t)
+	}
+	
+	var total int64
+	query.Count(&total)
+	unacknowledged, _ := database.UnacknowledgedAlertCount(c.Request.Context(), userID)
+	
+	var alerts []models.Alert
+	if err := query.Order("created_at DESC, id DESC").
+		Offset(pagination.Offset).
+		Limit(pagination.Limit).
+		Find(&alerts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch alerts",
+		})
+		return
+	}
+	
+	setPaginationHeaders(c, total, pagination.Page, pagination.Limit)
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"data": AlertListResponse{
+			Alerts:         alerts,
+			Total:          total,
+			Unacknowledged: unacknowledged,
+			Page:           pagination.Page,
+			Limit:          pagination.Limit,
+		},
+	})
+}
+
+// AckAlert 确认单条告警
+// @Summary 确认告警
+// @Description 已确认的告警保持原有的确认人和确认时间
+// @Tags 告警
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "告警ID"
+// @Success 200 {object} models.Alert
+// @Failure 404 {object} map[string]interface{}
+// @Router /alerts/{id}/ack [post]
+func (ctrl *AlertController) AckAlert(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	alertID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid alert ID",
+		})
+		return
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	var alert models.Alert
+	if err := db.Scopes(userAlertsScope(userID)).Where("id = ?", uint(alertID)).First(&alert).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Alert not found",
+		})
+		return
+	}
+	
+	if !alert.IsAcknowledged() {
+		now := time.Now()
+		// 条件更新，并发确认时保留先确认的人
+		result := db.Model(&models.Alert{}).
+			Where("id = ? AND acknowledged_at IS NULL", alert.ID).
+			Updates(map[string]interface{}{"acknowledged_by": userID, "acknowledged_at": now})
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to acknowledge alert",
+			})
+			return
+		}
+		if result.RowsAffected > 0 {
+			alert.AcknowledgedBy = &userID
+			alert.AcknowledgedAt = &now
+			pushAlertCount(c.Request.Context(), userID)
+		} else {
+			db.First(&alert, alert.ID)
+		}
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"data":   alert,
+	})
+}
+
+// AckAllAlerts 批量确认告警
+// @Summary 批量确认告警
+// @Description 确认当前用户设备上所有符合条件的未确认告警，可按设备、级别和产生时间限定
+// @Tags 告警
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body AckAllAlertsRequest false "确认条件"
+// @Success 200 {object} map[string]interface{}
+// @Router /alerts/ack-all [post]
+func (ctrl *AlertController) AckAllAlerts(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	
+	var req AckAllAlertsRequest
+	if c.Request.ContentLength != 0 && !bindJSON(c, &req) {
+		return
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	query := db.Model(&models.Alert{}).Scopes(userAlertsScope(userID)).Where("acknowledged_at IS NULL")
+	if req.DeviceID != "" {
+		query = query.Where("device_id = ?", req.DeviceID)
+	}
+	if req.Severity != "" {
+		query = query.Where("severity = ?", req.Severity)
+	}
+	if req.Before != nil {
+		query = query.Where("created_at < ?", *req.Before)
+	}
+	
+	result := query.Updates(map[string]interface{}{"acknowledged_by": userID, "acknowledged_at": time.Now()})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to acknowledge alerts",
+		})
+		return
+	}
+	if result.RowsAffected > 0 {
+		pushAlertCount(c.Request.Context(), userID)
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"msg":    "告警已确认",
+		"data":   gin.H{"acknowledged": result.RowsAffected},
+	})
+}
+
+// pushAlertCount 通过WebSocket推送用户最新的未确认告警数
+func pushAlertCount(ctx context.Context, userID uint) {
+	if websocket.DefaultManager != nil {
+		websocket.DefaultManager.PushAlertCount(ctx, userID)
+	}
+}
+
+// recordReadingAlert 读数超出范围时保存告警并推送未确认告警数，不阻塞设备上报
+func recordReadingAlert(device models.Device, reading models.SensorData) {
+	ctx := context.Background()
+	ownerIDs, err := database.RecordReadingAlerts(ctx, map[string]models.Device{device.DeviceID: device}, []models.SensorData{reading})
+	if err != nil {
+		log.Printf("Failed to record alert for device %s: %v", device.DeviceID, err)
+		return
+	}
+	for _, ownerID := range ownerIDs {
+		pushAlertCount(ctx, ownerID)
+	}
+}
@@ -0,0 +1,280 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/testutil"
+)
+
+// alertFixture 两个用户各自设备上的告警
+type alertFixture struct {
+	owner, other *models.User
+	base         time.Time
+	// 按创建顺序：mine-1 warning、mine-1 critical、mine-2 warning（已确认）、theirs-1 critical
+	alerts []models.Alert
+}
+
+// createAlertFixture 创建测试用户、设备和告警，告警产生时间间隔一小时
+func createAlertFixture(t *testing.T) alertFixture {
+	t.Helper()
+	f := alertFixture{
+		owner: createTestUser(t, "owner"),
+		other: createTestUser(t, "other"),
+		base:  time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC),
+	}
+	createTestDevice(t, models.Device{DeviceID: "mine-1", OwnerID: f.owner.ID})
+	createTestDevice(t, models.Device{DeviceID: "mine-2", OwnerID: f.owner.ID})
+	createTestDevice(t, models.Device{DeviceID: "theirs-1", OwnerID: f.other.ID})
+
+	ackedAt := f.base
+	for i, spec := range []struct {
+		deviceID, severity string
+		acknowledged       bool
+	}{
+		{"mine-1", models.AlertSeverityWarning, false},
+		{"mine-1", models.AlertSeverityCritical, false},
+		{"mine-2", models.AlertSeverityWarning, true},
+		{"theirs-1", models.AlertSeverityCritical, false},
+	} {
+		createdAt := f.base.Add(time.Duration(i) * time.Hour)
+		alert := models.Alert{
+			DeviceID:  spec.deviceID,
+			Severity:  spec.severity,
+			Fields:    []string{"temperature"},
+			Reading:   models.JSONB{"temperature": 99},
+			ReadingAt: createdAt,
+			CreatedAt: createdAt,
+		}
+		if spec.acknowledged {
+			alert.AcknowledgedBy = &f.owner.ID
+			alert.AcknowledgedAt = &ackedAt
+		}
+		if err := database.DB.Create(&alert).Error; err != nil {
+			t.Fatalf("create alert: %v", err)
+		}
+		f.alerts = append(f.alerts, alert)
+	}
+	return f
+}
+
+// listAlerts 以指定用户调用告警列表接口
+func listAlerts(t *testing.T, user *models.User, query url.Values) (int, AlertListResponse) {
+	t.Helper()
+	c, w := newJSONContext(t, "GET", "/api/v1/alerts?"+query.Encode(), nil, user)
+	NewAlertController().GetAlerts(c)
+	var body struct {
+		Data AlertListResponse `json:"data"`
+	}
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode response %q: %v", w.Body.String(), err)
+		}
+	}
+	return w.Code, body.Data
+}
+
+// alertIDs 返回告警ID列表
+func alertIDs(alerts []models.Alert) []uint {
+	ids := make([]uint, 0, len(alerts))
+	for _, alert := range alerts {
+		ids = append(ids, alert.ID)
+	}
+	return ids
+}
+
+// reloadAlert 从数据库重新读取告警
+func reloadAlert(t *testing.T, id uint) models.Alert {
+	t.Helper()
+	var alert models.Alert
+	if err := database.DB.First(&alert, id).Error; err != nil {
+		t.Fatalf("reload alert %d: %v", id, err)
+	}
+	return alert
+}
+
+func TestGetAlertsScopesAndFilters(t *testing.T) {
+	testutil.LoadConfig(t)
+	testutil.UseDB(t)
+	f := createAlertFixture(t)
+	a := f.alerts
+
+	for _, tc := range []struct {
+		name  string
+		query url.Values
+		want  []uint
+	}{
+		{"all", url.Values{}, []uint{a[2].ID, a[1].ID, a[0].ID}},
+		{"device", url.Values{"device_id": {"mine-1"}}, []uint{a[1].ID, a[0].ID}},
+		{"other user's device", url.Values{"device_id": {"theirs-1"}}, []uint{}},
+		{"severity", url.Values{"severity": {"warning"}}, []uint{a[2].ID, a[0].ID}},
+		{"acknowledged", url.Values{"acknowledged": {"true"}}, []uint{a[2].ID}},
+		{"unacknowledged", url.Values{"acknowledged": {"false"}}, []uint{a[1].ID, a[0].ID}},
+		{"since", url.Values{"since": {f.base.Add(time.Hour).Format(time.RFC3339)}}, []uint{a[2].ID, a[1].ID}},
+		{"until", url.Values{"until": {f.base.Add(time.Hour).Format(time.RFC3339)}}, []uint{a[0].ID}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			code, data := listAlerts(t, f.owner, tc.query)
+			if code != http.StatusOK {
+				t.Fatalf("status = %d", code)
+			}
+			if got := alertIDs(data.Alerts); fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Errorf("alerts = %v, want %v", got, tc.want)
+			}
+			if data.Total != int64(len(tc.want)) {
+				t.Errorf("total = %d, want %d", data.Total, len(tc.want))
+			}
+			// 未确认数统计用户全部设备，不受筛选条件影响
+			if data.Unacknowledged != 2 {
+				t.Errorf("unacknowledged = %d, want 2", data.Unacknowledged)
+			}
+		})
+	}
+
+	code, data := listAlerts(t, f.other, url.Values{})
+	if code != http.StatusOK || fmt.Sprint(alertIDs(data.Alerts)) != fmt.Sprint([]uint{a[3].ID}) || data.Unacknowledged != 1 {
+		t.Errorf("other user: status=%d alerts=%v unacknowledged=%d, want only %d", code, alertIDs(data.Alerts), data.Unacknowledged, a[3].ID)
+	}
+
+	for _, query := range []url.Values{
+		{"severity": {"info"}},
+		{"acknowledged": {"maybe"}},
+		{"since": {"yesterday"}},
+		{"until": {"2026-03-01"}},
+	} {
+		if code, _ := listAlerts(t, f.owner, query); code != http.StatusBadRequest {
+			t.Errorf("%v: status = %d, want %d", query, code, http.StatusBadRequest)
+		}
+	}
+}
+
+// ackAlert 以指定用户确认单条告警
+func ackAlert(t *testing.T, user *models.User, id string) (int, models.Alert) {
+	t.Helper()
+	c, w := newJSONContext(t, "POST", "/api/v1/alerts/"+id+"/ack", nil, user)
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	NewAlertController().AckAlert(c)
+	var body struct {
+		Data models.Alert `json:"data"`
+	}
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode response %q: %v", w.Body.String(), err)
+		}
+	}
+	return w.Code, body.Data
+}
+
+func TestAckAlert(t *testing.T) {
+	testutil.LoadConfig(t)
+	testutil.UseDB(t)
+	f := createAlertFixture(t)
+	target := f.alerts[0]
+
+	code, alert := ackAlert(t, f.owner, fmt.Sprint(target.ID))
+	if code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	if alert.AcknowledgedBy == nil || *alert.AcknowledgedBy != f.owner.ID || alert.AcknowledgedAt == nil {
+		t.Errorf("response acknowledged_by=%v acknowledged_at=%v, want the owner and a time", alert.AcknowledgedBy, alert.AcknowledgedAt)
+	}
+	stored := reloadAlert(t, target.ID)
+	if !stored.IsAcknowledged() || *stored.AcknowledgedBy != f.owner.ID {
+		t.Fatalf("stored alert not acknowledged by the owner: %+v", stored)
+	}
+
+	// 已确认的告警保持原确认人和确认时间
+	code, again := ackAlert(t, f.owner, fmt.Sprint(target.ID))
+	if code != http.StatusOK {
+		t.Fatalf("second ack status = %d", code)
+	}
+	if again.AcknowledgedAt == nil || !again.AcknowledgedAt.Equal(*stored.AcknowledgedAt) {
+		t.Errorf("second ack acknowledged_at = %v, want unchanged %v", again.AcknowledgedAt, stored.AcknowledgedAt)
+	}
+	preAcked := f.alerts[2]
+	if _, alert := ackAlert(t, f.owner, fmt.Sprint(preAcked.ID)); alert.AcknowledgedAt == nil || !alert.AcknowledgedAt.Equal(f.base) {
+		t.Errorf("re-acking an acknowledged alert changed acknowledged_at to %v", alert.AcknowledgedAt)
+	}
+
+	// 其他用户设备上的告警不可见
+	theirs := f.alerts[3]
+	if code, _ := ackAlert(t, f.owner, fmt.Sprint(theirs.ID)); code != http.StatusNotFound {
+		t.Errorf("acking another user's alert status = %d, want %d", code, http.StatusNotFound)
+	}
+	if stored := reloadAlert(t, theirs.ID); stored.IsAcknowledged() {
+		t.Error("another user's alert was acknowledged")
+	}
+	if code, _ := ackAlert(t, f.other, fmt.Sprint(f.alerts[1].ID)); code != http.StatusNotFound {
+		t.Errorf("acking the owner's alert as another user status = %d, want %d", code, http.StatusNotFound)
+	}
+	if code, _ := ackAlert(t, f.owner, "abc"); code != http.StatusBadRequest {
+		t.Errorf("invalid ID status = %d, want %d", code, http.StatusBadRequest)
+	}
+}
+
+// ackAllAlerts 以指定用户批量确认告警，body为nil时不带请求体
+func ackAllAlerts(t *testing.T, user *models.User, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	c, w := newJSONContext(t, "POST", "/api/v1/alerts/ack-all", body, user)
+	NewAlertController().AckAllAlerts(c)
+	return w.Code, decodeBody(t, w)
+}
+
+func TestAckAllAlerts(t *testing.T) {
+	testutil.LoadConfig(t)
+	testutil.UseDB(t)
+	f := createAlertFixture(t)
+	a := f.alerts
+
+	// 添加一条mine-2上较晚产生的critical告警，用于验证before条件
+	late := models.Alert{DeviceID: "mine-2", Severity: models.AlertSeverityCritical, ReadingAt: f.base, CreatedAt: f.base.Add(10 * time.Hour)}
+	if err := database.DB.Create(&late).Error; err != nil {
+		t.Fatalf("create alert: %v", err)
+	}
+
+	if code, _ := ackAllAlerts(t, f.owner, map[string]interface{}{"severity": "info"}); code != http.StatusBadRequest {
+		t.Errorf("invalid severity status = %d, want %d", code, http.StatusBadRequest)
+	}
+
+	for _, step := range []struct {
+		name  string
+		body  interface{}
+		want  float64
+		acked []uint
+	}{
+		{"device and severity", map[string]interface{}{"device_id": "mine-1", "severity": "critical"}, 1, []uint{a[1].ID}},
+		{"other user's device", map[string]interface{}{"device_id": "theirs-1"}, 0, nil},
+		{"before", map[string]interface{}{"before": f.base.Add(5 * time.Hour)}, 1, []uint{a[0].ID}},
+		{"no body", nil, 1, []uint{late.ID}},
+		{"nothing left", nil, 0, nil},
+	} {
+		code, body := ackAllAlerts(t, f.owner, step.body)
+		if code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %v", step.name, code, body)
+		}
+		data, _ := body["data"].(map[string]interface{})
+		if data["acknowledged"] != step.want {
+			t.Errorf("%s: acknowledged = %v, want %v", step.name, data["acknowledged"], step.want)
+		}
+		for _, id := range step.acked {
+			if alert := reloadAlert(t, id); alert.AcknowledgedBy == nil || *alert.AcknowledgedBy != f.owner.ID {
+				t.Errorf("%s: alert %d not acknowledged by the owner", step.name, id)
+			}
+		}
+	}
+
+	// 批量确认不影响其他用户的告警，也不改写已确认告警的确认时间
+	if theirs := reloadAlert(t, a[3].ID); theirs.IsAcknowledged() {
+		t.Error("another user's alert was acknowledged")
+	}
+	if acked := reloadAlert(t, a[2].ID); acked.AcknowledgedAt == nil || !acked.AcknowledgedAt.Equal(f.base) {
+		t.Errorf("already acknowledged alert acknowledged_at = %v, want %v", acked.AcknowledgedAt, f.base)
+	}
+}
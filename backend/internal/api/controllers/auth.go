@@ -6,6 +6,8 @@ import (
 	"time"
 	
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"iot-platform-backend/internal/config"
 	"iot-platform-backend/internal/database"
 	"iot-platform-backend/internal/middleware"
 	"iot-platform-backend/internal/models"
@@ -21,8 +23,14 @@ func NewAuthController() *AuthController {
 
 // LoginRequest 登录请求结构
 type LoginRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Username   string `json:"username" binding:"required"`
+	Password   string `json:"password" binding:"required"`
+	RememberMe bool   `json:"remember_me"` // 使用更长的有效期并下发httpOnly Cookie
+}
+
+// RefreshTokenRequest 刷新token请求，refresh token也可通过Authorization头或Cookie传递
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 // RegisterRequest 注册请求结构
@@ -31,14 +39,13 @@ type RegisterRequest struct {
 	Email    string `json:"email" binding:"omitempty,email"`
 	Phone    string `json:"phone" binding:"omitempty"`
 	Password string `json:"password" binding:"required,min=6"`
+	InviteCode string `json:"invite_code" binding:"omitempty,max=64"` // 邀请注册模式下必填
 }
 
 // LoginResponse 登录响应结构
 type LoginResponse struct {
-	User         UserInfo `json:"user"`
-	AccessToken  string   `json:"access_token"`
-	RefreshToken string   `json:"refresh_token"`
-	ExpiresIn    int64    `json:"expires_in"`
+	User UserInfo `json:"user"`
+	SessionTokens
 }
 
 // UserInfo 用户信息结构
@@ -65,15 +72,11 @@ type UserInfo struct {
 // @Router /auth/login [post]
 func (ctrl *AuthController) Login(c *gin.Context) {
 	var req LoginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request format",
-			"details": err.Error(),
-		})
+	if !bindJSON(c, &req) {
 		return
 	}
 	
-	db := database.GetDB()
+	db := database.GetDBWithContext(c.Request.Context())
 	var user models.User
 	
 	// 支持用户名、邮箱或手机号登录
@@ -104,7 +107,7 @@ func (ctrl *AuthController) Login(c *gin.Context) {
 	}
 	
 	// 生成JWT token
-	accessToken, err := middleware.GenerateToken(user.ID, user.Username, user.Role)
+	session, err := issueSession(c, &user, req.RememberMe, "")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to generate token",
@@ -112,14 +115,6 @@ func (ctrl *AuthController) Login(c *gin.Context) {
 		return
 	}
 	
-	refreshToken, err := middleware.GenerateRefreshToken(user.ID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to generate refresh token",
-		})
-		return
-	}
-	
 	// 更新最后登录时间
 	user.UpdateLastLogin(db)
 	
@@ -137,9 +132,7 @@ func (ctrl *AuthController) Login(c *gin.Context) {
 			Role:     user.Role,
 			Active:   user.Active,
 		},
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		ExpiresIn:    int64(time.Hour * 24 / time.Second), // 24小时，转换为秒
+		SessionTokens: session,
 	}
 	
 	c.JSON(http.StatusOK, gin.H{
@@ -158,19 +151,40 @@ func (ctrl *AuthController) Login(c *gin.Context) {
 // @Param request body RegisterRequest true "注册信息"
 // @Success 201 {object} UserInfo
 // @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
 // @Failure 409 {object} map[string]interface{}
 // @Router /auth/register [post]
 func (ctrl *AuthController) Register(c *gin.Context) {
 	var req RegisterRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request format",
-			"details": err.Error(),
+	if !bindJSON(c, &req) {
+		return
+	}
+	
+	account := config.AppConfig.Account
+	switch account.RegistrationMode {
+	case config.RegistrationClosed:
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Registration is closed",
+		})
+		return
+	case config.RegistrationInviteOnly:
+		if req.InviteCode == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Invite code is required",
+			})
+			return
+		}
+	}
+	
+	// 保留的用户名不可注册，避免冒充占位账号
+	if models.IsReservedUsername(req.Username) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Username already exists",
 		})
 		return
 	}
 	
-	db := database.GetDB()
+	db := database.GetDBWithContext(c.Request.Context())
 	
 	// 检查用户名是否已存在
 	var existingUser models.User
@@ -207,11 +221,26 @@ func (ctrl *AuthController) Register(c *gin.Context) {
 		Email:    req.Email,
 		Phone:    req.Phone,
 		Password: req.Password, // 会在BeforeCreate中自动加密
-		Role:     "user",
+		Role:     account.DefaultRole,
 		Active:   true,
 	}
 	
-	if err := db.Create(&user).Error; err != nil {
+	// 邀请码与用户在同一事务中消费，创建失败时不占用次数
+	err := database.TransactionWithContext(c.Request.Context(), func(tx *gorm.DB) error {
+		if account.RegistrationMode == config.RegistrationInviteOnly {
+			if err := consumeInvite(tx, req.InviteCode); err != nil {
+				return err
+			}
+		}
+		return tx.Create(&user).Error
+	})
+	if err != nil {
+		if isInviteError(err) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to create user",
 		})
@@ -237,16 +266,28 @@ func (ctrl *AuthController) Register(c *gin.Context) {
 
 // RefreshToken 刷新访问令牌
 // @Summary 刷新访问令牌
-// @Description 使用刷新令牌获取新的访问令牌
+// @Description 使用刷新令牌获取新的访问令牌，同时轮换刷新令牌（滑动续期）；刷新令牌可放在请求体、Authorization头或Cookie中
 // @Tags 认证
 // @Accept json
 // @Produce json
-// @Param Authorization header string true "Bearer refresh_token"
-// @Success 200 {object} map[string]interface{}
+// @Param request body RefreshTokenRequest false "刷新令牌"
+// @Param Authorization header string false "Bearer refresh_token"
+// @Success 200 {object} SessionTokens
 // @Failure 401 {object} map[string]interface{}
 // @Router /auth/refresh [post]
 func (ctrl *AuthController) RefreshToken(c *gin.Context) {
-	refreshToken := extractTokenFromHeader(c)
+	var req RefreshTokenRequest
+	if c.Request.ContentLength > 0 && !bindJSON(c, &req) {
+		return
+	}
+	
+	refreshToken := req.RefreshToken
+	if refreshToken == "" {
+		refreshToken = extractTokenFromHeader(c)
+	}
+	if refreshToken == "" {
+		refreshToken, _ = c.Cookie(refreshTokenCookie)
+	}
 	if refreshToken == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error": "Missing refresh token",
@@ -254,30 +295,77 @@ func (ctrl *AuthController) RefreshToken(c *gin.Context) {
 		return
 	}
 	
-	// TODO: 实现refresh token解析和验证逻辑
-	// 这里需要解析refresh token并生成新的access token
+	claims, err := middleware.ParseToken(refreshToken)
+	if err != nil || claims.TokenType != middleware.TokenTypeRefresh {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid refresh token",
+		})
+		return
+	}
+	if middleware.IsRevoked(c, refreshToken, claims) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Token has been revoked",
+		})
+		return
+	}
+	
+	var user models.User
+	if err := database.GetDBWithContext(c.Request.Context()).First(&user, claims.UserID).Error; err != nil || !user.Active {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Account is unavailable",
+		})
+		return
+	}
+	// 用户所属租户变更后，旧租户下签发的refresh token不再可用
+	if claims.TenantID != user.TenantID {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid refresh token",
+		})
+		return
+	}
+	
+	// 滑动续期：每次刷新签发新的refresh token并吊销旧的，活跃用户不会被登出；会话ID保持不变
+	session, err := issueSession(c, &user, claims.Remember, claims.SessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to generate token",
+		})
+		return
+	}
+	revokeToken(c, refreshToken, claims)
 	
 	c.JSON(http.StatusOK, gin.H{
-		"access_token": "new_access_token",
-		"expires_in":   3600,
+		"status": 1,
+		"msg":    "刷新成功",
+		"data":   session,
 	})
 }
 
 // Logout 用户登出
 // @Summary 用户登出
-// @Description 用户登出，将token加入黑名单
+// @Description 用户登出，将token加入黑名单并删除当前会话
 // @Tags 认证
 // @Security BearerAuth
 // @Success 200 {object} map[string]interface{}
 // @Router /auth/logout [post]
 func (ctrl *AuthController) Logout(c *gin.Context) {
 	// 获取当前token
-	token := extractTokenFromHeader(c)
-	if token != "" {
-		// TODO: 将token加入Redis黑名单
-		cache := database.NewCache()
-		cache.Set(c, "blacklist:"+token, true, 24*time.Hour)
+	// 将access token和refresh token加入Redis黑名单，保留至token自然过期
+	if token := extractTokenFromHeader(c); token != "" {
+		claims, _ := middleware.ParseToken(token)
+		revokeToken(c, token, claims)
+	} else if token, err := c.Cookie(accessTokenCookie); err == nil && token != "" {
+		claims, _ := middleware.ParseToken(token)
+		revokeToken(c, token, claims)
 	}
+	if token, err := c.Cookie(refreshTokenCookie); err == nil && token != "" {
+		claims, _ := middleware.ParseToken(token)
+		revokeToken(c, token, claims)
+	}
+	if sessionID := middleware.GetSessionID(c); sessionID != "" {
+		database.DeleteSession(c, middleware.GetUserID(c), sessionID)
+	}
+	clearSessionCookies(c)
 	
 	c.JSON(http.StatusOK, gin.H{
 		"status": 1,
@@ -295,7 +383,7 @@ func (ctrl *AuthController) Logout(c *gin.Context) {
 // @Failure 401 {object} map[string]interface{}
 // @Router /auth/me [get]
 func (ctrl *AuthController) Me(c *gin.Context) {
-	userID, username, role, authenticated := middleware.GetCurrentUser(c)
+	userID, _, _, authenticated := middleware.GetCurrentUser(c)
 	if !authenticated {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error": "Authentication required",
@@ -309,7 +397,7 @@ func (ctrl *AuthController) Me(c *gin.Context) {
 	
 	if err := cache.Get(c, database.Keys.User(userID), &user); err != nil {
 		// 缓存未命中，从数据库获取
-		db := database.GetDB()
+		db := database.GetDBWithContext(c.Request.Context())
 		if err := db.First(&user, userID).Error; err != nil {
 			c.JSON(http.StatusNotFound, gin.H{
 				"error": "User not found",
@@ -354,11 +442,7 @@ func (ctrl *AuthController) ChangePassword(c *gin.Context) {
 		NewPassword     string `json:"new_password" binding:"required,min=6"`
 	}
 	
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request format",
-			"details": err.Error(),
-		})
+	if !bindJSON(c, &req) {
 		return
 	}
 	
@@ -370,7 +454,7 @@ func (ctrl *AuthController) ChangePassword(c *gin.Context) {
 		return
 	}
 	
-	db := database.GetDB()
+	db := database.GetDBWithContext(c.Request.Context())
 	var user models.User
 	
 	if err := db.First(&user, userID).Error; err != nil {
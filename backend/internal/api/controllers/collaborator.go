@@ -0,0 +1,365 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+)
+
+// 当前用户对项目的访问角色
+const (
+	projectRoleNone   = ""
+	projectRoleOwner  = "owner"
+	projectRoleAdmin  = "admin"
+	projectRoleEditor = models.CollaboratorEditor
+	projectRoleViewer = models.CollaboratorViewer
+)
+
+// AddCollaboratorRequest 添加协作者请求，user_id和username二选一
+type AddCollaboratorRequest struct {
+	UserID   uint   `json:"user_id"`
+	Username string `json:"username"`
+	Role     string `json:"role" binding:"required,oneof=viewer editor"`
+}
+
+// UpdateCollaboratorRequest 修改协作者角色请求
+type UpdateCollaboratorRequest struct {
+	Role string `json:"role" binding:"required,oneof=viewer editor"`
+}
+
+// projectRole 返回当前用户对项目的角色：owner、admin、editor、viewer，无权限时为空
+func projectRole(c *gin.Context, db *gorm.DB, project *models.Project) string {
+	userID := middleware.GetUserID(c)
+	switch {
+	case userID != 0 && project.OwnerID == userID:
+		return projectRoleOwner
+	case middleware.IsAdminOf(c, project.OwnerID):
+		return projectRoleAdmin
+	case userID == 0:
+		return projectRoleNone
+	}
+	
+	var roles []string
+	db.Model(&models.ProjectCollaborator{}).
+		Where("project_id = ? AND user_id = ?", project.ID, userID).
+		Limit(1).
+		Pluck("role", &roles)
+	if len(roles) == 0 {
+		return projectRoleNone
+	}
+	return roles[0]
+}
+
+// canViewProject 公开项目或任意角色均可查看
+func canViewProject(role string, project *models.Project) bool {
+	return project.Public || role != projectRoleNone
+}
+
+// canEditProject 拥有者、管理员和编辑者可修改项目
+func canEditProject(role string) bool {
+	return role == projectRoleOwner || role == projectRoleAdmin || role == projectRoleEditor
+}
+
+// GetProjectCollaborators 获取项目协作者列表
+// @Summary 获取项目协作者
+// @Tags 项目管理
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "项目ID"
+// @Success 200 {object} []models.ProjectCollaborator
+// @Failure 403 {object} map[string]interface{}
+// @Router /projects/{id}/collaborators [get]
+func (ctrl *ProjectController) GetProjectCollaborators(c *gin.Context) {
+	project, ok := loadProjectParam(c)
+	if !ok {
+		return
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	if !canViewProject(projectRole(c, db, project), project) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Access denied",
+		})
+		return
+	}
+	
+	var collaborators []models.ProjectCollaborator
+	if err := db.Preload("User").
+		Where("project_id = ?", project.ID).
+		Order("created_at ASC").
+		Find(&collaborators).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch collaborators",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"data":   collaborators,
+	})
+}
+
+// AddProjectCollaborator 添加项目协作者
+// @Summary 添加项目协作者
+// @Description 项目拥有者或管理员邀请用户以viewer或editor角色协作
+// @Tags 项目管理
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "项目ID"
+// @Param request body AddCollaboratorRequest true "协作者信息"
+// @Success 201 {object} models.ProjectCollaborator
+// @Failure 400 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /projects/{id}/collaborators [post]
+func (ctrl *ProjectController) AddProjectCollaborator(c *gin.Context) {
+	project, ok := loadProjectParam(c)
+	if !ok {
+		return
+	}
+	
+	var req AddCollaboratorRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if req.UserID == 0 && req.Username == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "user_id or username is required",
+		})
+		return
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	var user models.User
+	query := db.Where("active = ?", true)
+	if req.UserID != 0 {
+		query = query.Where("id = ?", req.UserID)
+	} else {
+		query = query.Where("username = ?", req.Username)
+	}
+	if err := query.First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "User not found",
+		})
+		return
+	}
+	if user.ID == project.OwnerID {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Project owner cannot be added as a collaborator",
+		})
+		return
+	}
+	
+	collaborator := models.ProjectCollaborator{
+		ProjectID: project.ID,
+		UserID:    user.ID,
+		Role:      req.Role,
+		AddedBy:   middleware.GetUserID(c),
+	}
+	err := database.TransactionWithContext(c.Request.Context(), func(tx *gorm.DB) error {
+		if err := tx.Create(&collaborator).Error; err != nil {
+			return err
+		}
+		return recordCollaboratorHistory(c, tx, project.ID, "collaborator_add", models.JSONB{
+			"user_id":  user.ID,
+			"username": user.Username,
+			"role":     req.Role,
+		}, "添加协作者 "+user.Username)
+	})
+	if err != nil {
+		if database.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "User is already a collaborator",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to add collaborator",
+		})
+		return
+	}
+	
+	collaborator.User = models.PublicUser{ID: user.ID, Username: user.Username, Avatar: user.Avatar, Role: user.Role}
+	c.JSON(http.StatusCreated, gin.H{
+		"status": 1,
+		"msg":    "协作者添加成功",
+		"data":   collaborator,
+	})
+}
+
+// UpdateProjectCollaborator 修改协作者角色
+// @Summary 修改协作者角色
+// @Tags 项目管理
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "项目ID"
+// @Param user_id path int true "协作者用户ID"
+// @Param request body UpdateCollaboratorRequest true "新角色"
+// @Success 200 {object} models.ProjectCollaborator
+// @Failure 404 {object} map[string]interface{}
+// @Router /projects/{id}/collaborators/{user_id} [put]
+func (ctrl *ProjectController) UpdateProjectCollaborator(c *gin.Context) {
+	project, ok := loadProjectParam(c)
+	if !ok {
+		return
+	}
+	
+	var req UpdateCollaboratorRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	
+	collaborator, ok := loadCollaboratorParam(c, project.ID)
+	if !ok {
+		return
+	}
+	if collaborator.Role == req.Role {
+		c.JSON(http.StatusOK, gin.H{
+			"status": 1,
+			"data":   collaborator,
+		})
+		return
+	}
+	
+	oldRole := collaborator.Role
+	err := database.TransactionWithContext(c.Request.Context(), func(tx *gorm.DB) error {
+		if err := tx.Model(collaborator).Update("role", req.Role).Error; err != nil {
+			return err
+		}
+		return recordCollaboratorHistory(c, tx, project.ID, "collaborator_update", models.JSONB{
+			"user_id":  collaborator.UserID,
+			"old_role": oldRole,
+			"role":     req.Role,
+		}, "修改协作者角色")
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update collaborator",
+		})
+		return
+	}
+	
+	collaborator.Role = req.Role
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"msg":    "协作者角色已更新",
+		"data":   collaborator,
+	})
+}
+
+// RemoveProjectCollaborator 移除协作者，协作者也可以主动退出
+// @Summary 移除项目协作者
+// @Tags 项目管理
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "项目ID"
+// @Param user_id path int true "协作者用户ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /projects/{id}/collaborators/{user_id} [delete]
+func (ctrl *ProjectController) RemoveProjectCollaborator(c *gin.Context) {
+	project, ok := loadProjectParam(c)
+	if !ok {
+		return
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	role := projectRole(c, db, project)
+	
+	collaborator, ok := loadCollaboratorParam(c, project.ID)
+	if !ok {
+		return
+	}
+	
+	self := collaborator.UserID == middleware.GetUserID(c)
+	if role != projectRoleOwner && role != projectRoleAdmin && !self {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Access denied",
+		})
+		return
+	}
+	
+	err := database.TransactionWithContext(c.Request.Context(), func(tx *gorm.DB) error {
+		if err := tx.Delete(collaborator).Error; err != nil {
+			return err
+		}
+		return recordCollaboratorHistory(c, tx, project.ID, "collaborator_remove", models.JSONB{
+			"user_id": collaborator.UserID,
+			"role":    collaborator.Role,
+		}, "移除协作者")
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to remove collaborator",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"msg":    "协作者已移除",
+	})
+}
+
+// loadProjectParam 按路径参数id加载项目，失败时已写入响应
+func loadProjectParam(c *gin.Context) (*models.Project, bool) {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid project ID",
+		})
+		return nil, false
+	}
+	
+	var project models.Project
+	if err := database.GetDBWithContext(c.Request.Context()).First(&project, uint(projectID)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Project not found",
+		})
+		return nil, false
+	}
+	return &project, true
+}
+
+// loadCollaboratorParam 按路径参数user_id加载项目协作者，失败时已写入响应
+func loadCollaboratorParam(c *gin.Context, projectID uint) (*models.ProjectCollaborator, bool) {
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid user ID",
+		})
+		return nil, false
+	}
+	
+	var collaborator models.ProjectCollaborator
+	if err := database.GetDBWithContext(c.Request.Context()).
+		Where("project_id = ? AND user_id = ?", projectID, uint(userID)).
+		First(&collaborator).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Collaborator not found",
+		})
+		return nil, false
+	}
+	return &collaborator, true
+}
+
+// recordCollaboratorHistory 在项目历史中记录协作者变更
+func recordCollaboratorHistory(c *gin.Context, tx *gorm.DB, projectID uint, action string, details models.JSONB, message string) error {
+	return tx.Create(&models.ForkHistory{
+		ProjectID:  projectID,
+		UserID:     middleware.GetUserID(c),
+		Action:     action,
+		ConfigDiff: details,
+		Message:    message,
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.GetHeader("User-Agent"),
+	}).Error
+}
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/testutil"
+)
+
+func TestProjectRolePermissions(t *testing.T) {
+	private := &models.Project{}
+	public := &models.Project{Public: true}
+
+	tests := []struct {
+		role        string
+		canEdit     bool
+		viewPrivate bool
+	}{
+		{projectRoleOwner, true, true},
+		{projectRoleAdmin, true, true},
+		{projectRoleEditor, true, true},
+		{projectRoleViewer, false, true},
+		{projectRoleNone, false, false},
+	}
+	for _, tt := range tests {
+		if got := canEditProject(tt.role); got != tt.canEdit {
+			t.Errorf("canEditProject(%q) = %v, want %v", tt.role, got, tt.canEdit)
+		}
+		if got := canViewProject(tt.role, private); got != tt.viewPrivate {
+			t.Errorf("canViewProject(%q, private) = %v, want %v", tt.role, got, tt.viewPrivate)
+		}
+		if !canViewProject(tt.role, public) {
+			t.Errorf("canViewProject(%q, public) = false, want true", tt.role)
+		}
+	}
+}
+
+func TestProjectCollaboratorRoles(t *testing.T) {
+	testutil.LoadConfig(t)
+	testutil.UseRedis(t)
+	db := testutil.UseDB(t)
+
+	owner := createTestUser(t, "owner")
+	editor := createTestUser(t, "editor")
+	viewer := createTestUser(t, "viewer")
+	stranger := createTestUser(t, "stranger")
+	project := createTestProject(t, models.Project{Name: "p", Config: models.JSONB{"threshold": 10}, OwnerID: owner.ID})
+	projectID := fmt.Sprint(project.ID)
+	ctrl := NewProjectController()
+
+	call := func(handler gin.HandlerFunc, method, path string, body interface{}, user *models.User, params ...gin.Param) *httptest.ResponseRecorder {
+		t.Helper()
+		c, w := newJSONContext(t, method, "/api/v1/projects/"+projectID+path, body, user)
+		c.Params = append(gin.Params{{Key: "id", Value: projectID}}, params...)
+		handler(c)
+		return w
+	}
+	historyCount := func(action string) int64 {
+		t.Helper()
+		var count int64
+		db.Model(&models.ForkHistory{}).Where("project_id = ? AND action = ?", project.ID, action).Count(&count)
+		return count
+	}
+
+	for _, collaborator := range []struct {
+		user *models.User
+		role string
+	}{{editor, models.CollaboratorEditor}, {viewer, models.CollaboratorViewer}} {
+		w := call(ctrl.AddProjectCollaborator, "POST", "/collaborators", AddCollaboratorRequest{Username: collaborator.user.Username, Role: collaborator.role}, owner)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("add %s status = %d: %s", collaborator.role, w.Code, w.Body.String())
+		}
+	}
+	if got := historyCount("collaborator_add"); got != 2 {
+		t.Errorf("%d collaborator_add history row(s), want 2", got)
+	}
+	if w := call(ctrl.AddProjectCollaborator, "POST", "/collaborators", AddCollaboratorRequest{UserID: editor.ID, Role: models.CollaboratorViewer}, owner); w.Code != http.StatusConflict {
+		t.Errorf("adding an existing collaborator status = %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	// 编辑者可以修改配置，但不能修改可见性
+	w := call(ctrl.UpdateProject, "PUT", "", gin.H{"config": gin.H{"threshold": 20}, "public": true}, editor)
+	if w.Code != http.StatusOK {
+		t.Fatalf("editor update status = %d: %s", w.Code, w.Body.String())
+	}
+	var stored models.Project
+	if err := db.First(&stored, project.ID).Error; err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if stored.Config["threshold"] != float64(20) || stored.Public {
+		t.Errorf("after editor update config=%v public=%v, want threshold 20 and still private", stored.Config, stored.Public)
+	}
+
+	// 查看者可以查看私有项目但不能修改；无关用户看不到项目
+	if w := call(ctrl.GetProject, "GET", "", nil, viewer); w.Code != http.StatusOK {
+		t.Errorf("viewer get status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if w := call(ctrl.UpdateProject, "PUT", "", gin.H{"config": gin.H{"threshold": 30}}, viewer); w.Code != http.StatusForbidden {
+		t.Errorf("viewer update status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := call(ctrl.UpdateProject, "PUT", "", gin.H{"config": gin.H{"threshold": 30}}, stranger); w.Code != http.StatusNotFound {
+		t.Errorf("stranger update status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if err := db.First(&stored, project.ID).Error; err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if stored.Config["threshold"] != float64(20) {
+		t.Errorf("config = %v after rejected updates, want threshold 20", stored.Config)
+	}
+
+	// 升级为编辑者后可以修改，角色变更写入历史
+	viewerParam := gin.Param{Key: "user_id", Value: fmt.Sprint(viewer.ID)}
+	if w := call(ctrl.UpdateProjectCollaborator, "PUT", "/collaborators/"+viewerParam.Value, UpdateCollaboratorRequest{Role: models.CollaboratorEditor}, owner, viewerParam); w.Code != http.StatusOK {
+		t.Fatalf("promote status = %d: %s", w.Code, w.Body.String())
+	}
+	if got := historyCount("collaborator_update"); got != 1 {
+		t.Errorf("%d collaborator_update history row(s), want 1", got)
+	}
+	if w := call(ctrl.UpdateProject, "PUT", "", gin.H{"config": gin.H{"threshold": 30}}, viewer); w.Code != http.StatusOK {
+		t.Errorf("promoted collaborator update status = %d: %s", w.Code, w.Body.String())
+	}
+
+	// 协作者不能移除他人，但可以主动退出
+	editorParam := gin.Param{Key: "user_id", Value: fmt.Sprint(editor.ID)}
+	if w := call(ctrl.RemoveProjectCollaborator, "DELETE", "/collaborators/"+editorParam.Value, nil, viewer, editorParam); w.Code != http.StatusForbidden {
+		t.Errorf("removing another collaborator status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := call(ctrl.RemoveProjectCollaborator, "DELETE", "/collaborators/"+editorParam.Value, nil, editor, editorParam); w.Code != http.StatusOK {
+		t.Fatalf("leave status = %d: %s", w.Code, w.Body.String())
+	}
+	var removed models.ForkHistory
+	if err := db.Where("project_id = ? AND action = ?", project.ID, "collaborator_remove").First(&removed).Error; err != nil {
+		t.Fatalf("collaborator_remove history row missing: %v", err)
+	}
+	if removed.UserID != editor.ID || removed.ConfigDiff["user_id"] != float64(editor.ID) {
+		t.Errorf("remove history = %+v, want it recorded by and about the editor", removed)
+	}
+	if w := call(ctrl.UpdateProject, "PUT", "", gin.H{"config": gin.H{"threshold": 40}}, editor); w.Code != http.StatusNotFound {
+		t.Errorf("update after leaving status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
@@ -1,17 +1,31 @@
 package controllers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 	
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"iot-platform-backend/internal/config"
 	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/ingest"
 	"iot-platform-backend/internal/middleware"
 	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/shutdown"
+	"iot-platform-backend/internal/webhook"
 	"iot-platform-backend/internal/websocket"
+	"github.com/lib/pq"
 )
 
+// deviceTypesCacheTTL 设备类型元数据缓存时长，元数据随版本发布变化
+const deviceTypesCacheTTL = 10 * time.Minute
+
 // DeviceController 设备控制器
 type DeviceController struct{}
 
@@ -24,9 +38,11 @@ func NewDeviceController() *DeviceController {
 type CreateDeviceRequest struct {
 	DeviceID string                 `json:"device_id" binding:"required"`
 	Name     string                 `json:"name" binding:"required"`
-	Type     models.DeviceType      `json:"type" binding:"required,min=1,max=13"`
+	Type     models.DeviceType      `json:"type" binding:"required,device_type"`
 	Location models.JSONB           `json:"location"`
 	Config   models.JSONB           `json:"config"`
+	Tags     []string               `json:"tags"`
+	DedupEnabled bool               `json:"dedup_enabled"`
 }
 
 // UpdateDeviceRequest 更新设备请求
@@ -34,8 +50,29 @@ type UpdateDeviceRequest struct {
 	Name     string       `json:"name"`
 	Location models.JSONB `json:"location"`
 	Config   models.JSONB `json:"config"`
+	Tags     []string     `json:"tags"`
+	DedupEnabled *bool    `json:"dedup_enabled"`
+	Maintenance      *bool      `json:"maintenance"`       // 进入或退出维护模式
+	MaintenanceUntil *time.Time `json:"maintenance_until"` // 维护结束时间，只给出结束时间时视为进入维护模式
+	FirmwareVersion  *string    `json:"firmware_version"`  // 手动设置固件版本，空字符串表示清除；设备上报的版本会覆盖
+}
+
+// PatchDeviceRequest 部分更新设备请求
+// 字段缺失表示不修改；字段为null时清空（名称不能清空），有值时按给定值更新，空字符串、空对象和空数组同样表示清空
+type PatchDeviceRequest struct {
+	Name             Optional[string]       `json:"name" swaggertype:"string"`
+	Location         Optional[models.JSONB] `json:"location" swaggertype:"object"`
+	Config           Optional[models.JSONB] `json:"config" swaggertype:"object"`
+	Tags             Optional[[]string]     `json:"tags" swaggertype:"array,string"`
+	DedupEnabled     Optional[bool]         `json:"dedup_enabled" swaggertype:"boolean"` // null恢复为默认值false
+	Maintenance      Optional[bool]         `json:"maintenance" swaggertype:"boolean"` // null表示退出维护模式
+	MaintenanceUntil Optional[time.Time]    `json:"maintenance_until" swaggertype:"string"` // null清除结束时间（改为手动结束），不改变维护状态
+	FirmwareVersion  Optional[string]       `json:"firmware_version" swaggertype:"string"`
 }
 
+// maxDeviceNameLength 设备名称的最大长度
+const maxDeviceNameLength = 100
+
 // DeviceListResponse 设备列表响应
 type DeviceListResponse struct {
 	Devices []models.Device `json:"devices"`
@@ -44,6 +81,20 @@ type DeviceListResponse struct {
 	Limit   int             `json:"limit"`
 }
 
+// DeviceDetailResponse 设备详情响应（包含数据新鲜度）
+type DeviceDetailResponse struct {
+	models.Device
+	IsOnline             bool                  `json:"is_online"`
+	SecondsSinceLastSeen *int64                `json:"seconds_since_last_seen"`
+	LatestReading        *LatestReadingSummary `json:"latest_reading"`
+}
+
+// LatestReadingSummary 最新传感器数据摘要
+type LatestReadingSummary struct {
+	Timestamp time.Time `json:"timestamp"`
+	Keys      []string  `json:"keys"`
+}
+
 // GetDevices 获取设备列表
 // @Summary 获取设备列表
 // @Description 获取用户的设备列表，支持分页和筛选
@@ -55,7 +106,14 @@ type DeviceListResponse struct {
 // @Param type query int false "设备类型筛选"
 // @Param name query string false "设备名称筛选"
 // @Param status query string false "设备状态筛选"
+// @Param tag query string false "标签筛选"
+// @Param search query string false "关键词（名称、设备标识或标签）"
+// @Param last_seen_before query string false "最后通信时间早于（RFC3339）" format(date-time)
+// @Param last_seen_after query string false "最后通信时间不早于（RFC3339）" format(date-time)
+// @Param group_id query string false "分组ID（包含子孙分组），none表示未分组设备"
+// @Param firmware_version query string false "固件版本，none表示未知版本的设备"
 // @Success 200 {object} DeviceListResponse
+// @Failure 400 {object} map[string]interface{}
 // @Router /devices [get]
 func (ctrl *DeviceController) GetDevices(c *gin.Context) {
 	userID := middleware.GetUserID(c)
@@ -67,26 +125,14 @@ func (ctrl *DeviceController) GetDevices(c *gin.Context) {
 	}
 	
 	// 解析分页参数
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	if page < 1 {
-		page = 1
-	}
-	if limit < 1 || limit > 100 {
-		limit = 10
-	}
-	
-	offset := (page - 1) * limit
-	
-	// 尝试从缓存获取
-	cache := database.NewCache()
-	cacheKey := database.Keys.DeviceList(userID)
+	pagination := parseListPagination(c)
+	page, limit, offset := pagination.Page, pagination.Limit, pagination.Offset
 	
 	var devices []models.Device
 	var total int64
 	
 	// 构建查询
-	db := database.GetDB()
+	db := database.GetDBWithContext(c.Request.Context())
 	query := db.Where("owner_id = ?", userID)
 	
 	// 应用筛选
@@ -104,6 +150,56 @@ func (ctrl *DeviceController) GetDevices(c *gin.Context) {
 		query = query.Where("status = ?", status)
 	}
 	
+	// 标签筛选
+	if tag := c.Query("tag"); tag != "" {
+		query = query.Where("? = ANY(tags)", tag)
+	}
+	
+	// 分组筛选，包含子孙分组中的设备
+	if groupParam := c.Query("group_id"); groupParam != "" {
+		if groupParam == "none" {
+			query = query.Where("group_id IS NULL")
+		} else if groupID, err := strconv.ParseUint(groupParam, 10, 32); err == nil {
+			groupIDs, err := groupSubtreeIDs(db, uint(groupID))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Failed to resolve group hierarchy",
+				})
+				return
+			}
+			query = query.Where("group_id IN ?", groupIDs)
+		}
+	}
+	
+	// 关键词搜索（名称、设备标识或标签）
+	if search := c.Query("search"); search != "" {
+		query = query.Scopes(deviceSearchScope(search))
+	}
+	
+	// 固件版本筛选
+	if version := c.Query("firmware_version"); version != "" {
+		if version == "none" {
+			version = ""
+		}
+		query = query.Where("firmware_version = ?", version)
+	}
+	
+	// 最后通信时间范围（RFC3339），从未通信的设备不在范围内
+	for param, condition := range map[string]string{"last_seen_before": "last_seen < ?", "last_seen_after": "last_seen >= ?"} {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": param + " must be an RFC3339 time",
+			})
+			return
+		}
+		query = query.Where(condition, t)
+	}
+	
 	// 获取总数
 	query.Model(&models.Device{}).Count(&total)
 	
@@ -115,13 +211,10 @@ func (ctrl *DeviceController) GetDevices(c *gin.Context) {
 		return
 	}
 	
-	// 更新设备状态（基于最后通信时间）
+	// 更新设备状态（基于维护模式和最后通信时间）
+	now := time.Now()
 	for i := range devices {
-		if devices[i].IsOnline() {
-			devices[i].Status = "online"
-		} else {
-			devices[i].Status = "offline"
-		}
+		devices[i].Status = devices[i].DisplayStatus(now)
 	}
 	
 	response := DeviceListResponse{
@@ -131,6 +224,8 @@ func (ctrl *DeviceController) GetDevices(c *gin.Context) {
 		Limit:   limit,
 	}
 	
+	setPaginationHeaders(c, total, page, limit)
+	
 	c.JSON(http.StatusOK, gin.H{
 		"status": 1,
 		"data":   response,
@@ -143,40 +238,72 @@ func (ctrl *DeviceController) GetDevices(c *gin.Context) {
 // @Tags 设备管理
 // @Security BearerAuth
 // @Produce json
-// @Param id path int true "设备ID"
-// @Success 200 {object} models.Device
+// @Param id path string true "设备ID或device_id"
+// @Success 200 {object} DeviceDetailResponse
 // @Failure 404 {object} map[string]interface{}
 // @Router /devices/{id} [get]
 func (ctrl *DeviceController) GetDevice(c *gin.Context) {
 	userID := middleware.GetUserID(c)
-	deviceID, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid device ID",
-		})
-		return
-	}
-	
-	db := database.GetDB()
+	db := database.GetDBWithContext(c.Request.Context())
 	var device models.Device
 	
 	// 查询设备并验证所有权
-	if err := db.Where("id = ? AND owner_id = ?", uint(deviceID), userID).First(&device).Error; err != nil {
+	if err := db.Scopes(deviceParam(c, false)).Where("owner_id = ?", userID).First(&device).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": "Device not found",
 		})
 		return
 	}
 	
+	// 按配置在读取时将旧结构版本的配置升级到最新版本，失败时返回原配置，下次读取再试
+	if config.AppConfig.Devices.MigrateConfigOnRead {
+		migrateDeviceConfig(c.Request.Context(), &device, userID)
+	}
+	
+	isOnline := device.IsOnline()
+	device.Status = device.DisplayStatus(time.Now())
+	
+	response := DeviceDetailResponse{
+		Device:               device,
+		IsOnline:             isOnline,
+		SecondsSinceLastSeen: device.SecondsSinceLastSeen(),
+		LatestReading:        latestReadingSummary(c.Request.Context(), device.DeviceID),
+	}
+	
 	c.JSON(http.StatusOK, gin.H{
 		"status": 1,
-		"data":   device,
+		"data":   response,
 	})
 }
 
+// latestReadingSummary 查询设备最新一条传感器数据的摘要（走device_id+timestamp索引）
+func latestReadingSummary(ctx context.Context, deviceID string) *LatestReadingSummary {
+	db := database.GetDBWithContext(ctx)
+	var latest models.SensorData
+	
+	if err := db.Select("timestamp", "data").
+		Where("device_id = ?", deviceID).
+		Order("timestamp DESC").
+		Limit(1).
+		Take(&latest).Error; err != nil {
+		return nil
+	}
+	
+	keys := make([]string, 0, len(latest.Data))
+	for key := range latest.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	
+	return &LatestReadingSummary{
+		Timestamp: latest.Timestamp,
+		Keys:      keys,
+	}
+}
+
 // CreateDevice 创建设备
 // @Summary 创建新设备
-// @Description 创建一个新的IoT设备
+// @Description 创建一个新的IoT设备。提交的配置与设备类型的默认配置（见/devices/types的default_config）深度合并，未提交的项使用默认值
 // @Tags 设备管理
 // @Security BearerAuth
 // @Accept json
@@ -184,6 +311,7 @@ func (ctrl *DeviceController) GetDevice(c *gin.Context) {
 // @Param request body CreateDeviceRequest true "设备信息"
 // @Success 201 {object} models.Device
 // @Failure 400 {object} map[string]interface{}
+// @Failure 422 {object} map[string]interface{}
 // @Router /devices [post]
 func (ctrl *DeviceController) CreateDevice(c *gin.Context) {
 	userID := middleware.GetUserID(c)
@@ -195,19 +323,27 @@ func (ctrl *DeviceController) CreateDevice(c *gin.Context) {
 	}
 	
 	var req CreateDeviceRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request format",
+	if !bindJSON(c, &req) {
+		return
+	}
+	
+	location, err := models.NormalizeLocation(req.Location)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Invalid location",
 			"details": err.Error(),
 		})
 		return
 	}
 	
-	db := database.GetDB()
+	db := database.GetDBWithContext(c.Request.Context())
+	if !checkDeviceQuota(c, db, userID, 1) {
+		return
+	}
 	
-	// 检查设备ID是否已存在
+	// 检查设备ID是否已存在（包括已软删除的设备，device_id上有唯一约束）
 	var existingDevice models.Device
-	if err := db.Where("device_id = ?", req.DeviceID).First(&existingDevice).Error; err == nil {
+	if err := db.Unscoped().Where("device_id = ?", req.DeviceID).First(&existingDevice).Error; err == nil {
 		c.JSON(http.StatusConflict, gin.H{
 			"error": "Device ID already exists",
 		})
@@ -219,8 +355,11 @@ func (ctrl *DeviceController) CreateDevice(c *gin.Context) {
 		DeviceID: req.DeviceID,
 		Name:     req.Name,
 		Type:     req.Type,
-		Location: req.Location,
-		Config:   req.Config,
+		Location: location,
+		Config:   models.ApplyDeviceConfigDefaults(req.Type, req.Config),
+		ConfigSchemaVersion: models.LatestConfigSchemaVersion,
+		Tags:     normalizeTags(req.Tags),
+		DedupEnabled: req.DedupEnabled,
 		Status:   "offline",
 		OwnerID:  userID,
 	}
@@ -232,21 +371,14 @@ func (ctrl *DeviceController) CreateDevice(c *gin.Context) {
 		return
 	}
 	
-	// 清除缓存
+	// 清除缓存并初始化数据点计数
 	cache := database.NewCache()
 	cache.Delete(c, database.Keys.DeviceList(userID))
+	database.SeedDeviceSummary(c, device.DeviceID)
 	
-	// 通过WebSocket通知设备创建
+	// 保存通知并通过WebSocket推送设备创建消息
 	if websocket.DefaultManager != nil {
-		message := websocket.Message{
-			Type: websocket.TypeNotification,
-			Data: map[string]interface{}{
-				"action": "device_created",
-				"device": device,
-			},
-			Timestamp: time.Now(),
-		}
-		websocket.DefaultManager.SendToUser(userID, message)
+		websocket.DefaultManager.Notify(userID, "device_created", models.JSONB{"device": device})
 	}
 	
 	c.JSON(http.StatusCreated, gin.H{
@@ -263,35 +395,23 @@ func (ctrl *DeviceController) CreateDevice(c *gin.Context) {
 // @Security BearerAuth
 // @Accept json
 // @Produce json
-// @Param id path int true "设备ID"
+// @Param id path string true "设备ID或device_id"
 // @Param request body UpdateDeviceRequest true "更新信息"
 // @Success 200 {object} models.Device
 // @Failure 400 {object} map[string]interface{}
+// @Failure 422 {object} map[string]interface{}
 // @Router /devices/{id} [put]
 func (ctrl *DeviceController) UpdateDevice(c *gin.Context) {
-	userID := middleware.GetUserID(c)
-	deviceID, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid device ID",
-		})
-		return
-	}
-	
 	var req UpdateDeviceRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request format",
-			"details": err.Error(),
-		})
+	if !bindJSON(c, &req) {
 		return
 	}
 	
-	db := database.GetDB()
+	db := database.GetDBWithContext(c.Request.Context())
 	var device models.Device
 	
-	// 查询设备并验证所有权
-	if err := db.Where("id = ? AND owner_id = ?", uint(deviceID), userID).First(&device).Error; err != nil {
+	// 查询设备（所有权已由OwnerOrAdminRequired校验）
+	if err := db.Scopes(deviceParam(c, false)).First(&device).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": "Device not found",
 		})
@@ -303,11 +423,42 @@ func (ctrl *DeviceController) UpdateDevice(c *gin.Context) {
 		device.Name = req.Name
 	}
 	if req.Location != nil {
-		device.Location = req.Location
+		location, err := models.NormalizeLocation(req.Location)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":   "Invalid location",
+				"details": err.Error(),
+			})
+			return
+		}
+		device.Location = location
 	}
 	if req.Config != nil {
 		device.Config = req.Config
 	}
+	if req.Tags != nil {
+		device.Tags = normalizeTags(req.Tags)
+	}
+	if req.DedupEnabled != nil {
+		device.DedupEnabled = *req.DedupEnabled
+	}
+	if _, err := applyMaintenance(&device, req.Maintenance, req.MaintenanceUntil); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Invalid maintenance settings",
+			"details": err.Error(),
+		})
+		return
+	}
+	if req.FirmwareVersion != nil {
+		version, ok := normalizeFirmwareVersion(*req.FirmwareVersion)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "firmware_version is too long",
+			})
+			return
+		}
+		device.FirmwareVersion = version
+	}
 	
 	if err := db.Save(&device).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -319,7 +470,141 @@ func (ctrl *DeviceController) UpdateDevice(c *gin.Context) {
 	// 清除缓存
 	cache := database.NewCache()
 	cache.Delete(c, database.Keys.Device(device.DeviceID))
-	cache.Delete(c, database.Keys.DeviceList(userID))
+	cache.Delete(c, database.Keys.DeviceList(device.OwnerID))
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"msg":    "设备更新成功",
+		"data":   device,
+	})
+}
+
+// PatchDevice 部分更新设备
+// @Summary 部分更新设备信息
+// @Description 只修改请求中出现的字段：缺失的字段保持不变，null、空对象或空数组清空对应字段。名称不能为空
+// @Tags 设备管理
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "设备ID或device_id"
+// @Param request body PatchDeviceRequest true "需要修改的字段"
+// @Success 200 {object} models.Device
+// @Failure 400 {object} map[string]interface{}
+// @Failure 422 {object} map[string]interface{}
+// @Router /devices/{id} [patch]
+func (ctrl *DeviceController) PatchDevice(c *gin.Context) {
+	var req PatchDeviceRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	var device models.Device
+	
+	// 查询设备（所有权已由OwnerOrAdminRequired校验）
+	if err := db.Scopes(deviceParam(c, false)).First(&device).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Device not found",
+		})
+		return
+	}
+	
+	updates := make(map[string]interface{})
+	if req.Name.Set {
+		name := strings.TrimSpace(req.Name.Value)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "name must not be empty",
+			})
+			return
+		}
+		if len([]rune(name)) > maxDeviceNameLength {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("name must be at most %d characters", maxDeviceNameLength),
+			})
+			return
+		}
+		device.Name = name
+		updates["name"] = name
+	}
+	if req.Location.Set {
+		device.Location = nil
+		if len(req.Location.Value) > 0 {
+			location, err := models.NormalizeLocation(req.Location.Value)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{
+					"error":   "Invalid location",
+					"details": err.Error(),
+				})
+				return
+			}
+			device.Location = location
+		}
+		updates["location"] = device.Location
+	}
+	if req.Config.Set {
+		device.Config = req.Config.Value
+		if device.Config == nil {
+			device.Config = models.JSONB{}
+		}
+		updates["config"] = device.Config
+	}
+	if req.Tags.Set {
+		device.Tags = normalizeTags(req.Tags.Value)
+		updates["tags"] = device.Tags
+	}
+	if req.DedupEnabled.Set {
+		device.DedupEnabled = req.DedupEnabled.Value
+		updates["dedup_enabled"] = device.DedupEnabled
+	}
+	if req.Maintenance.Set || req.MaintenanceUntil.Set {
+		// 只清除结束时间时保持当前的维护状态
+		enabled := device.Maintenance
+		switch {
+		case req.Maintenance.Set:
+			enabled = req.Maintenance.Value
+		case req.MaintenanceUntil.Present():
+			enabled = true
+		}
+		var until *time.Time
+		if req.MaintenanceUntil.Present() {
+			until = &req.MaintenanceUntil.Value
+		}
+		if err := device.SetMaintenance(enabled, until, time.Now()); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":   "Invalid maintenance settings",
+				"details": err.Error(),
+			})
+			return
+		}
+		updates["maintenance"] = device.Maintenance
+		updates["maintenance_until"] = device.MaintenanceUntil
+	}
+	if req.FirmwareVersion.Set {
+		version, ok := normalizeFirmwareVersion(req.FirmwareVersion.Value)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "firmware_version is too long",
+			})
+			return
+		}
+		device.FirmwareVersion = version
+		updates["firmware_version"] = version
+	}
+	
+	// 只写入请求中出现的列，不覆盖并发修改的其他字段
+	if len(updates) > 0 {
+		if err := db.Model(&device).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to update device",
+			})
+			return
+		}
+		
+		cache := database.NewCache()
+		cache.Delete(c, database.Keys.Device(device.DeviceID))
+		cache.Delete(c, database.Keys.DeviceList(device.OwnerID))
+	}
 	
 	c.JSON(http.StatusOK, gin.H{
 		"status": 1,
@@ -328,56 +613,70 @@ func (ctrl *DeviceController) UpdateDevice(c *gin.Context) {
 	})
 }
 
+// applyMaintenance 按请求修改设备的维护模式，两个字段都缺失时不修改；
+// 只给出结束时间时视为进入维护模式，退出维护模式时清除结束时间
+func applyMaintenance(device *models.Device, maintenance *bool, until *time.Time) (bool, error) {
+	if maintenance == nil && until == nil {
+		return false, nil
+	}
+	enabled := maintenance == nil || *maintenance
+	return true, device.SetMaintenance(enabled, until, time.Now())
+}
+
 // DeleteDevice 删除设备
 // @Summary 删除设备
 // @Description 删除指定的设备
 // @Tags 设备管理
 // @Security BearerAuth
 // @Produce json
-// @Param id path int true "设备ID"
+// @Param id path string true "设备ID或device_id"
 // @Success 200 {object} map[string]interface{}
 // @Failure 404 {object} map[string]interface{}
 // @Router /devices/{id} [delete]
 func (ctrl *DeviceController) DeleteDevice(c *gin.Context) {
-	userID := middleware.GetUserID(c)
-	deviceID, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid device ID",
-		})
-		return
-	}
-	
-	db := database.GetDB()
+	db := database.GetDBWithContext(c.Request.Context())
 	var device models.Device
 	
-	// 查询设备并验证所有权
-	if err := db.Where("id = ? AND owner_id = ?", uint(deviceID), userID).First(&device).Error; err != nil {
+	// 查询设备（所有权已由OwnerOrAdminRequired校验）
+	if err := db.Scopes(deviceParam(c, false)).First(&device).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": "Device not found",
 		})
 		return
 	}
 	
-	// 删除相关的传感器数据
-	db.Where("device_id = ?", device.DeviceID).Delete(&models.SensorData{})
-	
-	// 删除设备
-	if err := db.Delete(&device).Error; err != nil {
+	// 传感器数据由后台任务按配置分批清理（retain模式下保留期满后再清理），这里只软删除设备
+	err := database.TransactionWithContext(c.Request.Context(), func(tx *gorm.DB) error {
+		result := tx.Delete(&device)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to delete device",
 		})
 		return
 	}
 	
-	// 清除缓存
+	// 事务提交后再清除缓存，避免删除失败时缓存与数据库不一致
 	cache := database.NewCache()
 	cache.Delete(c, database.Keys.Device(device.DeviceID))
-	cache.Delete(c, database.Keys.DeviceList(userID))
+	cache.Delete(c, database.Keys.DeviceList(device.OwnerID))
+	database.ResetDeviceSummary(c, device.DeviceID)
 	
+	dataPolicy := config.AppConfig.SensorData
 	c.JSON(http.StatusOK, gin.H{
 		"status": 1,
 		"msg":    "设备删除成功",
+		"data": gin.H{
+			"sensor_data_policy": dataPolicy.DeletePolicy,
+			"purge_after":        time.Now().Add(dataPolicy.PurgeDelay()),
+		},
 	})
 }
 
@@ -387,23 +686,23 @@ func (ctrl *DeviceController) DeleteDevice(c *gin.Context) {
 // @Tags 设备管理
 // @Security BearerAuth
 // @Produce json
-// @Param device_id path string true "设备ID"
+// @Param id path string true "设备ID或device_id"
 // @Success 200 {object} models.SensorData
-// @Router /devices/{device_id}/data [get]
+// @Router /devices/{id}/data [get]
 func (ctrl *DeviceController) GetDeviceData(c *gin.Context) {
 	userID := middleware.GetUserID(c)
-	deviceID := c.Param("device_id")
-	
 	// 验证设备所有权
-	db := database.GetDB()
+	db := database.GetDBWithContext(c.Request.Context())
 	var device models.Device
-	if err := db.Where("device_id = ? AND owner_id = ?", deviceID, userID).First(&device).Error; err != nil {
+	if err := db.Scopes(deviceParam(c, true)).Where("owner_id = ?", userID).First(&device).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": "Device not found",
 		})
 		return
 	}
 	
+	deviceID := device.DeviceID
+	
 	// 获取最新的传感器数据
 	var sensorData models.SensorData
 	if err := db.Where("device_id = ?", deviceID).Order("timestamp DESC").First(&sensorData).Error; err != nil {
@@ -421,32 +720,103 @@ func (ctrl *DeviceController) GetDeviceData(c *gin.Context) {
 	})
 }
 
+// DeviceSummaryResponse 设备数据摘要响应
+type DeviceSummaryResponse struct {
+	DeviceID      string             `json:"device_id"`
+	DataCount     int64              `json:"data_count"`
+	LatestReading *models.SensorData `json:"latest_reading"`
+}
+
+// GetDeviceSummary 获取设备数据摘要
+// @Summary 获取设备数据摘要
+// @Description 获取设备的数据点总数和最新读数（优先从缓存读取）
+// @Tags 设备管理
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "设备ID或device_id"
+// @Success 200 {object} DeviceSummaryResponse
+// @Failure 404 {object} map[string]interface{}
+// @Router /devices/{id}/summary [get]
+func (ctrl *DeviceController) GetDeviceSummary(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	// 验证设备所有权
+	db := database.GetDBWithContext(c.Request.Context())
+	var device models.Device
+	if err := db.Scopes(deviceParam(c, true)).Where("owner_id = ?", userID).First(&device).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Device not found",
+		})
+		return
+	}
+	
+	deviceID := device.DeviceID
+	
+	count, err := database.GetDeviceDataCount(c, deviceID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch data count",
+		})
+		return
+	}
+	
+	latest, err := database.GetLatestReading(c, deviceID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch latest reading",
+		})
+		return
+	}
+	if latest != nil {
+		latest.Data = ingest.Enrich(device.Type, latest.Data)
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"data": DeviceSummaryResponse{
+			DeviceID:      deviceID,
+			DataCount:     count,
+			LatestReading: latest,
+		},
+	})
+}
+
 // GetDeviceHistory 获取设备历史数据
 // @Summary 获取设备历史数据
-// @Description 获取设备的历史传感器数据
+// @Description 获取设备的历史传感器数据，data中合并了INGEST_COMPUTED_FIELDS配置的派生字段
 // @Tags 设备管理
 // @Security BearerAuth
 // @Produce json
-// @Param device_id path string true "设备ID"
+// @Param id path string true "设备ID或device_id"
 // @Param start_time query string false "开始时间" format(date-time)
 // @Param end_time query string false "结束时间" format(date-time)
+// @Param page query int false "页码" default(1)
 // @Param limit query int false "数据条数限制" default(100)
+// @Param include_deleted query bool false "包含已删除但传感器数据仍在保留期内的设备"
+// @Param quality query string false "质量筛选（good、suspect、bad，可逗号分隔多个）"
+// @Param order query string false "按时间排序方向（desc最新在前，asc按时间顺序，用于导出）" Enums(desc, asc) default(desc)
+// @Param max_points query int false "降采样到最多该条数（3到PAGINATION_HISTORY_MAX_LIMIT），覆盖整个时间范围而非截取最新的数据，忽略limit和offset"
+// @Param field query string false "降采样依据的数值字段（可为派生字段），默认为设备类型的第一个数值字段；无数值字段时按等间隔取点"
 // @Success 200 {object} []models.SensorData
-// @Router /devices/{device_id}/history [get]
+// @Failure 400 {object} map[string]interface{}
+// @Router /devices/{id}/history [get]
 func (ctrl *DeviceController) GetDeviceHistory(c *gin.Context) {
 	userID := middleware.GetUserID(c)
-	deviceID := c.Param("device_id")
-	
-	// 验证设备所有权
-	db := database.GetDB()
+	// 验证设备所有权，include_deleted=true时允许查询已删除但数据尚未清理的设备
+	db := database.GetDBWithContext(c.Request.Context())
+	deviceQuery := db
+	if c.Query("include_deleted") == "true" {
+		deviceQuery = db.Unscoped().Where("data_purged_at IS NULL")
+	}
 	var device models.Device
-	if err := db.Where("device_id = ? AND owner_id = ?", deviceID, userID).First(&device).Error; err != nil {
+	if err := deviceQuery.Scopes(deviceParam(c, true)).Where("owner_id = ?", userID).First(&device).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": "Device not found",
 		})
 		return
 	}
 	
+	deviceID := device.DeviceID
+	
 	// 解析时间参数
 	query := db.Where("device_id = ?", deviceID)
 	
@@ -462,84 +832,279 @@ func (ctrl *DeviceController) GetDeviceHistory(c *gin.Context) {
 		}
 	}
 	
+	// 质量筛选，支持逗号分隔的多个值，如quality=good,suspect
+	if qualityParam := c.Query("quality"); qualityParam != "" {
+		qualities := strings.Split(qualityParam, ",")
+		for i, quality := range qualities {
+			qualities[i] = strings.TrimSpace(quality)
+			if !models.ValidQuality(qualities[i]) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   "Invalid quality",
+					"details": "quality must be good, suspect or bad",
+				})
+				return
+			}
+		}
+		query = query.Where("quality IN ?", qualities)
+	}
+	
+	order, ok := historyOrder(c.DefaultQuery("order", "desc"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid order",
+			"details": "order must be asc or desc",
+		})
+		return
+	}
+	
+	// 图表场景：在整个时间范围内降采样，保留曲线形状
+	if maxPointsParam := c.Query("max_points"); maxPointsParam != "" {
+		maxLimit := config.AppConfig.Pagination.HistoryMaxLimit
+		maxPoints, err := strconv.Atoi(maxPointsParam)
+		if err != nil || maxPoints < 3 || maxPoints > maxLimit {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid max_points",
+				"details": fmt.Sprintf("max_points must be an integer between 3 and %d", maxLimit),
+			})
+			return
+		}
+		field := c.DefaultQuery("field", models.DefaultSeriesField(device.Type))
+		
+		sensorData, sampling, err := loadDownsampledHistory(db, query, device.Type, maxPoints, field)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to fetch sensor data",
+			})
+			return
+		}
+		if order != historyOrderAsc {
+			reverseSensorData(sensorData)
+		}
+		
+		c.JSON(http.StatusOK, gin.H{
+			"status":   1,
+			"data":     sensorData,
+			"sampling": sampling,
+		})
+		return
+	}
+	
 	// 限制数据条数
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
-	if limit > 1000 {
-		limit = 1000
+	pagination := parsePagination(c, 100, config.AppConfig.Pagination.HistoryMaxLimit)
+	
+	var total int64
+	if err := query.Model(&models.SensorData{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch sensor data",
+		})
+		return
 	}
 	
 	var sensorData []models.SensorData
-	if err := query.Order("timestamp DESC").Limit(limit).Find(&sensorData).Error; err != nil {
+	if err := query.Order(order).Offset(pagination.Offset).Limit(pagination.Limit).Find(&sensorData).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to fetch sensor data",
 		})
 		return
 	}
+	enrichReadings(device.Type, sensorData)
 	
+	setPaginationHeaders(c, total, pagination.Page, pagination.Limit)
 	c.JSON(http.StatusOK, gin.H{
 		"status": 1,
 		"data":   sensorData,
 	})
 }
 
+// enrichReadings 将设备类型配置的派生字段合并到返回的读数中，不修改数据库中的原始数据
+func enrichReadings(deviceType models.DeviceType, readings []models.SensorData) {
+	for i := range readings {
+		readings[i].Data = ingest.Enrich(deviceType, readings[i].Data)
+	}
+}
+
+// historyOrderAsc 历史数据按时间升序的排序子句
+const historyOrderAsc = "timestamp ASC NULLS FIRST, id ASC"
+
+// historyOrder 历史数据的排序子句，时间相同（批量写入）时按id排序保证分页稳定；
+// 无时间戳的数据视为最早的数据，降序时排在最后、升序时排在最前
+func historyOrder(direction string) (string, bool) {
+	switch direction {
+	case "desc":
+		return "timestamp DESC NULLS LAST, id DESC", true
+	case "asc":
+		return historyOrderAsc, true
+	}
+	return "", false
+}
+
 // PostDeviceData 接收设备上报的数据
 // @Summary 设备数据上报
-// @Description IoT设备上报传感器数据的接口
+// @Description IoT设备上报传感器数据的接口，支持Content-Encoding: gzip，请求体可为JSON、msgpack或CBOR；可选的_timestamp字段（RFC3339或Unix秒）为采集时间，未提供时使用接收时间
 // @Tags 设备数据
 // @Accept json
+// @Accept application/msgpack
+// @Accept application/cbor
 // @Produce json
-// @Param device_id path string true "设备ID"
+// @Param id path string true "设备的device_id"
 // @Param data body map[string]interface{} true "传感器数据"
 // @Success 200 {object} map[string]interface{}
-// @Router /devices/{device_id}/data [post]
+// @Failure 400 {object} map[string]interface{} "数据格式错误或_timestamp无效"
+// @Failure 413 {object} map[string]interface{} "解压后的数据超出大小限制"
+// @Failure 415 {object} map[string]interface{} "不支持的Content-Encoding"
+// @Failure 422 {object} map[string]interface{} "数据超出大小、键数或嵌套深度限制"
+// @Router /devices/{id}/data [post]
 func (ctrl *DeviceController) PostDeviceData(c *gin.Context) {
-	deviceID := c.Param("device_id")
-	
-	var data models.JSONB
-	if err := c.ShouldBindJSON(&data); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid data format",
-		})
+	// 支持gzip压缩和msgpack/CBOR等紧凑格式，默认按JSON解析
+	data, err := decodeDevicePayload(c, config.AppConfig.Ingestion.MaxDecodedBytes)
+	if err != nil {
+		respondPayloadError(c, err)
 		return
 	}
 	
-	db := database.GetDB()
+	db := database.GetDBWithContext(c.Request.Context())
 	
 	// 验证设备是否存在
 	var device models.Device
-	if err := db.Where("device_id = ?", deviceID).First(&device).Error; err != nil {
+	if err := db.Scopes(deviceIDParam(c)).First(&device).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": "Device not found",
 		})
 		return
 	}
 	
-	// 保存传感器数据
-	sensorData := models.SensorData{
-		DeviceID:  deviceID,
-		Data:      data,
-		Timestamp: time.Now(),
+	// 固件版本作为设备属性保存，不作为读数字段，读数被接受后再更新
+	firmwareVersion := models.ExtractFirmwareVersion(data)
+	
+	// 设备上报的采集时间作为读数时间，未上报时使用接收时间
+	reportedAt, err := models.ExtractReadingTimestamp(data, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
 	}
 	
-	if err := db.Create(&sensorData).Error; err != nil {
+	// 拒绝超出大小、键数或嵌套深度限制的数据，避免异常固件写入超大数据
+	limits := config.AppConfig.Ingestion.LimitsFor(int(device.Type))
+	if err := checkPayloadLimits(data, limits); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	
+	if !checkSensorRowQuota(c, db, device.OwnerID) {
+		return
+	}
+	
+	// 固件重试导致的重复上报只保存一次
+	release, duplicate := claimReading(c, &device, reportedAt, data)
+	if duplicate {
+		c.JSON(http.StatusOK, gin.H{
+			"status": 1,
+			"msg":    "重复数据已忽略",
+			"data":   gin.H{"duplicate": true},
+		})
+		return
+	}
+	
+	queued, err := ingestReading(c, db, &device, reportedAt, data)
+	if err != nil {
+		release()
+		if errors.Is(err, ingest.ErrQueueFull) || errors.Is(err, ingest.ErrStopped) {
+			status := http.StatusTooManyRequests
+			if errors.Is(err, ingest.ErrStopped) {
+				status = http.StatusServiceUnavailable
+			}
+			c.Header("Retry-After", "1")
+			c.JSON(status, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to save sensor data",
 		})
 		return
 	}
+	recordFirmwareVersion(c, db, &device, firmwareVersion)
+	
+	if queued {
+		c.JSON(http.StatusAccepted, gin.H{
+			"status": 1,
+			"msg":    "数据已接收",
+		})
+		return
+	}
 	
-	// 更新设备最后通信时间和状态
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"msg":    "数据接收成功",
+	})
+}
+
+// ingestReading 保存一条已通过校验的读数：评估质量后写入数据库（异步模式下放入写入队列，返回queued=true），
+// 再更新计数和最新读数缓存、设备在线状态，触发Webhook并通过WebSocket推送；timestamp为零值时使用当前时间
+func ingestReading(ctx context.Context, db *gorm.DB, device *models.Device, timestamp time.Time, data models.JSONB) (bool, error) {
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	sensorData := models.SensorData{
+		DeviceID:   device.DeviceID,
+		Data:       data,
+		Normalized: ingest.Normalize(device.Type, data),
+		Quality:    models.QualityGood,
+		Timestamp:  timestamp,
+	}
+	
+	// 超出合理范围的读数（如-9999等故障哨兵值）照常保存，只做质量标记供分析时过滤
+	if config.AppConfig.Ingestion.QualityChecks {
+		quality, issues := models.AssessQuality(device.Type, data)
+		sensorData.Quality = quality
+		if len(issues) > 0 {
+			sensorData.QualityIssues = pq.StringArray(issues)
+		}
+	}
+	
+	// 异步模式：放入写入队列后立即返回，由后台批量写入
+	if ingest.Default != nil {
+		if err := ingest.Default.Enqueue(sensorData); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	
+	if err := db.Create(&sensorData).Error; err != nil {
+		return false, err
+	}
+	
+	// 更新数据点计数和最新读数缓存
+	database.IncrDeviceDataCount(ctx, device.DeviceID)
+	database.RecordSensorRows(ctx, device.OwnerID, 1)
+	database.CacheLatestReading(ctx, &sensorData)
+	
+	// 更新设备最后通信时间和状态，只更新这两列，避免覆盖并发的设备配置修改
+	database.TouchDeviceLastSeen(ctx, db, []string{device.DeviceID}, config.AppConfig.Ingestion.LastSeenInterval)
 	now := time.Now()
 	device.LastSeen = &now
 	device.Status = "online"
-	db.Save(&device)
+	
+	// 保存告警并触发Webhook事件，不阻塞设备上报
+	snapshot := *device
+	shutdown.Default.Go("reading alerts", func() {
+		recordReadingAlert(snapshot, sensorData)
+	})
+	shutdown.Default.Go("reading webhooks", func() {
+		webhook.EmitReading(context.Background(), snapshot, sensorData)
+	})
 	
 	// 通过WebSocket实时推送数据
 	if websocket.DefaultManager != nil {
 		message := websocket.Message{
 			Type: websocket.TypeDeviceData,
 			Data: map[string]interface{}{
-				"device_id": deviceID,
+				"device_id": device.DeviceID,
 				"data":      data,
 				"timestamp": sensorData.Timestamp,
 			},
@@ -547,30 +1112,34 @@ func (ctrl *DeviceController) PostDeviceData(c *gin.Context) {
 		}
 		
 		// 推送给订阅该设备的客户端
-		websocket.DefaultManager.SendToDevice(deviceID, message)
+		websocket.DefaultManager.SendToDevice(device.DeviceID, message)
 	}
-	
-	c.JSON(http.StatusOK, gin.H{
-		"status": 1,
-		"msg":    "数据接收成功",
-	})
+	return false, nil
 }
 
 // GetDeviceTypes 获取设备类型列表
 // @Summary 获取设备类型列表
-// @Description 获取系统支持的所有设备类型
+// @Description 获取系统支持的所有设备类型，包含各类型上报字段、单位和取值范围
 // @Tags 设备管理
 // @Produce json
-// @Success 200 {object} map[string]interface{}
+// @Success 200 {object} []models.DeviceTypeMeta
 // @Router /devices/types [get]
 func (ctrl *DeviceController) GetDeviceTypes(c *gin.Context) {
-	types := make([]map[string]interface{}, 0, len(models.DeviceTypeNames))
+	var types []models.DeviceTypeMeta
+	if database.RedisClient != nil {
+		if err := database.NewCache().Get(c, database.Keys.DeviceTypes(), &types); err == nil {
+			c.JSON(http.StatusOK, gin.H{
+				"status": 1,
+				"data":   types,
+			})
+			return
+		}
+	}
 	
-	for typeID, typeName := range models.DeviceTypeNames {
-		types = append(types, map[string]interface{}{
-			"id":   int(typeID),
-			"name": typeName,
-		})
+	// 按类型ID排序，保证每次输出（及缓存内容）一致
+	types = models.AllDeviceTypeMeta()
+	if database.RedisClient != nil {
+		database.NewCache().Set(c, database.Keys.DeviceTypes(), types, deviceTypesCacheTTL)
 	}
 	
 	c.JSON(http.StatusOK, gin.H{
@@ -596,12 +1165,12 @@ func (ctrl *DeviceController) GetDeviceStats(c *gin.Context) {
 		return
 	}
 	
-	db := database.GetDB()
+	db := database.GetDBWithContext(c.Request.Context())
 	var stats []models.DeviceStatus
 	
 	// 统计各类型设备数量
 	for typeID, typeName := range models.DeviceTypeNames {
-		var total, online int64
+		var total, online, maintenance int64
 		
 		// 统计总数
 		db.Model(&models.Device{}).Where("owner_id = ? AND type = ?", userID, typeID).Count(&total)
@@ -611,12 +1180,17 @@ func (ctrl *DeviceController) GetDeviceStats(c *gin.Context) {
 		db.Model(&models.Device{}).Where("owner_id = ? AND type = ? AND last_seen > ?", 
 			userID, typeID, fiveMinutesAgo).Count(&online)
 		
+		// 统计维护中的数量
+		db.Model(&models.Device{}).Where("owner_id = ? AND type = ? AND maintenance AND (maintenance_until IS NULL OR maintenance_until > ?)",
+			userID, typeID, time.Now()).Count(&maintenance)
+		
 		stats = append(stats, models.DeviceStatus{
 			Type:       typeID,
 			TypeName:   typeName,
 			Total:      total,
 			Online:     online,
 			Offline:    total - online,
+			Maintenance: maintenance,
 			LastUpdate: time.Now(),
 		})
 	}
@@ -625,4 +1199,58 @@ func (ctrl *DeviceController) GetDeviceStats(c *gin.Context) {
 		"status": 1,
 		"data":   stats,
 	})
-}
\ No newline at end of file
+}
+
+// GetDeviceTags 获取设备标签列表
+// @Summary 获取设备标签列表
+// @Description 获取当前用户设备使用的所有标签及对应设备数量
+// @Tags 设备管理
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} []models.DeviceTagCount
+// @Router /devices/tags [get]
+func (ctrl *DeviceController) GetDeviceTags(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+		})
+		return
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	tags := make([]models.DeviceTagCount, 0)
+	
+	if err := db.Model(&models.Device{}).
+		Select("tag, COUNT(*) AS count").
+		Joins("CROSS JOIN LATERAL unnest(devices.tags) AS tag").
+		Where("devices.owner_id = ?", userID).
+		Group("tag").
+		Order("count DESC, tag ASC").
+		Scan(&tags).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch device tags",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"data":   tags,
+	})
+}
+
+// normalizeTags 去除空白、空值和重复标签
+func normalizeTags(tags []string) pq.StringArray {
+	result := make(pq.StringArray, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	return result
+}
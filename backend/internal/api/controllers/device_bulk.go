@@ -0,0 +1,259 @@
+package controllers
+
+import (
+	"net/http"
+	
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+)
+
+// BulkDeleteDevicesRequest 批量删除设备请求
+type BulkDeleteDevicesRequest struct {
+	IDs []uint `json:"ids" binding:"required,min=1,max=100"`
+}
+
+// BulkUpdateDevicesRequest 批量更新设备请求
+type BulkUpdateDevicesRequest struct {
+	IDs    []uint       `json:"ids" binding:"required,min=1,max=100"`
+	Status string       `json:"status" binding:"omitempty,oneof=online offline error"`
+	Config models.JSONB `json:"config"` // 合并到设备现有配置中的键值
+}
+
+// BulkResult 批量操作中单个设备的处理结果
+type BulkResult struct {
+	ID     uint   `json:"id"`
+	Status string `json:"status"` // deleted, updated, applied, forbidden, not_found, type_mismatch
+}
+
+// BulkResponse 批量操作响应
+type BulkResponse struct {
+	Results   []BulkResult `json:"results"`
+	Succeeded int          `json:"succeeded"`
+	Skipped   int          `json:"skipped"`
+}
+
+// BulkDeleteDevices 批量删除设备
+// @Summary 批量删除设备
+// @Description 批量软删除设备，逐个校验所有权并返回每个ID的处理结果
+// @Tags 设备管理
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body BulkDeleteDevicesRequest true "设备ID列表"
+// @Success 200 {object} BulkResponse
+// @Failure 400 {object} map[string]interface{}
+// @Router /devices/bulk-delete [post]
+func (ctrl *DeviceController) BulkDeleteDevices(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+		})
+		return
+	}
+	
+	var req BulkDeleteDevicesRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	ids := uniqueIDs(req.IDs)
+	owned, skipped, err := loadOwnedDevices(db, ids, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch devices",
+		})
+		return
+	}
+	
+	if len(owned) > 0 {
+		ownedIDs := make([]uint, 0, len(owned))
+		for _, device := range owned {
+			ownedIDs = append(ownedIDs, device.ID)
+		}
+		
+		err = database.TransactionWithContext(c.Request.Context(), func(tx *gorm.DB) error {
+			return tx.Where("id IN ? AND owner_id = ?", ownedIDs, userID).Delete(&models.Device{}).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to delete devices",
+			})
+			return
+		}
+		
+		invalidateDeviceCaches(c, userID, owned)
+		resetDeviceSummaries(c, owned)
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"msg":    "批量删除完成",
+		"data":   buildBulkResponse(ids, owned, skipped, "deleted"),
+	})
+}
+
+// BulkUpdateDevices 批量更新设备
+// @Summary 批量更新设备
+// @Description 批量设置设备状态或合并配置键，逐个校验所有权并返回每个ID的处理结果
+// @Tags 设备管理
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body BulkUpdateDevicesRequest true "更新内容"
+// @Success 200 {object} BulkResponse
+// @Failure 400 {object} map[string]interface{}
+// @Router /devices/bulk-update [post]
+func (ctrl *DeviceController) BulkUpdateDevices(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+		})
+		return
+	}
+	
+	var req BulkUpdateDevicesRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	
+	if req.Status == "" && len(req.Config) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Nothing to update",
+		})
+		return
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	ids := uniqueIDs(req.IDs)
+	owned, skipped, err := loadOwnedDevices(db, ids, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch devices",
+		})
+		return
+	}
+	
+	if len(owned) > 0 {
+		err = database.TransactionWithContext(c.Request.Context(), func(tx *gorm.DB) error {
+			for i := range owned {
+				if req.Status != "" {
+					owned[i].Status = req.Status
+				}
+				if len(req.Config) > 0 {
+					if owned[i].Config == nil {
+						owned[i].Config = make(models.JSONB)
+					}
+					for key, value := range req.Config {
+						owned[i].Config[key] = value
+					}
+				}
+				if err := tx.Save(&owned[i]).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to update devices",
+			})
+			return
+		}
+		
+		invalidateDeviceCaches(c, userID, owned)
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"msg":    "批量更新完成",
+		"data":   buildBulkResponse(ids, owned, skipped, "updated"),
+	})
+}
+
+// loadOwnedDevices 查询设备并按所有权分组，返回可操作的设备和被跳过的ID及原因
+func loadOwnedDevices(db *gorm.DB, ids []uint, userID uint) ([]models.Device, map[uint]string, error) {
+	var devices []models.Device
+	if err := db.Where("id IN ?", ids).Find(&devices).Error; err != nil {
+		return nil, nil, err
+	}
+	
+	found := make(map[uint]bool, len(devices))
+	owned := make([]models.Device, 0, len(devices))
+	skipped := make(map[uint]string)
+	
+	for _, device := range devices {
+		found[device.ID] = true
+		if device.OwnerID != userID {
+			skipped[device.ID] = "forbidden"
+			continue
+		}
+		owned = append(owned, device)
+	}
+	
+	for _, id := range ids {
+		if !found[id] {
+			skipped[id] = "not_found"
+		}
+	}
+	
+	return owned, skipped, nil
+}
+
+// buildBulkResponse 按请求顺序组装批量操作结果
+func buildBulkResponse(ids []uint, owned []models.Device, skipped map[uint]string, successStatus string) BulkResponse {
+	response := BulkResponse{
+		Results: make([]BulkResult, 0, len(ids)),
+	}
+	
+	for _, id := range ids {
+		if reason, ok := skipped[id]; ok {
+			response.Results = append(response.Results, BulkResult{ID: id, Status: reason})
+			response.Skipped++
+			continue
+		}
+		response.Results = append(response.Results, BulkResult{ID: id, Status: successStatus})
+	}
+	response.Succeeded = len(owned)
+	
+	return response
+}
+
+// invalidateDeviceCaches 清除设备详情及用户设备列表缓存
+func invalidateDeviceCaches(c *gin.Context, userID uint, devices []models.Device) {
+	keys := make([]string, 0, len(devices)+1)
+	for _, device := range devices {
+		keys = append(keys, database.Keys.Device(device.DeviceID))
+	}
+	keys = append(keys, database.Keys.DeviceList(userID))
+	
+	cache := database.NewCache()
+	cache.Delete(c, keys...)
+}
+
+// resetDeviceSummaries 清除设备的数据点计数和最新读数缓存
+func resetDeviceSummaries(c *gin.Context, devices []models.Device) {
+	deviceIDs := make([]string, 0, len(devices))
+	for _, device := range devices {
+		deviceIDs = append(deviceIDs, device.DeviceID)
+	}
+	database.ResetDeviceSummary(c, deviceIDs...)
+}
+
+// uniqueIDs 去除重复ID并保持原有顺序
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/testutil"
+)
+
+func TestBuildBulkResponse(t *testing.T) {
+	ids := uniqueIDs([]uint{3, 1, 3, 2, 4, 1})
+	if fmt.Sprint(ids) != "[3 1 2 4]" {
+		t.Fatalf("uniqueIDs() = %v, want [3 1 2 4]", ids)
+	}
+
+	owned := []models.Device{{ID: 1}, {ID: 3}}
+	response := buildBulkResponse(ids, owned, map[uint]string{2: "forbidden", 4: "not_found"}, "deleted")
+	want := []BulkResult{{3, "deleted"}, {1, "deleted"}, {2, "forbidden"}, {4, "not_found"}}
+	if fmt.Sprint(response.Results) != fmt.Sprint(want) {
+		t.Errorf("results = %v, want %v", response.Results, want)
+	}
+	if response.Succeeded != 2 || response.Skipped != 2 {
+		t.Errorf("succeeded=%d skipped=%d, want 2/2", response.Succeeded, response.Skipped)
+	}
+}
+
+// bulkFixtures 当前用户的两台设备和其他用户的一台设备
+type bulkFixtures struct {
+	user             *models.User
+	mine, alsoMine   models.Device
+	someoneElses     models.Device
+	missingID        uint
+	requestedIDOrder []uint
+}
+
+func createBulkFixtures(t *testing.T) bulkFixtures {
+	t.Helper()
+	user := createTestUser(t, "owner")
+	other := createTestUser(t, "other")
+	f := bulkFixtures{
+		user:         user,
+		mine:         createTestDevice(t, models.Device{DeviceID: "mine-1", OwnerID: user.ID, Status: "online", Config: models.JSONB{"interval": 60}}),
+		alsoMine:     createTestDevice(t, models.Device{DeviceID: "mine-2", OwnerID: user.ID, Status: "online"}),
+		someoneElses: createTestDevice(t, models.Device{DeviceID: "theirs-1", OwnerID: other.ID, Status: "online", Config: models.JSONB{"interval": 60}}),
+	}
+	f.missingID = f.someoneElses.ID + 1000
+	// 重复的ID只处理一次
+	f.requestedIDOrder = []uint{f.someoneElses.ID, f.mine.ID, f.missingID, f.alsoMine.ID, f.mine.ID}
+	return f
+}
+
+// decodeBulkResponse 解析批量操作响应
+func decodeBulkResponse(t *testing.T, body []byte) BulkResponse {
+	t.Helper()
+	var response struct {
+		Data BulkResponse `json:"data"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return response.Data
+}
+
+func TestBulkDeleteDevicesPartialPermission(t *testing.T) {
+	testutil.LoadConfig(t)
+	testutil.UseRedis(t)
+	db := testutil.UseDB(t)
+	f := createBulkFixtures(t)
+
+	ctx := context.Background()
+	cache := database.NewCache()
+	cache.Set(ctx, database.Keys.Device("mine-1"), f.mine, time.Minute)
+	cache.Set(ctx, database.Keys.Device("theirs-1"), f.someoneElses, time.Minute)
+	cache.Set(ctx, database.Keys.DeviceList(f.user.ID), []models.Device{f.mine, f.alsoMine}, time.Minute)
+
+	c, w := newJSONContext(t, "POST", "/api/v1/devices/bulk-delete", BulkDeleteDevicesRequest{IDs: f.requestedIDOrder}, f.user)
+	NewDeviceController().BulkDeleteDevices(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+
+	response := decodeBulkResponse(t, w.Body.Bytes())
+	want := []BulkResult{
+		{f.someoneElses.ID, "forbidden"},
+		{f.mine.ID, "deleted"},
+		{f.missingID, "not_found"},
+		{f.alsoMine.ID, "deleted"},
+	}
+	if fmt.Sprint(response.Results) != fmt.Sprint(want) {
+		t.Errorf("results = %v, want %v", response.Results, want)
+	}
+	if response.Succeeded != 2 || response.Skipped != 2 {
+		t.Errorf("succeeded=%d skipped=%d, want 2/2", response.Succeeded, response.Skipped)
+	}
+
+	// 自己的设备被软删除，其他用户的设备不受影响
+	var remaining []string
+	db.Model(&models.Device{}).Order("device_id").Pluck("device_id", &remaining)
+	if fmt.Sprint(remaining) != "[theirs-1]" {
+		t.Errorf("remaining devices = %v, want [theirs-1]", remaining)
+	}
+	var deleted int64
+	db.Unscoped().Model(&models.Device{}).Where("owner_id = ? AND deleted_at IS NOT NULL", f.user.ID).Count(&deleted)
+	if deleted != 2 {
+		t.Errorf("%d soft-deleted device(s), want 2", deleted)
+	}
+
+	for key, want := range map[string]bool{
+		database.Keys.Device("mine-1"):      false,
+		database.Keys.DeviceList(f.user.ID): false,
+		database.Keys.Device("theirs-1"):    true,
+	} {
+		if exists, _ := cache.Exists(ctx, key); exists != want {
+			t.Errorf("cache key %s exists = %v, want %v", key, exists, want)
+		}
+	}
+}
+
+func TestBulkUpdateDevicesPartialPermission(t *testing.T) {
+	testutil.LoadConfig(t)
+	testutil.UseRedis(t)
+	db := testutil.UseDB(t)
+	f := createBulkFixtures(t)
+
+	update := func(req BulkUpdateDevicesRequest) (int, []byte) {
+		t.Helper()
+		c, w := newJSONContext(t, "POST", "/api/v1/devices/bulk-update", req, f.user)
+		NewDeviceController().BulkUpdateDevices(c)
+		return w.Code, w.Body.Bytes()
+	}
+
+	if status, _ := update(BulkUpdateDevicesRequest{IDs: []uint{f.mine.ID}}); status != http.StatusBadRequest {
+		t.Errorf("empty update status = %d, want %d", status, http.StatusBadRequest)
+	}
+
+	status, body := update(BulkUpdateDevicesRequest{IDs: f.requestedIDOrder, Status: "offline", Config: models.JSONB{"tag": "field-a"}})
+	if status != http.StatusOK {
+		t.Fatalf("status = %d: %s", status, body)
+	}
+	response := decodeBulkResponse(t, body)
+	want := []BulkResult{
+		{f.someoneElses.ID, "forbidden"},
+		{f.mine.ID, "updated"},
+		{f.missingID, "not_found"},
+		{f.alsoMine.ID, "updated"},
+	}
+	if fmt.Sprint(response.Results) != fmt.Sprint(want) {
+		t.Errorf("results = %v, want %v", response.Results, want)
+	}
+
+	// 配置键合并到已有配置中，其他用户的设备保持原样
+	devices := make(map[string]models.Device)
+	var all []models.Device
+	db.Find(&all)
+	for _, device := range all {
+		devices[device.DeviceID] = device
+	}
+	if got := devices["mine-1"]; got.Status != "offline" || got.Config["tag"] != "field-a" || got.Config["interval"] != float64(60) {
+		t.Errorf("mine-1 = %s %v, want offline with tag merged into the existing config", got.Status, got.Config)
+	}
+	if got := devices["mine-2"]; got.Status != "offline" || got.Config["tag"] != "field-a" {
+		t.Errorf("mine-2 = %s %v, want offline with the tag", got.Status, got.Config)
+	}
+	if got := devices["theirs-1"]; got.Status != "online" || got.Config["tag"] != nil {
+		t.Errorf("theirs-1 = %s %v, want it untouched", got.Status, got.Config)
+	}
+}
@@ -0,0 +1,281 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+)
+
+// maxPendingCommandsPerPoll 设备每次拉取的最多命令数
+const maxPendingCommandsPerPoll = 20
+
+// IssueDeviceCommandRequest 下发设备命令请求
+type IssueDeviceCommandRequest struct {
+	Command string       `json:"command" binding:"required,max=100"`
+	Payload models.JSONB `json:"payload"`
+}
+
+// AckDeviceCommandRequest 设备回报命令执行结果，status为acked或failed
+type AckDeviceCommandRequest struct {
+	Status string `json:"status" binding:"required,oneof=acked failed"`
+	Error  string `json:"error" binding:"max=500"`
+}
+
+// DeviceCommandListResponse 设备命令历史分页结果
+type DeviceCommandListResponse struct {
+	Commands []models.DeviceCommand `json:"commands"`
+	Total    int64                  `json:"total"`
+	Page     int                    `json:"page"`
+	Limit    int                    `json:"limit"`
+}
+
+// GetDeviceCommands 获取设备命令历史
+// @Summary 获取设备命令历史
+// @Description 按创建时间倒序列出下发给设备的命令，包含投递/确认状态、各状态时间和下发用户
+// @Tags 设备管理
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "设备ID或device_id"
+// @Param status query string false "按状态过滤（pending, delivered, acked, failed）"
+// @Param page query int false "页码"
+// @Param limit query int false "每页数量"
+// @Success 200 {object} DeviceCommandListResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /devices/{id}/commands [get]
+func (ctrl *DeviceController) GetDeviceCommands(c *gin.Context) {
+	status := c.Query("status")
+	if status != "" && !models.ValidCommandStatus(status) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid status, expected one of pending, delivered, acked, failed",
+		})
+		return
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	var device models.Device
+	// 所有权已由OwnerOrAdminRequired校验
+	if err := db.Scopes(deviceParam(c, false)).Select("id").First(&device).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Device not found",
+		})
+		return
+	}
+	
+	pagination := parseListPagination(c)
+	query := db.Model(&models.DeviceCommand{}).
+		Where("device_id = ?", device.ID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	
+	var total int64
+	query.Count(&total)
+	
+	var commands []models.DeviceCommand
+	if err := query.Preload("Issuer").
+		Order("created_at DESC, id DESC").
+		Offset(pagination.Offset).
+		Limit(pagination.Limit).
+		Find(&commands).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch commands",
+		})
+		return
+	}
+	
+	setPaginationHeaders(c, total, pagination.Page, pagination.Limit)
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"data": DeviceCommandListResponse{
+			Commands: commands,
+			Total:    total,
+			Page:     pagination.Page,
+			Limit:    pagination.Limit,
+		},
+	})
+}
+
+// IssueDeviceCommand 下发设备命令
+// @Summary 下发设备命令
+// @Description 创建一条pending状态的命令，设备下次拉取时送达
+// @Tags 设备管理
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "设备ID或device_id"
+// @Param request body IssueDeviceCommandRequest true "命令"
+// @Success 201 {object} models.DeviceCommand
+// @Failure 404 {object} map[string]interface{}
+// @Router /devices/{id}/commands [post]
+func (ctrl *DeviceController) IssueDeviceCommand(c *gin.Context) {
+	var req IssueDeviceCommandRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	var device models.Device
+	// 所有权已由OwnerOrAdminRequired校验
+	if err := db.Scopes(deviceParam(c, false)).Select("id").First(&device).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Device not found",
+		})
+		return
+	}
+	
+	command := models.DeviceCommand{
+		DeviceID: device.ID,
+		Command:  req.Command,
+		Payload:  req.Payload,
+		Status:   models.CommandPending,
+		IssuedBy: middleware.GetUserID(c),
+	}
+	if err := db.Create(&command).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to issue command",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusCreated, gin.H{
+		"status": 1,
+		"msg":    "命令已创建",
+		"data":   command,
+	})
+}
+
+// PollDeviceCommands 设备拉取待执行的命令
+// @Summary 设备拉取待执行的命令
+// @Description 设备使用X-Device-Key拉取pending状态的命令（按创建时间顺序），返回的命令标记为已送达
+// @Tags 设备数据
+// @Produce json
+// @Param id path string true "设备的device_id"
+// @Param X-Device-Key header string true "设备密钥"
+// @Success 200 {object} []models.DeviceCommand
+// @Failure 401 {object} map[string]interface{} "密钥缺失或错误"
+// @Failure 404 {object} map[string]interface{} "设备不存在"
+// @Router /devices/{id}/commands/pending [get]
+func (ctrl *DeviceController) PollDeviceCommands(c *gin.Context) {
+	db := database.GetDBWithContext(c.Request.Context())
+	device, ok := authenticateDevice(c, db)
+	if !ok {
+		return
+	}
+	
+	delivered := make([]models.DeviceCommand, 0)
+	err := database.TransactionWithContext(c.Request.Context(), func(tx *gorm.DB) error {
+		// 并发拉取时每条命令只送达一次
+		var pending []models.DeviceCommand
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("device_id = ? AND status = ?", device.ID, models.CommandPending).
+			Order("created_at, id").
+			Limit(maxPendingCommandsPerPoll).
+			Find(&pending).Error; err != nil {
+			return err
+		}
+		
+		now := time.Now()
+		for _, command := range pending {
+			if err := command.Transition(models.CommandDelivered, now, ""); err != nil {
+				continue
+			}
+			if err := tx.Model(&command).Select("status", "delivered_at", "updated_at").Updates(&command).Error; err != nil {
+				return err
+			}
+			delivered = append(delivered, command)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch commands",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"data":   delivered,
+	})
+}
+
+// AckDeviceCommand 设备回报命令执行结果
+// @Summary 设备回报命令执行结果
+// @Description 设备使用X-Device-Key确认命令已执行（acked）或执行失败（failed），已确认或已失败的命令不能再次回报
+// @Tags 设备数据
+// @Accept json
+// @Produce json
+// @Param id path string true "设备的device_id"
+// @Param command_id path int true "命令ID"
+// @Param X-Device-Key header string true "设备密钥"
+// @Param request body AckDeviceCommandRequest true "执行结果"
+// @Success 200 {object} models.DeviceCommand
+// @Failure 401 {object} map[string]interface{} "密钥缺失或错误"
+// @Failure 404 {object} map[string]interface{} "设备或命令不存在"
+// @Failure 409 {object} map[string]interface{} "命令已确认或已失败"
+// @Router /devices/{id}/commands/{command_id}/ack [post]
+func (ctrl *DeviceController) AckDeviceCommand(c *gin.Context) {
+	commandID, err := strconv.ParseUint(c.Param("command_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid command ID",
+		})
+		return
+	}
+	
+	var req AckDeviceCommandRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	device, ok := authenticateDevice(c, db)
+	if !ok {
+		return
+	}
+	
+	var command models.DeviceCommand
+	err = database.TransactionWithContext(c.Request.Context(), func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND device_id = ?", uint(commandID), device.ID).
+			First(&command).Error; err != nil {
+			return err
+		}
+		if err := command.Transition(req.Status, time.Now(), req.Error); err != nil {
+			return err
+		}
+		return tx.Model(&command).Select("status", "delivered_at", "acked_at", "failed_at", "error", "updated_at").Updates(&command).Error
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Command not found",
+			})
+		case errors.Is(err, models.ErrInvalidCommandTransition):
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "Command is already " + command.Status,
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to update command",
+			})
+		}
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"data":   command,
+	})
+}
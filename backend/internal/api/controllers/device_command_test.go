@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/testutil"
+)
+
+const testDeviceKey = "valve-key"
+
+// newDeviceContext 构造携带设备密钥的设备端请求
+func newDeviceContext(t *testing.T, method, target, deviceID, key string, body interface{}, params ...gin.Param) (*gin.Context, *httptest.ResponseRecorder) {
+	t.Helper()
+	c, w := newJSONContext(t, method, target, body, nil)
+	c.Request.Header.Set(DeviceKeyHeader, key)
+	c.Params = append(gin.Params{{Key: "id", Value: deviceID}}, params...)
+	return c, w
+}
+
+func TestDeviceCommandLifecycle(t *testing.T) {
+	testutil.LoadConfig(t)
+	testutil.UseDB(t)
+
+	user := createTestUser(t, "operator")
+	device := createTestDevice(t, models.Device{DeviceID: "valve-1", OwnerID: user.ID, KeyHash: models.HashDeviceKey(testDeviceKey)})
+	ctrl := NewDeviceController()
+	pathID := strconv.Itoa(int(device.ID))
+
+	issue := func(command string) models.DeviceCommand {
+		t.Helper()
+		c, w := newJSONContext(t, "POST", "/api/v1/devices/"+pathID+"/commands", IssueDeviceCommandRequest{Command: command, Payload: models.JSONB{"name": command}}, user)
+		c.Params = gin.Params{{Key: "id", Value: pathID}}
+		ctrl.IssueDeviceCommand(c)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("issue %s status = %d: %s", command, w.Code, w.Body.String())
+		}
+		var body struct {
+			Data models.DeviceCommand `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return body.Data
+	}
+	poll := func(key string) (int, []models.DeviceCommand) {
+		t.Helper()
+		c, w := newDeviceContext(t, "GET", "/api/v1/devices/valve-1/commands/pending", "valve-1", key, nil)
+		ctrl.PollDeviceCommands(c)
+		var body struct {
+			Data []models.DeviceCommand `json:"data"`
+		}
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+		}
+		return w.Code, body.Data
+	}
+	ack := func(command models.DeviceCommand, status, errMsg string) int {
+		t.Helper()
+		target := fmt.Sprintf("/api/v1/devices/valve-1/commands/%d/ack", command.ID)
+		c, w := newDeviceContext(t, "POST", target, "valve-1", testDeviceKey,
+			AckDeviceCommandRequest{Status: status, Error: errMsg},
+			gin.Param{Key: "command_id", Value: strconv.Itoa(int(command.ID))})
+		ctrl.AckDeviceCommand(c)
+		return w.Code
+	}
+	list := func(query string) (int, DeviceCommandListResponse) {
+		t.Helper()
+		c, w := newJSONContext(t, "GET", "/api/v1/devices/"+pathID+"/commands"+query, nil, user)
+		c.Params = gin.Params{{Key: "id", Value: pathID}}
+		ctrl.GetDeviceCommands(c)
+		var body struct {
+			Data DeviceCommandListResponse `json:"data"`
+		}
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+		}
+		return w.Code, body.Data
+	}
+
+	open := issue("open")
+	closeValve := issue("close")
+	_, all := list("")
+	if all.Total != 2 || all.Commands[0].Command != "close" || all.Commands[0].Status != models.CommandPending {
+		t.Fatalf("listing = %+v, want 2 pending commands newest first", all)
+	}
+	if all.Commands[0].Issuer.Username != "operator" {
+		t.Errorf("issuer = %+v, want the issuing user", all.Commands[0].Issuer)
+	}
+
+	// 密钥错误时不能拉取命令
+	if status, _ := poll("wrong-key"); status != http.StatusUnauthorized {
+		t.Errorf("poll with a wrong key status = %d, want %d", status, http.StatusUnauthorized)
+	}
+
+	// 设备拉取后命令标记为已送达，再次拉取不会重复送达
+	status, delivered := poll(testDeviceKey)
+	if status != http.StatusOK || len(delivered) != 2 || delivered[0].ID != open.ID {
+		t.Fatalf("poll = %d %+v, want both commands oldest first", status, delivered)
+	}
+	if _, again := poll(testDeviceKey); len(again) != 0 {
+		t.Errorf("second poll returned %d command(s), want none", len(again))
+	}
+	reboot := issue("reboot")
+
+	// open执行成功，close执行失败，reboot保持pending
+	if status := ack(open, models.CommandAcked, ""); status != http.StatusOK {
+		t.Fatalf("ack status = %d", status)
+	}
+	if status := ack(closeValve, models.CommandFailed, "valve stuck"); status != http.StatusOK {
+		t.Fatalf("fail status = %d", status)
+	}
+	if status := ack(open, models.CommandFailed, "late"); status != http.StatusConflict {
+		t.Errorf("second report status = %d, want %d", status, http.StatusConflict)
+	}
+
+	_, acked := list("?status=acked")
+	if acked.Total != 1 || acked.Commands[0].ID != open.ID {
+		t.Fatalf("acked listing = %+v, want only the open command", acked)
+	}
+	if got := acked.Commands[0]; got.DeliveredAt == nil || got.AckedAt == nil || got.AckedAt.Before(*got.DeliveredAt) {
+		t.Errorf("acked command = %+v, want delivery and ack timestamps", got)
+	}
+	_, failed := list("?status=failed")
+	if failed.Total != 1 || failed.Commands[0].Error != "valve stuck" || failed.Commands[0].FailedAt == nil {
+		t.Errorf("failed listing = %+v, want the close command with its error", failed)
+	}
+	_, pending := list("?status=pending&limit=1")
+	if pending.Total != 1 || pending.Limit != 1 || pending.Commands[0].ID != reboot.ID {
+		t.Errorf("pending listing = %+v, want only the reboot command", pending)
+	}
+	if status, _ := list("?status=sent"); status != http.StatusBadRequest {
+		t.Errorf("status = %d for an unknown status filter, want %d", status, http.StatusBadRequest)
+	}
+}
+
+func TestPurgeDeletedDeviceDataRemovesCommands(t *testing.T) {
+	testutil.LoadConfig(t)
+	db := testutil.UseDB(t)
+
+	user := createTestUser(t, "operator")
+	device := createTestDevice(t, models.Device{DeviceID: "valve-1", OwnerID: user.ID})
+	kept := createTestDevice(t, models.Device{DeviceID: "valve-2", OwnerID: user.ID})
+	for _, target := range []models.Device{device, kept} {
+		if err := db.Create(&models.DeviceCommand{DeviceID: target.ID, Command: "open", Status: models.CommandPending, IssuedBy: user.ID}).Error; err != nil {
+			t.Fatalf("failed to create command: %v", err)
+		}
+	}
+	if err := db.Delete(&device).Error; err != nil {
+		t.Fatalf("failed to delete device: %v", err)
+	}
+
+	if _, _, err := database.PurgeDeletedDeviceData(context.Background(), time.Now(), 100); err != nil {
+		t.Fatalf("PurgeDeletedDeviceData() = %v", err)
+	}
+	var count int64
+	db.Model(&models.DeviceCommand{}).Where("device_id = ?", device.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("%d command(s) left for the purged device, want 0", count)
+	}
+	db.Model(&models.DeviceCommand{}).Where("device_id = ?", kept.ID).Count(&count)
+	if count != 1 {
+		t.Errorf("%d command(s) left for the remaining device, want 1", count)
+	}
+}
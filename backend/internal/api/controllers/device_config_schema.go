@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+)
+
+// migrateDeviceConfig 将设备配置升级到最新结构版本并记录变更，返回是否发生升级
+// 按原版本号条件更新，并发读取时只有一个请求写入；失败时device保持不变
+func migrateDeviceConfig(ctx context.Context, device *models.Device, userID uint) (bool, error) {
+	migrated, version, changed := models.MigrateConfig(device.Type, device.ConfigSchemaVersion, device.Config)
+	if !changed {
+		return false, nil
+	}
+	
+	updated := false
+	err := database.TransactionWithContext(ctx, func(tx *gorm.DB) error {
+		result := tx.Model(&models.Device{}).
+			Where("id = ? AND config_schema_version = ?", device.ID, device.ConfigSchemaVersion).
+			Updates(map[string]interface{}{
+				"config":                migrated,
+				"config_schema_version": version,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		// 已被其他请求升级
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		updated = true
+		
+		history := models.DeviceConfigHistory{
+			DeviceID:       device.ID,
+			UserID:         userID,
+			Action:         "migrate_schema",
+			PreviousConfig: device.Config,
+			NewConfig:      migrated,
+		}
+		return tx.Create(&history).Error
+	})
+	if err != nil {
+		return false, err
+	}
+	
+	// 无论由哪个请求完成升级，返回给调用方的都是最新版本的配置
+	device.Config = migrated
+	device.ConfigSchemaVersion = version
+	if updated {
+		database.NewCache().Delete(ctx, database.Keys.Device(device.DeviceID))
+	}
+	return updated, nil
+}
+
+// MigrateDeviceConfig 手动升级设备配置结构版本
+// @Summary 升级设备配置结构版本
+// @Description 将设备配置升级到最新结构版本并记录配置变更；已是最新版本或版本未知时不做修改
+// @Tags 设备管理
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "设备ID或device_id"
+// @Success 200 {object} map[string]interface{}
+// @Router /devices/{id}/migrate-config [post]
+func (ctrl *DeviceController) MigrateDeviceConfig(c *gin.Context) {
+	db := database.GetDBWithContext(c.Request.Context())
+	var device models.Device
+	// 所有权已由OwnerOrAdminRequired校验
+	if err := db.Scopes(deviceParam(c, false)).First(&device).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Device not found",
+		})
+		return
+	}
+	
+	previousVersion := device.ConfigSchemaVersion
+	migrated, err := migrateDeviceConfig(c.Request.Context(), &device, middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to migrate device config",
+		})
+		return
+	}
+	
+	msg := "设备配置已是最新版本"
+	if migrated {
+		msg = "设备配置已升级"
+	} else if previousVersion > models.LatestConfigSchemaVersion || previousVersion < 1 {
+		msg = "设备配置版本未知，未做修改"
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"msg":    msg,
+		"data": gin.H{
+			"migrated":         migrated,
+			"previous_version": previousVersion,
+			"schema_version":   device.ConfigSchemaVersion,
+			"latest_version":   models.LatestConfigSchemaVersion,
+			"config":           device.Config,
+		},
+	})
+}
@@ -0,0 +1,347 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+	
+	"github.com/gin-gonic/gin"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+)
+
+const (
+	// maxCorrelateDevices 单次关联查询的设备数上限
+	maxCorrelateDevices = 10
+	// defaultCorrelateRange 未指定from时关联最近的时长
+	defaultCorrelateRange = 24 * time.Hour
+	// maxCorrelateRange 单次关联查询允许的最大时间跨度
+	maxCorrelateRange = 31 * 24 * time.Hour
+	// defaultCorrelateTolerance 未指定tolerance时的对齐容差
+	defaultCorrelateTolerance = time.Minute
+	// maxCorrelateTolerance 对齐容差上限
+	maxCorrelateTolerance = time.Hour
+	// maxCorrelatePoints 每个设备最多读取的数据点数，超出时只关联最早的部分
+	maxCorrelatePoints = 10000
+)
+
+// CorrelatedReading 对齐到某一行的设备读数
+type CorrelatedReading struct {
+	Timestamp time.Time    `json:"timestamp"`
+	OffsetMs  int64        `json:"offset_ms"` // 相对该行时间的偏移（毫秒），负数表示早于该行
+	Data      models.JSONB `json:"data"`
+}
+
+// CorrelatedRow 按基准设备读数时间对齐的一行，未能在容差内对齐的设备为null
+type CorrelatedRow struct {
+	Timestamp time.Time                     `json:"timestamp"`
+	Readings  map[string]*CorrelatedReading `json:"readings"`
+}
+
+// CorrelationResponse 多设备关联查询结果
+type CorrelationResponse struct {
+	Anchor    string           `json:"anchor"` // 基准设备，每条读数生成一行
+	Devices   []string         `json:"devices"`
+	Fields    []string         `json:"fields,omitempty"`
+	From      time.Time        `json:"from"`
+	To        time.Time        `json:"to"`
+	Tolerance string           `json:"tolerance"`
+	Rows      []CorrelatedRow  `json:"rows"`
+	Matched   map[string]int   `json:"matched"`   // 各设备对齐到行的读数数
+	Unmatched map[string]int   `json:"unmatched"` // 各设备未能对齐的读数数（基准设备始终为0）
+	Truncated bool             `json:"truncated"` // 有设备的数据点超过上限，只关联了较早的部分
+}
+
+// GetDeviceCorrelation 多设备读数按时间关联
+// @Summary 多设备读数按时间关联
+// @Description 读取多个设备在时间范围内的读数，以基准设备的每条读数为一行，在容差内为其他设备各匹配时间最近的一条读数（每条读数最多匹配一行）。
+// @Description 上报频率不同时建议以频率最低的设备为基准，未指定anchor时自动选择读数最少的设备
+// @Tags 设备数据
+// @Security BearerAuth
+// @Produce json
+// @Param devices query string true "设备ID，逗号分隔，2到10个"
+// @Param fields query string false "只返回这些字段，逗号分隔，默认返回全部字段"
+// @Param from query string false "开始时间，默认24小时前" format(date-time)
+// @Param to query string false "结束时间，默认当前时间" format(date-time)
+// @Param tolerance query string false "对齐容差，如30s、5m，最大1h" default(1m)
+// @Param anchor query string false "基准设备ID，必须在devices中"
+// @Success 200 {object} CorrelationResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /devices/correlate [get]
+func (ctrl *DeviceController) GetDeviceCorrelation(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	
+	deviceIDs := uniqueStrings(splitCSV(c.Query("devices")))
+	if len(deviceIDs) < 2 || len(deviceIDs) > maxCorrelateDevices {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "devices must list between 2 and 10 device IDs",
+		})
+		return
+	}
+	fields := uniqueStrings(splitCSV(c.Query("fields")))
+	
+	to := time.Now()
+	if raw := c.Query("to"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid to, expected RFC3339 time",
+			})
+			return
+		}
+		to = t
+	}
+	from := to.Add(-defaultCorrelateRange)
+	if raw := c.Query("from"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid from, expected RFC3339 time",
+			})
+			return
+		}
+		from = t
+	}
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "from must be earlier than to",
+		})
+		return
+	}
+	if to.Sub(from) > maxCorrelateRange {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Time range must not exceed " + maxCorrelateRange.String(),
+		})
+		return
+	}
+	
+	tolerance := defaultCorrelateTolerance
+	if raw := c.Query("tolerance"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 || d > maxCorrelateTolerance {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid tolerance, expected a duration between 0s and 1h",
+			})
+			return
+		}
+		tolerance = d
+	}
+	
+	anchor := c.Query("anchor")
+	if anchor != "" && !containsString(deviceIDs, anchor) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "anchor must be one of devices",
+		})
+		return
+	}
+	
+	// 验证设备所有权，不区分不存在和无权访问
+	db := database.GetDBWithContext(c.Request.Context())
+	var owned []string
+	if err := db.Model(&models.Device{}).
+		Where("device_id IN ? AND owner_id = ?", deviceIDs, userID).
+		Pluck("device_id", &owned).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch devices",
+		})
+		return
+	}
+	if len(owned) != len(deviceIDs) {
+		var missing []string
+		for _, deviceID := range deviceIDs {
+			if !containsString(owned, deviceID) {
+				missing = append(missing, deviceID)
+			}
+		}
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Device not found",
+			"details": missing,
+		})
+		return
+	}
+	
+	// 每个设备一次范围查询，按时间升序读取，之后在内存中按时间归并
+	series := make(map[string][]models.SensorData, len(deviceIDs))
+	truncated := false
+	for _, deviceID := range deviceIDs {
+		var readings []models.SensorData
+		if err := db.Select("device_id", "data", "timestamp").
+			Where("device_id = ? AND timestamp >= ? AND timestamp < ?", deviceID, from, to).
+			Order("timestamp ASC, id ASC").
+			Limit(maxCorrelatePoints + 1).
+			Find(&readings).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to fetch sensor data",
+			})
+			return
+		}
+		if len(readings) > maxCorrelatePoints {
+			readings = readings[:maxCorrelatePoints]
+			truncated = true
+		}
+		series[deviceID] = readings
+	}
+	
+	if anchor == "" {
+		anchor = deviceIDs[0]
+		for _, deviceID := range deviceIDs[1:] {
+			if len(series[deviceID]) < len(series[anchor]) {
+				anchor = deviceID
+			}
+		}
+	}
+	
+	response := CorrelationResponse{
+		Anchor:    anchor,
+		Devices:   deviceIDs,
+		Fields:    fields,
+		From:      from,
+		To:        to,
+		Tolerance: tolerance.String(),
+		Matched:   make(map[string]int, len(deviceIDs)),
+		Unmatched: make(map[string]int, len(deviceIDs)),
+		Truncated: truncated,
+	}
+	response.Rows = correlateReadings(anchor, deviceIDs, series, tolerance, fields, response.Matched, response.Unmatched)
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"data":   response,
+	})
+}
+
+// correlateReadings 以基准设备的每条读数为一行，为其他设备在容差内对齐最近的读数，并统计各设备对齐和未对齐的读数数
+func correlateReadings(anchor string, deviceIDs []string, series map[string][]models.SensorData, tolerance time.Duration,
+	fields []string, matched, unmatched map[string]int) []CorrelatedRow {
+	anchorReadings := series[anchor]
+	rows := make([]CorrelatedRow, len(anchorReadings))
+	for i, reading := range anchorReadings {
+		rows[i] = CorrelatedRow{
+			Timestamp: reading.Timestamp,
+			Readings: map[string]*CorrelatedReading{
+				anchor: {Timestamp: reading.Timestamp, Data: projectFields(reading.Data, fields)},
+			},
+		}
+	}
+	matched[anchor] = len(anchorReadings)
+	unmatched[anchor] = 0
+	
+	for _, deviceID := range deviceIDs {
+		if deviceID == anchor {
+			continue
+		}
+		readings := series[deviceID]
+		alignment := alignTimestamps(readingTimes(anchorReadings), readingTimes(readings), tolerance)
+		count := 0
+		for i, j := range alignment {
+			if j < 0 {
+				rows[i].Readings[deviceID] = nil
+				continue
+			}
+			count++
+			rows[i].Readings[deviceID] = &CorrelatedReading{
+				Timestamp: readings[j].Timestamp,
+				OffsetMs:  readings[j].Timestamp.Sub(rows[i].Timestamp).Milliseconds(),
+				Data:      projectFields(readings[j].Data, fields),
+			}
+		}
+		matched[deviceID] = count
+		unmatched[deviceID] = len(readings) - count
+	}
+	return rows
+}
+
+// alignTimestamps 对两个升序时间序列做归并对齐：为anchors中的每个时间在others中找容差内最近且尚未使用的时间，
+// 返回各anchor对应的others下标，未对齐为-1。两个序列都只向前扫描一次，复杂度为O(len(anchors)+len(others))
+func alignTimestamps(anchors, others []time.Time, tolerance time.Duration) []int {
+	alignment := make([]int, len(anchors))
+	j := 0
+	for i, t := range anchors {
+		alignment[i] = -1
+		// 早于容差窗口的读数不会再与后面的anchor对齐
+		for j < len(others) && others[j].Before(t.Add(-tolerance)) {
+			j++
+		}
+		// 在窗口内向前找最近的读数，距离开始变大时停止
+		best := -1
+		for k := j; k < len(others) && !others[k].After(t.Add(tolerance)); k++ {
+			if best >= 0 && absDuration(others[k].Sub(t)) >= absDuration(others[best].Sub(t)) {
+				break
+			}
+			best = k
+		}
+		if best < 0 {
+			continue
+		}
+		// 下一个anchor离该读数更近、且其后没有容差内的读数可用时才留给下一个anchor，当前行不对齐；
+		// 否则当前anchor保留该读数，下一个anchor改用后面的读数，尽量多对齐
+		if i+1 < len(anchors) {
+			next := anchors[i+1]
+			if absDuration(next.Sub(others[best])) < absDuration(t.Sub(others[best])) &&
+				(best+1 >= len(others) || absDuration(others[best+1].Sub(next)) > tolerance) {
+				continue
+			}
+		}
+		alignment[i] = best
+		j = best + 1
+	}
+	return alignment
+}
+
+// readingTimes 读数的时间序列
+func readingTimes(readings []models.SensorData) []time.Time {
+	times := make([]time.Time, len(readings))
+	for i, reading := range readings {
+		times[i] = reading.Timestamp
+	}
+	return times
+}
+
+// projectFields 只保留指定的字段，fields为空时返回全部字段
+func projectFields(data models.JSONB, fields []string) models.JSONB {
+	if len(fields) == 0 {
+		return data
+	}
+	projected := make(models.JSONB, len(fields))
+	for _, field := range fields {
+		if value, ok := data[field]; ok {
+			projected[field] = value
+		}
+	}
+	return projected
+}
+
+// absDuration 时长的绝对值
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// splitCSV 拆分逗号分隔的查询参数，去掉空白项
+func splitCSV(raw string) []string {
+	if raw == "" {
+		return nil
+	}
+	parts := strings.Split(raw, ",")
+	result := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
+
+// containsString 切片中是否包含value
+func containsString(values []string, value string) bool {
+	for _, item := range values {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAlignTimestamps(t *testing.T) {
+	base := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	at := func(offsets ...time.Duration) []time.Time {
+		times := make([]time.Time, len(offsets))
+		for i, offset := range offsets {
+			times[i] = base.Add(offset)
+		}
+		return times
+	}
+	every := func(step time.Duration, n int) []time.Time {
+		times := make([]time.Time, n)
+		for i := range times {
+			times[i] = base.Add(time.Duration(i) * step)
+		}
+		return times
+	}
+	s := time.Second
+
+	tests := []struct {
+		name      string
+		anchors   []time.Time
+		others    []time.Time
+		tolerance time.Duration
+		want      []int
+	}{
+		{"identical series", every(10*s, 3), every(10*s, 3), s, []int{0, 1, 2}},
+		{"no other readings", every(10*s, 2), nil, s, []int{-1, -1}},
+		{"denser other cadence picks the nearest reading", every(10*s, 4), every(3*s, 11), 2 * s, []int{0, 3, 7, 10}},
+		{"sparser other cadence leaves anchors unmatched", every(3*s, 5), every(10*s, 2), 2 * s, []int{0, -1, -1, 1, -1}},
+		{"tolerance boundary is inclusive", at(10 * s), at(8 * s), 2 * s, []int{0}},
+		{"just outside tolerance on either side", at(10 * s), at(8*s-time.Millisecond, 12*s+time.Millisecond), 2 * s, []int{-1}},
+		{"contested reading goes to the closer later anchor", at(0, 4*s), at(3 * s), 5 * s, []int{-1, 0}},
+		{"contested reading stays with the closer earlier anchor", at(0, 4*s), at(s), 5 * s, []int{0, -1}},
+		{"a reading is used at most once", at(0, s, 2*s), at(s), 5 * s, []int{-1, 0, -1}},
+		// 下一个anchor还有容差内的读数可用时，当前anchor不必让出最近的读数而落空
+		{"anchor keeps its reading when a later one is free", at(0, 4*s), at(3*s, 5*s), 5 * s, []int{0, 1}},
+		{"anchor keeps its reading when the next anchor can use the following one", at(0, 10*s), at(9*s, 19*s), 10 * s, []int{0, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := alignTimestamps(tt.anchors, tt.others, tt.tolerance)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("alignTimestamps() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
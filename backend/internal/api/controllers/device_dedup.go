@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+	
+	"iot-platform-backend/internal/config"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/models"
+)
+
+// readingHash 计算一次上报的去重哈希，由设备ID、设备上报的采集时间（_timestamp）和数据组成
+// 同一时刻的重发会命中，而数值相同但采集时间不同的新读数不会被误判；
+// 服务端接收时间每次重试都不同，不参与计算，未上报采集时间时timestamp为零值
+func readingHash(deviceID string, timestamp time.Time, data models.JSONB) (string, error) {
+	// encoding/json按键名排序输出对象，键顺序不同的相同数据得到相同哈希
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	
+	sum := sha256.New()
+	sum.Write([]byte(deviceID))
+	sum.Write([]byte{0})
+	if !timestamp.IsZero() {
+		sum.Write([]byte(timestamp.UTC().Format(time.RFC3339Nano)))
+	}
+	sum.Write([]byte{0})
+	sum.Write(encoded)
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// claimReading 为开启去重的设备登记本次上报，返回是否为去重窗口内的重复数据
+// 登记后写入失败时调用release撤销，使设备重试时不会被当作重复数据丢弃；
+// Redis不可用时放行写入，宁可重复也不丢数据
+func claimReading(ctx context.Context, device *models.Device, timestamp time.Time, data models.JSONB) (release func(), duplicate bool) {
+	release = func() {}
+	if !device.DedupEnabled || database.RedisClient == nil {
+		return release, false
+	}
+	
+	hash, err := readingHash(device.DeviceID, timestamp, data)
+	if err != nil {
+		return release, false
+	}
+	
+	cache := database.NewCache()
+	key := database.Keys.DeviceDedup(device.DeviceID, hash)
+	claimed, err := cache.SetNX(ctx, key, time.Now().Unix(), config.AppConfig.Ingestion.DedupWindow)
+	if err != nil {
+		return release, false
+	}
+	if !claimed {
+		return release, true
+	}
+	
+	return func() {
+		cache.Delete(context.Background(), key)
+	}, false
+}
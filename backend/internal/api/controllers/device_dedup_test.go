@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/testutil"
+)
+
+func TestReadingHash(t *testing.T) {
+	at := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	base, err := readingHash("d1", at, models.JSONB{"t": 21.5, "h": 40})
+	if err != nil {
+		t.Fatalf("readingHash: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		deviceID  string
+		timestamp time.Time
+		data      models.JSONB
+		wantSame  bool
+	}{
+		{"identical retry", "d1", at, models.JSONB{"t": 21.5, "h": 40}, true},
+		{"key order", "d1", at, models.JSONB{"h": 40, "t": 21.5}, true},
+		{"same instant in another zone", "d1", at.In(time.FixedZone("CST", 8*3600)), models.JSONB{"t": 21.5, "h": 40}, true},
+		{"new reading with same values", "d1", at.Add(time.Minute), models.JSONB{"t": 21.5, "h": 40}, false},
+		{"no reported timestamp", "d1", time.Time{}, models.JSONB{"t": 21.5, "h": 40}, false},
+		{"different value", "d1", at, models.JSONB{"t": 21.6, "h": 40}, false},
+		{"different device", "d2", at, models.JSONB{"t": 21.5, "h": 40}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := readingHash(tt.deviceID, tt.timestamp, tt.data)
+			if err != nil {
+				t.Fatalf("readingHash: %v", err)
+			}
+			if (hash == base) != tt.wantSame {
+				t.Errorf("hash equal = %v, want %v", hash == base, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestClaimReading(t *testing.T) {
+	testutil.LoadConfig(t)
+	mr := testutil.UseRedis(t)
+	ctx := context.Background()
+
+	device := &models.Device{DeviceID: "d1", DedupEnabled: true}
+	at := time.Now().Truncate(time.Second)
+	data := models.JSONB{"t": 21.5}
+
+	if _, duplicate := claimReading(ctx, device, at, data); duplicate {
+		t.Fatal("first reading reported as duplicate")
+	}
+	if _, duplicate := claimReading(ctx, device, at, data); !duplicate {
+		t.Error("retry within the window was not suppressed")
+	}
+	if _, duplicate := claimReading(ctx, device, at.Add(time.Second), data); duplicate {
+		t.Error("new reading with the same values was suppressed")
+	}
+
+	// 写入失败时撤销登记，重试不会被当作重复数据
+	release, _ := claimReading(ctx, device, at, models.JSONB{"t": 30})
+	release()
+	if _, duplicate := claimReading(ctx, device, at, models.JSONB{"t": 30}); duplicate {
+		t.Error("released reading reported as duplicate")
+	}
+
+	// 去重窗口过后重新接受
+	mr.FastForward(2 * time.Hour)
+	if _, duplicate := claimReading(ctx, device, at, data); duplicate {
+		t.Error("reading outside the window reported as duplicate")
+	}
+
+	disabled := &models.Device{DeviceID: "d2"}
+	for i := 0; i < 2; i++ {
+		if _, duplicate := claimReading(ctx, disabled, at, data); duplicate {
+			t.Error("device without dedup reported a duplicate")
+		}
+	}
+}
+
+func TestImportDevicesKeepsDedupFlag(t *testing.T) {
+	testutil.LoadConfig(t)
+	db := testutil.UseDB(t)
+	user := createTestUser(t, "importer")
+
+	results, err := importDeviceChunk(context.Background(), user.ID, []CreateDeviceRequest{
+		{DeviceID: "dedup-on", Name: "a", Type: models.SoilMoisture, DedupEnabled: true},
+		{DeviceID: "dedup-off", Name: "b", Type: models.SoilMoisture},
+	}, 0)
+	if err != nil {
+		t.Fatalf("importDeviceChunk: %v", err)
+	}
+	for _, result := range results {
+		if result.Status != "created" {
+			t.Fatalf("result = %+v", result)
+		}
+	}
+
+	for deviceID, want := range map[string]bool{"dedup-on": true, "dedup-off": false} {
+		var device models.Device
+		if err := db.Where("device_id = ?", deviceID).First(&device).Error; err != nil {
+			t.Fatalf("load %s: %v", deviceID, err)
+		}
+		if device.DedupEnabled != want {
+			t.Errorf("%s dedup_enabled = %v, want %v", deviceID, device.DedupEnabled, want)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+	
+	"github.com/gin-gonic/gin"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/ingest"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+)
+
+// 字段发现时抽样的最近数据条数
+const (
+	defaultFieldSampleRows = 200
+	maxFieldSampleRows     = 2000
+)
+
+// DeviceField 设备上报的字段
+type DeviceField struct {
+	Path      string      `json:"path"`                 // 字段路径，嵌套字段以点号连接，与派生字段表达式的引用方式一致
+	Type      string      `json:"type"`                 // number、string、boolean、array、null，出现多种类型时为mixed
+	Types     []string    `json:"types,omitempty"`      // 类型为mixed时出现过的所有类型
+	Seen      int         `json:"seen"`                 // 抽样数据中包含该字段的条数
+	LastValue interface{} `json:"last_value,omitempty"` // 最近一次上报的值
+	LastSeen  *time.Time  `json:"last_seen,omitempty"`
+	Declared  bool        `json:"declared"`            // 是否为设备类型定义的字段
+	Label     string      `json:"label,omitempty"`
+	Unit      string      `json:"unit,omitempty"`
+}
+
+// fieldStats 字段发现过程中的统计
+type fieldStats struct {
+	field *DeviceField
+	types map[string]bool
+}
+
+// GetDeviceFields 获取设备上报的字段
+// @Summary 获取设备上报的字段
+// @Description 抽样设备最近的数据，返回出现过的字段路径、推断的类型和最近的值，并合并设备类型定义的字段（未上报的定义字段seen为0）。
+// @Description 数据中包含INGEST_COMPUTED_FIELDS配置的派生字段
+// @Tags 设备管理
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "设备ID或device_id"
+// @Param sample query int false "抽样的最近数据条数（最多2000）" default(200)
+// @Success 200 {object} []DeviceField
+// @Failure 404 {object} map[string]interface{}
+// @Router /devices/{id}/fields [get]
+func (ctrl *DeviceController) GetDeviceFields(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	sample := defaultFieldSampleRows
+	if raw := c.Query("sample"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid sample size",
+			})
+			return
+		}
+		if value > maxFieldSampleRows {
+			value = maxFieldSampleRows
+		}
+		sample = value
+	}
+	
+	// 验证设备所有权
+	db := database.GetDBWithContext(c.Request.Context())
+	var device models.Device
+	if err := db.Scopes(deviceParam(c, true)).Where("owner_id = ?", userID).First(&device).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Device not found",
+		})
+		return
+	}
+	
+	deviceID := device.DeviceID
+	
+	var rows []models.SensorData
+	if err := db.Select("timestamp", "data").
+		Where("device_id = ?", deviceID).
+		Order("timestamp DESC").
+		Limit(sample).
+		Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch sensor data",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"data":   discoverDeviceFields(device.Type, rows),
+		"sample": len(rows),
+	})
+}
+
+// discoverDeviceFields 从按时间倒序排列的数据中推断字段，并合并设备类型定义的字段，按路径排序
+func discoverDeviceFields(deviceType models.DeviceType, rows []models.SensorData) []DeviceField {
+	stats := make(map[string]*fieldStats)
+	for i := range rows {
+		timestamp := rows[i].Timestamp
+		collectFields(stats, "", ingest.Enrich(deviceType, rows[i].Data), timestamp)
+	}
+	
+	for _, spec := range models.DeviceTypeFields[deviceType] {
+		entry, ok := stats[spec.Key]
+		if !ok {
+			fieldType := spec.Type
+			if fieldType == "enum" {
+				fieldType = "string"
+			}
+			entry = &fieldStats{field: &DeviceField{Path: spec.Key, Type: fieldType}}
+			stats[spec.Key] = entry
+		}
+		entry.field.Declared = true
+		entry.field.Label = spec.Label
+		entry.field.Unit = spec.Unit
+	}
+	
+	fields := make([]DeviceField, 0, len(stats))
+	for _, entry := range stats {
+		if len(entry.types) > 0 {
+			types := make([]string, 0, len(entry.types))
+			for fieldType := range entry.types {
+				types = append(types, fieldType)
+			}
+			sort.Strings(types)
+			entry.field.Type = types[0]
+			if len(types) > 1 {
+				entry.field.Type = "mixed"
+				entry.field.Types = types
+			}
+		}
+		fields = append(fields, *entry.field)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Path < fields[j].Path
+	})
+	return fields
+}
+
+// collectFields 递归记录对象中的字段，嵌套对象展开为点号连接的路径，数组不展开
+func collectFields(stats map[string]*fieldStats, prefix string, data map[string]interface{}, timestamp time.Time) {
+	for key, value := range data {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if nested, ok := asJSONObject(value); ok {
+			collectFields(stats, path, nested, timestamp)
+			continue
+		}
+		
+		entry, ok := stats[path]
+		if !ok {
+			entry = &fieldStats{field: &DeviceField{Path: path}, types: make(map[string]bool)}
+			stats[path] = entry
+		}
+		if entry.types == nil {
+			entry.types = make(map[string]bool)
+		}
+		entry.types[jsonValueType(value)] = true
+		entry.field.Seen++
+		// 数据按时间倒序，第一次遇到的就是最近的值
+		if entry.field.LastSeen == nil {
+			seen := timestamp
+			entry.field.LastSeen = &seen
+			entry.field.LastValue = value
+		}
+	}
+}
+
+// asJSONObject 判断值是否为JSON对象
+func asJSONObject(value interface{}) (map[string]interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v, true
+	case models.JSONB:
+		return map[string]interface{}(v), true
+	}
+	return nil, false
+}
+
+// jsonValueType JSON值的类型名
+func jsonValueType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64, float32, int, int64, uint64, json.Number:
+		return "number"
+	case []interface{}:
+		return "array"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+)
+
+// FirmwareStats 按固件版本统计的设备数量
+type FirmwareStats struct {
+	TotalDevices int64                  `json:"total_devices"`
+	Unknown      int64                  `json:"unknown"` // 尚未上报或设置固件版本的设备数
+	Versions     []FirmwareVersionCount `json:"versions"`
+}
+
+// FirmwareVersionCount 某个固件版本的设备数量
+type FirmwareVersionCount struct {
+	Version string `json:"version"`
+	Devices int64  `json:"devices"`
+	Online  int64  `json:"online"`
+}
+
+// recordFirmwareVersion 读数被接受后保存上报的固件版本，与设备当前版本不同时更新，
+// 只写firmware_version一列，失败时只记录日志，不影响读数保存
+func recordFirmwareVersion(ctx context.Context, db *gorm.DB, device *models.Device, version string) {
+	if version == "" || version == device.FirmwareVersion {
+		return
+	}
+	if err := db.Model(&models.Device{}).Where("id = ?", device.ID).
+		UpdateColumn("firmware_version", version).Error; err != nil {
+		log.Printf("Failed to update firmware version of device %s: %v", device.DeviceID, err)
+		return
+	}
+	device.FirmwareVersion = version
+	
+	if database.RedisClient != nil {
+		database.NewCache().Delete(ctx, database.Keys.Device(device.DeviceID), database.Keys.DeviceList(device.OwnerID))
+	}
+}
+
+// normalizeFirmwareVersion 校验手动设置的固件版本，空字符串表示清除
+func normalizeFirmwareVersion(version string) (string, bool) {
+	version = strings.TrimSpace(version)
+	return version, len(version) <= models.MaxFirmwareVersionLength
+}
+
+// GetFirmwareStats 获取固件版本分布
+// @Summary 获取固件版本分布
+// @Description 按固件版本统计当前用户的设备数量和在线数量，按设备数量降序排列，用于跟踪分批升级进度
+// @Tags 设备管理
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} FirmwareStats
+// @Router /devices/firmware-stats [get]
+func (ctrl *DeviceController) GetFirmwareStats(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+		})
+		return
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	var rows []FirmwareVersionCount
+	if err := db.Model(&models.Device{}).
+		Select("firmware_version AS version, COUNT(*) AS devices, COUNT(*) FILTER (WHERE last_seen > ?) AS online",
+			time.Now().Add(-models.OnlineThreshold)).
+		Where("owner_id = ?", userID).
+		Group("firmware_version").
+		Order("devices DESC, version ASC").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to compute firmware stats",
+		})
+		return
+	}
+	
+	stats := FirmwareStats{Versions: make([]FirmwareVersionCount, 0, len(rows))}
+	for _, row := range rows {
+		stats.TotalDevices += row.Devices
+		if row.Version == "" {
+			stats.Unknown += row.Devices
+			continue
+		}
+		stats.Versions = append(stats.Versions, row)
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"data":   stats,
+	})
+}
@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"net/http"
+	"sort"
+	"time"
+	
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+)
+
+const (
+	fleetStatsCacheTTL = 30 * time.Second
+	fleetStatsWindow   = 24 * time.Hour // 上报间隔和告警状态的统计窗口
+	fleetTopDevices    = 5              // 上报最频繁的设备数
+)
+
+// FleetStats 用户全部设备的汇总统计
+type FleetStats struct {
+	TotalDevices      int64             `json:"total_devices"`
+	OnlineDevices     int64             `json:"online_devices"`
+	MaintenanceDevices int64            `json:"maintenance_devices"` // 维护模式中的设备数
+	ReportingDevices  int64             `json:"reporting_devices"`   // 最近24小时有上报的设备数
+	TotalReadings     int64             `json:"total_readings"`      // 累计数据点数（来自设备数据计数）
+	ReadingsToday     int64             `json:"readings_today"`      // UTC当天的数据点数
+	AvgReportInterval *float64          `json:"avg_report_interval"` // 最近24小时各设备平均上报间隔的均值（秒），无足够数据时为null
+	AlertingDevices   int64             `json:"alerting_devices"`    // 最近一条读数（24小时内）质量为suspect或bad的设备数，不含维护中的设备
+	TopDevices        []FleetDeviceRate `json:"top_devices"`         // 最近1小时上报最频繁的设备
+	GeneratedAt       time.Time         `json:"generated_at"`
+}
+
+// FleetDeviceRate 设备上报频率
+type FleetDeviceRate struct {
+	DeviceID      string  `json:"device_id"`
+	Name          string  `json:"name"`
+	Readings      int64   `json:"readings"`        // 最近1小时的数据点数
+	RatePerMinute float64 `json:"rate_per_minute"` // 最近1小时的平均每分钟数据点数
+}
+
+// fleetDeviceRow 按设备聚合的窗口期统计
+type fleetDeviceRow struct {
+	DeviceID      string
+	Readings      int64
+	Today         int64
+	LastHour      int64
+	SpanSeconds   float64
+	LatestQuality string
+}
+
+// GetFleetStats 获取设备汇总统计
+// @Summary 获取设备汇总统计
+// @Description 汇总当前用户所有设备：在线数、累计和当天数据点数、平均上报间隔、告警中的设备数以及最近1小时上报最频繁的设备，结果缓存30秒
+// @Tags 设备管理
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} FleetStats
+// @Router /devices/fleet-stats [get]
+func (ctrl *DeviceController) GetFleetStats(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+		})
+		return
+	}
+	
+	// 每次请求都要聚合最近24小时的数据，短时间缓存即可
+	cache := database.NewCache()
+	cacheKey := database.Keys.FleetStats(userID)
+	if database.RedisClient != nil {
+		var cached FleetStats
+		if err := cache.Get(c, cacheKey, &cached); err == nil {
+			c.JSON(http.StatusOK, gin.H{
+				"status": 1,
+				"data":   cached,
+			})
+			return
+		}
+	}
+	
+	stats, err := computeFleetStats(c, database.GetDBWithContext(c.Request.Context()), userID, time.Now().UTC())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to compute fleet stats",
+		})
+		return
+	}
+	
+	if database.RedisClient != nil {
+		cache.Set(c, cacheKey, stats, fleetStatsCacheTTL)
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"data":   stats,
+	})
+}
+
+// computeFleetStats 汇总用户设备的统计，窗口期数据用一次分组查询完成
+func computeFleetStats(c *gin.Context, db *gorm.DB, userID uint, now time.Time) (FleetStats, error) {
+	stats := FleetStats{TopDevices: []FleetDeviceRate{}, GeneratedAt: now}
+	
+	var devices []models.Device
+	if err := db.Select("device_id", "name", "last_seen", "maintenance", "maintenance_until").Where("owner_id = ?", userID).Find(&devices).Error; err != nil {
+		return stats, err
+	}
+	names := make(map[string]string, len(devices))
+	inMaintenance := make(map[string]bool)
+	onlineSince := now.Add(-models.OnlineThreshold)
+	for _, device := range devices {
+		names[device.DeviceID] = device.Name
+		if device.InMaintenance(now) {
+			inMaintenance[device.DeviceID] = true
+			stats.MaintenanceDevices++
+		}
+		if device.LastSeen != nil && device.LastSeen.After(onlineSince) {
+			stats.OnlineDevices++
+		}
+		// 累计数据点数使用Redis中的设备计数，未命中时回源统计
+		count, err := database.GetDeviceDataCount(c, device.DeviceID)
+		if err != nil {
+			return stats, err
+		}
+		stats.TotalReadings += count
+	}
+	stats.TotalDevices = int64(len(devices))
+	if len(devices) == 0 {
+		return stats, nil
+	}
+	
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	var rows []fleetDeviceRow
+	if err := db.Raw(`SELECT s.device_id,
+			COUNT(*) AS readings,
+			COUNT(*) FILTER (WHERE s.timestamp >= ?) AS today,
+			COUNT(*) FILTER (WHERE s.timestamp >= ?) AS last_hour,
+			EXTRACT(EPOCH FROM MAX(s.timestamp) - MIN(s.timestamp)) AS span_seconds,
+			(ARRAY_AGG(s.quality ORDER BY s.timestamp DESC))[1] AS latest_quality
+		FROM sensor_data s
+		JOIN devices d ON d.device_id = s.device_id AND d.deleted_at IS NULL
+		WHERE d.owner_id = ? AND s.timestamp >= ?
+		GROUP BY s.device_id`,
+		today, now.Add(-time.Hour), userID, now.Add(-fleetStatsWindow)).
+		Scan(&rows).Error; err != nil {
+		return stats, err
+	}
+	
+	var intervalSum float64
+	var intervalDevices int
+	for _, row := range rows {
+		stats.ReportingDevices++
+		stats.ReadingsToday += row.Today
+		if !inMaintenance[row.DeviceID] && (row.LatestQuality == models.QualitySuspect || row.LatestQuality == models.QualityBad) {
+			stats.AlertingDevices++
+		}
+		// 至少两条读数才能计算间隔
+		if row.Readings > 1 {
+			intervalSum += row.SpanSeconds / float64(row.Readings-1)
+			intervalDevices++
+		}
+	}
+	if intervalDevices > 0 {
+		avg := intervalSum / float64(intervalDevices)
+		stats.AvgReportInterval = &avg
+	}
+	
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].LastHour != rows[j].LastHour {
+			return rows[i].LastHour > rows[j].LastHour
+		}
+		return rows[i].DeviceID < rows[j].DeviceID
+	})
+	for _, row := range rows {
+		if row.LastHour == 0 || len(stats.TopDevices) == fleetTopDevices {
+			break
+		}
+		stats.TopDevices = append(stats.TopDevices, FleetDeviceRate{
+			DeviceID:      row.DeviceID,
+			Name:          names[row.DeviceID],
+			Readings:      row.LastHour,
+			RatePerMinute: float64(row.LastHour) / 60,
+		})
+	}
+	
+	return stats, nil
+}
@@ -0,0 +1,418 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+	
+	"github.com/gin-gonic/gin"
+	"iot-platform-backend/internal/config"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/shutdown"
+	"iot-platform-backend/internal/websocket"
+)
+
+// importChunkSize 每批查重和写入的设备数，异步任务每批结束后更新一次进度
+const importChunkSize = 100
+
+// 导入任务状态
+const (
+	importJobRunning   = "running"
+	importJobCompleted = "completed"
+	importJobCancelled = "cancelled"
+	importJobFailed    = "failed"
+)
+
+// ImportDevicesRequest 批量导入设备请求
+type ImportDevicesRequest struct {
+	Devices []CreateDeviceRequest `json:"devices" binding:"required,min=1,dive"`
+}
+
+// ImportResult 单个设备的导入结果
+type ImportResult struct {
+	Index    int    `json:"index"`
+	DeviceID string `json:"device_id"`
+	Status   string `json:"status"` // created, duplicate, invalid
+	ID       uint   `json:"id,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ImportJob 异步导入任务状态，保存在Redis中
+type ImportJob struct {
+	ID         string         `json:"id"`
+	OwnerID    uint           `json:"owner_id"`
+	Status     string         `json:"status"` // running, completed, cancelled, failed
+	Total      int            `json:"total"`
+	Processed  int            `json:"processed"`
+	Created    int            `json:"created"`
+	Skipped    int            `json:"skipped"`
+	Error      string         `json:"error,omitempty"`
+	Results    []ImportResult `json:"results"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty"`
+}
+
+// record 累计一批导入结果
+func (job *ImportJob) record(results []ImportResult) {
+	for _, result := range results {
+		if result.Status == "created" {
+			job.Created++
+		} else {
+			job.Skipped++
+		}
+	}
+	job.Processed += len(results)
+	job.Results = append(job.Results, results...)
+}
+
+// ImportDevices 批量导入设备
+// @Summary 批量导入设备
+// @Description 批量创建设备并返回每个设备的导入结果；async=true时立即返回任务ID，在后台分批导入
+// @Tags 设备管理
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param async query bool false "异步导入"
+// @Param request body ImportDevicesRequest true "设备列表"
+// @Success 200 {object} ImportJob
+// @Success 202 {object} ImportJob
+// @Failure 400 {object} map[string]interface{}
+// @Failure 413 {object} map[string]interface{}
+// @Router /devices/import [post]
+func (ctrl *DeviceController) ImportDevices(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+		})
+		return
+	}
+	
+	var req ImportDevicesRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	
+	cfg := config.AppConfig.Devices
+	if len(req.Devices) > cfg.ImportMaxDevices {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": fmt.Sprintf("At most %d devices can be imported at once", cfg.ImportMaxDevices),
+		})
+		return
+	}
+	
+	// 按整批设备数检查配额，避免导入到一半才超出
+	if !checkDeviceQuota(c, database.GetDBWithContext(c.Request.Context()), userID, len(req.Devices)) {
+		return
+	}
+	
+	async := c.Query("async") == "true"
+	if !async {
+		if len(req.Devices) > cfg.ImportSyncMax {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": fmt.Sprintf("Synchronous import is limited to %d devices, use async=true for larger imports", cfg.ImportSyncMax),
+			})
+			return
+		}
+		
+		job := ImportJob{
+			OwnerID:   userID,
+			Status:    importJobCompleted,
+			Total:     len(req.Devices),
+			Results:   make([]ImportResult, 0, len(req.Devices)),
+			CreatedAt: time.Now(),
+		}
+		for start := 0; start < len(req.Devices); start += importChunkSize {
+			results, err := importDeviceChunk(c.Request.Context(), userID, req.Devices, start)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Failed to import devices",
+				})
+				return
+			}
+			job.record(results)
+		}
+		finishImport(c.Request.Context(), &job)
+		
+		c.JSON(http.StatusOK, gin.H{
+			"status": 1,
+			"msg":    "设备导入完成",
+			"data":   job,
+		})
+		return
+	}
+	
+	// 任务状态保存在Redis中，多实例部署时任意实例都能查询和取消
+	if database.RedisClient == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Async import is unavailable",
+		})
+		return
+	}
+	
+	jobID, err := newImportJobID()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create import job",
+		})
+		return
+	}
+	now := time.Now()
+	job := ImportJob{
+		ID:        jobID,
+		OwnerID:   userID,
+		Status:    importJobRunning,
+		Total:     len(req.Devices),
+		Results:   []ImportResult{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := saveImportJob(c.Request.Context(), &job); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create import job",
+		})
+		return
+	}
+	
+	// 请求结束后继续执行，不能使用请求的context；退出时等待当前导入完成
+	shutdown.Default.Go("device import", func() {
+		runImportJob(job, req.Devices)
+	})
+	
+	c.JSON(http.StatusAccepted, gin.H{
+		"status": 1,
+		"msg":    "导入任务已创建",
+		"data":   job,
+	})
+}
+
+// GetImportJob 查询异步导入任务
+// @Summary 查询设备导入任务
+// @Description 返回异步导入任务的进度和已处理设备的导入结果
+// @Tags 设备管理
+// @Security BearerAuth
+// @Produce json
+// @Param job_id path string true "任务ID"
+// @Success 200 {object} ImportJob
+// @Failure 404 {object} map[string]interface{}
+// @Router /devices/import/{job_id} [get]
+func (ctrl *DeviceController) GetImportJob(c *gin.Context) {
+	job, ok := loadImportJobParam(c)
+	if !ok {
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"data":   job,
+	})
+}
+
+// CancelImportJob 取消异步导入任务
+// @Summary 取消设备导入任务
+// @Description 停止尚未处理的设备导入，已导入的设备会保留
+// @Tags 设备管理
+// @Security BearerAuth
+// @Produce json
+// @Param job_id path string true "任务ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /devices/import/{job_id} [delete]
+func (ctrl *DeviceController) CancelImportJob(c *gin.Context) {
+	job, ok := loadImportJobParam(c)
+	if !ok {
+		return
+	}
+	if job.Status != importJobRunning {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Import job is already " + job.Status,
+		})
+		return
+	}
+	
+	// 由执行任务的协程在下一批开始前检查并停止
+	cache := database.NewCache()
+	if err := cache.Set(c, database.Keys.DeviceImportCancel(job.ID), true, config.AppConfig.Devices.ImportJobTTL); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to cancel import job",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"msg":    "已请求取消导入任务",
+		"data": gin.H{
+			"id":        job.ID,
+			"processed": job.Processed,
+			"total":     job.Total,
+		},
+	})
+}
+
+// loadImportJobParam 按路径参数加载当前用户的导入任务，失败时已写入响应
+func loadImportJobParam(c *gin.Context) (ImportJob, bool) {
+	var job ImportJob
+	if database.RedisClient == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Import job not found",
+		})
+		return job, false
+	}
+	
+	cache := database.NewCache()
+	if err := cache.Get(c, database.Keys.DeviceImport(c.Param("job_id")), &job); err != nil || job.OwnerID != middleware.GetUserID(c) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Import job not found",
+		})
+		return job, false
+	}
+	return job, true
+}
+
+// runImportJob 在后台分批导入设备，每批结束后保存进度并检查是否被取消
+func runImportJob(job ImportJob, devices []CreateDeviceRequest) {
+	ctx := context.Background()
+	cache := database.NewCache()
+	
+	for start := 0; start < len(devices); start += importChunkSize {
+		if cancelled, _ := cache.Exists(ctx, database.Keys.DeviceImportCancel(job.ID)); cancelled {
+			job.Status = importJobCancelled
+			break
+		}
+		
+		results, err := importDeviceChunk(ctx, job.OwnerID, devices, start)
+		if err != nil {
+			job.Status = importJobFailed
+			job.Error = "Failed to import devices"
+			break
+		}
+		job.record(results)
+		job.UpdatedAt = time.Now()
+		if job.Processed < job.Total {
+			saveImportJob(ctx, &job)
+		}
+	}
+	
+	if job.Status == importJobRunning {
+		job.Status = importJobCompleted
+	}
+	finishImport(ctx, &job)
+	saveImportJob(ctx, &job)
+	cache.Delete(ctx, database.Keys.DeviceImportCancel(job.ID))
+	
+	if websocket.DefaultManager != nil {
+		websocket.DefaultManager.Notify(job.OwnerID, "device_import_finished", models.JSONB{
+			"job_id":  job.ID,
+			"status":  job.Status,
+			"created": job.Created,
+			"skipped": job.Skipped,
+		})
+	}
+}
+
+// importDeviceChunk 导入从start开始的一批设备，逐个返回结果
+// 已存在的device_id（包括已软删除的设备）和同一请求中重复的device_id记为duplicate
+func importDeviceChunk(ctx context.Context, userID uint, devices []CreateDeviceRequest, start int) ([]ImportResult, error) {
+	end := start + importChunkSize
+	if end > len(devices) {
+		end = len(devices)
+	}
+	chunk := devices[start:end]
+	
+	deviceIDs := make([]string, 0, len(chunk))
+	for _, req := range chunk {
+		deviceIDs = append(deviceIDs, req.DeviceID)
+	}
+	
+	db := database.GetDBWithContext(ctx)
+	var existingIDs []string
+	if err := db.Unscoped().Model(&models.Device{}).Where("device_id IN ?", deviceIDs).Pluck("device_id", &existingIDs).Error; err != nil {
+		return nil, err
+	}
+	// 前面批次中已导入的设备也会在这里查到
+	taken := make(map[string]bool, len(existingIDs))
+	for _, id := range existingIDs {
+		taken[id] = true
+	}
+	
+	results := make([]ImportResult, 0, len(chunk))
+	for i, req := range chunk {
+		result := ImportResult{Index: start + i, DeviceID: req.DeviceID}
+		
+		location, err := models.NormalizeLocation(req.Location)
+		switch {
+		case taken[req.DeviceID]:
+			result.Status = "duplicate"
+		case err != nil:
+			result.Status = "invalid"
+			result.Error = err.Error()
+		case !req.Type.Valid():
+			result.Status = "invalid"
+			result.Error = fmt.Sprintf("%v: %d", models.ErrInvalidDeviceType, req.Type)
+		default:
+			device := models.Device{
+				DeviceID:            req.DeviceID,
+				Name:                req.Name,
+				Type:                req.Type,
+				Location:            location,
+				Config:              models.ApplyDeviceConfigDefaults(req.Type, req.Config),
+				ConfigSchemaVersion: models.LatestConfigSchemaVersion,
+				Tags:                normalizeTags(req.Tags),
+				DedupEnabled:        req.DedupEnabled,
+				Status:              "offline",
+				OwnerID:             userID,
+			}
+			if err := db.Create(&device).Error; err != nil {
+				if !database.IsDuplicateKeyError(err) {
+					return nil, err
+				}
+				result.Status = "duplicate"
+			} else {
+				result.Status = "created"
+				result.ID = device.ID
+			}
+			taken[req.DeviceID] = true
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// finishImport 导入结束后清除设备列表缓存并初始化新设备的数据点计数
+func finishImport(ctx context.Context, job *ImportJob) {
+	now := time.Now()
+	job.UpdatedAt = now
+	job.FinishedAt = &now
+	if job.Created == 0 {
+		return
+	}
+	
+	database.NewCache().Delete(ctx, database.Keys.DeviceList(job.OwnerID))
+	for _, result := range job.Results {
+		if result.Status == "created" {
+			database.SeedDeviceSummary(ctx, result.DeviceID)
+		}
+	}
+}
+
+// saveImportJob 保存任务状态，过期时间从每次更新起重新计算
+func saveImportJob(ctx context.Context, job *ImportJob) error {
+	return database.NewCache().Set(ctx, database.Keys.DeviceImport(job.ID), job, config.AppConfig.Devices.ImportJobTTL)
+}
+
+// newImportJobID 生成随机任务ID
+func newImportJobID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
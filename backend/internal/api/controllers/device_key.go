@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+	
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/models"
+)
+
+// DeviceKeyHeader 设备使用的密钥请求头
+const DeviceKeyHeader = "X-Device-Key"
+
+// DeviceKeyResponse 签发设备密钥响应，密钥只在签发时返回一次
+type DeviceKeyResponse struct {
+	DeviceID string `json:"device_id"`
+	Key      string `json:"key"`
+}
+
+// DevicePingResponse 设备连通性测试响应
+type DevicePingResponse struct {
+	DeviceID            string    `json:"device_id"`
+	ServerTime          time.Time `json:"server_time"`
+	ConfigVersion       int       `json:"config_version"`        // 设备配置按当前服务解析后的结构版本
+	LatestConfigVersion int       `json:"latest_config_version"` // 服务支持的最新配置结构版本
+}
+
+// IssueDeviceKey 签发或轮换设备密钥
+// @Summary 签发设备密钥
+// @Description 为设备生成新的密钥，旧密钥立即失效。密钥只在本次响应中返回，服务端仅保存摘要
+// @Tags 设备管理
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "设备ID或device_id"
+// @Success 200 {object} DeviceKeyResponse
+// @Failure 404 {object} map[string]interface{}
+// @Router /devices/{id}/key [post]
+func (ctrl *DeviceController) IssueDeviceKey(c *gin.Context) {
+	db := database.GetDBWithContext(c.Request.Context())
+	var device models.Device
+	// 所有权已由OwnerOrAdminRequired校验
+	if err := db.Scopes(deviceParam(c, false)).First(&device).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Device not found",
+		})
+		return
+	}
+	
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to generate device key",
+		})
+		return
+	}
+	key := hex.EncodeToString(raw)
+	
+	if err := db.Model(&models.Device{}).Where("id = ?", device.ID).
+		UpdateColumn("key_hash", models.HashDeviceKey(key)).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to save device key",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"msg":    "设备密钥已签发，请妥善保存",
+		"data":   DeviceKeyResponse{DeviceID: device.DeviceID, Key: key},
+	})
+}
+
+// PingDevice 设备连通性测试
+// @Summary 设备连通性测试
+// @Description 现场调试用的轻量往返：校验设备密钥并更新最后通信时间，不写入传感器数据，返回服务器时间和设备配置版本
+// @Tags 设备数据
+// @Produce json
+// @Param id path string true "设备的device_id"
+// @Param X-Device-Key header string true "设备密钥"
+// @Success 200 {object} DevicePingResponse
+// @Failure 401 {object} map[string]interface{} "密钥缺失或错误"
+// @Failure 404 {object} map[string]interface{} "设备不存在"
+// @Router /devices/{id}/ping [post]
+func (ctrl *DeviceController) PingDevice(c *gin.Context) {
+	db := database.GetDBWithContext(c.Request.Context())
+	device, ok := authenticateDevice(c, db)
+	if !ok {
+		return
+	}
+	
+	// 连通性测试总是立即写入，不受上报节流限制
+	if err := database.TouchDeviceLastSeen(c, db, []string{device.DeviceID}, 0); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update device status",
+		})
+		return
+	}
+	
+	_, version, _ := models.MigrateConfig(device.Type, device.ConfigSchemaVersion, device.Config)
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"msg":    "pong",
+		"data": DevicePingResponse{
+			DeviceID:            device.DeviceID,
+			ServerTime:          time.Now().UTC(),
+			ConfigVersion:       version,
+			LatestConfigVersion: models.LatestConfigSchemaVersion,
+		},
+	})
+}
+
+// authenticateDevice 按device_id加载设备并校验X-Device-Key，失败时写入404或401响应并返回false
+func authenticateDevice(c *gin.Context, db *gorm.DB) (*models.Device, bool) {
+	var device models.Device
+	if err := db.Scopes(deviceIDParam(c)).First(&device).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Device not found",
+		})
+		return nil, false
+	}
+	
+	if !device.CheckKey(c.GetHeader(DeviceKeyHeader)) {
+		details := "Invalid device key"
+		if device.KeyHash == "" {
+			details = "No key has been issued for this device"
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Device authentication failed",
+			"details": details,
+		})
+		return nil, false
+	}
+	return &device, true
+}
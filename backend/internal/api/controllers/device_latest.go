@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"net/http"
+	
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/ingest"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+)
+
+// maxLatestDevices 单次批量查询最新读数的设备数上限
+const maxLatestDevices = 100
+
+// LatestReadingsRequest 批量获取最新读数请求
+type LatestReadingsRequest struct {
+	DeviceIDs []string `json:"device_ids" binding:"omitempty,max=100"` // 为空时返回当前用户的全部设备
+}
+
+// LatestReadingResult 单个设备的最新读数
+type LatestReadingResult struct {
+	DeviceID string             `json:"device_id"`
+	Status   string             `json:"status"` // ok, no_data, forbidden, not_found
+	Reading  *models.SensorData `json:"reading,omitempty"`
+}
+
+// GetLatestReadings 批量获取设备最新读数
+// @Summary 批量获取设备最新读数
+// @Description 一次返回多个设备的最新传感器数据，未指定设备ID时返回当前用户的全部设备（最多100个）
+// @Tags 设备管理
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body LatestReadingsRequest false "设备ID列表"
+// @Success 200 {object} []LatestReadingResult
+// @Failure 400 {object} map[string]interface{}
+// @Router /devices/latest [post]
+func (ctrl *DeviceController) GetLatestReadings(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+		})
+		return
+	}
+	
+	var req LatestReadingsRequest
+	if c.Request.ContentLength != 0 && !bindJSON(c, &req) {
+		return
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	ids := uniqueStrings(req.DeviceIDs)
+	skipped := make(map[string]string)
+	var owned []string
+	truncated := false
+	
+	if len(ids) == 0 {
+		// 默认返回当前用户的全部设备，超出上限时截断
+		if err := db.Model(&models.Device{}).
+			Where("owner_id = ?", userID).
+			Order("id ASC").
+			Limit(maxLatestDevices+1).
+			Pluck("device_id", &owned).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to fetch devices",
+			})
+			return
+		}
+		if len(owned) > maxLatestDevices {
+			owned = owned[:maxLatestDevices]
+			truncated = true
+		}
+		ids = owned
+	} else {
+		var devices []models.Device
+		if err := db.Select("device_id", "owner_id").Where("device_id IN ?", ids).Find(&devices).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to fetch devices",
+			})
+			return
+		}
+		
+		found := make(map[string]bool, len(devices))
+		for _, device := range devices {
+			found[device.DeviceID] = true
+			if device.OwnerID != userID {
+				skipped[device.DeviceID] = "forbidden"
+				continue
+			}
+			owned = append(owned, device.DeviceID)
+		}
+		for _, id := range ids {
+			if !found[id] {
+				skipped[id] = "not_found"
+			}
+		}
+	}
+	
+	// 每个设备取时间最新的一条，排序与idx_sensor_data_device_time_id索引一致
+	var readings []models.SensorData
+	if len(owned) > 0 {
+		if err := db.Raw(
+			"SELECT DISTINCT ON (device_id) * FROM sensor_data WHERE device_id IN ? ORDER BY device_id, timestamp DESC NULLS LAST, id DESC",
+			owned,
+		).Scan(&readings).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to fetch latest readings",
+			})
+			return
+		}
+	}
+	
+	if len(readings) > 0 && ingest.HasComputedFields() {
+		enrichLatestReadings(db, readings)
+	}
+	
+	latest := make(map[string]*models.SensorData, len(readings))
+	for i := range readings {
+		latest[readings[i].DeviceID] = &readings[i]
+	}
+	
+	results := make([]LatestReadingResult, 0, len(ids))
+	for _, id := range ids {
+		if reason, ok := skipped[id]; ok {
+			results = append(results, LatestReadingResult{DeviceID: id, Status: reason})
+			continue
+		}
+		if reading, ok := latest[id]; ok {
+			results = append(results, LatestReadingResult{DeviceID: id, Status: "ok", Reading: reading})
+			continue
+		}
+		results = append(results, LatestReadingResult{DeviceID: id, Status: "no_data"})
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status":    1,
+		"data":      results,
+		"truncated": truncated,
+	})
+}
+
+// uniqueStrings 去除重复及空字符串并保持原有顺序
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if value != "" && !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// enrichLatestReadings 按各设备的类型合并派生字段，查询设备类型失败时返回原始读数
+func enrichLatestReadings(db *gorm.DB, readings []models.SensorData) {
+	deviceIDs := make([]string, 0, len(readings))
+	for _, reading := range readings {
+		deviceIDs = append(deviceIDs, reading.DeviceID)
+	}
+	var devices []models.Device
+	if err := db.Select("device_id", "type").Where("device_id IN ?", deviceIDs).Find(&devices).Error; err != nil {
+		return
+	}
+	types := make(map[string]models.DeviceType, len(devices))
+	for _, device := range devices {
+		types[device.DeviceID] = device.Type
+	}
+	for i := range readings {
+		if deviceType, ok := types[readings[i].DeviceID]; ok {
+			readings[i].Data = ingest.Enrich(deviceType, readings[i].Data)
+		}
+	}
+}
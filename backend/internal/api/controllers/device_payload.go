@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+	
+	"github.com/gin-gonic/gin"
+	"github.com/ugorji/go/codec"
+	"iot-platform-backend/internal/config"
+	"iot-platform-backend/internal/models"
+)
+
+// payloadError 上报数据解码失败，Status为应返回的HTTP状态码
+type payloadError struct {
+	Status  int
+	Message string
+}
+
+func (e *payloadError) Error() string {
+	return e.Message
+}
+
+// payloadMapType msgpack/CBOR解码时对象统一解码为字符串键的map，与JSON一致
+var payloadMapType = reflect.TypeOf(map[string]interface{}(nil))
+
+// decodeDevicePayload 按Content-Encoding和Content-Type解码上报数据
+// 支持gzip压缩，以及msgpack、CBOR和JSON格式（其他Content-Type均按JSON解码）；解压后超过maxBytes时拒绝，防止压缩炸弹
+func decodeDevicePayload(c *gin.Context, maxBytes int) (models.JSONB, error) {
+	var reader io.Reader = c.Request.Body
+	switch encoding := strings.ToLower(strings.TrimSpace(c.GetHeader("Content-Encoding"))); encoding {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(c.Request.Body)
+		if err != nil {
+			return nil, &payloadError{Status: http.StatusBadRequest, Message: "Invalid gzip payload"}
+		}
+		defer gz.Close()
+		reader = gz
+	default:
+		return nil, &payloadError{Status: http.StatusUnsupportedMediaType, Message: "Unsupported Content-Encoding: " + encoding}
+	}
+	
+	// 多读1字节用于判断是否超限，而不是读完整个解压流
+	body, err := io.ReadAll(io.LimitReader(reader, int64(maxBytes)+1))
+	if err != nil {
+		return nil, &payloadError{Status: http.StatusBadRequest, Message: "Failed to read payload"}
+	}
+	if len(body) > maxBytes {
+		return nil, &payloadError{Status: http.StatusRequestEntityTooLarge, Message: fmt.Sprintf("Decoded payload exceeds limit of %d bytes", maxBytes)}
+	}
+	
+	var decoded interface{}
+	switch c.ContentType() {
+	case "application/msgpack", "application/x-msgpack", "application/vnd.msgpack":
+		handle := &codec.MsgpackHandle{}
+		handle.MapType = payloadMapType
+		handle.RawToString = true
+		err = codec.NewDecoderBytes(body, handle).Decode(&decoded)
+	case "application/cbor":
+		handle := &codec.CborHandle{}
+		handle.MapType = payloadMapType
+		err = codec.NewDecoderBytes(body, handle).Decode(&decoded)
+	default:
+		// 未声明或未知的类型（如部分固件发送的text/plain）按JSON解码，与旧版本行为一致
+		err = json.Unmarshal(body, &decoded)
+	}
+	if err != nil {
+		return nil, &payloadError{Status: http.StatusBadRequest, Message: "Invalid data format"}
+	}
+	
+	data, ok := normalizePayloadValue(decoded).(map[string]interface{})
+	if !ok {
+		return nil, &payloadError{Status: http.StatusBadRequest, Message: "Invalid data format"}
+	}
+	return models.JSONB(data), nil
+}
+
+// normalizePayloadValue 将msgpack/CBOR解码出的值转换为与JSON解码一致的类型：
+// 数值统一为float64，二进制转为base64字符串，时间转为RFC3339字符串，非字符串键转为字符串
+func normalizePayloadValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			v[key] = normalizePayloadValue(child)
+		}
+		return v
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			converted[fmt.Sprint(key)] = normalizePayloadValue(child)
+		}
+		return converted
+	case []interface{}:
+		for i, child := range v {
+			v[i] = normalizePayloadValue(child)
+		}
+		return v
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	default:
+		return v
+	}
+}
+
+// respondPayloadError 返回上报数据解码错误
+func respondPayloadError(c *gin.Context, err error) {
+	var perr *payloadError
+	if errors.As(err, &perr) {
+		c.JSON(perr.Status, gin.H{
+			"error": perr.Message,
+		})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error": "Invalid data format",
+	})
+}
+
+// checkPayloadLimits 校验传感器数据的序列化大小、键总数和嵌套深度，超出任一限制时返回说明原因的错误
+func checkPayloadLimits(data models.JSONB, limits config.PayloadLimits) error {
+	if limits.MaxBytes > 0 {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return fmt.Errorf("payload is not serializable: %w", err)
+		}
+		if len(raw) > limits.MaxBytes {
+			return fmt.Errorf("payload size %d bytes exceeds limit of %d bytes", len(raw), limits.MaxBytes)
+		}
+	}
+	
+	keys, depth := measurePayload(map[string]interface{}(data), 1)
+	if limits.MaxKeys > 0 && keys > limits.MaxKeys {
+		return fmt.Errorf("payload has %d keys, exceeds limit of %d", keys, limits.MaxKeys)
+	}
+	if limits.MaxDepth > 0 && depth > limits.MaxDepth {
+		return fmt.Errorf("payload nesting depth %d exceeds limit of %d", depth, limits.MaxDepth)
+	}
+	return nil
+}
+
+// measurePayload 递归统计值中对象键的总数和最大嵌套深度
+func measurePayload(value interface{}, depth int) (int, int) {
+	keys, maxDepth := 0, depth
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys = len(v)
+		for _, child := range v {
+			childKeys, childDepth := measurePayload(child, depth+1)
+			keys += childKeys
+			if childDepth > maxDepth {
+				maxDepth = childDepth
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			childKeys, childDepth := measurePayload(child, depth+1)
+			keys += childKeys
+			if childDepth > maxDepth {
+				maxDepth = childDepth
+			}
+		}
+	default:
+		// 标量不增加深度
+		return 0, depth - 1
+	}
+	return keys, maxDepth
+}
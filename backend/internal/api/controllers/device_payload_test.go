@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ugorji/go/codec"
+	"iot-platform-backend/internal/config"
+	"iot-platform-backend/internal/models"
+)
+
+func newPayloadContext(body []byte, contentType, encoding string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/api/v1/devices/d1/data", bytes.NewReader(body))
+	if contentType != "" {
+		c.Request.Header.Set("Content-Type", contentType)
+	}
+	if encoding != "" {
+		c.Request.Header.Set("Content-Encoding", encoding)
+	}
+	return c
+}
+
+func encodeWith(t *testing.T, handle codec.Handle, value interface{}) []byte {
+	t.Helper()
+	var out []byte
+	if err := codec.NewEncoderBytes(&out, handle).Encode(value); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	return out
+}
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(data)
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestDecodeDevicePayload(t *testing.T) {
+	reading := map[string]interface{}{"temperature": 21.5, "count": 3}
+	jsonBody := []byte(`{"temperature":21.5,"count":3}`)
+
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		encoding    string
+		maxBytes    int
+		wantStatus  int
+	}{
+		{name: "json", body: jsonBody, contentType: "application/json"},
+		{name: "json with charset", body: jsonBody, contentType: "application/json; charset=utf-8"},
+		{name: "no content type", body: jsonBody},
+		{name: "unknown content type falls back to json", body: jsonBody, contentType: "text/plain"},
+		{name: "form content type falls back to json", body: jsonBody, contentType: "application/x-www-form-urlencoded"},
+		{name: "msgpack", body: encodeWith(t, &codec.MsgpackHandle{}, reading), contentType: "application/msgpack"},
+		{name: "cbor", body: encodeWith(t, &codec.CborHandle{}, reading), contentType: "application/cbor"},
+		{name: "gzip json", body: gzipBytes(t, jsonBody), encoding: "gzip"},
+		{name: "unsupported encoding", body: jsonBody, encoding: "br", wantStatus: http.StatusUnsupportedMediaType},
+		{name: "invalid gzip", body: jsonBody, encoding: "gzip", wantStatus: http.StatusBadRequest},
+		{name: "invalid json", body: []byte(`{"temperature":`), contentType: "text/plain", wantStatus: http.StatusBadRequest},
+		{name: "not an object", body: []byte(`[1,2]`), wantStatus: http.StatusBadRequest},
+		{name: "decompressed too large", body: gzipBytes(t, []byte(`{"pad":"`+strings.Repeat("x", 4096)+`"}`)), encoding: "gzip", maxBytes: 1024, wantStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxBytes := tt.maxBytes
+			if maxBytes == 0 {
+				maxBytes = 1 << 20
+			}
+			data, err := decodeDevicePayload(newPayloadContext(tt.body, tt.contentType, tt.encoding), maxBytes)
+			if tt.wantStatus != 0 {
+				var perr *payloadError
+				if !errors.As(err, &perr) || perr.Status != tt.wantStatus {
+					t.Fatalf("error = %v, want status %d", err, tt.wantStatus)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeDevicePayload: %v", err)
+			}
+			if data["temperature"] != 21.5 || data["count"] != float64(3) {
+				t.Errorf("data = %#v", data)
+			}
+		})
+	}
+}
+
+func TestCheckPayloadLimits(t *testing.T) {
+	limits := config.PayloadLimits{MaxBytes: 256, MaxKeys: 6, MaxDepth: 3}
+
+	tests := []struct {
+		name    string
+		data    models.JSONB
+		wantErr string
+	}{
+		{"within limits", models.JSONB{"temperature": 21.5, "humidity": 40}, ""},
+		{"oversized", models.JSONB{"blob": strings.Repeat("x", 300)}, "exceeds limit of 256 bytes"},
+		{"too many keys", models.JSONB{"a": 1, "b": 2, "c": 3, "d": 4, "e": map[string]interface{}{"f": 5, "g": 6}}, "7 keys"},
+		{"too deep", models.JSONB{"a": map[string]interface{}{"b": map[string]interface{}{"c": map[string]interface{}{"d": 1}}}}, "nesting depth 4"},
+		{"arrays count towards depth", models.JSONB{"a": []interface{}{[]interface{}{[]interface{}{1}}}}, "nesting depth 4"},
+		{"scalar arrays do not", models.JSONB{"a": []interface{}{1, 2, 3}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkPayloadLimits(tt.data, limits)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("checkPayloadLimits() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("checkPayloadLimits() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPayloadLimitsFor(t *testing.T) {
+	cfg := config.IngestionConfig{
+		PayloadLimits: config.PayloadLimits{MaxBytes: 1024, MaxKeys: 10, MaxDepth: 3},
+		PayloadLimitOverrides: map[int]config.PayloadLimits{
+			int(models.VideoMonitor): {MaxBytes: 65536},
+		},
+	}
+
+	video := cfg.LimitsFor(int(models.VideoMonitor))
+	if video.MaxBytes != 65536 || video.MaxKeys != 10 || video.MaxDepth != 3 {
+		t.Errorf("video limits = %+v, want the byte limit overridden and the rest inherited", video)
+	}
+	if soil := cfg.LimitsFor(int(models.SoilMoisture)); soil != cfg.PayloadLimits {
+		t.Errorf("soil limits = %+v, want the defaults %+v", soil, cfg.PayloadLimits)
+	}
+
+	// 覆盖后的限制放行默认限制会拒绝的数据
+	data := models.JSONB{"frame": strings.Repeat("x", 2048)}
+	if err := checkPayloadLimits(data, cfg.PayloadLimits); err == nil {
+		t.Error("default limits accepted an oversized payload")
+	}
+	if err := checkPayloadLimits(data, video); err != nil {
+		t.Errorf("video limits rejected the payload: %v", err)
+	}
+}
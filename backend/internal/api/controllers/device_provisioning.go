@@ -0,0 +1,194 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+)
+
+// provisioningFormat 配置文件格式标识，导入时用于识别文件类型
+const provisioningFormat = "iot-device-config"
+
+// ProvisioningFile 设备配置文件，用于将配置写入替换的设备
+type ProvisioningFile struct {
+	Format        string            `json:"format" binding:"required"`
+	DeviceID      string            `json:"device_id"`
+	Name          string            `json:"name"`
+	Type          models.DeviceType `json:"type" binding:"required"`
+	TypeName      string            `json:"type_name"`
+	SchemaVersion int               `json:"schema_version" binding:"required,min=1"`
+	Config        models.JSONB      `json:"config" binding:"required"`
+	ExportedAt    time.Time         `json:"exported_at"`
+}
+
+// stripServerOnlyConfig 去掉仅供服务端使用的配置项（以下划线开头的键），返回副本
+func stripServerOnlyConfig(config models.JSONB) models.JSONB {
+	stripped := make(models.JSONB, len(config))
+	for key, value := range config {
+		if strings.HasPrefix(key, "_") {
+			continue
+		}
+		stripped[key] = value
+	}
+	return stripped
+}
+
+// sanitizeFilename 将设备标识转换为可用于下载文件名的字符串，字母、数字和._-以外的字符替换为下划线
+func sanitizeFilename(name string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '.' || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// ExportDeviceConfig 导出设备配置文件
+// @Summary 导出设备配置文件
+// @Description 以JSON文件下载设备当前配置及类型、标识信息，用于写入替换的设备；仅供服务端使用的配置项（以下划线开头的键）不导出
+// @Tags 设备管理
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "设备ID或device_id"
+// @Success 200 {object} ProvisioningFile
+// @Failure 404 {object} map[string]interface{}
+// @Router /devices/{id}/config/export [get]
+func (ctrl *DeviceController) ExportDeviceConfig(c *gin.Context) {
+	db := database.GetDBWithContext(c.Request.Context())
+	var device models.Device
+	// 所有权已由OwnerOrAdminRequired校验
+	if err := db.Scopes(deviceParam(c, false)).First(&device).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Device not found",
+		})
+		return
+	}
+	
+	// 导出的配置总是最新结构版本，导入时无需再升级
+	config, version, _ := models.MigrateConfig(device.Type, device.ConfigSchemaVersion, device.Config)
+	file := ProvisioningFile{
+		Format:        provisioningFormat,
+		DeviceID:      device.DeviceID,
+		Name:          device.Name,
+		Type:          device.Type,
+		TypeName:      device.TypeName,
+		SchemaVersion: version,
+		Config:        stripServerOnlyConfig(config),
+		ExportedAt:    time.Now().UTC(),
+	}
+	
+	filename := fmt.Sprintf("%s-config.json", sanitizeFilename(device.DeviceID))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.IndentedJSON(http.StatusOK, file)
+}
+
+// ImportDeviceConfig 导入设备配置文件
+// @Summary 导入设备配置文件
+// @Description 将导出的配置文件应用到设备，替换当前配置并记录配置变更。文件中的设备类型须与设备一致，
+// @Description 旧结构版本的配置先升级到最新版本再按设备类型校验；文件中的设备标识和名称不会修改设备
+// @Tags 设备管理
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "设备ID或device_id"
+// @Param request body ProvisioningFile true "配置文件"
+// @Success 200 {object} models.Device
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 422 {object} map[string]interface{}
+// @Router /devices/{id}/config/import [post]
+func (ctrl *DeviceController) ImportDeviceConfig(c *gin.Context) {
+	var file ProvisioningFile
+	if !bindJSON(c, &file) {
+		return
+	}
+	if file.Format != provisioningFormat {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Invalid config file",
+			"details": fmt.Sprintf("format must be %q", provisioningFormat),
+		})
+		return
+	}
+	if file.SchemaVersion > models.LatestConfigSchemaVersion {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Invalid config file",
+			"details": fmt.Sprintf("schema_version %d is newer than supported version %d", file.SchemaVersion, models.LatestConfigSchemaVersion),
+		})
+		return
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	var device models.Device
+	// 所有权已由OwnerOrAdminRequired校验
+	if err := db.Scopes(deviceParam(c, false)).First(&device).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Device not found",
+		})
+		return
+	}
+	if file.Type != device.Type {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Device type mismatch",
+			"details": fmt.Sprintf("config file is for device type %d, device is type %d", file.Type, device.Type),
+		})
+		return
+	}
+	
+	config, version, _ := models.MigrateConfig(device.Type, file.SchemaVersion, stripServerOnlyConfig(file.Config))
+	if problems := models.ValidateDeviceConfig(device.Type, config); len(problems) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Invalid device config",
+			"details": problems,
+		})
+		return
+	}
+	// 设备现有的服务端配置项不受导入影响
+	for key, value := range device.Config {
+		if strings.HasPrefix(key, "_") {
+			config[key] = value
+		}
+	}
+	
+	err := database.TransactionWithContext(c.Request.Context(), func(tx *gorm.DB) error {
+		if err := tx.Model(&device).Updates(map[string]interface{}{
+			"config":                config,
+			"config_schema_version": version,
+		}).Error; err != nil {
+			return err
+		}
+		
+		history := models.DeviceConfigHistory{
+			DeviceID:       device.ID,
+			UserID:         middleware.GetUserID(c),
+			Action:         "import_config",
+			PreviousConfig: device.Config,
+			NewConfig:      config,
+		}
+		return tx.Create(&history).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to import device config",
+		})
+		return
+	}
+	device.Config = config
+	device.ConfigSchemaVersion = version
+	
+	cache := database.NewCache()
+	cache.Delete(c, database.Keys.Device(device.DeviceID))
+	cache.Delete(c, database.Keys.DeviceList(device.OwnerID))
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"msg":    "设备配置已导入",
+		"data":   device,
+	})
+}
@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/models"
+)
+
+// errDeviceIDTaken 新设备ID已被占用
+var errDeviceIDTaken = errors.New("device id already exists")
+
+// RekeyDeviceRequest 重新分配设备ID请求
+type RekeyDeviceRequest struct {
+	NewDeviceID string `json:"new_device_id" binding:"required,max=100"`
+}
+
+// RekeyDevice 重新分配设备ID
+// @Summary 重新分配设备ID
+// @Description 为设备分配新的device_id，并在同一事务内将已有传感器数据迁移到新ID
+// @Tags 设备管理
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "设备ID或device_id"
+// @Param request body RekeyDeviceRequest true "新设备ID"
+// @Success 200 {object} models.Device
+// @Failure 409 {object} map[string]interface{}
+// @Router /devices/{id}/rekey [post]
+func (ctrl *DeviceController) RekeyDevice(c *gin.Context) {
+	var req RekeyDeviceRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	newDeviceID := strings.TrimSpace(req.NewDeviceID)
+	if newDeviceID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "new_device_id must not be empty",
+		})
+		return
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	var device models.Device
+	// 所有权已由OwnerOrAdminRequired校验
+	if err := db.Scopes(deviceParam(c, false)).First(&device).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Device not found",
+		})
+		return
+	}
+	
+	oldDeviceID := device.DeviceID
+	if newDeviceID == oldDeviceID {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "new_device_id is the same as the current device_id",
+		})
+		return
+	}
+	
+	var migrated int64
+	err := database.TransactionWithContext(c.Request.Context(), func(tx *gorm.DB) error {
+		// 软删除的设备仍占用device_id的唯一约束，一并检查
+		var taken int64
+		if err := tx.Unscoped().Model(&models.Device{}).Where("device_id = ?", newDeviceID).Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			return errDeviceIDTaken
+		}
+		
+		// sensor_data的外键在迁移时已设为可延迟，事务提交时再统一检查
+		if err := tx.Exec("SET CONSTRAINTS ALL DEFERRED").Error; err != nil {
+			return err
+		}
+		
+		result := tx.Model(&models.Device{}).
+			Where("id = ? AND device_id = ?", device.ID, oldDeviceID).
+			Update("device_id", newDeviceID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		
+		result = tx.Model(&models.SensorData{}).
+			Where("device_id = ?", oldDeviceID).
+			Update("device_id", newDeviceID)
+		if result.Error != nil {
+			return result.Error
+		}
+		migrated = result.RowsAffected
+		return nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errDeviceIDTaken) || database.IsDuplicateKeyError(err):
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "Device ID already exists",
+			})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "Device was modified concurrently, please retry",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to rekey device",
+			})
+		}
+		return
+	}
+	
+	// 事务提交后清除以旧ID为键的缓存
+	cache := database.NewCache()
+	cache.Delete(c,
+		database.Keys.Device(oldDeviceID),
+		database.Keys.DeviceReplay(oldDeviceID),
+		database.Keys.DeviceReplaySeq(oldDeviceID),
+		database.Keys.DeviceList(device.OwnerID),
+	)
+	database.ResetDeviceSummary(c, oldDeviceID)
+	
+	device.DeviceID = newDeviceID
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"msg":    "设备ID已更新",
+		"data": gin.H{
+			"device":           device,
+			"old_device_id":    oldDeviceID,
+			"migrated_records": migrated,
+		},
+	})
+}
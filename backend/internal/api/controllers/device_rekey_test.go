@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/testutil"
+)
+
+func TestRekeyDevice(t *testing.T) {
+	testutil.LoadConfig(t)
+	testutil.UseRedis(t)
+	db := testutil.UseDB(t)
+
+	ctx := context.Background()
+	user := createTestUser(t, "owner")
+	device := createTestDevice(t, models.Device{DeviceID: "old-sensor", OwnerID: user.ID})
+	createTestDevice(t, models.Device{DeviceID: "taken-sensor", OwnerID: user.ID})
+
+	for i := 0; i < 3; i++ {
+		reading := models.SensorData{DeviceID: "old-sensor", Data: models.JSONB{"moisture": 30 + i}, Timestamp: time.Now().Add(time.Duration(i) * time.Minute)}
+		if err := db.Create(&reading).Error; err != nil {
+			t.Fatalf("failed to create reading: %v", err)
+		}
+		database.IncrDeviceDataCount(ctx, "old-sensor")
+		database.CacheLatestReading(ctx, &reading)
+	}
+	cache := database.NewCache()
+	cache.Set(ctx, database.Keys.Device("old-sensor"), device, time.Minute)
+
+	rekey := func(newDeviceID string) (int, map[string]interface{}) {
+		t.Helper()
+		c, w := newJSONContext(t, "POST", "/api/v1/devices/"+strconv.Itoa(int(device.ID))+"/rekey", gin.H{"new_device_id": newDeviceID}, user)
+		c.Params = gin.Params{{Key: "id", Value: strconv.Itoa(int(device.ID))}}
+		NewDeviceController().RekeyDevice(c)
+		return w.Code, decodeBody(t, w)
+	}
+
+	// 新ID已被其他设备占用时拒绝，数据保持不变
+	if status, _ := rekey("taken-sensor"); status != http.StatusConflict {
+		t.Fatalf("status = %d for a taken device_id, want %d", status, http.StatusConflict)
+	}
+	var count int64
+	db.Model(&models.SensorData{}).Where("device_id = ?", "old-sensor").Count(&count)
+	if count != 3 {
+		t.Fatalf("%d reading(s) left under the old id after a rejected rekey, want 3", count)
+	}
+
+	status, body := rekey("new-sensor")
+	if status != http.StatusOK {
+		t.Fatalf("status = %d: %v", status, body)
+	}
+	if data := body["data"].(map[string]interface{}); data["migrated_records"] != float64(3) {
+		t.Errorf("migrated_records = %v, want 3", data["migrated_records"])
+	}
+
+	// 传感器数据随设备迁移到新ID
+	db.Model(&models.SensorData{}).Where("device_id = ?", "old-sensor").Count(&count)
+	if count != 0 {
+		t.Errorf("%d reading(s) left under the old id, want 0", count)
+	}
+	db.Model(&models.SensorData{}).Where("device_id = ?", "new-sensor").Count(&count)
+	if count != 3 {
+		t.Errorf("%d reading(s) under the new id, want 3", count)
+	}
+	var renamed models.Device
+	if err := db.First(&renamed, device.ID).Error; err != nil || renamed.DeviceID != "new-sensor" {
+		t.Errorf("device_id = %q (%v), want new-sensor", renamed.DeviceID, err)
+	}
+
+	// 以旧ID为键的设备、计数和最新读数缓存都已清除
+	for _, key := range []string{
+		database.Keys.Device("old-sensor"),
+		database.Keys.DeviceDataCount("old-sensor"),
+		database.Keys.DeviceLatest("old-sensor"),
+	} {
+		if exists, _ := cache.Exists(ctx, key); exists {
+			t.Errorf("cache key %s still exists after rekey", key)
+		}
+	}
+	if count, err := database.GetDeviceDataCount(ctx, "new-sensor"); err != nil || count != 3 {
+		t.Errorf("data count for the new id = %d (%v), want 3", count, err)
+	}
+}
@@ -0,0 +1,298 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+	
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/ingest"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+)
+
+const (
+	defaultSimulationInterval = 5 * time.Second
+	defaultSimulationDuration = time.Minute
+	minSimulationInterval     = time.Second
+	maxSimulationDuration     = time.Hour
+	
+	// simulationDrift 每步随机游走的幅度，占字段取值区间的比例
+	simulationDrift = 0.02
+)
+
+// SimulateDeviceRequest 模拟设备数据请求
+type SimulateDeviceRequest struct {
+	Interval string `json:"interval"` // 上报间隔，如5s，默认5s，最小1s
+	Duration string `json:"duration"` // 持续时间，如10m，默认1m，最长1h
+}
+
+// simulations 运行中的模拟任务，按设备ID索引，仅在当前实例内有效
+var simulations = struct {
+	sync.Mutex
+	running map[string]context.CancelFunc
+}{running: make(map[string]context.CancelFunc)}
+
+// SimulateDeviceData 为设备生成模拟数据（仅开发环境）
+// @Summary 模拟设备数据
+// @Description 按设备类型的字段定义在取值范围内随机游走生成读数，按间隔持续上报指定时长。读数经由真实的写入流程保存并通过WebSocket推送，计入数据配额。仅在非release模式下注册，设备拥有者或管理员可用
+// @Tags 设备数据
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "设备ID或device_id"
+// @Param request body SimulateDeviceRequest false "模拟参数"
+// @Success 202 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{} "该设备已有模拟任务在运行"
+// @Router /devices/{id}/simulate [post]
+func (ctrl *DeviceController) SimulateDeviceData(c *gin.Context) {
+	device, ok := loadSimulationDevice(c)
+	if !ok {
+		return
+	}
+	
+	var req SimulateDeviceRequest
+	if c.Request.ContentLength > 0 && !bindJSON(c, &req) {
+		return
+	}
+	
+	interval, err := parseSimulationDuration(req.Interval, defaultSimulationInterval)
+	if err != nil || interval < minSimulationInterval {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid interval",
+			"details": "interval must be a duration of at least 1s",
+		})
+		return
+	}
+	duration, err := parseSimulationDuration(req.Duration, defaultSimulationDuration)
+	if err != nil || duration < interval || duration > maxSimulationDuration {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid duration",
+			"details": "duration must be between interval and 1h",
+		})
+		return
+	}
+	
+	simulator := newReadingSimulator(device.Type, rand.New(rand.NewSource(time.Now().UnixNano())))
+	if len(simulator.fields) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Device type has no field definitions to simulate",
+		})
+		return
+	}
+	
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	simulations.Lock()
+	if _, running := simulations.running[device.DeviceID]; running {
+		simulations.Unlock()
+		cancel()
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Simulation already running for this device",
+		})
+		return
+	}
+	simulations.running[device.DeviceID] = cancel
+	simulations.Unlock()
+	
+	go runSimulation(ctx, device.DeviceID, interval, simulator)
+	
+	c.JSON(http.StatusAccepted, gin.H{
+		"status": 1,
+		"msg":    "模拟任务已启动",
+		"data": gin.H{
+			"device_id": device.DeviceID,
+			"interval":  interval.String(),
+			"duration":  duration.String(),
+			"readings":  int(duration/interval) + 1,
+			"fields":    simulator.fieldKeys(),
+		},
+	})
+}
+
+// StopDeviceSimulation 停止设备的模拟任务（仅开发环境）
+// @Summary 停止模拟设备数据
+// @Tags 设备数据
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "设备ID或device_id"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /devices/{id}/simulate [delete]
+func (ctrl *DeviceController) StopDeviceSimulation(c *gin.Context) {
+	device, ok := loadSimulationDevice(c)
+	if !ok {
+		return
+	}
+	
+	simulations.Lock()
+	cancel, running := simulations.running[device.DeviceID]
+	simulations.Unlock()
+	if !running {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "No simulation running for this device",
+		})
+		return
+	}
+	cancel()
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"msg":    "模拟任务已停止",
+	})
+}
+
+// loadSimulationDevice 按路径参数device_id加载设备，只允许拥有者或管理员，失败时已写入响应
+func loadSimulationDevice(c *gin.Context) (*models.Device, bool) {
+	var device models.Device
+	err := database.GetDBWithContext(c.Request.Context()).
+		Scopes(deviceParam(c, true)).
+		First(&device).Error
+	if err != nil || (device.OwnerID != middleware.GetUserID(c) && !middleware.IsAdminOf(c, device.OwnerID)) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Device not found",
+		})
+		return nil, false
+	}
+	return &device, true
+}
+
+// parseSimulationDuration 解析时长参数，为空时返回默认值
+func parseSimulationDuration(value string, fallback time.Duration) (time.Duration, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// runSimulation 按间隔生成读数并写入，直到ctx结束、设备被删除、达到数据配额或写入管道停止
+func runSimulation(ctx context.Context, deviceID string, interval time.Duration, simulator *readingSimulator) {
+	defer func() {
+		simulations.Lock()
+		if cancel, ok := simulations.running[deviceID]; ok {
+			cancel()
+			delete(simulations.running, deviceID)
+		}
+		simulations.Unlock()
+	}()
+	
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	
+	for {
+		if !simulateReading(ctx, deviceID, simulator) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// simulateReading 生成并写入一条读数，返回是否继续模拟
+func simulateReading(ctx context.Context, deviceID string, simulator *readingSimulator) bool {
+	db := database.GetDB().WithContext(ctx)
+	
+	// 每次重新加载设备，设备被删除后停止，状态和拥有者以数据库为准
+	var device models.Device
+	if err := db.Where("device_id = ?", deviceID).First(&device).Error; err != nil {
+		if !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Device simulation for %s stopped: %v", deviceID, err)
+		}
+		return false
+	}
+	if _, exceeded := sensorRowQuotaExceeded(ctx, db, device.OwnerID); exceeded {
+		log.Printf("Device simulation for %s stopped: sensor data quota reached", deviceID)
+		return false
+	}
+	
+	if _, err := ingestReading(ctx, db, &device, time.Time{}, simulator.next()); err != nil {
+		if errors.Is(err, ingest.ErrStopped) {
+			return false
+		}
+		// 队列满等临时错误跳过本次读数
+		log.Printf("Device simulation for %s skipped a reading: %v", deviceID, err)
+	}
+	return true
+}
+
+// readingSimulator 按设备类型字段定义生成连续、合理的模拟读数
+// 数值字段从区间中部开始随机游走并限制在[min, max]内，枚举字段大多保持不变偶尔切换
+type readingSimulator struct {
+	fields []models.FieldSpec
+	values map[string]float64
+	enums  map[string]string
+	rng    *rand.Rand
+}
+
+// newReadingSimulator 创建模拟器
+func newReadingSimulator(deviceType models.DeviceType, rng *rand.Rand) *readingSimulator {
+	s := &readingSimulator{
+		fields: models.DeviceTypeFields[deviceType],
+		values: make(map[string]float64),
+		enums:  make(map[string]string),
+		rng:    rng,
+	}
+	for _, field := range s.fields {
+		switch {
+		case field.Type == "enum" && len(field.Values) > 0:
+			s.enums[field.Key] = field.Values[0]
+		case field.Min != nil && field.Max != nil:
+			span := *field.Max - *field.Min
+			s.values[field.Key] = *field.Min + span*(0.3+0.4*rng.Float64())
+		}
+	}
+	return s
+}
+
+// fieldKeys 模拟的字段名
+func (s *readingSimulator) fieldKeys() []string {
+	keys := make([]string, 0, len(s.fields))
+	for _, field := range s.fields {
+		keys = append(keys, field.Key)
+	}
+	return keys
+}
+
+// next 生成下一条读数
+func (s *readingSimulator) next() models.JSONB {
+	data := make(models.JSONB, len(s.fields))
+	for _, field := range s.fields {
+		if field.Type == "enum" {
+			if len(field.Values) == 0 {
+				continue
+			}
+			if s.rng.Float64() < 0.1 {
+				s.enums[field.Key] = field.Values[s.rng.Intn(len(field.Values))]
+			}
+			data[field.Key] = s.enums[field.Key]
+			continue
+		}
+		
+		value, ok := s.values[field.Key]
+		if !ok {
+			continue
+		}
+		min, max := *field.Min, *field.Max
+		value += (max - min) * simulationDrift * s.rng.NormFloat64()
+		value = math.Max(min, math.Min(max, value))
+		s.values[field.Key] = value
+		
+		if field.Integer {
+			data[field.Key] = math.Round(value)
+		} else {
+			data[field.Key] = math.Round(value*100) / 100
+		}
+	}
+	return data
+}
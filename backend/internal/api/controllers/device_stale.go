@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+	
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+)
+
+// defaultStaleThreshold 未指定threshold时判定为失联的时长
+const defaultStaleThreshold = 24 * time.Hour
+
+// StaleDevicesResponse 失联设备列表响应
+type StaleDevicesResponse struct {
+	Threshold  string          `json:"threshold"`
+	Cutoff     time.Time       `json:"cutoff"`
+	Stale      []models.Device `json:"stale"`       // 本页中最后通信时间早于cutoff的设备
+	NeverSeen  []models.Device `json:"never_seen"`  // 本页中从未上报过数据的设备
+	StaleCount int64           `json:"stale_count"` // 全部失联设备数（不含从未上报）
+	NeverCount int64           `json:"never_seen_count"`
+	Total      int64           `json:"total"`
+	Page       int             `json:"page"`
+	Limit      int             `json:"limit"`
+}
+
+// GetStaleDevices 获取长时间未上报的设备
+// @Summary 获取失联设备
+// @Description 返回最后通信时间早于阈值的设备，从未上报的设备排在最前并单独列出，便于安排维护
+// @Tags 设备管理
+// @Security BearerAuth
+// @Produce json
+// @Param threshold query string false "失联阈值，如24h或7d" default(24h)
+// @Param page query int false "页码" default(1)
+// @Param limit query int false "每页数量" default(10)
+// @Success 200 {object} StaleDevicesResponse
+// @Failure 400 {object} map[string]interface{}
+// @Router /devices/stale [get]
+func (ctrl *DeviceController) GetStaleDevices(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+		})
+		return
+	}
+	
+	threshold := defaultStaleThreshold
+	if value := c.Query("threshold"); value != "" {
+		parsed, err := parseDayDuration(value)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid threshold",
+			})
+			return
+		}
+		threshold = parsed
+	}
+	cutoff := time.Now().Add(-threshold)
+	pagination := parseListPagination(c)
+	
+	query := database.GetDBWithContext(c.Request.Context()).Model(&models.Device{}).
+		Where("owner_id = ? AND (last_seen IS NULL OR last_seen < ?)", userID, cutoff)
+	
+	var counts struct {
+		Stale     int64
+		NeverSeen int64
+	}
+	if err := query.Session(&gorm.Session{}).
+		Select("COUNT(last_seen) AS stale, COUNT(*) - COUNT(last_seen) AS never_seen").
+		Scan(&counts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to count devices",
+		})
+		return
+	}
+	
+	var devices []models.Device
+	if err := query.Order("last_seen ASC NULLS FIRST").Order("id ASC").
+		Offset(pagination.Offset).Limit(pagination.Limit).
+		Find(&devices).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch devices",
+		})
+		return
+	}
+	
+	response := StaleDevicesResponse{
+		Threshold:  threshold.String(),
+		Cutoff:     cutoff,
+		Stale:      make([]models.Device, 0),
+		NeverSeen:  make([]models.Device, 0),
+		StaleCount: counts.Stale,
+		NeverCount: counts.NeverSeen,
+		Total:      counts.Stale + counts.NeverSeen,
+		Page:       pagination.Page,
+		Limit:      pagination.Limit,
+	}
+	for _, device := range devices {
+		if !device.IsOnline() {
+			device.Status = "offline"
+		}
+		if device.LastSeen == nil {
+			response.NeverSeen = append(response.NeverSeen, device)
+		} else {
+			response.Stale = append(response.Stale, device)
+		}
+	}
+	
+	setPaginationHeaders(c, response.Total, pagination.Page, pagination.Limit)
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"data":   response,
+	})
+}
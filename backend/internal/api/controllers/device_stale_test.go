@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/testutil"
+)
+
+func TestGetStaleDevicesPaginates(t *testing.T) {
+	testutil.LoadConfig(t)
+	testutil.UseDB(t)
+
+	user := createTestUser(t, "owner")
+	for i := 0; i < 3; i++ {
+		lastSeen := time.Now().Add(-time.Duration(48+i) * time.Hour)
+		createTestDevice(t, models.Device{DeviceID: fmt.Sprintf("stale-%d", i), OwnerID: user.ID, LastSeen: &lastSeen})
+	}
+	createTestDevice(t, models.Device{DeviceID: "never", OwnerID: user.ID})
+	fresh := time.Now()
+	createTestDevice(t, models.Device{DeviceID: "fresh", OwnerID: user.ID, LastSeen: &fresh})
+
+	tests := []struct {
+		page        int
+		wantNever   []string
+		wantStale   []string
+		wantLinkRel string
+	}{
+		// 从未上报的设备排在最前，其余按最后通信时间从早到晚
+		{1, []string{"never"}, []string{"stale-2"}, `rel="next"`},
+		{2, nil, []string{"stale-1", "stale-0"}, `rel="prev"`},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("page %d", tt.page), func(t *testing.T) {
+			c, w := newJSONContext(t, "GET", fmt.Sprintf("/api/v1/devices/stale?threshold=24h&limit=2&page=%d", tt.page), nil, user)
+			NewDeviceController().GetStaleDevices(c)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+
+			var body struct {
+				Data StaleDevicesResponse `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if body.Data.Total != 4 || body.Data.StaleCount != 3 || body.Data.NeverCount != 1 {
+				t.Errorf("counts = total:%d stale:%d never:%d, want 4/3/1", body.Data.Total, body.Data.StaleCount, body.Data.NeverCount)
+			}
+			if got := deviceIDs(body.Data.NeverSeen); fmt.Sprint(got) != fmt.Sprint(tt.wantNever) {
+				t.Errorf("never_seen = %v, want %v", got, tt.wantNever)
+			}
+			if got := deviceIDs(body.Data.Stale); fmt.Sprint(got) != fmt.Sprint(tt.wantStale) {
+				t.Errorf("stale = %v, want %v", got, tt.wantStale)
+			}
+			if w.Header().Get("X-Total-Count") != "4" {
+				t.Errorf("X-Total-Count = %q", w.Header().Get("X-Total-Count"))
+			}
+			if link := w.Header().Get("Link"); !strings.Contains(link, tt.wantLinkRel) {
+				t.Errorf("Link = %q, want %s", link, tt.wantLinkRel)
+			}
+		})
+	}
+}
+
+func TestGetStaleDevicesRejectsInvalidThreshold(t *testing.T) {
+	testutil.LoadConfig(t)
+	for _, threshold := range []string{"soon", "-1h", "0s"} {
+		c, w := newJSONContext(t, "GET", "/api/v1/devices/stale?threshold="+threshold, nil, &models.User{ID: 1})
+		NewDeviceController().GetStaleDevices(c)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("threshold %q: status = %d, want 400", threshold, w.Code)
+		}
+	}
+}
+
+// deviceIDs 提取设备标识列表
+func deviceIDs(devices []models.Device) []string {
+	ids := make([]string, 0, len(devices))
+	for _, device := range devices {
+		ids = append(ids, device.DeviceID)
+	}
+	return ids
+}
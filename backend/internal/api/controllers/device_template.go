@@ -0,0 +1,299 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+)
+
+// CreateDeviceTemplateRequest 创建设备配置模板请求
+type CreateDeviceTemplateRequest struct {
+	Name        string             `json:"name" binding:"required,min=1,max=100"`
+	Description string             `json:"description" binding:"max=500"`
+	DeviceType  *models.DeviceType `json:"device_type" binding:"omitempty,device_type"`
+	Config      models.JSONB       `json:"config" binding:"required"`
+}
+
+// ApplyDeviceTemplateRequest 应用配置模板请求
+type ApplyDeviceTemplateRequest struct {
+	TemplateID uint   `json:"template_id" binding:"required"`
+	IDs        []uint `json:"ids" binding:"required,min=1,max=100"`
+	Mode       string `json:"mode" binding:"omitempty,oneof=merge replace"` // merge（默认，深度合并）或replace（整体替换）
+	Preview    bool   `json:"preview"`                                      // 仅预览结果配置，不写入
+}
+
+// ApplyDeviceTemplateResponse 应用配置模板响应
+type ApplyDeviceTemplateResponse struct {
+	BulkResponse
+	Preview bool                  `json:"preview"`
+	Configs map[uint]models.JSONB `json:"configs"` // 每个设备应用后的配置
+}
+
+// GetDeviceTemplates 获取设备配置模板列表
+// @Summary 获取设备配置模板
+// @Description 获取当前用户的设备配置模板
+// @Tags 设备管理
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.DeviceConfigTemplate
+// @Router /devices/templates [get]
+func (ctrl *DeviceController) GetDeviceTemplates(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+		})
+		return
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	query := db.Model(&models.DeviceConfigTemplate{})
+	if !middleware.IsAdmin(c) {
+		query = query.Where("owner_id = ?", userID)
+	}
+	if deviceType := c.Query("type"); deviceType != "" {
+		query = query.Where("device_type = ? OR device_type IS NULL", deviceType)
+	}
+	
+	var templates []models.DeviceConfigTemplate
+	if err := query.Order("use_count DESC, created_at DESC").Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch templates",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"data":   templates,
+	})
+}
+
+// CreateDeviceTemplate 创建设备配置模板
+// @Summary 创建设备配置模板
+// @Description 保存一份可复用的设备配置预设
+// @Tags 设备管理
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body CreateDeviceTemplateRequest true "模板信息"
+// @Success 201 {object} models.DeviceConfigTemplate
+// @Failure 400 {object} map[string]interface{}
+// @Router /devices/templates [post]
+func (ctrl *DeviceController) CreateDeviceTemplate(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+		})
+		return
+	}
+	
+	var req CreateDeviceTemplateRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	
+	template := models.DeviceConfigTemplate{
+		Name:        req.Name,
+		Description: req.Description,
+		DeviceType:  req.DeviceType,
+		Config:      req.Config,
+		OwnerID:     userID,
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	if err := db.Create(&template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create template",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusCreated, gin.H{
+		"status": 1,
+		"msg":    "模板创建成功",
+		"data":   template,
+	})
+}
+
+// DeleteDeviceTemplate 删除设备配置模板
+// @Summary 删除设备配置模板
+// @Tags 设备管理
+// @Security BearerAuth
+// @Produce json
+// @Param template_id path int true "模板ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /devices/templates/{template_id} [delete]
+func (ctrl *DeviceController) DeleteDeviceTemplate(c *gin.Context) {
+	template, ok := loadDeviceTemplate(c, c.Param("template_id"))
+	if !ok {
+		return
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	if err := db.Delete(&template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete template",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"msg":    "模板删除成功",
+	})
+}
+
+// ApplyDeviceTemplate 将配置模板应用到多个设备
+// @Summary 应用设备配置模板
+// @Description 将模板配置深度合并（或替换）到多个设备，逐个校验所有权并记录变更；preview为true时仅返回结果配置
+// @Tags 设备管理
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body ApplyDeviceTemplateRequest true "应用参数"
+// @Success 200 {object} ApplyDeviceTemplateResponse
+// @Failure 400 {object} map[string]interface{}
+// @Router /devices/apply-template [post]
+func (ctrl *DeviceController) ApplyDeviceTemplate(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+		})
+		return
+	}
+	
+	var req ApplyDeviceTemplateRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	
+	template, ok := loadDeviceTemplate(c, strconv.FormatUint(uint64(req.TemplateID), 10))
+	if !ok {
+		return
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	ids := uniqueIDs(req.IDs)
+	owned, skipped, err := loadOwnedDevices(db, ids, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch devices",
+		})
+		return
+	}
+	
+	// 模板限定了设备类型时，跳过类型不匹配的设备
+	applicable := make([]models.Device, 0, len(owned))
+	for _, device := range owned {
+		if template.DeviceType != nil && device.Type != *template.DeviceType {
+			skipped[device.ID] = "type_mismatch"
+			continue
+		}
+		applicable = append(applicable, device)
+	}
+	
+	configs := make(map[uint]models.JSONB, len(applicable))
+	previous := make(map[uint]models.JSONB, len(applicable))
+	for i := range applicable {
+		previous[applicable[i].ID] = applicable[i].Config
+		if req.Mode == "replace" {
+			applicable[i].Config = models.MergeConfig(nil, template.Config)
+		} else {
+			applicable[i].Config = models.MergeConfig(applicable[i].Config, template.Config)
+		}
+		configs[applicable[i].ID] = applicable[i].Config
+	}
+	
+	if !req.Preview && len(applicable) > 0 {
+		err = database.TransactionWithContext(c.Request.Context(), func(tx *gorm.DB) error {
+			for i := range applicable {
+				if err := tx.Model(&applicable[i]).Update("config", applicable[i].Config).Error; err != nil {
+					return err
+				}
+				
+				history := models.DeviceConfigHistory{
+					DeviceID:       applicable[i].ID,
+					UserID:         userID,
+					TemplateID:     &template.ID,
+					Action:         "apply_template",
+					PreviousConfig: previous[applicable[i].ID],
+					NewConfig:      applicable[i].Config,
+				}
+				if err := tx.Create(&history).Error; err != nil {
+					return err
+				}
+			}
+			
+			return tx.Model(&template).Update("use_count", gorm.Expr("use_count + ?", 1)).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to apply template",
+			})
+			return
+		}
+		
+		invalidateDeviceCaches(c, userID, applicable)
+	}
+	
+	msg := "模板应用完成"
+	if req.Preview {
+		msg = "模板应用预览"
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"msg":    msg,
+		"data": ApplyDeviceTemplateResponse{
+			BulkResponse: buildBulkResponse(ids, applicable, skipped, "applied"),
+			Preview:      req.Preview,
+			Configs:      configs,
+		},
+	})
+}
+
+// loadDeviceTemplate 按ID加载模板并校验所有权，失败时直接写入响应
+func loadDeviceTemplate(c *gin.Context, rawID string) (models.DeviceConfigTemplate, bool) {
+	var template models.DeviceConfigTemplate
+	
+	templateID, err := strconv.ParseUint(rawID, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid template ID",
+		})
+		return template, false
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	if err := db.First(&template, uint(templateID)).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Template not found",
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to fetch template",
+			})
+		}
+		return template, false
+	}
+	
+	if template.OwnerID != middleware.GetUserID(c) && !middleware.IsAdminOf(c, template.OwnerID) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Access denied",
+		})
+		return template, false
+	}
+	
+	return template, true
+}
@@ -0,0 +1,456 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"iot-platform-backend/internal/config"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/testutil"
+)
+
+func TestGetDevicesLastSeenFilter(t *testing.T) {
+	testutil.LoadConfig(t)
+	testutil.UseDB(t)
+
+	user := createTestUser(t, "owner")
+	recent := time.Now().Add(-time.Hour)
+	old := time.Now().Add(-72 * time.Hour)
+	createTestDevice(t, models.Device{DeviceID: "recent", OwnerID: user.ID, LastSeen: &recent})
+	createTestDevice(t, models.Device{DeviceID: "old", OwnerID: user.ID, LastSeen: &old})
+	createTestDevice(t, models.Device{DeviceID: "never", OwnerID: user.ID})
+
+	cutoff := time.Now().Add(-24 * time.Hour).UTC().Format(time.RFC3339)
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantTotal  float64
+	}{
+		{"no filter", "", http.StatusOK, 3},
+		{"before", "?last_seen_before=" + cutoff, http.StatusOK, 1},
+		{"after", "?last_seen_after=" + cutoff, http.StatusOK, 1},
+		{"invalid before", "?last_seen_before=yesterday", http.StatusBadRequest, 0},
+		{"invalid after", "?last_seen_after=2024-01-01", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := newJSONContext(t, "GET", "/api/v1/devices"+tt.query, nil, user)
+			NewDeviceController().GetDevices(c)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			data := decodeBody(t, w)["data"].(map[string]interface{})
+			if data["total"] != tt.wantTotal {
+				t.Errorf("total = %v, want %v", data["total"], tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestPatchDevice(t *testing.T) {
+	testutil.LoadConfig(t)
+	testutil.UseRedis(t)
+	db := testutil.UseDB(t)
+	user := createTestUser(t, "owner")
+
+	until := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		check      func(t *testing.T, device models.Device)
+	}{
+		{
+			name: "absent fields are unchanged",
+			body: `{}`,
+			check: func(t *testing.T, device models.Device) {
+				if device.Name != "sensor" || device.FirmwareVersion != "1.0" || len(device.Tags) != 1 || !device.DedupEnabled || device.Location == nil {
+					t.Errorf("device changed: %+v", device)
+				}
+			},
+		},
+		{
+			name: "null clears fields",
+			body: `{"location":null,"tags":null,"firmware_version":null,"dedup_enabled":null,"config":null}`,
+			check: func(t *testing.T, device models.Device) {
+				if device.Location != nil || len(device.Tags) != 0 || device.FirmwareVersion != "" || device.DedupEnabled || len(device.Config) != 0 {
+					t.Errorf("fields not cleared: %+v", device)
+				}
+				if device.Name != "sensor" {
+					t.Errorf("name changed to %q", device.Name)
+				}
+			},
+		},
+		{
+			name: "empty values clear fields",
+			body: `{"location":{},"tags":[],"firmware_version":""}`,
+			check: func(t *testing.T, device models.Device) {
+				if device.Location != nil || len(device.Tags) != 0 || device.FirmwareVersion != "" {
+					t.Errorf("fields not cleared: %+v", device)
+				}
+			},
+		},
+		{
+			name: "null maintenance_until keeps maintenance mode",
+			body: `{"maintenance_until":null}`,
+			check: func(t *testing.T, device models.Device) {
+				if !device.Maintenance || device.MaintenanceUntil != nil {
+					t.Errorf("maintenance = %v until %v, want manual maintenance", device.Maintenance, device.MaintenanceUntil)
+				}
+			},
+		},
+		{
+			name: "null maintenance ends maintenance",
+			body: `{"maintenance":null}`,
+			check: func(t *testing.T, device models.Device) {
+				if device.Maintenance || device.MaintenanceUntil != nil {
+					t.Errorf("maintenance = %v until %v, want off", device.Maintenance, device.MaintenanceUntil)
+				}
+			},
+		},
+		{name: "null name is rejected", body: `{"name":null}`, wantStatus: http.StatusBadRequest},
+		{name: "blank name is rejected", body: `{"name":"  "}`, wantStatus: http.StatusBadRequest},
+		{name: "long name is rejected", body: `{"name":"` + strings.Repeat("x", 101) + `"}`, wantStatus: http.StatusBadRequest},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			device := createTestDevice(t, models.Device{
+				DeviceID:         fmt.Sprintf("patch-%d", i),
+				Name:             "sensor",
+				OwnerID:          user.ID,
+				Location:         models.JSONB{"lat": 30.0, "lng": 120.0},
+				Config:           models.JSONB{"interval": 60},
+				Tags:             pq.StringArray{"field-a"},
+				FirmwareVersion:  "1.0",
+				DedupEnabled:     true,
+				Maintenance:      true,
+				MaintenanceUntil: &until,
+			})
+
+			c, w := newJSONContext(t, "PATCH", fmt.Sprintf("/api/v1/devices/%d", device.ID), json.RawMessage(tt.body), user)
+			c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(device.ID)}}
+			NewDeviceController().PatchDevice(c)
+
+			wantStatus := tt.wantStatus
+			if wantStatus == 0 {
+				wantStatus = http.StatusOK
+			}
+			if w.Code != wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, wantStatus, w.Body.String())
+			}
+			if tt.check == nil {
+				return
+			}
+			var stored models.Device
+			if err := db.First(&stored, device.ID).Error; err != nil {
+				t.Fatalf("reload: %v", err)
+			}
+			tt.check(t, stored)
+		})
+	}
+}
+
+func TestDeviceParamResolvesIDOrDeviceID(t *testing.T) {
+	testutil.LoadConfig(t)
+	db := testutil.UseDB(t)
+	user := createTestUser(t, "owner")
+
+	named := createTestDevice(t, models.Device{DeviceID: "sensor-a", OwnerID: user.ID})
+	// device_id与另一台设备的主键相同
+	numeric := createTestDevice(t, models.Device{DeviceID: fmt.Sprint(named.ID), OwnerID: user.ID})
+
+	tests := []struct {
+		ref            string
+		preferDeviceID bool
+		want           uint
+	}{
+		{"sensor-a", false, named.ID},
+		{"sensor-a", true, named.ID},
+		{fmt.Sprint(named.ID), false, named.ID},
+		{fmt.Sprint(named.ID), true, numeric.ID},
+		{fmt.Sprint(numeric.ID), true, numeric.ID},
+	}
+	for _, tt := range tests {
+		c, _ := newTestContext("GET", "/api/v1/devices/"+tt.ref)
+		c.Params = gin.Params{{Key: "id", Value: tt.ref}}
+		var device models.Device
+		if err := db.Scopes(deviceParam(c, tt.preferDeviceID)).First(&device).Error; err != nil {
+			t.Errorf("ref %q (prefer device_id: %v): %v", tt.ref, tt.preferDeviceID, err)
+			continue
+		}
+		if device.ID != tt.want {
+			t.Errorf("ref %q (prefer device_id: %v) = device %d, want %d", tt.ref, tt.preferDeviceID, device.ID, tt.want)
+		}
+	}
+
+	// 无需认证的上报路由只按device_id匹配，不能通过主键定位设备
+	for ref, want := range map[string]uint{"sensor-a": named.ID, fmt.Sprint(named.ID): numeric.ID} {
+		c, _ := newTestContext("POST", "/api/v1/devices/"+ref+"/data")
+		c.Params = gin.Params{{Key: "id", Value: ref}}
+		var device models.Device
+		if err := db.Scopes(deviceIDParam(c)).First(&device).Error; err != nil || device.ID != want {
+			t.Errorf("deviceIDParam(%q) = device %d (%v), want %d", ref, device.ID, err, want)
+		}
+	}
+	c, _ := newTestContext("POST", "/api/v1/devices/"+fmt.Sprint(numeric.ID)+"/data")
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(numeric.ID)}}
+	if err := db.Scopes(deviceIDParam(c)).First(&models.Device{}).Error; err == nil {
+		t.Errorf("deviceIDParam(%d) matched a device by primary key", numeric.ID)
+	}
+
+	c, _ = newTestContext("GET", "/api/v1/devices/missing")
+	c.Params = gin.Params{{Key: "id", Value: "missing"}}
+	if _, err := DeviceOwner(c); err != middleware.ErrResourceNotFound {
+		t.Errorf("DeviceOwner() for an unknown device = %v, want ErrResourceNotFound", err)
+	}
+}
+
+func TestPostDeviceDataRecordsFirmwareOnlyWhenAccepted(t *testing.T) {
+	testutil.LoadConfig(t)
+	testutil.UseRedis(t)
+	db := testutil.UseDB(t)
+	user := createTestUser(t, "owner")
+	device := createTestDevice(t, models.Device{DeviceID: "sensor-fw", OwnerID: user.ID, FirmwareVersion: "1.0"})
+
+	post := func(body map[string]interface{}) int {
+		t.Helper()
+		c, w := newJSONContext(t, "POST", "/api/v1/devices/sensor-fw/data", body, nil)
+		c.Params = gin.Params{{Key: "id", Value: "sensor-fw"}}
+		NewDeviceController().PostDeviceData(c)
+		return w.Code
+	}
+	firmware := func() string {
+		t.Helper()
+		var current models.Device
+		if err := db.First(&current, device.ID).Error; err != nil {
+			t.Fatalf("failed to reload device: %v", err)
+		}
+		return current.FirmwareVersion
+	}
+
+	// _timestamp无效的读数被拒绝，固件版本不变
+	if status := post(map[string]interface{}{"moisture": 30, "_firmware_version": "2.0", "_timestamp": "yesterday"}); status != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", status, http.StatusBadRequest)
+	}
+	if got := firmware(); got != "1.0" {
+		t.Errorf("firmware after a rejected reading = %q, want 1.0", got)
+	}
+
+	if status := post(map[string]interface{}{"moisture": 30, "_firmware_version": "2.0"}); status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", status, http.StatusOK)
+	}
+	if got := firmware(); got != "2.0" {
+		t.Errorf("firmware after an accepted reading = %q, want 2.0", got)
+	}
+}
+
+func TestIngestReadingLastSeenWrites(t *testing.T) {
+	cfg := testutil.LoadConfig(t)
+	cfg.Ingestion.LastSeenInterval = time.Minute
+	testutil.UseRedis(t)
+	db := testutil.UseDB(t)
+	user := createTestUser(t, "owner")
+	device := createTestDevice(t, models.Device{DeviceID: "sensor-ls", OwnerID: user.ID, Status: "offline"})
+
+	reload := func() models.Device {
+		t.Helper()
+		var current models.Device
+		if err := db.First(&current, device.ID).Error; err != nil {
+			t.Fatalf("failed to reload device: %v", err)
+		}
+		return current
+	}
+	ingest := func(stale models.Device) {
+		t.Helper()
+		if _, err := ingestReading(context.Background(), db, &stale, time.Now(), models.JSONB{"moisture": 30}); err != nil {
+			t.Fatalf("ingestReading() = %v", err)
+		}
+	}
+
+	// 离线设备的第一条读数立即置为在线
+	ingest(device)
+	first := reload()
+	if first.Status != "online" || first.LastSeen == nil {
+		t.Fatalf("after the first reading status = %q, last_seen = %v, want online with a last_seen", first.Status, first.LastSeen)
+	}
+
+	// 读数期间修改的配置不被上报时持有的旧设备对象覆盖
+	if err := db.Model(&models.Device{}).Where("id = ?", device.ID).Update("name", "renamed").Error; err != nil {
+		t.Fatalf("failed to edit device: %v", err)
+	}
+
+	// 节流周期内的第二条读数不再写last_seen
+	marker := first.LastSeen.Add(-time.Hour).UTC().Truncate(time.Second)
+	if err := db.Model(&models.Device{}).Where("id = ?", device.ID).UpdateColumn("last_seen", marker).Error; err != nil {
+		t.Fatalf("failed to mark last_seen: %v", err)
+	}
+	ingest(first)
+	second := reload()
+	if second.LastSeen == nil || !second.LastSeen.Equal(marker) {
+		t.Errorf("last_seen = %v after a throttled reading, want %v unchanged", second.LastSeen, marker)
+	}
+	if second.Name != "renamed" {
+		t.Errorf("name = %q, want the concurrent edit to survive ingestion", second.Name)
+	}
+
+	// 节流周期内离线的设备仍立即恢复在线
+	if err := db.Model(&models.Device{}).Where("id = ?", device.ID).UpdateColumn("status", "offline").Error; err != nil {
+		t.Fatalf("failed to mark device offline: %v", err)
+	}
+	ingest(first)
+	if third := reload(); third.Status != "online" || third.LastSeen.Equal(marker) {
+		t.Errorf("status = %q, last_seen = %v, want an immediate flip to online", third.Status, third.LastSeen)
+	}
+}
+
+func TestCreateDeviceNormalizesLocation(t *testing.T) {
+	testutil.LoadConfig(t)
+	testutil.UseRedis(t)
+	db := testutil.UseDB(t)
+	user := createTestUser(t, "owner")
+
+	create := func(deviceID string, location gin.H) *httptest.ResponseRecorder {
+		t.Helper()
+		c, w := newJSONContext(t, "POST", "/api/v1/devices", gin.H{
+			"device_id": deviceID,
+			"name":      deviceID,
+			"type":      models.SoilMoisture,
+			"location":  location,
+		}, user)
+		NewDeviceController().CreateDevice(c)
+		return w
+	}
+
+	if w := create("sensor-1", gin.H{"latitude": "31.82", "longitude": 117.22, "plot": "A3"}); w.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	var device models.Device
+	if err := db.Where("device_id = ?", "sensor-1").First(&device).Error; err != nil {
+		t.Fatalf("failed to load device: %v", err)
+	}
+	want := models.JSONB{"lat": 31.82, "lng": 117.22, "meta": map[string]interface{}{"plot": "A3"}}
+	if fmt.Sprint(device.Location) != fmt.Sprint(want) {
+		t.Errorf("stored location = %v, want %v", device.Location, want)
+	}
+
+	// 坐标超出范围时返回422且不创建设备
+	if w := create("sensor-2", gin.H{"lat": 91, "lng": 0}); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d for an out-of-range latitude, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+	var count int64
+	db.Model(&models.Device{}).Where("device_id = ?", "sensor-2").Count(&count)
+	if count != 0 {
+		t.Errorf("device with an invalid location was created")
+	}
+}
+
+func TestDeleteDeviceDefersSensorDataPurge(t *testing.T) {
+	cfg := testutil.LoadConfig(t)
+	testutil.UseRedis(t)
+	db := testutil.UseDB(t)
+	user := createTestUser(t, "owner")
+
+	tests := []struct {
+		policy    string
+		wantDelay time.Duration
+	}{
+		{config.SensorDataRetain, 72 * time.Hour},
+		{config.SensorDataBatch, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			cfg.SensorData.DeletePolicy = tt.policy
+			cfg.SensorData.RetainFor = 72 * time.Hour
+
+			deviceID := "sensor-" + tt.policy
+			device := createTestDevice(t, models.Device{DeviceID: deviceID, OwnerID: user.ID})
+			for i := 0; i < 3; i++ {
+				if err := db.Create(&models.SensorData{DeviceID: deviceID, Data: models.JSONB{"soil_humidity": i}, Timestamp: time.Now()}).Error; err != nil {
+					t.Fatalf("failed to create reading: %v", err)
+				}
+			}
+			database.IncrDeviceDataCount(context.Background(), deviceID)
+
+			c, w := newJSONContext(t, "DELETE", "/api/v1/devices/"+deviceID, nil, user)
+			c.Params = gin.Params{{Key: "id", Value: deviceID}}
+			NewDeviceController().DeleteDevice(c)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+			var body struct {
+				Data struct {
+					Policy     string    `json:"sensor_data_policy"`
+					PurgeAfter time.Time `json:"purge_after"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if body.Data.Policy != tt.policy {
+				t.Errorf("sensor_data_policy = %q, want %q", body.Data.Policy, tt.policy)
+			}
+			if delay := time.Until(body.Data.PurgeAfter); delay > tt.wantDelay || delay < tt.wantDelay-time.Minute {
+				t.Errorf("purge_after is %s from now, want about %s", delay, tt.wantDelay)
+			}
+
+			// 设备被软删除，数据留给后台任务清理，计数缓存已清除
+			var deleted models.Device
+			if err := db.Unscoped().First(&deleted, device.ID).Error; err != nil || !deleted.DeletedAt.Valid {
+				t.Errorf("device deleted_at = %v (%v), want it soft-deleted", deleted.DeletedAt, err)
+			}
+			var readings int64
+			db.Model(&models.SensorData{}).Where("device_id = ?", deviceID).Count(&readings)
+			if readings != 3 {
+				t.Errorf("%d reading(s) left right after deletion, want 3", readings)
+			}
+			if exists, _ := database.NewCache().Exists(context.Background(), database.Keys.DeviceDataCount(deviceID)); exists {
+				t.Error("data count cache still exists after deletion")
+			}
+		})
+	}
+}
+
+func TestDeviceTypeBinding(t *testing.T) {
+	testutil.LoadConfig(t)
+	user := &models.User{ID: 1, Username: "owner", Role: models.RoleUser}
+
+	tests := []struct {
+		name   string
+		target string
+		body   string
+		handle func(*gin.Context)
+		field  string
+		want   string
+	}{
+		{"create device", "/api/v1/devices", `{"device_id":"d-1","name":"sensor","type":99}`, NewDeviceController().CreateDevice, "type", "must be a valid device type (see GET /devices/types)"},
+		{"create device with type 0", "/api/v1/devices", `{"device_id":"d-1","name":"sensor","type":0}`, NewDeviceController().CreateDevice, "type", "is required"},
+		{"create template", "/api/v1/device-templates", `{"name":"t","device_type":14,"config":{"interval":60}}`, NewDeviceController().CreateDeviceTemplate, "device_type", "must be a valid device type (see GET /devices/types)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := newJSONContext(t, "POST", tt.target, json.RawMessage(tt.body), user)
+			tt.handle(c)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusBadRequest, w.Body.String())
+			}
+			fieldErrors, _ := decodeBody(t, w)["errors"].(map[string]interface{})
+			if fieldErrors[tt.field] != tt.want {
+				t.Errorf("errors = %v, want %s: %s", fieldErrors, tt.field, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+	
+	"github.com/gin-gonic/gin"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+)
+
+const (
+	// defaultUptimeRange 未指定from时统计最近的时长
+	defaultUptimeRange = 24 * time.Hour
+	// maxUptimeRange 单次统计允许的最大时间跨度
+	maxUptimeRange = 90 * 24 * time.Hour
+	// defaultExpectedInterval 未指定expected_interval时的期望上报间隔
+	defaultExpectedInterval = 5 * time.Minute
+	// maxUptimeGaps 单次最多返回的中断区间数量，统计值不受影响
+	maxUptimeGaps = 500
+)
+
+// UptimeGap 一段上报中断区间
+type UptimeGap struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Duration string    `json:"duration"`
+	Seconds  float64   `json:"seconds"`
+}
+
+// DeviceUptimeResponse 设备上报可用性报告
+type DeviceUptimeResponse struct {
+	DeviceID         string      `json:"device_id"`
+	From             time.Time   `json:"from"`
+	To               time.Time   `json:"to"`
+	ExpectedInterval string      `json:"expected_interval"`
+	ExpectedPoints   int64       `json:"expected_points"`
+	ActualPoints     int64       `json:"actual_points"`
+	UptimePercent    float64     `json:"uptime_percent"` // 未处于中断区间的时间占比
+	DowntimeSeconds  float64     `json:"downtime_seconds"`
+	GapCount         int64       `json:"gap_count"`
+	Gaps             []UptimeGap `json:"gaps"`
+	Truncated        bool        `json:"truncated"` // 中断区间超过上限时只返回最早的部分
+}
+
+// uptimeSummary 中断统计查询结果
+type uptimeSummary struct {
+	Points          int64
+	GapCount        int64
+	DowntimeSeconds float64
+}
+
+// uptimeGapRow 中断区间查询结果
+type uptimeGapRow struct {
+	GapStart time.Time
+	GapEnd   time.Time
+}
+
+// uptimeOrderedSQL 统计区间内的读数时间序列，首尾补上区间边界，
+// 使区间开始前和结束后的空白也计入中断；LAG取相邻读数计算间隔
+const uptimeOrderedSQL = `WITH points AS (
+		SELECT timestamp AS ts FROM sensor_data
+		WHERE device_id = @device_id AND timestamp > @from AND timestamp < @to
+		UNION ALL SELECT CAST(@from AS timestamptz)
+		UNION ALL SELECT CAST(@to AS timestamptz)
+	), ordered AS (
+		SELECT ts, LAG(ts) OVER (ORDER BY ts) AS prev FROM points
+	)`
+
+// GetDeviceUptime 获取设备上报可用性报告
+// @Summary 获取设备上报可用性
+// @Description 按期望上报间隔统计时间范围内的期望/实际数据点数、中断区间和可用率，用于SLA报告
+// @Tags 设备管理
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "设备ID或device_id"
+// @Param from query string false "开始时间，默认24小时前" format(date-time)
+// @Param to query string false "结束时间，默认当前时间" format(date-time)
+// @Param expected_interval query string false "期望上报间隔，如30s、5m" default(5m)
+// @Success 200 {object} DeviceUptimeResponse
+// @Failure 400 {object} map[string]interface{}
+// @Router /devices/{id}/uptime [get]
+func (ctrl *DeviceController) GetDeviceUptime(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	to := time.Now()
+	if raw := c.Query("to"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid to, expected RFC3339 time",
+			})
+			return
+		}
+		to = t
+	}
+	from := to.Add(-defaultUptimeRange)
+	if raw := c.Query("from"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid from, expected RFC3339 time",
+			})
+			return
+		}
+		from = t
+	}
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "from must be earlier than to",
+		})
+		return
+	}
+	if to.Sub(from) > maxUptimeRange {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Time range must not exceed " + maxUptimeRange.String(),
+		})
+		return
+	}
+	
+	interval := defaultExpectedInterval
+	if raw := c.Query("expected_interval"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < time.Second {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid expected_interval, expected a duration of at least 1s",
+			})
+			return
+		}
+		interval = d
+	}
+	if interval > to.Sub(from) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "expected_interval must not exceed the time range",
+		})
+		return
+	}
+	
+	// 验证设备所有权
+	db := database.GetDBWithContext(c.Request.Context())
+	var device models.Device
+	if err := db.Scopes(deviceParam(c, true)).Where("owner_id = ?", userID).First(&device).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Device not found",
+		})
+		return
+	}
+	
+	deviceID := device.DeviceID
+	
+	args := map[string]interface{}{
+		"device_id": deviceID,
+		"from":      from,
+		"to":        to,
+		"interval":  interval.Seconds(),
+	}
+	
+	var summary uptimeSummary
+	if err := db.Raw(uptimeOrderedSQL+`
+		SELECT
+			COUNT(*) AS points,
+			COUNT(*) FILTER (WHERE ts - prev > make_interval(secs => @interval)) AS gap_count,
+			COALESCE(SUM(EXTRACT(EPOCH FROM ts - prev)) FILTER (WHERE ts - prev > make_interval(secs => @interval)), 0) AS downtime_seconds
+		FROM ordered`, args).Scan(&summary).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to compute uptime",
+		})
+		return
+	}
+	
+	args["limit"] = maxUptimeGaps + 1
+	var rows []uptimeGapRow
+	if err := db.Raw(uptimeOrderedSQL+`
+		SELECT prev AS gap_start, ts AS gap_end
+		FROM ordered
+		WHERE ts - prev > make_interval(secs => @interval)
+		ORDER BY prev
+		LIMIT @limit`, args).Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to compute uptime",
+		})
+		return
+	}
+	
+	truncated := len(rows) > maxUptimeGaps
+	if truncated {
+		rows = rows[:maxUptimeGaps]
+	}
+	gaps := make([]UptimeGap, 0, len(rows))
+	for _, row := range rows {
+		duration := row.GapEnd.Sub(row.GapStart)
+		gaps = append(gaps, UptimeGap{
+			Start:    row.GapStart,
+			End:      row.GapEnd,
+			Duration: duration.String(),
+			Seconds:  duration.Seconds(),
+		})
+	}
+	
+	// points包含补上的两个区间边界
+	actual := summary.Points - 2
+	if actual < 0 {
+		actual = 0
+	}
+	total := to.Sub(from).Seconds()
+	uptime := 100 * (1 - summary.DowntimeSeconds/total)
+	if uptime < 0 {
+		uptime = 0
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"data": DeviceUptimeResponse{
+			DeviceID:         deviceID,
+			From:             from,
+			To:               to,
+			ExpectedInterval: interval.String(),
+			ExpectedPoints:   int64(to.Sub(from) / interval),
+			ActualPoints:     actual,
+			UptimePercent:    uptime,
+			DowntimeSeconds:  summary.DowntimeSeconds,
+			GapCount:         summary.GapCount,
+			Gaps:             gaps,
+			Truncated:        truncated,
+		},
+	})
+}
+
@@ -0,0 +1,303 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+	
+	"github.com/gin-gonic/gin"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+)
+
+// upstreamContentActions 视为上游内容变更的历史操作（浏览、点赞等计数变化不计入）
+var upstreamContentActions = []string{"update", "merge", "revert"}
+
+// UpstreamSummary 上游项目摘要
+type UpstreamSummary struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	OwnerID   uint      `json:"owner_id"`
+	Public    bool      `json:"public"`
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ForkSummary 用户Fork的项目及其上游状态
+type ForkSummary struct {
+	Project           models.Project   `json:"project"`
+	Upstream          *UpstreamSummary `json:"upstream"` // 上游已删除或无权访问时为空
+	UpstreamDeleted   bool             `json:"upstream_deleted"`
+	UpstreamUpdated   bool             `json:"upstream_updated"` // 上游在Fork创建之后有内容变更（暂无同步操作，以Fork的创建时间为基准）
+	UpstreamChangedAt *time.Time       `json:"upstream_changed_at"`
+}
+
+// ForkListResponse Fork列表响应
+type ForkListResponse struct {
+	Forks []ForkSummary `json:"forks"`
+	Total int64         `json:"total"`
+	Page  int           `json:"page"`
+	Limit int           `json:"limit"`
+}
+
+// GetMyForks 获取当前用户Fork的项目
+// @Summary 我的Fork
+// @Description 列出当前用户Fork的项目、上游信息以及上游是否在Fork之后有更新
+// @Tags 项目管理
+// @Security BearerAuth
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param limit query int false "每页数量" default(10)
+// @Success 200 {object} ForkListResponse
+// @Router /users/me/forks [get]
+func (ctrl *ProjectController) GetMyForks(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+		})
+		return
+	}
+	
+	pagination := parseListPagination(c)
+	
+	// 上游被删除后parent_id会被置空，通过Fork记录仍可识别为Fork项目
+	db := database.GetDBWithContext(c.Request.Context())
+	query := db.Model(&models.Project{}).
+		Where("owner_id = ?", userID).
+		Where("parent_id IS NOT NULL OR id IN (?)", db.Model(&models.Fork{}).Select("project_id"))
+	
+	var total int64
+	query.Count(&total)
+	
+	var forks []models.Project
+	if err := query.Order("created_at DESC").
+		Offset(pagination.Offset).
+		Limit(pagination.Limit).
+		Find(&forks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch forks",
+		})
+		return
+	}
+	
+	parentIDs := make([]uint, 0, len(forks))
+	for _, fork := range forks {
+		if fork.ParentID != nil {
+			parentIDs = append(parentIDs, *fork.ParentID)
+		}
+	}
+	
+	parents := make(map[uint]models.Project, len(parentIDs))
+	changedAt := make(map[uint]time.Time, len(parentIDs))
+	if len(parentIDs) > 0 {
+		var parentList []models.Project
+		db.Where("id IN ?", parentIDs).Find(&parentList)
+		for _, parent := range parentList {
+			parents[parent.ID] = parent
+		}
+		
+		var changes []struct {
+			ProjectID uint
+			ChangedAt time.Time
+		}
+		db.Model(&models.ForkHistory{}).
+			Select("project_id, MAX(created_at) AS changed_at").
+			Where("project_id IN ? AND action IN ?", parentIDs, upstreamContentActions).
+			Group("project_id").
+			Scan(&changes)
+		for _, change := range changes {
+			changedAt[change.ProjectID] = change.ChangedAt
+		}
+	}
+	
+	summaries := make([]ForkSummary, 0, len(forks))
+	for _, fork := range forks {
+		summary := ForkSummary{Project: fork}
+		
+		parent, ok := models.Project{}, false
+		if fork.ParentID != nil {
+			parent, ok = parents[*fork.ParentID]
+		}
+		if !ok {
+			summary.UpstreamDeleted = true
+			summaries = append(summaries, summary)
+			continue
+		}
+		
+		if parent.Public || parent.OwnerID == userID || middleware.IsAdminOf(c, parent.OwnerID) {
+			summary.Upstream = &UpstreamSummary{
+				ID:        parent.ID,
+				Name:      parent.Name,
+				OwnerID:   parent.OwnerID,
+				Public:    parent.Public,
+				Version:   parent.Version,
+				UpdatedAt: parent.UpdatedAt,
+			}
+		}
+		
+		if changed, ok := changedAt[parent.ID]; ok {
+			summary.UpstreamChangedAt = &changed
+			summary.UpstreamUpdated = changed.After(fork.CreatedAt)
+		}
+		
+		summaries = append(summaries, summary)
+	}
+	
+	setPaginationHeaders(c, total, pagination.Page, pagination.Limit)
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"data": ForkListResponse{
+			Forks: summaries,
+			Total: total,
+			Page:  pagination.Page,
+			Limit: pagination.Limit,
+		},
+	})
+}
+
+// Fork与上游配置的同步状态
+const (
+	forkSyncUpToDate = "up_to_date"
+	forkSyncBehind   = "behind"   // 上游在Fork之后修改了配置
+	forkSyncAhead    = "ahead"    // Fork在创建之后修改了配置
+	forkSyncDiverged = "diverged" // 双方都有修改
+	forkSyncUnknown  = "unknown"  // 缺少Fork时的配置快照，只能判断当前是否一致
+)
+
+// ChildFork 项目的下游Fork
+type ChildFork struct {
+	Hidden     bool               `json:"hidden"` // 私有Fork对非拥有者只显示存在
+	ID         uint               `json:"id,omitempty"`
+	Name       string             `json:"name,omitempty"`
+	Owner      *models.PublicUser `json:"owner,omitempty"`
+	Public     bool               `json:"public"`
+	CreatedAt  *time.Time         `json:"created_at,omitempty"`
+	SyncStatus string             `json:"sync_status,omitempty"`
+	Behind     bool               `json:"behind"`
+	Ahead      bool               `json:"ahead"`
+	BehindKeys []string           `json:"behind_keys,omitempty"` // 上游相对Fork快照变化的顶层配置键
+	AheadKeys  []string           `json:"ahead_keys,omitempty"`  // Fork相对快照变化的顶层配置键
+	Differs    bool               `json:"differs"`               // 当前配置与上游不一致
+}
+
+// ChildForkListResponse 下游Fork列表响应
+type ChildForkListResponse struct {
+	Forks []ChildFork `json:"forks"`
+	Total int64       `json:"total"`
+	Page  int         `json:"page"`
+	Limit int         `json:"limit"`
+}
+
+// GetProjectForks 获取项目的下游Fork
+// @Summary 项目的Fork列表
+// @Description 列出直接Fork自该项目的项目，并根据Fork时的配置快照判断各Fork落后（behind）或领先（ahead）于当前上游配置
+// @Tags 项目管理
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "项目ID"
+// @Param page query int false "页码" default(1)
+// @Param limit query int false "每页数量" default(10)
+// @Success 200 {object} ChildForkListResponse
+// @Failure 404 {object} map[string]interface{}
+// @Router /projects/{id}/forks [get]
+func (ctrl *ProjectController) GetProjectForks(c *gin.Context) {
+	project, ok := loadProjectParam(c)
+	if !ok {
+		return
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	if !canViewProject(projectRole(c, db, project), project) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Project not found",
+		})
+		return
+	}
+	
+	pagination := parseListPagination(c)
+	query := db.Model(&models.Project{}).Where("parent_id = ?", project.ID)
+	
+	var total int64
+	query.Count(&total)
+	
+	var children []models.Project
+	if err := query.Preload("Owner").
+		Order("created_at DESC").
+		Offset(pagination.Offset).
+		Limit(pagination.Limit).
+		Find(&children).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch forks",
+		})
+		return
+	}
+	
+	// Fork记录中保存了Fork时上游的配置快照，作为三方比较的基准
+	snapshots := make(map[uint]models.JSONB, len(children))
+	if len(children) > 0 {
+		childIDs := make([]uint, 0, len(children))
+		for _, child := range children {
+			childIDs = append(childIDs, child.ID)
+		}
+		var records []models.Fork
+		db.Select("project_id", "config").Where("project_id IN ?", childIDs).Find(&records)
+		for _, record := range records {
+			snapshots[record.ProjectID] = record.Config
+		}
+	}
+	
+	userID := middleware.GetUserID(c)
+	forks := make([]ChildFork, 0, len(children))
+	for _, child := range children {
+		if !child.Public && child.OwnerID != userID && !middleware.IsAdminOf(c, child.OwnerID) {
+			forks = append(forks, ChildFork{Hidden: true})
+			continue
+		}
+		
+		owner := child.Owner
+		createdAt := child.CreatedAt
+		fork := ChildFork{
+			ID:        child.ID,
+			Name:      child.Name,
+			Owner:     &owner,
+			Public:    child.Public,
+			CreatedAt: &createdAt,
+			Differs:   len(models.ChangedConfigKeys(project.Config, child.Config)) > 0,
+		}
+		
+		if snapshot, ok := snapshots[child.ID]; ok {
+			fork.BehindKeys = models.ChangedConfigKeys(snapshot, project.Config)
+			fork.AheadKeys = models.ChangedConfigKeys(snapshot, child.Config)
+			fork.Behind = len(fork.BehindKeys) > 0
+			fork.Ahead = len(fork.AheadKeys) > 0
+			switch {
+			case fork.Behind && fork.Ahead:
+				fork.SyncStatus = forkSyncDiverged
+			case fork.Behind:
+				fork.SyncStatus = forkSyncBehind
+			case fork.Ahead:
+				fork.SyncStatus = forkSyncAhead
+			default:
+				fork.SyncStatus = forkSyncUpToDate
+			}
+		} else {
+			fork.SyncStatus = forkSyncUnknown
+		}
+		
+		forks = append(forks, fork)
+	}
+	
+	setPaginationHeaders(c, total, pagination.Page, pagination.Limit)
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"data": ChildForkListResponse{
+			Forks: forks,
+			Total: total,
+			Page:  pagination.Page,
+			Limit: pagination.Limit,
+		},
+	})
+}
@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/testutil"
+)
+
+// createTestProject 在测试库中创建项目
+func createTestProject(t *testing.T, project models.Project) models.Project {
+	t.Helper()
+	if err := database.DB.Create(&project).Error; err != nil {
+		t.Fatalf("failed to create project %s: %v", project.Name, err)
+	}
+	return project
+}
+
+func TestGetMyForksUpstreamUpdated(t *testing.T) {
+	testutil.LoadConfig(t)
+	db := testutil.UseDB(t)
+
+	upstreamOwner := createTestUser(t, "upstream")
+	user := createTestUser(t, "forker")
+	forkedAt := time.Now().Add(-2 * time.Hour).UTC()
+
+	changed := createTestProject(t, models.Project{Name: "changed", OwnerID: upstreamOwner.ID, Public: true})
+	unchanged := createTestProject(t, models.Project{Name: "unchanged", OwnerID: upstreamOwner.ID, Public: true})
+
+	forkOfChanged := createTestProject(t, models.Project{Name: "fork-changed", OwnerID: user.ID, ParentID: &changed.ID, CreatedAt: forkedAt})
+	forkOfUnchanged := createTestProject(t, models.Project{Name: "fork-unchanged", OwnerID: user.ID, ParentID: &unchanged.ID, CreatedAt: forkedAt.Add(time.Minute)})
+	createTestProject(t, models.Project{Name: "not-a-fork", OwnerID: user.ID})
+
+	history := []models.ForkHistory{
+		// 上游在Fork之后修改了配置
+		{ProjectID: changed.ID, UserID: upstreamOwner.ID, Action: "update", CreatedAt: forkedAt.Add(time.Hour)},
+		// 修改发生在Fork之前，浏览等非内容操作不计入
+		{ProjectID: unchanged.ID, UserID: upstreamOwner.ID, Action: "update", CreatedAt: forkedAt.Add(-time.Hour)},
+		{ProjectID: unchanged.ID, UserID: upstreamOwner.ID, Action: "collaborator_add", CreatedAt: forkedAt.Add(time.Hour)},
+	}
+	if err := db.Create(&history).Error; err != nil {
+		t.Fatalf("create history: %v", err)
+	}
+
+	c, w := newJSONContext(t, "GET", "/api/v1/users/me/forks", nil, user)
+	NewProjectController().GetMyForks(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+
+	var body struct {
+		Data ForkListResponse `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Data.Total != 2 {
+		t.Fatalf("total = %d, want 2 forks", body.Data.Total)
+	}
+
+	want := map[uint]bool{forkOfChanged.ID: true, forkOfUnchanged.ID: false}
+	for _, fork := range body.Data.Forks {
+		updated, ok := want[fork.Project.ID]
+		if !ok {
+			t.Errorf("unexpected project %d in fork list", fork.Project.ID)
+			continue
+		}
+		if fork.UpstreamUpdated != updated {
+			t.Errorf("fork %s upstream_updated = %v, want %v", fork.Project.Name, fork.UpstreamUpdated, updated)
+		}
+		if fork.Upstream == nil || fork.UpstreamDeleted {
+			t.Errorf("fork %s lost its upstream", fork.Project.Name)
+		}
+	}
+}
+
+func TestGetMyForksDeletedUpstream(t *testing.T) {
+	testutil.LoadConfig(t)
+	db := testutil.UseDB(t)
+
+	user := createTestUser(t, "forker")
+	parent := createTestProject(t, models.Project{Name: "gone", OwnerID: user.ID, Public: true})
+	fork := createTestProject(t, models.Project{Name: "orphan", OwnerID: user.ID, ParentID: &parent.ID})
+	if err := db.Create(&models.Fork{ProjectID: fork.ID, UserID: user.ID}).Error; err != nil {
+		t.Fatalf("create fork record: %v", err)
+	}
+
+	// 与DeleteProject一致：删除上游前将下游的parent_id置空
+	if err := db.Model(&models.Project{}).Where("parent_id = ?", parent.ID).Update("parent_id", nil).Error; err != nil {
+		t.Fatalf("detach fork: %v", err)
+	}
+	if err := db.Delete(&models.Project{}, parent.ID).Error; err != nil {
+		t.Fatalf("delete parent: %v", err)
+	}
+
+	c, w := newJSONContext(t, "GET", "/api/v1/users/me/forks", nil, user)
+	NewProjectController().GetMyForks(c)
+
+	var body struct {
+		Data ForkListResponse `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(body.Data.Forks) != 1 || body.Data.Forks[0].Project.ID != fork.ID {
+		t.Fatalf("forks = %+v, want only %d", body.Data.Forks, fork.ID)
+	}
+	if !body.Data.Forks[0].UpstreamDeleted || body.Data.Forks[0].Upstream != nil {
+		t.Errorf("deleted upstream reported as %+v", body.Data.Forks[0])
+	}
+}
+
+func TestGetProjectReturnsChildCount(t *testing.T) {
+	testutil.LoadConfig(t)
+	db := testutil.UseDB(t)
+	owner := createTestUser(t, "owner")
+	upstream := createTestProject(t, models.Project{Name: "upstream", Public: true, OwnerID: owner.ID})
+
+	var children []models.Project
+	for i := 0; i < 5; i++ {
+		forker := createTestUser(t, fmt.Sprintf("forker%d", i))
+		children = append(children, createTestProject(t, models.Project{Name: fmt.Sprintf("fork-%d", i), Public: true, OwnerID: forker.ID, ParentID: &upstream.ID}))
+	}
+	// 已删除的Fork不计数
+	if err := db.Delete(&children[4]).Error; err != nil {
+		t.Fatalf("failed to delete fork: %v", err)
+	}
+
+	c, w := newJSONContext(t, "GET", fmt.Sprintf("/api/v1/projects/%d", upstream.ID), nil, owner)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(upstream.ID)}}
+	NewProjectController().GetProject(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	data := decodeBody(t, w)["data"].(map[string]interface{})
+	if _, ok := data["children"]; ok {
+		t.Errorf("detail embeds children: %v", data["children"])
+	}
+	if data["child_count"] != float64(4) {
+		t.Errorf("child_count = %v, want 4", data["child_count"])
+	}
+
+	// Fork列表分页返回
+	c, w = newJSONContext(t, "GET", fmt.Sprintf("/api/v1/projects/%d/forks?page=2&limit=3", upstream.ID), nil, owner)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(upstream.ID)}}
+	NewProjectController().GetProjectForks(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("forks status = %d: %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Data ChildForkListResponse `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Data.Total != 4 || body.Data.Page != 2 || len(body.Data.Forks) != 1 {
+		t.Errorf("total=%d page=%d forks=%d, want 4, page 2 and 1 fork", body.Data.Total, body.Data.Page, len(body.Data.Forks))
+	}
+}
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/models"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func newTestContext(method, target string) (*gin.Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, target, nil)
+	return c, w
+}
+
+// newJSONContext 构造携带JSON请求体和登录用户的请求上下文
+func newJSONContext(t *testing.T, method, target string, body interface{}, user *models.User) (*gin.Context, *httptest.ResponseRecorder) {
+	t.Helper()
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			t.Fatalf("failed to encode request body: %v", err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, target, bytes.NewReader(payload))
+	c.Request.Header.Set("Content-Type", "application/json")
+	if user != nil {
+		setTestUser(c, user)
+	}
+	return c, w
+}
+
+// setTestUser 模拟AuthRequired写入的认证信息
+func setTestUser(c *gin.Context, user *models.User) {
+	role := user.Role
+	if role == "" {
+		role = "user"
+	}
+	c.Set("user_id", user.ID)
+	c.Set("username", user.Username)
+	c.Set("role", role)
+	c.Set("tenant_id", user.TenantID)
+}
+
+// decodeBody 解析JSON响应体
+func decodeBody(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode response %q: %v", w.Body.String(), err)
+	}
+	return body
+}
+
+// createTestUser 在测试库中创建用户
+func createTestUser(t *testing.T, username string, mutate ...func(*models.User)) *models.User {
+	t.Helper()
+	user := &models.User{
+		Username: username,
+		Email:    username + "@example.com",
+		Phone:    "phone-" + username,
+		Password: "secret123",
+		Role:     "user",
+		Active:   true,
+	}
+	for _, fn := range mutate {
+		fn(user)
+	}
+	if err := database.DB.Create(user).Error; err != nil {
+		t.Fatalf("failed to create user %s: %v", username, err)
+	}
+	return user
+}
+
+// createTestDevice 在测试库中创建设备，未指定时使用土壤湿度类型
+func createTestDevice(t *testing.T, device models.Device) models.Device {
+	t.Helper()
+	if device.Name == "" {
+		device.Name = device.DeviceID
+	}
+	if device.Type == 0 {
+		device.Type = models.SoilMoisture
+	}
+	if err := database.DB.Create(&device).Error; err != nil {
+		t.Fatalf("failed to create device %s: %v", device.DeviceID, err)
+	}
+	return device
+}
@@ -0,0 +1,361 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	
+	"github.com/gin-gonic/gin"
+	"iot-platform-backend/internal/config"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/ingest"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+)
+
+const (
+	exportFormatCSV    = "csv"
+	exportFormatNDJSON = "ndjson"
+	
+	// exportFlushRows 每写出多少行刷新一次响应
+	exportFlushRows = 500
+	
+	// exportExtraColumn CSV中未在设备类型元数据中定义的字段，以JSON对象写入该列
+	exportExtraColumn = "extra"
+)
+
+// ExportHistoryRequest 多设备历史数据导出请求
+type ExportHistoryRequest struct {
+	DeviceIDs []string  `json:"device_ids" binding:"required,min=1,max=100,dive,required"`
+	StartTime time.Time `json:"start_time" binding:"required"`
+	EndTime   time.Time `json:"end_time" binding:"required"`
+	Format    string    `json:"format" binding:"omitempty,oneof=csv ndjson"` // 默认csv
+}
+
+// exportRow NDJSON导出的单行
+type exportRow struct {
+	DeviceID  string       `json:"device_id"`
+	Timestamp time.Time    `json:"timestamp"`
+	Quality   string       `json:"quality"`
+	Data      models.JSONB `json:"data"`
+}
+
+// ExportDeviceHistory 导出多台设备的历史数据
+// @Summary 导出多设备历史数据
+// @Description 按时间顺序（时间相同时按写入顺序）合并导出多台设备在时间范围内的数据，流式返回CSV或NDJSON。
+// @Description CSV按设备类型元数据展开字段，嵌套对象以点号连接键名，未定义的字段（包括派生字段）写入extra列。
+// @Description 行数超出SENSOR_DATA_EXPORT_MAX_ROWS时拒绝导出；字节数超出SENSOR_DATA_EXPORT_MAX_BYTES时截断，响应尾部X-Export-Truncated为true
+// @Tags 设备数据
+// @Security BearerAuth
+// @Accept json
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Param request body ExportHistoryRequest true "导出范围"
+// @Success 200 {string} string "导出数据"
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{} "部分设备不存在或无权访问"
+// @Failure 422 {object} map[string]interface{} "数据行数超出导出上限"
+// @Router /devices/history/export [post]
+func (ctrl *DeviceController) ExportDeviceHistory(c *gin.Context) {
+	var req ExportHistoryRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if !req.EndTime.After(req.StartTime) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "end_time must be after start_time",
+		})
+		return
+	}
+	format := req.Format
+	if format == "" {
+		format = exportFormatCSV
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	devices, ok := loadExportDevices(c, req.DeviceIDs)
+	if !ok {
+		return
+	}
+	
+	deviceIDs := make([]string, 0, len(devices))
+	deviceTypes := make(map[string]models.DeviceType, len(devices))
+	for _, device := range devices {
+		deviceIDs = append(deviceIDs, device.DeviceID)
+		deviceTypes[device.DeviceID] = device.Type
+	}
+	query := db.Model(&models.SensorData{}).
+		Where("device_id IN ? AND timestamp >= ? AND timestamp <= ?", deviceIDs, req.StartTime, req.EndTime)
+	
+	limits := config.AppConfig.SensorData
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to export sensor data",
+		})
+		return
+	}
+	if total > limits.ExportMaxRows {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Export too large",
+			"details": fmt.Sprintf("%d rows match, the limit is %d; narrow the time range or device list", total, limits.ExportMaxRows),
+		})
+		return
+	}
+	
+	// 按时间顺序导出，排序规则与历史查询的升序一致，多台设备的数据按时间交错
+	order, _ := historyOrder("asc")
+	rows, err := query.Order(order).Rows()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to export sensor data",
+		})
+		return
+	}
+	defer rows.Close()
+	
+	contentType := "text/csv; charset=utf-8"
+	if format == exportFormatNDJSON {
+		contentType = "application/x-ndjson"
+	}
+	header := c.Writer.Header()
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="history-%s.%s"`, time.Now().UTC().Format("20060102T150405Z"), format))
+	header.Set("Trailer", "X-Export-Rows, X-Export-Truncated")
+	c.Status(http.StatusOK)
+	
+	var encoder exportEncoder
+	if format == exportFormatNDJSON {
+		encoder = ndjsonEncoder{}
+	} else {
+		encoder = newCSVEncoder(devices)
+	}
+	
+	var written, count int64
+	truncated := false
+	var line bytes.Buffer
+	if err := encoder.header(&line); err == nil && line.Len() > 0 {
+		n, _ := c.Writer.Write(line.Bytes())
+		written += int64(n)
+	}
+	
+	for rows.Next() {
+		var data models.SensorData
+		if err := db.ScanRows(rows, &data); err != nil {
+			log.Printf("History export failed to read row: %v", err)
+			truncated = true
+			break
+		}
+		
+		data.Data = ingest.Enrich(deviceTypes[data.DeviceID], data.Data)
+		
+		line.Reset()
+		if err := encoder.row(&line, &data); err != nil {
+			log.Printf("History export failed to encode row %d: %v", data.ID, err)
+			continue
+		}
+		if written+int64(line.Len()) > limits.ExportMaxBytes {
+			truncated = true
+			break
+		}
+		
+		n, err := c.Writer.Write(line.Bytes())
+		written += int64(n)
+		if err != nil {
+			// 客户端断开
+			return
+		}
+		count++
+		if count%exportFlushRows == 0 {
+			c.Writer.Flush()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("History export stopped early: %v", err)
+		truncated = true
+	}
+	
+	header.Set("X-Export-Rows", strconv.FormatInt(count, 10))
+	header.Set("X-Export-Truncated", strconv.FormatBool(truncated))
+	c.Writer.Flush()
+}
+
+// loadExportDevices 按请求顺序加载待导出的设备，逐台检查访问权限（拥有者或管理员），失败时已写入响应
+func loadExportDevices(c *gin.Context, deviceIDs []string) ([]models.Device, bool) {
+	ids := uniqueStrings(deviceIDs)
+	
+	var found []models.Device
+	if err := database.GetDBWithContext(c.Request.Context()).Where("device_id IN ?", ids).Find(&found).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to load devices",
+		})
+		return nil, false
+	}
+	byID := make(map[string]models.Device, len(found))
+	for _, device := range found {
+		byID[device.DeviceID] = device
+	}
+	
+	// 无权访问的设备与不存在的设备一并返回404，不泄露其他用户的设备标识是否存在
+	userID := middleware.GetUserID(c)
+	devices := make([]models.Device, 0, len(ids))
+	var missing []string
+	for _, id := range ids {
+		device, ok := byID[id]
+		if !ok || (device.OwnerID != userID && !middleware.IsAdminOf(c, device.OwnerID)) {
+			missing = append(missing, id)
+			continue
+		}
+		devices = append(devices, device)
+	}
+	
+	if len(missing) > 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Device not found",
+			"details": missing,
+		})
+		return nil, false
+	}
+	return devices, true
+}
+
+// exportEncoder 导出格式编码器，每次调用写出完整的一行
+type exportEncoder interface {
+	header(buf *bytes.Buffer) error
+	row(buf *bytes.Buffer, data *models.SensorData) error
+}
+
+// ndjsonEncoder 每行一个JSON对象，保留原始的嵌套结构
+type ndjsonEncoder struct{}
+
+func (ndjsonEncoder) header(buf *bytes.Buffer) error {
+	return nil
+}
+
+func (ndjsonEncoder) row(buf *bytes.Buffer, data *models.SensorData) error {
+	return json.NewEncoder(buf).Encode(exportRow{
+		DeviceID:  data.DeviceID,
+		Timestamp: data.Timestamp,
+		Quality:   data.Quality,
+		Data:      data.Data,
+	})
+}
+
+// csvEncoder 按固定列输出CSV：timestamp、device_id、quality、各设备类型定义的字段、extra
+type csvEncoder struct {
+	columns []string
+	known   map[string]bool
+}
+
+// newCSVEncoder 按设备顺序汇总各设备类型的字段作为列
+func newCSVEncoder(devices []models.Device) *csvEncoder {
+	e := &csvEncoder{known: make(map[string]bool)}
+	for _, device := range devices {
+		for _, field := range models.DeviceTypeFields[device.Type] {
+			if !e.known[field.Key] {
+				e.known[field.Key] = true
+				e.columns = append(e.columns, field.Key)
+			}
+		}
+	}
+	return e
+}
+
+func (e *csvEncoder) header(buf *bytes.Buffer) error {
+	record := append([]string{"timestamp", "device_id", "quality"}, e.columns...)
+	record = append(record, exportExtraColumn)
+	return writeCSVRecord(buf, record)
+}
+
+func (e *csvEncoder) row(buf *bytes.Buffer, data *models.SensorData) error {
+	fields := make(map[string]interface{})
+	flattenReading("", map[string]interface{}(data.Data), fields)
+	
+	record := make([]string, 0, len(e.columns)+4)
+	record = append(record, data.Timestamp.UTC().Format(time.RFC3339Nano), data.DeviceID, data.Quality)
+	for _, column := range e.columns {
+		record = append(record, formatExportValue(fields[column]))
+		delete(fields, column)
+	}
+	
+	extra := ""
+	if len(fields) > 0 {
+		encoded, err := json.Marshal(fields)
+		if err != nil {
+			return err
+		}
+		extra = string(encoded)
+	}
+	record = append(record, extra)
+	return writeCSVRecord(buf, record)
+}
+
+// writeCSVRecord 写出一行CSV
+func writeCSVRecord(buf *bytes.Buffer, record []string) error {
+	writer := csv.NewWriter(buf)
+	if err := writer.Write(record); err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// flattenReading 将读数中的嵌套对象展开为以点号连接的键，数组和标量作为叶子值
+func flattenReading(prefix string, data map[string]interface{}, out map[string]interface{}) {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	
+	for _, key := range keys {
+		name := key
+		if prefix != "" {
+			name = prefix + "." + key
+		}
+		if nested, ok := asObject(data[key]); ok {
+			flattenReading(name, nested, out)
+			continue
+		}
+		out[name] = data[key]
+	}
+}
+
+// asObject 判断值是否为JSON对象
+func asObject(value interface{}) (map[string]interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v, true
+	case models.JSONB:
+		return map[string]interface{}(v), true
+	}
+	return nil, false
+}
+
+// formatExportValue 将叶子值格式化为CSV单元格
+func formatExportValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return strings.TrimSpace(string(encoded))
+}
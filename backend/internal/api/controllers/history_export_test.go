@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/testutil"
+)
+
+func TestExportDeviceHistory(t *testing.T) {
+	cfg := testutil.LoadConfig(t)
+	testutil.UseDB(t)
+
+	owner := createTestUser(t, "owner")
+	other := createTestUser(t, "other")
+	admin := createTestUser(t, "root", func(u *models.User) { u.Role = models.RoleAdmin })
+	createTestDevice(t, models.Device{DeviceID: "ws-1", Type: models.WeatherStation, OwnerID: owner.ID})
+	createTestDevice(t, models.Device{DeviceID: "soil-1", Type: models.SoilMoisture, OwnerID: owner.ID})
+	createTestDevice(t, models.Device{DeviceID: "other-1", Type: models.SoilMoisture, OwnerID: other.ID})
+
+	// 按设备分批写入，时间交错
+	base := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	for _, reading := range []struct {
+		deviceID string
+		minute   int
+		data     models.JSONB
+	}{
+		{"ws-1", 0, models.JSONB{"temperature": 20.5}},
+		{"ws-1", 2, models.JSONB{"temperature": 21.0}},
+		{"ws-1", 4, models.JSONB{"temperature": 21.5}},
+		{"soil-1", 1, models.JSONB{"soil_humidity": 30.0}},
+		{"soil-1", 3, models.JSONB{"soil_humidity": 31.0, "probe": models.JSONB{"depth": 20}}},
+		{"other-1", 2, models.JSONB{"soil_humidity": 50.0}},
+		{"ws-1", 90, models.JSONB{"temperature": 25.0}}, // 超出导出时间范围
+	} {
+		row := models.SensorData{DeviceID: reading.deviceID, Data: reading.data, Timestamp: base.Add(time.Duration(reading.minute) * time.Minute)}
+		if err := database.DB.Create(&row).Error; err != nil {
+			t.Fatalf("failed to create reading: %v", err)
+		}
+	}
+
+	export := func(user *models.User, format string, deviceIDs ...string) *httptest.ResponseRecorder {
+		t.Helper()
+		c, w := newJSONContext(t, "POST", "/api/v1/devices/history/export", ExportHistoryRequest{
+			DeviceIDs: deviceIDs,
+			StartTime: base,
+			EndTime:   base.Add(time.Hour),
+			Format:    format,
+		}, user)
+		NewDeviceController().ExportDeviceHistory(c)
+		return w
+	}
+	wantOrder := []string{"ws-1", "soil-1", "ws-1", "soil-1", "ws-1"}
+
+	t.Run("ndjson rows are interleaved by timestamp", func(t *testing.T) {
+		w := export(owner, exportFormatNDJSON, "ws-1", "soil-1")
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+		}
+		var devices []string
+		var previous time.Time
+		scanner := bufio.NewScanner(strings.NewReader(w.Body.String()))
+		for scanner.Scan() {
+			var row exportRow
+			if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+				t.Fatalf("invalid NDJSON line %q: %v", scanner.Text(), err)
+			}
+			if row.Timestamp.Before(previous) {
+				t.Errorf("row at %s is out of order after %s", row.Timestamp, previous)
+			}
+			previous = row.Timestamp
+			devices = append(devices, row.DeviceID)
+		}
+		if fmt.Sprint(devices) != fmt.Sprint(wantOrder) {
+			t.Errorf("device order = %v, want %v", devices, wantOrder)
+		}
+		if got := w.Header().Get("X-Export-Rows"); got != "5" {
+			t.Errorf("X-Export-Rows = %q, want 5", got)
+		}
+		if got := w.Header().Get("X-Export-Truncated"); got != "false" {
+			t.Errorf("X-Export-Truncated = %q, want false", got)
+		}
+	})
+
+	t.Run("csv rows carry device_id and flattened fields", func(t *testing.T) {
+		w := export(owner, "", "ws-1", "soil-1")
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+		}
+		records, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+		if err != nil {
+			t.Fatalf("invalid CSV: %v", err)
+		}
+		if len(records) != len(wantOrder)+1 {
+			t.Fatalf("%d CSV record(s), want a header and %d rows", len(records), len(wantOrder))
+		}
+		column := make(map[string]int)
+		for i, name := range records[0] {
+			column[name] = i
+		}
+		for _, name := range []string{"timestamp", "device_id", "temperature", "soil_humidity", exportExtraColumn} {
+			if _, ok := column[name]; !ok {
+				t.Fatalf("header %v is missing %s", records[0], name)
+			}
+		}
+		for i, record := range records[1:] {
+			if record[column["device_id"]] != wantOrder[i] {
+				t.Errorf("row %d device_id = %s, want %s", i, record[column["device_id"]], wantOrder[i])
+			}
+		}
+		if got := records[1][column["temperature"]]; got != "20.5" {
+			t.Errorf("first temperature = %q, want 20.5", got)
+		}
+		if got := records[4][column[exportExtraColumn]]; !strings.Contains(got, `"probe.depth":20`) {
+			t.Errorf("extra column = %q, want the flattened probe.depth", got)
+		}
+	})
+
+	t.Run("any inaccessible device rejects the export", func(t *testing.T) {
+		for _, deviceIDs := range [][]string{{"ws-1", "other-1"}, {"ws-1", "missing"}} {
+			w := export(owner, exportFormatNDJSON, deviceIDs...)
+			if w.Code != http.StatusNotFound {
+				t.Errorf("export %v status = %d, want %d", deviceIDs, w.Code, http.StatusNotFound)
+				continue
+			}
+			body := decodeBody(t, w)
+			if fmt.Sprint(body["details"]) != fmt.Sprint([]interface{}{deviceIDs[1]}) {
+				t.Errorf("export %v details = %v, want only %s", deviceIDs, body["details"], deviceIDs[1])
+			}
+			if strings.Contains(w.Body.String(), "timestamp") {
+				t.Errorf("rejected export streamed data: %s", w.Body.String())
+			}
+		}
+	})
+
+	t.Run("admin can export any device", func(t *testing.T) {
+		w := export(admin, exportFormatNDJSON, "other-1", "ws-1")
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+		}
+		if got := w.Header().Get("X-Export-Rows"); got != "4" {
+			t.Errorf("X-Export-Rows = %q, want 4", got)
+		}
+	})
+
+	t.Run("size limits", func(t *testing.T) {
+		limits := cfg.SensorData
+		defer func() { cfg.SensorData = limits }()
+
+		cfg.SensorData.ExportMaxRows = 4
+		if w := export(owner, exportFormatNDJSON, "ws-1", "soil-1"); w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("status = %d over the row limit, want %d", w.Code, http.StatusUnprocessableEntity)
+		}
+
+		// 超出字节上限时在完整的行边界截断
+		cfg.SensorData.ExportMaxRows = limits.ExportMaxRows
+		cfg.SensorData.ExportMaxBytes = 300
+		w := export(owner, exportFormatNDJSON, "ws-1", "soil-1")
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+		}
+		if w.Body.Len() > 300 || !strings.HasSuffix(w.Body.String(), "\n") {
+			t.Errorf("body = %q (%d bytes), want at most 300 bytes of whole lines", w.Body.String(), w.Body.Len())
+		}
+		rows := strings.Count(w.Body.String(), "\n")
+		if rows == 0 || rows >= len(wantOrder) {
+			t.Errorf("%d row(s) written, want a truncated export", rows)
+		}
+		if got := w.Header().Get("X-Export-Rows"); got != fmt.Sprint(rows) {
+			t.Errorf("X-Export-Rows = %q, want %d", got, rows)
+		}
+		if got := w.Header().Get("X-Export-Truncated"); got != "true" {
+			t.Errorf("X-Export-Truncated = %q, want true", got)
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"gorm.io/gorm"
+	"iot-platform-backend/internal/config"
+	"iot-platform-backend/internal/models"
+)
+
+// 降采样方法
+const (
+	samplingLTTB = "lttb" // 按数值字段的Largest-Triangle-Three-Buckets
+	samplingNth  = "nth"  // 等间隔取点
+)
+
+// HistorySampling 降采样结果说明
+type HistorySampling struct {
+	Method       string `json:"method"`
+	Field        string `json:"field,omitempty"`
+	SourcePoints int64  `json:"source_points"` // 时间范围内的原始数据条数
+	Prescaled    bool   `json:"prescaled"`     // 原始数据过多，已先在数据库中等间隔抽样
+	Points       int    `json:"points"`
+}
+
+// loadDownsampledHistory 读取查询范围内的全部数据（按时间升序），合并派生字段后降采样到最多maxPoints条
+// 范围内的数据超过SENSOR_DATA_DOWNSAMPLE_SCAN_ROWS时，先在SQL中用row_number等间隔抽样，避免将大量数据读入内存
+func loadDownsampledHistory(db, query *gorm.DB, deviceType models.DeviceType, maxPoints int, field string) ([]models.SensorData, HistorySampling, error) {
+	sampling := HistorySampling{Method: samplingNth}
+	if field != "" {
+		sampling.Method = samplingLTTB
+		sampling.Field = field
+	}
+	
+	if err := query.Session(&gorm.Session{}).Model(&models.SensorData{}).Count(&sampling.SourcePoints).Error; err != nil {
+		return nil, sampling, err
+	}
+	
+	var rows []models.SensorData
+	scanRows := config.AppConfig.SensorData.DownsampleScanRows
+	if sampling.SourcePoints > scanRows {
+		step := (sampling.SourcePoints + scanRows - 1) / scanRows
+		numbered := query.Session(&gorm.Session{}).Model(&models.SensorData{}).
+			Select("*, row_number() OVER (ORDER BY " + historyOrderAsc + ") AS sample_rn")
+		err := db.Table("(?) AS sampled", numbered).
+			Where("(sample_rn - 1) % ? = 0", step).
+			Order("sample_rn").
+			Find(&rows).Error
+		if err != nil {
+			return nil, sampling, err
+		}
+		sampling.Prescaled = true
+	} else if err := query.Session(&gorm.Session{}).Order(historyOrderAsc).Find(&rows).Error; err != nil {
+		return nil, sampling, err
+	}
+	
+	// 先合并派生字段，派生字段也可以作为降采样依据
+	enrichReadings(deviceType, rows)
+	if field != "" {
+		rows = models.DownsampleLTTB(rows, field, maxPoints)
+	} else {
+		rows = models.DownsampleNth(rows, maxPoints)
+	}
+	sampling.Points = len(rows)
+	return rows, sampling, nil
+}
+
+// reverseSensorData 原地反转数据顺序
+func reverseSensorData(data []models.SensorData) {
+	for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
+		data[i], data[j] = data[j], data[i]
+	}
+}
@@ -0,0 +1,236 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+)
+
+// 邀请码校验失败的原因，错误信息直接返回给注册用户
+var (
+	errInviteInvalid = errors.New("Invalid invite code")
+	errInviteRevoked = errors.New("Invite code has been revoked")
+	errInviteExpired = errors.New("Invite code has expired")
+	errInviteUsed    = errors.New("Invite code has already been used")
+)
+
+// isInviteError 是否为邀请码校验失败
+func isInviteError(err error) bool {
+	return errors.Is(err, errInviteInvalid) || errors.Is(err, errInviteRevoked) ||
+		errors.Is(err, errInviteExpired) || errors.Is(err, errInviteUsed)
+}
+
+// consumeInvite 在事务中校验并占用一次邀请码
+// 使用条件更新保证并发注册时一次性邀请码只会被使用一次
+func consumeInvite(tx *gorm.DB, code string) error {
+	var invite models.Invite
+	if err := tx.Where("code = ?", code).First(&invite).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errInviteInvalid
+		}
+		return err
+	}
+	
+	now := time.Now()
+	switch {
+	case invite.RevokedAt != nil:
+		return errInviteRevoked
+	case invite.Expired(now):
+		return errInviteExpired
+	case invite.Exhausted():
+		return errInviteUsed
+	}
+	
+	result := tx.Model(&models.Invite{}).
+		Where("id = ? AND revoked_at IS NULL", invite.ID).
+		Where("max_uses = 0 OR use_count < max_uses").
+		Where("expires_at IS NULL OR expires_at > ?", now).
+		UpdateColumn("use_count", gorm.Expr("use_count + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	// 校验之后被其他注册请求用完
+	if result.RowsAffected == 0 {
+		return errInviteUsed
+	}
+	return nil
+}
+
+// InviteController 注册邀请码管理控制器
+type InviteController struct{}
+
+// NewInviteController 创建邀请码控制器
+func NewInviteController() *InviteController {
+	return &InviteController{}
+}
+
+// CreateInviteRequest 创建邀请码请求
+type CreateInviteRequest struct {
+	MaxUses   *int   `json:"max_uses" binding:"omitempty,min=0,max=10000"` // 默认1（一次性），0为不限次数
+	ExpiresIn string `json:"expires_in"`                                   // 有效期，如72h、7d，为空时永不过期
+	Note      string `json:"note" binding:"max=200"`
+}
+
+// InviteListResponse 邀请码列表响应
+type InviteListResponse struct {
+	Invites []models.Invite `json:"invites"`
+	Total   int64           `json:"total"`
+	Page    int             `json:"page"`
+	Limit   int             `json:"limit"`
+}
+
+// CreateInvite 创建注册邀请码
+// @Summary 创建邀请码
+// @Description 创建一次性或多次使用的注册邀请码，可设置有效期
+// @Tags 管理员
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body CreateInviteRequest true "邀请码信息"
+// @Success 201 {object} models.Invite
+// @Failure 400 {object} map[string]interface{}
+// @Router /admin/invites [post]
+func (ctrl *InviteController) CreateInvite(c *gin.Context) {
+	var req CreateInviteRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	
+	invite := models.Invite{
+		MaxUses:   1,
+		Note:      req.Note,
+		CreatedBy: middleware.GetUserID(c),
+	}
+	if req.MaxUses != nil {
+		invite.MaxUses = *req.MaxUses
+	}
+	if req.ExpiresIn != "" {
+		duration, err := parseDayDuration(req.ExpiresIn)
+		if err != nil || duration <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid expires_in",
+			})
+			return
+		}
+		expiresAt := time.Now().Add(duration)
+		invite.ExpiresAt = &expiresAt
+	}
+	
+	raw := make([]byte, 12)
+	if _, err := rand.Read(raw); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to generate invite code",
+		})
+		return
+	}
+	invite.Code = hex.EncodeToString(raw)
+	
+	if err := database.GetDBWithContext(c.Request.Context()).Create(&invite).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create invite",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusCreated, gin.H{
+		"status": 1,
+		"msg":    "邀请码创建成功",
+		"data":   invite,
+	})
+}
+
+// GetInvites 获取邀请码列表
+// @Summary 获取邀请码列表
+// @Tags 管理员
+// @Security BearerAuth
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param limit query int false "每页数量" default(20)
+// @Param active query bool false "仅返回仍可使用的邀请码"
+// @Success 200 {object} InviteListResponse
+// @Router /admin/invites [get]
+func (ctrl *InviteController) GetInvites(c *gin.Context) {
+	pagination := parseListPagination(c)
+	
+	query := database.GetDBWithContext(c.Request.Context()).Model(&models.Invite{})
+	if c.Query("active") == "true" {
+		query = query.Where("revoked_at IS NULL").
+			Where("max_uses = 0 OR use_count < max_uses").
+			Where("expires_at IS NULL OR expires_at > ?", time.Now())
+	}
+	
+	var total int64
+	query.Count(&total)
+	
+	var invites []models.Invite
+	if err := query.Order("created_at DESC").
+		Offset(pagination.Offset).
+		Limit(pagination.Limit).
+		Find(&invites).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch invites",
+		})
+		return
+	}
+	
+	setPaginationHeaders(c, total, pagination.Page, pagination.Limit)
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"data": InviteListResponse{
+			Invites: invites,
+			Total:   total,
+			Page:    pagination.Page,
+			Limit:   pagination.Limit,
+		},
+	})
+}
+
+// RevokeInvite 撤销邀请码，已注册的用户不受影响
+// @Summary 撤销邀请码
+// @Tags 管理员
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "邀请码ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /admin/invites/{id} [delete]
+func (ctrl *InviteController) RevokeInvite(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid invite ID",
+		})
+		return
+	}
+	
+	result := database.GetDBWithContext(c.Request.Context()).
+		Model(&models.Invite{}).
+		Where("id = ? AND revoked_at IS NULL", uint(id)).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to revoke invite",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Invite not found or already revoked",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"msg":    "邀请码已撤销",
+	})
+}
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/testutil"
+)
+
+func TestCreateInviteMaxUses(t *testing.T) {
+	testutil.LoadConfig(t)
+	db := testutil.UseDB(t)
+	admin := createTestUser(t, "admin", func(u *models.User) { u.Role = models.RoleAdmin })
+
+	zero, five := 0, 5
+	tests := []struct {
+		name    string
+		maxUses *int
+		want    int
+	}{
+		{"default is single use", nil, 1},
+		{"zero means unlimited", &zero, 0},
+		{"explicit limit", &five, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := newJSONContext(t, "POST", "/api/v1/admin/invites", CreateInviteRequest{MaxUses: tt.maxUses}, admin)
+			NewInviteController().CreateInvite(c)
+			if w.Code != http.StatusCreated {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+			var body struct {
+				Data models.Invite `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+
+			var stored models.Invite
+			if err := db.First(&stored, body.Data.ID).Error; err != nil {
+				t.Fatalf("load invite: %v", err)
+			}
+			if stored.MaxUses != tt.want {
+				t.Errorf("stored max_uses = %d, want %d", stored.MaxUses, tt.want)
+			}
+		})
+	}
+}
+
+func TestConsumeInvite(t *testing.T) {
+	testutil.LoadConfig(t)
+	db := testutil.UseDB(t)
+
+	past := time.Now().Add(-time.Hour)
+	invites := []models.Invite{
+		{Code: "unlimited", MaxUses: 0, UseCount: 100},
+		{Code: "single", MaxUses: 1},
+		{Code: "used", MaxUses: 1, UseCount: 1},
+		{Code: "expired", MaxUses: 1, ExpiresAt: &past},
+		{Code: "revoked", MaxUses: 1, RevokedAt: &past},
+	}
+	if err := db.Create(&invites).Error; err != nil {
+		t.Fatalf("create invites: %v", err)
+	}
+
+	tests := []struct {
+		code string
+		want error
+	}{
+		{"unlimited", nil},
+		{"single", nil},
+		{"single", errInviteUsed},
+		{"used", errInviteUsed},
+		{"expired", errInviteExpired},
+		{"revoked", errInviteRevoked},
+		{"missing", errInviteInvalid},
+	}
+	for _, tt := range tests {
+		if err := consumeInvite(db, tt.code); !errors.Is(err, tt.want) {
+			t.Errorf("consumeInvite(%q) = %v, want %v", tt.code, err, tt.want)
+		}
+	}
+}
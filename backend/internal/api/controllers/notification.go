@@ -0,0 +1,337 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/websocket"
+)
+
+// NotificationController 通知与公告控制器
+type NotificationController struct{}
+
+// NewNotificationController 创建通知控制器
+func NewNotificationController() *NotificationController {
+	return &NotificationController{}
+}
+
+// BroadcastNotificationRequest 广播通知请求
+type BroadcastNotificationRequest struct {
+	Title   string `json:"title" binding:"required,max=100"`
+	Message string `json:"message" binding:"required,max=2000"`
+	Level   string `json:"level" binding:"omitempty,oneof=info warning critical"`
+	Target  string `json:"target" binding:"required,oneof=all users role"`
+	UserIDs []uint `json:"user_ids" binding:"required_if=Target users,max=1000"`
+	Role    string `json:"role" binding:"required_if=Target role,omitempty,oneof=admin moderator user"`
+	Persist bool   `json:"persist"` // 为离线用户保存，下次连接时推送
+}
+
+// BroadcastNotificationResponse 广播通知结果
+type BroadcastNotificationResponse struct {
+	AnnouncementID uint `json:"announcement_id"`
+	Delivered      int  `json:"delivered"` // 已推送的在线用户数（不含匿名连接）
+	Persisted      int  `json:"persisted"` // 为离线用户保存的条数
+}
+
+// BroadcastNotification 向在线用户广播通知
+// @Summary 广播通知
+// @Description 管理员向全部、指定用户或指定角色的在线连接推送通知，可选为离线用户保存
+// @Tags 管理
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body BroadcastNotificationRequest true "通知内容"
+// @Success 200 {object} BroadcastNotificationResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 429 {object} map[string]interface{}
+// @Router /admin/notifications/broadcast [post]
+func (ctrl *NotificationController) BroadcastNotification(c *gin.Context) {
+	var req BroadcastNotificationRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if req.Level == "" {
+		req.Level = "info"
+	}
+	
+	adminID := middleware.GetUserID(c)
+	announcement := models.Announcement{
+		Title:      req.Title,
+		Message:    req.Message,
+		Level:      req.Level,
+		Target:     req.Target,
+		TargetRole: req.Role,
+		CreatedBy:  adminID,
+	}
+	targetIDs := uniqueIDs(req.UserIDs)
+	for _, id := range targetIDs {
+		announcement.TargetIDs = append(announcement.TargetIDs, int64(id))
+	}
+	
+	online := map[uint]bool{}
+	if websocket.DefaultManager != nil {
+		online = websocket.DefaultManager.OnlineUserIDs()
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	var response BroadcastNotificationResponse
+	err := database.TransactionWithContext(c.Request.Context(), func(tx *gorm.DB) error {
+		if err := tx.Create(&announcement).Error; err != nil {
+			return err
+		}
+		
+		if req.Persist {
+			offline, err := offlineTargets(tx, req.Target, targetIDs, req.Role, online)
+			if err != nil {
+				return err
+			}
+			if len(offline) > 0 {
+				payload := websocket.AnnouncementPayload(announcement)
+				pending := make([]models.Notification, 0, len(offline))
+				for _, userID := range offline {
+					pending = append(pending, models.Notification{
+						UserID:  userID,
+						Type:    "announcement",
+						Payload: payload,
+					})
+				}
+				if err := tx.CreateInBatches(&pending, 500).Error; err != nil {
+					return err
+				}
+			}
+			response.Persisted = len(offline)
+		}
+		
+		audit := models.AuditLog{
+			ActorID:    adminID,
+			Action:     "notification_broadcast",
+			Resource:   "announcement",
+			ResourceID: announcement.ID,
+			Details: models.JSONB{
+				"target":    req.Target,
+				"role":      req.Role,
+				"user_ids":  targetIDs,
+				"level":     req.Level,
+				"persist":   req.Persist,
+				"persisted": response.Persisted,
+			},
+			IPAddress: c.ClientIP(),
+			UserAgent: c.GetHeader("User-Agent"),
+		}
+		return tx.Create(&audit).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to broadcast notification",
+		})
+		return
+	}
+	response.AnnouncementID = announcement.ID
+	
+	// 事务提交后再推送给在线连接
+	if websocket.DefaultManager != nil {
+		message := websocket.NotificationMessage(models.Notification{
+			Type:    "announcement",
+			Payload: websocket.AnnouncementPayload(announcement),
+		})
+		switch req.Target {
+		case websocket.NotifyTargetAll:
+			websocket.DefaultManager.Broadcast(message)
+			response.Delivered = len(online)
+		case websocket.NotifyTargetUsers:
+			for _, userID := range targetIDs {
+				if online[userID] {
+					websocket.DefaultManager.SendToUser(userID, message)
+					response.Delivered++
+				}
+			}
+		case websocket.NotifyTargetRole:
+			websocket.DefaultManager.SendToRole(req.Role, message)
+			var roleOnline int64
+			if len(online) > 0 {
+				onlineIDs := make([]uint, 0, len(online))
+				for userID := range online {
+					onlineIDs = append(onlineIDs, userID)
+				}
+				db.Model(&models.User{}).Where("id IN ? AND role = ?", onlineIDs, req.Role).Count(&roleOnline)
+			}
+			response.Delivered = int(roleOnline)
+		}
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"msg":    "通知已发送",
+		"data":   response,
+	})
+}
+
+// offlineTargets 查询公告目标中当前不在线的活跃用户
+func offlineTargets(tx *gorm.DB, target string, userIDs []uint, role string, online map[uint]bool) ([]uint, error) {
+	query := tx.Model(&models.User{}).Where("active = ?", true)
+	switch target {
+	case websocket.NotifyTargetUsers:
+		query = query.Where("id IN ?", userIDs)
+	case websocket.NotifyTargetRole:
+		query = query.Where("role = ?", role)
+	}
+	
+	var ids []uint
+	if err := query.Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	
+	offline := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !online[id] {
+			offline = append(offline, id)
+		}
+	}
+	return offline, nil
+}
+
+// NotificationListResponse 通知列表响应
+type NotificationListResponse struct {
+	Notifications []models.Notification `json:"notifications"`
+	Total         int64                 `json:"total"`
+	Unread        int64                 `json:"unread"`
+	Page          int                   `json:"page"`
+	Limit         int                   `json:"limit"`
+}
+
+// GetNotifications 获取当前用户的通知
+// @Summary 获取通知列表
+// @Description 分页获取当前用户的站内通知，unread=true时仅返回未读
+// @Tags 通知
+// @Security BearerAuth
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param limit query int false "每页数量" default(10)
+// @Param unread query bool false "仅未读"
+// @Success 200 {object} NotificationListResponse
+// @Router /notifications [get]
+func (ctrl *NotificationController) GetNotifications(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+		})
+		return
+	}
+	
+	pagination := parseListPagination(c)
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	query := db.Model(&models.Notification{}).Where("user_id = ?", userID)
+	if c.Query("unread") == "true" {
+		query = query.Where("read_at IS NULL")
+	}
+	
+	var total, unread int64
+	query.Count(&total)
+	db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&unread)
+	
+	var notifications []models.Notification
+	if err := query.Order("created_at DESC").
+		Offset(pagination.Offset).
+		Limit(pagination.Limit).
+		Find(&notifications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch notifications",
+		})
+		return
+	}
+	
+	setPaginationHeaders(c, total, pagination.Page, pagination.Limit)
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"data": NotificationListResponse{
+			Notifications: notifications,
+			Total:         total,
+			Unread:        unread,
+			Page:          pagination.Page,
+			Limit:         pagination.Limit,
+		},
+	})
+}
+
+// MarkNotificationRead 标记单条通知为已读
+// @Summary 标记通知已读
+// @Tags 通知
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "通知ID"
+// @Success 200 {object} models.Notification
+// @Failure 404 {object} map[string]interface{}
+// @Router /notifications/{id}/read [post]
+func (ctrl *NotificationController) MarkNotificationRead(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	notificationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid notification ID",
+		})
+		return
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	var notification models.Notification
+	if err := db.Where("id = ? AND user_id = ?", uint(notificationID), userID).First(&notification).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Notification not found",
+		})
+		return
+	}
+	
+	// 已读通知保持原有的已读时间
+	if !notification.IsRead() {
+		now := time.Now()
+		if err := db.Model(&notification).Update("read_at", now).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to update notification",
+			})
+			return
+		}
+		notification.ReadAt = &now
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"data":   notification,
+	})
+}
+
+// MarkAllNotificationsRead 将当前用户的所有未读通知标记为已读
+// @Summary 全部标记已读
+// @Tags 通知
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /notifications/read-all [post]
+func (ctrl *NotificationController) MarkAllNotificationsRead(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	result := db.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update notifications",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"msg":    "已全部标记为已读",
+		"data":   gin.H{"updated": result.RowsAffected},
+	})
+}
@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Optional 部分更新请求中的字段，区分缺失（Set=false）、显式null（Set=true, Null=true）和给定值三种状态
+// 指针字段无法区分缺失和null，两者都会解码为nil
+type Optional[T any] struct {
+	Set   bool
+	Null  bool
+	Value T
+}
+
+// UnmarshalJSON 字段出现在请求中时才会被调用，因此调用即表示Set
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		var zero T
+		o.Null, o.Value = true, zero
+		return nil
+	}
+	o.Null = false
+	return json.Unmarshal(data, &o.Value)
+}
+
+// MarshalJSON 缺失或null时输出null
+func (o Optional[T]) MarshalJSON() ([]byte, error) {
+	if !o.Set || o.Null {
+		return []byte("null"), nil
+	}
+	return json.Marshal(o.Value)
+}
+
+// Present 字段是否出现在请求中且不为null
+func (o Optional[T]) Present() bool {
+	return o.Set && !o.Null
+}
@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestOptionalUnmarshal(t *testing.T) {
+	type request struct {
+		Name Optional[string]   `json:"name"`
+		Tags Optional[[]string] `json:"tags"`
+	}
+	tests := []struct {
+		body      string
+		wantSet   bool
+		wantNull  bool
+		wantValue string
+	}{
+		{`{}`, false, false, ""},
+		{`{"name":null}`, true, true, ""},
+		{`{"name":""}`, true, false, ""},
+		{`{"name":"sensor"}`, true, false, "sensor"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.body, func(t *testing.T) {
+			var req request
+			if err := json.Unmarshal([]byte(tt.body), &req); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			if req.Name.Set != tt.wantSet || req.Name.Null != tt.wantNull || req.Name.Value != tt.wantValue {
+				t.Errorf("name = %+v, want set=%v null=%v value=%q", req.Name, tt.wantSet, tt.wantNull, tt.wantValue)
+			}
+			if req.Tags.Set {
+				t.Error("absent tags reported as set")
+			}
+		})
+	}
+
+	var req request
+	if err := json.Unmarshal([]byte(`{"name":1}`), &req); err == nil {
+		t.Error("wrong type accepted")
+	}
+}
+
+func TestOptionalMarshal(t *testing.T) {
+	for _, tt := range []struct {
+		value Optional[int]
+		want  string
+	}{
+		{Optional[int]{}, "null"},
+		{Optional[int]{Set: true, Null: true}, "null"},
+		{Optional[int]{Set: true, Value: 3}, "3"},
+	} {
+		raw, err := json.Marshal(tt.value)
+		if err != nil || string(raw) != tt.want {
+			t.Errorf("Marshal(%+v) = %s, %v, want %s", tt.value, raw, err, tt.want)
+		}
+	}
+}
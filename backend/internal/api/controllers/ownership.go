@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+	
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+)
+
+// DeviceOwner 根据路径参数id（主键或device_id）加载设备拥有者，供OwnerOrAdminRequired使用
+func DeviceOwner(c *gin.Context) (uint, error) {
+	var ownerIDs []uint
+	if err := database.GetDBWithContext(c.Request.Context()).Model(&models.Device{}).Scopes(deviceParam(c, false)).Limit(1).Pluck("owner_id", &ownerIDs).Error; err != nil {
+		return 0, err
+	}
+	if len(ownerIDs) == 0 {
+		return 0, middleware.ErrResourceNotFound
+	}
+	
+	return ownerIDs[0], nil
+}
+
+// DeviceGroupOwner 根据路径参数id加载设备分组拥有者，供OwnerOrAdminRequired使用
+func DeviceGroupOwner(c *gin.Context) (uint, error) {
+	return loadOwnerID(c, &models.DeviceGroup{})
+}
+
+// ProjectOwner 根据路径参数id加载项目拥有者，供OwnerOrAdminRequired使用
+func ProjectOwner(c *gin.Context) (uint, error) {
+	return loadOwnerID(c, &models.Project{})
+}
+
+// loadOwnerID 查询指定模型记录的owner_id
+func loadOwnerID(c *gin.Context, model interface{}) (uint, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return 0, middleware.ErrInvalidResourceID
+	}
+	
+	var ownerIDs []uint
+	if err := database.GetDBWithContext(c.Request.Context()).Model(model).Where("id = ?", uint(id)).Limit(1).Pluck("owner_id", &ownerIDs).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, middleware.ErrResourceNotFound
+		}
+		return 0, err
+	}
+	if len(ownerIDs) == 0 {
+		return 0, middleware.ErrResourceNotFound
+	}
+	
+	return ownerIDs[0], nil
+}
+
+// deviceParam 按路径参数id匹配设备的查询条件，/devices下的路由共用同一个参数名，id可以是主键或device_id
+// 纯数字的id同时匹配两者，与另一台设备的主键相同的device_id由preferDeviceID决定优先级：
+// 数据查询等原先按device_id寻址的路由优先device_id，其余路由优先主键。只用于已认证并校验所有权的路由
+func deviceParam(c *gin.Context, preferDeviceID bool) func(*gorm.DB) *gorm.DB {
+	ref := c.Param("id")
+	return func(db *gorm.DB) *gorm.DB {
+		id, err := strconv.ParseUint(ref, 10, 32)
+		if err != nil {
+			return db.Where("devices.device_id = ?", ref)
+		}
+		
+		preferred := clause.Expr{SQL: "CASE WHEN devices.id = ? THEN 0 ELSE 1 END", Vars: []interface{}{uint(id)}}
+		if preferDeviceID {
+			preferred = clause.Expr{SQL: "CASE WHEN devices.device_id = ? THEN 0 ELSE 1 END", Vars: []interface{}{ref}}
+		}
+		return db.Where("(devices.device_id = ? OR devices.id = ?)", ref, uint(id)).
+			Clauses(clause.OrderBy{Expression: preferred})
+	}
+}
+
+// deviceIDParam 只按device_id匹配路径参数id，用于设备上报等无需用户认证的路由，
+// 避免通过递增主键定位到任意设备
+func deviceIDParam(c *gin.Context) func(*gorm.DB) *gorm.DB {
+	ref := c.Param("id")
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("devices.device_id = ?", ref)
+	}
+}
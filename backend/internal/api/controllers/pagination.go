@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	
+	"github.com/gin-gonic/gin"
+	"iot-platform-backend/internal/config"
+)
+
+// Pagination 分页参数
+type Pagination struct {
+	Page   int
+	Limit  int
+	Offset int
+}
+
+// parsePagination 解析并校验page/limit查询参数
+// 非法或小于1的page视为1，非法或小于1的limit使用defaultLimit，超过maxLimit时截断为maxLimit
+func parsePagination(c *gin.Context, defaultLimit, maxLimit int) Pagination {
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 {
+		limit = defaultLimit
+	}
+	if maxLimit > 0 && limit > maxLimit {
+		limit = maxLimit
+	}
+	
+	return Pagination{
+		Page:   page,
+		Limit:  limit,
+		Offset: (page - 1) * limit,
+	}
+}
+
+// parseListPagination 使用全局配置解析列表接口分页参数
+func parseListPagination(c *gin.Context) Pagination {
+	cfg := config.AppConfig.Pagination
+	return parsePagination(c, cfg.DefaultLimit, cfg.MaxLimit)
+}
+
+// setPaginationHeaders 设置X-Total-Count和RFC5988 Link分页头
+func setPaginationHeaders(c *gin.Context, total int64, page, limit int) {
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	
+	if limit <= 0 {
+		return
+	}
+	
+	lastPage := int((total + int64(limit) - 1) / int64(limit))
+	if lastPage < 1 {
+		lastPage = 1
+	}
+	
+	links := []string{
+		buildPageLink(c, 1, limit, "first"),
+	}
+	if page > 1 {
+		prev := page - 1
+		if prev > lastPage {
+			prev = lastPage
+		}
+		links = append(links, buildPageLink(c, prev, limit, "prev"))
+	}
+	if page < lastPage {
+		links = append(links, buildPageLink(c, page+1, limit, "next"))
+	}
+	links = append(links, buildPageLink(c, lastPage, limit, "last"))
+	
+	c.Header("Link", strings.Join(links, ", "))
+}
+
+// buildPageLink 基于当前请求地址构造指定页的链接
+func buildPageLink(c *gin.Context, page, limit int, rel string) string {
+	query := c.Request.URL.Query()
+	query.Set("page", strconv.Itoa(page))
+	query.Set("limit", strconv.Itoa(limit))
+	
+	return fmt.Sprintf(`<%s?%s>; rel="%s"`, c.Request.URL.Path, query.Encode(), rel)
+}
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePagination(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		wantPage  int
+		wantLimit int
+		wantOff   int
+	}{
+		{"defaults", "", 1, 20, 0},
+		{"explicit", "page=3&limit=10", 3, 10, 20},
+		{"clamped to max", "page=2&limit=5000", 2, 100, 100},
+		{"negative page", "page=-4&limit=10", 1, 10, 0},
+		{"negative limit", "page=2&limit=-1", 2, 20, 20},
+		{"zero values", "page=0&limit=0", 1, 20, 0},
+		{"non-numeric", "page=abc&limit=ten", 1, 20, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestContext("GET", "/items?"+tt.query)
+			p := parsePagination(c, 20, 100)
+			if p.Page != tt.wantPage || p.Limit != tt.wantLimit || p.Offset != tt.wantOff {
+				t.Errorf("parsePagination(%q) = %+v, want page=%d limit=%d offset=%d",
+					tt.query, p, tt.wantPage, tt.wantLimit, tt.wantOff)
+			}
+		})
+	}
+}
+
+func TestSetPaginationHeaders(t *testing.T) {
+	tests := []struct {
+		name      string
+		total     int64
+		page      int
+		wantRels  []string
+		wantLinks []string
+	}{
+		{
+			name:     "middle page",
+			total:    95,
+			page:     3,
+			wantRels: []string{"first", "prev", "next", "last"},
+			wantLinks: []string{
+				`</api/v1/devices/d1/history?limit=10&order=asc&page=1>; rel="first"`,
+				`</api/v1/devices/d1/history?limit=10&order=asc&page=2>; rel="prev"`,
+				`</api/v1/devices/d1/history?limit=10&order=asc&page=4>; rel="next"`,
+				`</api/v1/devices/d1/history?limit=10&order=asc&page=10>; rel="last"`,
+			},
+		},
+		{
+			name:     "first page",
+			total:    25,
+			page:     1,
+			wantRels: []string{"first", "next", "last"},
+		},
+		{
+			name:     "last page",
+			total:    25,
+			page:     3,
+			wantRels: []string{"first", "prev", "last"},
+		},
+		{
+			name:     "empty result",
+			total:    0,
+			page:     1,
+			wantRels: []string{"first", "last"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := newTestContext("GET", "/api/v1/devices/d1/history?order=asc&page=3&limit=10")
+			setPaginationHeaders(c, tt.total, tt.page, 10)
+
+			if got := w.Header().Get("X-Total-Count"); got == "" {
+				t.Fatal("X-Total-Count header missing")
+			}
+			links := strings.Split(w.Header().Get("Link"), ", ")
+			if len(links) != len(tt.wantRels) {
+				t.Fatalf("Link = %v, want rels %v", links, tt.wantRels)
+			}
+			for i, rel := range tt.wantRels {
+				if !strings.HasSuffix(links[i], `rel="`+rel+`"`) {
+					t.Errorf("link %d = %q, want rel %q", i, links[i], rel)
+				}
+			}
+			for i, want := range tt.wantLinks {
+				if links[i] != want {
+					t.Errorf("link %d = %q, want %q", i, links[i], want)
+				}
+			}
+		})
+	}
+}
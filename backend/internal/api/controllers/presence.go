@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	
+	"github.com/gin-gonic/gin"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/websocket"
+)
+
+// PresenceController 在线连接控制器
+type PresenceController struct{}
+
+// NewPresenceController 创建在线连接控制器
+func NewPresenceController() *PresenceController {
+	return &PresenceController{}
+}
+
+// GetPresence 获取WebSocket在线连接列表
+// @Summary 获取WebSocket在线连接
+// @Description 列出当前的WebSocket连接（用户、连接时间、订阅数、客户端地址），按连接时间从早到晚排序，用于排查泄漏或滥用的连接。
+// @Description user_id=0查询匿名连接；租户内的管理员只能看到本租户用户的连接
+// @Tags 管理
+// @Security BearerAuth
+// @Produce json
+// @Param user_id query int false "按用户ID过滤"
+// @Param page query int false "页码"
+// @Param limit query int false "每页数量"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 503 {object} map[string]interface{}
+// @Router /admin/presence [get]
+func (ctrl *PresenceController) GetPresence(c *gin.Context) {
+	if websocket.DefaultManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "WebSocket manager not initialized",
+		})
+		return
+	}
+	
+	var clients []websocket.ClientInfo
+	if raw := c.Query("user_id"); raw != "" {
+		userID, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid user ID",
+			})
+			return
+		}
+		clients = websocket.DefaultManager.UserSnapshot(uint(userID))
+	} else {
+		clients = websocket.DefaultManager.Snapshot()
+	}
+	
+	if tenantID := middleware.GetTenantID(c); tenantID != "" {
+		filtered, err := filterTenantClients(c, tenantID, clients)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to load connections",
+			})
+			return
+		}
+		clients = filtered
+	}
+	
+	pagination := parseListPagination(c)
+	total := len(clients)
+	start := pagination.Offset
+	if start > total {
+		start = total
+	}
+	end := start + pagination.Limit
+	if end > total {
+		end = total
+	}
+	
+	setPaginationHeaders(c, int64(total), pagination.Page, pagination.Limit)
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"data":   clients[start:end],
+	})
+}
+
+// filterTenantClients 只保留属于指定租户用户的连接，匿名连接不属于任何租户
+func filterTenantClients(c *gin.Context, tenantID string, clients []websocket.ClientInfo) ([]websocket.ClientInfo, error) {
+	userIDs := make([]uint, 0, len(clients))
+	for _, client := range clients {
+		if client.UserID != 0 {
+			userIDs = append(userIDs, client.UserID)
+		}
+	}
+	if len(userIDs) == 0 {
+		return []websocket.ClientInfo{}, nil
+	}
+	
+	var tenantUserIDs []uint
+	if err := database.GetDBWithContext(c.Request.Context()).
+		Table("users").
+		Where("id IN ? AND tenant_id = ?", userIDs, tenantID).
+		Pluck("id", &tenantUserIDs).Error; err != nil {
+		return nil, err
+	}
+	
+	inTenant := make(map[uint]bool, len(tenantUserIDs))
+	for _, id := range tenantUserIDs {
+		inTenant[id] = true
+	}
+	filtered := make([]websocket.ClientInfo, 0, len(clients))
+	for _, client := range clients {
+		if inTenant[client.UserID] {
+			filtered = append(filtered, client)
+		}
+	}
+	return filtered, nil
+}
@@ -1,17 +1,26 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
 	"strconv"
-	"time"
+	"strings"
 	
 	"github.com/gin-gonic/gin"
+	"iot-platform-backend/internal/config"
 	"iot-platform-backend/internal/database"
 	"iot-platform-backend/internal/middleware"
 	"iot-platform-backend/internal/models"
 	"github.com/lib/pq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// jsonPatchContentType RFC 6902 JSON Patch请求体的Content-Type
+const jsonPatchContentType = "application/json-patch+json"
+
 // ProjectController 项目控制器
 type ProjectController struct{}
 
@@ -32,10 +41,11 @@ type CreateProjectRequest struct {
 // UpdateProjectRequest 更新项目请求
 type UpdateProjectRequest struct {
 	Name        string       `json:"name"`
-	Description string       `json:"description"`
+	Description Optional[string] `json:"description" swaggertype:"string"` // 缺失时不修改，null或空字符串清空描述
 	Config      models.JSONB `json:"config"`
-	Public      bool         `json:"public"`
+	Public      Optional[bool]   `json:"public" swaggertype:"boolean"` // 缺失时不修改，null设为不公开
 	Tags        []string     `json:"tags"`
+	Version     *int         `json:"version"` // 客户端编辑时基于的版本号，也可通过If-Match头传递
 }
 
 // ForkProjectRequest Fork项目请求
@@ -64,35 +74,67 @@ type ProjectListResponse struct {
 // @Param limit query int false "每页数量" default(10)
 // @Param public query bool false "是否只显示公开项目"
 // @Param tag query string false "标签筛选"
+// @Param sort query string false "排序字段（created_at、updated_at、name、star_count、fork_count、view_count），前缀-表示降序；默认由PROJECT_DEFAULT_SORT配置"
+// @Param forked query bool false "true只显示Fork出来的项目，false只显示原创项目"
+// @Param has_forks query bool false "true只显示被Fork过的项目，false只显示未被Fork过的项目"
+// @Param parent_id query int false "只显示该项目的直接Fork（仍按可见性过滤）"
 // @Success 200 {object} ProjectListResponse
+// @Failure 400 {object} map[string]interface{}
 // @Router /projects [get]
 func (ctrl *ProjectController) GetProjects(c *gin.Context) {
-	userID := middleware.GetUserID(c)
-	isAdmin := middleware.IsAdmin(c)
-	
 	// 解析分页参数
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	if page < 1 {
-		page = 1
-	}
-	if limit < 1 || limit > 100 {
-		limit = 10
+	pagination := parseListPagination(c)
+	page, limit, offset := pagination.Page, pagination.Limit, pagination.Offset
+	
+	sort := models.DefaultProjectSort()
+	if raw := c.Query("sort"); raw != "" {
+		parsed, err := models.ParseProjectSort(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid sort",
+				"details": err.Error(),
+			})
+			return
+		}
+		sort = parsed
 	}
 	
-	offset := (page - 1) * limit
+	forks, err := parseProjectForkFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid fork filter",
+			"details": err.Error(),
+		})
+		return
+	}
 	
 	// 构建查询
-	db := database.GetDB()
+	db := database.GetDBWithContext(c.Request.Context())
 	query := db.Model(&models.Project{}).Preload("Owner")
 	
 	// 筛选条件
 	publicOnly := c.Query("public") == "true"
+	
+	// 公开项目列表与用户无关且访问频繁，按查询条件短时间缓存
+	var cacheKey string
+	if publicOnly && database.RedisClient != nil {
+		cacheKey = publicProjectsCacheKey(c, page, limit, c.Query("tag"), c.Query("search"), sort.String(), forks)
+		var cached ProjectListResponse
+		if err := database.NewCache().Get(c, cacheKey, &cached); err == nil {
+			setPaginationHeaders(c, cached.Total, page, limit)
+			c.JSON(http.StatusOK, gin.H{
+				"status": 1,
+				"data":   cached,
+			})
+			return
+		}
+	}
+	
 	if publicOnly {
 		query = query.Where("public = ?", true)
-	} else if !isAdmin {
-		// 非管理员只能看到自己的项目或公开项目
-		query = query.Where("owner_id = ? OR public = ?", userID, true)
+	} else {
+		// 非管理员只能看到自己的项目或公开项目，租户管理员只能看到本租户的项目
+		query = query.Scopes(visibleProjectsScope(c))
 	}
 	
 	// 标签筛选
@@ -102,9 +144,12 @@ func (ctrl *ProjectController) GetProjects(c *gin.Context) {
 	
 	// 关键词搜索
 	if search := c.Query("search"); search != "" {
-		query = query.Where("name ILIKE ? OR description ILIKE ?", "%"+search+"%", "%"+search+"%")
+		query = query.Scopes(projectSearchScope(search))
 	}
 	
+	// Fork关系筛选
+	query = query.Scopes(forks.scope)
+	
 	var total int64
 	var projects []models.Project
 	
@@ -112,7 +157,7 @@ func (ctrl *ProjectController) GetProjects(c *gin.Context) {
 	query.Count(&total)
 	
 	// 获取项目列表
-	if err := query.Offset(offset).Limit(limit).Order("created_at DESC").Find(&projects).Error; err != nil {
+	if err := query.Offset(offset).Limit(limit).Order(sort.OrderClause()).Find(&projects).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to fetch projects",
 		})
@@ -125,6 +170,11 @@ func (ctrl *ProjectController) GetProjects(c *gin.Context) {
 		Page:     page,
 		Limit:    limit,
 	}
+	if cacheKey != "" {
+		database.NewCache().Set(c, cacheKey, response, PublicProjectsCacheTTL)
+	}
+	
+	setPaginationHeaders(c, total, page, limit)
 	
 	c.JSON(http.StatusOK, gin.H{
 		"status": 1,
@@ -134,12 +184,15 @@ func (ctrl *ProjectController) GetProjects(c *gin.Context) {
 
 // GetProject 获取项目详情
 // @Summary 获取项目详情
-// @Description 根据ID获取项目的详细信息
+// @Description 根据ID获取项目的详细信息。下游Fork只返回数量child_count，列表通过GET /projects/{id}/forks分页获取。
+// @Description 响应带有ETag，请求头If-None-Match与之匹配时返回304
 // @Tags 项目管理
 // @Security BearerAuth
 // @Produce json
 // @Param id path int true "项目ID"
+// @Param If-None-Match header string false "上次响应的ETag"
 // @Success 200 {object} models.Project
+// @Success 304 "项目未修改"
 // @Failure 404 {object} map[string]interface{}
 // @Router /projects/{id} [get]
 func (ctrl *ProjectController) GetProject(c *gin.Context) {
@@ -152,11 +205,11 @@ func (ctrl *ProjectController) GetProject(c *gin.Context) {
 		return
 	}
 	
-	db := database.GetDB()
+	db := database.GetDBWithContext(c.Request.Context())
 	var project models.Project
 	
 	// 查询项目（包含关联数据）
-	query := db.Preload("Owner").Preload("Parent").Preload("Children")
+	query := db.Preload("Owner").Preload("Parent")
 	if err := query.First(&project, uint(projectID)).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": "Project not found",
@@ -164,8 +217,8 @@ func (ctrl *ProjectController) GetProject(c *gin.Context) {
 		return
 	}
 	
-	// 权限检查：只有项目拥有者、管理员或公开项目才能访问
-	if project.OwnerID != userID && !project.Public && !middleware.IsAdmin(c) {
+	// 权限检查：公开项目，或项目拥有者、管理员、协作者才能访问
+	if !canViewProject(projectRole(c, db, &project), &project) {
 		c.JSON(http.StatusForbidden, gin.H{
 			"error": "Access denied",
 		})
@@ -174,11 +227,25 @@ func (ctrl *ProjectController) GetProject(c *gin.Context) {
 	
 	// 增加查看次数
 	if project.OwnerID != userID { // 不统计自己查看自己项目的次数
-		go func() {
-			db.Model(&project).Update("view_count", project.ViewCount+1)
-		}()
+		// 浏览次数先计入Redis缓冲区，由对账任务批量写回，避免每次浏览都更新项目行
+		database.BufferProjectView(c, project.ID)
+		database.RecordProjectView(c, project.ID)
 	}
 	
+	// 条件请求命中时仍计入浏览次数，客户端只是复用了缓存的内容
+	if CheckNotModified(c, ProjectETag(&project)) {
+		return
+	}
+	
+	var childCount int64
+	if err := db.Model(&models.Project{}).Where("parent_id = ?", project.ID).Count(&childCount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to count forks",
+		})
+		return
+	}
+	project.ChildCount = &childCount
+	
 	c.JSON(http.StatusOK, gin.H{
 		"status": 1,
 		"data":   project,
@@ -206,11 +273,12 @@ func (ctrl *ProjectController) CreateProject(c *gin.Context) {
 	}
 	
 	var req CreateProjectRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request format",
-			"details": err.Error(),
-		})
+	if !bindJSON(c, &req) {
+		return
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	if !checkProjectQuota(c, db, userID) {
 		return
 	}
 	
@@ -224,13 +292,15 @@ func (ctrl *ProjectController) CreateProject(c *gin.Context) {
 		OwnerID:     userID,
 	}
 	
-	db := database.GetDB()
 	if err := db.Create(&project).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to create project",
 		})
 		return
 	}
+	if project.Public {
+		invalidatePublicProjects(c)
+	}
 	
 	// 记录创建历史
 	history := models.ForkHistory{
@@ -255,15 +325,19 @@ func (ctrl *ProjectController) CreateProject(c *gin.Context) {
 
 // UpdateProject 更新项目
 // @Summary 更新项目
-// @Description 更新项目信息
+// @Description 更新项目信息，未提供的description、public保持不变（空字符串清空描述）；Content-Type为application/json-patch+json时请求体为RFC 6902补丁数组，仅修改项目配置
 // @Tags 项目管理
 // @Security BearerAuth
 // @Accept json
+// @Accept json-patch+json
 // @Produce json
 // @Param id path int true "项目ID"
 // @Param request body UpdateProjectRequest true "更新信息"
+// @Param If-Match header string false "项目版本号"
 // @Success 200 {object} models.Project
 // @Failure 400 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{} "版本冲突或补丁test操作不匹配"
+// @Failure 422 {object} map[string]interface{} "补丁操作无法执行"
 // @Router /projects/{id} [put]
 func (ctrl *ProjectController) UpdateProject(c *gin.Context) {
 	userID := middleware.GetUserID(c)
@@ -275,16 +349,25 @@ func (ctrl *ProjectController) UpdateProject(c *gin.Context) {
 		return
 	}
 	
+	// application/json-patch+json请求体为RFC 6902补丁，只修改项目配置
+	patchRequest := c.ContentType() == jsonPatchContentType
 	var req UpdateProjectRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request format",
-			"details": err.Error(),
-		})
+	var patch []models.PatchOperation
+	if patchRequest {
+		if !bindJSON(c, &patch) {
+			return
+		}
+		if len(patch) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Patch must contain at least one operation",
+			})
+			return
+		}
+	} else if !bindJSON(c, &req) {
 		return
 	}
 	
-	db := database.GetDB()
+	db := database.GetDBWithContext(c.Request.Context())
 	var project models.Project
 	
 	// 查询项目并验证所有权
@@ -295,35 +378,99 @@ func (ctrl *ProjectController) UpdateProject(c *gin.Context) {
 		return
 	}
 	
-	if project.OwnerID != userID && !middleware.IsAdmin(c) {
-		c.JSON(http.StatusForbidden, gin.H{
-			"error": "Access denied",
+	// 拥有者、管理员和编辑者可以修改，查看者只读
+	role := projectRole(c, db, &project)
+	if !canEditProject(role) {
+		status := http.StatusForbidden
+		if !canViewProject(role, &project) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": "Access denied: editor role required",
 		})
 		return
 	}
 	
+	// 确定客户端的基准版本号
+	baseVersion, ok := resolveBaseVersion(c, req.Version, project.Version)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid If-Match header",
+		})
+		return
+	}
+	if baseVersion != project.Version {
+		respondVersionConflict(c, &project)
+		return
+	}
+	
 	// 保存更新前的配置（用于diff）
 	oldConfig := project.Config
 	
-	// 更新项目信息
-	if req.Name != "" {
-		project.Name = req.Name
-	}
-	project.Description = req.Description
-	if req.Config != nil {
-		project.Config = req.Config
-	}
-	project.Public = req.Public
-	if req.Tags != nil {
-		project.Tags = pq.StringArray(req.Tags)
+	if patchRequest {
+		// 补丁整体生效或整体失败，test操作不匹配视为并发冲突
+		patched, err := models.ApplyPatch(project.Config, patch)
+		if err != nil {
+			status := http.StatusUnprocessableEntity
+			if errors.Is(err, models.ErrPatchTestFailed) {
+				status = http.StatusConflict
+			}
+			c.JSON(status, gin.H{
+				"error":   "Failed to apply patch",
+				"details": err.Error(),
+			})
+			return
+		}
+		project.Config = patched
+	} else {
+		// 更新项目信息
+		if req.Name != "" {
+			project.Name = req.Name
+		}
+		if req.Description.Set {
+			project.Description = req.Description.Value
+		}
+		if req.Config != nil {
+			project.Config = req.Config
+		}
+		// 可见性只能由拥有者或管理员修改
+		if req.Public.Set && role != projectRoleEditor {
+			project.Public = req.Public.Value
+		}
+		if req.Tags != nil {
+			project.Tags = pq.StringArray(req.Tags)
+		}
 	}
 	
-	if err := db.Save(&project).Error; err != nil {
+	// 仅当版本号未被其他人修改时才保存
+	result := db.Model(&models.Project{}).
+		Where("id = ? AND version = ?", project.ID, baseVersion).
+		Updates(map[string]interface{}{
+			"name":        project.Name,
+			"description": project.Description,
+			"config":      project.Config,
+			"public":      project.Public,
+			"tags":        project.Tags,
+			"version":     gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to update project",
 		})
 		return
 	}
+	if result.RowsAffected == 0 {
+		var current models.Project
+		if err := db.First(&current, project.ID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Project not found",
+			})
+			return
+		}
+		respondVersionConflict(c, &current)
+		return
+	}
+	project.Version = baseVersion + 1
 	
 	// 记录更新历史
 	configDiff := make(models.JSONB)
@@ -344,6 +491,7 @@ func (ctrl *ProjectController) UpdateProject(c *gin.Context) {
 	// 清除缓存
 	cache := database.NewCache()
 	cache.Delete(c, database.Keys.Project(project.ID))
+	invalidatePublicProjects(c)
 	
 	c.JSON(http.StatusOK, gin.H{
 		"status": 1,
@@ -352,6 +500,34 @@ func (ctrl *ProjectController) UpdateProject(c *gin.Context) {
 	})
 }
 
+// resolveBaseVersion 从请求体version字段或If-Match头解析客户端基准版本，均未提供时使用当前版本
+func resolveBaseVersion(c *gin.Context, bodyVersion *int, currentVersion int) (int, bool) {
+	if bodyVersion != nil {
+		return *bodyVersion, true
+	}
+	
+	ifMatch := strings.TrimSpace(c.GetHeader("If-Match"))
+	if ifMatch == "" {
+		return currentVersion, true
+	}
+	
+	ifMatch = strings.TrimPrefix(ifMatch, "W/")
+	version, err := strconv.Atoi(strings.Trim(ifMatch, `"`))
+	if err != nil {
+		return 0, false
+	}
+	return version, true
+}
+
+// respondVersionConflict 返回版本冲突响应，附带当前配置以便客户端重新合并
+func respondVersionConflict(c *gin.Context, current *models.Project) {
+	c.JSON(http.StatusConflict, gin.H{
+		"error":           "Project has been modified by another user",
+		"current_version": current.Version,
+		"data":            current,
+	})
+}
+
 // DeleteProject 删除项目
 // @Summary 删除项目
 // @Description 删除指定项目
@@ -363,7 +539,6 @@ func (ctrl *ProjectController) UpdateProject(c *gin.Context) {
 // @Failure 404 {object} map[string]interface{}
 // @Router /projects/{id} [delete]
 func (ctrl *ProjectController) DeleteProject(c *gin.Context) {
-	userID := middleware.GetUserID(c)
 	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -372,7 +547,7 @@ func (ctrl *ProjectController) DeleteProject(c *gin.Context) {
 		return
 	}
 	
-	db := database.GetDB()
+	db := database.GetDBWithContext(c.Request.Context())
 	var project models.Project
 	
 	// 查询项目并验证所有权
@@ -383,15 +558,8 @@ func (ctrl *ProjectController) DeleteProject(c *gin.Context) {
 		return
 	}
 	
-	if project.OwnerID != userID && !middleware.IsAdmin(c) {
-		c.JSON(http.StatusForbidden, gin.H{
-			"error": "Access denied",
-		})
-		return
-	}
-	
-	// 在事务中删除项目及相关数据
-	err = database.Transaction(func(tx *database.DB) error {
+	// 在事务中删除项目及相关数据（所有权已由OwnerOrAdminRequired校验）
+	err = database.TransactionWithContext(c.Request.Context(), func(tx *gorm.DB) error {
 		// 删除Fork历史记录
 		tx.Where("project_id = ?", project.ID).Delete(&models.ForkHistory{})
 		
@@ -401,6 +569,14 @@ func (ctrl *ProjectController) DeleteProject(c *gin.Context) {
 		// 删除Fork记录
 		tx.Where("project_id = ?", project.ID).Delete(&models.Fork{})
 		
+		// 删除协作者
+		tx.Where("project_id = ?", project.ID).Delete(&models.ProjectCollaborator{})
+		
+		// 下游Fork与已删除的上游解除关联
+		if err := tx.Model(&models.Project{}).Where("parent_id = ?", project.ID).Update("parent_id", nil).Error; err != nil {
+			return err
+		}
+		
 		// 删除项目
 		return tx.Delete(&project).Error
 	})
@@ -411,6 +587,7 @@ func (ctrl *ProjectController) DeleteProject(c *gin.Context) {
 		})
 		return
 	}
+	invalidatePublicProjects(c)
 	
 	c.JSON(http.StatusOK, gin.H{
 		"status": 1,
@@ -429,6 +606,8 @@ func (ctrl *ProjectController) DeleteProject(c *gin.Context) {
 // @Param request body ForkProjectRequest true "Fork信息"
 // @Success 201 {object} models.Project
 // @Failure 400 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{} "已Fork过该项目或超出PROJECT_MAX_FORK_DEPTH"
+// @Failure 429 {object} map[string]interface{} "超出PROJECT_FORK_RATE_LIMIT"
 // @Router /projects/{id}/fork [post]
 func (ctrl *ProjectController) ForkProject(c *gin.Context) {
 	userID := middleware.GetUserID(c)
@@ -448,15 +627,11 @@ func (ctrl *ProjectController) ForkProject(c *gin.Context) {
 	}
 	
 	var req ForkProjectRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request format",
-			"details": err.Error(),
-		})
+	if !bindJSON(c, &req) {
 		return
 	}
 	
-	db := database.GetDB()
+	db := database.GetDBWithContext(c.Request.Context())
 	var sourceProject models.Project
 	
 	// 查询源项目
@@ -468,7 +643,7 @@ func (ctrl *ProjectController) ForkProject(c *gin.Context) {
 	}
 	
 	// 权限检查：只有公开项目或拥有者才能Fork
-	if !sourceProject.Public && sourceProject.OwnerID != userID && !middleware.IsAdmin(c) {
+	if !sourceProject.Public && sourceProject.OwnerID != userID && !middleware.IsAdminOf(c, sourceProject.OwnerID) {
 		c.JSON(http.StatusForbidden, gin.H{
 			"error": "Cannot fork private project",
 		})
@@ -485,6 +660,33 @@ func (ctrl *ProjectController) ForkProject(c *gin.Context) {
 		return
 	}
 	
+	// Fork链过深时拒绝，避免谱系查询代价过高；顺带防御父指针成环
+	maxDepth := config.AppConfig.Projects.MaxForkDepth
+	depth, cycle, err := forkDepth(db, sourceProject.ID, maxDepth)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to check fork lineage",
+		})
+		return
+	}
+	if cycle {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Fork lineage of the source project contains a cycle",
+		})
+		return
+	}
+	if maxDepth > 0 && depth+1 > maxDepth {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Fork depth limit exceeded",
+			"details": fmt.Sprintf("forks can be at most %d levels deep; fork an ancestor of this project instead", maxDepth),
+		})
+		return
+	}
+	
+	if !checkProjectQuota(c, db, userID) {
+		return
+	}
+	
 	// 创建Fork项目
 	var forkConfig models.JSONB
 	if req.Config != nil {
@@ -503,7 +705,7 @@ func (ctrl *ProjectController) ForkProject(c *gin.Context) {
 		OwnerID:     userID,
 	}
 	
-	err = database.Transaction(func(tx *database.DB) error {
+	err = database.TransactionWithContext(c.Request.Context(), func(tx *gorm.DB) error {
 		// 创建Fork项目
 		if err := tx.Create(&forkProject).Error; err != nil {
 			return err
@@ -539,6 +741,17 @@ func (ctrl *ProjectController) ForkProject(c *gin.Context) {
 		return tx.Create(&history).Error
 	})
 	
+	// 并发请求绕过了上面的检查时由唯一索引兜底，返回已存在的Fork
+	if database.IsDuplicateKeyError(err) {
+		if db.Where("parent_id = ? AND owner_id = ?", sourceProject.ID, userID).First(&existingFork).Error == nil {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "You have already forked this project",
+				"data":  existingFork,
+			})
+			return
+		}
+	}
+	
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to fork project",
@@ -556,14 +769,42 @@ func (ctrl *ProjectController) ForkProject(c *gin.Context) {
 	})
 }
 
+// maxForkLineageWalk 不限制Fork深度时向上查找祖先的最大层数，仅用于检测成环
+const maxForkLineageWalk = 1000
+
+// forkDepth 沿parent_id向上查找项目的Fork深度（原始项目为0），最多查找limit层（0表示maxForkLineageWalk层）
+// 祖先链中出现重复项目时cycle为true
+func forkDepth(db *gorm.DB, projectID uint, limit int) (int, bool, error) {
+	if limit <= 0 {
+		limit = maxForkLineageWalk
+	}
+	
+	var result struct {
+		Depth int
+		Cycle bool
+	}
+	err := db.Raw(`WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id, 0 AS depth, ARRAY[id] AS path, false AS cycle
+			FROM projects WHERE id = ?
+			UNION ALL
+			SELECT p.id, p.parent_id, a.depth + 1, a.path || p.id, p.id = ANY(a.path)
+			FROM projects p JOIN ancestors a ON p.id = a.parent_id
+			WHERE NOT a.cycle AND a.depth < ?
+		)
+		SELECT COALESCE(MAX(depth), 0) AS depth, COALESCE(BOOL_OR(cycle), false) AS cycle FROM ancestors`,
+		projectID, limit).Scan(&result).Error
+	return result.Depth, result.Cycle, err
+}
+
 // StarProject 给项目点赞
 // @Summary 给项目点赞/取消点赞
-// @Description 给项目点赞或取消点赞
+// @Description 给项目点赞或取消点赞。按用户限流，且同一项目的两次切换须间隔PROJECT_STAR_TOGGLE_COOLDOWN
 // @Tags 项目管理
 // @Security BearerAuth
 // @Produce json
 // @Param id path int true "项目ID"
 // @Success 200 {object} map[string]interface{}
+// @Failure 429 {object} map[string]interface{} "操作过于频繁"
 // @Router /projects/{id}/star [post]
 func (ctrl *ProjectController) StarProject(c *gin.Context) {
 	userID := middleware.GetUserID(c)
@@ -582,7 +823,7 @@ func (ctrl *ProjectController) StarProject(c *gin.Context) {
 		return
 	}
 	
-	db := database.GetDB()
+	db := database.GetDBWithContext(c.Request.Context())
 	var project models.Project
 	
 	// 验证项目存在且有权访问
@@ -593,55 +834,83 @@ func (ctrl *ProjectController) StarProject(c *gin.Context) {
 		return
 	}
 	
-	if !project.Public && project.OwnerID != userID && !middleware.IsAdmin(c) {
+	if !project.Public && project.OwnerID != userID && !middleware.IsAdminOf(c, project.OwnerID) {
 		c.JSON(http.StatusForbidden, gin.H{
 			"error": "Access denied",
 		})
 		return
 	}
 	
-	// 检查是否已经点赞
-	var existingStar models.ProjectStar
-	if err := db.Where("project_id = ? AND user_id = ?", project.ID, userID).First(&existingStar).Error; err == nil {
-		// 已经点赞，取消点赞
-		db.Delete(&existingStar)
-		project.StarCount--
-		db.Save(&project)
-		
-		c.JSON(http.StatusOK, gin.H{
-			"status":  1,
-			"msg":     "取消点赞成功",
-			"starred": false,
-			"count":   project.StarCount,
-		})
-		return
+	// 同一项目的点赞/取消点赞须间隔一段时间，防止反复切换刷计数
+	if cooldown := config.AppConfig.Projects.StarToggleCooldown; cooldown > 0 && database.RedisClient != nil {
+		acquired, err := database.NewCache().SetNX(c, database.Keys.StarCooldown(userID, project.ID), 1, cooldown)
+		if err == nil && !acquired {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(cooldown.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error": "Star toggled too frequently",
+			})
+			return
+		}
 	}
 	
-	// 创建点赞记录
-	star := models.ProjectStar{
-		ProjectID: project.ID,
-		UserID:    userID,
+	// 点赞记录和计数在同一事务中修改，任一步失败都整体回滚
+	starred, err := toggleProjectStar(c, project.ID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update star",
+		})
+		return
 	}
 	
-	if err := db.Create(&star).Error; err != nil {
+	// 以数据库中的值为准，并发点赞时不依赖本地计数
+	var count int
+	if err := db.Model(&models.Project{}).Where("id = ?", project.ID).Pluck("star_count", &count).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to star project",
+			"error": "Failed to fetch star count",
 		})
 		return
 	}
 	
-	// 增加点赞数
-	project.StarCount++
-	db.Save(&project)
-	
+	msg := "点赞成功"
+	if !starred {
+		msg = "取消点赞成功"
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"status":  1,
-		"msg":     "点赞成功",
-		"starred": true,
-		"count":   project.StarCount,
+		"msg":     msg,
+		"starred": starred,
+		"count":   count,
 	})
 }
 
+// toggleProjectStar 切换用户对项目的点赞状态，返回操作后是否为已点赞
+// 删除成功表示原先已点赞；否则插入点赞记录，并发的重复插入由唯一索引忽略且不重复计数
+func toggleProjectStar(c *gin.Context, projectID, userID uint) (bool, error) {
+	starred := false
+	err := database.TransactionWithContext(c.Request.Context(), func(tx *gorm.DB) error {
+		result := tx.Where("project_id = ? AND user_id = ?", projectID, userID).Delete(&models.ProjectStar{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			return tx.Model(&models.Project{}).
+				Where("id = ?", projectID).
+				UpdateColumn("star_count", gorm.Expr("GREATEST(star_count - 1, 0)")).Error
+		}
+		
+		starred = true
+		star := models.ProjectStar{ProjectID: projectID, UserID: userID}
+		result = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&star)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return tx.Model(&models.Project{}).
+			Where("id = ?", projectID).
+			UpdateColumn("star_count", gorm.Expr("star_count + 1")).Error
+	})
+	return starred, err
+}
+
 // GetProjectHistory 获取项目历史记录
 // @Summary 获取项目历史记录
 // @Description 获取项目的操作历史记录
@@ -649,10 +918,11 @@ func (ctrl *ProjectController) StarProject(c *gin.Context) {
 // @Security BearerAuth
 // @Produce json
 // @Param id path int true "项目ID"
+// @Param page query int false "页码" default(1)
+// @Param limit query int false "每页数量" default(50)
 // @Success 200 {object} []models.ForkHistory
 // @Router /projects/{id}/history [get]
 func (ctrl *ProjectController) GetProjectHistory(c *gin.Context) {
-	userID := middleware.GetUserID(c)
 	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -661,7 +931,7 @@ func (ctrl *ProjectController) GetProjectHistory(c *gin.Context) {
 		return
 	}
 	
-	db := database.GetDB()
+	db := database.GetDBWithContext(c.Request.Context())
 	var project models.Project
 	
 	// 验证项目存在且有权访问
@@ -672,17 +942,26 @@ func (ctrl *ProjectController) GetProjectHistory(c *gin.Context) {
 		return
 	}
 	
-	if project.OwnerID != userID && !middleware.IsAdmin(c) {
+	if projectRole(c, db, &project) == projectRoleNone {
 		c.JSON(http.StatusForbidden, gin.H{
 			"error": "Access denied",
 		})
 		return
 	}
 	
+	// 解析分页参数
+	pagination := parsePagination(c, 50, config.AppConfig.Pagination.MaxLimit)
+	page, limit := pagination.Page, pagination.Limit
+	
+	var total int64
+	db.Model(&models.ForkHistory{}).Where("project_id = ?", project.ID).Count(&total)
+	
 	var history []models.ForkHistory
 	if err := db.Where("project_id = ?", project.ID).
 		Preload("User").
 		Order("created_at DESC").
+		Offset(pagination.Offset).
+		Limit(limit).
 		Find(&history).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to fetch project history",
@@ -690,6 +969,8 @@ func (ctrl *ProjectController) GetProjectHistory(c *gin.Context) {
 		return
 	}
 	
+	setPaginationHeaders(c, total, page, limit)
+	
 	c.JSON(http.StatusOK, gin.H{
 		"status": 1,
 		"data":   history,
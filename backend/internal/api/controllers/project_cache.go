@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+	
+	"iot-platform-backend/internal/database"
+)
+
+// PublicProjectsCacheTTL 公开项目列表缓存时长，浏览数、Star数等计数在此期间可能略有滞后
+const PublicProjectsCacheTTL = 30 * time.Second
+
+// publicProjectsCacheKey 按当前缓存代数和查询条件生成公开项目列表的缓存键
+func publicProjectsCacheKey(ctx context.Context, page, limit int, tag, search, sort string, forks projectForkFilter) string {
+	query := url.Values{}
+	query.Set("page", strconv.Itoa(page))
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("tag", tag)
+	query.Set("search", search)
+	query.Set("sort", sort)
+	forks.encode(query)
+	return database.Keys.PublicProjects(publicProjectsGeneration(ctx), query.Encode())
+}
+
+// PublicTopProjectsCacheKey 公开API热门项目列表的缓存键，与公开项目列表共用缓存代数，随其一起失效
+func PublicTopProjectsCacheKey(ctx context.Context) string {
+	return database.Keys.PublicProjects(publicProjectsGeneration(ctx), "top")
+}
+
+// publicProjectsGeneration 当前公开项目列表的缓存代数，由invalidatePublicProjects递增
+func publicProjectsGeneration(ctx context.Context) int64 {
+	var generation int64
+	database.NewCache().Get(ctx, database.Keys.PublicProjectsGeneration(), &generation)
+	return generation
+}
+
+// invalidatePublicProjects 项目创建、修改或删除后使所有公开项目列表缓存失效
+// 递增缓存代数而不是逐个删除键，旧代数的缓存按TTL自然过期
+func invalidatePublicProjects(ctx context.Context) {
+	if database.RedisClient == nil {
+		return
+	}
+	database.NewCache().Incr(ctx, database.Keys.PublicProjectsGeneration())
+}
@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"iot-platform-backend/internal/testutil"
+)
+
+func TestPublicProjectsCacheKeys(t *testing.T) {
+	testutil.LoadConfig(t)
+	testutil.UseRedis(t)
+	ctx := context.Background()
+
+	listKey := publicProjectsCacheKey(ctx, 1, 10, "", "", "stars", projectForkFilter{})
+	topKey := PublicTopProjectsCacheKey(ctx)
+	if listKey == topKey {
+		t.Fatal("top projects share a cache key with the project list")
+	}
+	if again := PublicTopProjectsCacheKey(ctx); again != topKey {
+		t.Errorf("cache key is not stable: %q != %q", again, topKey)
+	}
+
+	invalidatePublicProjects(ctx)
+	if PublicTopProjectsCacheKey(ctx) == topKey {
+		t.Error("top projects cache key did not change after invalidation")
+	}
+	if publicProjectsCacheKey(ctx, 1, 10, "", "", "stars", projectForkFilter{}) == listKey {
+		t.Error("project list cache key did not change after invalidation")
+	}
+}
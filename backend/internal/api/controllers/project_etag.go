@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	
+	"github.com/gin-gonic/gin"
+	"iot-platform-backend/internal/models"
+)
+
+// ProjectETag 根据项目配置和更新时间生成弱ETag
+// 响应中的浏览数、Star数等计数不参与计算，计数变化不会使客户端缓存失效，因此使用弱校验器
+func ProjectETag(project *models.Project) string {
+	config, _ := json.Marshal(project.Config)
+	
+	hash := sha256.New()
+	hash.Write([]byte(strconv.FormatUint(uint64(project.ID), 10)))
+	hash.Write([]byte{0})
+	hash.Write([]byte(strconv.Itoa(project.Version)))
+	hash.Write([]byte{0})
+	hash.Write([]byte(project.UpdatedAt.UTC().Format(time.RFC3339Nano)))
+	hash.Write([]byte{0})
+	hash.Write(config)
+	return `W/"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+}
+
+// CheckNotModified 设置ETag响应头，If-None-Match与之匹配时返回304并返回true
+func CheckNotModified(c *gin.Context, etag string) bool {
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, no-cache")
+	
+	if !etagMatches(c.GetHeader("If-None-Match"), etag) {
+		return false
+	}
+	c.Status(http.StatusNotModified)
+	return true
+}
+
+// etagMatches 按弱比较判断If-None-Match是否包含etag，支持逗号分隔的多个值和*
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	target := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/testutil"
+)
+
+func TestProjectETag(t *testing.T) {
+	updated := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	base := models.Project{ID: 7, Version: 2, UpdatedAt: updated, Config: models.JSONB{"threshold": 10}}
+	etag := ProjectETag(&base)
+	if len(etag) < 4 || etag[:3] != `W/"` {
+		t.Fatalf("ProjectETag() = %s, want a weak validator", etag)
+	}
+
+	// 计数和时区表示不影响ETag
+	same := base
+	same.ViewCount, same.StarCount = 100, 5
+	same.UpdatedAt = updated.In(time.FixedZone("CST", 8*3600))
+	if got := ProjectETag(&same); got != etag {
+		t.Errorf("ETag changed with counters or time zone: %s != %s", got, etag)
+	}
+
+	for name, mutate := range map[string]func(*models.Project){
+		"config":     func(p *models.Project) { p.Config = models.JSONB{"threshold": 11} },
+		"updated_at": func(p *models.Project) { p.UpdatedAt = updated.Add(time.Millisecond) },
+		"version":    func(p *models.Project) { p.Version++ },
+		"id":         func(p *models.Project) { p.ID++ },
+	} {
+		changed := base
+		mutate(&changed)
+		if got := ProjectETag(&changed); got == etag {
+			t.Errorf("ETag unchanged after changing %s", name)
+		}
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	const etag = `W/"abc"`
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{`W/"abc"`, true},
+		{`"abc"`, true},
+		{`"other", W/"abc"`, true},
+		{`"other"`, false},
+		{`*`, true},
+		{`abc`, false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, etag); got != tt.want {
+			t.Errorf("etagMatches(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestGetProjectConditional(t *testing.T) {
+	testutil.LoadConfig(t)
+	db := testutil.UseDB(t)
+	owner := createTestUser(t, "owner")
+	viewer := createTestUser(t, "viewer")
+	project := createTestProject(t, models.Project{Name: "p", Public: true, Config: models.JSONB{"threshold": 10}, OwnerID: owner.ID})
+
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		t.Helper()
+		c, w := newJSONContext(t, "GET", fmt.Sprintf("/api/v1/projects/%d", project.ID), nil, viewer)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(project.ID)}}
+		if ifNoneMatch != "" {
+			c.Request.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		NewProjectController().GetProject(c)
+		// 与gin处理链结束时相同，写出仅设置了状态码的响应
+		c.Writer.WriteHeaderNow()
+		return w
+	}
+
+	first := get("")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("status = %d ETag = %q, want 200 with an ETag", first.Code, etag)
+	}
+
+	cached := get(etag)
+	if cached.Code != http.StatusNotModified || cached.Body.Len() != 0 {
+		t.Fatalf("status = %d body = %q, want an empty 304", cached.Code, cached.Body.String())
+	}
+	if cached.Header().Get("ETag") != etag {
+		t.Errorf("304 ETag = %q, want %q", cached.Header().Get("ETag"), etag)
+	}
+
+	// 未配置Redis时浏览次数直接写库，条件请求命中也计入
+	var stored models.Project
+	db.First(&stored, project.ID)
+	if stored.ViewCount != 2 {
+		t.Errorf("view_count = %d, want 2 including the 304", stored.ViewCount)
+	}
+
+	c, w := newJSONContext(t, "PUT", fmt.Sprintf("/api/v1/projects/%d", project.ID), map[string]interface{}{"config": map[string]interface{}{"threshold": 20}}, owner)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(project.ID)}}
+	NewProjectController().UpdateProject(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("update status = %d: %s", w.Code, w.Body.String())
+	}
+
+	// 修改后旧ETag不再匹配，返回新内容和新ETag
+	fresh := get(etag)
+	if fresh.Code != http.StatusOK {
+		t.Fatalf("status after update = %d, want 200", fresh.Code)
+	}
+	if got := fresh.Header().Get("ETag"); got == "" || got == etag {
+		t.Errorf("ETag after update = %q, want a new one", got)
+	}
+	if body := decodeBody(t, fresh); body["data"].(map[string]interface{})["config"].(map[string]interface{})["threshold"] != float64(20) {
+		t.Errorf("data = %v, want the updated config", body["data"])
+	}
+	if again := get(fresh.Header().Get("ETag")); again.Code != http.StatusNotModified {
+		t.Errorf("status with the new ETag = %d, want 304", again.Code)
+	}
+}
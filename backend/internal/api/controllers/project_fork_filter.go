@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// projectForkFilter 项目列表按Fork关系筛选，字段为nil表示不筛选
+type projectForkFilter struct {
+	Forked   *bool // true只列出Fork出来的项目，false只列出原创项目
+	HasForks *bool // true只列出被Fork过的项目，false只列出未被Fork过的项目
+	ParentID *uint // 只列出指定项目的直接Fork
+}
+
+// parseProjectForkFilter 解析forked、has_forks和parent_id查询参数
+func parseProjectForkFilter(c *gin.Context) (projectForkFilter, error) {
+	var filter projectForkFilter
+	for name, target := range map[string]**bool{"forked": &filter.Forked, "has_forks": &filter.HasForks} {
+		raw := c.Query(name)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return filter, fmt.Errorf("%s must be true or false", name)
+		}
+		*target = &value
+	}
+	if raw := c.Query("parent_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil || id == 0 {
+			return filter, fmt.Errorf("parent_id must be a positive integer")
+		}
+		parentID := uint(id)
+		filter.ParentID = &parentID
+	}
+	// 指定了来源项目的一定是Fork
+	if filter.ParentID != nil && filter.Forked != nil && !*filter.Forked {
+		return filter, fmt.Errorf("parent_id cannot be combined with forked=false")
+	}
+	return filter, nil
+}
+
+// scope 按筛选条件限定查询，可见性由调用方的其他条件保证
+func (f projectForkFilter) scope(db *gorm.DB) *gorm.DB {
+	if f.Forked != nil {
+		if *f.Forked {
+			db = db.Where("parent_id IS NOT NULL")
+		} else {
+			db = db.Where("parent_id IS NULL")
+		}
+	}
+	if f.HasForks != nil {
+		// 按实际存在的Fork判断，不依赖可能滞后的fork_count
+		exists := "EXISTS (SELECT 1 FROM projects forks WHERE forks.parent_id = projects.id AND forks.deleted_at IS NULL)"
+		if *f.HasForks {
+			db = db.Where(exists)
+		} else {
+			db = db.Where("NOT " + exists)
+		}
+	}
+	if f.ParentID != nil {
+		db = db.Where("parent_id = ?", *f.ParentID)
+	}
+	return db
+}
+
+// encode 将筛选条件写入缓存键的查询参数
+func (f projectForkFilter) encode(query url.Values) {
+	if f.Forked != nil {
+		query.Set("forked", strconv.FormatBool(*f.Forked))
+	}
+	if f.HasForks != nil {
+		query.Set("has_forks", strconv.FormatBool(*f.HasForks))
+	}
+	if f.ParentID != nil {
+		query.Set("parent_id", strconv.FormatUint(uint64(*f.ParentID), 10))
+	}
+}
@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"testing"
+
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/testutil"
+)
+
+func TestParseProjectForkFilter(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{"forked=true", "forked=true", false},
+		{"forked=0&has_forks=1", "forked=false&has_forks=true", false},
+		{"parent_id=12&has_forks=false", "has_forks=false&parent_id=12", false},
+		{"parent_id=12&forked=true", "forked=true&parent_id=12", false},
+		{"forked=maybe", "", true},
+		{"has_forks=", "", false},
+		{"parent_id=0", "", true},
+		{"parent_id=-1", "", true},
+		{"parent_id=abc", "", true},
+		{"parent_id=12&forked=false", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			c, _ := newTestContext("GET", "/api/v1/projects?"+tt.query)
+			filter, err := parseProjectForkFilter(c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseProjectForkFilter() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			// 缓存键中只包含指定了的筛选条件
+			encoded := url.Values{}
+			filter.encode(encoded)
+			if got := encoded.Encode(); got != tt.want {
+				t.Errorf("encoded filter = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetProjectsForkFilters(t *testing.T) {
+	testutil.LoadConfig(t)
+	db := testutil.UseDB(t)
+	alice := createTestUser(t, "alice")
+	bob := createTestUser(t, "bob")
+	carol := createTestUser(t, "carol")
+
+	// orig-a <- fork-a1 <- fork-a1-1，orig-a <- fork-a2（bob的私有项目）；orig-b的Fork已删除
+	origA := createTestProject(t, models.Project{Name: "orig-a", Public: true, OwnerID: alice.ID})
+	origB := createTestProject(t, models.Project{Name: "orig-b", OwnerID: alice.ID})
+	forkA1 := createTestProject(t, models.Project{Name: "fork-a1", Public: true, OwnerID: bob.ID, ParentID: &origA.ID})
+	createTestProject(t, models.Project{Name: "fork-a2", OwnerID: carol.ID, ParentID: &origA.ID})
+	createTestProject(t, models.Project{Name: "fork-a1-1", Public: true, OwnerID: carol.ID, ParentID: &forkA1.ID})
+	deleted := createTestProject(t, models.Project{Name: "fork-b1", Public: true, OwnerID: bob.ID, ParentID: &origB.ID})
+	if err := db.Delete(&deleted).Error; err != nil {
+		t.Fatalf("failed to delete fork: %v", err)
+	}
+
+	list := func(user *models.User, query string) (int, []string) {
+		t.Helper()
+		c, w := newJSONContext(t, "GET", "/api/v1/projects?limit=50&"+query, nil, user)
+		NewProjectController().GetProjects(c)
+		if w.Code != http.StatusOK {
+			return w.Code, nil
+		}
+		var body struct {
+			Data ProjectListResponse `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		names := make([]string, 0, len(body.Data.Projects))
+		for _, project := range body.Data.Projects {
+			names = append(names, project.Name)
+		}
+		sort.Strings(names)
+		return w.Code, names
+	}
+
+	tests := []struct {
+		name  string
+		user  *models.User
+		query string
+		want  []string
+	}{
+		// alice看不到carol的私有Fork fork-a2
+		{"no filter", alice, "", []string{"fork-a1", "fork-a1-1", "orig-a", "orig-b"}},
+		{"forked", alice, "forked=true", []string{"fork-a1", "fork-a1-1"}},
+		{"not forked", alice, "forked=false", []string{"orig-a", "orig-b"}},
+		{"has forks", alice, "has_forks=true", []string{"fork-a1", "orig-a"}},
+		{"without forks ignores deleted forks", alice, "has_forks=false", []string{"fork-a1-1", "orig-b"}},
+		{"forked with forks", alice, "forked=true&has_forks=true", []string{"fork-a1"}},
+		{"original without forks", alice, "forked=false&has_forks=false", []string{"orig-b"}},
+		{"direct forks only", alice, fmt.Sprintf("parent_id=%d", origA.ID), []string{"fork-a1"}},
+		{"direct forks with forks", alice, fmt.Sprintf("parent_id=%d&has_forks=true", origA.ID), []string{"fork-a1"}},
+		{"direct forks without forks", alice, fmt.Sprintf("parent_id=%d&has_forks=false&forked=true", origA.ID), []string{}},
+		{"owner sees own private fork", carol, fmt.Sprintf("parent_id=%d", origA.ID), []string{"fork-a1", "fork-a2"}},
+		{"forks of an invisible project", bob, fmt.Sprintf("parent_id=%d", origB.ID), []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, names := list(tt.user, tt.query)
+			if code != http.StatusOK {
+				t.Fatalf("status = %d", code)
+			}
+			if fmt.Sprint(names) != fmt.Sprint(tt.want) {
+				t.Errorf("projects = %v, want %v", names, tt.want)
+			}
+		})
+	}
+
+	for _, query := range []string{"forked=maybe", "parent_id=0", fmt.Sprintf("parent_id=%d&forked=false", origA.ID)} {
+		if code, _ := list(alice, query); code != http.StatusBadRequest {
+			t.Errorf("%s status = %d, want %d", query, code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/websocket"
+)
+
+// UnpublishProjectRequest 下架公开项目请求
+type UnpublishProjectRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+// UnpublishProject 下架公开项目
+// @Summary 下架公开项目
+// @Description 将违规的公开项目设为私有，项目本身不做其他修改。需要projects:moderate权限（版主或管理员），租户内的账号只能处理本租户的项目。
+// @Description 操作写入审计日志，并以站内通知告知项目拥有者下架原因
+// @Tags 管理
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "项目ID"
+// @Param request body UnpublishProjectRequest true "下架原因"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{} "项目不是公开项目"
+// @Router /admin/projects/{id}/unpublish [post]
+func (ctrl *ProjectController) UnpublishProject(c *gin.Context) {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid project ID",
+		})
+		return
+	}
+	
+	var req UnpublishProjectRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	var project models.Project
+	if err := db.First(&project, projectID).Error; err != nil || !middleware.SameTenant(c, project.OwnerID) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Project not found",
+		})
+		return
+	}
+	
+	// 仅在项目仍为公开时更新，避免覆盖拥有者同时做的修改
+	result := db.Model(&models.Project{}).
+		Where("id = ? AND public = ?", project.ID, true).
+		Updates(map[string]interface{}{
+			"public":  false,
+			"version": gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to unpublish project",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Project is not public",
+		})
+		return
+	}
+	project.Public = false
+	project.Version++
+	
+	audit := models.AuditLog{
+		ActorID:    middleware.GetUserID(c),
+		Action:     "project_unpublish",
+		Resource:   "project",
+		ResourceID: project.ID,
+		Details: models.JSONB{
+			"owner_id": project.OwnerID,
+			"reason":   req.Reason,
+		},
+		IPAddress: c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+	}
+	db.Create(&audit)
+	
+	cache := database.NewCache()
+	cache.Delete(c, database.Keys.Project(project.ID))
+	invalidatePublicProjects(c)
+	
+	if websocket.DefaultManager != nil {
+		websocket.DefaultManager.Notify(project.OwnerID, "project_unpublished", models.JSONB{
+			"project_id":   project.ID,
+			"project_name": project.Name,
+			"reason":       req.Reason,
+		})
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"msg":    "项目已下架",
+		"data":   project,
+	})
+}
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/testutil"
+)
+
+// starProject 以指定用户调用点赞接口
+func starProject(t *testing.T, user *models.User, projectID uint) *httptest.ResponseRecorder {
+	t.Helper()
+	c, w := newJSONContext(t, "POST", fmt.Sprintf("/api/v1/projects/%d/star", projectID), nil, user)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(projectID)}}
+	NewProjectController().StarProject(c)
+	return w
+}
+
+// starState 返回用户的点赞记录数和项目的star_count
+func starState(t *testing.T, db *gorm.DB, projectID, userID uint) (int64, int) {
+	t.Helper()
+	var stars int64
+	if err := db.Model(&models.ProjectStar{}).Where("project_id = ? AND user_id = ?", projectID, userID).Count(&stars).Error; err != nil {
+		t.Fatalf("count stars: %v", err)
+	}
+	var project models.Project
+	if err := db.First(&project, projectID).Error; err != nil {
+		t.Fatalf("reload project: %v", err)
+	}
+	return stars, project.StarCount
+}
+
+func TestStarProjectToggle(t *testing.T) {
+	cfg := testutil.LoadConfig(t)
+	cfg.Projects.StarToggleCooldown = 0
+	db := testutil.UseDB(t)
+	owner := createTestUser(t, "owner")
+	fan := createTestUser(t, "fan")
+	project := createTestProject(t, models.Project{Name: "p", OwnerID: owner.ID, Public: true, StarCount: 3})
+
+	for _, want := range []struct {
+		starred bool
+		count   int
+	}{{true, 4}, {false, 3}, {true, 4}} {
+		w := starProject(t, fan, project.ID)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+		}
+		body := decodeBody(t, w)
+		if body["starred"] != want.starred || body["count"] != float64(want.count) {
+			t.Errorf("starred=%v count=%v, want %v/%d", body["starred"], body["count"], want.starred, want.count)
+		}
+		stars, count := starState(t, db, project.ID, fan.ID)
+		if (stars == 1) != want.starred || count != want.count {
+			t.Errorf("stored stars=%d star_count=%d, want starred=%v and %d", stars, count, want.starred, want.count)
+		}
+	}
+
+	private := createTestProject(t, models.Project{Name: "private", OwnerID: owner.ID})
+	if w := starProject(t, fan, private.ID); w.Code != http.StatusForbidden {
+		t.Errorf("starring a private project status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := starProject(t, fan, private.ID+1000); w.Code != http.StatusNotFound {
+		t.Errorf("starring a missing project status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestStarProjectRollsBackOnError(t *testing.T) {
+	cfg := testutil.LoadConfig(t)
+	cfg.Projects.StarToggleCooldown = 0
+	db := testutil.UseDB(t)
+	owner := createTestUser(t, "owner")
+	fan := createTestUser(t, "fan")
+	project := createTestProject(t, models.Project{Name: "p", OwnerID: owner.ID, Public: true, StarCount: 5})
+
+	// 在点赞记录写入之后、计数更新时注入错误，模拟操作中途失败
+	failCount := false
+	err := db.Callback().Update().Before("gorm:update").Register("test:fail_star_count", func(tx *gorm.DB) {
+		if failCount && tx.Statement.Table == "projects" {
+			tx.AddError(errors.New("injected failure"))
+		}
+	})
+	if err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+
+	failCount = true
+	if w := starProject(t, fan, project.ID); w.Code != http.StatusInternalServerError {
+		t.Fatalf("star status = %d, want %d: %s", w.Code, http.StatusInternalServerError, w.Body.String())
+	}
+	if stars, count := starState(t, db, project.ID, fan.ID); stars != 0 || count != 5 {
+		t.Errorf("after a failed star: stars=%d star_count=%d, want the insert rolled back and 5", stars, count)
+	}
+
+	failCount = false
+	if w := starProject(t, fan, project.ID); w.Code != http.StatusOK {
+		t.Fatalf("star status = %d: %s", w.Code, w.Body.String())
+	}
+
+	// 取消点赞时删除记录后计数更新失败，记录应恢复
+	failCount = true
+	if w := starProject(t, fan, project.ID); w.Code != http.StatusInternalServerError {
+		t.Fatalf("unstar status = %d, want %d: %s", w.Code, http.StatusInternalServerError, w.Body.String())
+	}
+	if stars, count := starState(t, db, project.ID, fan.ID); stars != 1 || count != 6 {
+		t.Errorf("after a failed unstar: stars=%d star_count=%d, want the delete rolled back and 6", stars, count)
+	}
+}
+
+func TestStarProjectCooldown(t *testing.T) {
+	cfg := testutil.LoadConfig(t)
+	cfg.Projects.StarToggleCooldown = 5 * time.Second
+	mr := testutil.UseRedis(t)
+	db := testutil.UseDB(t)
+	owner := createTestUser(t, "owner")
+	fan := createTestUser(t, "fan")
+	project := createTestProject(t, models.Project{Name: "p", OwnerID: owner.ID, Public: true})
+
+	if w := starProject(t, fan, project.ID); w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	w := starProject(t, fan, project.ID)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "5" {
+		t.Errorf("status = %d Retry-After = %q, want %d and 5", w.Code, w.Header().Get("Retry-After"), http.StatusTooManyRequests)
+	}
+	if stars, count := starState(t, db, project.ID, fan.ID); stars != 1 || count != 1 {
+		t.Errorf("rejected toggle changed state: stars=%d star_count=%d", stars, count)
+	}
+
+	mr.FastForward(5 * time.Second)
+	if w := starProject(t, fan, project.ID); w.Code != http.StatusOK {
+		t.Errorf("status after the cooldown = %d: %s", w.Code, w.Body.String())
+	}
+}
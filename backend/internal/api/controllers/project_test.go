@@ -0,0 +1,478 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/testutil"
+)
+
+func TestGetProjectsVisibility(t *testing.T) {
+	testutil.LoadConfig(t)
+	testutil.UseDB(t)
+
+	inTenant := func(tenant string) func(*models.User) {
+		return func(u *models.User) { u.TenantID = tenant }
+	}
+	asAdmin := func(u *models.User) { u.Role = models.RoleAdmin }
+
+	alice := createTestUser(t, "alice", inTenant("acme"))
+	bob := createTestUser(t, "bob", inTenant("globex"))
+	acmeAdmin := createTestUser(t, "acme-admin", inTenant("acme"), asAdmin)
+	platformAdmin := createTestUser(t, "root", asAdmin)
+
+	createTestProject(t, models.Project{Name: "alice-private", OwnerID: alice.ID})
+	createTestProject(t, models.Project{Name: "bob-private", OwnerID: bob.ID})
+	createTestProject(t, models.Project{Name: "bob-public", OwnerID: bob.ID, Public: true})
+
+	tests := []struct {
+		name string
+		user *models.User
+		want []string
+	}{
+		{"owner", alice, []string{"alice-private", "bob-public"}},
+		{"other tenant", bob, []string{"bob-private", "bob-public"}},
+		{"tenant admin sees only its tenant", acmeAdmin, []string{"alice-private", "bob-public"}},
+		{"platform admin sees everything", platformAdmin, []string{"alice-private", "bob-private", "bob-public"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := newJSONContext(t, "GET", "/api/v1/projects?limit=50", nil, tt.user)
+			NewProjectController().GetProjects(c)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+			var body struct {
+				Data ProjectListResponse `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			names := make([]string, 0, len(body.Data.Projects))
+			for _, project := range body.Data.Projects {
+				names = append(names, project.Name)
+			}
+			sort.Strings(names)
+			if fmt.Sprint(names) != fmt.Sprint(tt.want) {
+				t.Errorf("projects = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateProjectDescriptionAndPublic(t *testing.T) {
+	testutil.LoadConfig(t)
+	testutil.UseRedis(t)
+	db := testutil.UseDB(t)
+	owner := createTestUser(t, "owner")
+
+	tests := []struct {
+		name            string
+		body            string
+		wantDescription string
+		wantPublic      bool
+	}{
+		{"absent fields are unchanged", `{}`, "original", true},
+		{"null clears", `{"description":null,"public":null}`, "", false},
+		{"empty description clears", `{"description":""}`, "", true},
+		{"values are applied", `{"description":"new","public":false}`, "new", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project := createTestProject(t, models.Project{Name: "p", Description: "original", Public: true, OwnerID: owner.ID})
+
+			c, w := newJSONContext(t, "PUT", fmt.Sprintf("/api/v1/projects/%d", project.ID), json.RawMessage(tt.body), owner)
+			c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(project.ID)}}
+			NewProjectController().UpdateProject(c)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+
+			var stored models.Project
+			if err := db.First(&stored, project.ID).Error; err != nil {
+				t.Fatalf("reload: %v", err)
+			}
+			if stored.Description != tt.wantDescription || stored.Public != tt.wantPublic {
+				t.Errorf("description=%q public=%v, want %q/%v", stored.Description, stored.Public, tt.wantDescription, tt.wantPublic)
+			}
+		})
+	}
+}
+
+func TestUpdateProjectVersionConflict(t *testing.T) {
+	testutil.LoadConfig(t)
+	testutil.UseRedis(t)
+	db := testutil.UseDB(t)
+	owner := createTestUser(t, "owner")
+	editor := createTestUser(t, "editor")
+
+	project := createTestProject(t, models.Project{Name: "p", Config: models.JSONB{"threshold": 10}, OwnerID: owner.ID})
+	if err := db.Create(&models.ProjectCollaborator{ProjectID: project.ID, UserID: editor.ID, Role: models.CollaboratorEditor}).Error; err != nil {
+		t.Fatalf("failed to add collaborator: %v", err)
+	}
+	if project.Version != 1 {
+		t.Fatalf("initial version = %d, want 1", project.Version)
+	}
+
+	update := func(user *models.User, body interface{}, ifMatch string) *httptest.ResponseRecorder {
+		t.Helper()
+		c, w := newJSONContext(t, "PUT", fmt.Sprintf("/api/v1/projects/%d", project.ID), body, user)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(project.ID)}}
+		if ifMatch != "" {
+			c.Request.Header.Set("If-Match", ifMatch)
+		}
+		NewProjectController().UpdateProject(c)
+		return w
+	}
+
+	// 基准版本可以放在请求体version字段，也可以通过If-Match头传递
+	tests := []struct {
+		name    string
+		ifMatch bool
+	}{
+		{"version field", false},
+		{"If-Match header", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var current models.Project
+			if err := db.First(&current, project.ID).Error; err != nil {
+				t.Fatalf("reload: %v", err)
+			}
+			base := current.Version
+			ifMatch := ""
+			if tt.ifMatch {
+				ifMatch = fmt.Sprintf(`"%d"`, base)
+			}
+			body := func(threshold int) gin.H {
+				req := gin.H{"config": gin.H{"threshold": threshold}}
+				if !tt.ifMatch {
+					req["version"] = base
+				}
+				return req
+			}
+
+			// 两个用户基于同一版本编辑，先保存者成功
+			if w := update(owner, body(20), ifMatch); w.Code != http.StatusOK {
+				t.Fatalf("first update status = %d: %s", w.Code, w.Body.String())
+			}
+
+			// 后保存者基于过期版本，返回409和当前配置
+			w := update(editor, body(30), ifMatch)
+			if w.Code != http.StatusConflict {
+				t.Fatalf("second update status = %d, want %d: %s", w.Code, http.StatusConflict, w.Body.String())
+			}
+			var conflict struct {
+				CurrentVersion int            `json:"current_version"`
+				Data           models.Project `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &conflict); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if conflict.CurrentVersion != base+1 || conflict.Data.Version != base+1 {
+				t.Errorf("current_version = %d, data.version = %d, want %d", conflict.CurrentVersion, conflict.Data.Version, base+1)
+			}
+			if conflict.Data.Config["threshold"] != float64(20) {
+				t.Errorf("conflict config = %v, want the first update's config", conflict.Data.Config)
+			}
+
+			var stored models.Project
+			if err := db.First(&stored, project.ID).Error; err != nil {
+				t.Fatalf("reload: %v", err)
+			}
+			if stored.Version != base+1 || stored.Config["threshold"] != float64(20) {
+				t.Errorf("stored version=%d config=%v, want the first update only", stored.Version, stored.Config)
+			}
+		})
+	}
+
+	for _, ifMatch := range []string{`"abc"`, "*"} {
+		if w := update(owner, gin.H{"name": "renamed"}, ifMatch); w.Code != http.StatusBadRequest {
+			t.Errorf("If-Match %s status = %d, want %d", ifMatch, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestResolveBaseVersion(t *testing.T) {
+	five := 5
+	tests := []struct {
+		name    string
+		body    *int
+		ifMatch string
+		want    int
+		wantOK  bool
+	}{
+		{"defaults to current version", nil, "", 3, true},
+		{"body version wins", &five, `"7"`, 5, true},
+		{"quoted If-Match", nil, `"7"`, 7, true},
+		{"weak If-Match", nil, `W/"7"`, 7, true},
+		{"bare If-Match", nil, "7", 7, true},
+		{"non-numeric If-Match", nil, `"abc"`, 0, false},
+		{"wildcard If-Match", nil, "*", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestContext("PUT", "/api/v1/projects/1")
+			if tt.ifMatch != "" {
+				c.Request.Header.Set("If-Match", tt.ifMatch)
+			}
+			got, ok := resolveBaseVersion(c, tt.body, 3)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("resolveBaseVersion() = %d, %v, want %d, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestForkProjectConcurrent(t *testing.T) {
+	testutil.LoadConfig(t)
+	testutil.UseRedis(t)
+	db := testutil.UseDB(t)
+	owner := createTestUser(t, "owner")
+	forker := createTestUser(t, "forker")
+	source := createTestProject(t, models.Project{Name: "upstream", Public: true, OwnerID: owner.ID})
+
+	// 两个请求同时Fork同一项目，只能有一个成功
+	const attempts = 2
+	start := make(chan struct{})
+	recorders := make([]*httptest.ResponseRecorder, attempts)
+	var wg sync.WaitGroup
+	for i := range recorders {
+		c, w := newJSONContext(t, "POST", fmt.Sprintf("/api/v1/projects/%d/fork", source.ID), ForkProjectRequest{Name: fmt.Sprintf("fork-%d", i)}, forker)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(source.ID)}}
+		recorders[i] = w
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			NewProjectController().ForkProject(c)
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	var created, conflicts int
+	var forkID uint
+	for _, w := range recorders {
+		switch w.Code {
+		case http.StatusCreated:
+			created++
+		case http.StatusConflict:
+			conflicts++
+			var body struct {
+				Data models.Project `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			forkID = body.Data.ID
+		default:
+			t.Errorf("status = %d: %s", w.Code, w.Body.String())
+		}
+	}
+	if created != 1 || conflicts != 1 {
+		t.Fatalf("created=%d conflicts=%d, want exactly one of each", created, conflicts)
+	}
+
+	// 409响应返回的是已创建的那个Fork
+	var forks []models.Project
+	if err := db.Where("parent_id = ? AND owner_id = ?", source.ID, forker.ID).Find(&forks).Error; err != nil {
+		t.Fatalf("failed to load forks: %v", err)
+	}
+	if len(forks) != 1 || forks[0].ID != forkID {
+		t.Errorf("forks = %+v, want a single fork matching the 409 response (id %d)", forks, forkID)
+	}
+	var stored models.Project
+	if err := db.First(&stored, source.ID).Error; err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if stored.ForkCount != 1 {
+		t.Errorf("fork_count = %d, want 1", stored.ForkCount)
+	}
+}
+
+func TestForkProjectDepthLimit(t *testing.T) {
+	cfg := testutil.LoadConfig(t)
+	cfg.Projects.MaxForkDepth = 2
+	testutil.UseRedis(t)
+	db := testutil.UseDB(t)
+	owner := createTestUser(t, "owner")
+	forker := createTestUser(t, "forker")
+
+	// root(0) <- level1(1) <- level2(2)
+	root := createTestProject(t, models.Project{Name: "root", Public: true, OwnerID: owner.ID})
+	level1 := createTestProject(t, models.Project{Name: "level1", Public: true, OwnerID: owner.ID, ParentID: &root.ID})
+	level2 := createTestProject(t, models.Project{Name: "level2", Public: true, OwnerID: owner.ID, ParentID: &level1.ID})
+
+	for _, tt := range []struct {
+		project   models.Project
+		wantDepth int
+	}{{root, 0}, {level1, 1}, {level2, 2}} {
+		if depth, cycle, err := forkDepth(db, tt.project.ID, 0); err != nil || cycle || depth != tt.wantDepth {
+			t.Errorf("forkDepth(%s) = %d, %v, %v, want %d", tt.project.Name, depth, cycle, err, tt.wantDepth)
+		}
+	}
+	// limit限制向上查找的层数
+	if depth, _, err := forkDepth(db, level2.ID, 1); err != nil || depth != 1 {
+		t.Errorf("forkDepth(level2, 1) = %d, %v, want 1", depth, err)
+	}
+
+	fork := func(source models.Project) *httptest.ResponseRecorder {
+		t.Helper()
+		c, w := newJSONContext(t, "POST", fmt.Sprintf("/api/v1/projects/%d/fork", source.ID), ForkProjectRequest{Name: "fork-of-" + source.Name}, forker)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(source.ID)}}
+		NewProjectController().ForkProject(c)
+		return w
+	}
+
+	// Fork level2会产生深度3，超出上限
+	if w := fork(level2); w.Code != http.StatusConflict {
+		t.Fatalf("fork beyond the depth limit status = %d, want %d: %s", w.Code, http.StatusConflict, w.Body.String())
+	}
+	var count int64
+	db.Model(&models.Project{}).Where("owner_id = ?", forker.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("%d project(s) created by a rejected fork, want 0", count)
+	}
+
+	// 正好达到上限的Fork允许
+	if w := fork(level1); w.Code != http.StatusCreated {
+		t.Errorf("fork at the depth limit status = %d: %s", w.Code, w.Body.String())
+	}
+
+	// 不限制深度时仍可Fork更深的项目
+	cfg.Projects.MaxForkDepth = 0
+	if w := fork(level2); w.Code != http.StatusCreated {
+		t.Errorf("fork without a depth limit status = %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestForkProjectLineageCycle(t *testing.T) {
+	cfg := testutil.LoadConfig(t)
+	testutil.UseRedis(t)
+	db := testutil.UseDB(t)
+	owner := createTestUser(t, "owner")
+	forker := createTestUser(t, "forker")
+
+	// a <- b，再把a的父项目指向b构成环
+	a := createTestProject(t, models.Project{Name: "a", Public: true, OwnerID: owner.ID})
+	b := createTestProject(t, models.Project{Name: "b", Public: true, OwnerID: owner.ID, ParentID: &a.ID})
+	if err := db.Model(&a).Update("parent_id", b.ID).Error; err != nil {
+		t.Fatalf("failed to create cycle: %v", err)
+	}
+
+	if _, cycle, err := forkDepth(db, b.ID, 0); err != nil || !cycle {
+		t.Errorf("forkDepth() cycle = %v, %v, want true", cycle, err)
+	}
+
+	// 无论是否限制深度，成环的谱系都拒绝Fork
+	for _, maxDepth := range []int{0, 10} {
+		cfg.Projects.MaxForkDepth = maxDepth
+		c, w := newJSONContext(t, "POST", fmt.Sprintf("/api/v1/projects/%d/fork", b.ID), ForkProjectRequest{Name: "fork"}, forker)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(b.ID)}}
+		NewProjectController().ForkProject(c)
+		if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "cycle") {
+			t.Errorf("max depth %d: status = %d body = %s, want 409 reporting a cycle", maxDepth, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestForkProjectRateLimit(t *testing.T) {
+	cfg := testutil.LoadConfig(t)
+	cfg.Projects.ForkRateLimit = 2
+	testutil.UseRedis(t)
+	db := testutil.UseDB(t)
+	owner := createTestUser(t, "owner")
+	forker := createTestUser(t, "forker")
+
+	// 与路由注册相同：限流中间件在ForkProject之前执行
+	r := gin.New()
+	r.POST("/api/v1/projects/:id/fork", func(c *gin.Context) {
+		setTestUser(c, forker)
+	}, middleware.RateLimitByUser(cfg.Projects.ForkRateLimit, cfg.Projects.ForkRateWindow), NewProjectController().ForkProject)
+
+	for i, want := range []int{http.StatusCreated, http.StatusCreated, http.StatusTooManyRequests} {
+		source := createTestProject(t, models.Project{Name: fmt.Sprintf("upstream-%d", i), Public: true, OwnerID: owner.ID})
+		body, _ := json.Marshal(ForkProjectRequest{Name: fmt.Sprintf("fork-%d", i)})
+		req := httptest.NewRequest("POST", fmt.Sprintf("/api/v1/projects/%d/fork", source.ID), strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Fatalf("fork %d status = %d, want %d: %s", i, w.Code, want, w.Body.String())
+		}
+	}
+
+	var forks int64
+	db.Model(&models.Project{}).Where("owner_id = ?", forker.ID).Count(&forks)
+	if forks != 2 {
+		t.Errorf("%d fork(s) created, want 2", forks)
+	}
+}
+
+func TestGetProjectsStablePagination(t *testing.T) {
+	testutil.LoadConfig(t)
+	testutil.UseDB(t)
+	owner := createTestUser(t, "owner")
+
+	// 同一时刻创建的项目，仅靠created_at无法确定顺序
+	createdAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	var wantIDs []uint
+	for i := 0; i < 7; i++ {
+		project := createTestProject(t, models.Project{Name: fmt.Sprintf("p%d", i), OwnerID: owner.ID, CreatedAt: createdAt})
+		wantIDs = append([]uint{project.ID}, wantIDs...)
+	}
+
+	list := func(query string) (int, []models.Project) {
+		t.Helper()
+		c, w := newJSONContext(t, "GET", "/api/v1/projects?"+query, nil, owner)
+		NewProjectController().GetProjects(c)
+		var body struct {
+			Data ProjectListResponse `json:"data"`
+		}
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+		}
+		return w.Code, body.Data.Projects
+	}
+
+	// 逐页读取，同一时刻的项目按id降序排列，不重复也不遗漏
+	var gotIDs []uint
+	for page := 1; page <= 3; page++ {
+		code, projects := list(fmt.Sprintf("page=%d&limit=3", page))
+		if code != http.StatusOK {
+			t.Fatalf("page %d status = %d", page, code)
+		}
+		for _, project := range projects {
+			gotIDs = append(gotIDs, project.ID)
+		}
+	}
+	if fmt.Sprint(gotIDs) != fmt.Sprint(wantIDs) {
+		t.Errorf("paged ids = %v, want %v", gotIDs, wantIDs)
+	}
+
+	// 排序字段相同时id同方向作为第二排序键
+	code, projects := list("sort=star_count&limit=50")
+	if code != http.StatusOK {
+		t.Fatalf("sort=star_count status = %d", code)
+	}
+	if len(projects) != len(wantIDs) || projects[0].ID != wantIDs[len(wantIDs)-1] {
+		t.Errorf("sort=star_count returned %d project(s), want %d starting with the lowest id %d", len(projects), len(wantIDs), wantIDs[len(wantIDs)-1])
+	}
+
+	if code, _ := list("sort=owner_id"); code != http.StatusBadRequest {
+		t.Errorf("unsupported sort status = %d, want %d", code, http.StatusBadRequest)
+	}
+}
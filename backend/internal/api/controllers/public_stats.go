@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+	
+	"github.com/gin-gonic/gin"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/models"
+)
+
+const (
+	defaultStatsWindow  = "30d"
+	maxStatsWindow      = 365 * 24 * time.Hour
+	publicStatsCacheTTL = time.Minute
+)
+
+// PublicStats 平台公开统计
+type PublicStats struct {
+	Window            string `json:"window"`
+	PublicProjects    int64  `json:"public_projects"`
+	TotalViews        int64  `json:"total_views"`
+	TotalStars        int64  `json:"total_stars"`
+	ActiveUsers       int64  `json:"active_users"`        // 窗口期内登录过的用户
+	NewPublicProjects int64  `json:"new_public_projects"` // 窗口期内创建的公开项目
+	NewStars          int64  `json:"new_stars"`           // 窗口期内新增的Star
+}
+
+// projectTotals 项目汇总查询结果，空表时SUM为NULL，由COALESCE兜底为0
+type projectTotals struct {
+	PublicProjects    int64
+	NewPublicProjects int64
+	TotalViews        int64
+	TotalStars        int64
+}
+
+// GetPublicStats 获取平台公开统计
+// @Summary 平台统计
+// @Description 公开项目数、累计浏览和Star数，以及窗口期内的活跃用户、新增公开项目和新增Star数
+// @Tags 项目管理
+// @Produce json
+// @Param window query string false "统计窗口，如24h、7d、30d，最长365d" default(30d)
+// @Success 200 {object} PublicStats
+// @Failure 400 {object} map[string]interface{}
+// @Router /public/stats [get]
+func (ctrl *ProjectController) GetPublicStats(c *gin.Context) {
+	window := c.DefaultQuery("window", defaultStatsWindow)
+	duration, err := parseDayDuration(window)
+	if err == nil && (duration <= 0 || duration > maxStatsWindow) {
+		err = fmt.Errorf("window must be positive and at most 365d")
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid window",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	// 每次请求都要全表聚合，短时间缓存即可
+	cache := database.NewCache()
+	cacheKey := database.Keys.PublicStats(window)
+	var cached PublicStats
+	if err := cache.Get(c, cacheKey, &cached); err == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status": 1,
+			"data":   cached,
+		})
+		return
+	}
+	
+	stats, err := computePublicStats(c, window, time.Now().Add(-duration))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to compute stats",
+		})
+		return
+	}
+	
+	cache.Set(c, cacheKey, stats, publicStatsCacheTTL)
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"data":   stats,
+	})
+}
+
+// computePublicStats 汇总累计值和since之后的窗口期统计
+func computePublicStats(c *gin.Context, window string, since time.Time) (PublicStats, error) {
+	db := database.GetDBWithContext(c.Request.Context())
+	stats := PublicStats{Window: window}
+	
+	var totals projectTotals
+	if err := db.Model(&models.Project{}).
+		Select(`COUNT(*) FILTER (WHERE public) AS public_projects,
+			COUNT(*) FILTER (WHERE public AND created_at >= ?) AS new_public_projects,
+			COALESCE(SUM(view_count), 0) AS total_views,
+			COALESCE(SUM(star_count), 0) AS total_stars`, since).
+		Scan(&totals).Error; err != nil {
+		return stats, err
+	}
+	stats.PublicProjects = totals.PublicProjects
+	stats.NewPublicProjects = totals.NewPublicProjects
+	stats.TotalViews = totals.TotalViews
+	stats.TotalStars = totals.TotalStars
+	
+	if err := db.Model(&models.User{}).
+		Where("active = ? AND last_login >= ?", true, since).
+		Count(&stats.ActiveUsers).Error; err != nil {
+		return stats, err
+	}
+	
+	if err := db.Model(&models.ProjectStar{}).
+		Where("created_at >= ?", since).
+		Count(&stats.NewStars).Error; err != nil {
+		return stats, err
+	}
+	
+	return stats, nil
+}
@@ -0,0 +1,335 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"iot-platform-backend/internal/config"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+)
+
+// QuotaLimits 用户生效的配额，0表示不限制
+type QuotaLimits struct {
+	MaxDevices    int   `json:"max_devices"`
+	MaxProjects   int   `json:"max_projects"`
+	MaxSensorRows int64 `json:"max_sensor_rows"`
+}
+
+// QuotaUsage 用户资源用量与配额
+type QuotaUsage struct {
+	Devices    int64       `json:"devices"`
+	Projects   int64       `json:"projects"`
+	SensorRows int64       `json:"sensor_rows"`
+	Limits     QuotaLimits `json:"limits"`
+	Overridden bool        `json:"overridden"` // 是否设置了单独配额
+}
+
+// UpdateUserQuotaRequest 设置用户配额请求，整体替换；字段为空时沿用全局默认值，0表示不限制
+type UpdateUserQuotaRequest struct {
+	MaxDevices    *int   `json:"max_devices" binding:"omitempty,min=0"`
+	MaxProjects   *int   `json:"max_projects" binding:"omitempty,min=0"`
+	MaxSensorRows *int64 `json:"max_sensor_rows" binding:"omitempty,min=0"`
+}
+
+// effectiveQuota 合并全局默认配额和用户单独配额，返回是否存在单独配额
+func effectiveQuota(db *gorm.DB, userID uint) (QuotaLimits, bool, error) {
+	defaults := config.AppConfig.Quota
+	limits := QuotaLimits{
+		MaxDevices:    defaults.MaxDevices,
+		MaxProjects:   defaults.MaxProjects,
+		MaxSensorRows: defaults.MaxSensorRows,
+	}
+	
+	var override models.UserQuota
+	if err := db.Where("user_id = ?", userID).First(&override).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return limits, false, nil
+		}
+		return limits, false, err
+	}
+	
+	if override.MaxDevices != nil {
+		limits.MaxDevices = *override.MaxDevices
+	}
+	if override.MaxProjects != nil {
+		limits.MaxProjects = *override.MaxProjects
+	}
+	if override.MaxSensorRows != nil {
+		limits.MaxSensorRows = *override.MaxSensorRows
+	}
+	return limits, true, nil
+}
+
+// userSensorRows 汇总用户所有设备的数据点计数（读取每个设备的计数缓存，未命中时回填）
+func userSensorRows(ctx context.Context, db *gorm.DB, userID uint) (int64, error) {
+	var deviceIDs []string
+	if err := db.Model(&models.Device{}).Where("owner_id = ?", userID).Pluck("device_id", &deviceIDs).Error; err != nil {
+		return 0, err
+	}
+	
+	var total int64
+	for _, deviceID := range deviceIDs {
+		count, err := database.GetDeviceDataCount(ctx, deviceID)
+		if err != nil {
+			return 0, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// respondQuotaExceeded 返回配额超限响应
+func respondQuotaExceeded(c *gin.Context, resource string, limit int64) {
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":   "Quota exceeded",
+		"details": fmt.Sprintf("%s quota of %d reached", resource, limit),
+	})
+}
+
+// checkDeviceQuota 检查用户再创建adding个设备是否超出配额，超出时写入403响应并返回false
+func checkDeviceQuota(c *gin.Context, db *gorm.DB, userID uint, adding int) bool {
+	limits, _, err := effectiveQuota(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to check quota",
+		})
+		return false
+	}
+	if limits.MaxDevices == 0 {
+		return true
+	}
+	
+	var count int64
+	if err := db.Model(&models.Device{}).Where("owner_id = ?", userID).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to check quota",
+		})
+		return false
+	}
+	if count+int64(adding) > int64(limits.MaxDevices) {
+		respondQuotaExceeded(c, "Device", int64(limits.MaxDevices))
+		return false
+	}
+	return true
+}
+
+// checkProjectQuota 检查用户再创建一个项目（含Fork）是否超出配额，超出时写入403响应并返回false
+func checkProjectQuota(c *gin.Context, db *gorm.DB, userID uint) bool {
+	limits, _, err := effectiveQuota(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to check quota",
+		})
+		return false
+	}
+	if limits.MaxProjects == 0 {
+		return true
+	}
+	
+	var count int64
+	if err := db.Model(&models.Project{}).Where("owner_id = ?", userID).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to check quota",
+		})
+		return false
+	}
+	if count >= int64(limits.MaxProjects) {
+		respondQuotaExceeded(c, "Project", int64(limits.MaxProjects))
+		return false
+	}
+	return true
+}
+
+// checkSensorRowQuota 检查设备拥有者的数据行数配额，超出时写入403响应并返回false
+func checkSensorRowQuota(c *gin.Context, db *gorm.DB, ownerID uint) bool {
+	if limit, exceeded := sensorRowQuotaExceeded(c, db, ownerID); exceeded {
+		respondQuotaExceeded(c, "Sensor data", limit)
+		return false
+	}
+	return true
+}
+
+// sensorRowQuotaExceeded 设备拥有者的数据行数是否已达到配额，返回生效的配额
+// 配额和用量按QUOTA_USAGE_CACHE_TTL缓存，缓存期内的写入会通过计数累加，多实例下可能略微超出配额
+func sensorRowQuotaExceeded(ctx context.Context, db *gorm.DB, ownerID uint) (int64, bool) {
+	cache := database.NewCache()
+	key := database.Keys.QuotaSensorRows(ownerID)
+	
+	var state database.SensorRowQuota
+	if database.RedisClient == nil || cache.Get(ctx, key, &state) != nil {
+		limits, _, err := effectiveQuota(db, ownerID)
+		if err != nil {
+			// 配额检查失败时放行，不因配额服务异常丢弃设备数据
+			return 0, false
+		}
+		state.Limit = limits.MaxSensorRows
+		if state.Limit > 0 {
+			if state.Used, err = userSensorRows(ctx, db, ownerID); err != nil {
+				return 0, false
+			}
+		}
+		if database.RedisClient != nil {
+			cache.Set(ctx, key, state, config.AppConfig.Quota.UsageCacheTTL)
+		}
+	}
+	
+	return state.Limit, state.Limit > 0 && state.Used >= state.Limit
+}
+
+// loadQuotaUsage 统计用户当前用量和生效配额
+func loadQuotaUsage(ctx context.Context, db *gorm.DB, userID uint) (QuotaUsage, error) {
+	var usage QuotaUsage
+	limits, overridden, err := effectiveQuota(db, userID)
+	if err != nil {
+		return usage, err
+	}
+	usage.Limits = limits
+	usage.Overridden = overridden
+	
+	if err := db.Model(&models.Device{}).Where("owner_id = ?", userID).Count(&usage.Devices).Error; err != nil {
+		return usage, err
+	}
+	if err := db.Model(&models.Project{}).Where("owner_id = ?", userID).Count(&usage.Projects).Error; err != nil {
+		return usage, err
+	}
+	if usage.SensorRows, err = userSensorRows(ctx, db, userID); err != nil {
+		return usage, err
+	}
+	return usage, nil
+}
+
+// GetMyUsage 获取当前用户的资源用量与配额
+// @Summary 获取资源用量
+// @Description 返回当前用户的设备数、项目数、传感器数据行数以及生效的配额（0表示不限制）
+// @Tags 认证
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} QuotaUsage
+// @Router /users/me/usage [get]
+func (ctrl *AuthController) GetMyUsage(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	
+	usage, err := loadQuotaUsage(c.Request.Context(), database.GetDBWithContext(c.Request.Context()), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to load usage",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"data":   usage,
+	})
+}
+
+// GetUserQuota 获取指定用户的用量与配额
+// @Summary 获取用户配额
+// @Tags 管理员
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "用户ID"
+// @Success 200 {object} QuotaUsage
+// @Failure 404 {object} map[string]interface{}
+// @Router /admin/users/{id}/quota [get]
+func (ctrl *AuthController) GetUserQuota(c *gin.Context) {
+	user, ok := loadQuotaUser(c)
+	if !ok {
+		return
+	}
+	
+	usage, err := loadQuotaUsage(c.Request.Context(), database.GetDBWithContext(c.Request.Context()), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to load usage",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"data":   usage,
+	})
+}
+
+// UpdateUserQuota 设置指定用户的配额
+// @Summary 设置用户配额
+// @Description 整体替换用户的单独配额，字段为空时沿用全局默认值，0表示不限制
+// @Tags 管理员
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "用户ID"
+// @Param request body UpdateUserQuotaRequest true "配额"
+// @Success 200 {object} QuotaUsage
+// @Failure 404 {object} map[string]interface{}
+// @Router /admin/users/{id}/quota [put]
+func (ctrl *AuthController) UpdateUserQuota(c *gin.Context) {
+	user, ok := loadQuotaUser(c)
+	if !ok {
+		return
+	}
+	
+	var req UpdateUserQuotaRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	quota := models.UserQuota{
+		UserID:        user.ID,
+		MaxDevices:    req.MaxDevices,
+		MaxProjects:   req.MaxProjects,
+		MaxSensorRows: req.MaxSensorRows,
+		UpdatedBy:     middleware.GetUserID(c),
+	}
+	if err := db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&quota).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update quota",
+		})
+		return
+	}
+	database.NewCache().Delete(c, database.Keys.QuotaSensorRows(user.ID))
+	
+	usage, err := loadQuotaUsage(c.Request.Context(), db, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to load usage",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"msg":    "用户配额已更新",
+		"data":   usage,
+	})
+}
+
+// loadQuotaUser 加载路径参数id对应的用户，租户管理员只能管理本租户用户
+func loadQuotaUser(c *gin.Context) (*models.User, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid user ID",
+		})
+		return nil, false
+	}
+	
+	var user models.User
+	if err := database.GetDBWithContext(c.Request.Context()).First(&user, uint(id)).Error; err != nil || !middleware.SameTenant(c, user.ID) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "User not found",
+		})
+		return nil, false
+	}
+	return &user, true
+}
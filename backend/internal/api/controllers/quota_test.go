@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"iot-platform-backend/internal/config"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/ingest"
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/testutil"
+)
+
+func TestSensorRowQuotaExceeded(t *testing.T) {
+	testutil.LoadConfig(t)
+	testutil.UseRedis(t)
+
+	const ownerID = 42
+	c, _ := newTestContext("POST", "/api/v1/devices/d1/data")
+	state := database.SensorRowQuota{Limit: 2, Used: 1}
+	if err := database.NewCache().Set(c, database.Keys.QuotaSensorRows(ownerID), state, time.Minute); err != nil {
+		t.Fatalf("failed to seed quota cache: %v", err)
+	}
+
+	// 缓存命中时不查询数据库，db传nil
+	if !checkSensorRowQuota(c, nil, ownerID) {
+		t.Fatal("write below the quota was rejected")
+	}
+	database.RecordSensorRows(c, ownerID, 1)
+
+	c, w := newTestContext("POST", "/api/v1/devices/d1/data")
+	if checkSensorRowQuota(c, nil, ownerID) {
+		t.Fatal("write at the quota was accepted")
+	}
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if body := decodeBody(t, w); body["error"] != "Quota exceeded" {
+		t.Errorf("error = %v, want Quota exceeded", body["error"])
+	}
+}
+
+func TestCreateDeviceQuota(t *testing.T) {
+	testutil.LoadConfig(t)
+	testutil.UseRedis(t)
+	testutil.UseDB(t)
+	config.AppConfig.Quota.MaxDevices = 1
+
+	user := createTestUser(t, "owner")
+	createTestDevice(t, models.Device{DeviceID: "existing", OwnerID: user.ID})
+
+	create := func(deviceID string) int {
+		body := map[string]interface{}{"device_id": deviceID, "name": deviceID, "type": int(models.SoilMoisture)}
+		c, w := newJSONContext(t, "POST", "/api/v1/devices", body, user)
+		NewDeviceController().CreateDevice(c)
+		return w.Code
+	}
+
+	if status := create("over-quota"); status != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d when the device quota is reached", status, http.StatusForbidden)
+	}
+
+	// 单独配额覆盖全局默认值
+	maxDevices := 2
+	if err := database.DB.Create(&models.UserQuota{UserID: user.ID, MaxDevices: &maxDevices}).Error; err != nil {
+		t.Fatalf("failed to create quota override: %v", err)
+	}
+	if status := create("within-override"); status != http.StatusCreated {
+		t.Fatalf("status = %d, want %d under the raised quota", status, http.StatusCreated)
+	}
+	if status := create("over-override"); status != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d when the raised quota is reached", status, http.StatusForbidden)
+	}
+}
+
+func TestSensorRowQuotaAsyncIngestion(t *testing.T) {
+	cfg := testutil.LoadConfig(t)
+	testutil.UseRedis(t)
+	testutil.UseDB(t)
+	cfg.Quota.MaxSensorRows = 2
+	cfg.Quota.UsageCacheTTL = time.Minute
+
+	previous := ingest.Default
+	ingest.Default = ingest.NewPipeline(config.IngestionConfig{Workers: 1, QueueSize: 10, BatchSize: 1, FlushInterval: time.Hour})
+	ingest.Default.Start()
+	t.Cleanup(func() {
+		ingest.Default.Stop(context.Background())
+		ingest.Default = previous
+	})
+
+	user := createTestUser(t, "owner")
+	createTestDevice(t, models.Device{DeviceID: "meter-1", OwnerID: user.ID})
+
+	post := func(value int) int {
+		t.Helper()
+		c, w := newJSONContext(t, "POST", "/api/v1/devices/meter-1/data", map[string]interface{}{"value": value}, nil)
+		c.Params = gin.Params{{Key: "id", Value: "meter-1"}}
+		NewDeviceController().PostDeviceData(c)
+		return w.Code
+	}
+	for i := 1; i <= 2; i++ {
+		if status := post(i); status != http.StatusAccepted {
+			t.Fatalf("reading %d status = %d, want %d", i, status, http.StatusAccepted)
+		}
+		// 等待批次写入，用量在写入后才累加
+		deadline := time.Now().Add(2 * time.Second)
+		for ingest.Default.Stats().Written < int64(i) {
+			if time.Now().After(deadline) {
+				t.Fatalf("reading %d was not written", i)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	if status := post(3); status != http.StatusForbidden {
+		t.Errorf("reading over the quota status = %d, want %d", status, http.StatusForbidden)
+	}
+}
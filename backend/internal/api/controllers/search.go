@@ -0,0 +1,234 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+	
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+)
+
+const (
+	defaultSearchLimit = 5
+	maxSearchLimit     = 20
+	previewLength      = 120
+)
+
+// searchTypes 支持的搜索类型
+var searchTypes = map[string]bool{
+	"projects": true,
+	"devices":  true,
+}
+
+// SearchController 全局搜索控制器
+type SearchController struct{}
+
+// NewSearchController 创建搜索控制器
+func NewSearchController() *SearchController {
+	return &SearchController{}
+}
+
+// ProjectPreview 项目搜索结果预览
+type ProjectPreview struct {
+	ID          uint      `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Public      bool      `json:"public"`
+	OwnerID     uint      `json:"owner_id"`
+	Tags        []string  `json:"tags"`
+	StarCount   int       `json:"star_count"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// DevicePreview 设备搜索结果预览
+type DevicePreview struct {
+	ID       uint              `json:"id"`
+	DeviceID string            `json:"device_id"`
+	Name     string            `json:"name"`
+	Type     models.DeviceType `json:"type"`
+	TypeName string            `json:"type_name"`
+	Status   string            `json:"status"`
+	Tags     []string          `json:"tags"`
+	LastSeen *time.Time        `json:"last_seen"`
+}
+
+// SearchGroup 单类资源的搜索结果
+type SearchGroup struct {
+	Total int64       `json:"total"`
+	Items interface{} `json:"items"`
+}
+
+// Search 跨项目和设备搜索
+// @Summary 全局搜索
+// @Description 在当前用户可见的项目和自己的设备中搜索，按类型分组返回预览
+// @Tags 搜索
+// @Security BearerAuth
+// @Produce json
+// @Param q query string true "关键词"
+// @Param types query string false "搜索类型，逗号分隔（projects,devices）"
+// @Param limit query int false "每类返回数量" default(5)
+// @Success 200 {object} map[string]SearchGroup
+// @Failure 400 {object} map[string]interface{}
+// @Router /search [get]
+func (ctrl *SearchController) Search(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+		})
+		return
+	}
+	
+	term := strings.TrimSpace(c.Query("q"))
+	if term == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Search query is required",
+		})
+		return
+	}
+	
+	types := map[string]bool{"projects": true, "devices": true}
+	if raw := c.Query("types"); raw != "" {
+		types = make(map[string]bool)
+		for _, t := range strings.Split(raw, ",") {
+			t = strings.TrimSpace(t)
+			if !searchTypes[t] {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": "Unsupported search type: " + t,
+				})
+				return
+			}
+			types[t] = true
+		}
+	}
+	
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultSearchLimit)))
+	if err != nil || limit < 1 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	results := make(map[string]SearchGroup)
+	
+	if types["projects"] {
+		query := db.Model(&models.Project{}).
+			Scopes(visibleProjectsScope(c), projectSearchScope(term))
+		
+		var total int64
+		var projects []models.Project
+		query.Count(&total)
+		if err := query.Order("star_count DESC, updated_at DESC").Limit(limit).Find(&projects).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to search projects",
+			})
+			return
+		}
+		
+		previews := make([]ProjectPreview, 0, len(projects))
+		for _, project := range projects {
+			previews = append(previews, ProjectPreview{
+				ID:          project.ID,
+				Name:        project.Name,
+				Description: truncatePreview(project.Description),
+				Public:      project.Public,
+				OwnerID:     project.OwnerID,
+				Tags:        project.Tags,
+				StarCount:   project.StarCount,
+				UpdatedAt:   project.UpdatedAt,
+			})
+		}
+		results["projects"] = SearchGroup{Total: total, Items: previews}
+	}
+	
+	if types["devices"] {
+		query := db.Model(&models.Device{}).
+			Where("owner_id = ?", userID).
+			Scopes(deviceSearchScope(term))
+		
+		var total int64
+		var devices []models.Device
+		query.Count(&total)
+		if err := query.Order("updated_at DESC").Limit(limit).Find(&devices).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to search devices",
+			})
+			return
+		}
+		
+		previews := make([]DevicePreview, 0, len(devices))
+		now := time.Now()
+		for _, device := range devices {
+			status := device.DisplayStatus(now)
+			previews = append(previews, DevicePreview{
+				ID:       device.ID,
+				DeviceID: device.DeviceID,
+				Name:     device.Name,
+				Type:     device.Type,
+				TypeName: device.TypeName,
+				Status:   status,
+				Tags:     device.Tags,
+				LastSeen: device.LastSeen,
+			})
+		}
+		results["devices"] = SearchGroup{Total: total, Items: previews}
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"query":  term,
+		"data":   results,
+	})
+}
+
+// visibleProjectsScope 限定为当前用户可见的项目（自己的、公开的或参与协作的）；
+// 管理员额外可见本租户用户的全部项目，平台级管理员（token不属于任何租户）不限
+func visibleProjectsScope(c *gin.Context) func(*gorm.DB) *gorm.DB {
+	userID := middleware.GetUserID(c)
+	isAdmin := middleware.IsAdmin(c)
+	tenantID := middleware.GetTenantID(c)
+	return func(db *gorm.DB) *gorm.DB {
+		visible := "owner_id = ? OR public = ? OR id IN (SELECT project_id FROM project_collaborators WHERE user_id = ?)"
+		switch {
+		case !isAdmin:
+			return db.Where(visible, userID, true, userID)
+		case tenantID != "":
+			return db.Where(visible+" OR owner_id IN (SELECT id FROM users WHERE tenant_id = ?)", userID, true, userID, tenantID)
+		default:
+			return db
+		}
+	}
+}
+
+// projectSearchScope 按名称、描述模糊匹配或标签精确匹配项目
+func projectSearchScope(term string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		pattern := "%" + term + "%"
+		return db.Where("name ILIKE ? OR description ILIKE ? OR ? = ANY(tags)", pattern, pattern, term)
+	}
+}
+
+// deviceSearchScope 按名称、设备标识模糊匹配或标签精确匹配设备
+func deviceSearchScope(term string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		pattern := "%" + term + "%"
+		return db.Where("name ILIKE ? OR device_id ILIKE ? OR ? = ANY(tags)", pattern, pattern, term)
+	}
+}
+
+// truncatePreview 截断描述用于预览
+func truncatePreview(text string) string {
+	if utf8.RuneCountInString(text) <= previewLength {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:previewLength]) + "..."
+}
@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	
+	"github.com/gin-gonic/gin"
+	"iot-platform-backend/internal/config"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+)
+
+// 会话Cookie名称，access_token同时被认证中间件读取
+const (
+	accessTokenCookie  = "access_token"
+	refreshTokenCookie = "refresh_token"
+)
+
+// refreshCookiePath refresh token Cookie的路径，只随同一API版本的刷新和登出请求发送
+func refreshCookiePath(version string) string {
+	return "/api/" + version + "/auth"
+}
+
+// SessionTokens 签发的一组会话token
+type SessionTokens struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int64  `json:"expires_in"`         // access token有效期（秒）
+	RefreshExpiresIn int64  `json:"refresh_expires_in"` // refresh token有效期（秒）
+	Remembered       bool   `json:"remembered"`
+	SessionID        string `json:"session_id"`
+}
+
+// SessionInfo 会话列表中的一项
+type SessionInfo struct {
+	database.Session
+	Current bool `json:"current"` // 是否为发起请求的会话
+}
+
+// issueSession 为用户签发access/refresh token，“记住我”会话同时写入httpOnly Cookie
+// sessionID为空时创建新会话（登录），否则续期已有会话（刷新），会话记录的有效期与refresh token一致
+func issueSession(c *gin.Context, user *models.User, remember bool, sessionID string) (SessionTokens, error) {
+	accessTTL, refreshTTL := middleware.SessionLifetimes(remember)
+	
+	now := time.Now()
+	session := &database.Session{
+		ID:        sessionID,
+		UserID:    user.ID,
+		Remember:  remember,
+		CreatedAt: now,
+	}
+	if sessionID == "" {
+		id, err := middleware.NewSessionID()
+		if err != nil {
+			return SessionTokens{}, err
+		}
+		session.ID = id
+	} else if existing, err := database.GetSession(c, sessionID); err == nil {
+		session.CreatedAt = existing.CreatedAt
+	}
+	session.UserAgent = c.GetHeader("User-Agent")
+	session.IP = c.ClientIP()
+	session.LastUsedAt = now
+	session.ExpiresAt = now.Add(refreshTTL)
+	
+	accessToken, err := middleware.GenerateToken(user.ID, user.Username, user.Role, user.TenantID, session.ID, remember)
+	if err != nil {
+		return SessionTokens{}, err
+	}
+	refreshToken, err := middleware.GenerateRefreshToken(user.ID, user.TenantID, session.ID, remember)
+	if err != nil {
+		return SessionTokens{}, err
+	}
+	if err := database.SaveSession(c, session, refreshTTL); err != nil {
+		return SessionTokens{}, err
+	}
+	
+	if remember {
+		setSessionCookies(c, accessToken, refreshToken, accessTTL, refreshTTL)
+	}
+	
+	return SessionTokens{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		ExpiresIn:        int64(accessTTL / time.Second),
+		RefreshExpiresIn: int64(refreshTTL / time.Second),
+		Remembered:       remember,
+		SessionID:        session.ID,
+	}, nil
+}
+
+// setSessionCookies 写入会话Cookie（HttpOnly，Secure和SameSite按配置）
+func setSessionCookies(c *gin.Context, accessToken, refreshToken string, accessTTL, refreshTTL time.Duration) {
+	cfg := config.AppConfig.JWT
+	c.SetSameSite(cookieSameSite(cfg.CookieSameSite))
+	c.SetCookie(accessTokenCookie, accessToken, int(accessTTL/time.Second), "/", cfg.CookieDomain, cfg.CookieSecure, true)
+	c.SetCookie(refreshTokenCookie, refreshToken, int(refreshTTL/time.Second), refreshCookiePath(middleware.RequestAPIVersion(c)), cfg.CookieDomain, cfg.CookieSecure, true)
+}
+
+// clearSessionCookies 删除会话Cookie
+func clearSessionCookies(c *gin.Context) {
+	cfg := config.AppConfig.JWT
+	c.SetSameSite(cookieSameSite(cfg.CookieSameSite))
+	c.SetCookie(accessTokenCookie, "", -1, "/", cfg.CookieDomain, cfg.CookieSecure, true)
+	// 登录时所用的API版本未知，删除所有版本路径下的refresh token
+	for _, version := range middleware.APIVersions {
+		c.SetCookie(refreshTokenCookie, "", -1, refreshCookiePath(version), cfg.CookieDomain, cfg.CookieSecure, true)
+	}
+}
+
+// cookieSameSite 将配置值转换为http.SameSite
+func cookieSameSite(value string) http.SameSite {
+	switch value {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+// revokeToken 将token加入黑名单直至其自然过期
+func revokeToken(c *gin.Context, token string, claims *middleware.Claims) {
+	ttl := config.AppConfig.JWT.RefreshExpires
+	if claims != nil && claims.ExpiresAt != nil {
+		ttl = time.Until(claims.ExpiresAt.Time)
+	}
+	if ttl <= 0 {
+		return
+	}
+	database.NewCache().Set(c, database.Keys.TokenBlacklist(token), true, ttl)
+}
+
+// ListSessions 列出当前用户的有效会话
+// @Summary 列出登录会话
+// @Description 列出当前用户所有未过期、未吊销的登录会话（设备、IP、最近使用时间），current标记发起请求的会话
+// @Tags 认证
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} SessionInfo
+// @Router /auth/sessions [get]
+func (ctrl *AuthController) ListSessions(c *gin.Context) {
+	sessions, err := database.ListUserSessions(c, middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list sessions",
+		})
+		return
+	}
+	
+	current := middleware.GetSessionID(c)
+	items := make([]SessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		items = append(items, SessionInfo{
+			Session: session,
+			Current: session.ID == current,
+		})
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"msg":    "获取成功",
+		"data":   items,
+	})
+}
+
+// RevokeSession 吊销当前用户的指定会话
+// @Summary 吊销登录会话
+// @Description 吊销当前用户的一个会话，该会话的access token和refresh token立即失效；吊销当前会话等同于登出
+// @Tags 认证
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "会话ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /auth/sessions/{id} [delete]
+func (ctrl *AuthController) RevokeSession(c *gin.Context) {
+	sessionID := c.Param("id")
+	if err := database.DeleteSession(c, middleware.GetUserID(c), sessionID); err != nil {
+		if errors.Is(err, database.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Session not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to revoke session",
+		})
+		return
+	}
+	if sessionID == middleware.GetSessionID(c) {
+		clearSessionCookies(c)
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"msg":    "会话已吊销",
+	})
+}
@@ -0,0 +1,270 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/testutil"
+)
+
+// loginSession 模拟一次登录，返回签发的token及其声明
+func loginSession(t *testing.T, user *models.User, userAgent string) (SessionTokens, *middleware.Claims, *middleware.Claims) {
+	t.Helper()
+	c, _ := newTestContext("POST", "/api/v1/auth/login")
+	c.Request.Header.Set("User-Agent", userAgent)
+	tokens, err := issueSession(c, user, false, "")
+	if err != nil {
+		t.Fatalf("issueSession() = %v", err)
+	}
+
+	access, err := middleware.ParseToken(tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("failed to parse access token: %v", err)
+	}
+	refresh, err := middleware.ParseToken(tokens.RefreshToken)
+	if err != nil {
+		t.Fatalf("failed to parse refresh token: %v", err)
+	}
+	return tokens, access, refresh
+}
+
+// sessionContext 构造由指定会话发起的请求上下文
+func sessionContext(t *testing.T, method, target string, user *models.User, sessionID string) (*gin.Context, *httptest.ResponseRecorder) {
+	t.Helper()
+	c, w := newJSONContext(t, method, target, nil, user)
+	c.Set("session_id", sessionID)
+	return c, w
+}
+
+func TestRevokeSessionKeepsOtherSessions(t *testing.T) {
+	testutil.LoadConfig(t)
+	testutil.UseRedis(t)
+
+	user := &models.User{ID: 7, Username: "alice", Role: "user"}
+	laptop, laptopAccess, laptopRefresh := loginSession(t, user, "laptop")
+	phone, phoneAccess, phoneRefresh := loginSession(t, user, "phone")
+	if laptop.SessionID == phone.SessionID {
+		t.Fatal("two logins share a session ID")
+	}
+
+	// 从手机会话吊销笔记本会话
+	c, w := sessionContext(t, "DELETE", "/api/v1/auth/sessions/"+laptop.SessionID, user, phone.SessionID)
+	c.Params = gin.Params{{Key: "id", Value: laptop.SessionID}}
+	(&AuthController{}).RevokeSession(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+
+	c, _ = newTestContext("GET", "/api/v1/auth/me")
+	if !middleware.IsRevoked(c, laptop.AccessToken, laptopAccess) || !middleware.IsRevoked(c, laptop.RefreshToken, laptopRefresh) {
+		t.Error("tokens of the revoked session are still valid")
+	}
+	if middleware.IsRevoked(c, phone.AccessToken, phoneAccess) || middleware.IsRevoked(c, phone.RefreshToken, phoneRefresh) {
+		t.Error("tokens of the remaining session were revoked")
+	}
+
+	c, w = sessionContext(t, "GET", "/api/v1/auth/sessions", user, phone.SessionID)
+	(&AuthController{}).ListSessions(c)
+	sessions := decodeBody(t, w)["data"].([]interface{})
+	if len(sessions) != 1 {
+		t.Fatalf("got %d sessions, want 1", len(sessions))
+	}
+	remaining := sessions[0].(map[string]interface{})
+	if remaining["id"] != phone.SessionID || remaining["current"] != true {
+		t.Errorf("remaining session = %v, want the current phone session", remaining)
+	}
+}
+
+func TestRevokeSessionOfAnotherUser(t *testing.T) {
+	testutil.LoadConfig(t)
+	testutil.UseRedis(t)
+
+	owner := &models.User{ID: 7, Username: "alice", Role: "user"}
+	other := &models.User{ID: 8, Username: "bob", Role: "user"}
+	session, access, _ := loginSession(t, owner, "laptop")
+
+	c, w := newJSONContext(t, "DELETE", "/api/v1/auth/sessions/"+session.SessionID, nil, other)
+	c.Params = gin.Params{{Key: "id", Value: session.SessionID}}
+	(&AuthController{}).RevokeSession(c)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if middleware.IsRevoked(c, session.AccessToken, access) {
+		t.Error("another user revoked the session")
+	}
+}
+
+// responseCookies 按名称和路径索引响应中的Set-Cookie
+func responseCookies(w *httptest.ResponseRecorder) map[string]*http.Cookie {
+	cookies := make(map[string]*http.Cookie)
+	for _, cookie := range w.Result().Cookies() {
+		cookies[cookie.Name+" "+cookie.Path] = cookie
+	}
+	return cookies
+}
+
+func TestIssueSessionRememberMe(t *testing.T) {
+	cfg := testutil.LoadConfig(t)
+	testutil.UseRedis(t)
+	cfg.JWT.Expires = 24 * time.Hour
+	cfg.JWT.RefreshExpires = 30 * 24 * time.Hour
+	cfg.JWT.SessionExpires = time.Hour
+	cfg.JWT.SessionRefreshExpires = 12 * time.Hour
+	cfg.JWT.CookieSecure = true
+	cfg.JWT.CookieSameSite = "strict"
+	user := &models.User{ID: 7, Username: "alice", Role: "user"}
+
+	tests := []struct {
+		remember              bool
+		accessTTL, refreshTTL time.Duration
+	}{
+		{false, time.Hour, 12 * time.Hour},
+		{true, 24 * time.Hour, 30 * 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("remember=%v", tt.remember), func(t *testing.T) {
+			c, w := newTestContext("POST", "/api/v2/auth/login")
+			tokens, err := issueSession(c, user, tt.remember, "")
+			if err != nil {
+				t.Fatalf("issueSession() = %v", err)
+			}
+			if tokens.Remembered != tt.remember ||
+				tokens.ExpiresIn != int64(tt.accessTTL/time.Second) ||
+				tokens.RefreshExpiresIn != int64(tt.refreshTTL/time.Second) {
+				t.Errorf("tokens = %+v, want remembered=%v and lifetimes %s/%s", tokens, tt.remember, tt.accessTTL, tt.refreshTTL)
+			}
+
+			refresh, err := middleware.ParseToken(tokens.RefreshToken)
+			if err != nil {
+				t.Fatalf("failed to parse refresh token: %v", err)
+			}
+			if refresh.Remember != tt.remember {
+				t.Errorf("refresh token remember = %v, want %v", refresh.Remember, tt.remember)
+			}
+			if lifetime := refresh.ExpiresAt.Sub(refresh.IssuedAt.Time); lifetime != tt.refreshTTL {
+				t.Errorf("refresh token lifetime = %s, want %s", lifetime, tt.refreshTTL)
+			}
+
+			// 只有“记住我”会话下发Cookie
+			cookies := responseCookies(w)
+			if !tt.remember {
+				if len(cookies) != 0 {
+					t.Errorf("cookies = %v, want none", cookies)
+				}
+				return
+			}
+			for key, want := range map[string]struct {
+				value  string
+				maxAge time.Duration
+			}{
+				"access_token /":             {tokens.AccessToken, tt.accessTTL},
+				"refresh_token /api/v2/auth": {tokens.RefreshToken, tt.refreshTTL},
+			} {
+				cookie, ok := cookies[key]
+				if !ok {
+					t.Errorf("cookie %s is missing from %v", key, cookies)
+					continue
+				}
+				if cookie.Value != want.value || cookie.MaxAge != int(want.maxAge/time.Second) {
+					t.Errorf("cookie %s value/max-age = %q/%d, want the issued token and %d", key, cookie.Value, cookie.MaxAge, int(want.maxAge/time.Second))
+				}
+				if !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteStrictMode {
+					t.Errorf("cookie %s HttpOnly=%v Secure=%v SameSite=%v, want HttpOnly, Secure, Strict", key, cookie.HttpOnly, cookie.Secure, cookie.SameSite)
+				}
+			}
+		})
+	}
+}
+
+func TestCookieSameSite(t *testing.T) {
+	for value, want := range map[string]http.SameSite{
+		"lax":    http.SameSiteLaxMode,
+		"strict": http.SameSiteStrictMode,
+		"none":   http.SameSiteNoneMode,
+		"":       http.SameSiteLaxMode,
+	} {
+		if got := cookieSameSite(value); got != want {
+			t.Errorf("cookieSameSite(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestClearSessionCookies(t *testing.T) {
+	testutil.LoadConfig(t)
+	c, w := newTestContext("POST", "/api/v1/auth/logout")
+	clearSessionCookies(c)
+
+	cookies := responseCookies(w)
+	keys := []string{"access_token /"}
+	for _, version := range middleware.APIVersions {
+		keys = append(keys, "refresh_token "+refreshCookiePath(version))
+	}
+	for _, key := range keys {
+		if cookie, ok := cookies[key]; !ok || cookie.MaxAge >= 0 || cookie.Value != "" {
+			t.Errorf("cookie %s = %+v, want it expired", key, cookie)
+		}
+	}
+}
+
+func TestRefreshTokenSlidesRememberedSession(t *testing.T) {
+	testutil.LoadConfig(t)
+	testutil.UseRedis(t)
+	testutil.UseDB(t)
+	createTestUser(t, "alice")
+
+	c, w := newJSONContext(t, "POST", "/api/v1/auth/login", LoginRequest{Username: "alice", Password: "secret123", RememberMe: true}, nil)
+	NewAuthController().Login(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("login status = %d: %s", w.Code, w.Body.String())
+	}
+	login := responseCookies(w)
+	refreshCookie := login["refresh_token /api/v1/auth"]
+	if refreshCookie == nil || login["access_token /"] == nil {
+		t.Fatalf("login cookies = %v, want access and refresh tokens", login)
+	}
+	original, err := middleware.ParseToken(refreshCookie.Value)
+	if err != nil {
+		t.Fatalf("failed to parse refresh token: %v", err)
+	}
+
+	refresh := func(token string) *httptest.ResponseRecorder {
+		t.Helper()
+		c, w := newTestContext("POST", "/api/v1/auth/refresh")
+		c.Request.AddCookie(&http.Cookie{Name: refreshTokenCookie, Value: token})
+		NewAuthController().RefreshToken(c)
+		return w
+	}
+
+	// 通过Cookie刷新：会话ID不变，仍为“记住我”会话并重新下发Cookie
+	w = refresh(refreshCookie.Value)
+	if w.Code != http.StatusOK {
+		t.Fatalf("refresh status = %d: %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Data SessionTokens `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Data.SessionID != original.SessionID || !body.Data.Remembered {
+		t.Errorf("refreshed session = %+v, want session %s still remembered", body.Data, original.SessionID)
+	}
+	if rotated := responseCookies(w)["refresh_token /api/v1/auth"]; rotated == nil || rotated.Value != body.Data.RefreshToken {
+		t.Errorf("refresh cookie = %+v, want the rotated refresh token", rotated)
+	}
+
+	// 旧的refresh token已被轮换吊销
+	if w := refresh(refreshCookie.Value); w.Code != http.StatusUnauthorized {
+		t.Errorf("reusing the old refresh token status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w := refresh(body.Data.RefreshToken); w.Code != http.StatusOK {
+		t.Errorf("refresh with the rotated token status = %d: %s", w.Code, w.Body.String())
+	}
+}
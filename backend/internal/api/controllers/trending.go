@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	
+	"github.com/gin-gonic/gin"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/models"
+)
+
+// 热门项目评分权重
+const (
+	trendingStarWeight = 3.0
+	trendingForkWeight = 5.0
+	trendingViewWeight = 1.0
+)
+
+const (
+	defaultTrendingWindow = "7d"
+	maxTrendingWindow     = 30 * 24 * time.Hour
+	defaultTrendingLimit  = 20
+	maxTrendingLimit      = 100
+	trendingCacheTTL      = 5 * time.Minute
+)
+
+// TrendingProject 热门项目（包含窗口期内的活跃度统计）
+type TrendingProject struct {
+	models.Project
+	TrendingScore float64 `json:"trending_score"`
+	RecentStars   int64   `json:"recent_stars"`
+	RecentForks   int64   `json:"recent_forks"`
+	RecentViews   int64   `json:"recent_views"`
+}
+
+// projectActivityCount 按项目分组的活动计数
+type projectActivityCount struct {
+	ProjectID uint
+	Count     int64
+}
+
+// GetTrendingProjects 获取热门公开项目
+// @Summary 热门项目
+// @Description 按窗口期内新增的Star、Fork和浏览次数计算热度排名（非累计值）
+// @Tags 项目管理
+// @Produce json
+// @Param window query string false "统计窗口，如24h、7d、30d" default(7d)
+// @Param limit query int false "返回数量" default(20)
+// @Success 200 {array} TrendingProject
+// @Failure 400 {object} map[string]interface{}
+// @Router /public/trending [get]
+func (ctrl *ProjectController) GetTrendingProjects(c *gin.Context) {
+	window := c.DefaultQuery("window", defaultTrendingWindow)
+	duration, err := parseTrendingWindow(window)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid window",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultTrendingLimit)))
+	if err != nil || limit < 1 {
+		limit = defaultTrendingLimit
+	}
+	if limit > maxTrendingLimit {
+		limit = maxTrendingLimit
+	}
+	
+	// 计算代价较高，优先读取缓存
+	cache := database.NewCache()
+	cacheKey := database.Keys.Trending(window, limit)
+	var cached []TrendingProject
+	if err := cache.Get(c, cacheKey, &cached); err == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status": 1,
+			"data":   cached,
+			"window": window,
+		})
+		return
+	}
+	
+	trending, err := computeTrendingProjects(c, time.Now().Add(-duration), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to compute trending projects",
+		})
+		return
+	}
+	
+	cache.Set(c, cacheKey, trending, trendingCacheTTL)
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"data":   trending,
+		"window": window,
+	})
+}
+
+// computeTrendingProjects 汇总since之后的Star、Fork和浏览次数并按热度排序
+func computeTrendingProjects(c *gin.Context, since time.Time, limit int) ([]TrendingProject, error) {
+	db := database.GetDBWithContext(c.Request.Context())
+	
+	var stars []projectActivityCount
+	if err := db.Model(&models.ProjectStar{}).
+		Select("project_id, COUNT(*) AS count").
+		Where("created_at >= ?", since).
+		Group("project_id").
+		Scan(&stars).Error; err != nil {
+		return nil, err
+	}
+	
+	// Fork历史记录在新项目上，通过parent_id归属到源项目
+	var forks []projectActivityCount
+	if err := db.Table("fork_history").
+		Select("projects.parent_id AS project_id, COUNT(*) AS count").
+		Joins("JOIN projects ON projects.id = fork_history.project_id").
+		Where("fork_history.action = ? AND fork_history.created_at >= ? AND projects.parent_id IS NOT NULL", "fork", since).
+		Group("projects.parent_id").
+		Scan(&forks).Error; err != nil {
+		return nil, err
+	}
+	
+	views, err := database.GetProjectViewsSince(c, since)
+	if err != nil {
+		return nil, err
+	}
+	
+	activity := make(map[uint]*TrendingProject)
+	entry := func(projectID uint) *TrendingProject {
+		if activity[projectID] == nil {
+			activity[projectID] = &TrendingProject{}
+		}
+		return activity[projectID]
+	}
+	for _, star := range stars {
+		entry(star.ProjectID).RecentStars = star.Count
+	}
+	for _, fork := range forks {
+		entry(fork.ProjectID).RecentForks = fork.Count
+	}
+	for projectID, count := range views {
+		entry(projectID).RecentViews = count
+	}
+	
+	trending := make([]TrendingProject, 0)
+	if len(activity) == 0 {
+		return trending, nil
+	}
+	
+	ids := make([]uint, 0, len(activity))
+	for projectID := range activity {
+		ids = append(ids, projectID)
+	}
+	
+	var projects []models.Project
+	if err := db.Where("id IN ? AND public = ?", ids, true).
+		Preload("Owner").
+		Find(&projects).Error; err != nil {
+		return nil, err
+	}
+	
+	for _, project := range projects {
+		item := activity[project.ID]
+		item.Project = project
+		item.TrendingScore = float64(item.RecentStars)*trendingStarWeight +
+			float64(item.RecentForks)*trendingForkWeight +
+			float64(item.RecentViews)*trendingViewWeight
+		if item.TrendingScore > 0 {
+			trending = append(trending, *item)
+		}
+	}
+	
+	sort.Slice(trending, func(i, j int) bool {
+		if trending[i].TrendingScore != trending[j].TrendingScore {
+			return trending[i].TrendingScore > trending[j].TrendingScore
+		}
+		return trending[i].ID > trending[j].ID
+	})
+	
+	if len(trending) > limit {
+		trending = trending[:limit]
+	}
+	return trending, nil
+}
+
+// parseTrendingWindow 解析统计窗口，支持按天（7d）或Go时长格式（24h）
+func parseTrendingWindow(window string) (time.Duration, error) {
+	duration, err := parseDayDuration(window)
+	if err != nil {
+		return 0, err
+	}
+	if duration <= 0 || duration > maxTrendingWindow {
+		return 0, fmt.Errorf("window must be positive and at most 30d")
+	}
+	return duration, nil
+}
+
+// parseDayDuration 解析时长，除Go时长格式（24h）外还支持按天（7d）
+func parseDayDuration(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid day count: %s", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration: %s", value)
+	}
+	return parsed, nil
+}
@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"iot-platform-backend/internal/models"
+)
+
+// validationMessages 校验错误提示模板，按语言和校验规则索引
+// 带类型后缀的键（如min.string）优先于通用键（如min）
+var validationMessages = map[string]map[string]string{
+	"en": {
+		"required":   "is required",
+		"email":      "must be a valid email address",
+		"url":        "must be a valid URL",
+		"oneof":      "must be one of: %s",
+		"min.string": "must be at least %s characters",
+		"max.string": "must be at most %s characters",
+		"len.string": "must be exactly %s characters",
+		"min.slice":  "must contain at least %s items",
+		"max.slice":  "must contain at most %s items",
+		"min":        "must be at least %s",
+		"max":        "must be at most %s",
+		"gte":        "must be greater than or equal to %s",
+		"lte":        "must be less than or equal to %s",
+		"gt":         "must be greater than %s",
+		"lt":         "must be less than %s",
+		"device_type": "must be a valid device type (see GET /devices/types)",
+		"default":    "is invalid",
+	},
+	"zh": {
+		"required":   "不能为空",
+		"email":      "必须是有效的邮箱地址",
+		"url":        "必须是有效的URL",
+		"oneof":      "必须是以下值之一: %s",
+		"min.string": "长度不能少于%s个字符",
+		"max.string": "长度不能超过%s个字符",
+		"len.string": "长度必须为%s个字符",
+		"min.slice":  "至少需要%s项",
+		"max.slice":  "最多允许%s项",
+		"min":        "不能小于%s",
+		"max":        "不能大于%s",
+		"gte":        "必须大于或等于%s",
+		"lte":        "必须小于或等于%s",
+		"gt":         "必须大于%s",
+		"lt":         "必须小于%s",
+		"device_type": "必须是有效的设备类型（见GET /devices/types）",
+		"default":    "格式不正确",
+	},
+}
+
+var registerTagNameOnce sync.Once
+
+// RegisterValidationMessage 注册或覆盖某语言下指定校验规则的提示模板
+func RegisterValidationMessage(locale, tag, template string) {
+	if validationMessages[locale] == nil {
+		validationMessages[locale] = make(map[string]string)
+	}
+	validationMessages[locale][tag] = template
+}
+
+// bindJSON 绑定并校验JSON请求体，失败时返回400和结构化的字段错误
+func bindJSON(c *gin.Context, obj interface{}) bool {
+	registerJSONTagNames()
+	
+	err := c.ShouldBindJSON(obj)
+	if err == nil {
+		return true
+	}
+	
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "Validation failed",
+			"errors": translateValidationErrors(validationErrors, requestLocale(c)),
+		})
+		return false
+	}
+	
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":   "Invalid request format",
+		"details": err.Error(),
+	})
+	return false
+}
+
+// translateValidationErrors 将校验错误转换为 字段->提示 映射
+func translateValidationErrors(errs validator.ValidationErrors, locale string) map[string]string {
+	messages, ok := validationMessages[locale]
+	if !ok {
+		messages = validationMessages["en"]
+	}
+	
+	result := make(map[string]string, len(errs))
+	for _, fe := range errs {
+		field := fieldPath(fe)
+		if _, exists := result[field]; exists {
+			continue
+		}
+		result[field] = formatFieldError(fe, messages)
+	}
+	return result
+}
+
+// formatFieldError 根据校验规则和字段类型生成提示
+func formatFieldError(fe validator.FieldError, messages map[string]string) string {
+	template, ok := messages[fe.Tag()+"."+kindName(fe.Kind())]
+	if !ok {
+		template, ok = messages[fe.Tag()]
+	}
+	if !ok {
+		template = messages["default"]
+	}
+	
+	if strings.Contains(template, "%s") {
+		return fmt.Sprintf(template, fe.Param())
+	}
+	return template
+}
+
+// fieldPath 去掉顶层结构体名称，得到如 location.lat 形式的字段路径
+func fieldPath(fe validator.FieldError) string {
+	namespace := fe.Namespace()
+	if idx := strings.Index(namespace, "."); idx >= 0 {
+		namespace = namespace[idx+1:]
+	}
+	return namespace
+}
+
+// kindName 将字段类型归类为string、slice或number
+func kindName(kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return "slice"
+	default:
+		return "number"
+	}
+}
+
+// requestLocale 根据Accept-Language选择提示语言
+func requestLocale(c *gin.Context) string {
+	if strings.HasPrefix(strings.ToLower(c.GetHeader("Accept-Language")), "zh") {
+		return "zh"
+	}
+	return "en"
+}
+
+// registerJSONTagNames 让校验错误使用json标签作为字段名，并注册自定义校验规则
+func registerJSONTagNames() {
+	registerTagNameOnce.Do(func() {
+		v, ok := binding.Validator.Engine().(*validator.Validate)
+		if !ok {
+			return
+		}
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return field.Name
+			}
+			return name
+		})
+		// device_type：合法类型以models.DeviceTypeNames为准，新增类型时无需修改各请求的取值范围
+		v.RegisterValidation("device_type", func(fl validator.FieldLevel) bool {
+			return models.DeviceType(fl.Field().Int()).Valid()
+		})
+	})
+}
@@ -0,0 +1,359 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+	
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+	"iot-platform-backend/internal/config"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/webhook"
+)
+
+// WebhookController Webhook管理控制器
+type WebhookController struct{}
+
+// NewWebhookController 创建Webhook控制器
+func NewWebhookController() *WebhookController {
+	return &WebhookController{}
+}
+
+// CreateWebhookRequest 创建Webhook请求
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" binding:"required,url,max=2048"`
+	Events []string `json:"events" binding:"required,min=1,dive,oneof=device.data device.offline alert.fired"`
+	Secret string   `json:"secret" binding:"omitempty,min=16,max=128"` // 为空时自动生成
+}
+
+// UpdateWebhookRequest 更新Webhook请求
+type UpdateWebhookRequest struct {
+	URL     *string  `json:"url" binding:"omitempty,url,max=2048"`
+	Events  []string `json:"events" binding:"omitempty,min=1,dive,oneof=device.data device.offline alert.fired"`
+	Enabled *bool    `json:"enabled"`
+}
+
+// CreateWebhookResponse 创建Webhook响应，签名密钥仅在此时返回
+type CreateWebhookResponse struct {
+	Webhook models.Webhook `json:"webhook"`
+	Secret  string         `json:"secret"`
+}
+
+// WebhookDeliveryListResponse 投递记录列表响应
+type WebhookDeliveryListResponse struct {
+	Deliveries []models.WebhookDelivery `json:"deliveries"`
+	Total      int64                    `json:"total"`
+	Page       int                      `json:"page"`
+	Limit      int                      `json:"limit"`
+}
+
+// GetWebhooks 获取当前用户的Webhook列表
+// @Summary 获取Webhook列表
+// @Tags Webhook
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} []models.Webhook
+// @Router /webhooks [get]
+func (ctrl *WebhookController) GetWebhooks(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	
+	var hooks []models.Webhook
+	if err := database.GetDBWithContext(c.Request.Context()).
+		Where("owner_id = ?", userID).
+		Order("created_at DESC").
+		Find(&hooks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch webhooks",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"data":   hooks,
+	})
+}
+
+// CreateWebhook 创建Webhook
+// @Summary 创建Webhook
+// @Description 订阅设备事件，事件以带HMAC签名的POST请求投递到指定地址；不允许内网地址
+// @Tags Webhook
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body CreateWebhookRequest true "Webhook信息"
+// @Success 201 {object} CreateWebhookResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 422 {object} map[string]interface{}
+// @Router /webhooks [post]
+func (ctrl *WebhookController) CreateWebhook(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	
+	var req CreateWebhookRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	
+	if err := webhook.ValidateURL(c.Request.Context(), req.URL, config.AppConfig.Webhook.AllowPrivate); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Invalid webhook URL",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	secret := req.Secret
+	if secret == "" {
+		raw := make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to generate secret",
+			})
+			return
+		}
+		secret = hex.EncodeToString(raw)
+	}
+	
+	hook := models.Webhook{
+		OwnerID: userID,
+		URL:     req.URL,
+		Events:  pq.StringArray(uniqueStrings(req.Events)),
+		Secret:  secret,
+		Enabled: true,
+	}
+	if err := database.GetDBWithContext(c.Request.Context()).Create(&hook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create webhook",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusCreated, gin.H{
+		"status": 1,
+		"msg":    "Webhook创建成功",
+		"data":   CreateWebhookResponse{Webhook: hook, Secret: secret},
+	})
+}
+
+// UpdateWebhook 更新Webhook地址、订阅事件或启用状态
+// @Summary 更新Webhook
+// @Tags Webhook
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param request body UpdateWebhookRequest true "更新内容"
+// @Success 200 {object} models.Webhook
+// @Failure 404 {object} map[string]interface{}
+// @Failure 422 {object} map[string]interface{}
+// @Router /webhooks/{id} [put]
+func (ctrl *WebhookController) UpdateWebhook(c *gin.Context) {
+	hook, ok := loadOwnedWebhook(c)
+	if !ok {
+		return
+	}
+	
+	var req UpdateWebhookRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	
+	if req.URL != nil {
+		if err := webhook.ValidateURL(c.Request.Context(), *req.URL, config.AppConfig.Webhook.AllowPrivate); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":   "Invalid webhook URL",
+				"details": err.Error(),
+			})
+			return
+		}
+		hook.URL = *req.URL
+	}
+	if len(req.Events) > 0 {
+		hook.Events = pq.StringArray(uniqueStrings(req.Events))
+	}
+	if req.Enabled != nil {
+		hook.Enabled = *req.Enabled
+	}
+	
+	if err := database.GetDBWithContext(c.Request.Context()).
+		Select("url", "events", "enabled", "updated_at").
+		Save(hook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update webhook",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"msg":    "Webhook更新成功",
+		"data":   hook,
+	})
+}
+
+// DeleteWebhook 删除Webhook及其投递记录
+// @Summary 删除Webhook
+// @Tags Webhook
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /webhooks/{id} [delete]
+func (ctrl *WebhookController) DeleteWebhook(c *gin.Context) {
+	hook, ok := loadOwnedWebhook(c)
+	if !ok {
+		return
+	}
+	
+	err := database.TransactionWithContext(c.Request.Context(), func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", hook.ID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(hook).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete webhook",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"msg":    "Webhook删除成功",
+	})
+}
+
+// GetWebhookDeliveries 获取Webhook投递记录
+// @Summary 获取Webhook投递记录
+// @Tags Webhook
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param status query string false "按状态过滤（pending, succeeded, dead）"
+// @Param page query int false "页码"
+// @Param limit query int false "每页数量"
+// @Success 200 {object} WebhookDeliveryListResponse
+// @Failure 404 {object} map[string]interface{}
+// @Router /webhooks/{id}/deliveries [get]
+func (ctrl *WebhookController) GetWebhookDeliveries(c *gin.Context) {
+	hook, ok := loadOwnedWebhook(c)
+	if !ok {
+		return
+	}
+	
+	pagination := parseListPagination(c)
+	query := database.GetDBWithContext(c.Request.Context()).
+		Model(&models.WebhookDelivery{}).
+		Where("webhook_id = ?", hook.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	
+	var total int64
+	query.Count(&total)
+	
+	var deliveries []models.WebhookDelivery
+	if err := query.Order("created_at DESC").
+		Offset(pagination.Offset).
+		Limit(pagination.Limit).
+		Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch deliveries",
+		})
+		return
+	}
+	
+	setPaginationHeaders(c, total, pagination.Page, pagination.Limit)
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"data": WebhookDeliveryListResponse{
+			Deliveries: deliveries,
+			Total:      total,
+			Page:       pagination.Page,
+			Limit:      pagination.Limit,
+		},
+	})
+}
+
+// RetryWebhookDelivery 重新投递一条记录（通常用于dead记录）
+// @Summary 重新投递
+// @Tags Webhook
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param delivery_id path int true "投递记录ID"
+// @Success 200 {object} models.WebhookDelivery
+// @Failure 404 {object} map[string]interface{}
+// @Router /webhooks/{id}/deliveries/{delivery_id}/retry [post]
+func (ctrl *WebhookController) RetryWebhookDelivery(c *gin.Context) {
+	hook, ok := loadOwnedWebhook(c)
+	if !ok {
+		return
+	}
+	
+	deliveryID, err := strconv.ParseUint(c.Param("delivery_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid delivery ID",
+		})
+		return
+	}
+	
+	db := database.GetDBWithContext(c.Request.Context())
+	var delivery models.WebhookDelivery
+	if err := db.Where("id = ? AND webhook_id = ?", uint(deliveryID), hook.ID).First(&delivery).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Delivery not found",
+		})
+		return
+	}
+	
+	if err := db.Model(&delivery).Updates(map[string]interface{}{
+		"status":          models.DeliveryPending,
+		"attempts":        0,
+		"error":           "",
+		"next_attempt_at": time.Now(),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to schedule delivery",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"status": 1,
+		"msg":    "已重新加入投递队列",
+		"data":   delivery,
+	})
+}
+
+// loadOwnedWebhook 按路径参数加载当前用户的Webhook，失败时已写入响应
+func loadOwnedWebhook(c *gin.Context) (*models.Webhook, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid webhook ID",
+		})
+		return nil, false
+	}
+	
+	var hook models.Webhook
+	if err := database.GetDBWithContext(c.Request.Context()).
+		Where("id = ? AND owner_id = ?", uint(id), middleware.GetUserID(c)).
+		First(&hook).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Webhook not found",
+		})
+		return nil, false
+	}
+	return &hook, true
+}
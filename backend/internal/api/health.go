@@ -0,0 +1,128 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+	
+	"github.com/gin-gonic/gin"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/websocket"
+)
+
+// readinessTimeout 就绪检查的最长等待时间，避免依赖挂起拖住探针
+const readinessTimeout = 2 * time.Second
+
+// CheckResult 单项依赖检查结果
+type CheckResult struct {
+	Status    string  `json:"status"` // ok, error
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// HealthChecker 依赖检查函数
+type HealthChecker func(ctx context.Context) error
+
+// readinessCheckers 就绪检查依赖列表
+var readinessCheckers = map[string]HealthChecker{
+	"database":  database.HealthContext,
+	"redis":     database.RedisHealthContext,
+	"websocket": websocketHealth,
+}
+
+// livenessCheck 存活检查：进程能响应即返回200
+func livenessCheck(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "alive",
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// readinessCheck 就绪检查：数据库、Redis和WebSocket管理器均正常时返回200，否则返回503
+func readinessCheck(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+	
+	checks := runHealthChecks(ctx, readinessCheckers)
+	
+	status := http.StatusOK
+	overall := "ready"
+	for _, result := range checks {
+		if result.Status != "ok" {
+			status = http.StatusServiceUnavailable
+			overall = "not_ready"
+			break
+		}
+	}
+	
+	connections := 0
+	if websocket.DefaultManager != nil {
+		connections = websocket.DefaultManager.GetClientCount()
+	}
+	
+	c.JSON(status, gin.H{
+		"status":      overall,
+		"timestamp":   time.Now().UTC().Format(time.RFC3339),
+		"checks":      checks,
+		"connections": connections,
+	})
+}
+
+// runHealthChecks 并发执行所有依赖检查，每项检查受ctx超时约束
+func runHealthChecks(ctx context.Context, checkers map[string]HealthChecker) map[string]CheckResult {
+	results := make(map[string]CheckResult, len(checkers))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	
+	for name, checker := range checkers {
+		wg.Add(1)
+		go func(name string, checker HealthChecker) {
+			defer wg.Done()
+			result := runHealthCheck(ctx, checker)
+			
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+		}(name, checker)
+	}
+	
+	wg.Wait()
+	return results
+}
+
+// runHealthCheck 执行单项检查，超时后立即返回而不等待挂起的调用
+func runHealthCheck(ctx context.Context, checker HealthChecker) CheckResult {
+	start := time.Now()
+	done := make(chan error, 1)
+	
+	go func() {
+		done <- checker(ctx)
+	}()
+	
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("check timed out: %w", ctx.Err())
+	}
+	
+	result := CheckResult{
+		Status:    "ok",
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// websocketHealth 检查WebSocket管理器是否已初始化
+func websocketHealth(ctx context.Context) error {
+	if websocket.DefaultManager == nil {
+		return fmt.Errorf("websocket manager not initialized")
+	}
+	return nil
+}
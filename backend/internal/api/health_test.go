@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// useReadinessCheckers 替换就绪检查依赖列表，测试结束后恢复
+func useReadinessCheckers(t *testing.T, checkers map[string]HealthChecker) {
+	t.Helper()
+	previous := readinessCheckers
+	readinessCheckers = checkers
+	t.Cleanup(func() { readinessCheckers = previous })
+}
+
+func serveHealth(t *testing.T, handler gin.HandlerFunc) (int, map[string]interface{}) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/health", nil)
+	handler(c)
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode response %q: %v", w.Body.String(), err)
+	}
+	return w.Code, body
+}
+
+func TestLivenessCheck(t *testing.T) {
+	// 依赖全部不可用时存活探针仍返回200
+	useReadinessCheckers(t, map[string]HealthChecker{
+		"database": func(ctx context.Context) error { return errors.New("connection refused") },
+	})
+
+	status, body := serveHealth(t, livenessCheck)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", status, http.StatusOK)
+	}
+	if body["status"] != "alive" {
+		t.Errorf("status field = %v, want alive", body["status"])
+	}
+}
+
+func TestReadinessCheck(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return errors.New("connection refused") }
+
+	tests := []struct {
+		name       string
+		checkers   map[string]HealthChecker
+		wantStatus int
+		wantBody   string
+	}{
+		{"all ok", map[string]HealthChecker{"database": ok, "redis": ok}, http.StatusOK, "ready"},
+		{"redis down", map[string]HealthChecker{"database": ok, "redis": failing}, http.StatusServiceUnavailable, "not_ready"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useReadinessCheckers(t, tt.checkers)
+
+			status, body := serveHealth(t, readinessCheck)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if body["status"] != tt.wantBody {
+				t.Errorf("status field = %v, want %s", body["status"], tt.wantBody)
+			}
+			checks := body["checks"].(map[string]interface{})
+			if len(checks) != len(tt.checkers) {
+				t.Errorf("got %d checks, want %d", len(checks), len(tt.checkers))
+			}
+		})
+	}
+}
+
+func TestRunHealthCheckTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	hanging := func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}
+	start := time.Now()
+	result := runHealthCheck(ctx, hanging)
+	if result.Status != "error" {
+		t.Errorf("status = %q, want error", result.Status)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("check returned after %v, want it to stop at the deadline", elapsed)
+	}
+}
@@ -2,20 +2,32 @@ package api
 
 import (
 	"net/http"
+	"time"
 	
 	"github.com/gin-gonic/gin"
 	"iot-platform-backend/internal/api/controllers"
+	"iot-platform-backend/internal/config"
+	"iot-platform-backend/internal/ingest"
 	"iot-platform-backend/internal/middleware"
 	"iot-platform-backend/internal/websocket"
 	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/models"
 )
 
 // SetupRoutes 设置所有API路由
 func SetupRoutes(r *gin.Engine) {
-	// 创建控制器实例
-	authController := controllers.NewAuthController()
-	deviceController := controllers.NewDeviceController()
-	projectController := controllers.NewProjectController()
+	// 创建控制器实例，各API版本共用
+	ctrls := apiControllers{
+		auth:         controllers.NewAuthController(),
+		device:       controllers.NewDeviceController(),
+		project:      controllers.NewProjectController(),
+		notification: controllers.NewNotificationController(),
+		search:       controllers.NewSearchController(),
+		webhook:      controllers.NewWebhookController(),
+		invite:       controllers.NewInviteController(),
+		presence:     controllers.NewPresenceController(),
+		alert:        controllers.NewAlertController(),
+	}
 	
 	// 全局中间件
 	r.Use(middleware.CORS())
@@ -23,14 +35,60 @@ func SetupRoutes(r *gin.Engine) {
 	r.Use(middleware.Logger())
 	
 	// 健康检查路由（无需认证）
-	r.GET("/health", healthCheck)
+	r.GET("/healthz", livenessCheck)
+	r.GET("/readyz", readinessCheck)
+	r.GET("/health", readinessCheck) // 兼容旧的健康检查地址
 	r.GET("/metrics", metricsHandler)
 	
-	// API版本前缀
-	v1 := r.Group("/api/v1")
+	// API版本：各版本共用控制器，破坏性变更通过在新版本中替换个别路由实现，
+	// 旧版本中计划下线的路由通过API_DEPRECATED_ROUTES标记
+	deprecations := middleware.Deprecation(config.AppConfig.Server.DeprecatedRoutes)
+	for _, version := range middleware.APIVersions {
+		api := r.Group("/api/"+version, middleware.APIVersion(version), deprecations, middleware.NoStore())
+		registerAPIRoutes(api, ctrls)
+	}
+}
+
+// apiControllers 各API版本共用的控制器实例
+type apiControllers struct {
+	auth         *controllers.AuthController
+	device       *controllers.DeviceController
+	project      *controllers.ProjectController
+	notification *controllers.NotificationController
+	search       *controllers.SearchController
+	webhook      *controllers.WebhookController
+	invite       *controllers.InviteController
+	presence     *controllers.PresenceController
+	alert        *controllers.AlertController
+}
+
+// registerAPIRoutes 在某个API版本的分组下注册路由
+func registerAPIRoutes(api *gin.RouterGroup, ctrls apiControllers) {
+	authController := ctrls.auth
+	deviceController := ctrls.device
+	projectController := ctrls.project
+	notificationController := ctrls.notification
+	searchController := ctrls.search
+	webhookController := ctrls.webhook
+	inviteController := ctrls.invite
+	presenceController := ctrls.presence
+	alertController := ctrls.alert
+	
+	// 请求体必须为JSON；设备数据上报还支持msgpack/CBOR，文件上传使用multipart，不做检查
+	if config.AppConfig.Server.EnforceJSONContentType {
+		prefix := api.BasePath()
+		api.Use(middleware.RequireJSON(
+			prefix+"/devices/:id/data",
+			prefix+"/upload/avatar",
+			prefix+"/upload/file",
+		))
+	}
+	
+	// 各路由分组的请求超时（WebSocket长连接不设超时）
+	timeouts := config.AppConfig.Server
 	
 	// 认证路由（无需认证）
-	auth := v1.Group("/auth")
+	auth := api.Group("/auth", middleware.Timeout(timeouts.TimeoutFor("auth")))
 	{
 		auth.POST("/login", authController.Login)
 		auth.POST("/register", authController.Register)
@@ -42,21 +100,27 @@ func SetupRoutes(r *gin.Engine) {
 		{
 			authProtected.GET("/me", authController.Me)
 			authProtected.POST("/logout", authController.Logout)
+			authProtected.GET("/sessions", authController.ListSessions)
+			authProtected.DELETE("/sessions/:id", authController.RevokeSession)
 			authProtected.PUT("/password", authController.ChangePassword)
+			authProtected.DELETE("/me", authController.DeleteAccount)
 		}
 	}
 	
 	// WebSocket路由（支持可选认证）
-	v1.GET("/ws", middleware.OptionalAuth(), websocket.HandleWebSocket)
+	api.GET("/ws", middleware.OptionalAuth(), websocket.HandleWebSocket)
 	
 	// 设备路由
-	devices := v1.Group("/devices")
+	devices := api.Group("/devices", middleware.Timeout(timeouts.TimeoutFor("devices")))
 	{
 		// 公开路由
-		devices.GET("/types", deviceController.GetDeviceTypes)
+		devices.GET("/types", middleware.PublicCache(timeouts.CacheMaxAgeFor("devices")), deviceController.GetDeviceTypes)
 		
 		// 设备数据上报（IoT设备使用，可能需要不同的认证方式）
-		devices.POST("/:device_id/data", deviceController.PostDeviceData)
+		devices.POST("/:id/data", deviceController.PostDeviceData)
+		devices.POST("/:id/ping", deviceController.PingDevice)
+		devices.GET("/:id/commands/pending", deviceController.PollDeviceCommands)
+		devices.POST("/:id/commands/:command_id/ack", deviceController.AckDeviceCommand)
 		
 		// 需要用户认证的路由
 		devicesProtected := devices.Group("")
@@ -65,16 +129,58 @@ func SetupRoutes(r *gin.Engine) {
 			devicesProtected.GET("", deviceController.GetDevices)
 			devicesProtected.POST("", deviceController.CreateDevice)
 			devicesProtected.GET("/stats", deviceController.GetDeviceStats)
+			devicesProtected.GET("/fleet-stats", deviceController.GetFleetStats)
+			devicesProtected.GET("/firmware-stats", deviceController.GetFirmwareStats)
+			devicesProtected.GET("/tags", deviceController.GetDeviceTags)
+			devicesProtected.GET("/stale", deviceController.GetStaleDevices)
+			devicesProtected.GET("/correlate", deviceController.GetDeviceCorrelation)
+			devicesProtected.POST("/latest", deviceController.GetLatestReadings)
+			devicesProtected.POST("/bulk-delete", deviceController.BulkDeleteDevices)
+			devicesProtected.POST("/bulk-update", deviceController.BulkUpdateDevices)
+			devicesProtected.GET("/templates", deviceController.GetDeviceTemplates)
+			devicesProtected.POST("/templates", deviceController.CreateDeviceTemplate)
+			devicesProtected.DELETE("/templates/:template_id", deviceController.DeleteDeviceTemplate)
+			devicesProtected.POST("/apply-template", deviceController.ApplyDeviceTemplate)
+			devicesProtected.POST("/move-group", deviceController.MoveDevicesToGroup)
+			devicesProtected.GET("/groups", deviceController.GetDeviceGroups)
+			devicesProtected.POST("/groups", deviceController.CreateDeviceGroup)
+			devicesProtected.PUT("/groups/:id", middleware.OwnerOrAdminRequired("DeviceGroup", controllers.DeviceGroupOwner), deviceController.UpdateDeviceGroup)
+			devicesProtected.DELETE("/groups/:id", middleware.OwnerOrAdminRequired("DeviceGroup", controllers.DeviceGroupOwner), deviceController.DeleteDeviceGroup)
+			devicesProtected.GET("/groups/:id/devices", middleware.OwnerOrAdminRequired("DeviceGroup", controllers.DeviceGroupOwner), deviceController.GetDeviceGroupDevices)
+			devicesProtected.GET("/groups/:id/stats", middleware.OwnerOrAdminRequired("DeviceGroup", controllers.DeviceGroupOwner), deviceController.GetDeviceGroupStats)
+			devicesProtected.POST("/import", deviceController.ImportDevices)
+			devicesProtected.GET("/import/:job_id", deviceController.GetImportJob)
+			devicesProtected.DELETE("/import/:job_id", deviceController.CancelImportJob)
 			devicesProtected.GET("/:id", deviceController.GetDevice)
-			devicesProtected.PUT("/:id", deviceController.UpdateDevice)
-			devicesProtected.DELETE("/:id", deviceController.DeleteDevice)
-			devicesProtected.GET("/:device_id/data", deviceController.GetDeviceData)
-			devicesProtected.GET("/:device_id/history", deviceController.GetDeviceHistory)
+			devicesProtected.PUT("/:id", middleware.OwnerOrAdminRequired("Device", controllers.DeviceOwner), deviceController.UpdateDevice)
+			devicesProtected.PATCH("/:id", middleware.OwnerOrAdminRequired("Device", controllers.DeviceOwner), deviceController.PatchDevice)
+			devicesProtected.DELETE("/:id", middleware.OwnerOrAdminRequired("Device", controllers.DeviceOwner), deviceController.DeleteDevice)
+			devicesProtected.POST("/:id/rekey", middleware.OwnerOrAdminRequired("Device", controllers.DeviceOwner), deviceController.RekeyDevice)
+			devicesProtected.POST("/:id/key", middleware.OwnerOrAdminRequired("Device", controllers.DeviceOwner), deviceController.IssueDeviceKey)
+			devicesProtected.POST("/:id/migrate-config", middleware.OwnerOrAdminRequired("Device", controllers.DeviceOwner), deviceController.MigrateDeviceConfig)
+			devicesProtected.GET("/:id/config/export", middleware.OwnerOrAdminRequired("Device", controllers.DeviceOwner), deviceController.ExportDeviceConfig)
+			devicesProtected.POST("/:id/config/import", middleware.OwnerOrAdminRequired("Device", controllers.DeviceOwner), deviceController.ImportDeviceConfig)
+			devicesProtected.GET("/:id/commands", middleware.OwnerOrAdminRequired("Device", controllers.DeviceOwner), deviceController.GetDeviceCommands)
+			devicesProtected.POST("/:id/commands", middleware.OwnerOrAdminRequired("Device", controllers.DeviceOwner), deviceController.IssueDeviceCommand)
+			devicesProtected.GET("/:id/data", deviceController.GetDeviceData)
+			devicesProtected.GET("/:id/history", deviceController.GetDeviceHistory)
+			devicesProtected.GET("/:id/summary", deviceController.GetDeviceSummary)
+			devicesProtected.GET("/:id/fields", deviceController.GetDeviceFields)
+			devicesProtected.GET("/:id/uptime", deviceController.GetDeviceUptime)
+			
+			// 模拟数据仅用于开发调试，release模式下不注册
+			if !config.AppConfig.IsProduction() {
+				devicesProtected.POST("/:id/simulate", deviceController.SimulateDeviceData)
+				devicesProtected.DELETE("/:id/simulate", deviceController.StopDeviceSimulation)
+			}
 		}
 	}
 	
+	// 历史数据导出为流式响应，不经过会缓冲整个响应的超时中间件
+	api.POST("/devices/history/export", middleware.AuthRequired(), deviceController.ExportDeviceHistory)
+	
 	// 项目路由
-	projects := v1.Group("/projects")
+	projects := api.Group("/projects", middleware.Timeout(timeouts.TimeoutFor("projects")))
 	{
 		// 需要认证的路由
 		projectsProtected := projects.Group("")
@@ -82,39 +188,106 @@ func SetupRoutes(r *gin.Engine) {
 		{
 			projectsProtected.GET("", projectController.GetProjects)
 			projectsProtected.POST("", projectController.CreateProject)
+			projectsProtected.GET("/compare", projectController.CompareProjects)
 			projectsProtected.GET("/:id", projectController.GetProject)
 			projectsProtected.PUT("/:id", projectController.UpdateProject)
-			projectsProtected.DELETE("/:id", projectController.DeleteProject)
+			projectsProtected.DELETE("/:id", middleware.OwnerOrAdminRequired("Project", controllers.ProjectOwner), projectController.DeleteProject)
 			
 			// Fork功能
-			projectsProtected.POST("/:id/fork", projectController.ForkProject)
-			projectsProtected.POST("/:id/star", projectController.StarProject)
+			// Fork和点赞按用户限流，防止脚本刷计数
+			projectLimits := config.AppConfig.Projects
+			projectsProtected.POST("/:id/fork", middleware.RateLimitByUser(projectLimits.ForkRateLimit, projectLimits.ForkRateWindow), projectController.ForkProject)
+			projectsProtected.GET("/:id/forks", projectController.GetProjectForks)
+			projectsProtected.POST("/:id/star", middleware.RateLimitByUser(projectLimits.StarRateLimit, projectLimits.StarRateWindow), projectController.StarProject)
 			
 			// 项目历史
 			projectsProtected.GET("/:id/history", projectController.GetProjectHistory)
+			
+			// 协作者管理
+			projectsProtected.GET("/:id/collaborators", projectController.GetProjectCollaborators)
+			projectsProtected.POST("/:id/collaborators", middleware.OwnerOrAdminRequired("Project", controllers.ProjectOwner), projectController.AddProjectCollaborator)
+			projectsProtected.PUT("/:id/collaborators/:user_id", middleware.OwnerOrAdminRequired("Project", controllers.ProjectOwner), projectController.UpdateProjectCollaborator)
+			projectsProtected.DELETE("/:id/collaborators/:user_id", projectController.RemoveProjectCollaborator)
 		}
 	}
 	
-	// 用户管理路由（管理员专用）
-	admin := v1.Group("/admin")
+	// 全局搜索
+	api.GET("/search", middleware.Timeout(timeouts.TimeoutFor("search")), middleware.AuthRequired(), searchController.Search)
+	
+	// 当前用户相关路由
+	users := api.Group("/users", middleware.Timeout(timeouts.TimeoutFor("users")))
+	users.Use(middleware.AuthRequired())
+	{
+		users.GET("/me/forks", projectController.GetMyForks)
+		users.GET("/me/usage", authController.GetMyUsage)
+	}
+	
+	// 站内通知路由
+	notifications := api.Group("/notifications", middleware.Timeout(timeouts.TimeoutFor("notifications")))
+	notifications.Use(middleware.AuthRequired())
+	{
+		notifications.GET("", notificationController.GetNotifications)
+		notifications.POST("/read-all", notificationController.MarkAllNotificationsRead)
+		notifications.POST("/:id/read", notificationController.MarkNotificationRead)
+	}
+	
+	// 设备告警路由
+	alerts := api.Group("/alerts", middleware.Timeout(timeouts.TimeoutFor("alerts")))
+	alerts.Use(middleware.AuthRequired())
+	{
+		alerts.GET("", alertController.GetAlerts)
+		alerts.POST("/ack-all", alertController.AckAllAlerts)
+		alerts.POST("/:id/ack", alertController.AckAlert)
+	}
+	
+	// Webhook路由
+	webhooks := api.Group("/webhooks", middleware.Timeout(timeouts.TimeoutFor("webhooks")))
+	webhooks.Use(middleware.AuthRequired())
+	{
+		webhooks.GET("", webhookController.GetWebhooks)
+		webhooks.POST("", webhookController.CreateWebhook)
+		webhooks.PUT("/:id", webhookController.UpdateWebhook)
+		webhooks.DELETE("/:id", webhookController.DeleteWebhook)
+		webhooks.GET("/:id/deliveries", webhookController.GetWebhookDeliveries)
+		webhooks.POST("/:id/deliveries/:delivery_id/retry", webhookController.RetryWebhookDelivery)
+	}
+	
+	// 管理路由，按权限项授权：管理员拥有全部权限，版主可查看统计和处理公开项目
+	admin := api.Group("/admin", middleware.Timeout(timeouts.TimeoutFor("admin")))
 	admin.Use(middleware.AuthRequired())
-	admin.Use(middleware.AdminRequired())
 	{
 		// 用户管理
-		admin.GET("/users", getUserList)
-		admin.GET("/users/:id", getUserDetail)
-		admin.PUT("/users/:id/status", updateUserStatus)
+		manageUsers := middleware.RequireCapability(models.CapManageUsers)
+		admin.GET("/users", manageUsers, getUserList)
+		admin.GET("/users/:id", manageUsers, getUserDetail)
+		admin.PUT("/users/:id/status", manageUsers, updateUserStatus)
+		admin.GET("/users/:id/quota", manageUsers, authController.GetUserQuota)
+		admin.PUT("/users/:id/quota", manageUsers, authController.UpdateUserQuota)
+		admin.GET("/presence", manageUsers, presenceController.GetPresence)
 		
 		// 系统统计
-		admin.GET("/stats", getSystemStats)
+		admin.GET("/stats", middleware.RequireCapability(models.CapViewStats), getSystemStats)
 		
 		// 系统配置
-		admin.GET("/config", getSystemConfig)
-		admin.PUT("/config", updateSystemConfig)
+		manageConfig := middleware.RequireCapability(models.CapManageConfig)
+		admin.GET("/config", manageConfig, getSystemConfig)
+		admin.PUT("/config", manageConfig, updateSystemConfig)
+		
+		// 注册邀请码
+		manageInvites := middleware.RequireCapability(models.CapManageInvites)
+		admin.GET("/invites", manageInvites, inviteController.GetInvites)
+		admin.POST("/invites", manageInvites, inviteController.CreateInvite)
+		admin.DELETE("/invites/:id", manageInvites, inviteController.RevokeInvite)
+		
+		// 通知广播（限流防止误操作刷屏）
+		admin.POST("/notifications/broadcast", middleware.RequireCapability(models.CapBroadcast), middleware.RateLimitByUser(5, time.Minute), notificationController.BroadcastNotification)
+		
+		// 公开内容管理
+		admin.POST("/projects/:id/unpublish", middleware.RequireCapability(models.CapModerateProjects), projectController.UnpublishProject)
 	}
 	
 	// 文件上传路由
-	upload := v1.Group("/upload")
+	upload := api.Group("/upload", middleware.Timeout(timeouts.TimeoutFor("upload")))
 	upload.Use(middleware.AuthRequired())
 	{
 		upload.POST("/avatar", uploadAvatar)
@@ -122,58 +295,17 @@ func SetupRoutes(r *gin.Engine) {
 	}
 	
 	// 公开API（支持CORS，用于前端调用）
-	public := v1.Group("/public")
+	public := api.Group("/public", middleware.Timeout(timeouts.TimeoutFor("public")))
 	{
-		public.GET("/projects", publicProjectList)
+		// 项目详情需要记录浏览数并使用ETag验证，不交给CDN缓存
+		publicCache := middleware.PublicCache(timeouts.CacheMaxAgeFor("public"))
+		public.GET("/projects", publicCache, publicProjectList)
 		public.GET("/projects/:id", publicProjectDetail)
-		public.GET("/stats", publicStats)
+		public.GET("/stats", publicCache, projectController.GetPublicStats)
+		public.GET("/trending", publicCache, projectController.GetTrendingProjects)
 	}
 }
 
-// healthCheck 健康检查
-func healthCheck(c *gin.Context) {
-	// 检查数据库连接
-	dbHealth := "ok"
-	if err := database.Health(); err != nil {
-		dbHealth = "error: " + err.Error()
-	}
-	
-	// 检查Redis连接
-	redisHealth := "ok"
-	if err := database.RedisHealth(); err != nil {
-		redisHealth = "error: " + err.Error()
-	}
-	
-	// 检查WebSocket管理器
-	wsHealth := "ok"
-	wsConnections := 0
-	if websocket.DefaultManager != nil {
-		wsConnections = websocket.DefaultManager.GetClientCount()
-	} else {
-		wsHealth = "not initialized"
-	}
-	
-	status := http.StatusOK
-	if dbHealth != "ok" || redisHealth != "ok" {
-		status = http.StatusServiceUnavailable
-	}
-	
-	c.JSON(status, gin.H{
-		"status": "healthy",
-		"timestamp": gin.H{
-			"unix": gin.H{
-				"timestamp": gin.H{},
-			},
-		},
-		"checks": gin.H{
-			"database":    dbHealth,
-			"redis":       redisHealth,
-			"websocket":   wsHealth,
-			"connections": wsConnections,
-		},
-	})
-}
-
 // metricsHandler 指标处理器
 func metricsHandler(c *gin.Context) {
 	// 获取数据库统计
@@ -182,12 +314,21 @@ func metricsHandler(c *gin.Context) {
 	// 获取Redis统计
 	redisStats, _ := database.RedisStats()
 	
+	var wsMetrics interface{}
+	if websocket.DefaultManager != nil {
+		wsMetrics = websocket.DefaultManager.Metrics()
+	}
+	
+	var ingestStats interface{}
+	if ingest.Default != nil {
+		ingestStats = ingest.Default.Stats()
+	}
+	
 	c.JSON(http.StatusOK, gin.H{
 		"database":  dbStats,
 		"redis":     redisStats,
-		"websocket": gin.H{
-			"connections": websocket.DefaultManager.GetClientCount(),
-		},
+		"websocket": wsMetrics,
+		"ingestion": ingestStats,
 	})
 }
 
@@ -217,7 +358,7 @@ func updateUserStatus(c *gin.Context) {
 
 func getSystemStats(c *gin.Context) {
 	// 获取基本统计信息
-	db := database.GetDB()
+	db := database.GetDBWithContext(c.Request.Context())
 	
 	var userCount, deviceCount, projectCount int64
 	db.Model(&models.User{}).Count(&userCount)
@@ -264,15 +405,38 @@ func uploadFile(c *gin.Context) {
 }
 
 func publicProjectList(c *gin.Context) {
+	// 热门公开项目与用户无关，与公开项目列表共用缓存代数，项目变更后随之失效
+	var cacheKey string
+	if database.RedisClient != nil {
+		cacheKey = controllers.PublicTopProjectsCacheKey(c)
+		var cached []models.Project
+		if err := database.NewCache().Get(c, cacheKey, &cached); err == nil {
+			c.JSON(http.StatusOK, gin.H{
+				"status": 1,
+				"data":   cached,
+			})
+			return
+		}
+	}
+	
 	// 获取公开项目列表
-	db := database.GetDB()
+	db := database.GetDBWithContext(c.Request.Context())
 	var projects []models.Project
 	
-	db.Where("public = ?", true).
+	if err := db.Where("public = ?", true).
 		Preload("Owner").
 		Order("star_count DESC, created_at DESC").
 		Limit(20).
-		Find(&projects)
+		Find(&projects).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch projects",
+		})
+		return
+	}
+	
+	if cacheKey != "" {
+		database.NewCache().Set(c, cacheKey, projects, controllers.PublicProjectsCacheTTL)
+	}
 	
 	c.JSON(http.StatusOK, gin.H{
 		"status": 1,
@@ -283,7 +447,7 @@ func publicProjectList(c *gin.Context) {
 func publicProjectDetail(c *gin.Context) {
 	projectID := c.Param("id")
 	
-	db := database.GetDB()
+	db := database.GetDBWithContext(c.Request.Context())
 	var project models.Project
 	
 	if err := db.Where("id = ? AND public = ?", projectID, true).
@@ -295,32 +459,14 @@ func publicProjectDetail(c *gin.Context) {
 		return
 	}
 	
-	c.JSON(http.StatusOK, gin.H{
-		"status": 1,
-		"data":   project,
-	})
-}
-
-func publicStats(c *gin.Context) {
-	db := database.GetDB()
-	
-	var stats struct {
-		PublicProjects int64 `json:"public_projects"`
-		TotalViews     int64 `json:"total_views"`
-		TotalStars     int64 `json:"total_stars"`
-		ActiveUsers    int64 `json:"active_users"`
-	}
-	
-	db.Model(&models.Project{}).Where("public = ?", true).Count(&stats.PublicProjects)
-	db.Model(&models.Project{}).Select("COALESCE(SUM(view_count), 0)").Row().Scan(&stats.TotalViews)
-	db.Model(&models.Project{}).Select("COALESCE(SUM(star_count), 0)").Row().Scan(&stats.TotalStars)
+	database.RecordProjectView(c, project.ID)
 	
-	// 活跃用户：最近30天有活动的用户
-	//thirtyDaysAgo := time.Now().AddDate(0, 0, -30)
-	//db.Model(&models.User{}).Where("last_login > ?", thirtyDaysAgo).Count(&stats.ActiveUsers)
+	if controllers.CheckNotModified(c, controllers.ProjectETag(&project)) {
+		return
+	}
 	
 	c.JSON(http.StatusOK, gin.H{
 		"status": 1,
-		"data":   stats,
+		"data":   project,
 	})
 }
\ No newline at end of file
@@ -0,0 +1,312 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/middleware"
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/testutil"
+)
+
+// getPublicProjects 调用公开项目列表并返回项目名
+func getPublicProjects(t *testing.T) []string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/api/v1/public/projects", nil)
+	publicProjectList(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+
+	var body struct {
+		Data []models.Project `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	names := make([]string, 0, len(body.Data))
+	for _, project := range body.Data {
+		names = append(names, project.Name)
+	}
+	return names
+}
+
+func TestPublicProjectListIsCached(t *testing.T) {
+	testutil.LoadConfig(t)
+	testutil.UseRedis(t)
+	db := testutil.UseDB(t)
+
+	owner := models.User{Username: "owner", Email: "owner@example.com", Phone: "1", Password: "secret123"}
+	if err := db.Create(&owner).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if err := db.Create(&models.Project{Name: "first", OwnerID: owner.ID, Public: true}).Error; err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+
+	if names := getPublicProjects(t); len(names) != 1 {
+		t.Fatalf("projects = %v, want [first]", names)
+	}
+
+	// 绕过控制器直接写库，缓存未失效时仍返回旧结果
+	if err := db.Create(&models.Project{Name: "second", OwnerID: owner.ID, Public: true}).Error; err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+	if names := getPublicProjects(t); len(names) != 1 {
+		t.Errorf("projects = %v, want the cached [first]", names)
+	}
+
+	// 与invalidatePublicProjects相同：递增缓存代数
+	database.NewCache().Incr(context.Background(), database.Keys.PublicProjectsGeneration())
+	if names := getPublicProjects(t); len(names) != 2 {
+		t.Errorf("projects = %v after invalidation, want both", names)
+	}
+}
+
+// TestSetupRoutes 注册全部路由：gin在通配参数名冲突时panic，服务无法启动
+func TestSetupRoutes(t *testing.T) {
+	cfg := testutil.LoadConfig(t)
+	cfg.Server.EnforceJSONContentType = true
+
+	r := gin.New()
+	SetupRoutes(r)
+
+	registered := make(map[string]bool)
+	for _, route := range r.Routes() {
+		registered[route.Method+" "+route.Path] = true
+	}
+	for _, version := range middleware.APIVersions {
+		for _, route := range []string{
+			"GET /devices/:id",
+			"PATCH /devices/:id",
+			"POST /devices/:id/data",
+			"GET /devices/:id/data",
+			"POST /devices/:id/ping",
+			"GET /devices/:id/history",
+			"GET /devices/:id/commands",
+			"POST /devices/:id/commands",
+			"GET /devices/:id/commands/pending",
+			"POST /devices/:id/commands/:command_id/ack",
+			"POST /devices/:id/simulate",
+		} {
+			method, path, _ := strings.Cut(route, " ")
+			if want := method + " /api/" + version + path; !registered[want] {
+				t.Errorf("route %s is not registered", want)
+			}
+		}
+	}
+}
+
+func TestDeprecatedRouteHeaders(t *testing.T) {
+	cfg := testutil.LoadConfig(t)
+	testutil.UseRedis(t)
+	cfg.Server.DeprecatedRoutes = map[string]string{"GET /api/v1/devices/types": "2027-01-31"}
+
+	r := gin.New()
+	SetupRoutes(r)
+	get := func(path string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s status = %d: %s", path, w.Code, w.Body.String())
+		}
+		return w
+	}
+
+	v1 := get("/api/v1/devices/types")
+	for header, want := range map[string]string{
+		"X-API-Version": "v1",
+		"Deprecation":   "true",
+		"Sunset":        "Sun, 31 Jan 2027 00:00:00 GMT",
+		"Link":          `</api/v2/devices/types>; rel="successor-version"`,
+	} {
+		if got := v1.Header().Get(header); got != want {
+			t.Errorf("v1 %s = %q, want %q", header, got, want)
+		}
+	}
+
+	// 新版本的同一路由不带弃用头
+	v2 := get("/api/v2/devices/types")
+	if got := v2.Header().Get("X-API-Version"); got != "v2" {
+		t.Errorf("v2 X-API-Version = %q, want v2", got)
+	}
+	for _, header := range []string{"Deprecation", "Sunset", "Link"} {
+		if got := v2.Header().Get(header); got != "" {
+			t.Errorf("v2 %s = %q, want none", header, got)
+		}
+	}
+}
+
+func TestPublicProjectDetailConditional(t *testing.T) {
+	testutil.LoadConfig(t)
+	db := testutil.UseDB(t)
+
+	owner := models.User{Username: "owner", Email: "owner@example.com", Phone: "1", Password: "secret123"}
+	if err := db.Create(&owner).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	project := models.Project{Name: "p", OwnerID: owner.ID, Public: true, Config: models.JSONB{"threshold": 10}}
+	if err := db.Create(&project).Error; err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+
+	router := gin.New()
+	router.GET("/api/v1/public/projects/:id", publicProjectDetail)
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/v1/public/projects/"+strconv.Itoa(int(project.ID)), nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := get("")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("status = %d ETag = %q, want 200 with an ETag", first.Code, etag)
+	}
+	if w := get(etag); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("status = %d body = %q, want an empty 304", w.Code, w.Body.String())
+	}
+
+	if err := db.Model(&project).Updates(map[string]interface{}{"config": models.JSONB{"threshold": 20}, "version": 2}).Error; err != nil {
+		t.Fatalf("update project: %v", err)
+	}
+	w := get(etag)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Errorf("status = %d ETag = %q after an update, want 200 with a new ETag", w.Code, w.Header().Get("ETag"))
+	}
+}
+
+func TestJSONContentTypeEnforcement(t *testing.T) {
+	cfg := testutil.LoadConfig(t)
+	testutil.UseRedis(t)
+
+	post := func(path, contentType, body string) int {
+		t.Helper()
+		r := gin.New()
+		SetupRoutes(r)
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	cfg.Server.EnforceJSONContentType = true
+	for _, version := range middleware.APIVersions {
+		path := "/api/" + version + "/auth/login"
+		if code := post(path, "application/x-www-form-urlencoded", "username=bob&password=x"); code != http.StatusUnsupportedMediaType {
+			t.Errorf("form POST %s status = %d, want %d", path, code, http.StatusUnsupportedMediaType)
+		}
+		// 文件上传不检查Content-Type，由认证中间件拒绝未登录请求
+		path = "/api/" + version + "/upload/avatar"
+		if code := post(path, "multipart/form-data; boundary=x", "--x--"); code != http.StatusUnauthorized {
+			t.Errorf("multipart POST %s status = %d, want %d", path, code, http.StatusUnauthorized)
+		}
+	}
+
+	cfg.Server.EnforceJSONContentType = false
+	if code := post("/api/v1/auth/login", "application/x-www-form-urlencoded", "username=bob&password=x"); code == http.StatusUnsupportedMediaType {
+		t.Error("form POST rejected with 415 although enforcement is disabled")
+	}
+}
+
+func TestCacheControlHeaders(t *testing.T) {
+	cfg := testutil.LoadConfig(t)
+	testutil.UseRedis(t)
+	cfg.Server.PublicCacheMaxAge = time.Minute
+	cfg.Server.PublicCacheMaxAgeOverrides = map[string]time.Duration{"devices": time.Hour}
+
+	r := gin.New()
+	SetupRoutes(r)
+	for _, version := range middleware.APIVersions {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/api/"+version+"/devices/types", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s device types status = %d", version, w.Code)
+		}
+		if got := w.Header().Get("Cache-Control"); got != "public, max-age=3600" {
+			t.Errorf("%s device types Cache-Control = %q, want the devices override", version, got)
+		}
+		if got := w.Header().Get("Vary"); got != "Origin" {
+			t.Errorf("%s device types Vary = %q, want Origin", version, got)
+		}
+
+		// 需要认证的接口（包括被拒绝的请求）不允许缓存
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/api/"+version+"/devices", nil))
+		if w.Code != http.StatusUnauthorized || w.Header().Get("Cache-Control") != "no-store" {
+			t.Errorf("%s devices status = %d Cache-Control = %q, want 401 and no-store", version, w.Code, w.Header().Get("Cache-Control"))
+		}
+	}
+}
+
+func TestCacheControlHeadersWithData(t *testing.T) {
+	cfg := testutil.LoadConfig(t)
+	testutil.UseRedis(t)
+	db := testutil.UseDB(t)
+	cfg.Server.PublicCacheMaxAge = 2 * time.Minute
+
+	owner := models.User{Username: "owner", Email: "owner@example.com", Phone: "1", Password: "secret123", Role: "user", Active: true}
+	if err := db.Create(&owner).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if err := db.Create(&models.Project{Name: "p", OwnerID: owner.ID, Public: true}).Error; err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+
+	r := gin.New()
+	SetupRoutes(r)
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s %s status = %d: %s", req.Method, req.URL.Path, w.Code, w.Body.String())
+		}
+		return w
+	}
+
+	for _, path := range []string{"/api/v1/public/projects", "/api/v1/public/stats", "/api/v1/public/trending"} {
+		if got := serve(httptest.NewRequest("GET", path, nil)).Header().Get("Cache-Control"); got != "public, max-age=120" {
+			t.Errorf("%s Cache-Control = %q, want public, max-age=120", path, got)
+		}
+	}
+
+	login := httptest.NewRequest("POST", "/api/v1/auth/login", strings.NewReader(`{"username":"owner","password":"secret123"}`))
+	login.Header.Set("Content-Type", "application/json")
+	w := serve(login)
+	if got := w.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("login Cache-Control = %q, want no-store", got)
+	}
+	var body struct {
+		Data struct {
+			AccessToken string `json:"access_token"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Data.AccessToken == "" {
+		t.Fatalf("login response = %s, want an access token", w.Body.String())
+	}
+
+	// 认证后的成功响应同样不允许缓存
+	me := httptest.NewRequest("GET", "/api/v1/auth/me", nil)
+	me.Header.Set("Authorization", "Bearer "+body.Data.AccessToken)
+	if got := serve(me).Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("/auth/me Cache-Control = %q, want no-store", got)
+	}
+}
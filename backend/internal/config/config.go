@@ -2,8 +2,11 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 	
 	"github.com/joho/godotenv"
@@ -17,6 +20,15 @@ type Config struct {
 	JWT      JWTConfig      `json:"jwt"`
 	WebSocket WebSocketConfig `json:"websocket"`
 	Log      LogConfig      `json:"log"`
+	Pagination PaginationConfig `json:"pagination"`
+	Jobs     JobsConfig     `json:"jobs"`
+	Account  AccountConfig  `json:"account"`
+	Ingestion IngestionConfig `json:"ingestion"`
+	Webhook   WebhookConfig   `json:"webhook"`
+	SensorData SensorDataConfig `json:"sensor_data"`
+	Devices    DevicesConfig    `json:"devices"`
+	Quota      QuotaConfig      `json:"quota"`
+	Projects   ProjectsConfig   `json:"projects"`
 }
 
 // ServerConfig 服务器配置
@@ -25,7 +37,42 @@ type ServerConfig struct {
 	Mode         string        `json:"mode"`         // debug, release
 	ReadTimeout  time.Duration `json:"read_timeout"`
 	WriteTimeout time.Duration `json:"write_timeout"`
+	ShutdownTimeout time.Duration `json:"shutdown_timeout"` // 收到退出信号后等待进行中的请求和后台任务结束的最长时间
 	CORS         CORSConfig    `json:"cors"`
+	TrustedProxies []string    `json:"trusted_proxies"` // 受信任代理的IP或CIDR，为空时不信任任何转发头
+	RequestTimeout time.Duration `json:"request_timeout"` // API请求处理超时，0表示不限制
+	RequestTimeoutOverrides map[string]time.Duration `json:"request_timeout_overrides"` // 按路由分组覆盖超时，如projects=60s
+	EnforceJSONContentType bool `json:"enforce_json_content_type"` // 带请求体的API请求必须使用JSON的Content-Type，否则返回415
+	DeprecatedRoutes map[string]string `json:"deprecated_routes"` // 计划下线的路由及下线日期，响应中添加Deprecation/Sunset头
+	PublicCacheMaxAge time.Duration `json:"public_cache_max_age"` // 公开只读路由允许浏览器和CDN缓存的时长，0表示每次重新验证
+	PublicCacheMaxAgeOverrides map[string]time.Duration `json:"public_cache_max_age_overrides"` // 按路由分组覆盖缓存时长，如devices=1h
+}
+
+// ParseSunset 解析路由下线日期，支持2006-01-02和RFC 3339格式，空字符串返回零值
+func ParseSunset(date string) (time.Time, error) {
+	if date == "" {
+		return time.Time{}, nil
+	}
+	if sunset, err := time.Parse("2006-01-02", date); err == nil {
+		return sunset, nil
+	}
+	return time.Parse(time.RFC3339, date)
+}
+
+// TimeoutFor 获取指定路由分组的请求超时
+func (s ServerConfig) TimeoutFor(group string) time.Duration {
+	if timeout, ok := s.RequestTimeoutOverrides[group]; ok {
+		return timeout
+	}
+	return s.RequestTimeout
+}
+
+// CacheMaxAgeFor 获取指定路由分组公开只读路由的缓存时长
+func (s ServerConfig) CacheMaxAgeFor(group string) time.Duration {
+	if maxAge, ok := s.PublicCacheMaxAgeOverrides[group]; ok {
+		return maxAge
+	}
+	return s.PublicCacheMaxAge
 }
 
 // DatabaseConfig 数据库配置
@@ -37,6 +84,10 @@ type DatabaseConfig struct {
 	DBName   string `json:"db_name"`
 	SSLMode  string `json:"ssl_mode"`
 	TimeZone string `json:"time_zone"`
+	JSONUseNumber bool `json:"json_use_number"` // JSONB数值解码为json.Number，保留整数精度
+	MigrateMode   string `json:"migrate_mode"`  // 启动时的迁移模式：auto、always、skip、dry-run
+	IndexCheck    string `json:"index_check"`   // 启动时检查预期索引是否存在：off、warn、fail
+	SensorTimeIndex string `json:"sensor_time_index"` // sensor_data.timestamp的索引类型：btree、brin
 }
 
 // RedisConfig Redis配置
@@ -45,14 +96,22 @@ type RedisConfig struct {
 	Port     string `json:"port"`
 	Password string `json:"password"`
 	DB       int    `json:"db"`
+	KeyPrefix string `json:"key_prefix"` // 所有键和频道的全局前缀，如app:prod:，多个环境共用同一Redis时避免冲突
 }
 
 // JWTConfig JWT配置
 type JWTConfig struct {
 	Secret     string        `json:"secret"`
-	Expires    time.Duration `json:"expires"`
-	RefreshExpires time.Duration `json:"refresh_expires"`
+	Expires    time.Duration `json:"expires"`         // “记住我”会话的access token有效期
+	RefreshExpires time.Duration `json:"refresh_expires"` // “记住我”会话的refresh token有效期
+	SessionExpires        time.Duration `json:"session_expires"`         // 普通会话的access token有效期
+	SessionRefreshExpires time.Duration `json:"session_refresh_expires"` // 普通会话的refresh token有效期
 	Issuer     string        `json:"issuer"`
+	Audience   string        `json:"audience"`               // 为空时不签发也不校验aud
+	AllowMissingAudience bool `json:"allow_missing_audience"` // 过渡期内接受不带aud的旧token
+	CookieDomain   string `json:"cookie_domain"`
+	CookieSecure   bool   `json:"cookie_secure"`
+	CookieSameSite string `json:"cookie_same_site"` // lax, strict, none
 }
 
 // WebSocketConfig WebSocket配置
@@ -62,6 +121,19 @@ type WebSocketConfig struct {
 	CheckOrigin     bool          `json:"check_origin"`
 	HandshakeTimeout time.Duration `json:"handshake_timeout"`
 	MaxMessageSize   int64         `json:"max_message_size"`
+	MaxConnectionsPerUser int      `json:"max_connections_per_user"` // 0表示不限制
+	ConnectionLimitPolicy string   `json:"connection_limit_policy"`  // reject, evict_oldest
+	ReplayBufferSize int64         `json:"replay_buffer_size"` // 每个设备缓存的最近消息条数，0表示关闭回放
+	ReplayTTL        time.Duration `json:"replay_ttl"`
+	PongWait         time.Duration `json:"pong_wait"`     // 读超时：超过该时间未收到消息或pong即断开
+	PingInterval     time.Duration `json:"ping_interval"` // ping发送间隔，必须小于PongWait
+	WriteWait        time.Duration `json:"write_wait"`    // 单次写入超时
+	EnableCompression    bool `json:"enable_compression"`    // 与支持的客户端协商permessage-deflate
+	CompressionThreshold int  `json:"compression_threshold"` // 不小于该字节数的消息才压缩
+	CompressionLevel     int  `json:"compression_level"`     // flate压缩级别，-2到9
+	MessageRate       int `json:"message_rate"`        // 每个连接每秒允许的上行消息数，0表示不限制
+	MessageBurst      int `json:"message_burst"`       // 允许的突发上行消息数
+	MaxRateViolations int `json:"max_rate_violations"` // 持续超限被丢弃的消息数超过该值时断开连接，0表示只丢弃不断开
 }
 
 // CORSConfig CORS配置
@@ -74,6 +146,157 @@ type CORSConfig struct {
 	MaxAge            time.Duration `json:"max_age"`
 }
 
+// PaginationConfig 分页配置
+type PaginationConfig struct {
+	DefaultLimit    int `json:"default_limit"`     // 列表接口默认每页数量
+	MaxLimit        int `json:"max_limit"`         // 列表接口每页最大数量
+	HistoryMaxLimit int `json:"history_max_limit"` // 历史数据接口单次最大条数
+}
+
+// JobsConfig 后台任务配置（间隔为0表示关闭对应任务）
+type JobsConfig struct {
+	DeviceCounterReconcileInterval time.Duration `json:"device_counter_reconcile_interval"`
+	NotificationCleanupInterval    time.Duration `json:"notification_cleanup_interval"`
+	NotificationRetention          time.Duration `json:"notification_retention"` // 已读通知保留时长
+	WebhookDeliveryInterval        time.Duration `json:"webhook_delivery_interval"`
+	WebhookCleanupInterval         time.Duration `json:"webhook_cleanup_interval"`
+	WebhookDeliveryRetention       time.Duration `json:"webhook_delivery_retention"` // 已成功或已放弃的投递记录保留时长
+	DeviceOfflineSweepInterval     time.Duration `json:"device_offline_sweep_interval"`
+	SensorDataPurgeInterval        time.Duration `json:"sensor_data_purge_interval"`
+	ProjectCounterReconcileInterval time.Duration `json:"project_counter_reconcile_interval"`
+}
+
+// 删除设备时传感器数据的处理方式
+const (
+	SensorDataRetain = "retain" // 保留一段时间（仍可按device_id查询），到期后后台清理
+	SensorDataBatch  = "batch"  // 由后台任务分批删除，避免单条大删除语句锁表
+)
+
+// SensorDataConfig 传感器数据清理配置
+type SensorDataConfig struct {
+	DeletePolicy       string        `json:"delete_policy"`        // retain, batch
+	RetainFor          time.Duration `json:"retain_for"`           // retain模式下设备删除后数据的保留时长
+	PurgeBatchSize     int           `json:"purge_batch_size"`     // 每条DELETE语句删除的最大行数
+	ExportMaxRows      int64         `json:"export_max_rows"`      // 单次历史数据导出的最大行数，超出时要求缩小范围
+	ExportMaxBytes     int64         `json:"export_max_bytes"`     // 单次导出的最大字节数，超出时截断
+	DownsampleScanRows int64         `json:"downsample_scan_rows"` // 降采样时最多读入内存的行数，超出时先在SQL中等间隔抽样
+}
+
+// PurgeDelay 设备删除后多久开始清理数据
+func (c SensorDataConfig) PurgeDelay() time.Duration {
+	if c.DeletePolicy == SensorDataRetain {
+		return c.RetainFor
+	}
+	return 0
+}
+
+// DevicesConfig 设备配置
+type DevicesConfig struct {
+	MigrateConfigOnRead bool          `json:"migrate_config_on_read"` // 读取设备详情时自动将配置升级到最新结构版本
+	ImportMaxDevices    int           `json:"import_max_devices"`     // 单次导入的最大设备数
+	ImportSyncMax       int           `json:"import_sync_max"`        // 同步导入的最大设备数，超过时需使用async=true
+	ImportJobTTL        time.Duration `json:"import_job_ttl"`         // 异步导入任务状态在Redis中的保留时长
+	DefaultConfigs      string        `json:"default_configs"`        // 按设备类型覆盖的默认配置（JSON），由models包解析
+}
+
+// QuotaConfig 每个用户的默认资源配额，0表示不限制，可按用户单独覆盖
+type QuotaConfig struct {
+	MaxDevices    int           `json:"max_devices"`
+	MaxProjects   int           `json:"max_projects"`
+	MaxSensorRows int64         `json:"max_sensor_rows"`  // 用户所有设备的传感器数据总行数
+	UsageCacheTTL time.Duration `json:"usage_cache_ttl"` // 数据行数用量的缓存时长，期间的写入可能略微超出配额
+}
+
+// ProjectsConfig 项目配置
+type ProjectsConfig struct {
+	MaxForkDepth       int           `json:"max_fork_depth"`       // Fork链的最大深度（原始项目为0），0表示不限制
+	ForkRateLimit      int           `json:"fork_rate_limit"`      // 每个用户在ForkRateWindow内最多Fork的次数，0表示不限制
+	ForkRateWindow     time.Duration `json:"fork_rate_window"`
+	StarRateLimit      int           `json:"star_rate_limit"`      // 每个用户在StarRateWindow内最多点赞/取消点赞的次数，0表示不限制
+	StarRateWindow     time.Duration `json:"star_rate_window"`
+	StarToggleCooldown time.Duration `json:"star_toggle_cooldown"` // 同一用户对同一项目两次点赞/取消点赞的最小间隔，0表示不限制
+	DefaultSort        string        `json:"default_sort"`         // 项目列表未指定sort参数时的排序，如-created_at
+}
+
+// WebhookConfig Webhook投递配置
+type WebhookConfig struct {
+	Timeout      time.Duration `json:"timeout"`       // 单次请求超时
+	MaxAttempts  int           `json:"max_attempts"`  // 超过后标记为dead不再重试
+	BackoffBase  time.Duration `json:"backoff_base"`  // 第n次失败后等待 base*2^(n-1)
+	BackoffMax   time.Duration `json:"backoff_max"`
+	BatchSize    int           `json:"batch_size"`    // 每轮最多投递的记录数
+	AllowPrivate bool          `json:"allow_private"` // 允许投递到内网/回环地址（仅用于开发环境）
+}
+
+// AccountConfig 账号管理配置
+type AccountConfig struct {
+	// DeletionPolicy 注销账号时私有数据的处理方式：delete（删除）或anonymize（转移给占位账号）
+	// 公开项目始终转移给占位账号，保证其Fork仍可追溯来源
+	DeletionPolicy string `json:"deletion_policy"`
+	RegistrationMode string `json:"registration_mode"` // open, invite_only, closed
+	DefaultRole      string `json:"default_role"`      // 新注册用户的角色
+}
+
+// 注册模式
+const (
+	RegistrationOpen       = "open"        // 开放注册
+	RegistrationInviteOnly = "invite_only" // 需要有效邀请码
+	RegistrationClosed     = "closed"      // 关闭注册
+)
+
+// 设备数据写入模式
+const (
+	IngestionModeSync  = "sync"  // 每个请求同步写入（适合低流量部署）
+	IngestionModeAsync = "async" // 进入队列后批量写入，立即返回202
+)
+
+// IngestionConfig 设备数据写入配置
+type IngestionConfig struct {
+	Mode          string        `json:"mode"`       // sync, async
+	QueueSize     int           `json:"queue_size"` // 异步队列容量，队列满时返回429
+	Workers       int           `json:"workers"`
+	BatchSize     int           `json:"batch_size"`
+	FlushInterval time.Duration `json:"flush_interval"` // 批次未满时的最长等待时间
+	
+	PayloadLimits         PayloadLimits         `json:"payload_limits"`
+	PayloadLimitOverrides map[int]PayloadLimits `json:"payload_limit_overrides"` // 按设备类型覆盖，未设置的项沿用默认值
+	MaxDecodedBytes       int                   `json:"max_decoded_bytes"`       // 请求体解压后的最大字节数，防止压缩炸弹
+	DedupWindow           time.Duration         `json:"dedup_window"`            // 开启去重的设备在该时间内重复上报相同数据时只保存一次
+	QualityChecks         bool                  `json:"quality_checks"`          // 按设备类型字段范围标记读数质量，关闭时均记为good
+	LastSeenInterval      time.Duration         `json:"last_seen_interval"`      // 在线设备每台在该间隔内只写一次last_seen，0表示每条读数都写
+	
+	// Transforms 按设备类型的数据转换流水线（JSON），由ingest包解析校验
+	Transforms string `json:"transforms"`
+	// ComputedFields 按设备类型读取时计算的派生字段（JSON），由ingest包解析编译
+	ComputedFields string `json:"computed_fields"`
+}
+
+// PayloadLimits 单条传感器数据的大小限制，0表示不限制
+type PayloadLimits struct {
+	MaxBytes int `json:"max_bytes"` // 序列化后的字节数
+	MaxKeys  int `json:"max_keys"`  // 所有层级的键总数
+	MaxDepth int `json:"max_depth"` // 对象/数组嵌套深度，顶层对象为1
+}
+
+// LimitsFor 返回指定设备类型生效的数据限制
+func (c IngestionConfig) LimitsFor(deviceType int) PayloadLimits {
+	limits := c.PayloadLimits
+	override, ok := c.PayloadLimitOverrides[deviceType]
+	if !ok {
+		return limits
+	}
+	if override.MaxBytes > 0 {
+		limits.MaxBytes = override.MaxBytes
+	}
+	if override.MaxKeys > 0 {
+		limits.MaxKeys = override.MaxKeys
+	}
+	if override.MaxDepth > 0 {
+		limits.MaxDepth = override.MaxDepth
+	}
+	return limits
+}
+
 // LogConfig 日志配置
 type LogConfig struct {
 	Level      string `json:"level"`    // debug, info, warn, error
@@ -84,6 +307,11 @@ type LogConfig struct {
 	MaxBackups int    `json:"max_backups"`
 	MaxAge     int    `json:"max_age"`     // days
 	Compress   bool   `json:"compress"`
+	SampleRate    int               `json:"sample_rate"`    // 成功请求每N条记录1条，1表示全部记录；错误和慢请求不采样
+	SlowThreshold time.Duration     `json:"slow_threshold"` // 超过该耗时的请求总是以warn级别记录，0表示不单独记录
+	RouteLevels   map[string]string `json:"route_levels"`   // 按路由前缀覆盖成功请求的日志级别，*匹配一段路径
+	BodyPaths     []string          `json:"body_paths"`     // 记录请求和响应体的路径前缀，仅GIN_MODE=debug时生效
+	BodyMaxBytes  int               `json:"body_max_bytes"` // 请求和响应体各自记录的最大字节数
 }
 
 var AppConfig *Config
@@ -101,6 +329,14 @@ func Load() (*Config, error) {
 			Mode:         getEnvWithDefault("GIN_MODE", "debug"),
 			ReadTimeout:  getDurationEnvWithDefault("READ_TIMEOUT", 30*time.Second),
 			WriteTimeout: getDurationEnvWithDefault("WRITE_TIMEOUT", 30*time.Second),
+			ShutdownTimeout: getDurationEnvWithDefault("SHUTDOWN_TIMEOUT", 30*time.Second),
+			TrustedProxies: getSliceEnvWithDefault("TRUSTED_PROXIES", nil),
+			RequestTimeout: getDurationEnvWithDefault("REQUEST_TIMEOUT", 15*time.Second),
+			RequestTimeoutOverrides: getDurationMapEnv("REQUEST_TIMEOUT_OVERRIDES"),
+			EnforceJSONContentType: getBoolEnvWithDefault("ENFORCE_JSON_CONTENT_TYPE", true),
+			DeprecatedRoutes: getStringMapEnv("API_DEPRECATED_ROUTES"),
+			PublicCacheMaxAge: getDurationEnvWithDefault("PUBLIC_CACHE_MAX_AGE", time.Minute),
+			PublicCacheMaxAgeOverrides: getDurationMapEnv("PUBLIC_CACHE_MAX_AGE_OVERRIDES"),
 			CORS: CORSConfig{
 				AllowedOrigins: []string{
 					getEnvWithDefault("FRONTEND_URL", "http://localhost:8501"),
@@ -110,9 +346,9 @@ func Load() (*Config, error) {
 				AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
 				AllowedHeaders: []string{
 					"Origin", "Content-Type", "Accept", "Authorization",
-					"X-Requested-With", "X-CSRF-Token",
+					"X-Requested-With", "X-CSRF-Token", "If-None-Match",
 				},
-				ExposedHeaders:   []string{"X-Total-Count"},
+				ExposedHeaders:   []string{"X-Total-Count", "Link", "ETag", "X-API-Version", "Deprecation", "Sunset", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After"},
 				AllowCredentials: true,
 				MaxAge:          12 * time.Hour,
 			},
@@ -125,18 +361,30 @@ func Load() (*Config, error) {
 			DBName:   getEnvWithDefault("DB_NAME", "iot_platform"),
 			SSLMode:  getEnvWithDefault("DB_SSL_MODE", "disable"),
 			TimeZone: getEnvWithDefault("DB_TIMEZONE", "UTC"),
+			JSONUseNumber: getBoolEnvWithDefault("DB_JSON_USE_NUMBER", true),
+			MigrateMode:   getEnvWithDefault("DB_MIGRATE_MODE", "auto"),
+			IndexCheck:    getEnvWithDefault("DB_INDEX_CHECK", "warn"),
+			SensorTimeIndex: getEnvWithDefault("DB_SENSOR_TIME_INDEX", "btree"),
 		},
 		Redis: RedisConfig{
 			Host:     getEnvWithDefault("REDIS_HOST", "localhost"),
 			Port:     getEnvWithDefault("REDIS_PORT", "6379"),
 			Password: getEnvWithDefault("REDIS_PASSWORD", ""),
 			DB:       getIntEnvWithDefault("REDIS_DB", 0),
+			KeyPrefix: getEnvWithDefault("REDIS_KEY_PREFIX", ""),
 		},
 		JWT: JWTConfig{
 			Secret:         getEnvWithDefault("JWT_SECRET", "your-secret-key-change-in-production"),
 			Expires:        getDurationEnvWithDefault("JWT_EXPIRES", 24*time.Hour),
 			RefreshExpires: getDurationEnvWithDefault("JWT_REFRESH_EXPIRES", 7*24*time.Hour),
+			SessionExpires:        getDurationEnvWithDefault("JWT_SESSION_EXPIRES", 2*time.Hour),
+			SessionRefreshExpires: getDurationEnvWithDefault("JWT_SESSION_REFRESH_EXPIRES", 12*time.Hour),
 			Issuer:         getEnvWithDefault("JWT_ISSUER", "iot-platform"),
+			Audience:       getEnvWithDefault("JWT_AUDIENCE", ""),
+			AllowMissingAudience: getBoolEnvWithDefault("JWT_ALLOW_MISSING_AUDIENCE", false),
+			CookieDomain:   getEnvWithDefault("AUTH_COOKIE_DOMAIN", ""),
+			CookieSecure:   getBoolEnvWithDefault("AUTH_COOKIE_SECURE", true),
+			CookieSameSite: getEnvWithDefault("AUTH_COOKIE_SAMESITE", "lax"),
 		},
 		WebSocket: WebSocketConfig{
 			ReadBufferSize:   getIntEnvWithDefault("WS_READ_BUFFER", 1024),
@@ -144,6 +392,19 @@ func Load() (*Config, error) {
 			CheckOrigin:      getBoolEnvWithDefault("WS_CHECK_ORIGIN", false),
 			HandshakeTimeout: getDurationEnvWithDefault("WS_HANDSHAKE_TIMEOUT", 10*time.Second),
 			MaxMessageSize:   getInt64EnvWithDefault("WS_MAX_MESSAGE_SIZE", 512),
+			MaxConnectionsPerUser: getIntEnvWithDefault("WS_MAX_CONNECTIONS_PER_USER", 10),
+			ConnectionLimitPolicy: getEnvWithDefault("WS_CONNECTION_LIMIT_POLICY", "evict_oldest"),
+			ReplayBufferSize: getInt64EnvWithDefault("WS_REPLAY_BUFFER_SIZE", 100),
+			ReplayTTL:        getDurationEnvWithDefault("WS_REPLAY_TTL", 10*time.Minute),
+			PongWait:         getDurationEnvWithDefault("WS_PONG_WAIT", 60*time.Second),
+			PingInterval:     getDurationEnvWithDefault("WS_PING_INTERVAL", 54*time.Second),
+			WriteWait:        getDurationEnvWithDefault("WS_WRITE_WAIT", 10*time.Second),
+			EnableCompression:    getBoolEnvWithDefault("WS_ENABLE_COMPRESSION", false),
+			CompressionThreshold: getIntEnvWithDefault("WS_COMPRESSION_THRESHOLD", 1024),
+			CompressionLevel:     getIntEnvWithDefault("WS_COMPRESSION_LEVEL", 1),
+			MessageRate:       getIntEnvWithDefault("WS_MESSAGE_RATE", 10),
+			MessageBurst:      getIntEnvWithDefault("WS_MESSAGE_BURST", 20),
+			MaxRateViolations: getIntEnvWithDefault("WS_MAX_RATE_VIOLATIONS", 50),
 		},
 		Log: LogConfig{
 			Level:      getEnvWithDefault("LOG_LEVEL", "info"),
@@ -154,6 +415,89 @@ func Load() (*Config, error) {
 			MaxBackups: getIntEnvWithDefault("LOG_MAX_BACKUPS", 3),
 			MaxAge:     getIntEnvWithDefault("LOG_MAX_AGE", 28),
 			Compress:   getBoolEnvWithDefault("LOG_COMPRESS", true),
+			SampleRate:    getIntEnvWithDefault("LOG_SAMPLE_RATE", 1),
+			SlowThreshold: getDurationEnvWithDefault("LOG_SLOW_THRESHOLD", time.Second),
+			RouteLevels:   getStringMapEnv("LOG_ROUTE_LEVELS"),
+			BodyPaths:     getSliceEnvWithDefault("LOG_BODY_PATHS", nil),
+			BodyMaxBytes:  getIntEnvWithDefault("LOG_BODY_MAX_BYTES", 4096),
+		},
+		Pagination: PaginationConfig{
+			DefaultLimit:    getIntEnvWithDefault("PAGINATION_DEFAULT_LIMIT", 10),
+			MaxLimit:        getIntEnvWithDefault("PAGINATION_MAX_LIMIT", 100),
+			HistoryMaxLimit: getIntEnvWithDefault("PAGINATION_HISTORY_MAX_LIMIT", 1000),
+		},
+		Jobs: JobsConfig{
+			DeviceCounterReconcileInterval: getDurationEnvWithDefault("JOB_DEVICE_COUNTER_RECONCILE_INTERVAL", time.Hour),
+			NotificationCleanupInterval:    getDurationEnvWithDefault("JOB_NOTIFICATION_CLEANUP_INTERVAL", 24*time.Hour),
+			NotificationRetention:          getDurationEnvWithDefault("NOTIFICATION_RETENTION", 30*24*time.Hour),
+			WebhookDeliveryInterval:        getDurationEnvWithDefault("JOB_WEBHOOK_DELIVERY_INTERVAL", 5*time.Second),
+			WebhookCleanupInterval:         getDurationEnvWithDefault("JOB_WEBHOOK_CLEANUP_INTERVAL", 24*time.Hour),
+			WebhookDeliveryRetention:       getDurationEnvWithDefault("WEBHOOK_DELIVERY_RETENTION", 7*24*time.Hour),
+			DeviceOfflineSweepInterval:     getDurationEnvWithDefault("JOB_DEVICE_OFFLINE_SWEEP_INTERVAL", time.Minute),
+			SensorDataPurgeInterval:        getDurationEnvWithDefault("JOB_SENSOR_DATA_PURGE_INTERVAL", 5*time.Minute),
+			ProjectCounterReconcileInterval: getDurationEnvWithDefault("JOB_PROJECT_COUNTER_RECONCILE_INTERVAL", 5*time.Minute),
+		},
+		Ingestion: IngestionConfig{
+			Mode:          getEnvWithDefault("INGEST_MODE", IngestionModeSync),
+			QueueSize:     getIntEnvWithDefault("INGEST_QUEUE_SIZE", 10000),
+			Workers:       getIntEnvWithDefault("INGEST_WORKERS", 4),
+			BatchSize:     getIntEnvWithDefault("INGEST_BATCH_SIZE", 500),
+			FlushInterval: getDurationEnvWithDefault("INGEST_FLUSH_INTERVAL", time.Second),
+			PayloadLimits: PayloadLimits{
+				MaxBytes: getIntEnvWithDefault("INGEST_MAX_PAYLOAD_BYTES", 16*1024),
+				MaxKeys:  getIntEnvWithDefault("INGEST_MAX_PAYLOAD_KEYS", 256),
+				MaxDepth: getIntEnvWithDefault("INGEST_MAX_PAYLOAD_DEPTH", 5),
+			},
+			PayloadLimitOverrides: getPayloadLimitOverridesEnv("INGEST_PAYLOAD_LIMIT_OVERRIDES"),
+			MaxDecodedBytes:       getIntEnvWithDefault("INGEST_MAX_DECODED_BYTES", 1024*1024),
+			DedupWindow:           getDurationEnvWithDefault("INGEST_DEDUP_WINDOW", 10*time.Minute),
+			QualityChecks:         getBoolEnvWithDefault("INGEST_QUALITY_CHECKS", true),
+			LastSeenInterval:      getDurationEnvWithDefault("INGEST_LAST_SEEN_INTERVAL", 30*time.Second),
+			Transforms:            getEnvWithDefault("INGEST_TRANSFORMS", ""),
+			ComputedFields:        getEnvWithDefault("INGEST_COMPUTED_FIELDS", ""),
+		},
+		Webhook: WebhookConfig{
+			Timeout:      getDurationEnvWithDefault("WEBHOOK_TIMEOUT", 10*time.Second),
+			MaxAttempts:  getIntEnvWithDefault("WEBHOOK_MAX_ATTEMPTS", 6),
+			BackoffBase:  getDurationEnvWithDefault("WEBHOOK_BACKOFF_BASE", 30*time.Second),
+			BackoffMax:   getDurationEnvWithDefault("WEBHOOK_BACKOFF_MAX", time.Hour),
+			BatchSize:    getIntEnvWithDefault("WEBHOOK_BATCH_SIZE", 50),
+			AllowPrivate: getBoolEnvWithDefault("WEBHOOK_ALLOW_PRIVATE", false),
+		},
+		SensorData: SensorDataConfig{
+			DeletePolicy:       getEnvWithDefault("SENSOR_DATA_DELETE_POLICY", SensorDataBatch),
+			RetainFor:          getDurationEnvWithDefault("SENSOR_DATA_RETAIN_FOR", 30*24*time.Hour),
+			PurgeBatchSize:     getIntEnvWithDefault("SENSOR_DATA_PURGE_BATCH_SIZE", 5000),
+			ExportMaxRows:      getInt64EnvWithDefault("SENSOR_DATA_EXPORT_MAX_ROWS", 1000000),
+			ExportMaxBytes:     getInt64EnvWithDefault("SENSOR_DATA_EXPORT_MAX_BYTES", 256*1024*1024),
+			DownsampleScanRows: getInt64EnvWithDefault("SENSOR_DATA_DOWNSAMPLE_SCAN_ROWS", 200000),
+		},
+		Devices: DevicesConfig{
+			MigrateConfigOnRead: getBoolEnvWithDefault("DEVICE_CONFIG_MIGRATE_ON_READ", true),
+			ImportMaxDevices:    getIntEnvWithDefault("DEVICE_IMPORT_MAX", 5000),
+			ImportSyncMax:       getIntEnvWithDefault("DEVICE_IMPORT_SYNC_MAX", 200),
+			ImportJobTTL:        getDurationEnvWithDefault("DEVICE_IMPORT_JOB_TTL", 24*time.Hour),
+			DefaultConfigs:      getEnvWithDefault("DEVICE_DEFAULT_CONFIGS", ""),
+		},
+		Quota: QuotaConfig{
+			MaxDevices:    getIntEnvWithDefault("QUOTA_MAX_DEVICES", 0),
+			MaxProjects:   getIntEnvWithDefault("QUOTA_MAX_PROJECTS", 0),
+			MaxSensorRows: getInt64EnvWithDefault("QUOTA_MAX_SENSOR_ROWS", 0),
+			UsageCacheTTL: getDurationEnvWithDefault("QUOTA_USAGE_CACHE_TTL", time.Minute),
+		},
+		Projects: ProjectsConfig{
+			MaxForkDepth:       getIntEnvWithDefault("PROJECT_MAX_FORK_DEPTH", 10),
+			ForkRateLimit:      getIntEnvWithDefault("PROJECT_FORK_RATE_LIMIT", 10),
+			ForkRateWindow:     getDurationEnvWithDefault("PROJECT_FORK_RATE_WINDOW", time.Hour),
+			StarRateLimit:      getIntEnvWithDefault("PROJECT_STAR_RATE_LIMIT", 30),
+			StarRateWindow:     getDurationEnvWithDefault("PROJECT_STAR_RATE_WINDOW", time.Minute),
+			StarToggleCooldown: getDurationEnvWithDefault("PROJECT_STAR_TOGGLE_COOLDOWN", 5*time.Second),
+			DefaultSort:        getEnvWithDefault("PROJECT_DEFAULT_SORT", "-created_at"),
+		},
+		Account: AccountConfig{
+			DeletionPolicy: getEnvWithDefault("ACCOUNT_DELETION_POLICY", "delete"),
+			RegistrationMode: getEnvWithDefault("REGISTRATION_MODE", RegistrationOpen),
+			DefaultRole:      getEnvWithDefault("REGISTRATION_DEFAULT_ROLE", "user"),
 		},
 	}
 	
@@ -161,19 +505,347 @@ func Load() (*Config, error) {
 	return config, nil
 }
 
-// Validate 验证配置
-func (c *Config) Validate() error {
-	if c.JWT.Secret == "your-secret-key-change-in-production" {
-		return fmt.Errorf("please change JWT secret in production")
+// ValidationError 配置校验错误，汇总所有不合法的配置项以便一次修正
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return e.Problems[0]
+	}
+	return fmt.Sprintf("%d configuration problems:\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+// configProblems 收集校验过程中发现的问题
+type configProblems []string
+
+func (p *configProblems) addf(format string, args ...interface{}) {
+	*p = append(*p, fmt.Sprintf(format, args...))
+}
+
+// positive 要求时长大于0
+func (p *configProblems) positive(env string, value time.Duration) {
+	if value <= 0 {
+		p.addf("%s must be a positive duration, got %s", env, value)
+	}
+}
+
+// nonNegative 要求时长不小于0（0通常表示关闭或不限制）
+func (p *configProblems) nonNegative(env string, value time.Duration) {
+	if value < 0 {
+		p.addf("%s must not be negative, got %s", env, value)
 	}
+}
+
+// Validate 验证配置，返回汇总了所有问题的*ValidationError
+func (c *Config) Validate() error {
+	var problems configProblems
+	
+	c.validateServer(&problems)
 	
 	if c.Database.Password == "" {
-		return fmt.Errorf("database password is required")
+		problems.addf("DB_PASSWORD is required")
+	}
+	if port, err := strconv.Atoi(c.Database.Port); err != nil || port < 1 || port > 65535 {
+		problems.addf("DB_PORT must be a number between 1 and 65535, got %q", c.Database.Port)
+	}
+	switch c.Database.MigrateMode {
+	case "auto", "always", "skip", "dry-run":
+	default:
+		problems.addf("DB_MIGRATE_MODE must be one of auto, always, skip, dry-run, got %q", c.Database.MigrateMode)
+	}
+	switch c.Database.IndexCheck {
+	case "off", "warn", "fail":
+	default:
+		problems.addf("DB_INDEX_CHECK must be one of off, warn, fail, got %q", c.Database.IndexCheck)
+	}
+	if c.Database.SensorTimeIndex != "btree" && c.Database.SensorTimeIndex != "brin" {
+		problems.addf("DB_SENSOR_TIME_INDEX must be btree or brin, got %q", c.Database.SensorTimeIndex)
+	}
+	
+	if port, err := strconv.Atoi(c.Redis.Port); err != nil || port < 1 || port > 65535 {
+		problems.addf("REDIS_PORT must be a number between 1 and 65535, got %q", c.Redis.Port)
+	}
+	// Redis默认提供16个逻辑库
+	if c.Redis.DB < 0 || c.Redis.DB > 15 {
+		problems.addf("REDIS_DB must be between 0 and 15, got %d", c.Redis.DB)
+	}
+	if strings.ContainsAny(c.Redis.KeyPrefix, " \t\r\n*?[]") {
+		problems.addf("REDIS_KEY_PREFIX must not contain whitespace or glob characters, got %q", c.Redis.KeyPrefix)
+	}
+	
+	c.validateJWT(&problems)
+	c.validateLog(&problems)
+	
+	ws := c.WebSocket
+	problems.positive("WS_HANDSHAKE_TIMEOUT", ws.HandshakeTimeout)
+	problems.positive("WS_PONG_WAIT", ws.PongWait)
+	problems.positive("WS_PING_INTERVAL", ws.PingInterval)
+	problems.positive("WS_WRITE_WAIT", ws.WriteWait)
+	if ws.PingInterval > 0 && ws.PingInterval >= ws.PongWait {
+		problems.addf("WS_PING_INTERVAL (%s) must be less than WS_PONG_WAIT (%s)", ws.PingInterval, ws.PongWait)
+	}
+	if ws.ReplayBufferSize > 0 {
+		problems.positive("WS_REPLAY_TTL", ws.ReplayTTL)
+	}
+	if ws.CompressionThreshold < 0 {
+		problems.addf("WS_COMPRESSION_THRESHOLD must not be negative, got %d", ws.CompressionThreshold)
+	}
+	// gorilla/websocket接受的压缩级别范围
+	if ws.CompressionLevel < -2 || ws.CompressionLevel > 9 {
+		problems.addf("WS_COMPRESSION_LEVEL must be between -2 and 9, got %d", ws.CompressionLevel)
+	}
+	if ws.ConnectionLimitPolicy != "reject" && ws.ConnectionLimitPolicy != "evict_oldest" {
+		problems.addf("WS_CONNECTION_LIMIT_POLICY must be reject or evict_oldest, got %q", ws.ConnectionLimitPolicy)
+	}
+	if ws.MessageRate < 0 || ws.MaxRateViolations < 0 {
+		problems.addf("WS_MESSAGE_RATE and WS_MAX_RATE_VIOLATIONS must not be negative, got %d and %d", ws.MessageRate, ws.MaxRateViolations)
+	}
+	if ws.MessageRate > 0 && ws.MessageBurst < 1 {
+		problems.addf("WS_MESSAGE_BURST must be at least 1 when WS_MESSAGE_RATE is set, got %d", ws.MessageBurst)
+	}
+	
+	pagination := c.Pagination
+	if pagination.DefaultLimit < 1 || pagination.MaxLimit < pagination.DefaultLimit {
+		problems.addf("PAGINATION_MAX_LIMIT (%d) must be at least PAGINATION_DEFAULT_LIMIT (%d), and both must be positive", pagination.MaxLimit, pagination.DefaultLimit)
 	}
+	if pagination.HistoryMaxLimit < 1 {
+		problems.addf("PAGINATION_HISTORY_MAX_LIMIT must be positive")
+	}
+	
+	jobs := c.Jobs
+	problems.nonNegative("JOB_DEVICE_COUNTER_RECONCILE_INTERVAL", jobs.DeviceCounterReconcileInterval)
+	problems.nonNegative("JOB_NOTIFICATION_CLEANUP_INTERVAL", jobs.NotificationCleanupInterval)
+	problems.positive("NOTIFICATION_RETENTION", jobs.NotificationRetention)
+	problems.nonNegative("JOB_WEBHOOK_DELIVERY_INTERVAL", jobs.WebhookDeliveryInterval)
+	problems.nonNegative("JOB_WEBHOOK_CLEANUP_INTERVAL", jobs.WebhookCleanupInterval)
+	problems.positive("WEBHOOK_DELIVERY_RETENTION", jobs.WebhookDeliveryRetention)
+	problems.nonNegative("JOB_DEVICE_OFFLINE_SWEEP_INTERVAL", jobs.DeviceOfflineSweepInterval)
+	problems.nonNegative("JOB_SENSOR_DATA_PURGE_INTERVAL", jobs.SensorDataPurgeInterval)
+	problems.nonNegative("JOB_PROJECT_COUNTER_RECONCILE_INTERVAL", jobs.ProjectCounterReconcileInterval)
+	
+	devices := c.Devices
+	if devices.ImportSyncMax < 1 || devices.ImportMaxDevices < devices.ImportSyncMax {
+		problems.addf("DEVICE_IMPORT_MAX (%d) must be at least DEVICE_IMPORT_SYNC_MAX (%d), and both must be positive", devices.ImportMaxDevices, devices.ImportSyncMax)
+	}
+	problems.positive("DEVICE_IMPORT_JOB_TTL", devices.ImportJobTTL)
+	
+	c.validateIngestion(&problems)
+	
+	quota := c.Quota
+	if quota.MaxDevices < 0 || quota.MaxProjects < 0 || quota.MaxSensorRows < 0 {
+		problems.addf("QUOTA_MAX_DEVICES, QUOTA_MAX_PROJECTS and QUOTA_MAX_SENSOR_ROWS must not be negative (0 means unlimited)")
+	}
+	problems.positive("QUOTA_USAGE_CACHE_TTL", quota.UsageCacheTTL)
+	if c.Projects.MaxForkDepth < 0 {
+		problems.addf("PROJECT_MAX_FORK_DEPTH must not be negative (0 means unlimited)")
+	}
+	if c.Projects.ForkRateLimit < 0 || c.Projects.StarRateLimit < 0 {
+		problems.addf("PROJECT_FORK_RATE_LIMIT and PROJECT_STAR_RATE_LIMIT must not be negative (0 means unlimited)")
+	}
+	problems.positive("PROJECT_FORK_RATE_WINDOW", c.Projects.ForkRateWindow)
+	problems.positive("PROJECT_STAR_RATE_WINDOW", c.Projects.StarRateWindow)
+	problems.nonNegative("PROJECT_STAR_TOGGLE_COOLDOWN", c.Projects.StarToggleCooldown)
 	
+	webhook := c.Webhook
+	if webhook.MaxAttempts < 1 {
+		problems.addf("WEBHOOK_MAX_ATTEMPTS must be positive")
+	}
+	problems.positive("WEBHOOK_TIMEOUT", webhook.Timeout)
+	problems.positive("WEBHOOK_BACKOFF_BASE", webhook.BackoffBase)
+	if webhook.BackoffMax < webhook.BackoffBase {
+		problems.addf("WEBHOOK_BACKOFF_MAX (%s) must not be less than WEBHOOK_BACKOFF_BASE (%s)", webhook.BackoffMax, webhook.BackoffBase)
+	}
+	if webhook.BatchSize < 1 {
+		problems.addf("WEBHOOK_BATCH_SIZE must be positive")
+	}
+	
+	switch c.SensorData.DeletePolicy {
+	case SensorDataRetain:
+		problems.positive("SENSOR_DATA_RETAIN_FOR", c.SensorData.RetainFor)
+	case SensorDataBatch:
+	default:
+		problems.addf("SENSOR_DATA_DELETE_POLICY must be retain or batch, got %q", c.SensorData.DeletePolicy)
+	}
+	if c.SensorData.PurgeBatchSize < 1 {
+		problems.addf("SENSOR_DATA_PURGE_BATCH_SIZE must be positive")
+	}
+	if c.SensorData.ExportMaxRows < 1 || c.SensorData.ExportMaxBytes < 1 {
+		problems.addf("SENSOR_DATA_EXPORT_MAX_ROWS and SENSOR_DATA_EXPORT_MAX_BYTES must be positive")
+	}
+	if c.SensorData.DownsampleScanRows < 1 {
+		problems.addf("SENSOR_DATA_DOWNSAMPLE_SCAN_ROWS must be positive")
+	}
+	
+	if c.Account.DeletionPolicy != "delete" && c.Account.DeletionPolicy != "anonymize" {
+		problems.addf("ACCOUNT_DELETION_POLICY must be delete or anonymize, got %q", c.Account.DeletionPolicy)
+	}
+	switch c.Account.RegistrationMode {
+	case RegistrationOpen, RegistrationInviteOnly, RegistrationClosed:
+	default:
+		problems.addf("REGISTRATION_MODE must be open, invite_only or closed, got %q", c.Account.RegistrationMode)
+	}
+	// 自助注册不能直接获得管理员或版主权限
+	if c.Account.DefaultRole == "" || c.Account.DefaultRole == "admin" || c.Account.DefaultRole == "moderator" {
+		problems.addf("REGISTRATION_DEFAULT_ROLE must be non-empty and not admin or moderator, got %q", c.Account.DefaultRole)
+	}
+	
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
 	return nil
 }
 
+// validateServer 校验监听端口、超时、代理和CORS配置
+func (c *Config) validateServer(problems *configProblems) {
+	server := c.Server
+	if port, err := strconv.Atoi(server.Port); err != nil || port < 1 || port > 65535 {
+		problems.addf("PORT must be a number between 1 and 65535, got %q", server.Port)
+	}
+	switch server.Mode {
+	case "debug", "release", "test":
+	default:
+		problems.addf("GIN_MODE must be debug, release or test, got %q", server.Mode)
+	}
+	problems.positive("READ_TIMEOUT", server.ReadTimeout)
+	problems.positive("WRITE_TIMEOUT", server.WriteTimeout)
+	problems.positive("SHUTDOWN_TIMEOUT", server.ShutdownTimeout)
+	problems.nonNegative("REQUEST_TIMEOUT", server.RequestTimeout)
+	for group, timeout := range server.RequestTimeoutOverrides {
+		problems.nonNegative("REQUEST_TIMEOUT_OVERRIDES["+group+"]", timeout)
+	}
+	problems.nonNegative("PUBLIC_CACHE_MAX_AGE", server.PublicCacheMaxAge)
+	for group, maxAge := range server.PublicCacheMaxAgeOverrides {
+		problems.nonNegative("PUBLIC_CACHE_MAX_AGE_OVERRIDES["+group+"]", maxAge)
+	}
+	
+	for route, date := range server.DeprecatedRoutes {
+		if _, err := ParseSunset(date); err != nil {
+			problems.addf("API_DEPRECATED_ROUTES[%s] sunset must be a date (2006-01-02) or RFC 3339 time, got %q", route, date)
+		}
+	}
+	
+	for _, proxy := range server.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				problems.addf("TRUSTED_PROXIES entry %q must be an IP or CIDR", proxy)
+			}
+		}
+	}
+	
+	// 来源必须是scheme://host[:port]，不能带路径，否则浏览器发送的Origin永远不会匹配
+	for _, origin := range server.CORS.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+			(u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+			problems.addf("CORS origin %q (FRONTEND_URL) must be an http(s) URL of the form scheme://host[:port]", origin)
+		}
+	}
+	problems.nonNegative("CORS max age", server.CORS.MaxAge)
+}
+
+// validateJWT 校验JWT密钥、有效期和认证Cookie配置
+func (c *Config) validateJWT(problems *configProblems) {
+	jwtCfg := c.JWT
+	if jwtCfg.Secret == "your-secret-key-change-in-production" {
+		problems.addf("JWT_SECRET must be changed from the default value")
+	}
+	problems.positive("JWT_EXPIRES", jwtCfg.Expires)
+	problems.positive("JWT_REFRESH_EXPIRES", jwtCfg.RefreshExpires)
+	problems.positive("JWT_SESSION_EXPIRES", jwtCfg.SessionExpires)
+	problems.positive("JWT_SESSION_REFRESH_EXPIRES", jwtCfg.SessionRefreshExpires)
+	if jwtCfg.SessionRefreshExpires > jwtCfg.RefreshExpires {
+		problems.addf("JWT_SESSION_REFRESH_EXPIRES (%s) must not exceed JWT_REFRESH_EXPIRES (%s)", jwtCfg.SessionRefreshExpires, jwtCfg.RefreshExpires)
+	}
+	switch jwtCfg.CookieSameSite {
+	case "lax", "strict":
+	case "none":
+		if !jwtCfg.CookieSecure {
+			problems.addf("AUTH_COOKIE_SAMESITE=none requires AUTH_COOKIE_SECURE=true")
+		}
+	default:
+		problems.addf("AUTH_COOKIE_SAMESITE must be lax, strict or none, got %q", jwtCfg.CookieSameSite)
+	}
+}
+
+// validateLog 校验日志级别、格式、输出以及采样配置
+func (c *Config) validateLog(problems *configProblems) {
+	logCfg := c.Log
+	if !validLogLevel(logCfg.Level) {
+		problems.addf("LOG_LEVEL must be one of trace, debug, info, warn, error, got %q", logCfg.Level)
+	}
+	if logCfg.Format != "json" && logCfg.Format != "text" {
+		problems.addf("LOG_FORMAT must be json or text, got %q", logCfg.Format)
+	}
+	switch logCfg.Output {
+	case "stdout":
+	case "file":
+		if logCfg.FilePath == "" {
+			problems.addf("LOG_FILE_PATH is required when LOG_OUTPUT=file")
+		}
+	default:
+		problems.addf("LOG_OUTPUT must be stdout or file, got %q", logCfg.Output)
+	}
+	if logCfg.SampleRate < 1 {
+		problems.addf("LOG_SAMPLE_RATE must be at least 1, got %d", logCfg.SampleRate)
+	}
+	problems.nonNegative("LOG_SLOW_THRESHOLD", logCfg.SlowThreshold)
+	for route, level := range logCfg.RouteLevels {
+		if !strings.HasPrefix(route, "/") {
+			problems.addf("LOG_ROUTE_LEVELS route %q must start with /", route)
+		}
+		if !validLogLevel(level) {
+			problems.addf("LOG_ROUTE_LEVELS level %q for route %s must be one of trace, debug, info, warn, error", level, route)
+		}
+	}
+	for _, path := range logCfg.BodyPaths {
+		if !strings.HasPrefix(path, "/") {
+			problems.addf("LOG_BODY_PATHS prefix %q must start with /", path)
+		}
+	}
+	if len(logCfg.BodyPaths) > 0 && logCfg.BodyMaxBytes < 1 {
+		problems.addf("LOG_BODY_MAX_BYTES must be at least 1 when LOG_BODY_PATHS is set, got %d", logCfg.BodyMaxBytes)
+	}
+}
+
+// validateIngestion 校验数据写入模式、队列和数据限制
+func (c *Config) validateIngestion(problems *configProblems) {
+	ingestion := c.Ingestion
+	switch ingestion.Mode {
+	case IngestionModeSync:
+	case IngestionModeAsync:
+		if ingestion.QueueSize < 1 || ingestion.BatchSize < 1 || ingestion.Workers < 1 {
+			problems.addf("INGEST_QUEUE_SIZE, INGEST_BATCH_SIZE and INGEST_WORKERS must be positive when INGEST_MODE=async")
+		}
+		problems.positive("INGEST_FLUSH_INTERVAL", ingestion.FlushInterval)
+	default:
+		problems.addf("INGEST_MODE must be sync or async, got %q", ingestion.Mode)
+	}
+	
+	limits := ingestion.PayloadLimits
+	if limits.MaxBytes < 0 || limits.MaxKeys < 0 || limits.MaxDepth < 0 {
+		problems.addf("INGEST_MAX_PAYLOAD_BYTES, INGEST_MAX_PAYLOAD_KEYS and INGEST_MAX_PAYLOAD_DEPTH must not be negative")
+	}
+	if ingestion.MaxDecodedBytes < 1 {
+		problems.addf("INGEST_MAX_DECODED_BYTES must be positive")
+	}
+	problems.positive("INGEST_DEDUP_WINDOW", ingestion.DedupWindow)
+	problems.nonNegative("INGEST_LAST_SEEN_INTERVAL", ingestion.LastSeenInterval)
+}
+
+// validLogLevel 是否为logrus支持的请求日志级别
+func validLogLevel(level string) bool {
+	switch level {
+	case "trace", "debug", "info", "warn", "warning", "error":
+		return true
+	}
+	return false
+}
+
 // IsDevelopment 是否为开发环境
 func (c *Config) IsDevelopment() bool {
 	return c.Server.Mode == "debug"
@@ -219,6 +891,19 @@ func getBoolEnvWithDefault(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+func getSliceEnvWithDefault(key string, defaultValue []string) []string {
+	if value := os.Getenv(key); value != "" {
+		var result []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				result = append(result, item)
+			}
+		}
+		return result
+	}
+	return defaultValue
+}
+
 func getDurationEnvWithDefault(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
@@ -226,4 +911,66 @@ func getDurationEnvWithDefault(key string, defaultValue time.Duration) time.Dura
 		}
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
+
+// getDurationMapEnv 解析形如"key1=10s,key2=1m"的时长映射，忽略格式错误的项
+func getDurationMapEnv(key string) map[string]time.Duration {
+	result := make(map[string]time.Duration)
+	for _, item := range getSliceEnvWithDefault(key, nil) {
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			continue
+		}
+		if duration, err := time.ParseDuration(strings.TrimSpace(value)); err == nil {
+			result[strings.TrimSpace(name)] = duration
+		}
+	}
+	return result
+}
+
+// getStringMapEnv 解析形如"a=x,b=y"的键值对，忽略格式错误的项
+func getStringMapEnv(key string) map[string]string {
+	result := make(map[string]string)
+	for _, item := range getSliceEnvWithDefault(key, nil) {
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			continue
+		}
+		result[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return result
+}
+
+// getPayloadLimitOverridesEnv 解析形如"4=65536:512:6,7=0:1024:0"的按设备类型限制，
+// 值依次为最大字节数、最大键数和最大深度，0表示沿用默认值，忽略格式错误的项
+func getPayloadLimitOverridesEnv(key string) map[int]PayloadLimits {
+	result := make(map[int]PayloadLimits)
+	for _, item := range getSliceEnvWithDefault(key, nil) {
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			continue
+		}
+		deviceType, err := strconv.Atoi(strings.TrimSpace(name))
+		if err != nil {
+			continue
+		}
+		parts := strings.Split(value, ":")
+		if len(parts) != 3 {
+			continue
+		}
+		numbers := make([]int, 3)
+		valid := true
+		for i, part := range parts {
+			n, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || n < 0 {
+				valid = false
+				break
+			}
+			numbers[i] = n
+		}
+		if valid {
+			result[deviceType] = PayloadLimits{MaxBytes: numbers[0], MaxKeys: numbers[1], MaxDepth: numbers[2]}
+		}
+	}
+	return result
+}
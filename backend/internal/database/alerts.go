@@ -0,0 +1,44 @@
+package database
+
+import (
+	"context"
+	
+	"iot-platform-backend/internal/models"
+)
+
+// RecordReadingAlerts 为超出范围的读数保存告警，返回产生了新告警的设备所有者
+func RecordReadingAlerts(ctx context.Context, devices map[string]models.Device, readings []models.SensorData) ([]uint, error) {
+	alerts := make([]models.Alert, 0)
+	owners := make(map[uint]bool)
+	for _, reading := range readings {
+		device, ok := devices[reading.DeviceID]
+		if !ok {
+			continue
+		}
+		if alert := models.NewReadingAlert(device, reading); alert != nil {
+			alerts = append(alerts, *alert)
+			owners[device.OwnerID] = true
+		}
+	}
+	if len(alerts) == 0 {
+		return nil, nil
+	}
+	if err := GetDB().WithContext(ctx).Create(&alerts).Error; err != nil {
+		return nil, err
+	}
+	
+	ownerIDs := make([]uint, 0, len(owners))
+	for ownerID := range owners {
+		ownerIDs = append(ownerIDs, ownerID)
+	}
+	return ownerIDs, nil
+}
+
+// UnacknowledgedAlertCount 用户当前设备上未确认的告警数
+func UnacknowledgedAlertCount(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := GetDB().WithContext(ctx).Model(&models.Alert{}).
+		Where("acknowledged_at IS NULL AND device_id IN (SELECT device_id FROM devices WHERE owner_id = ? AND deleted_at IS NULL)", userID).
+		Count(&count).Error
+	return count, err
+}
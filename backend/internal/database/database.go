@@ -1,6 +1,8 @@
 package database
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -23,10 +25,17 @@ type Config struct {
 	DBName   string
 	SSLMode  string
 	TimeZone string
+	JSONUseNumber bool
+	SensorTimeIndex string // sensor_data.timestamp的索引类型：btree或brin
 }
 
 // Connect 连接数据库
 func Connect(cfg *Config) error {
+	models.JSONBUseNumber = cfg.JSONUseNumber
+	if cfg.SensorTimeIndex != "" {
+		sensorTimeIndex = cfg.SensorTimeIndex
+	}
+	
 	dsn := fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s TimeZone=%s",
 		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode, cfg.TimeZone,
@@ -43,6 +52,8 @@ func Connect(cfg *Config) error {
 	// 连接数据库
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
 		Logger: gormLogger,
+		// 将唯一约束冲突等驱动错误转换为gorm.ErrDuplicatedKey等通用错误
+		TranslateError: true,
 		NowFunc: func() time.Time {
 			return time.Now().UTC()
 		},
@@ -69,57 +80,9 @@ func Connect(cfg *Config) error {
 	return nil
 }
 
-// Migrate 自动迁移数据库表
-func Migrate() error {
-	if DB == nil {
-		return fmt.Errorf("database connection not initialized")
-	}
-	
-	// 自动迁移所有模型
-	err := DB.AutoMigrate(
-		&models.User{},
-		&models.Device{},
-		&models.SensorData{},
-		&models.Project{},
-		&models.Fork{},
-		&models.ForkHistory{},
-		&models.ProjectStar{},
-		&models.PullRequest{},
-		&models.ProjectTemplate{},
-	)
-	
-	if err != nil {
-		return fmt.Errorf("failed to migrate database: %w", err)
-	}
-	
-	// 创建自定义索引
-	if err := createIndexes(); err != nil {
-		return fmt.Errorf("failed to create indexes: %w", err)
-	}
-	
-	log.Println("Database migration completed successfully")
-	return nil
-}
-
-// createIndexes 创建自定义索引
-func createIndexes() error {
-	// 创建复合索引
-	indexes := []string{
-		"CREATE INDEX IF NOT EXISTS idx_sensor_data_device_time ON sensor_data(device_id, timestamp DESC)",
-		"CREATE INDEX IF NOT EXISTS idx_devices_owner_type ON devices(owner_id, type)",
-		"CREATE INDEX IF NOT EXISTS idx_projects_owner_public ON projects(owner_id, public)",
-		"CREATE UNIQUE INDEX IF NOT EXISTS idx_forks_user_project ON forks(user_id, project_id)",
-		"CREATE UNIQUE INDEX IF NOT EXISTS idx_project_stars_user_project ON project_stars(user_id, project_id)",
-		"CREATE INDEX IF NOT EXISTS idx_fork_history_project_time ON fork_history(project_id, created_at DESC)",
-	}
-	
-	for _, index := range indexes {
-		if err := DB.Exec(index).Error; err != nil {
-			return fmt.Errorf("failed to create index: %s, error: %w", index, err)
-		}
-	}
-	
-	return nil
+// IsDuplicateKeyError 判断是否为唯一约束冲突错误
+func IsDuplicateKeyError(err error) bool {
+	return errors.Is(err, gorm.ErrDuplicatedKey)
 }
 
 // Close 关闭数据库连接
@@ -141,13 +104,28 @@ func GetDB() *gorm.DB {
 	return DB
 }
 
+// GetDBWithContext 获取绑定上下文的数据库实例，上下文取消或超时时查询随之中止
+func GetDBWithContext(ctx context.Context) *gorm.DB {
+	return DB.WithContext(ctx)
+}
+
 // Transaction 执行事务
 func Transaction(fn func(*gorm.DB) error) error {
 	return DB.Transaction(fn)
 }
 
+// TransactionWithContext 在请求上下文中执行事务，上下文取消时事务回滚
+func TransactionWithContext(ctx context.Context, fn func(*gorm.DB) error) error {
+	return DB.WithContext(ctx).Transaction(fn)
+}
+
 // Health 健康检查
 func Health() error {
+	return HealthContext(context.Background())
+}
+
+// HealthContext 带超时控制的健康检查
+func HealthContext(ctx context.Context) error {
 	if DB == nil {
 		return fmt.Errorf("database connection not initialized")
 	}
@@ -157,7 +135,7 @@ func Health() error {
 		return err
 	}
 	
-	return sqlDB.Ping()
+	return sqlDB.PingContext(ctx)
 }
 
 // Stats 获取数据库统计信息
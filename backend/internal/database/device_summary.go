@@ -0,0 +1,211 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+	
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"iot-platform-backend/internal/models"
+)
+
+// latestReadingTTL 最新读数缓存有效期
+const latestReadingTTL = 24 * time.Hour
+
+// IncrDeviceDataCount 设备数据点计数加一
+func IncrDeviceDataCount(ctx context.Context, deviceID string) error {
+	if RedisClient == nil {
+		return nil
+	}
+	_, err := NewCache().Incr(ctx, Keys.DeviceDataCount(deviceID))
+	return err
+}
+
+// CacheLatestReading 缓存设备最新一条读数
+func CacheLatestReading(ctx context.Context, data *models.SensorData) error {
+	if RedisClient == nil {
+		return nil
+	}
+	return NewCache().Set(ctx, Keys.DeviceLatest(data.DeviceID), data, latestReadingTTL)
+}
+
+// SeedDeviceSummary 初始化设备计数（用于新建设备）
+func SeedDeviceSummary(ctx context.Context, deviceID string) error {
+	if RedisClient == nil {
+		return nil
+	}
+	cache := NewCache()
+	if err := cache.Delete(ctx, Keys.DeviceLatest(deviceID)); err != nil {
+		return err
+	}
+	return cache.Set(ctx, Keys.DeviceDataCount(deviceID), 0, 0)
+}
+
+// ResetDeviceSummary 清除设备计数和最新读数缓存（用于删除设备或数据清理后）
+func ResetDeviceSummary(ctx context.Context, deviceIDs ...string) error {
+	if RedisClient == nil || len(deviceIDs) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(deviceIDs)*2)
+	for _, deviceID := range deviceIDs {
+		keys = append(keys, Keys.DeviceDataCount(deviceID), Keys.DeviceLatest(deviceID))
+	}
+	return NewCache().Delete(ctx, keys...)
+}
+
+// GetDeviceDataCount 获取设备数据点数量，缓存未命中时从数据库统计并回填
+func GetDeviceDataCount(ctx context.Context, deviceID string) (int64, error) {
+	cache := NewCache()
+	if RedisClient != nil {
+		var count int64
+		err := cache.Get(ctx, Keys.DeviceDataCount(deviceID), &count)
+		if err == nil {
+			return count, nil
+		}
+		if !errors.Is(err, redis.Nil) {
+			return 0, err
+		}
+	}
+	
+	var count int64
+	if err := DB.WithContext(ctx).Model(&models.SensorData{}).Where("device_id = ?", deviceID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	
+	if RedisClient != nil {
+		cache.Set(ctx, Keys.DeviceDataCount(deviceID), count, 0)
+	}
+	return count, nil
+}
+
+// GetLatestReading 获取设备最新读数，缓存未命中时查询数据库并回填，无数据时返回nil
+func GetLatestReading(ctx context.Context, deviceID string) (*models.SensorData, error) {
+	cache := NewCache()
+	if RedisClient != nil {
+		var latest models.SensorData
+		err := cache.Get(ctx, Keys.DeviceLatest(deviceID), &latest)
+		if err == nil {
+			return &latest, nil
+		}
+		if !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+	}
+	
+	var latest models.SensorData
+	err := DB.WithContext(ctx).
+		Where("device_id = ?", deviceID).
+		Order("timestamp DESC").
+		Limit(1).
+		Take(&latest).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	
+	if RedisClient != nil {
+		cache.Set(ctx, Keys.DeviceLatest(deviceID), &latest, latestReadingTTL)
+	}
+	return &latest, nil
+}
+
+// ReconcileDeviceDataCounts 使用数据库实际数量校正所有设备的计数缓存，返回被修正的设备数
+func ReconcileDeviceDataCounts(ctx context.Context) (int, error) {
+	if DB == nil || RedisClient == nil {
+		return 0, nil
+	}
+	
+	var rows []struct {
+		DeviceID string
+		Count    int64
+	}
+	if err := DB.WithContext(ctx).
+		Model(&models.Device{}).
+		Select("devices.device_id, COUNT(sensor_data.id) AS count").
+		Joins("LEFT JOIN sensor_data ON sensor_data.device_id = devices.device_id").
+		Group("devices.device_id").
+		Scan(&rows).Error; err != nil {
+		return 0, err
+	}
+	
+	cache := NewCache()
+	corrected := 0
+	for _, row := range rows {
+		key := Keys.DeviceDataCount(row.DeviceID)
+		
+		var cached int64
+		if err := cache.Get(ctx, key, &cached); err == nil && cached == row.Count {
+			continue
+		}
+		
+		if err := cache.Set(ctx, key, row.Count, 0); err != nil {
+			return corrected, err
+		}
+		corrected++
+	}
+	
+	return corrected, nil
+}
+
+// TouchDeviceLastSeen 更新设备最后通信时间并置为在线，只写last_seen和status两列
+// interval大于0时每台设备在interval内只持久化一次last_seen（由Redis记录），高频上报的设备不再每条读数都写设备表；
+// 未在线的设备不受节流限制，总是立即置为在线
+func TouchDeviceLastSeen(ctx context.Context, db *gorm.DB, deviceIDs []string, interval time.Duration) error {
+	if len(deviceIDs) == 0 {
+		return nil
+	}
+	
+	due := make([]string, 0, len(deviceIDs))
+	throttled := make([]string, 0)
+	for _, deviceID := range deviceIDs {
+		if claimLastSeenWrite(ctx, deviceID, interval) {
+			due = append(due, deviceID)
+		} else {
+			throttled = append(throttled, deviceID)
+		}
+	}
+	
+	columns := map[string]interface{}{"last_seen": time.Now(), "status": "online"}
+	if len(due) > 0 {
+		if err := db.Model(&models.Device{}).Where("device_id IN ?", due).UpdateColumns(columns).Error; err != nil {
+			// 写入失败时释放节流标记，下一条读数重试
+			releaseLastSeenWrite(ctx, due)
+			return err
+		}
+	}
+	if len(throttled) > 0 {
+		if err := db.Model(&models.Device{}).
+			Where("device_id IN ? AND status <> ?", throttled, "online").
+			UpdateColumns(columns).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// claimLastSeenWrite 占用设备本周期的last_seen写入机会，Redis不可用时不节流
+func claimLastSeenWrite(ctx context.Context, deviceID string, interval time.Duration) bool {
+	if interval <= 0 || RedisClient == nil {
+		return true
+	}
+	claimed, err := NewCache().SetNX(ctx, Keys.DeviceLastSeen(deviceID), time.Now().Unix(), interval)
+	if err != nil {
+		return true
+	}
+	return claimed
+}
+
+// releaseLastSeenWrite 清除设备的last_seen节流标记
+func releaseLastSeenWrite(ctx context.Context, deviceIDs []string) {
+	if RedisClient == nil {
+		return
+	}
+	keys := make([]string, 0, len(deviceIDs))
+	for _, deviceID := range deviceIDs {
+		keys = append(keys, Keys.DeviceLastSeen(deviceID))
+	}
+	NewCache().Delete(ctx, keys...)
+}
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestClaimLastSeenWrite(t *testing.T) {
+	mr := useTestRedis(t)
+	ctx := context.Background()
+
+	if !claimLastSeenWrite(ctx, "d1", time.Minute) {
+		t.Fatal("first write in the interval was throttled")
+	}
+	if claimLastSeenWrite(ctx, "d1", time.Minute) {
+		t.Error("second write in the interval was not throttled")
+	}
+	if !claimLastSeenWrite(ctx, "d2", time.Minute) {
+		t.Error("another device was throttled")
+	}
+
+	// 节流周期结束后再次写入
+	mr.FastForward(time.Minute)
+	if !claimLastSeenWrite(ctx, "d1", time.Minute) {
+		t.Error("write after the interval was throttled")
+	}
+
+	// 写入失败时释放标记，下一条读数重试
+	releaseLastSeenWrite(ctx, []string{"d1"})
+	if !claimLastSeenWrite(ctx, "d1", time.Minute) {
+		t.Error("write after release was throttled")
+	}
+
+	// interval为0时不节流
+	for i := 0; i < 2; i++ {
+		if !claimLastSeenWrite(ctx, "d3", 0) {
+			t.Errorf("write %d without an interval was throttled", i)
+		}
+	}
+}
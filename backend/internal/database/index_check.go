@@ -0,0 +1,132 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// 启动时的索引检查模式
+const (
+	IndexCheckOff  = "off"  // 不检查
+	IndexCheckWarn = "warn" // 缺少索引时输出警告
+	IndexCheckFail = "fail" // 缺少索引时启动失败
+)
+
+// forkUniqueIndex createForkUniqueIndex创建的唯一索引，存在重复Fork时会被跳过
+var forkUniqueIndex = ExpectedIndex{Table: "projects", Name: "idx_projects_owner_parent", Unique: true}
+
+// customIndexPattern 从customIndexes的语句中解析索引名和表名
+var customIndexPattern = regexp.MustCompile(`(?i)^CREATE\s+(UNIQUE\s+)?INDEX\s+IF\s+NOT\s+EXISTS\s+(\w+)\s+ON\s+(\w+)`)
+
+// ExpectedIndex 迁移后应当存在的索引
+type ExpectedIndex struct {
+	Table  string `json:"table"`
+	Name   string `json:"name"`
+	Unique bool   `json:"unique"`
+	Using  string `json:"using,omitempty"` // 索引访问方法（btree、brin等），为空时不检查
+}
+
+// ExpectedIndexes 模型gorm标签、customIndexes、时间索引和createForkUniqueIndex定义的所有索引，按表名、索引名排序
+func ExpectedIndexes(db *gorm.DB) ([]ExpectedIndex, error) {
+	cache := &sync.Map{}
+	var expected []ExpectedIndex
+	for _, model := range migrationModels() {
+		parsed, err := schema.Parse(model, cache, db.NamingStrategy)
+		if err != nil {
+			return nil, err
+		}
+		for _, index := range parsed.ParseIndexes() {
+			expected = append(expected, ExpectedIndex{
+				Table:  parsed.Table,
+				Name:   index.Name,
+				Unique: index.Class == "UNIQUE",
+			})
+		}
+	}
+	for _, statement := range customIndexes {
+		match := customIndexPattern.FindStringSubmatch(statement)
+		if match == nil {
+			return nil, fmt.Errorf("unrecognized custom index statement: %s", statement)
+		}
+		expected = append(expected, ExpectedIndex{Table: match[3], Name: match[2], Unique: match[1] != ""})
+	}
+	expected = append(expected, forkUniqueIndex, sensorTimeIndexFor(sensorTimeIndex))
+	
+	sort.Slice(expected, func(i, j int) bool {
+		if expected[i].Table != expected[j].Table {
+			return expected[i].Table < expected[j].Table
+		}
+		return expected[i].Name < expected[j].Name
+	})
+	return expected, nil
+}
+
+// VerifyIndexes 对照pg_indexes检查预期的索引，返回缺失、唯一性或访问方法不符的问题
+func VerifyIndexes(db *gorm.DB) ([]string, error) {
+	expected, err := ExpectedIndexes(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect expected indexes: %w", err)
+	}
+	
+	var rows []struct {
+		Tablename string
+		Indexname string
+		Indexdef  string
+	}
+	if err := db.Raw("SELECT tablename, indexname, indexdef FROM pg_indexes WHERE schemaname = current_schema()").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to query pg_indexes: %w", err)
+	}
+	existing := make(map[string]string, len(rows))
+	for _, row := range rows {
+		existing[row.Tablename+"."+row.Indexname] = row.Indexdef
+	}
+	
+	var problems []string
+	for _, index := range expected {
+		definition, ok := existing[index.Table+"."+index.Name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("index %s on %s is missing", index.Name, index.Table))
+			continue
+		}
+		if index.Unique && !strings.HasPrefix(strings.ToUpper(definition), "CREATE UNIQUE INDEX") {
+			problems = append(problems, fmt.Sprintf("index %s on %s is not unique", index.Name, index.Table))
+		}
+		if index.Using != "" && !strings.Contains(strings.ToLower(definition), " using "+index.Using+" ") {
+			problems = append(problems, fmt.Sprintf("index %s on %s is not a %s index", index.Name, index.Table, index.Using))
+		}
+	}
+	return problems, nil
+}
+
+// CheckIndexes 按检查模式验证索引，fail模式下存在问题时返回错误
+func CheckIndexes(mode string) error {
+	if mode == IndexCheckOff {
+		return nil
+	}
+	if DB == nil {
+		return fmt.Errorf("database connection not initialized")
+	}
+	
+	problems, err := VerifyIndexes(DB)
+	if err != nil {
+		return err
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	if mode == IndexCheckFail {
+		return fmt.Errorf("index verification failed: %s", strings.Join(problems, "; "))
+	}
+	for _, problem := range problems {
+		log.Printf("Warning: %s", problem)
+	}
+	return nil
+}
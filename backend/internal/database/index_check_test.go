@@ -0,0 +1,44 @@
+package database
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+func TestExpectedIndexes(t *testing.T) {
+	db := &gorm.DB{Config: &gorm.Config{NamingStrategy: schema.NamingStrategy{}}}
+	expected, err := ExpectedIndexes(db)
+	if err != nil {
+		t.Fatalf("ExpectedIndexes: %v", err)
+	}
+
+	names := make(map[string]ExpectedIndex, len(expected))
+	for _, index := range expected {
+		names[index.Name] = index
+	}
+
+	tests := []struct {
+		name       string
+		wantExists bool
+		wantUnique bool
+	}{
+		{"idx_sensor_data_device_time_id", true, false},
+		{"idx_sensor_data_timestamp", true, false},
+		{"idx_users_ghost", true, true},
+		{"idx_projects_owner_parent", true, true},
+		// 被idx_sensor_data_device_time_id覆盖的旧索引
+		{"idx_sensor_data_device_time", false, false},
+	}
+	for _, tt := range tests {
+		index, ok := names[tt.name]
+		if ok != tt.wantExists {
+			t.Errorf("index %s expected = %v, want %v", tt.name, ok, tt.wantExists)
+			continue
+		}
+		if ok && index.Unique != tt.wantUnique {
+			t.Errorf("index %s unique = %v, want %v", tt.name, index.Unique, tt.wantUnique)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+	
+	"github.com/redis/go-redis/v9"
+)
+
+// unlockScript 仅当锁仍由自己持有时才删除，避免误删过期后被他人获取的锁
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// TryLock 尝试获取分布式锁，获取成功时返回释放函数
+// 锁在ttl后自动过期，防止持有者崩溃后永久占用；未连接Redis时视为单实例直接成功
+func TryLock(ctx context.Context, name string, ttl time.Duration) (func(), bool, error) {
+	if RedisClient == nil {
+		return func() {}, true, nil
+	}
+	
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, false, err
+	}
+	token := hex.EncodeToString(raw)
+	key := Keys.Lock(name)
+	
+	acquired, err := RedisClient.SetNX(ctx, key, token, ttl).Result()
+	if err != nil || !acquired {
+		return nil, false, err
+	}
+	
+	unlock := func() {
+		// 使用独立的上下文，保证任务被取消时仍能释放锁
+		releaseCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		unlockScript.Run(releaseCtx, RedisClient, []string{key}, token)
+	}
+	return unlock, true, nil
+}
@@ -0,0 +1,513 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+	"iot-platform-backend/internal/models"
+)
+
+// 启动时的数据库迁移模式
+const (
+	MigrateAuto   = "auto"    // 模型结构与上次迁移一致时跳过
+	MigrateAlways = "always"  // 每次启动都执行迁移
+	MigrateSkip   = "skip"    // 不执行迁移（只读副本）
+	MigrateDryRun = "dry-run" // 在回滚的事务中执行迁移，只输出将要执行的SQL
+)
+
+// sensor_data.timestamp的索引类型
+// btree适合按时间点查找和小范围查询；BRIN只记录每个数据块的时间范围，体积通常只有btree的千分之一，
+// 适合按时间追加写入的数据做大范围扫描（保留期清理、全局按时间统计），但单条或小范围查询需要扫描整个块范围。
+// 单设备按时间的查询由(device_id, timestamp)复合索引承担，不受该选项影响
+const (
+	SensorTimeIndexBtree = "btree"
+	SensorTimeIndexBRIN  = "brin"
+)
+
+// sensorTimeIndex 当前使用的时间索引类型，由Connect按配置设置
+var sensorTimeIndex = SensorTimeIndexBtree
+
+// schemaRevision 手写迁移SQL（customIndexes以外的索引、约束调整等）的版本
+// 修改createIndexes中的非索引语句时需要递增，否则auto模式下不会重新执行
+const schemaRevision = 7
+
+// schemaFingerprintKey 结构指纹在schema_fingerprints表中的键
+const schemaFingerprintKey = "models"
+
+// errDryRunRollback 试运行结束后用于回滚事务
+var errDryRunRollback = errors.New("migration dry run")
+
+// migrationModels 需要自动迁移的模型
+func migrationModels() []interface{} {
+	return []interface{}{
+		&models.User{},
+		&models.Device{},
+		&models.SensorData{},
+		&models.Project{},
+		&models.Fork{},
+		&models.ForkHistory{},
+		&models.ProjectStar{},
+		&models.PullRequest{},
+		&models.ProjectTemplate{},
+		&models.ProjectCollaborator{},
+		&models.DeviceConfigTemplate{},
+		&models.DeviceConfigHistory{},
+		&models.AuditLog{},
+		&models.Announcement{},
+		&models.Notification{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
+		&models.Invite{},
+		&models.DeviceGroup{},
+		&models.UserQuota{},
+		&models.DeviceCommand{},
+		&models.Alert{},
+	}
+}
+
+// customIndexes AutoMigrate无法表达的自定义索引（NULLS LAST排序、部分索引）
+// 其他索引在模型的gorm标签中定义，由AutoMigrate创建
+var customIndexes = []string{
+	"CREATE INDEX IF NOT EXISTS idx_sensor_data_device_time_id ON sensor_data(device_id, timestamp DESC NULLS LAST, id DESC)",
+	"CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending'",
+	"CREATE UNIQUE INDEX IF NOT EXISTS idx_users_ghost ON users(ghost) WHERE ghost",
+}
+
+// Migrate 自动迁移数据库表（每次启动都执行）
+func Migrate() error {
+	return MigrateWithMode(MigrateAlways)
+}
+
+// MigrateWithMode 按迁移模式迁移数据库表
+func MigrateWithMode(mode string) error {
+	if DB == nil {
+		return fmt.Errorf("database connection not initialized")
+	}
+	
+	switch mode {
+	case MigrateSkip:
+		log.Println("Database migration skipped")
+		return nil
+	case MigrateDryRun:
+		statements, err := DryRunMigrate()
+		if err != nil {
+			return err
+		}
+		if len(statements) == 0 {
+			log.Println("Database migration dry run: schema is up to date")
+		}
+		for _, statement := range statements {
+			log.Printf("Database migration dry run: %s", statement)
+		}
+		return nil
+	}
+	
+	fingerprint, err := SchemaFingerprint(DB)
+	if err != nil {
+		return fmt.Errorf("failed to compute schema fingerprint: %w", err)
+	}
+	if mode == MigrateAuto {
+		// 指纹表不存在（首次启动）时读取失败，照常迁移
+		if applied, err := appliedSchemaFingerprint(DB); err == nil && applied == fingerprint {
+			log.Println("Database schema unchanged, migration skipped")
+			return nil
+		}
+	}
+	
+	complete, err := runMigrations(DB)
+	if err != nil {
+		return err
+	}
+	// 有步骤被跳过时不记录指纹，下次启动会重新尝试
+	if !complete {
+		log.Println("Database migration completed with skipped steps")
+		return nil
+	}
+	if err := saveSchemaFingerprint(DB, fingerprint); err != nil {
+		return fmt.Errorf("failed to save schema fingerprint: %w", err)
+	}
+	
+	log.Println("Database migration completed successfully")
+	return nil
+}
+
+// DryRunMigrate 在事务中执行迁移并记录所有会修改结构的SQL，最后回滚事务
+// PostgreSQL的DDL支持事务，因此不会留下任何修改；但执行期间会持有相关表的锁，应在低峰期使用
+func DryRunMigrate() ([]string, error) {
+	if DB == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+	
+	recorder := &sqlRecorder{Interface: DB.Logger}
+	db := DB.Session(&gorm.Session{Logger: recorder})
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if _, err := runMigrations(tx); err != nil {
+			return err
+		}
+		return errDryRunRollback
+	})
+	if err != nil && !errors.Is(err, errDryRunRollback) {
+		return nil, err
+	}
+	return recorder.statements(), nil
+}
+
+// runMigrations 执行AutoMigrate和自定义索引，返回是否所有步骤都已完成
+func runMigrations(db *gorm.DB) (bool, error) {
+	legacyMigrated, err := migrateLegacyNotifications(db)
+	if err != nil {
+		return false, err
+	}
+	if err := db.AutoMigrate(migrationModels()...); err != nil {
+		return false, fmt.Errorf("failed to migrate database: %w", err)
+	}
+	complete, err := createIndexes(db)
+	if err != nil {
+		return false, fmt.Errorf("failed to create indexes: %w", err)
+	}
+	if legacyMigrated {
+		if err := convertLegacyNotificationDeliveries(db); err != nil {
+			return false, err
+		}
+	}
+	return complete && legacyMigrated, nil
+}
+
+// migrateLegacyNotifications 迁移早期版本的通知表结构：当时广播公告存放在notifications表（含title等列），
+// 待离线补发的记录存放在user_notifications表；现在公告使用announcements表，notifications表存放用户站内通知。
+// 将旧表改名为announcements，并删除之后的迁移在旧表上追加的站内通知列。
+// announcements表已有数据时无法自动合并，跳过并给出警告，返回是否已完成
+func migrateLegacyNotifications(db *gorm.DB) (bool, error) {
+	var legacy int64
+	if err := db.Raw(`SELECT COUNT(*) FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'notifications' AND column_name = 'title'`).
+		Scan(&legacy).Error; err != nil {
+		return false, fmt.Errorf("failed to check legacy notifications table: %w", err)
+	}
+	if legacy == 0 {
+		return true, nil
+	}
+	
+	if db.Migrator().HasTable("announcements") {
+		var announcements int64
+		if err := db.Table("announcements").Count(&announcements).Error; err != nil {
+			return false, fmt.Errorf("failed to check announcements table: %w", err)
+		}
+		if announcements > 0 {
+			log.Printf("Warning: both the legacy notifications table and announcements contain announcements, skip migrating notifications; merge them manually and restart")
+			return false, nil
+		}
+	}
+	
+	statements := []string{
+		"DROP TABLE IF EXISTS announcements",
+		"ALTER TABLE notifications RENAME TO announcements",
+		"ALTER SEQUENCE IF EXISTS notifications_id_seq RENAME TO announcements_id_seq",
+		"ALTER INDEX IF EXISTS notifications_pkey RENAME TO announcements_pkey",
+		"ALTER INDEX IF EXISTS idx_notifications_created_by RENAME TO idx_announcements_created_by",
+		"DROP INDEX IF EXISTS idx_notifications_created_at",
+		`ALTER TABLE announcements DROP COLUMN IF EXISTS user_id, DROP COLUMN IF EXISTS type,
+			DROP COLUMN IF EXISTS payload, DROP COLUMN IF EXISTS read_at, DROP COLUMN IF EXISTS delivered_at`,
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return false, fmt.Errorf("failed to migrate legacy notifications: %s, error: %w", statement, err)
+		}
+	}
+	log.Println("Migrated legacy notifications table to announcements")
+	return true, nil
+}
+
+// convertLegacyNotificationDeliveries 将旧版user_notifications中的公告投递记录转为用户站内通知后删除该表，
+// 未送达的记录保留delivered_at为空，由用户下次连接时补发
+func convertLegacyNotificationDeliveries(db *gorm.DB) error {
+	if !db.Migrator().HasTable("user_notifications") {
+		return nil
+	}
+	
+	statements := []string{
+		`INSERT INTO notifications (user_id, type, payload, delivered_at, created_at)
+		SELECT un.user_id, 'announcement',
+			jsonb_build_object('announcement_id', a.id, 'title', a.title, 'message', a.message, 'level', a.level),
+			un.delivered_at, un.created_at
+		FROM user_notifications un JOIN announcements a ON a.id = un.notification_id`,
+		"DROP TABLE user_notifications",
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to convert legacy notification deliveries: %w", err)
+		}
+	}
+	return nil
+}
+
+// createIndexes 创建自定义索引，返回是否所有索引都已创建
+func createIndexes(db *gorm.DB) (bool, error) {
+	for _, index := range customIndexes {
+		if err := db.Exec(index).Error; err != nil {
+			return false, fmt.Errorf("failed to create index: %s, error: %w", index, err)
+		}
+	}
+	
+	if err := createSensorTimeIndex(db); err != nil {
+		return false, err
+	}
+	
+	// 早期版本的(device_id, timestamp DESC)索引是idx_sensor_data_device_time_id的前缀，写入时白白多维护一份
+	if err := db.Exec("DROP INDEX IF EXISTS idx_sensor_data_device_time").Error; err != nil {
+		return false, fmt.Errorf("failed to drop idx_sensor_data_device_time: %w", err)
+	}
+	
+	constrained, err := createDeviceTypeCheck(db)
+	if err != nil {
+		return false, err
+	}
+	
+	created, err := createForkUniqueIndex(db)
+	if err != nil {
+		return false, err
+	}
+	
+	if err := markGhostUser(db); err != nil {
+		return false, err
+	}
+	
+	// 早期版本预留的Fork同步时间列，从未写入，以Fork的创建时间为基准
+	if err := db.Exec("ALTER TABLE projects DROP COLUMN IF EXISTS synced_at").Error; err != nil {
+		return false, fmt.Errorf("failed to drop projects.synced_at: %w", err)
+	}
+	
+	// 早期版本的max_uses列默认值为1，插入0（不限次数）时会被当作零值替换为默认值
+	if err := db.Exec("ALTER TABLE invites ALTER COLUMN max_uses DROP DEFAULT").Error; err != nil {
+		return false, fmt.Errorf("failed to drop invites.max_uses default: %w", err)
+	}
+	return created && constrained, deferSensorDataForeignKeys(db)
+}
+
+// deviceTypeCheckName devices.type检查约束的名称
+const deviceTypeCheckName = "chk_devices_type"
+
+// deviceTypeCheckExpr 合法设备类型的检查条件，由models.DeviceTypeNames生成
+func deviceTypeCheckExpr() string {
+	types := models.ValidDeviceTypes()
+	values := make([]string, len(types))
+	for i, deviceType := range types {
+		values[i] = strconv.Itoa(int(deviceType))
+	}
+	return "type IN (" + strings.Join(values, ", ") + ")"
+}
+
+// createDeviceTypeCheck 按当前合法的设备类型重建devices.type检查约束，返回是否已创建
+// 历史数据中存在未知类型时跳过创建并给出警告，避免阻塞启动
+func createDeviceTypeCheck(db *gorm.DB) (bool, error) {
+	expr := deviceTypeCheckExpr()
+	var invalid int64
+	if err := db.Raw("SELECT COUNT(*) FROM devices WHERE NOT (" + expr + ")").Scan(&invalid).Error; err != nil {
+		return false, fmt.Errorf("failed to check device types: %w", err)
+	}
+	if invalid > 0 {
+		log.Printf("Warning: %d device(s) have an unknown type, skip creating %s; fix them and restart", invalid, deviceTypeCheckName)
+		return false, nil
+	}
+	
+	statements := []string{
+		"ALTER TABLE devices DROP CONSTRAINT IF EXISTS " + deviceTypeCheckName,
+		"ALTER TABLE devices ADD CONSTRAINT " + deviceTypeCheckName + " CHECK (" + expr + ")",
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return false, fmt.Errorf("failed to create device type check: %s, error: %w", statement, err)
+		}
+	}
+	return true, nil
+}
+
+// sensorTimeIndexFor 时间索引类型对应的索引
+func sensorTimeIndexFor(strategy string) ExpectedIndex {
+	if strategy == SensorTimeIndexBRIN {
+		return ExpectedIndex{Table: "sensor_data", Name: "idx_sensor_data_timestamp_brin", Using: "brin"}
+	}
+	return ExpectedIndex{Table: "sensor_data", Name: "idx_sensor_data_timestamp", Using: "btree"}
+}
+
+// createSensorTimeIndex 按配置的类型创建sensor_data.timestamp索引，并删除另一种类型的索引
+// btree索引沿用AutoMigrate默认的索引名，已有部署切换前后不会重复创建
+func createSensorTimeIndex(db *gorm.DB) error {
+	index := sensorTimeIndexFor(sensorTimeIndex)
+	other := sensorTimeIndexFor(SensorTimeIndexBtree)
+	if sensorTimeIndex != SensorTimeIndexBRIN {
+		other = sensorTimeIndexFor(SensorTimeIndexBRIN)
+	}
+	
+	statements := []string{
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON sensor_data USING %s (timestamp)", index.Name, strings.ToUpper(index.Using)),
+		fmt.Sprintf("DROP INDEX IF EXISTS %s", other.Name),
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to create sensor time index: %s, error: %w", statement, err)
+		}
+	}
+	return nil
+}
+
+// deferSensorDataForeignKeys 将sensor_data指向devices的外键改为可延迟检查
+// 设备重新分配device_id时需要在同一事务内先后更新两张表
+func deferSensorDataForeignKeys(db *gorm.DB) error {
+	stmt := `DO $$
+	DECLARE
+		con RECORD;
+	BEGIN
+		FOR con IN
+			SELECT conname FROM pg_constraint
+			WHERE contype = 'f'
+				AND conrelid = 'sensor_data'::regclass
+				AND confrelid = 'devices'::regclass
+				AND NOT condeferrable
+		LOOP
+			EXECUTE format('ALTER TABLE sensor_data ALTER CONSTRAINT %I DEFERRABLE INITIALLY IMMEDIATE', con.conname);
+		END LOOP;
+	END $$`
+	if err := db.Exec(stmt).Error; err != nil {
+		return fmt.Errorf("failed to make sensor_data foreign keys deferrable: %w", err)
+	}
+	return nil
+}
+
+// markGhostUser 为ghost标记引入前创建的占位账号补上标记
+// 只匹配系统创建时的固定邮箱、手机号且已停用的账号，不会误标注册了同名用户名的真实账号
+func markGhostUser(db *gorm.DB) error {
+	err := db.Exec(`UPDATE users SET ghost = true
+		WHERE NOT ghost AND username = ? AND email = ? AND phone = ? AND NOT active`,
+		models.GhostUsername, models.GhostUsername+"@users.invalid", models.GhostUsername).Error
+	if err != nil {
+		return fmt.Errorf("failed to mark ghost user: %w", err)
+	}
+	return nil
+}
+
+// createForkUniqueIndex 创建“每个用户对同一项目只能Fork一次”的唯一索引，返回是否已创建
+// 历史数据中存在重复Fork时跳过创建并给出警告，避免阻塞启动
+func createForkUniqueIndex(db *gorm.DB) (bool, error) {
+	var duplicates int64
+	if err := db.Raw(`SELECT COUNT(*) FROM (
+		SELECT owner_id, parent_id FROM projects
+		WHERE parent_id IS NOT NULL
+		GROUP BY owner_id, parent_id
+		HAVING COUNT(*) > 1
+	) AS duplicated`).Scan(&duplicates).Error; err != nil {
+		return false, fmt.Errorf("failed to check duplicate forks: %w", err)
+	}
+	
+	if duplicates > 0 {
+		log.Printf("Warning: %d duplicate fork group(s) found, skip creating idx_projects_owner_parent; resolve them and restart", duplicates)
+		return false, nil
+	}
+	
+	index := "CREATE UNIQUE INDEX IF NOT EXISTS idx_projects_owner_parent ON projects(owner_id, parent_id) WHERE parent_id IS NOT NULL"
+	if err := db.Exec(index).Error; err != nil {
+		return false, fmt.Errorf("failed to create index: %s, error: %w", index, err)
+	}
+	return true, nil
+}
+
+// SchemaFingerprint 根据模型的表名、字段定义（列名、类型、gorm标签）、自定义索引、时间索引类型、合法设备类型和schemaRevision计算结构指纹
+// 任何会影响迁移结果的模型修改都会改变指纹
+func SchemaFingerprint(db *gorm.DB) (string, error) {
+	cache := &sync.Map{}
+	hash := sha256.New()
+	fmt.Fprintf(hash, "revision=%d\n", schemaRevision)
+	fmt.Fprintf(hash, "sensor_time_index=%s\n", sensorTimeIndex)
+	
+	for _, model := range migrationModels() {
+		parsed, err := schema.Parse(model, cache, db.NamingStrategy)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(hash, "table=%s\n", parsed.Table)
+		for _, field := range parsed.Fields {
+			if field.DBName == "" {
+				continue
+			}
+			// 使用原始标签而不是TagSettings：同一字段的多个index标签在TagSettings中只保留最后一个
+			fmt.Fprintf(hash, "field=%s type=%s go=%s tags=%s\n", field.DBName, field.DataType, field.FieldType, field.Tag.Get("gorm"))
+		}
+	}
+	for _, index := range customIndexes {
+		fmt.Fprintf(hash, "index=%s\n", index)
+	}
+	fmt.Fprintf(hash, "device_type_check=%s\n", deviceTypeCheckExpr())
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// appliedSchemaFingerprint 读取上次成功迁移时的结构指纹
+func appliedSchemaFingerprint(db *gorm.DB) (string, error) {
+	var fingerprint string
+	err := db.Raw("SELECT fingerprint FROM schema_fingerprints WHERE key = ?", schemaFingerprintKey).
+		Row().Scan(&fingerprint)
+	return fingerprint, err
+}
+
+// saveSchemaFingerprint 记录本次迁移后的结构指纹
+func saveSchemaFingerprint(db *gorm.DB, fingerprint string) error {
+	if err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_fingerprints (
+		key TEXT PRIMARY KEY,
+		fingerprint TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL
+	)`).Error; err != nil {
+		return err
+	}
+	return db.Exec(`INSERT INTO schema_fingerprints (key, fingerprint, applied_at) VALUES (?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET fingerprint = EXCLUDED.fingerprint, applied_at = EXCLUDED.applied_at`,
+		schemaFingerprintKey, fingerprint, time.Now().UTC()).Error
+}
+
+// sqlRecorder 记录执行过的SQL，忽略AutoMigrate检查表结构时的查询
+type sqlRecorder struct {
+	logger.Interface
+	mu    sync.Mutex
+	stmts []string
+}
+
+// LogMode 保持记录器本身，只调整被包装日志的级别
+func (r *sqlRecorder) LogMode(level logger.LogLevel) logger.Interface {
+	r.Interface = r.Interface.LogMode(level)
+	return r
+}
+
+// Trace 记录每条执行的SQL
+func (r *sqlRecorder) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	sql, _ := fc()
+	if isSchemaChange(sql) {
+		r.mu.Lock()
+		r.stmts = append(r.stmts, sql)
+		r.mu.Unlock()
+	}
+	r.Interface.Trace(ctx, begin, fc, err)
+}
+
+// statements 已记录的SQL
+func (r *sqlRecorder) statements() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	
+	return append([]string(nil), r.stmts...)
+}
+
+// isSchemaChange 是否为会修改结构的语句（非SELECT查询）
+func isSchemaChange(sql string) bool {
+	statement := strings.ToUpper(strings.TrimSpace(sql))
+	return statement != "" && !strings.HasPrefix(statement, "SELECT") && !strings.HasPrefix(statement, "SAVEPOINT") &&
+		!strings.HasPrefix(statement, "RELEASE SAVEPOINT") && !strings.HasPrefix(statement, "ROLLBACK TO SAVEPOINT")
+}
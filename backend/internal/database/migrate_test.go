@@ -0,0 +1,260 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"iot-platform-backend/internal/models"
+)
+
+// openTestSchema 在TEST_DATABASE_DSN指定的库中重建名为schema的空schema并设为database.DB，未配置时跳过
+// （database包的测试不能使用testutil，否则会形成循环导入）
+func openTestSchema(t *testing.T, schema string) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set, skipping test that needs PostgreSQL")
+	}
+
+	open := func(dsn string) *gorm.DB {
+		db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+			Logger:         logger.Default.LogMode(logger.Silent),
+			TranslateError: true,
+		})
+		if err != nil {
+			t.Fatalf("failed to connect to test database: %v", err)
+		}
+		t.Cleanup(func() {
+			if sqlDB, err := db.DB(); err == nil {
+				sqlDB.Close()
+			}
+		})
+		return db
+	}
+
+	admin := open(dsn)
+	for _, statement := range []string{
+		fmt.Sprintf("DROP SCHEMA IF EXISTS %q CASCADE", schema),
+		fmt.Sprintf("CREATE SCHEMA %q", schema),
+	} {
+		if err := admin.Exec(statement).Error; err != nil {
+			t.Fatalf("failed to prepare schema %s: %v", schema, err)
+		}
+	}
+
+	if strings.Contains(dsn, "://") {
+		if strings.Contains(dsn, "?") {
+			dsn += "&search_path=" + schema
+		} else {
+			dsn += "?search_path=" + schema
+		}
+	} else {
+		dsn += " search_path=" + schema
+	}
+	db := open(dsn)
+
+	previous := DB
+	DB = db
+	t.Cleanup(func() { DB = previous })
+	return db
+}
+
+func TestMigrateLegacyNotifications(t *testing.T) {
+	db := openTestSchema(t, "test_database_legacy_notifications")
+
+	// 早期版本的结构：公告存放在notifications表，待补发记录存放在user_notifications表
+	legacy := []string{
+		`CREATE TABLE notifications (
+			id bigserial PRIMARY KEY,
+			title text NOT NULL,
+			message text NOT NULL,
+			level text DEFAULT 'info',
+			target text NOT NULL,
+			target_role text,
+			target_ids bigint[],
+			created_by bigint,
+			created_at timestamptz
+		)`,
+		"CREATE INDEX idx_notifications_created_by ON notifications(created_by)",
+		`CREATE TABLE user_notifications (
+			id bigserial PRIMARY KEY,
+			notification_id bigint NOT NULL,
+			user_id bigint NOT NULL,
+			delivered_at timestamptz,
+			created_at timestamptz
+		)`,
+		`INSERT INTO notifications (title, message, target, created_by, created_at)
+			VALUES ('Maintenance', 'Tonight 22:00', 'all', 1, now())`,
+		"INSERT INTO user_notifications (notification_id, user_id, created_at) VALUES (1, 7, now())",
+	}
+	for _, statement := range legacy {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatalf("failed to create legacy schema: %v", err)
+		}
+	}
+
+	if _, err := runMigrations(db); err != nil {
+		t.Fatalf("runMigrations: %v", err)
+	}
+
+	var title string
+	if err := db.Raw("SELECT title FROM announcements WHERE id = 1").Scan(&title).Error; err != nil || title != "Maintenance" {
+		t.Fatalf("announcement not migrated: title=%q err=%v", title, err)
+	}
+	if db.Migrator().HasTable("user_notifications") {
+		t.Error("user_notifications table was not dropped")
+	}
+
+	var converted struct {
+		UserID uint
+		Type   string
+		Title  string
+	}
+	if err := db.Raw(`SELECT user_id, type, payload->>'title' AS title FROM notifications`).Scan(&converted).Error; err != nil {
+		t.Fatalf("query notifications: %v", err)
+	}
+	if converted.UserID != 7 || converted.Type != "announcement" || converted.Title != "Maintenance" {
+		t.Errorf("pending delivery converted to %+v", converted)
+	}
+
+	// 新的站内通知和公告都能正常写入
+	if err := db.Exec("INSERT INTO notifications (user_id, type, created_at) VALUES (8, 'device_created', now())").Error; err != nil {
+		t.Errorf("insert notification: %v", err)
+	}
+	if err := db.Exec("INSERT INTO announcements (title, message, target, created_at) VALUES ('a', 'b', 'all', now())").Error; err != nil {
+		t.Errorf("insert announcement: %v", err)
+	}
+
+	// 再次迁移不做任何修改
+	if _, err := runMigrations(db); err != nil {
+		t.Fatalf("second runMigrations: %v", err)
+	}
+}
+
+func TestCreateIndexesDropsRedundantSensorIndex(t *testing.T) {
+	db := openTestSchema(t, "test_database_sensor_indexes")
+	if _, err := runMigrations(db); err != nil {
+		t.Fatalf("runMigrations: %v", err)
+	}
+	if err := db.Exec("CREATE INDEX idx_sensor_data_device_time ON sensor_data(device_id, timestamp DESC)").Error; err != nil {
+		t.Fatalf("create legacy index: %v", err)
+	}
+
+	if _, err := createIndexes(db); err != nil {
+		t.Fatalf("createIndexes: %v", err)
+	}
+	if db.Migrator().HasIndex("sensor_data", "idx_sensor_data_device_time") {
+		t.Error("idx_sensor_data_device_time was not dropped")
+	}
+	if !db.Migrator().HasIndex("sensor_data", "idx_sensor_data_device_time_id") {
+		t.Error("idx_sensor_data_device_time_id is missing")
+	}
+}
+
+func TestCreateForkUniqueIndex(t *testing.T) {
+	db := openTestSchema(t, "test_database_fork_index")
+	if _, err := runMigrations(db); err != nil {
+		t.Fatalf("runMigrations: %v", err)
+	}
+	if !db.Migrator().HasIndex("projects", "idx_projects_owner_parent") {
+		t.Fatal("idx_projects_owner_parent was not created by the migration")
+	}
+
+	// 已有重复Fork时跳过建索引，不阻塞启动
+	if err := db.Exec("DROP INDEX idx_projects_owner_parent").Error; err != nil {
+		t.Fatalf("drop index: %v", err)
+	}
+	for _, statement := range []string{
+		"INSERT INTO users (id, username, email, phone, password) VALUES (1, 'owner', 'owner@example.com', '13800000000', 'x')",
+		"INSERT INTO projects (id, name, owner_id) VALUES (1, 'upstream', 1)",
+		"INSERT INTO projects (name, owner_id, parent_id) VALUES ('fork-a', 1, 1), ('fork-b', 1, 1)",
+	} {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+	}
+	created, err := createForkUniqueIndex(db)
+	if err != nil || created {
+		t.Fatalf("createForkUniqueIndex() = %v, %v, want false, nil with duplicate forks", created, err)
+	}
+	if db.Migrator().HasIndex("projects", "idx_projects_owner_parent") {
+		t.Error("idx_projects_owner_parent was created despite duplicate forks")
+	}
+
+	// 清理重复后再次创建成功
+	if err := db.Exec("DELETE FROM projects WHERE name = 'fork-b'").Error; err != nil {
+		t.Fatalf("delete duplicate: %v", err)
+	}
+	if created, err := createForkUniqueIndex(db); err != nil || !created {
+		t.Fatalf("createForkUniqueIndex() = %v, %v, want true, nil", created, err)
+	}
+	if !db.Migrator().HasIndex("projects", "idx_projects_owner_parent") {
+		t.Error("idx_projects_owner_parent is missing")
+	}
+}
+
+func TestCreateDeviceTypeCheck(t *testing.T) {
+	db := openTestSchema(t, "test_database_device_type_check")
+	if _, err := runMigrations(db); err != nil {
+		t.Fatalf("runMigrations: %v", err)
+	}
+	if !db.Migrator().HasConstraint("devices", deviceTypeCheckName) {
+		t.Fatalf("%s was not created by the migration", deviceTypeCheckName)
+	}
+	if err := db.Exec("INSERT INTO users (id, username, email, phone, password) VALUES (1, 'owner', 'owner@example.com', '13800000000', 'x')").Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	// 模型钩子在写库前拒绝未知类型
+	device := models.Device{DeviceID: "bad", Name: "bad", Type: 99, OwnerID: 1}
+	if err := db.Create(&device).Error; !errors.Is(err, models.ErrInvalidDeviceType) {
+		t.Errorf("Create() with type 99 error = %v, want ErrInvalidDeviceType", err)
+	}
+	valid := models.Device{DeviceID: "ok", Name: "ok", Type: models.SoilMoisture, OwnerID: 1}
+	if err := db.Create(&valid).Error; err != nil {
+		t.Fatalf("Create() with a valid type: %v", err)
+	}
+	if err := db.Model(&valid).Update("type", 99).Error; !errors.Is(err, models.ErrInvalidDeviceType) {
+		t.Errorf("Update(type, 99) error = %v, want ErrInvalidDeviceType", err)
+	}
+
+	// 绕过模型的写入由检查约束拒绝
+	if err := db.Exec("UPDATE devices SET type = 99 WHERE id = ?", valid.ID).Error; err == nil {
+		t.Error("raw update to type 99 succeeded despite the check constraint")
+	}
+
+	// 已有未知类型的设备时跳过创建约束，不阻塞启动
+	if err := db.Exec("ALTER TABLE devices DROP CONSTRAINT " + deviceTypeCheckName).Error; err != nil {
+		t.Fatalf("drop constraint: %v", err)
+	}
+	if err := db.Exec("UPDATE devices SET type = 99 WHERE id = ?", valid.ID).Error; err != nil {
+		t.Fatalf("raw update without the constraint: %v", err)
+	}
+	if created, err := createDeviceTypeCheck(db); err != nil || created {
+		t.Fatalf("createDeviceTypeCheck() = %v, %v, want false, nil with an unknown type", created, err)
+	}
+	if db.Migrator().HasConstraint("devices", deviceTypeCheckName) {
+		t.Error("the check constraint was created despite an unknown type")
+	}
+
+	if err := db.Exec("UPDATE devices SET type = ? WHERE id = ?", models.SoilMoisture, valid.ID).Error; err != nil {
+		t.Fatalf("fix device type: %v", err)
+	}
+	if created, err := createDeviceTypeCheck(db); err != nil || !created {
+		t.Fatalf("createDeviceTypeCheck() = %v, %v, want true, nil", created, err)
+	}
+}
+
+func TestDeviceTypeCheckExpr(t *testing.T) {
+	expr := deviceTypeCheckExpr()
+	types := models.ValidDeviceTypes()
+	if !strings.HasPrefix(expr, "type IN (1, ") || strings.Count(expr, ",") != len(types)-1 {
+		t.Errorf("deviceTypeCheckExpr() = %s, want every type in DeviceTypeNames", expr)
+	}
+}
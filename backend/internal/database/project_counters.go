@@ -0,0 +1,31 @@
+package database
+
+import (
+	"context"
+	"log"
+)
+
+// projectCounterUpdates 各计数列的校正语句：在单条UPDATE中按实际记录数重算，
+// 不会覆盖读取与写入之间发生的增减
+var projectCounterUpdates = map[string]string{
+	"star_count": `UPDATE projects p SET star_count = (SELECT COUNT(*) FROM project_stars s WHERE s.project_id = p.id)
+		WHERE p.star_count <> (SELECT COUNT(*) FROM project_stars s WHERE s.project_id = p.id)`,
+	"fork_count": `UPDATE projects p SET fork_count = (SELECT COUNT(*) FROM projects c WHERE c.parent_id = p.id)
+		WHERE p.fork_count <> (SELECT COUNT(*) FROM projects c WHERE c.parent_id = p.id)`,
+}
+
+// ReconcileProjectCounters 根据点赞记录和子项目数重新计算star_count和fork_count，返回修正的计数个数
+func ReconcileProjectCounters(ctx context.Context) (int, error) {
+	corrected := 0
+	for column, statement := range projectCounterUpdates {
+		result := DB.WithContext(ctx).Exec(statement)
+		if result.Error != nil {
+			return corrected, result.Error
+		}
+		if result.RowsAffected > 0 {
+			log.Printf("Project %s corrected for %d project(s)", column, result.RowsAffected)
+		}
+		corrected += int(result.RowsAffected)
+	}
+	return corrected, nil
+}
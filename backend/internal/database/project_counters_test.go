@@ -0,0 +1,105 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"iot-platform-backend/internal/models"
+)
+
+// useTestRedis 启动内存Redis并设为RedisClient
+func useTestRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	previous := RedisClient
+	RedisClient = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		RedisClient.Close()
+		RedisClient = previous
+	})
+	return mr
+}
+
+func TestHTakeAll(t *testing.T) {
+	useTestRedis(t)
+	ctx := context.Background()
+	cache := NewCache()
+
+	for field, incr := range map[string]int64{"1": 3, "2": 5} {
+		if _, err := cache.HIncrBy(ctx, "buffer", field, incr); err != nil {
+			t.Fatalf("HIncrBy: %v", err)
+		}
+	}
+
+	values, err := cache.HTakeAll(ctx, "buffer")
+	if err != nil {
+		t.Fatalf("HTakeAll: %v", err)
+	}
+	if values["1"] != "3" || values["2"] != "5" || len(values) != 2 {
+		t.Errorf("HTakeAll = %v", values)
+	}
+	if exists, _ := cache.Exists(ctx, "buffer"); exists {
+		t.Error("hash still exists after HTakeAll")
+	}
+
+	values, err = cache.HTakeAll(ctx, "buffer")
+	if err != nil || len(values) != 0 {
+		t.Errorf("HTakeAll on missing key = %v, %v", values, err)
+	}
+}
+
+func TestProjectCounters(t *testing.T) {
+	db := openTestSchema(t, "test_database_project_counters")
+	if _, err := runMigrations(db); err != nil {
+		t.Fatalf("runMigrations: %v", err)
+	}
+	useTestRedis(t)
+	ctx := context.Background()
+
+	owner := models.User{Username: "owner", Email: "owner@example.com", Phone: "1", Password: "x"}
+	fan := models.User{Username: "fan", Email: "fan@example.com", Phone: "2", Password: "x"}
+	for _, user := range []*models.User{&owner, &fan} {
+		if err := db.Create(user).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+	project := models.Project{Name: "p", OwnerID: owner.ID, StarCount: 7, ForkCount: 3}
+	if err := db.Create(&project).Error; err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+	if err := db.Create(&models.ProjectStar{ProjectID: project.ID, UserID: fan.ID}).Error; err != nil {
+		t.Fatalf("create star: %v", err)
+	}
+
+	corrected, err := ReconcileProjectCounters(ctx)
+	if err != nil {
+		t.Fatalf("ReconcileProjectCounters: %v", err)
+	}
+	if corrected != 2 {
+		t.Errorf("corrected %d counter(s), want 2", corrected)
+	}
+	var reloaded models.Project
+	db.First(&reloaded, project.ID)
+	if reloaded.StarCount != 1 || reloaded.ForkCount != 0 {
+		t.Errorf("counters = star:%d fork:%d, want star:1 fork:0", reloaded.StarCount, reloaded.ForkCount)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := BufferProjectView(ctx, project.ID); err != nil {
+			t.Fatalf("BufferProjectView: %v", err)
+		}
+	}
+	if flushed, err := FlushProjectViews(ctx); err != nil || flushed != 1 {
+		t.Fatalf("FlushProjectViews = %d, %v", flushed, err)
+	}
+	// 缓冲区已清空，再次写回不会重复累加
+	if flushed, err := FlushProjectViews(ctx); err != nil || flushed != 0 {
+		t.Fatalf("second FlushProjectViews = %d, %v", flushed, err)
+	}
+	db.First(&reloaded, project.ID)
+	if reloaded.ViewCount != 3 {
+		t.Errorf("view_count = %d, want 3", reloaded.ViewCount)
+	}
+}
@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+	
+	"gorm.io/gorm"
+	"iot-platform-backend/internal/models"
+)
+
+// projectViewTTL 每日浏览计数保留时间，需覆盖最长的热门统计窗口
+const projectViewTTL = 31 * 24 * time.Hour
+
+// projectViewDayLayout 每日浏览计数键的日期格式
+const projectViewDayLayout = "20060102"
+
+// RecordProjectView 记录一次项目浏览（按天累计，用于热门项目统计）
+func RecordProjectView(ctx context.Context, projectID uint) error {
+	if RedisClient == nil {
+		return nil
+	}
+	
+	cache := NewCache()
+	key := Keys.ProjectViews(time.Now().UTC().Format(projectViewDayLayout))
+	if _, err := cache.HIncrBy(ctx, key, strconv.FormatUint(uint64(projectID), 10), 1); err != nil {
+		return err
+	}
+	return cache.Expire(ctx, key, projectViewTTL)
+}
+
+// GetProjectViewsSince 汇总since当天至今的项目浏览次数（按天粒度）
+func GetProjectViewsSince(ctx context.Context, since time.Time) (map[uint]int64, error) {
+	views := make(map[uint]int64)
+	if RedisClient == nil {
+		return views, nil
+	}
+	
+	cache := NewCache()
+	day := since.UTC().Truncate(24 * time.Hour)
+	today := time.Now().UTC()
+	for ; !day.After(today); day = day.Add(24 * time.Hour) {
+		counts, err := cache.HGetAll(ctx, Keys.ProjectViews(day.Format(projectViewDayLayout)))
+		if err != nil {
+			return nil, err
+		}
+		
+		for field, value := range counts {
+			projectID, err := strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				continue
+			}
+			count, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			views[uint(projectID)] += count
+		}
+	}
+	
+	return views, nil
+}
+
+// BufferProjectView 累加项目浏览次数，由对账任务批量写回数据库；未连接Redis时直接更新
+func BufferProjectView(ctx context.Context, projectID uint) error {
+	if RedisClient == nil {
+		return DB.WithContext(ctx).Model(&models.Project{}).
+			Where("id = ?", projectID).
+			UpdateColumn("view_count", gorm.Expr("view_count + ?", 1)).Error
+	}
+	_, err := NewCache().HIncrBy(ctx, Keys.ViewBuffer(), strconv.FormatUint(uint64(projectID), 10), 1)
+	return err
+}
+
+// FlushProjectViews 将缓冲的浏览次数写回projects.view_count，返回写回的项目数
+// 缓冲区被原子地读出并删除，期间新的浏览计入新缓冲区；写回在一个数据库事务中完成，
+// 失败时将读出的计数加回缓冲区。进程在读出与写回之间退出时本轮计数会丢失（只少计、不重复累加）
+func FlushProjectViews(ctx context.Context) (int, error) {
+	if RedisClient == nil {
+		return 0, nil
+	}
+	
+	cache := NewCache()
+	counts, err := cache.HTakeAll(ctx, Keys.ViewBuffer())
+	if err != nil || len(counts) == 0 {
+		return 0, err
+	}
+	
+	views := make(map[uint]int64, len(counts))
+	for field, value := range counts {
+		projectID, err := strconv.ParseUint(field, 10, 32)
+		count, countErr := strconv.ParseInt(value, 10, 64)
+		if err == nil && countErr == nil && count > 0 {
+			views[uint(projectID)] += count
+		}
+	}
+	
+	err = DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for projectID, count := range views {
+			if err := tx.Model(&models.Project{}).
+				Where("id = ?", projectID).
+				UpdateColumn("view_count", gorm.Expr("view_count + ?", count)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		// 写回失败时调用方的上下文可能已取消，加回缓冲区不受其影响
+		restoreCtx := context.WithoutCancel(ctx)
+		for projectID, count := range views {
+			if _, restoreErr := cache.HIncrBy(restoreCtx, Keys.ViewBuffer(), strconv.FormatUint(uint64(projectID), 10), count); restoreErr != nil {
+				log.Printf("Failed to restore %d buffered view(s) of project %d: %v", count, projectID, restoreErr)
+			}
+		}
+		return 0, err
+	}
+	return len(views), nil
+}
@@ -0,0 +1,31 @@
+package database
+
+import (
+	"context"
+	
+	"github.com/redis/go-redis/v9"
+)
+
+// SensorRowQuota 缓存的数据行数配额状态，数据上报时避免每次查询配额和统计用量
+type SensorRowQuota struct {
+	Limit int64 `json:"limit"`
+	Used  int64 `json:"used"`
+}
+
+// RecordSensorRows 写入成功后按写入行数累加缓存中的用量，使缓存期内的配额检查仍然有效
+// 同步上报每条读数调用一次，异步管道在批量写入后按设备拥有者汇总调用
+func RecordSensorRows(ctx context.Context, ownerID uint, rows int64) {
+	if RedisClient == nil || rows <= 0 {
+		return
+	}
+	cache := NewCache()
+	key := Keys.QuotaSensorRows(ownerID)
+	
+	var state SensorRowQuota
+	if err := cache.Get(ctx, key, &state); err != nil || state.Limit == 0 {
+		return
+	}
+	state.Used += rows
+	// 保持原有过期时间，到期后重新从设备计数汇总
+	cache.Set(ctx, key, state, redis.KeepTTL)
+}
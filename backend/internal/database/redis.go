@@ -17,11 +17,13 @@ type RedisConfig struct {
 	Port     string
 	Password string
 	DB       int
+	KeyPrefix string // 所有键和频道的全局前缀
 }
 
 // ConnectRedis 连接Redis
 func ConnectRedis(cfg *RedisConfig) error {
 	addr := fmt.Sprintf("%s:%s", cfg.Host, cfg.Port)
+	SetKeyPrefix(cfg.KeyPrefix)
 	
 	RedisClient = redis.NewClient(&redis.Options{
 		Addr:     addr,
@@ -31,8 +33,7 @@ func ConnectRedis(cfg *RedisConfig) error {
 		// 连接池配置
 		PoolSize:        10,
 		PoolTimeout:     30 * time.Second,
-		IdleTimeout:     5 * time.Minute,
-		IdleCheckFrequency: time.Minute,
+		ConnMaxIdleTime: 5 * time.Minute,
 		
 		// 重试配置
 		MaxRetries:      3,
@@ -92,11 +93,26 @@ func (c *Cache) Exists(ctx context.Context, key string) (bool, error) {
 	return result > 0, err
 }
 
+// SetNX 仅当键不存在时设置缓存，返回是否设置成功
+func (c *Cache) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	jsonValue, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+	
+	return c.client.SetNX(ctx, key, jsonValue, expiration).Result()
+}
+
 // Expire 设置过期时间
 func (c *Cache) Expire(ctx context.Context, key string, expiration time.Duration) error {
 	return c.client.Expire(ctx, key, expiration).Err()
 }
 
+// TTL 获取剩余过期时间，键不存在时为-2，未设置过期时间时为-1（与Redis一致）
+func (c *Cache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return c.client.TTL(ctx, key).Result()
+}
+
 // HSet 哈希表设置
 func (c *Cache) HSet(ctx context.Context, key string, field string, value interface{}) error {
 	jsonValue, err := json.Marshal(value)
@@ -127,6 +143,11 @@ func (c *Cache) HDel(ctx context.Context, key string, fields ...string) error {
 	return c.client.HDel(ctx, key, fields...).Err()
 }
 
+// HIncrBy 哈希表字段自增
+func (c *Cache) HIncrBy(ctx context.Context, key string, field string, incr int64) (int64, error) {
+	return c.client.HIncrBy(ctx, key, field, incr).Result()
+}
+
 // ZAdd 有序集合添加
 func (c *Cache) ZAdd(ctx context.Context, key string, score float64, member interface{}) error {
 	return c.client.ZAdd(ctx, key, redis.Z{
@@ -145,11 +166,50 @@ func (c *Cache) ZRangeByScore(ctx context.Context, key string, min, max string,
 	}).Result()
 }
 
+// ZRemRangeByRank 按排名范围删除有序集合成员
+func (c *Cache) ZRemRangeByRank(ctx context.Context, key string, start, stop int64) error {
+	return c.client.ZRemRangeByRank(ctx, key, start, stop).Err()
+}
+
+// HTakeAll 在一个MULTI事务中读出并删除整个哈希表
+func (c *Cache) HTakeAll(ctx context.Context, key string) (map[string]string, error) {
+	var values *redis.MapStringStringCmd
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		values = pipe.HGetAll(ctx, key)
+		pipe.Del(ctx, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values.Val(), nil
+}
+
+// Incr 自增计数
+func (c *Cache) Incr(ctx context.Context, key string) (int64, error) {
+	return c.client.Incr(ctx, key).Result()
+}
+
 // ZRem 删除有序集合成员
 func (c *Cache) ZRem(ctx context.Context, key string, members ...interface{}) error {
 	return c.client.ZRem(ctx, key, members...).Err()
 }
 
+// SAdd 添加集合成员
+func (c *Cache) SAdd(ctx context.Context, key string, members ...interface{}) error {
+	return c.client.SAdd(ctx, key, members...).Err()
+}
+
+// SMembers 获取集合所有成员
+func (c *Cache) SMembers(ctx context.Context, key string) ([]string, error) {
+	return c.client.SMembers(ctx, key).Result()
+}
+
+// SRem 删除集合成员
+func (c *Cache) SRem(ctx context.Context, key string, members ...interface{}) error {
+	return c.client.SRem(ctx, key, members...).Err()
+}
+
 // Publish 发布消息
 func (c *Cache) Publish(ctx context.Context, channel string, message interface{}) error {
 	jsonMessage, err := json.Marshal(message)
@@ -157,12 +217,16 @@ func (c *Cache) Publish(ctx context.Context, channel string, message interface{}
 		return err
 	}
 	
-	return c.client.Publish(ctx, channel, jsonMessage).Err()
+	return c.client.Publish(ctx, namespaced(channel), jsonMessage).Err()
 }
 
-// Subscribe 订阅消息
+// Subscribe 订阅消息，频道名带有全局键前缀
 func (c *Cache) Subscribe(ctx context.Context, channels ...string) *redis.PubSub {
-	return c.client.Subscribe(ctx, channels...)
+	prefixed := make([]string, len(channels))
+	for i, channel := range channels {
+		prefixed[i] = namespaced(channel)
+	}
+	return c.client.Subscribe(ctx, prefixed...)
 }
 
 // Close 关闭Redis连接
@@ -182,6 +246,15 @@ func RedisHealth() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	
+	return RedisHealthContext(ctx)
+}
+
+// RedisHealthContext 使用调用方上下文的Redis健康检查
+func RedisHealthContext(ctx context.Context) error {
+	if RedisClient == nil {
+		return fmt.Errorf("Redis client not initialized")
+	}
+	
 	_, err := RedisClient.Ping(ctx).Result()
 	return err
 }
@@ -212,44 +285,174 @@ func RedisStats() (map[string]interface{}, error) {
 	}, nil
 }
 
+// keyPrefix 全局键前缀（如app:prod:），多个环境或部署共用同一Redis时区分各自的键和频道
+var keyPrefix string
+
+// SetKeyPrefix 设置全局键前缀，需在使用缓存之前调用
+func SetKeyPrefix(prefix string) {
+	keyPrefix = prefix
+}
+
+// KeyPrefix 当前的全局键前缀
+func KeyPrefix() string {
+	return keyPrefix
+}
+
+// namespaced 为键或频道名加上全局键前缀
+func namespaced(key string) string {
+	return keyPrefix + key
+}
+
 // 缓存键前缀常量
 const (
-	UserCachePrefix    = "user:"
-	DeviceCachePrefix  = "device:"
-	ProjectCachePrefix = "project:"
-	DataCachePrefix    = "data:"
-	SessionPrefix      = "session:"
+	UserCachePrefix      = "user:"
+	DeviceCachePrefix    = "device:"
+	ProjectCachePrefix   = "project:"
+	DataCachePrefix      = "data:"
+	SessionPrefix        = "session:"
+	UserSessionsPrefix   = "user_sessions:"
+	ReplayPrefix         = "ws_replay:"
+	DeviceCountPrefix    = "device_count:"
+	DeviceLatestPrefix   = "device_latest:"
+	ProjectViewPrefix    = "project_views:"
+	TrendingPrefix       = "trending:"
+	PublicStatsPrefix    = "public_stats:"
+	PublicProjectsPrefix = "public_projects:"
+	DeviceImportPrefix   = "device_import:"
+	DeviceDedupPrefix    = "device_dedup:"
+	DeviceLastSeenPrefix = "device_last_seen:"
+	QuotaUsagePrefix     = "quota_usage:"
+	BlacklistPrefix      = "blacklist:"
+	TokenRevokePrefix    = "token_revoked:"
+	RateLimitPrefix      = "rate_limit:"
+	StarCooldownPrefix   = "star_cooldown:"
+	FleetStatsPrefix     = "fleet_stats:"
+	LockPrefix           = "lock:"
+	ViewBufferKey        = "project_view_buffer"
 )
 
-// CacheKeys 生成缓存键的辅助函数
+// CacheKeys 生成缓存键的辅助函数，所有键都带有全局键前缀
 type CacheKeys struct{}
 
 func (CacheKeys) User(id uint) string {
-	return fmt.Sprintf("%s%d", UserCachePrefix, id)
+	return namespaced(fmt.Sprintf("%s%d", UserCachePrefix, id))
 }
 
 func (CacheKeys) Device(deviceID string) string {
-	return fmt.Sprintf("%s%s", DeviceCachePrefix, deviceID)
+	return namespaced(fmt.Sprintf("%s%s", DeviceCachePrefix, deviceID))
 }
 
 func (CacheKeys) Project(id uint) string {
-	return fmt.Sprintf("%s%d", ProjectCachePrefix, id)
+	return namespaced(fmt.Sprintf("%s%d", ProjectCachePrefix, id))
 }
 
 func (CacheKeys) SensorData(deviceID string, date string) string {
-	return fmt.Sprintf("%s%s:%s", DataCachePrefix, deviceID, date)
+	return namespaced(fmt.Sprintf("%s%s:%s", DataCachePrefix, deviceID, date))
 }
 
 func (CacheKeys) Session(sessionID string) string {
-	return fmt.Sprintf("%s%s", SessionPrefix, sessionID)
+	return namespaced(fmt.Sprintf("%s%s", SessionPrefix, sessionID))
+}
+
+func (CacheKeys) UserSessions(userID uint) string {
+	return namespaced(fmt.Sprintf("%s%d", UserSessionsPrefix, userID))
+}
+
+func (CacheKeys) DeviceReplay(deviceID string) string {
+	return namespaced(fmt.Sprintf("%s%s", ReplayPrefix, deviceID))
+}
+
+func (CacheKeys) DeviceReplaySeq(deviceID string) string {
+	return namespaced(fmt.Sprintf("%s%s:seq", ReplayPrefix, deviceID))
+}
+
+func (CacheKeys) DeviceDataCount(deviceID string) string {
+	return namespaced(fmt.Sprintf("%s%s", DeviceCountPrefix, deviceID))
+}
+
+func (CacheKeys) FleetStats(userID uint) string {
+	return namespaced(fmt.Sprintf("%s%d", FleetStatsPrefix, userID))
+}
+
+func (CacheKeys) DeviceLatest(deviceID string) string {
+	return namespaced(fmt.Sprintf("%s%s", DeviceLatestPrefix, deviceID))
+}
+
+func (CacheKeys) ProjectViews(day string) string {
+	return namespaced(fmt.Sprintf("%s%s", ProjectViewPrefix, day))
+}
+
+func (CacheKeys) Trending(window string, limit int) string {
+	return namespaced(fmt.Sprintf("%s%s:%d", TrendingPrefix, window, limit))
+}
+
+func (CacheKeys) PublicStats(window string) string {
+	return namespaced(fmt.Sprintf("%s%s", PublicStatsPrefix, window))
+}
+
+func (CacheKeys) DeviceImport(jobID string) string {
+	return namespaced(fmt.Sprintf("%s%s", DeviceImportPrefix, jobID))
+}
+
+func (CacheKeys) DeviceImportCancel(jobID string) string {
+	return namespaced(fmt.Sprintf("%s%s:cancel", DeviceImportPrefix, jobID))
+}
+
+func (CacheKeys) DeviceDedup(deviceID, hash string) string {
+	return namespaced(fmt.Sprintf("%s%s:%s", DeviceDedupPrefix, deviceID, hash))
+}
+
+func (CacheKeys) DeviceLastSeen(deviceID string) string {
+	return namespaced(fmt.Sprintf("%s%s", DeviceLastSeenPrefix, deviceID))
+}
+
+func (CacheKeys) QuotaSensorRows(userID uint) string {
+	return namespaced(fmt.Sprintf("%ssensor_rows:%d", QuotaUsagePrefix, userID))
+}
+
+func (CacheKeys) TokenBlacklist(token string) string {
+	return namespaced(fmt.Sprintf("%s%s", BlacklistPrefix, token))
+}
+
+func (CacheKeys) TokensRevokedAt(userID uint) string {
+	return namespaced(fmt.Sprintf("%s%d", TokenRevokePrefix, userID))
+}
+
+func (CacheKeys) RateLimit(userID uint, scope string) string {
+	return namespaced(fmt.Sprintf("%s%d:%s", RateLimitPrefix, userID, scope))
+}
+
+func (CacheKeys) StarCooldown(userID, projectID uint) string {
+	return namespaced(fmt.Sprintf("%s%d:%d", StarCooldownPrefix, userID, projectID))
+}
+
+func (CacheKeys) Lock(name string) string {
+	return namespaced(fmt.Sprintf("%s%s", LockPrefix, name))
 }
 
 func (CacheKeys) DeviceList(userID uint) string {
-	return fmt.Sprintf("device_list:%d", userID)
+	return namespaced(fmt.Sprintf("device_list:%d", userID))
 }
 
 func (CacheKeys) ProjectList(userID uint) string {
-	return fmt.Sprintf("project_list:%d", userID)
+	return namespaced(fmt.Sprintf("project_list:%d", userID))
+}
+
+func (CacheKeys) ViewBuffer() string {
+	return namespaced(ViewBufferKey)
+}
+
+func (CacheKeys) DeviceTypes() string {
+	return namespaced("device_types")
+}
+
+// PublicProjects 公开项目列表缓存，generation变化后旧的缓存不再命中
+func (CacheKeys) PublicProjects(generation int64, query string) string {
+	return namespaced(fmt.Sprintf("%s%d:%s", PublicProjectsPrefix, generation, query))
+}
+
+func (CacheKeys) PublicProjectsGeneration() string {
+	return namespaced(fmt.Sprintf("%sgeneration", PublicProjectsPrefix))
 }
 
 // 全局缓存键实例
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"time"
+	
+	"iot-platform-backend/internal/models"
+)
+
+// PurgeSensorData 分批删除设备的传感器数据，每批最多batchSize行，返回删除总数
+// 分批删除使每条语句只持有短时间的行锁，避免一次删除大量数据时阻塞写入
+func PurgeSensorData(ctx context.Context, deviceID string, batchSize int) (int64, error) {
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		
+		result := DB.WithContext(ctx).Exec(
+			"DELETE FROM sensor_data WHERE id IN (SELECT id FROM sensor_data WHERE device_id = ? LIMIT ?)",
+			deviceID, batchSize,
+		)
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+		if result.RowsAffected < int64(batchSize) {
+			return total, nil
+		}
+	}
+}
+
+// PurgeDeletedDeviceData 清理删除时间早于olderThan、尚未清理的设备的传感器数据和命令记录
+func PurgeDeletedDeviceData(ctx context.Context, olderThan time.Time, batchSize int) (int, int64, error) {
+	var devices []models.Device
+	if err := DB.WithContext(ctx).Unscoped().
+		Select("id", "device_id").
+		Where("deleted_at IS NOT NULL AND deleted_at <= ? AND data_purged_at IS NULL", olderThan).
+		Find(&devices).Error; err != nil {
+		return 0, 0, err
+	}
+	
+	var rows int64
+	for i, device := range devices {
+		deleted, err := PurgeSensorData(ctx, device.DeviceID, batchSize)
+		rows += deleted
+		if err != nil {
+			return i, rows, err
+		}
+		if err := DB.WithContext(ctx).Where("device_id = ?", device.ID).Delete(&models.DeviceCommand{}).Error; err != nil {
+			return i, rows, err
+		}
+		if err := DB.WithContext(ctx).Unscoped().Model(&models.Device{}).
+			Where("id = ?", device.ID).
+			Update("data_purged_at", time.Now()).Error; err != nil {
+			return i, rows, err
+		}
+	}
+	return len(devices), rows, nil
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"iot-platform-backend/internal/models"
+)
+
+// createPurgeFixtures 创建设备及其传感器数据，deletedAgo不为nil时软删除设备并回拨删除时间
+func createPurgeFixtures(t *testing.T, db *gorm.DB, ownerID uint, deviceID string, readings int, deletedAgo *time.Duration) models.Device {
+	t.Helper()
+	device := models.Device{DeviceID: deviceID, Name: deviceID, Type: models.SoilMoisture, OwnerID: ownerID}
+	if err := db.Create(&device).Error; err != nil {
+		t.Fatalf("create device %s: %v", deviceID, err)
+	}
+	for i := 0; i < readings; i++ {
+		reading := models.SensorData{DeviceID: deviceID, Data: models.JSONB{"soil_humidity": i}, Timestamp: time.Now().Add(-time.Duration(i) * time.Minute)}
+		if err := db.Create(&reading).Error; err != nil {
+			t.Fatalf("create reading: %v", err)
+		}
+	}
+	if deletedAgo != nil {
+		if err := db.Unscoped().Model(&device).Update("deleted_at", time.Now().Add(-*deletedAgo)).Error; err != nil {
+			t.Fatalf("delete device %s: %v", deviceID, err)
+		}
+	}
+	return device
+}
+
+// countReadings 统计设备的传感器数据行数
+func countReadings(db *gorm.DB, deviceID string) int64 {
+	var count int64
+	db.Model(&models.SensorData{}).Where("device_id = ?", deviceID).Count(&count)
+	return count
+}
+
+func TestPurgeSensorDataInBatches(t *testing.T) {
+	db := openTestSchema(t, "test_database_purge_batches")
+	if _, err := runMigrations(db); err != nil {
+		t.Fatalf("runMigrations: %v", err)
+	}
+	owner := models.User{Username: "owner", Email: "owner@example.com", Phone: "1", Password: "x"}
+	if err := db.Create(&owner).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	createPurgeFixtures(t, db, owner.ID, "purged", 7, nil)
+	createPurgeFixtures(t, db, owner.ID, "kept", 2, nil)
+
+	// 批大小不整除总行数时最后一批不足batchSize后结束
+	deleted, err := PurgeSensorData(context.Background(), "purged", 3)
+	if err != nil || deleted != 7 {
+		t.Fatalf("PurgeSensorData() = %d, %v, want 7", deleted, err)
+	}
+	if got := countReadings(db, "purged"); got != 0 {
+		t.Errorf("%d reading(s) left for the purged device, want 0", got)
+	}
+	if got := countReadings(db, "kept"); got != 2 {
+		t.Errorf("%d reading(s) left for another device, want 2", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := PurgeSensorData(ctx, "kept", 1); err == nil {
+		t.Error("PurgeSensorData() with a canceled context succeeded, want an error")
+	}
+	if got := countReadings(db, "kept"); got != 2 {
+		t.Errorf("%d reading(s) left after a canceled purge, want 2", got)
+	}
+}
+
+func TestPurgeDeletedDeviceDataRetention(t *testing.T) {
+	db := openTestSchema(t, "test_database_purge_retention")
+	if _, err := runMigrations(db); err != nil {
+		t.Fatalf("runMigrations: %v", err)
+	}
+	owner := models.User{Username: "owner", Email: "owner@example.com", Phone: "1", Password: "x"}
+	if err := db.Create(&owner).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	twoDays, oneHour := 48*time.Hour, time.Hour
+	expired := createPurgeFixtures(t, db, owner.ID, "expired", 5, &twoDays)
+	recent := createPurgeFixtures(t, db, owner.ID, "recent", 3, &oneHour)
+	createPurgeFixtures(t, db, owner.ID, "active", 4, nil)
+
+	// retain模式：只清理保留期（1天）已满的设备，其余数据仍可按device_id查询
+	ctx := context.Background()
+	devices, rows, err := PurgeDeletedDeviceData(ctx, time.Now().Add(-24*time.Hour), 2)
+	if err != nil || devices != 1 || rows != 5 {
+		t.Fatalf("PurgeDeletedDeviceData() = %d, %d, %v, want 1 device and 5 rows", devices, rows, err)
+	}
+	for deviceID, want := range map[string]int64{"expired": 0, "recent": 3, "active": 4} {
+		if got := countReadings(db, deviceID); got != want {
+			t.Errorf("%s has %d reading(s), want %d", deviceID, got, want)
+		}
+	}
+	var purged models.Device
+	db.Unscoped().First(&purged, expired.ID)
+	if purged.DataPurgedAt == nil {
+		t.Error("data_purged_at is not set on the purged device")
+	}
+
+	// 已清理的设备不再重复处理
+	if devices, _, err := PurgeDeletedDeviceData(ctx, time.Now().Add(-24*time.Hour), 2); err != nil || devices != 0 {
+		t.Errorf("second run processed %d device(s) (%v), want 0", devices, err)
+	}
+
+	// batch模式：删除后立即由后台分批清理
+	devices, rows, err = PurgeDeletedDeviceData(ctx, time.Now(), 2)
+	if err != nil || devices != 1 || rows != 3 {
+		t.Fatalf("PurgeDeletedDeviceData() = %d, %d, %v, want the recent device and 3 rows", devices, rows, err)
+	}
+	if got := countReadings(db, "active"); got != 4 {
+		t.Errorf("active device has %d reading(s), want 4", got)
+	}
+	db.Unscoped().First(&purged, recent.ID)
+	if purged.DataPurgedAt == nil {
+		t.Error("data_purged_at is not set on the recently deleted device")
+	}
+}
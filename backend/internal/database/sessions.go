@@ -0,0 +1,147 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+	
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrSessionNotFound 会话不存在、已过期或已被吊销
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session 一次登录产生的会话，同一会话的access token和refresh token共享会话ID
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     uint      `json:"user_id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	Remember   bool      `json:"remember"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"` // 最近一次登录或刷新的时间
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// SaveSession 写入会话记录并加入用户的会话索引，ttl与refresh token有效期一致
+// 刷新时以新的有效期重新写入，实现滑动续期
+func SaveSession(ctx context.Context, session *Session, ttl time.Duration) error {
+	if RedisClient == nil {
+		return nil
+	}
+	
+	cache := NewCache()
+	if err := cache.Set(ctx, Keys.Session(session.ID), session, ttl); err != nil {
+		return err
+	}
+	
+	// 索引的有效期取其中最长的会话，索引中过期的会话ID在列出时清理
+	indexKey := Keys.UserSessions(session.UserID)
+	if err := cache.SAdd(ctx, indexKey, session.ID); err != nil {
+		return err
+	}
+	if current, err := RedisClient.TTL(ctx, indexKey).Result(); err == nil && current >= ttl {
+		return nil
+	}
+	return cache.Expire(ctx, indexKey, ttl)
+}
+
+// GetSession 获取会话记录
+func GetSession(ctx context.Context, sessionID string) (*Session, error) {
+	if RedisClient == nil {
+		return nil, ErrSessionNotFound
+	}
+	
+	var session Session
+	if err := NewCache().Get(ctx, Keys.Session(sessionID), &session); err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// IsSessionActive 检查会话是否仍然有效
+// 未启用Redis、token不带会话ID（旧token）或Redis异常时视为有效，与token黑名单的处理一致
+func IsSessionActive(ctx context.Context, sessionID string) bool {
+	if RedisClient == nil || sessionID == "" {
+		return true
+	}
+	exists, err := NewCache().Exists(ctx, Keys.Session(sessionID))
+	return err != nil || exists
+}
+
+// ListUserSessions 列出用户的有效会话，按最近使用时间倒序，顺带清理索引中已过期的会话ID
+func ListUserSessions(ctx context.Context, userID uint) ([]Session, error) {
+	if RedisClient == nil {
+		return []Session{}, nil
+	}
+	
+	cache := NewCache()
+	indexKey := Keys.UserSessions(userID)
+	ids, err := cache.SMembers(ctx, indexKey)
+	if err != nil {
+		return nil, err
+	}
+	
+	sessions := make([]Session, 0, len(ids))
+	var stale []interface{}
+	for _, id := range ids {
+		session, err := GetSession(ctx, id)
+		if errors.Is(err, ErrSessionNotFound) {
+			stale = append(stale, id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+	if len(stale) > 0 {
+		cache.SRem(ctx, indexKey, stale...)
+	}
+	
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+	return sessions, nil
+}
+
+// DeleteSession 吊销用户的单个会话，会话不属于该用户时返回ErrSessionNotFound
+func DeleteSession(ctx context.Context, userID uint, sessionID string) error {
+	session, err := GetSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if session.UserID != userID {
+		return ErrSessionNotFound
+	}
+	
+	cache := NewCache()
+	if err := cache.Delete(ctx, Keys.Session(sessionID)); err != nil {
+		return err
+	}
+	return cache.SRem(ctx, Keys.UserSessions(userID), sessionID)
+}
+
+// DeleteUserSessions 吊销用户的所有会话
+func DeleteUserSessions(ctx context.Context, userID uint) error {
+	if RedisClient == nil {
+		return nil
+	}
+	
+	cache := NewCache()
+	indexKey := Keys.UserSessions(userID)
+	ids, err := cache.SMembers(ctx, indexKey)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(ids)+1)
+	for _, id := range ids {
+		keys = append(keys, Keys.Session(id))
+	}
+	keys = append(keys, indexKey)
+	return cache.Delete(ctx, keys...)
+}
@@ -0,0 +1,33 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// RevokeUserTokens 吊销用户在此刻之前签发的所有token
+// ttl应不小于refresh token有效期，过期后旧token本身也已失效
+func RevokeUserTokens(ctx context.Context, userID uint, ttl time.Duration) error {
+	if RedisClient == nil {
+		return nil
+	}
+	return NewCache().Set(ctx, Keys.TokensRevokedAt(userID), time.Now().Unix(), ttl)
+}
+
+// IsTokenRevoked 检查token是否已被登出加入黑名单，或签发于用户吊销时间之前
+func IsTokenRevoked(ctx context.Context, token string, userID uint, issuedAt time.Time) bool {
+	if RedisClient == nil {
+		return false
+	}
+	
+	cache := NewCache()
+	if blacklisted, err := cache.Exists(ctx, Keys.TokenBlacklist(token)); err == nil && blacklisted {
+		return true
+	}
+	
+	var revokedAt int64
+	if err := cache.Get(ctx, Keys.TokensRevokedAt(userID), &revokedAt); err != nil {
+		return false
+	}
+	return issuedAt.Unix() <= revokedAt
+}
@@ -0,0 +1,147 @@
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	
+	"iot-platform-backend/internal/models"
+)
+
+// ComputedField 由表达式计算的派生字段，如由温度和湿度计算露点
+type ComputedField struct {
+	Field     string `json:"field"`
+	Expr      string `json:"expr"`
+	Precision *int   `json:"precision,omitempty"` // 保留的小数位数，不设置时不取整
+	
+	compiled *Expr
+}
+
+// ComputedFields 按设备类型配置的派生字段，按顺序计算，后面的字段可以引用前面的结果
+type ComputedFields map[models.DeviceType][]ComputedField
+
+// computedFields 当前生效的派生字段配置，未配置时读数原样返回
+var computedFields ComputedFields
+
+// InitComputedFields 解析并编译派生字段配置，格式为{"设备类型ID": [{"field":..., "expr":...}]}，空字符串表示不计算
+func InitComputedFields(raw string) error {
+	parsed, err := ParseComputedFields(raw)
+	if err != nil {
+		return err
+	}
+	computedFields = parsed
+	return nil
+}
+
+// ParseComputedFields 解析派生字段配置并编译表达式
+func ParseComputedFields(raw string) (ComputedFields, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	
+	var byType map[string][]ComputedField
+	if err := json.Unmarshal([]byte(raw), &byType); err != nil {
+		return nil, fmt.Errorf("invalid computed field config: %w", err)
+	}
+	
+	result := make(ComputedFields, len(byType))
+	for key, fields := range byType {
+		id, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid device type %q in computed field config", key)
+		}
+		deviceType := models.DeviceType(id)
+		if _, ok := models.DeviceTypeNames[deviceType]; !ok {
+			return nil, fmt.Errorf("unknown device type %d in computed field config", id)
+		}
+		for i := range fields {
+			field := &fields[i]
+			if field.Field == "" {
+				return nil, fmt.Errorf("device type %d computed field %d: field is required", id, i+1)
+			}
+			if field.Precision != nil && (*field.Precision < 0 || *field.Precision > 10) {
+				return nil, fmt.Errorf("device type %d computed field %s: precision must be between 0 and 10", id, field.Field)
+			}
+			compiled, err := Compile(field.Expr)
+			if err != nil {
+				return nil, fmt.Errorf("device type %d computed field %s: %w", id, field.Field, err)
+			}
+			field.compiled = compiled
+		}
+		result[deviceType] = fields
+	}
+	return result, nil
+}
+
+// HasComputedFields 是否配置了任何派生字段
+func HasComputedFields() bool {
+	return len(computedFields) > 0
+}
+
+// Enrich 计算设备类型的派生字段并合并到读数副本中返回，原始数据不被修改
+// 读数中已有同名字段时保留原值；输入字段缺失、不是数值或结果不是有限数时跳过该字段。
+// 未配置派生字段或没有计算出任何字段时原样返回data
+func Enrich(deviceType models.DeviceType, data models.JSONB) models.JSONB {
+	fields := computedFields[deviceType]
+	if len(fields) == 0 || data == nil {
+		return data
+	}
+	
+	var enriched models.JSONB
+	for _, field := range fields {
+		if _, exists := data[field.Field]; exists {
+			continue
+		}
+		
+		// 已计算的字段可被后续表达式引用
+		input := map[string]interface{}(data)
+		if enriched != nil {
+			input = enriched
+		}
+		value, err := field.compiled.Eval(input)
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+		if field.Precision != nil {
+			scale := math.Pow(10, float64(*field.Precision))
+			value = math.Round(value*scale) / scale
+		}
+		
+		if enriched == nil {
+			enriched = make(models.JSONB, len(data)+len(fields))
+			for key, v := range data {
+				enriched[key] = v
+			}
+		}
+		enriched[field.Field] = value
+	}
+	
+	if enriched == nil {
+		return data
+	}
+	return enriched
+}
+
+// numberValue 将JSON数值转换为float64
+func numberValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case json.Number:
+		number, err := v.Float64()
+		return number, err == nil
+	}
+	return 0, false
+}
+
+// asMap 判断值是否为JSON对象
+func asMap(value interface{}) (map[string]interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v, true
+	case models.JSONB:
+		return map[string]interface{}(v), true
+	}
+	return nil, false
+}
@@ -0,0 +1,88 @@
+package ingest
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"iot-platform-backend/internal/models"
+)
+
+// useComputedFields 在测试期间替换生效的派生字段配置
+func useComputedFields(t *testing.T, raw string) {
+	t.Helper()
+	previous := computedFields
+	if err := InitComputedFields(raw); err != nil {
+		t.Fatalf("InitComputedFields() = %v", err)
+	}
+	t.Cleanup(func() { computedFields = previous })
+}
+
+func TestParseComputedFields(t *testing.T) {
+	if fields, err := ParseComputedFields(""); err != nil || fields != nil {
+		t.Errorf(`ParseComputedFields("") = %v, %v, want nil, nil`, fields, err)
+	}
+
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr string
+	}{
+		{"invalid json", `{"1": {}}`, "invalid computed field config"},
+		{"non-numeric type", `{"weather": []}`, `invalid device type "weather"`},
+		{"unknown type", `{"999": []}`, "unknown device type 999"},
+		{"missing field", `{"1": [{"expr": "1"}]}`, "field is required"},
+		{"negative precision", `{"1": [{"field": "x", "expr": "1", "precision": -1}]}`, "precision must be between 0 and 10"},
+		{"bad expression", `{"1": [{"field": "x", "expr": "temperature +"}]}`, "computed field x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseComputedFields(tt.raw)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseComputedFields() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEnrich(t *testing.T) {
+	useComputedFields(t, `{"1": [
+		{"field": "gamma", "expr": "ln(humidity / 100) + 17.62 * temperature / (243.12 + temperature)"},
+		{"field": "dew_point", "expr": "243.12 * gamma / (17.62 - gamma)", "precision": 2},
+		{"field": "temperature_f", "expr": "temperature * 1.8 + 32"},
+		{"field": "ratio", "expr": "temperature / (humidity - humidity)"}
+	]}`)
+	if !HasComputedFields() {
+		t.Fatal("HasComputedFields() = false after configuring fields")
+	}
+
+	data := models.JSONB{"temperature": 20.0, "humidity": 50.0, "temperature_f": "reported"}
+	enriched := Enrich(models.WeatherStation, data)
+
+	gamma := math.Log(0.5) + 17.62*20/(243.12+20)
+	if got := enriched["gamma"]; got != gamma {
+		t.Errorf("gamma = %v, want %v", got, gamma)
+	}
+	// 后面的字段引用前面计算的结果，并按精度取整
+	if got, want := enriched["dew_point"], math.Round(243.12*gamma/(17.62-gamma)*100)/100; got != want {
+		t.Errorf("dew_point = %v, want %v", got, want)
+	}
+	if got := enriched["temperature_f"]; got != "reported" {
+		t.Errorf("temperature_f = %v, want the reported value kept", got)
+	}
+	if _, ok := enriched["ratio"]; ok {
+		t.Error("non-finite result was added to the reading")
+	}
+	if len(data) != 3 {
+		t.Errorf("original reading was modified: %v", data)
+	}
+
+	// 输入缺失时跳过，没有计算出字段则原样返回
+	partial := models.JSONB{"pressure": 1013.0}
+	if got := Enrich(models.WeatherStation, partial); len(got) != 1 {
+		t.Errorf("Enrich() = %v, want the reading unchanged", got)
+	}
+	if got := Enrich(models.SoilMoisture, data); len(got) != 3 {
+		t.Errorf("Enrich() for an unconfigured type = %v, want the reading unchanged", got)
+	}
+}
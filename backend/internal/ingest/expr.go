@@ -0,0 +1,388 @@
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 表达式长度和嵌套深度上限，避免配置错误导致的超深递归
+const (
+	maxExprLength = 1024
+	maxExprDepth  = 32
+)
+
+// ErrMissingInput 表达式引用的字段不存在或不是数值
+var ErrMissingInput = errors.New("missing numeric input")
+
+// Expr 编译后的数值表达式
+// 支持数字、字段引用（嵌套字段以点号连接，如env.temperature）、+ - * / ^、括号和内置函数，
+// 不支持赋值、字符串或任何副作用，可以安全地执行配置中的表达式
+type Expr struct {
+	source string
+	root   exprNode
+	fields []string
+}
+
+// exprFuncs 内置函数及其参数个数
+var exprFuncs = map[string]struct {
+	arity int
+	fn    func(args []float64) float64
+}{
+	"abs":   {1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"sqrt":  {1, func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"exp":   {1, func(a []float64) float64 { return math.Exp(a[0]) }},
+	"ln":    {1, func(a []float64) float64 { return math.Log(a[0]) }},
+	"log10": {1, func(a []float64) float64 { return math.Log10(a[0]) }},
+	"pow":   {2, func(a []float64) float64 { return math.Pow(a[0], a[1]) }},
+	"min":   {2, func(a []float64) float64 { return math.Min(a[0], a[1]) }},
+	"max":   {2, func(a []float64) float64 { return math.Max(a[0], a[1]) }},
+}
+
+// compiledExprs 已编译的表达式，按源码缓存
+var compiledExprs sync.Map
+
+// Compile 编译表达式，相同的源码只编译一次
+func Compile(source string) (*Expr, error) {
+	if cached, ok := compiledExprs.Load(source); ok {
+		return cached.(*Expr), nil
+	}
+	if len(source) > maxExprLength {
+		return nil, fmt.Errorf("expression longer than %d characters", maxExprLength)
+	}
+	
+	p := &exprParser{src: source}
+	p.next()
+	root, err := p.parseExpr(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.tok.text, p.tok.pos)
+	}
+	
+	expr := &Expr{source: source, root: root, fields: p.fields}
+	actual, _ := compiledExprs.LoadOrStore(source, expr)
+	return actual.(*Expr), nil
+}
+
+// String 表达式源码
+func (e *Expr) String() string {
+	return e.source
+}
+
+// Fields 表达式引用的字段，按首次出现的顺序
+func (e *Expr) Fields() []string {
+	return e.fields
+}
+
+// Eval 以读数中的字段为变量求值；引用的字段缺失或不是数值时返回ErrMissingInput
+func (e *Expr) Eval(data map[string]interface{}) (float64, error) {
+	return e.root.eval(data)
+}
+
+// exprNode 表达式语法树节点
+type exprNode interface {
+	eval(data map[string]interface{}) (float64, error)
+}
+
+type numberNode float64
+
+func (n numberNode) eval(map[string]interface{}) (float64, error) {
+	return float64(n), nil
+}
+
+type fieldNode string
+
+func (n fieldNode) eval(data map[string]interface{}) (float64, error) {
+	value, ok := lookupNumber(data, string(n))
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrMissingInput, string(n))
+	}
+	return value, nil
+}
+
+type negNode struct {
+	operand exprNode
+}
+
+func (n negNode) eval(data map[string]interface{}) (float64, error) {
+	value, err := n.operand.eval(data)
+	return -value, err
+}
+
+type binaryNode struct {
+	op          byte
+	left, right exprNode
+}
+
+func (n binaryNode) eval(data map[string]interface{}) (float64, error) {
+	left, err := n.left.eval(data)
+	if err != nil {
+		return 0, err
+	}
+	right, err := n.right.eval(data)
+	if err != nil {
+		return 0, err
+	}
+	switch n.op {
+	case '+':
+		return left + right, nil
+	case '-':
+		return left - right, nil
+	case '*':
+		return left * right, nil
+	case '/':
+		return left / right, nil
+	default:
+		return math.Pow(left, right), nil
+	}
+}
+
+type callNode struct {
+	fn   func(args []float64) float64
+	args []exprNode
+}
+
+func (n callNode) eval(data map[string]interface{}) (float64, error) {
+	args := make([]float64, len(n.args))
+	for i, arg := range n.args {
+		value, err := arg.eval(data)
+		if err != nil {
+			return 0, err
+		}
+		args[i] = value
+	}
+	return n.fn(args), nil
+}
+
+// lookupNumber 按点号分隔的路径查找数值字段
+func lookupNumber(data map[string]interface{}, path string) (float64, bool) {
+	var value interface{} = data
+	for _, key := range strings.Split(path, ".") {
+		object, ok := asMap(value)
+		if !ok {
+			return 0, false
+		}
+		if value, ok = object[key]; !ok {
+			return 0, false
+		}
+	}
+	return numberValue(value)
+}
+
+// 词法单元类型
+const (
+	tokEOF = iota
+	tokNumber
+	tokIdent
+	tokOp
+)
+
+type exprToken struct {
+	kind int
+	text string
+	pos  int
+}
+
+// exprParser 递归下降解析器，优先级从低到高：+ -、* /、一元负号、^（右结合）
+type exprParser struct {
+	src    string
+	pos    int
+	tok    exprToken
+	fields []string
+}
+
+// next 读取下一个词法单元
+func (p *exprParser) next() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = exprToken{kind: tokEOF, pos: start}
+		return
+	}
+	
+	ch := p.src[p.pos]
+	switch {
+	case isDigit(ch) || ch == '.':
+		for p.pos < len(p.src) && (isDigit(p.src[p.pos]) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		// 科学计数法，如1e-3
+		if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+			p.pos++
+			if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+				p.pos++
+			}
+			for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+				p.pos++
+			}
+		}
+		p.tok = exprToken{kind: tokNumber, text: p.src[start:p.pos], pos: start}
+	case isIdentStart(ch):
+		for p.pos < len(p.src) && (isIdentStart(p.src[p.pos]) || isDigit(p.src[p.pos]) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		p.tok = exprToken{kind: tokIdent, text: p.src[start:p.pos], pos: start}
+	default:
+		p.pos++
+		p.tok = exprToken{kind: tokOp, text: string(ch), pos: start}
+	}
+}
+
+// parseExpr 解析加减
+func (p *exprParser) parseExpr(depth int) (exprNode, error) {
+	if depth > maxExprDepth {
+		return nil, fmt.Errorf("expression nested deeper than %d levels", maxExprDepth)
+	}
+	left, err := p.parseTerm(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokOp && (p.tok.text == "+" || p.tok.text == "-") {
+		op := p.tok.text[0]
+		p.next()
+		right, err := p.parseTerm(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseTerm 解析乘除
+func (p *exprParser) parseTerm(depth int) (exprNode, error) {
+	left, err := p.parseUnary(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokOp && (p.tok.text == "*" || p.tok.text == "/") {
+		op := p.tok.text[0]
+		p.next()
+		right, err := p.parseUnary(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseUnary 解析一元负号，-x^2按-(x^2)处理
+func (p *exprParser) parseUnary(depth int) (exprNode, error) {
+	if p.tok.kind == tokOp && p.tok.text == "-" {
+		p.next()
+		operand, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return negNode{operand: operand}, nil
+	}
+	return p.parsePower(depth)
+}
+
+// parsePower 解析乘方（右结合）
+func (p *exprParser) parsePower(depth int) (exprNode, error) {
+	base, err := p.parsePrimary(depth)
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokOp && p.tok.text == "^" {
+		p.next()
+		exponent, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return binaryNode{op: '^', left: base, right: exponent}, nil
+	}
+	return base, nil
+}
+
+// parsePrimary 解析数字、字段、函数调用和括号
+func (p *exprParser) parsePrimary(depth int) (exprNode, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokNumber:
+		value, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at position %d", tok.text, tok.pos)
+		}
+		p.next()
+		return numberNode(value), nil
+	
+	case tokIdent:
+		p.next()
+		if p.tok.kind != tokOp || p.tok.text != "(" {
+			p.addField(tok.text)
+			return fieldNode(tok.text), nil
+		}
+		
+		fn, ok := exprFuncs[tok.text]
+		if !ok {
+			return nil, fmt.Errorf("unknown function %q at position %d", tok.text, tok.pos)
+		}
+		p.next()
+		var args []exprNode
+		for !(p.tok.kind == tokOp && p.tok.text == ")") {
+			if len(args) > 0 {
+				if p.tok.kind != tokOp || p.tok.text != "," {
+					return nil, fmt.Errorf("expected , or ) at position %d", p.tok.pos)
+				}
+				p.next()
+			}
+			arg, err := p.parseExpr(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+		}
+		p.next()
+		if len(args) != fn.arity {
+			return nil, fmt.Errorf("%s expects %d arguments, got %d", tok.text, fn.arity, len(args))
+		}
+		return callNode{fn: fn.fn, args: args}, nil
+	
+	case tokOp:
+		if tok.text == "(" {
+			p.next()
+			inner, err := p.parseExpr(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if p.tok.kind != tokOp || p.tok.text != ")" {
+				return nil, fmt.Errorf("expected ) at position %d", p.tok.pos)
+			}
+			p.next()
+			return inner, nil
+		}
+	}
+	
+	if tok.kind == tokEOF {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+}
+
+// addField 记录引用的字段
+func (p *exprParser) addField(name string) {
+	for _, field := range p.fields {
+		if field == name {
+			return
+		}
+	}
+	p.fields = append(p.fields, name)
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isIdentStart(ch byte) bool {
+	return ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
@@ -0,0 +1,123 @@
+package ingest
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExprEval(t *testing.T) {
+	data := map[string]interface{}{
+		"temperature": 20.0,
+		"humidity":    json.Number("50"),
+		"env":         map[string]interface{}{"pressure": 1013.25},
+	}
+
+	tests := []struct {
+		expr string
+		want float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"10 / 4", 2.5},
+		{"2 ^ 3 ^ 2", 512},
+		{"-2 ^ 2", -4},
+		{"--3", 3},
+		{"1e-3 * 1000", 1},
+		{".5 + .5", 1},
+		{"temperature * 1.8 + 32", 68},
+		{"humidity / 100", 0.5},
+		{"env.pressure / 10", 101.325},
+		{"abs(-3) + sqrt(16) + pow(2, 10)", 1031},
+		{"max(temperature, humidity) - min(temperature, humidity)", 30},
+		{"ln(exp(2)) + log10(1000)", 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := Compile(tt.expr)
+			if err != nil {
+				t.Fatalf("Compile(%q) = %v", tt.expr, err)
+			}
+			got, err := expr.Eval(data)
+			if err != nil {
+				t.Fatalf("Eval() = %v", err)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Eval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExprMissingInput(t *testing.T) {
+	data := map[string]interface{}{
+		"temperature": 20.0,
+		"status":      "ok",
+		"env":         map[string]interface{}{"pressure": 1013.25},
+	}
+	for _, source := range []string{"humidity + 1", "status * 2", "env.missing", "temperature.value", "max(temperature, humidity)"} {
+		t.Run(source, func(t *testing.T) {
+			expr, err := Compile(source)
+			if err != nil {
+				t.Fatalf("Compile(%q) = %v", source, err)
+			}
+			if _, err := expr.Eval(data); !errors.Is(err, ErrMissingInput) {
+				t.Errorf("Eval() = %v, want ErrMissingInput", err)
+			}
+		})
+	}
+}
+
+func TestCompileRejectsInvalidExpressions(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr string
+	}{
+		{"", "unexpected end"},
+		{"1 +", "unexpected end"},
+		{"1 2", `unexpected "2"`},
+		{"(1 + 2", "expected )"},
+		{"1 + 2)", `unexpected ")"`},
+		{"temperature = 1", `unexpected "="`},
+		{`"text"`, `unexpected "\""`},
+		{"system(1)", `unknown function "system"`},
+		{"sqrt(1, 2)", "sqrt expects 1 arguments, got 2"},
+		{"pow(2 3)", "expected , or )"},
+		{"1.2.3", "invalid number"},
+		{strings.Repeat("(", 40) + "1" + strings.Repeat(")", 40), "nested deeper than"},
+		{strings.Repeat("1+", 600) + "1", "longer than"},
+	}
+	for _, tt := range tests {
+		name := tt.expr
+		if len(name) > 20 {
+			name = name[:20]
+		}
+		t.Run(name, func(t *testing.T) {
+			_, err := Compile(tt.expr)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Compile() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExprFields(t *testing.T) {
+	expr, err := Compile("temperature + humidity * temperature - env.pressure")
+	if err != nil {
+		t.Fatalf("Compile() = %v", err)
+	}
+	want := []string{"temperature", "humidity", "env.pressure"}
+	if got := expr.Fields(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Fields() = %v, want %v", got, want)
+	}
+
+	// 相同源码复用编译结果
+	again, err := Compile(expr.String())
+	if err != nil || again != expr {
+		t.Errorf("Compile() of the same source = %p, %v, want the cached %p", again, err, expr)
+	}
+}
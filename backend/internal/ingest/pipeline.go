@@ -0,0 +1,314 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+	
+	"iot-platform-backend/internal/config"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/shutdown"
+	"iot-platform-backend/internal/webhook"
+	"iot-platform-backend/internal/websocket"
+)
+
+var (
+	// ErrQueueFull 写入队列已满，调用方应稍后重试
+	ErrQueueFull = errors.New("ingestion queue is full")
+	// ErrStopped 管道已停止，不再接受新数据
+	ErrStopped = errors.New("ingestion pipeline stopped")
+)
+
+// Pipeline 异步批量写入传感器数据的管道
+// 读数先进入有界队列，由多个worker按批次写入数据库，再统一更新设备状态、缓存并推送
+type Pipeline struct {
+	queue            chan models.SensorData
+	workers          int
+	batchSize        int
+	flushInterval    time.Duration
+	lastSeenInterval time.Duration // 设备last_seen写入节流间隔
+	
+	// flushBatch 写入一个批次，默认为flush，测试中替换以避免依赖数据库
+	flushBatch func(batch []models.SensorData)
+	
+	mu      sync.RWMutex
+	stopped bool
+	wg      sync.WaitGroup
+	
+	enqueued atomic.Int64
+	rejected atomic.Int64
+	written  atomic.Int64
+	failed   atomic.Int64
+}
+
+// Stats 管道运行统计
+type Stats struct {
+	QueueLength   int   `json:"queue_length"`
+	QueueCapacity int   `json:"queue_capacity"`
+	Enqueued      int64 `json:"enqueued"`
+	Rejected      int64 `json:"rejected"`
+	Written       int64 `json:"written"`
+	Failed        int64 `json:"failed"`
+}
+
+// Default 全局写入管道，同步模式下为nil
+var Default *Pipeline
+
+// Init 根据配置初始化写入管道，仅在async模式下启用
+func Init(cfg config.IngestionConfig) {
+	if cfg.Mode != config.IngestionModeAsync {
+		return
+	}
+	Default = NewPipeline(cfg)
+	Default.Start()
+	log.Printf("Async ingestion enabled: %d worker(s), queue size %d, batch size %d", cfg.Workers, cfg.QueueSize, cfg.BatchSize)
+}
+
+// NewPipeline 创建写入管道
+func NewPipeline(cfg config.IngestionConfig) *Pipeline {
+	workers := cfg.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	batchSize := cfg.BatchSize
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	flushInterval := cfg.FlushInterval
+	if flushInterval <= 0 {
+		flushInterval = time.Second
+	}
+	
+	p := &Pipeline{
+		queue:            make(chan models.SensorData, cfg.QueueSize),
+		workers:          workers,
+		batchSize:        batchSize,
+		flushInterval:    flushInterval,
+		lastSeenInterval: cfg.LastSeenInterval,
+	}
+	p.flushBatch = p.flush
+	return p
+}
+
+// Start 启动worker
+func (p *Pipeline) Start() {
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		shutdown.Default.Go("ingest worker", p.worker)
+	}
+}
+
+// Enqueue 非阻塞地将读数放入队列，队列已满时返回ErrQueueFull
+func (p *Pipeline) Enqueue(data models.SensorData) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	
+	if p.stopped {
+		return ErrStopped
+	}
+	
+	select {
+	case p.queue <- data:
+		p.enqueued.Add(1)
+		return nil
+	default:
+		p.rejected.Add(1)
+		return ErrQueueFull
+	}
+}
+
+// Stop 停止接收新数据，等待队列中剩余数据写入完成或ctx超时
+func (p *Pipeline) Stop(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.stopped {
+		p.stopped = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+	
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats 获取运行统计
+func (p *Pipeline) Stats() Stats {
+	return Stats{
+		QueueLength:   len(p.queue),
+		QueueCapacity: cap(p.queue),
+		Enqueued:      p.enqueued.Load(),
+		Rejected:      p.rejected.Load(),
+		Written:       p.written.Load(),
+		Failed:        p.failed.Load(),
+	}
+}
+
+// worker 从队列读取数据，达到批次大小或定时刷新时写入
+func (p *Pipeline) worker() {
+	defer p.wg.Done()
+	
+	ticker := time.NewTicker(p.flushInterval)
+	defer ticker.Stop()
+	
+	batch := make([]models.SensorData, 0, p.batchSize)
+	for {
+		select {
+		case data, ok := <-p.queue:
+			if !ok {
+				if len(batch) > 0 {
+					p.flushBatch(batch)
+				}
+				return
+			}
+			batch = append(batch, data)
+			if len(batch) >= p.batchSize {
+				p.flushBatch(batch)
+				batch = make([]models.SensorData, 0, p.batchSize)
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				p.flushBatch(batch)
+				batch = make([]models.SensorData, 0, p.batchSize)
+			}
+		}
+	}
+}
+
+// flush 批量写入读数，并更新设备状态、计数缓存和WebSocket推送
+func (p *Pipeline) flush(batch []models.SensorData) {
+	if len(batch) == 0 {
+		return
+	}
+	
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	
+	db := database.GetDB().WithContext(ctx)
+	if err := db.CreateInBatches(&batch, p.batchSize).Error; err != nil {
+		p.failed.Add(int64(len(batch)))
+		log.Printf("Failed to write %d sensor reading(s): %v", len(batch), err)
+		return
+	}
+	p.written.Add(int64(len(batch)))
+	
+	// 每个设备只保留批次内最新的读数用于状态和缓存更新
+	latest := make(map[string]models.SensorData)
+	for _, data := range batch {
+		if current, ok := latest[data.DeviceID]; !ok || data.Timestamp.After(current.Timestamp) {
+			latest[data.DeviceID] = data
+		}
+		database.IncrDeviceDataCount(ctx, data.DeviceID)
+	}
+	
+	deviceIDs := make([]string, 0, len(latest))
+	for deviceID, data := range latest {
+		deviceIDs = append(deviceIDs, deviceID)
+		database.CacheLatestReading(ctx, &data)
+	}
+	database.TouchDeviceLastSeen(ctx, db, deviceIDs, p.lastSeenInterval)
+	
+	var devices []models.Device
+	if err := db.Select("id", "device_id", "name", "type", "owner_id", "maintenance", "maintenance_until").
+		Where("device_id IN ?", deviceIDs).
+		Find(&devices).Error; err != nil {
+		log.Printf("Failed to load devices for quota usage, alerts and webhook events: %v", err)
+	} else {
+		recordQuotaUsage(ctx, devices, batch)
+		recordAlerts(ctx, devices, batch)
+		emitWebhooks(ctx, devices, batch)
+	}
+	
+	if websocket.DefaultManager != nil {
+		for _, data := range batch {
+			websocket.DefaultManager.SendToDevice(data.DeviceID, websocket.Message{
+				Type: websocket.TypeDeviceData,
+				Data: map[string]interface{}{
+					"device_id": data.DeviceID,
+					"data":      data.Data,
+					"timestamp": data.Timestamp,
+				},
+				Timestamp: time.Now(),
+			})
+		}
+	}
+}
+
+// recordQuotaUsage 按设备拥有者累加批次写入的数据行数，使异步写入同样受数据行数配额限制
+func recordQuotaUsage(ctx context.Context, devices []models.Device, batch []models.SensorData) {
+	owners := make(map[string]uint, len(devices))
+	for _, device := range devices {
+		owners[device.DeviceID] = device.OwnerID
+	}
+	rows := make(map[uint]int64)
+	for _, data := range batch {
+		if ownerID, ok := owners[data.DeviceID]; ok {
+			rows[ownerID]++
+		}
+	}
+	for ownerID, count := range rows {
+		database.RecordSensorRows(ctx, ownerID, count)
+	}
+}
+
+// recordAlerts 为批次内超出范围的读数保存告警，并向设备所有者推送未确认告警数
+func recordAlerts(ctx context.Context, devices []models.Device, batch []models.SensorData) {
+	byDeviceID := make(map[string]models.Device, len(devices))
+	for _, device := range devices {
+		byDeviceID[device.DeviceID] = device
+	}
+	ownerIDs, err := database.RecordReadingAlerts(ctx, byDeviceID, batch)
+	if err != nil {
+		log.Printf("Failed to record alerts: %v", err)
+		return
+	}
+	if websocket.DefaultManager != nil {
+		for _, ownerID := range ownerIDs {
+			websocket.DefaultManager.PushAlertCount(ctx, ownerID)
+		}
+	}
+}
+
+// emitWebhooks 为批次内的每条读数触发Webhook事件
+func emitWebhooks(ctx context.Context, devices []models.Device, batch []models.SensorData) {
+	// 只为配置了Webhook的用户逐条触发事件，避免每条读数都查询一次
+	ownerIDs := make([]uint, 0, len(devices))
+	for _, device := range devices {
+		ownerIDs = append(ownerIDs, device.OwnerID)
+	}
+	var subscribed []uint
+	if err := database.GetDB().WithContext(ctx).Model(&models.Webhook{}).
+		Where("owner_id IN ? AND enabled = ?", ownerIDs, true).
+		Distinct().
+		Pluck("owner_id", &subscribed).Error; err != nil || len(subscribed) == 0 {
+		return
+	}
+	hasWebhook := make(map[uint]bool, len(subscribed))
+	for _, ownerID := range subscribed {
+		hasWebhook[ownerID] = true
+	}
+	
+	byDeviceID := make(map[string]models.Device, len(devices))
+	for _, device := range devices {
+		if hasWebhook[device.OwnerID] {
+			byDeviceID[device.DeviceID] = device
+		}
+	}
+	for _, data := range batch {
+		if device, ok := byDeviceID[data.DeviceID]; ok {
+			webhook.EmitReading(ctx, device, data)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"iot-platform-backend/internal/config"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/testutil"
+)
+
+// batchRecorder 记录管道交给写入函数的批次
+type batchRecorder struct {
+	mu      sync.Mutex
+	batches [][]models.SensorData
+	flushed chan int
+}
+
+func newBatchRecorder() *batchRecorder {
+	return &batchRecorder{flushed: make(chan int, 100)}
+}
+
+func (r *batchRecorder) flush(batch []models.SensorData) {
+	r.mu.Lock()
+	r.batches = append(r.batches, batch)
+	r.mu.Unlock()
+	r.flushed <- len(batch)
+}
+
+func (r *batchRecorder) sizes() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sizes := make([]int, len(r.batches))
+	for i, batch := range r.batches {
+		sizes[i] = len(batch)
+	}
+	return sizes
+}
+
+// newTestPipeline 创建单worker管道，批次交给recorder而不写数据库
+func newTestPipeline(cfg config.IngestionConfig, recorder *batchRecorder) *Pipeline {
+	cfg.Workers = 1
+	p := NewPipeline(cfg)
+	p.flushBatch = recorder.flush
+	return p
+}
+
+func reading(i int) models.SensorData {
+	return models.SensorData{
+		DeviceID:  fmt.Sprintf("device-%d", i%3),
+		Data:      models.JSONB{"value": i},
+		Timestamp: time.Now(),
+	}
+}
+
+func waitFlush(t *testing.T, recorder *batchRecorder) int {
+	t.Helper()
+	select {
+	case size := <-recorder.flushed:
+		return size
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a batch to be flushed")
+		return 0
+	}
+}
+
+func TestPipelineBatchesBySize(t *testing.T) {
+	recorder := newBatchRecorder()
+	p := newTestPipeline(config.IngestionConfig{QueueSize: 10, BatchSize: 3, FlushInterval: time.Hour}, recorder)
+	p.Start()
+
+	for i := 0; i < 7; i++ {
+		if err := p.Enqueue(reading(i)); err != nil {
+			t.Fatalf("Enqueue(%d) = %v", i, err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if size := waitFlush(t, recorder); size != 3 {
+			t.Fatalf("batch %d has %d reading(s), want 3", i, size)
+		}
+	}
+
+	// 未满一个批次的剩余读数在停止时写入
+	if err := p.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() = %v", err)
+	}
+	if got := recorder.sizes(); len(got) != 3 || got[2] != 1 {
+		t.Errorf("batch sizes = %v, want [3 3 1]", got)
+	}
+	if stats := p.Stats(); stats.Enqueued != 7 {
+		t.Errorf("enqueued = %d, want 7", stats.Enqueued)
+	}
+	if err := p.Enqueue(reading(8)); !errors.Is(err, ErrStopped) {
+		t.Errorf("Enqueue after Stop = %v, want ErrStopped", err)
+	}
+}
+
+func TestPipelineFlushesOnInterval(t *testing.T) {
+	recorder := newBatchRecorder()
+	p := newTestPipeline(config.IngestionConfig{QueueSize: 10, BatchSize: 100, FlushInterval: 20 * time.Millisecond}, recorder)
+	p.Start()
+	defer p.Stop(context.Background())
+
+	for i := 0; i < 2; i++ {
+		if err := p.Enqueue(reading(i)); err != nil {
+			t.Fatalf("Enqueue(%d) = %v", i, err)
+		}
+	}
+	if size := waitFlush(t, recorder); size != 2 {
+		t.Errorf("interval flush wrote %d reading(s), want 2", size)
+	}
+}
+
+func TestPipelineFlushesQueueOnShutdown(t *testing.T) {
+	recorder := newBatchRecorder()
+	p := newTestPipeline(config.IngestionConfig{QueueSize: 10, BatchSize: 4, FlushInterval: time.Hour}, recorder)
+
+	// worker启动前入队，停止时必须把队列中的读数全部写完
+	for i := 0; i < 6; i++ {
+		if err := p.Enqueue(reading(i)); err != nil {
+			t.Fatalf("Enqueue(%d) = %v", i, err)
+		}
+	}
+	p.Start()
+	if err := p.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() = %v", err)
+	}
+
+	total := 0
+	for _, size := range recorder.sizes() {
+		total += size
+	}
+	if total != 6 {
+		t.Errorf("flushed %d reading(s) on shutdown, want 6", total)
+	}
+}
+
+func TestPipelineStopTimeout(t *testing.T) {
+	release := make(chan struct{})
+	p := NewPipeline(config.IngestionConfig{QueueSize: 10, Workers: 1, BatchSize: 1, FlushInterval: time.Hour})
+	p.flushBatch = func(batch []models.SensorData) { <-release }
+	p.Start()
+	defer close(release)
+
+	if err := p.Enqueue(reading(0)); err != nil {
+		t.Fatalf("Enqueue() = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := p.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Stop() = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestPipelineRejectsWhenFull(t *testing.T) {
+	p := newTestPipeline(config.IngestionConfig{QueueSize: 2, BatchSize: 10, FlushInterval: time.Hour}, newBatchRecorder())
+
+	// 未启动worker，队列不会被消费
+	for i := 0; i < 2; i++ {
+		if err := p.Enqueue(reading(i)); err != nil {
+			t.Fatalf("Enqueue(%d) = %v", i, err)
+		}
+	}
+	if err := p.Enqueue(reading(2)); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Enqueue on a full queue = %v, want ErrQueueFull", err)
+	}
+
+	stats := p.Stats()
+	if stats.QueueLength != 2 || stats.QueueCapacity != 2 || stats.Enqueued != 2 || stats.Rejected != 1 {
+		t.Errorf("stats = %+v, want 2/2 queued, 2 enqueued and 1 rejected", stats)
+	}
+}
+
+func BenchmarkPipelineEnqueue(b *testing.B) {
+	for _, batchSize := range []int{1, 50, 500} {
+		b.Run(fmt.Sprintf("batch=%d", batchSize), func(b *testing.B) {
+			p := NewPipeline(config.IngestionConfig{QueueSize: 10000, Workers: 4, BatchSize: batchSize, FlushInterval: 10 * time.Millisecond})
+			p.flushBatch = func(batch []models.SensorData) {}
+			p.Start()
+			data := reading(0)
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					for p.Enqueue(data) == ErrQueueFull {
+						runtime.Gosched()
+					}
+				}
+			})
+			if err := p.Stop(context.Background()); err != nil {
+				b.Fatalf("Stop() = %v", err)
+			}
+		})
+	}
+}
+
+func TestRecordQuotaUsage(t *testing.T) {
+	testutil.LoadConfig(t)
+	testutil.UseRedis(t)
+	ctx := context.Background()
+
+	const ownerID, otherID = 7, 8
+	cache := database.NewCache()
+	if err := cache.Set(ctx, database.Keys.QuotaSensorRows(ownerID), database.SensorRowQuota{Limit: 3, Used: 1}, time.Minute); err != nil {
+		t.Fatalf("failed to seed quota cache: %v", err)
+	}
+
+	devices := []models.Device{
+		{DeviceID: "device-0", OwnerID: ownerID},
+		{DeviceID: "device-1", OwnerID: ownerID},
+		{DeviceID: "device-2", OwnerID: otherID},
+	}
+	batch := []models.SensorData{reading(0), reading(1), reading(3), reading(2), {DeviceID: "deleted"}}
+	recordQuotaUsage(ctx, devices, batch)
+
+	// 批次内属于该拥有者的三条读数全部计入，用量超出配额
+	var state database.SensorRowQuota
+	if err := cache.Get(ctx, database.Keys.QuotaSensorRows(ownerID), &state); err != nil {
+		t.Fatalf("failed to read quota cache: %v", err)
+	}
+	if state.Used != 4 {
+		t.Errorf("used = %d, want 4", state.Used)
+	}
+	// 没有缓存配额状态的拥有者不写入缓存，下次检查时从数据库统计
+	if err := cache.Get(ctx, database.Keys.QuotaSensorRows(otherID), &state); err == nil {
+		t.Errorf("quota state for an uncached owner = %+v, want none", state)
+	}
+}
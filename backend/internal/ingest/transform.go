@@ -0,0 +1,172 @@
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	
+	"iot-platform-backend/internal/models"
+)
+
+// 转换操作类型
+const (
+	OpRename = "rename" // 将field改名为to
+	OpScale  = "scale"  // field乘以factor，指定to时写入新字段
+	OpOffset = "offset" // field加上offset，指定to时写入新字段
+	OpDerive = "derive" // to = field*factor + offset，保留原字段
+	OpRound  = "round"  // 将field保留precision位小数
+)
+
+// TransformStep 单个转换步骤
+type TransformStep struct {
+	Op        string   `json:"op"`
+	Field     string   `json:"field"`
+	To        string   `json:"to,omitempty"`
+	Factor    *float64 `json:"factor,omitempty"`
+	Offset    *float64 `json:"offset,omitempty"`
+	Precision *int     `json:"precision,omitempty"`
+}
+
+// Transforms 按设备类型配置的转换流水线，按顺序执行
+type Transforms map[models.DeviceType][]TransformStep
+
+// transforms 当前生效的转换配置，未配置时读数原样保存
+var transforms Transforms
+
+// InitTransforms 解析并校验转换配置，格式为{"设备类型ID": [步骤...]}，空字符串表示不转换
+func InitTransforms(raw string) error {
+	parsed, err := ParseTransforms(raw)
+	if err != nil {
+		return err
+	}
+	transforms = parsed
+	return nil
+}
+
+// ParseTransforms 解析并校验转换配置
+func ParseTransforms(raw string) (Transforms, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	
+	var byType map[string][]TransformStep
+	if err := json.Unmarshal([]byte(raw), &byType); err != nil {
+		return nil, fmt.Errorf("invalid transform config: %w", err)
+	}
+	
+	result := make(Transforms, len(byType))
+	for key, steps := range byType {
+		id, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid device type %q in transform config", key)
+		}
+		deviceType := models.DeviceType(id)
+		if _, ok := models.DeviceTypeNames[deviceType]; !ok {
+			return nil, fmt.Errorf("unknown device type %d in transform config", id)
+		}
+		for i, step := range steps {
+			if err := step.validate(); err != nil {
+				return nil, fmt.Errorf("device type %d step %d: %w", id, i+1, err)
+			}
+		}
+		result[deviceType] = steps
+	}
+	return result, nil
+}
+
+// validate 校验步骤参数
+func (s TransformStep) validate() error {
+	if s.Field == "" {
+		return fmt.Errorf("field is required")
+	}
+	switch s.Op {
+	case OpRename:
+		if s.To == "" {
+			return fmt.Errorf("rename requires to")
+		}
+	case OpScale:
+		if s.Factor == nil {
+			return fmt.Errorf("scale requires factor")
+		}
+	case OpOffset:
+		if s.Offset == nil {
+			return fmt.Errorf("offset requires offset")
+		}
+	case OpDerive:
+		if s.To == "" {
+			return fmt.Errorf("derive requires to")
+		}
+		if s.Factor == nil && s.Offset == nil {
+			return fmt.Errorf("derive requires factor or offset")
+		}
+	case OpRound:
+		if s.Precision == nil || *s.Precision < 0 || *s.Precision > 10 {
+			return fmt.Errorf("round requires precision between 0 and 10")
+		}
+	default:
+		return fmt.Errorf("unknown op %q", s.Op)
+	}
+	return nil
+}
+
+// Normalize 对读数执行设备类型的转换流水线，返回归一化后的数据
+// 原始数据不被修改；未配置转换时返回nil，表示只保存原始数据
+func Normalize(deviceType models.DeviceType, data models.JSONB) models.JSONB {
+	steps := transforms[deviceType]
+	if len(steps) == 0 {
+		return nil
+	}
+	
+	normalized := make(models.JSONB, len(data))
+	for key, value := range data {
+		normalized[key] = value
+	}
+	for _, step := range steps {
+		step.apply(normalized)
+	}
+	return normalized
+}
+
+// apply 执行单个步骤，字段不存在或非数值时跳过
+func (s TransformStep) apply(data models.JSONB) {
+	value, exists := data[s.Field]
+	if !exists {
+		return
+	}
+	
+	if s.Op == OpRename {
+		delete(data, s.Field)
+		data[s.To] = value
+		return
+	}
+	
+	number, ok := numberValue(value)
+	if !ok {
+		return
+	}
+	
+	target := s.Field
+	if s.To != "" {
+		target = s.To
+	}
+	
+	switch s.Op {
+	case OpScale:
+		data[target] = number * *s.Factor
+	case OpOffset:
+		data[target] = number + *s.Offset
+	case OpDerive:
+		factor, offset := 1.0, 0.0
+		if s.Factor != nil {
+			factor = *s.Factor
+		}
+		if s.Offset != nil {
+			offset = *s.Offset
+		}
+		data[target] = number*factor + offset
+	case OpRound:
+		scale := math.Pow(10, float64(*s.Precision))
+		data[target] = math.Round(number*scale) / scale
+	}
+}
@@ -0,0 +1,207 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+	
+	"iot-platform-backend/internal/config"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/webhook"
+)
+
+// Start 注册并启动所有后台任务
+func Start(cfg *config.Config) *Scheduler {
+	scheduler := NewScheduler()
+	
+	scheduler.Every("device_counter_reconcile", cfg.Jobs.DeviceCounterReconcileInterval, reconcileDeviceCounters)
+	scheduler.Every("notification_cleanup", cfg.Jobs.NotificationCleanupInterval, func(ctx context.Context) error {
+		return purgeReadNotifications(ctx, cfg.Jobs.NotificationRetention)
+	})
+	scheduler.Every("project_counter_reconcile", cfg.Jobs.ProjectCounterReconcileInterval,
+		singleton("project_counter_reconcile", cfg.Jobs.ProjectCounterReconcileInterval, reconcileProjectCounters))
+	scheduler.Every("sensor_data_purge", cfg.Jobs.SensorDataPurgeInterval, func(ctx context.Context) error {
+		return purgeDeletedDeviceData(ctx, cfg.SensorData)
+	})
+	scheduler.Every("device_offline_sweep", cfg.Jobs.DeviceOfflineSweepInterval, sweepOfflineDevices)
+	if webhook.Default != nil {
+		scheduler.Every("webhook_delivery", cfg.Jobs.WebhookDeliveryInterval, webhook.Default.ProcessDue)
+		scheduler.Every("webhook_cleanup", cfg.Jobs.WebhookCleanupInterval, func(ctx context.Context) error {
+			return purgeWebhookDeliveries(ctx, cfg.Jobs.WebhookDeliveryRetention)
+		})
+	}
+	
+	return scheduler
+}
+
+// reconcileDeviceCounters 校正设备数据点计数缓存
+func reconcileDeviceCounters(ctx context.Context) error {
+	corrected, err := database.ReconcileDeviceDataCounts(ctx)
+	if err != nil {
+		return err
+	}
+	if corrected > 0 {
+		log.Printf("Device counter reconcile corrected %d device(s)", corrected)
+	}
+	return nil
+}
+
+// singleton 包装任务，多实例部署时通过分布式锁保证同一时间只有一个实例执行
+// 锁的有效期为任务间隔，执行完成后立即释放
+func singleton(name string, ttl time.Duration, job Job) Job {
+	return func(ctx context.Context) error {
+		unlock, acquired, err := database.TryLock(ctx, "job:"+name, ttl)
+		if err != nil {
+			return err
+		}
+		if !acquired {
+			return nil
+		}
+		defer unlock()
+		return job(ctx)
+	}
+}
+
+// reconcileProjectCounters 写回缓冲的浏览次数并校正项目点赞数和Fork数
+func reconcileProjectCounters(ctx context.Context) error {
+	flushed, err := database.FlushProjectViews(ctx)
+	if err != nil {
+		return err
+	}
+	if flushed > 0 {
+		log.Printf("Project counter reconcile flushed view counts for %d project(s)", flushed)
+	}
+	
+	corrected, err := database.ReconcileProjectCounters(ctx)
+	if err != nil {
+		return err
+	}
+	if corrected > 0 {
+		log.Printf("Project counter reconcile corrected %d counter(s)", corrected)
+	}
+	return nil
+}
+
+// purgeReadNotifications 删除超过保留时长的已读通知
+func purgeReadNotifications(ctx context.Context, retention time.Duration) error {
+	if retention <= 0 {
+		return nil
+	}
+	
+	result := database.GetDB().WithContext(ctx).
+		Where("read_at IS NOT NULL AND read_at < ?", time.Now().Add(-retention)).
+		Delete(&models.Notification{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Notification cleanup removed %d read notification(s)", result.RowsAffected)
+	}
+	return nil
+}
+
+// purgeWebhookDeliveries 删除超过保留时长的已成功或已放弃的Webhook投递记录
+func purgeWebhookDeliveries(ctx context.Context, retention time.Duration) error {
+	if retention <= 0 {
+		return nil
+	}
+	
+	removed, err := webhook.PurgeFinished(ctx, retention)
+	if err != nil {
+		return err
+	}
+	if removed > 0 {
+		log.Printf("Webhook cleanup removed %d finished delivery record(s)", removed)
+	}
+	return nil
+}
+
+// purgeDeletedDeviceData 分批清理已删除设备的传感器数据，retain模式下等待保留期结束
+func purgeDeletedDeviceData(ctx context.Context, cfg config.SensorDataConfig) error {
+	devices, rows, err := database.PurgeDeletedDeviceData(ctx, time.Now().Add(-cfg.PurgeDelay()), cfg.PurgeBatchSize)
+	if rows > 0 {
+		log.Printf("Sensor data purge removed %d reading(s) from %d deleted device(s)", rows, devices)
+	}
+	return err
+}
+
+// sweepOfflineDevices 将超时未上报的在线设备标记为离线并触发device.offline事件，
+// 维护中的设备跳过，维护结束时间已到的设备先退出维护模式
+func sweepOfflineDevices(ctx context.Context) error {
+	db := database.GetDB().WithContext(ctx)
+	now := time.Now()
+	cutoff := now.Add(-models.OnlineThreshold)
+	
+	if err := clearExpiredMaintenance(ctx, now); err != nil {
+		return err
+	}
+	
+	var devices []models.Device
+	if err := db.Where("status = ? AND (last_seen IS NULL OR last_seen < ?) AND NOT maintenance", "online", cutoff).
+		Find(&devices).Error; err != nil {
+		return err
+	}
+	
+	swept := 0
+	for _, device := range devices {
+		// 条件更新保证多实例同时运行时每台设备只触发一次事件，也避免覆盖刚进入维护模式的设备
+		result := db.Model(&models.Device{}).
+			Where("id = ? AND status = ? AND NOT maintenance", device.ID, "online").
+			Update("status", "offline")
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		swept++
+		
+		webhook.Emit(ctx, device.OwnerID, models.WebhookEventDeviceOffline, models.JSONB{
+			"device_id": device.DeviceID,
+			"name":      device.Name,
+			"type":      device.Type,
+			"last_seen": device.LastSeen,
+		})
+	}
+	if swept > 0 {
+		log.Printf("Device offline sweep marked %d device(s) offline", swept)
+	}
+	return nil
+}
+
+// clearExpiredMaintenance 维护结束时间已到的设备退出维护模式
+func clearExpiredMaintenance(ctx context.Context, now time.Time) error {
+	db := database.GetDB().WithContext(ctx)
+	
+	var devices []models.Device
+	if err := db.Select("id", "device_id", "owner_id").
+		Where("maintenance AND maintenance_until <= ?", now).
+		Find(&devices).Error; err != nil {
+		return err
+	}
+	if len(devices) == 0 {
+		return nil
+	}
+	
+	ids := make([]uint, len(devices))
+	for i, device := range devices {
+		ids[i] = device.ID
+	}
+	// 再次带上到期条件，不覆盖期间被延长或重新设置的维护
+	result := db.Model(&models.Device{}).
+		Where("id IN ? AND maintenance AND maintenance_until <= ?", ids, now).
+		Updates(map[string]interface{}{"maintenance": false, "maintenance_until": nil})
+	if result.Error != nil {
+		return result.Error
+	}
+	
+	if database.RedisClient != nil {
+		cache := database.NewCache()
+		for _, device := range devices {
+			cache.Delete(ctx, database.Keys.Device(device.DeviceID), database.Keys.DeviceList(device.OwnerID))
+		}
+	}
+	log.Printf("Device maintenance sweep ended maintenance for %d device(s)", result.RowsAffected)
+	return nil
+}
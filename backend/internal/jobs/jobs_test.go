@@ -0,0 +1,84 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/testutil"
+)
+
+func TestSweepOfflineDevicesMaintenance(t *testing.T) {
+	testutil.LoadConfig(t)
+	testutil.UseRedis(t)
+	db := testutil.UseDB(t)
+
+	owner := models.User{Username: "owner", Email: "owner@example.com", Phone: "1", Password: "secret123"}
+	if err := db.Create(&owner).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	hook := models.Webhook{OwnerID: owner.ID, URL: "https://example.com/hook", Events: pq.StringArray{models.WebhookEventDeviceOffline}, Secret: "s", Enabled: true}
+	if err := db.Create(&hook).Error; err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+
+	now := time.Now()
+	stale := now.Add(-2 * models.OnlineThreshold)
+	expired, future := now.Add(-time.Minute), now.Add(time.Hour)
+	create := func(deviceID string, maintenance bool, until *time.Time) models.Device {
+		t.Helper()
+		device := models.Device{DeviceID: deviceID, Name: deviceID, Type: models.SoilMoisture, OwnerID: owner.ID, Status: "online", LastSeen: &stale, Maintenance: maintenance, MaintenanceUntil: until}
+		if err := db.Create(&device).Error; err != nil {
+			t.Fatalf("create device %s: %v", deviceID, err)
+		}
+		return device
+	}
+	create("normal", false, nil)
+	create("manual", true, nil)
+	create("scheduled", true, &future)
+	create("expired", true, &expired)
+
+	ctx := context.Background()
+	cache := database.NewCache()
+	cache.Set(ctx, database.Keys.Device("expired"), "stale", time.Minute)
+
+	if err := sweepOfflineDevices(ctx); err != nil {
+		t.Fatalf("sweepOfflineDevices() error = %v", err)
+	}
+
+	var devices []models.Device
+	db.Order("device_id").Find(&devices)
+	got := make(map[string]string)
+	for _, device := range devices {
+		got[device.DeviceID] = fmt.Sprintf("%s maintenance=%v until=%v", device.Status, device.Maintenance, device.MaintenanceUntil != nil)
+	}
+	// 维护中的设备不被标记离线；维护到期的设备先退出维护模式，再按超时标记离线
+	want := map[string]string{
+		"normal":    "offline maintenance=false until=false",
+		"manual":    "online maintenance=true until=false",
+		"scheduled": "online maintenance=true until=true",
+		"expired":   "offline maintenance=false until=false",
+	}
+	for deviceID, state := range want {
+		if got[deviceID] != state {
+			t.Errorf("%s = %s, want %s", deviceID, got[deviceID], state)
+		}
+	}
+	if exists, _ := cache.Exists(ctx, database.Keys.Device("expired")); exists {
+		t.Error("cache of the device leaving maintenance was not cleared")
+	}
+
+	var events []models.WebhookDelivery
+	db.Where("webhook_id = ?", hook.ID).Find(&events)
+	offline := make(map[string]bool)
+	for _, event := range events {
+		offline[fmt.Sprint(event.Payload["device_id"])] = true
+	}
+	if len(events) != 2 || !offline["normal"] || !offline["expired"] {
+		t.Errorf("device.offline events for %v, want only normal and expired", offline)
+	}
+}
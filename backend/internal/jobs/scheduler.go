@@ -0,0 +1,63 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+	
+	"iot-platform-backend/internal/shutdown"
+)
+
+// Job 后台任务函数
+type Job func(ctx context.Context) error
+
+// Scheduler 后台定时任务调度器，任务goroutine登记在shutdown.Default中，关闭时统一等待
+type Scheduler struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewScheduler 创建调度器
+func NewScheduler() *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{ctx: ctx, cancel: cancel}
+}
+
+// Every 按固定间隔运行任务，interval<=0时不调度
+func (s *Scheduler) Every(name string, interval time.Duration, job Job) {
+	if interval <= 0 {
+		log.Printf("Job %s disabled", name)
+		return
+	}
+	
+	shutdown.Default.Go("job "+name, func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+				s.run(name, job)
+			}
+		}
+	})
+	
+	log.Printf("Job %s scheduled every %s", name, interval)
+}
+
+// run 执行一次任务并记录耗时和错误
+func (s *Scheduler) run(name string, job Job) {
+	start := time.Now()
+	if err := job(s.ctx); err != nil {
+		log.Printf("Job %s failed: %v", name, err)
+		return
+	}
+	log.Printf("Job %s completed in %s", name, time.Since(start))
+}
+
+// Stop 通知所有任务停止，不再开始新的执行；正在执行的任务通过shutdown.Default.Wait等待
+func (s *Scheduler) Stop() {
+	s.cancel()
+}
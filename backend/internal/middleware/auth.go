@@ -1,55 +1,113 @@
 package middleware
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 	
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"iot-platform-backend/internal/config"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/models"
+)
+
+// token类型
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
 )
 
 // Claims JWT声明结构
 type Claims struct {
-	UserID   uint   `json:"user_id"`
-	Username string `json:"username"`
-	Role     string `json:"role"`
+	UserID    uint   `json:"user_id"`
+	Username  string `json:"username,omitempty"`
+	Role      string `json:"role,omitempty"`
+	TokenType string `json:"token_type,omitempty"` // 为空视为access，兼容旧token
+	Remember  bool   `json:"remember,omitempty"`   // 是否为“记住我”会话，刷新时沿用
+	TenantID  string `json:"org_id,omitempty"`     // 所属租户，为空表示单租户token
+	SessionID string `json:"sid,omitempty"`        // 所属会话，同一次登录的access和refresh token相同，刷新时沿用
 	jwt.RegisteredClaims
 }
 
-// GenerateToken 生成JWT token
-func GenerateToken(userID uint, username, role string) (string, error) {
-	claims := Claims{
-		UserID:   userID,
-		Username: username,
-		Role:     role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			Issuer:    config.AppConfig.JWT.Issuer,
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(config.AppConfig.JWT.Expires)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-		},
+// SessionLifetimes 返回会话的access token和refresh token有效期，“记住我”会话使用更长的有效期
+func SessionLifetimes(remember bool) (time.Duration, time.Duration) {
+	cfg := config.AppConfig.JWT
+	if remember {
+		return cfg.Expires, cfg.RefreshExpires
 	}
-	
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(config.AppConfig.JWT.Secret))
+	return cfg.SessionExpires, cfg.SessionRefreshExpires
+}
+
+// NewSessionID 生成随机会话ID
+func NewSessionID() (string, error) {
+	return randomID()
+}
+
+// GenerateToken 生成JWT access token
+func GenerateToken(userID uint, username, role, tenantID, sessionID string, remember bool) (string, error) {
+	accessTTL, _ := SessionLifetimes(remember)
+	return signToken(Claims{
+		UserID:    userID,
+		Username:  username,
+		Role:      role,
+		TokenType: TokenTypeAccess,
+		Remember:  remember,
+		TenantID:  tenantID,
+		SessionID: sessionID,
+	}, accessTTL)
 }
 
 // GenerateRefreshToken 生成刷新token
-func GenerateRefreshToken(userID uint) (string, error) {
-	claims := jwt.RegisteredClaims{
+func GenerateRefreshToken(userID uint, tenantID, sessionID string, remember bool) (string, error) {
+	_, refreshTTL := SessionLifetimes(remember)
+	return signToken(Claims{
+		UserID:    userID,
+		TokenType: TokenTypeRefresh,
+		Remember:  remember,
+		TenantID:  tenantID,
+		SessionID: sessionID,
+	}, refreshTTL)
+}
+
+// signToken 填充标准声明并签名，每个token带随机ID，保证同一秒内签发的token也互不相同
+func signToken(claims Claims, ttl time.Duration) (string, error) {
+	id, err := randomID()
+	if err != nil {
+		return "", err
+	}
+	
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        id,
 		Issuer:    config.AppConfig.JWT.Issuer,
-		Subject:   string(rune(userID)),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(config.AppConfig.JWT.RefreshExpires)),
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		NotBefore: jwt.NewNumericDate(time.Now()),
+		Subject:   strconv.FormatUint(uint64(claims.UserID), 10),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+	}
+	if audience := config.AppConfig.JWT.Audience; audience != "" {
+		claims.Audience = jwt.ClaimStrings{audience}
 	}
 	
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(config.AppConfig.JWT.Secret))
 }
 
+// randomID 生成128位随机ID的十六进制表示
+func randomID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
 // ParseToken 解析JWT token
 func ParseToken(tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
@@ -60,11 +118,35 @@ func ParseToken(tokenString string) (*Claims, error) {
 		return nil, err
 	}
 	
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	if err := verifyAudience(claims); err != nil {
+		return nil, err
 	}
 	
-	return nil, jwt.ErrTokenInvalid
+	return claims, nil
+}
+
+// verifyAudience 校验token受众，未配置JWT_AUDIENCE时不校验，兼容单部署场景
+func verifyAudience(claims *Claims) error {
+	cfg := config.AppConfig.JWT
+	if cfg.Audience == "" {
+		return nil
+	}
+	if len(claims.Audience) == 0 {
+		if cfg.AllowMissingAudience {
+			return nil
+		}
+		return fmt.Errorf("%w: missing audience", jwt.ErrTokenInvalidAudience)
+	}
+	for _, aud := range claims.Audience {
+		if aud == cfg.Audience {
+			return nil
+		}
+	}
+	return jwt.ErrTokenInvalidAudience
 }
 
 // AuthRequired JWT认证中间件
@@ -88,10 +170,30 @@ func AuthRequired() gin.HandlerFunc {
 			return
 		}
 		
+		// refresh token只能用于刷新，不能访问接口
+		if claims.TokenType == TokenTypeRefresh {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Invalid token: refresh token cannot be used for authentication",
+			})
+			c.Abort()
+			return
+		}
+		
+		// 已登出或已被吊销（如账号注销）的token
+		if IsRevoked(c, token, claims) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Token has been revoked",
+			})
+			c.Abort()
+			return
+		}
+		
 		// 将用户信息存储到上下文
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
 		c.Set("role", claims.Role)
+		c.Set("tenant_id", claims.TenantID)
+		c.Set("session_id", claims.SessionID)
 		c.Set("claims", claims)
 		
 		c.Next()
@@ -104,10 +206,12 @@ func OptionalAuth() gin.HandlerFunc {
 		token := extractToken(c)
 		if token != "" {
 			claims, err := ParseToken(token)
-			if err == nil {
+			if err == nil && claims.TokenType != TokenTypeRefresh && !IsRevoked(c, token, claims) {
 				c.Set("user_id", claims.UserID)
 				c.Set("username", claims.Username)
 				c.Set("role", claims.Role)
+				c.Set("tenant_id", claims.TenantID)
+				c.Set("session_id", claims.SessionID)
 				c.Set("claims", claims)
 			}
 		}
@@ -115,11 +219,20 @@ func OptionalAuth() gin.HandlerFunc {
 	}
 }
 
-// AdminRequired 管理员权限中间件
-func AdminRequired() gin.HandlerFunc {
+// IsRevoked 检查token是否已被登出或吊销，或其所属会话已被吊销
+func IsRevoked(c *gin.Context, token string, claims *Claims) bool {
+	var issuedAt time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+	return database.IsTokenRevoked(c, token, claims.UserID, issuedAt) ||
+		!database.IsSessionActive(c, claims.SessionID)
+}
+
+// RequireCapability 权限项中间件，当前用户的角色须拥有指定权限项（管理员拥有全部权限）
+func RequireCapability(capability models.Capability) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		role, exists := c.Get("role")
-		if !exists {
+		if _, exists := c.Get("role"); !exists {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "Authentication required",
 			})
@@ -127,9 +240,10 @@ func AdminRequired() gin.HandlerFunc {
 			return
 		}
 		
-		if role != "admin" {
+		if !HasCapability(c, capability) {
 			c.JSON(http.StatusForbidden, gin.H{
-				"error": "Admin access required",
+				"error":   "Insufficient permissions",
+				"details": "requires " + string(capability),
 			})
 			c.Abort()
 			return
@@ -139,11 +253,22 @@ func AdminRequired() gin.HandlerFunc {
 	}
 }
 
+// OwnerLoader 加载资源并返回其拥有者ID
+type OwnerLoader func(c *gin.Context) (uint, error)
+
+var (
+	// ErrInvalidResourceID 资源ID格式错误
+	ErrInvalidResourceID = errors.New("invalid resource ID")
+	// ErrResourceNotFound 资源不存在
+	ErrResourceNotFound = errors.New("resource not found")
+)
+
 // OwnerOrAdminRequired 资源拥有者或管理员权限中间件
-func OwnerOrAdminRequired(ownerIDKey string) gin.HandlerFunc {
+// resource为资源名称（用于错误信息），loader负责根据请求加载资源的拥有者ID
+func OwnerOrAdminRequired(resource string, loader OwnerLoader) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		currentUserID, exists := c.Get("user_id")
-		if !exists {
+		currentUserID := GetUserID(c)
+		if currentUserID == 0 {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "Authentication required",
 			})
@@ -151,24 +276,42 @@ func OwnerOrAdminRequired(ownerIDKey string) gin.HandlerFunc {
 			return
 		}
 		
-		currentRole, _ := c.Get("role")
-		
-		// 管理员有所有权限
-		if currentRole == "admin" {
-			c.Next()
+		ownerID, err := loader(c)
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrInvalidResourceID):
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": "Invalid " + strings.ToLower(resource) + " ID",
+				})
+			case errors.Is(err, ErrResourceNotFound):
+				c.JSON(http.StatusNotFound, gin.H{
+					"error": resource + " not found",
+				})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Failed to load " + strings.ToLower(resource),
+				})
+			}
+			c.Abort()
 			return
 		}
 		
-		// 检查是否为资源拥有者
-		ownerID, exists := c.Get(ownerIDKey)
-		if !exists {
-			c.JSON(http.StatusForbidden, gin.H{
-				"error": "Access denied: owner information not found",
-			})
-			c.Abort()
+		c.Set("resource_owner_id", ownerID)
+		
+		// 管理员有所有权限；租户管理员只能管理本租户用户的资源
+		if IsAdmin(c) {
+			if !SameTenant(c, ownerID) {
+				c.JSON(http.StatusForbidden, gin.H{
+					"error": "Access denied: resource belongs to another tenant",
+				})
+				c.Abort()
+				return
+			}
+			c.Next()
 			return
 		}
 		
+		// 检查是否为资源拥有者
 		if currentUserID != ownerID {
 			c.JSON(http.StatusForbidden, gin.H{
 				"error": "Access denied: you can only access your own resources",
@@ -181,15 +324,85 @@ func OwnerOrAdminRequired(ownerIDKey string) gin.HandlerFunc {
 	}
 }
 
-// RateLimitByUser 按用户限流中间件
+// SameTenant 检查资源拥有者是否与当前token属于同一租户
+// 不带租户的token（平台级账号或单租户部署）不受限制
+func SameTenant(c *gin.Context, ownerID uint) bool {
+	tenantID := GetTenantID(c)
+	if tenantID == "" {
+		return true
+	}
+	
+	var ownerTenant string
+	err := database.GetDBWithContext(c.Request.Context()).
+		Table("users").
+		Where("id = ?", ownerID).
+		Select("tenant_id").
+		Scan(&ownerTenant).Error
+	return err == nil && ownerTenant == tenantID
+}
+
+// RateLimitByUser 按用户限流中间件（基于Redis的固定窗口计数，按路由分别计数）
+// 每个响应都带X-RateLimit-*头，客户端可在达到限制前自行降速；
+// Redis不可用时放行，避免限流组件故障影响正常请求；maxRequests<=0时不限制
 func RateLimitByUser(maxRequests int, window time.Duration) gin.HandlerFunc {
-	// 这里可以实现Redis-based的限流逻辑
 	return func(c *gin.Context) {
-		// TODO: 实现基于Redis的限流
+		userID := GetUserID(c)
+		if maxRequests <= 0 || userID == 0 || database.RedisClient == nil {
+			c.Next()
+			return
+		}
+		
+		cache := database.NewCache()
+		// 各API版本共用同一计数，避免通过切换版本绕过限制
+		key := database.Keys.RateLimit(userID, c.Request.Method+":"+UnversionedRoute(c.FullPath()))
+		count, err := cache.Incr(c, key)
+		if err != nil {
+			c.Next()
+			return
+		}
+		reset := window
+		if count == 1 {
+			cache.Expire(c, key, window)
+		} else if ttl, err := cache.TTL(c, key); err == nil && ttl > 0 {
+			reset = ttl
+		} else if err == nil && ttl == -1 {
+			// 首次请求设置过期时间失败时补设，避免计数永不重置
+			cache.Expire(c, key, window)
+		}
+		setRateLimitHeaders(c, maxRequests, maxRequests-int(count), reset)
+		
+		if count > int64(maxRequests) {
+			c.Header("Retry-After", strconv.Itoa(rateLimitSeconds(reset)))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error": "Rate limit exceeded",
+			})
+			c.Abort()
+			return
+		}
+		
 		c.Next()
 	}
 }
 
+// setRateLimitHeaders 设置限流状态响应头：窗口内允许的请求数、剩余次数（不小于0）和距窗口重置的秒数
+func setRateLimitHeaders(c *gin.Context, limit, remaining int, reset time.Duration) {
+	if remaining < 0 {
+		remaining = 0
+	}
+	c.Header("X-RateLimit-Limit", strconv.Itoa(limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	c.Header("X-RateLimit-Reset", strconv.Itoa(rateLimitSeconds(reset)))
+}
+
+// rateLimitSeconds 将剩余时长向上取整为秒，至少为1秒
+func rateLimitSeconds(d time.Duration) int {
+	seconds := int((d + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
+
 // extractToken 从请求中提取token
 func extractToken(c *gin.Context) string {
 	// 从Authorization header中提取
@@ -226,10 +439,19 @@ func GetCurrentUser(c *gin.Context) (uint, string, string, bool) {
 	return 0, "", "", false
 }
 
-// IsAdmin 检查当前用户是否为管理员
-func IsAdmin(c *gin.Context) bool {
+// HasCapability 检查当前用户的角色是否拥有指定权限项
+func HasCapability(c *gin.Context, capability models.Capability) bool {
 	role, exists := c.Get("role")
-	return exists && role == "admin"
+	if !exists {
+		return false
+	}
+	roleName, _ := role.(string)
+	return models.RoleHasCapability(roleName, capability)
+}
+
+// IsAdmin 检查当前用户是否可管理任意用户的资源（管理员）
+func IsAdmin(c *gin.Context) bool {
+	return HasCapability(c, models.CapManageResources)
 }
 
 // GetUserID 获取当前用户ID
@@ -238,4 +460,25 @@ func GetUserID(c *gin.Context) uint {
 		return userID.(uint)
 	}
 	return 0
-}
\ No newline at end of file
+}
+
+// IsAdminOf 检查当前用户是否为可管理该拥有者资源的管理员（同租户或平台级管理员）
+func IsAdminOf(c *gin.Context, ownerID uint) bool {
+	return IsAdmin(c) && SameTenant(c, ownerID)
+}
+
+// GetTenantID 获取当前token所属租户，单租户token返回空字符串
+func GetTenantID(c *gin.Context) string {
+	if tenantID, exists := c.Get("tenant_id"); exists {
+		return tenantID.(string)
+	}
+	return ""
+}
+
+// GetSessionID 获取当前token所属会话，旧token返回空字符串
+func GetSessionID(c *gin.Context) string {
+	if sessionID, exists := c.Get("session_id"); exists {
+		return sessionID.(string)
+	}
+	return ""
+}
@@ -0,0 +1,296 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/testutil"
+)
+
+func TestParseTokenAudience(t *testing.T) {
+	cfg := testutil.LoadConfig(t)
+
+	// 以某个受众签发token，再切换到当前部署的受众配置解析
+	issue := func(audience string) string {
+		t.Helper()
+		cfg.JWT.Audience = audience
+		token, err := GenerateToken(1, "user", "user", "acme", "session", false)
+		if err != nil {
+			t.Fatalf("GenerateToken() = %v", err)
+		}
+		return token
+	}
+	matching := issue("iot-acme")
+	other := issue("iot-globex")
+	legacy := issue("")
+
+	cfg.JWT.Audience = "iot-acme"
+	claims, err := ParseToken(matching)
+	if err != nil {
+		t.Fatalf("ParseToken(matching audience) = %v", err)
+	}
+	if claims.TenantID != "acme" {
+		t.Errorf("tenant = %q, want acme", claims.TenantID)
+	}
+	if _, err := ParseToken(other); !errors.Is(err, jwt.ErrTokenInvalidAudience) {
+		t.Errorf("ParseToken(other audience) = %v, want ErrTokenInvalidAudience", err)
+	}
+	if _, err := ParseToken(legacy); !errors.Is(err, jwt.ErrTokenInvalidAudience) {
+		t.Errorf("ParseToken(missing audience) = %v, want ErrTokenInvalidAudience", err)
+	}
+
+	// 过渡期内接受不带aud的旧token，但仍拒绝其他受众
+	cfg.JWT.AllowMissingAudience = true
+	if _, err := ParseToken(legacy); err != nil {
+		t.Errorf("ParseToken(missing audience) with AllowMissingAudience = %v", err)
+	}
+	if _, err := ParseToken(other); !errors.Is(err, jwt.ErrTokenInvalidAudience) {
+		t.Errorf("ParseToken(other audience) with AllowMissingAudience = %v, want ErrTokenInvalidAudience", err)
+	}
+
+	// 未配置受众时不校验，兼容单部署的token
+	cfg.JWT.Audience = ""
+	if _, err := ParseToken(other); err != nil {
+		t.Errorf("ParseToken() without a configured audience = %v", err)
+	}
+}
+
+func TestOwnerOrAdminRequired(t *testing.T) {
+	const ownerID uint = 7
+	loadOwner := func(c *gin.Context) (uint, error) {
+		switch c.Param("id") {
+		case "1":
+			return ownerID, nil
+		case "missing":
+			return 0, ErrResourceNotFound
+		case "bad":
+			return 0, ErrInvalidResourceID
+		}
+		return 0, errors.New("connection refused")
+	}
+
+	tests := []struct {
+		name     string
+		userID   uint
+		role     string
+		id       string
+		want     int
+		wantNext bool
+	}{
+		{"owner is allowed", ownerID, models.RoleUser, "1", http.StatusOK, true},
+		{"other user is denied", ownerID + 1, models.RoleUser, "1", http.StatusForbidden, false},
+		{"admin is allowed", ownerID + 1, models.RoleAdmin, "1", http.StatusOK, true},
+		{"anonymous request", 0, "", "1", http.StatusUnauthorized, false},
+		{"missing resource", ownerID, models.RoleUser, "missing", http.StatusNotFound, false},
+		{"invalid id", ownerID, models.RoleUser, "bad", http.StatusBadRequest, false},
+		{"loader failure", ownerID, models.RoleUser, "broken", http.StatusInternalServerError, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(func(c *gin.Context) {
+				if tt.userID != 0 {
+					c.Set("user_id", tt.userID)
+					c.Set("role", tt.role)
+				}
+			})
+			called := false
+			r.PUT("/devices/:id", OwnerOrAdminRequired("Device", loadOwner), func(c *gin.Context) {
+				called = true
+				if got := c.GetUint("resource_owner_id"); got != ownerID {
+					t.Errorf("resource_owner_id = %d, want %d", got, ownerID)
+				}
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("PUT", "/devices/"+tt.id, nil))
+			if w.Code != tt.want || called != tt.wantNext {
+				t.Errorf("status = %d, handler called = %v, want %d, %v", w.Code, called, tt.want, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestOwnerOrAdminRequiredTenantAdmin(t *testing.T) {
+	testutil.LoadConfig(t)
+	db := testutil.UseDB(t)
+
+	acme := models.User{Username: "acme-user", Email: "a@example.com", Phone: "1", Password: "x", TenantID: "acme"}
+	globex := models.User{Username: "globex-user", Email: "g@example.com", Phone: "2", Password: "x", TenantID: "globex"}
+	for _, user := range []*models.User{&acme, &globex} {
+		if err := db.Create(user).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+
+	// 租户管理员只能管理本租户用户的资源
+	for owner, want := range map[uint]int{acme.ID: http.StatusOK, globex.ID: http.StatusForbidden} {
+		r := gin.New()
+		r.Use(func(c *gin.Context) {
+			c.Set("user_id", uint(1000))
+			c.Set("role", models.RoleAdmin)
+			c.Set("tenant_id", "acme")
+		})
+		ownerID := owner
+		r.DELETE("/projects/:id", OwnerOrAdminRequired("Project", func(*gin.Context) (uint, error) { return ownerID, nil }), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("DELETE", "/projects/1", nil))
+		if w.Code != want {
+			t.Errorf("owner %d status = %d, want %d", owner, w.Code, want)
+		}
+	}
+}
+
+func TestRateLimitByUser(t *testing.T) {
+	mr := testutil.UseRedis(t)
+
+	newRouter := func(limit int) *gin.Engine {
+		r := gin.New()
+		r.Use(func(c *gin.Context) {
+			if id, err := strconv.ParseUint(c.GetHeader("X-Test-User"), 10, 32); err == nil {
+				c.Set("user_id", uint(id))
+			}
+		})
+		handler := func(c *gin.Context) { c.Status(http.StatusCreated) }
+		for _, version := range APIVersions {
+			r.POST("/api/"+version+"/projects/:id/fork", RateLimitByUser(limit, time.Hour), handler)
+		}
+		return r
+	}
+	post := func(r *gin.Engine, version, user, project string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/api/"+version+"/projects/"+project+"/fork", nil)
+		if user != "" {
+			req.Header.Set("X-Test-User", user)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	r := newRouter(2)
+	// 按路由模式计数：不同项目、不同API版本共用同一配额
+	if w := post(r, "v1", "1", "10"); w.Code != http.StatusCreated || w.Header().Get("X-RateLimit-Remaining") != "1" {
+		t.Fatalf("first request status = %d remaining = %q, want 201 and 1", w.Code, w.Header().Get("X-RateLimit-Remaining"))
+	}
+	if w := post(r, "v2", "1", "11"); w.Code != http.StatusCreated {
+		t.Fatalf("second request status = %d, want 201", w.Code)
+	}
+	w := post(r, "v1", "1", "12")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "3600" {
+		t.Errorf("third request status = %d Retry-After = %q, want 429 and 3600", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	// 其他用户和匿名请求不受影响
+	if w := post(r, "v1", "2", "10"); w.Code != http.StatusCreated {
+		t.Errorf("another user status = %d, want 201", w.Code)
+	}
+	if w := post(r, "v1", "", "10"); w.Code != http.StatusCreated {
+		t.Errorf("anonymous status = %d, want 201", w.Code)
+	}
+
+	// 窗口过后重新计数
+	mr.FastForward(time.Hour)
+	if w := post(r, "v1", "1", "10"); w.Code != http.StatusCreated {
+		t.Errorf("status after the window = %d, want 201", w.Code)
+	}
+
+	unlimited := newRouter(0)
+	for i := 0; i < 5; i++ {
+		if w := post(unlimited, "v1", "3", "10"); w.Code != http.StatusCreated || w.Header().Get("X-RateLimit-Limit") != "" {
+			t.Fatalf("unlimited request %d status = %d, want 201 without rate limit headers", i, w.Code)
+		}
+	}
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	mr := testutil.UseRedis(t)
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user_id", uint(1)) })
+	r.GET("/limited", RateLimitByUser(3, time.Minute), func(c *gin.Context) { c.Status(http.StatusOK) })
+	get := func() *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/limited", nil))
+		return w
+	}
+
+	// 每个响应都带限流头，剩余次数逐次递减到0
+	previousReset := 61
+	for i, want := range []struct {
+		status    int
+		remaining string
+	}{
+		{http.StatusOK, "2"},
+		{http.StatusOK, "1"},
+		{http.StatusOK, "0"},
+		{http.StatusTooManyRequests, "0"},
+	} {
+		if i == 2 {
+			mr.FastForward(20 * time.Second)
+		}
+		w := get()
+		if w.Code != want.status {
+			t.Fatalf("request %d status = %d, want %d", i+1, w.Code, want.status)
+		}
+		if got := w.Header().Get("X-RateLimit-Limit"); got != "3" {
+			t.Errorf("request %d X-RateLimit-Limit = %q, want 3", i+1, got)
+		}
+		if got := w.Header().Get("X-RateLimit-Remaining"); got != want.remaining {
+			t.Errorf("request %d X-RateLimit-Remaining = %q, want %s", i+1, got, want.remaining)
+		}
+		// 重置时间为窗口的剩余时长，不随请求延长
+		reset, err := strconv.Atoi(w.Header().Get("X-RateLimit-Reset"))
+		if err != nil || reset < 1 || reset > previousReset {
+			t.Errorf("request %d X-RateLimit-Reset = %q, want 1..%d", i+1, w.Header().Get("X-RateLimit-Reset"), previousReset)
+		}
+		previousReset = reset
+		if i >= 2 && reset > 40 {
+			t.Errorf("request %d X-RateLimit-Reset = %d after 20s, want at most 40", i+1, reset)
+		}
+		if want.status == http.StatusTooManyRequests && w.Header().Get("Retry-After") != w.Header().Get("X-RateLimit-Reset") {
+			t.Errorf("Retry-After = %q, want it to match X-RateLimit-Reset %q", w.Header().Get("Retry-After"), w.Header().Get("X-RateLimit-Reset"))
+		}
+	}
+
+	// 计数键缺少过期时间时补设，计数不会永不重置
+	keys := mr.Keys()
+	if len(keys) != 1 {
+		t.Fatalf("redis keys = %v, want a single rate limit counter", keys)
+	}
+	mr.Set(keys[0], "1")
+	if w := get(); w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Remaining") != "1" {
+		t.Errorf("status = %d remaining = %q, want 200 and 1", w.Code, w.Header().Get("X-RateLimit-Remaining"))
+	}
+	if ttl := mr.TTL(keys[0]); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("counter TTL = %v, want the window to be restored", ttl)
+	}
+}
+
+func TestRateLimitSeconds(t *testing.T) {
+	for d, want := range map[time.Duration]int{
+		0:                       1,
+		-time.Second:            1,
+		time.Millisecond:        1,
+		time.Second:             1,
+		time.Second + 1:         2,
+		59*time.Second + 999999: 60,
+		time.Hour:               3600,
+	} {
+		if got := rateLimitSeconds(d); got != want {
+			t.Errorf("rateLimitSeconds(%v) = %d, want %d", d, got, want)
+		}
+	}
+}
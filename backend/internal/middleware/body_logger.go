@@ -0,0 +1,241 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	
+	"github.com/gin-gonic/gin"
+)
+
+// redactedValue 敏感字段替换后的值
+const redactedValue = "[REDACTED]"
+
+// sensitiveKeyParts 字段名（不区分大小写）包含这些片段时视为敏感字段，"key"覆盖设备密钥、API密钥等
+var sensitiveKeyParts = []string{"password", "passwd", "token", "secret", "key", "authorization", "cookie"}
+
+// sensitiveJSONKey 无法完整解析（被截断）的JSON中按文本匹配敏感字段名及其后的冒号
+var sensitiveJSONKey = regexp.MustCompile(`(?i)"[^"]*(?:password|passwd|token|secret|key|authorization|cookie)[^"]*"\s*:\s*`)
+
+// isSensitiveKey 字段名是否为敏感字段
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// bodyLogMatcher 按路径前缀决定是否记录请求和响应体
+type bodyLogMatcher struct {
+	prefixes []string
+	maxBytes int
+}
+
+// matches 路径是否在需要记录请求体的前缀下
+func (m bodyLogMatcher) matches(path string) bool {
+	for _, prefix := range m.prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// cappedBuffer 只保留前limit字节，记录实际写入的总字节数
+type cappedBuffer struct {
+	buf   bytes.Buffer
+	limit int
+	total int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.total += len(p)
+	if remaining := b.limit - b.buf.Len(); remaining > 0 {
+		if len(p) > remaining {
+			b.buf.Write(p[:remaining])
+		} else {
+			b.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// truncated 是否有内容超出上限未被保留
+func (b *cappedBuffer) truncated() bool {
+	return b.total > b.buf.Len()
+}
+
+// teeReadCloser 处理器读取请求体时同时复制到缓冲区
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// bodyCaptureWriter 写出响应体时同时复制到缓冲区
+type bodyCaptureWriter struct {
+	gin.ResponseWriter
+	capture *cappedBuffer
+}
+
+func (w *bodyCaptureWriter) Write(data []byte) (int, error) {
+	w.capture.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *bodyCaptureWriter) WriteString(s string) (int, error) {
+	w.capture.Write([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// captureBodies 开始复制请求体和响应体，返回的函数在请求结束后生成脱敏后的日志内容
+func captureBodies(c *gin.Context, maxBytes int) func() (string, string) {
+	request := &cappedBuffer{limit: maxBytes}
+	if c.Request.Body != nil {
+		c.Request.Body = teeReadCloser{Reader: io.TeeReader(c.Request.Body, request), Closer: c.Request.Body}
+	}
+	response := &cappedBuffer{limit: maxBytes}
+	c.Writer = &bodyCaptureWriter{ResponseWriter: c.Writer, capture: response}
+	
+	return func() (string, string) {
+		return formatLoggedBody(request, c.Request.Header.Get("Content-Type")),
+			formatLoggedBody(response, c.Writer.Header().Get("Content-Type"))
+	}
+}
+
+// formatLoggedBody 将缓冲的内容脱敏后转为日志文本；非文本内容只记录长度
+func formatLoggedBody(body *cappedBuffer, contentType string) string {
+	if body.total == 0 {
+		return ""
+	}
+	if !isTextContent(contentType) {
+		return fmt.Sprintf("[%s body: %d bytes]", contentTypeLabel(contentType), body.total)
+	}
+	
+	text := redactBody(body.buf.Bytes(), contentType)
+	if body.truncated() {
+		text += fmt.Sprintf("...[truncated, %d bytes total]", body.total)
+	}
+	return text
+}
+
+// redactBody 脱敏敏感字段：表单按字段名替换，完整的JSON按字段名逐层替换，被截断或格式错误的内容按文本匹配字段名并替换其值
+func redactBody(data []byte, contentType string) string {
+	if strings.HasPrefix(strings.ToLower(contentType), "application/x-www-form-urlencoded") {
+		if values, err := url.ParseQuery(string(data)); err == nil {
+			for key := range values {
+				if isSensitiveKey(key) {
+					values[key] = []string{redactedValue}
+				}
+			}
+			return values.Encode()
+		}
+	}
+	
+	var parsed interface{}
+	if err := json.Unmarshal(data, &parsed); err == nil {
+		if redacted, err := json.Marshal(redactValue(parsed)); err == nil {
+			return string(redacted)
+		}
+	}
+	return redactJSONText(string(data))
+}
+
+// redactJSONText 按文本替换敏感字段的值，值可以是字符串、数字、布尔、null或嵌套的对象和数组，被截断的值一直替换到末尾
+func redactJSONText(text string) string {
+	var out strings.Builder
+	pos := 0
+	for pos < len(text) {
+		loc := sensitiveJSONKey.FindStringIndex(text[pos:])
+		if loc == nil {
+			break
+		}
+		start := pos + loc[1]
+		out.WriteString(text[pos:start])
+		out.WriteString(`"` + redactedValue + `"`)
+		pos = skipJSONValue(text, start)
+	}
+	out.WriteString(text[pos:])
+	return out.String()
+}
+
+// skipJSONValue 返回从start开始的JSON值结束后的位置，值不完整时返回文本末尾
+func skipJSONValue(text string, start int) int {
+	depth := 0
+	inString := false
+	for i := start; i < len(text); i++ {
+		ch := text[i]
+		if inString {
+			switch ch {
+			case '\\':
+				i++
+			case '"':
+				inString = false
+				if depth == 0 {
+					return i + 1
+				}
+			}
+			continue
+		}
+		switch ch {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+		case '}', ']':
+			// 顶层遇到外层对象或数组的结束符，说明标量值已结束
+			if depth == 0 {
+				return i
+			}
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		case ',', ' ', '\t', '\r', '\n':
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(text)
+}
+
+// redactValue 递归替换对象中敏感字段的值
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if isSensitiveKey(key) {
+				v[key] = redactedValue
+			} else {
+				v[key] = redactValue(item)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+	}
+	return value
+}
+
+// isTextContent 是否为可直接记录的文本内容（JSON、表单或纯文本）
+func isTextContent(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	return contentType == "" ||
+		strings.Contains(contentType, "json") ||
+		strings.HasPrefix(contentType, "text/") ||
+		strings.HasPrefix(contentType, "application/x-www-form-urlencoded")
+}
+
+// contentTypeLabel 日志中展示的内容类型，去掉参数部分
+func contentTypeLabel(contentType string) string {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return strings.TrimSpace(mediaType)
+}
@@ -0,0 +1,206 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRedactBodyJSON(t *testing.T) {
+	body := `{"username":"bob","password":"hunter2","profile":{"api_key":"k-1","city":"Hefei"},` +
+		`"devices":[{"device_id":"s-1","device_key":"dk-1"}],"pin_token":123456,"secret":{"a":"b"}}`
+	redacted := redactBody([]byte(body), "application/json")
+
+	var got map[string]interface{}
+	if err := json.Unmarshal([]byte(redacted), &got); err != nil {
+		t.Fatalf("redacted body is not valid JSON: %v\n%s", err, redacted)
+	}
+	profile := got["profile"].(map[string]interface{})
+	device := got["devices"].([]interface{})[0].(map[string]interface{})
+	checks := map[string]interface{}{
+		"username":          got["username"],
+		"password":          got["password"],
+		"profile.api_key":   profile["api_key"],
+		"profile.city":      profile["city"],
+		"devices.device_id": device["device_id"],
+		"devices.key":       device["device_key"],
+		"pin_token":         got["pin_token"],
+		"secret":            got["secret"],
+	}
+	want := map[string]interface{}{
+		"username":          "bob",
+		"password":          redactedValue,
+		"profile.api_key":   redactedValue,
+		"profile.city":      "Hefei",
+		"devices.device_id": "s-1",
+		"devices.key":       redactedValue,
+		"pin_token":         redactedValue,
+		"secret":            redactedValue,
+	}
+	for field, value := range want {
+		if checks[field] != value {
+			t.Errorf("%s = %v, want %v", field, checks[field], value)
+		}
+	}
+}
+
+func TestRedactBodyForm(t *testing.T) {
+	redacted := redactBody([]byte("username=bob&password=hunter2&Device_Key=dk-1&note=hi"), "application/x-www-form-urlencoded; charset=utf-8")
+	values, err := url.ParseQuery(redacted)
+	if err != nil {
+		t.Fatalf("redacted body is not a valid form: %v", err)
+	}
+	for field, want := range map[string]string{
+		"username":   "bob",
+		"password":   redactedValue,
+		"Device_Key": redactedValue,
+		"note":       "hi",
+	} {
+		if got := values.Get(field); got != want {
+			t.Errorf("%s = %q, want %q", field, got, want)
+		}
+	}
+}
+
+func TestRedactBodyTruncatedJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		want   string
+		leaked []string
+	}{
+		{
+			name: "string value",
+			body: `{"name":"bob","password":"hunter2","city":"He`,
+			want: `{"name":"bob","password":"[REDACTED]","city":"He`,
+		},
+		{
+			name: "number, boolean and null values",
+			body: `{"pin_token": 123456, "secret":true,"api_key":null,"count":3,"na`,
+			want: `{"pin_token": "[REDACTED]", "secret":"[REDACTED]","api_key":"[REDACTED]","count":3,"na`,
+		},
+		{
+			name:   "nested object and array",
+			body:   `{"credentials_secret":{"user":"bob","pass":"x,y}"},"keys":[1,[2,3]],"id":7,"x`,
+			want:   `{"credentials_secret":"[REDACTED]","keys":"[REDACTED]","id":7,"x`,
+			leaked: []string{"bob", "x,y"},
+		},
+		{
+			name: "value cut off mid-string",
+			body: `{"id":1,"refresh_token":"eyJhbGciOi`,
+			want: `{"id":1,"refresh_token":"[REDACTED]"`,
+		},
+		{
+			name:   "value cut off inside a nested object",
+			body:   `{"id":1,"device_key":{"value":"dk-1","rotated":`,
+			want:   `{"id":1,"device_key":"[REDACTED]"`,
+			leaked: []string{"dk-1"},
+		},
+		{
+			name: "escaped quotes inside the value",
+			body: `{"password":"a\"b","name":"bob","tail":"`,
+			want: `{"password":"[REDACTED]","name":"bob","tail":"`,
+		},
+		{
+			name: "last value in an object",
+			body: `{"a":{"token":42},"b":"c`,
+			want: `{"a":{"token":"[REDACTED]"},"b":"c`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := redactBody([]byte(tt.body), "application/json")
+			if got != tt.want {
+				t.Errorf("redactBody() = %s, want %s", got, tt.want)
+			}
+			for _, secret := range tt.leaked {
+				if strings.Contains(got, secret) {
+					t.Errorf("redactBody() = %s, leaked %q", got, secret)
+				}
+			}
+		})
+	}
+}
+
+func TestCappedBuffer(t *testing.T) {
+	buf := &cappedBuffer{limit: 8}
+	for _, chunk := range []string{"abc", "defgh", "ijk"} {
+		if n, err := buf.Write([]byte(chunk)); n != len(chunk) || err != nil {
+			t.Fatalf("Write(%q) = %d, %v, want the full length", chunk, n, err)
+		}
+	}
+	if got := buf.buf.String(); got != "abcdefgh" {
+		t.Errorf("kept %q, want the first 8 bytes", got)
+	}
+	if buf.total != 11 || !buf.truncated() {
+		t.Errorf("total = %d truncated = %v, want 11 and true", buf.total, buf.truncated())
+	}
+
+	exact := &cappedBuffer{limit: 3}
+	exact.Write([]byte("abc"))
+	if exact.truncated() {
+		t.Error("a body exactly at the limit is reported as truncated")
+	}
+}
+
+func TestFormatLoggedBody(t *testing.T) {
+	write := func(limit int, body string) *cappedBuffer {
+		buf := &cappedBuffer{limit: limit}
+		buf.Write([]byte(body))
+		return buf
+	}
+
+	tests := []struct {
+		name        string
+		buf         *cappedBuffer
+		contentType string
+		want        string
+	}{
+		{"empty body", write(64, ""), "application/json", ""},
+		{"binary body logs only its size", write(4, "\x89PNG\r\n"), "image/png; charset=binary", "[image/png body: 6 bytes]"},
+		{"complete JSON", write(64, `{"token":"t"}`), "application/json", `{"token":"[REDACTED]"}`},
+		{
+			"truncated JSON is redacted and marked",
+			write(24, `{"id":1,"password":"hunter2"}`),
+			"application/json",
+			`{"id":1,"password":"[REDACTED]"...[truncated, 29 bytes total]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatLoggedBody(tt.buf, tt.contentType); got != tt.want {
+				t.Errorf("formatLoggedBody() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCaptureBodies(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/api/v1/auth/login", strings.NewReader(`{"username":"bob","password":"hunter2"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	finish := captureBodies(c, 1024)
+	var req map[string]string
+	if err := c.ShouldBindJSON(&req); err != nil {
+		t.Fatalf("handler failed to read the request body: %v", err)
+	}
+	c.JSON(http.StatusOK, gin.H{"token": "jwt-token", "user": req["username"]})
+	requestBody, responseBody := finish()
+
+	if requestBody != `{"password":"[REDACTED]","username":"bob"}` {
+		t.Errorf("request body = %s", requestBody)
+	}
+	if responseBody != `{"token":"[REDACTED]","user":"bob"}` {
+		t.Errorf("response body = %s", responseBody)
+	}
+	if !strings.Contains(w.Body.String(), "jwt-token") {
+		t.Errorf("client response = %s, want the unredacted token", w.Body.String())
+	}
+}
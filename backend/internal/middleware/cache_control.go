@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	
+	"github.com/gin-gonic/gin"
+)
+
+// cacheControlNoStore 需要认证或与用户相关的响应不允许任何缓存
+const cacheControlNoStore = "no-store"
+
+// NoStore 默认禁止缓存API响应，公开只读路由再通过PublicCache放开
+func NoStore() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", cacheControlNoStore)
+		c.Next()
+	}
+}
+
+// PublicCache 允许浏览器和CDN缓存公开只读路由的成功响应maxAge时长，maxAge为0时要求每次重新验证；
+// 错误响应仍为no-store，避免短暂故障被缓存。CORS响应头随Origin变化，因此添加Vary: Origin
+func PublicCache(maxAge time.Duration) gin.HandlerFunc {
+	value := "no-cache"
+	if maxAge > 0 {
+		value = "public, max-age=" + strconv.Itoa(int(maxAge/time.Second))
+	}
+	
+	return func(c *gin.Context) {
+		original := c.Writer
+		c.Writer = &cacheControlWriter{ResponseWriter: original, value: value}
+		c.Next()
+		c.Writer = original
+	}
+}
+
+// cacheControlWriter 在写入状态码时按状态设置Cache-Control
+type cacheControlWriter struct {
+	gin.ResponseWriter
+	value string
+}
+
+func (w *cacheControlWriter) WriteHeader(code int) {
+	header := w.Header()
+	if code == http.StatusOK || code == http.StatusNotModified {
+		header.Set("Cache-Control", w.value)
+		addVary(header, "Origin")
+	} else {
+		header.Set("Cache-Control", cacheControlNoStore)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// addVary 向Vary头追加字段，已存在时不重复添加
+func addVary(header http.Header, field string) {
+	for _, value := range header.Values("Vary") {
+		for _, existing := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(existing), field) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", field)
+}
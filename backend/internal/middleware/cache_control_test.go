@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPublicCache(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxAge    time.Duration
+		status    int
+		vary      string
+		wantCache string
+		wantVary  string
+	}{
+		{"cached success", 5 * time.Minute, http.StatusOK, "", "public, max-age=300", "Origin"},
+		{"not modified", time.Minute, http.StatusNotModified, "", "public, max-age=60", "Origin"},
+		{"zero max-age revalidates", 0, http.StatusOK, "", "no-cache", "Origin"},
+		{"errors are not cached", time.Minute, http.StatusInternalServerError, "", "no-store", ""},
+		{"not found is not cached", time.Minute, http.StatusNotFound, "", "no-store", ""},
+		{"existing vary is kept", time.Minute, http.StatusOK, "Accept-Encoding", "public, max-age=60", "Accept-Encoding,Origin"},
+		{"vary is not duplicated", time.Minute, http.StatusOK, "accept-encoding, origin", "public, max-age=60", "accept-encoding, origin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(NoStore())
+			r.GET("/public", PublicCache(tt.maxAge), func(c *gin.Context) {
+				if tt.vary != "" {
+					c.Header("Vary", tt.vary)
+				}
+				c.JSON(tt.status, gin.H{"status": 1})
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", "/public", nil))
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if got := w.Header().Get("Cache-Control"); got != tt.wantCache {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.wantCache)
+			}
+			if got := strings.Join(w.Header().Values("Vary"), ","); got != tt.wantVary {
+				t.Errorf("Vary = %q, want %q", got, tt.wantVary)
+			}
+		})
+	}
+}
+
+func TestNoStore(t *testing.T) {
+	r := gin.New()
+	r.Use(NoStore())
+	r.GET("/private", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"status": 1}) })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/private", nil))
+	if got := w.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", got)
+	}
+	if got := w.Header().Get("Vary"); got != "" {
+		t.Errorf("Vary = %q, want none", got)
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+	
+	"github.com/gin-gonic/gin"
+)
+
+// RequireJSON 要求带请求体的请求使用JSON的Content-Type（application/json或+json后缀，如application/json-patch+json），否则返回415
+// 没有请求体的请求（如GET或空body的POST）不检查。exempt为不检查的路由（gin的完整路由模式，如/api/v1/devices/:id/data），
+// 用于接受多种编码的接口
+func RequireJSON(exempt ...string) gin.HandlerFunc {
+	skip := make(map[string]bool, len(exempt))
+	for _, route := range exempt {
+		skip[route] = true
+	}
+	
+	return func(c *gin.Context) {
+		if skip[c.FullPath()] || !hasRequestBody(c.Request) {
+			c.Next()
+			return
+		}
+		
+		if !isJSONContentType(c.GetHeader("Content-Type")) {
+			c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, gin.H{
+				"error":   "Unsupported Content-Type",
+				"details": "request body must be application/json",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// hasRequestBody 请求是否带有请求体，分块传输（长度未知）视为有请求体
+func hasRequestBody(req *http.Request) bool {
+	return req.Body != nil && req.Body != http.NoBody && req.ContentLength != 0
+}
+
+// isJSONContentType 是否为JSON媒体类型，忽略charset等参数
+func isJSONContentType(header string) bool {
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || (strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json"))
+}
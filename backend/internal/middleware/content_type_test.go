@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireJSON(t *testing.T) {
+	r := gin.New()
+	r.Use(RequireJSON("/upload/:kind"))
+	ok := func(c *gin.Context) { c.Status(http.StatusNoContent) }
+	r.POST("/items", ok)
+	r.GET("/items", ok)
+	r.POST("/upload/:kind", ok)
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        io.Reader
+		want        int
+	}{
+		{"json", "POST", "/items", "application/json", strings.NewReader(`{}`), http.StatusNoContent},
+		{"json with charset", "POST", "/items", "application/json; charset=utf-8", strings.NewReader(`{}`), http.StatusNoContent},
+		{"json suffix", "POST", "/items", "application/merge-patch+json", strings.NewReader(`{}`), http.StatusNoContent},
+		{"form", "POST", "/items", "application/x-www-form-urlencoded", strings.NewReader("a=1"), http.StatusUnsupportedMediaType},
+		{"plain text", "POST", "/items", "text/plain", strings.NewReader(`{}`), http.StatusUnsupportedMediaType},
+		{"json lookalike", "POST", "/items", "application/jsonx", strings.NewReader(`{}`), http.StatusUnsupportedMediaType},
+		{"non-application suffix", "POST", "/items", "text/foo+json", strings.NewReader(`{}`), http.StatusUnsupportedMediaType},
+		{"malformed header", "POST", "/items", "application/json; =", strings.NewReader(`{}`), http.StatusUnsupportedMediaType},
+		{"missing header", "POST", "/items", "", strings.NewReader(`{}`), http.StatusUnsupportedMediaType},
+		{"empty body", "POST", "/items", "", nil, http.StatusNoContent},
+		{"get without body", "GET", "/items", "text/plain", nil, http.StatusNoContent},
+		{"exempt route", "POST", "/upload/avatar", "multipart/form-data; boundary=x", strings.NewReader("--x--"), http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, tt.body)
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+
+	// 分块传输的请求体长度未知，同样检查
+	req := httptest.NewRequest("POST", "/items", io.NopCloser(strings.NewReader("a=1")))
+	req.ContentLength = -1
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("chunked form status = %d, want %d", w.Code, http.StatusUnsupportedMediaType)
+	}
+}
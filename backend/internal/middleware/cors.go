@@ -1,8 +1,6 @@
 package middleware
 
 import (
-	"time"
-	
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"iot-platform-backend/internal/config"
@@ -1,9 +1,14 @@
 package middleware
 
 import (
+	"crypto/rand"
 	"fmt"
 	"io"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 	
 	"github.com/gin-gonic/gin"
@@ -58,16 +63,98 @@ func Logger() gin.HandlerFunc {
 		Logger: logger,
 		SkipPaths: []string{
 			"/health",
+			"/healthz",
+			"/readyz",
 			"/metrics",
 		},
+		SampleRate:    config.AppConfig.Log.SampleRate,
+		SlowThreshold: config.AppConfig.Log.SlowThreshold,
+		RouteLevels:   config.AppConfig.Log.RouteLevels,
+		BodyPaths:     bodyLogPaths(logger),
+		BodyMaxBytes:  config.AppConfig.Log.BodyMaxBytes,
 	})
 }
 
+// bodyLogPaths 需要记录请求和响应体的路径前缀，只在debug模式下生效，避免生产环境日志泄露用户数据
+func bodyLogPaths(logger *logrus.Logger) []string {
+	paths := config.AppConfig.Log.BodyPaths
+	if len(paths) == 0 {
+		return nil
+	}
+	if !config.AppConfig.IsDevelopment() {
+		logger.Warn("LOG_BODY_PATHS ignored: body logging is only available when GIN_MODE=debug")
+		return nil
+	}
+	logger.Warnf("Body logging enabled for %s (sensitive fields redacted, bodies capped at %d bytes)",
+		strings.Join(paths, ", "), config.AppConfig.Log.BodyMaxBytes)
+	return paths
+}
+
 // LoggerConfig 日志中间件配置
 type LoggerConfig struct {
-	Logger     *logrus.Logger
-	SkipPaths  []string
-	TimeFormat string
+	Logger        *logrus.Logger
+	SkipPaths     []string
+	TimeFormat    string
+	SampleRate    int               // 成功请求每N条记录1条，<=1表示全部记录
+	SlowThreshold time.Duration     // 超过该耗时的请求不采样并以warn级别记录，0表示不启用
+	RouteLevels   map[string]string // 路由前缀 -> 成功请求的日志级别，*匹配一段路径
+	BodyPaths     []string          // 记录请求和响应体（敏感字段脱敏）的路径前缀，为空表示不记录
+	BodyMaxBytes  int               // 请求和响应体各自记录的最大字节数
+}
+
+// routeLogLevel 按路由前缀覆盖的日志级别
+type routeLogLevel struct {
+	segments []string
+	level    logrus.Level
+}
+
+// compileRouteLevels 解析路由级别配置，按前缀段数从长到短排序，最具体的规则优先
+func compileRouteLevels(routeLevels map[string]string) []routeLogLevel {
+	rules := make([]routeLogLevel, 0, len(routeLevels))
+	for route, name := range routeLevels {
+		level, err := logrus.ParseLevel(name)
+		if err != nil {
+			continue
+		}
+		rules = append(rules, routeLogLevel{segments: splitPath(route), level: level})
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return len(rules[i].segments) > len(rules[j].segments)
+	})
+	return rules
+}
+
+// matchRouteLevel 返回第一个前缀匹配path的规则级别
+func matchRouteLevel(rules []routeLogLevel, path string) (logrus.Level, bool) {
+	if len(rules) == 0 {
+		return 0, false
+	}
+	segments := splitPath(path)
+	for _, rule := range rules {
+		if len(rule.segments) > len(segments) {
+			continue
+		}
+		matched := true
+		for i, segment := range rule.segments {
+			if segment != "*" && segment != segments[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return rule.level, true
+		}
+	}
+	return 0, false
+}
+
+// splitPath 将路径拆分为段，忽略首尾的斜杠
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
 }
 
 // LoggerWithConfig 带配置的日志中间件
@@ -87,6 +174,14 @@ func LoggerWithConfig(config LoggerConfig) gin.HandlerFunc {
 		timeFormat = time.RFC3339
 	}
 	
+	sampleRate := uint64(1)
+	if config.SampleRate > 1 {
+		sampleRate = uint64(config.SampleRate)
+	}
+	var sampled uint64
+	routeLevels := compileRouteLevels(config.RouteLevels)
+	bodyLog := bodyLogMatcher{prefixes: config.BodyPaths, maxBytes: config.BodyMaxBytes}
+	
 	return gin.HandlerFunc(func(c *gin.Context) {
 		// 跳过某些路径的日志记录
 		if skipPaths[c.Request.URL.Path] {
@@ -98,11 +193,23 @@ func LoggerWithConfig(config LoggerConfig) gin.HandlerFunc {
 		path := c.Request.URL.Path
 		raw := c.Request.URL.RawQuery
 		
+		var bodies func() (string, string)
+		if bodyLog.maxBytes > 0 && bodyLog.matches(path) {
+			bodies = captureBodies(c, bodyLog.maxBytes)
+		}
+		
 		// 执行请求
 		c.Next()
 		
 		// 计算延迟
 		latency := time.Since(start)
+		status := c.Writer.Status()
+		slow := config.SlowThreshold > 0 && latency >= config.SlowThreshold
+		
+		// 成功且不慢的请求按比例采样，错误和慢请求总是记录
+		if status < 400 && !slow && atomic.AddUint64(&sampled, 1)%sampleRate != 0 {
+			return
+		}
 		
 		// 构建日志字段
 		fields := logrus.Fields{
@@ -138,8 +245,18 @@ func LoggerWithConfig(config LoggerConfig) gin.HandlerFunc {
 			fields["errors"] = c.Errors.String()
 		}
 		
+		// 添加脱敏后的请求和响应体（仅debug模式下配置的路径）
+		if bodies != nil {
+			requestBody, responseBody := bodies()
+			if requestBody != "" {
+				fields["request_body"] = requestBody
+			}
+			if responseBody != "" {
+				fields["response_body"] = responseBody
+			}
+		}
+		
 		// 根据状态码选择日志级别
-		status := c.Writer.Status()
 		msg := fmt.Sprintf("%s %s", c.Request.Method, path)
 		
 		switch {
@@ -147,8 +264,19 @@ func LoggerWithConfig(config LoggerConfig) gin.HandlerFunc {
 			logger.WithFields(fields).Error(msg)
 		case status >= 400:
 			logger.WithFields(fields).Warn(msg)
+		case slow:
+			fields["slow"] = true
+			logger.WithFields(fields).Warn(msg)
 		default:
-			logger.WithFields(fields).Info(msg)
+			// 被采样的记录带上采样率，便于统计时还原请求量
+			if sampleRate > 1 {
+				fields["sample_rate"] = sampleRate
+			}
+			level := logrus.InfoLevel
+			if routeLevel, ok := matchRouteLevel(routeLevels, path); ok {
+				level = routeLevel
+			}
+			logger.WithFields(fields).Log(level, msg)
 		}
 	})
 }
@@ -170,4 +298,17 @@ func RequestID() gin.HandlerFunc {
 // generateRequestID 生成请求ID
 func generateRequestID() string {
 	return fmt.Sprintf("%d-%s", time.Now().UnixNano(), generateRandomString(8))
+}
+
+// generateRandomString 生成随机字符串
+func generateRandomString(length int) string {
+	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	b := make([]byte, length)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	for i := range b {
+		b[i] = charset[int(b[i])%len(charset)]
+	}
+	return string(b)
 }
\ No newline at end of file
@@ -0,0 +1,229 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	
+	"github.com/gin-gonic/gin"
+)
+
+// timeoutBufferLimit 超时中间件最多缓冲的响应体大小，超出后直接写出，之后不再能替换为504
+const timeoutBufferLimit = 64 << 10
+
+// timeoutResponse 超时时返回给客户端的响应体
+var timeoutResponse = []byte(`{"error":"Request timed out"}`)
+
+// Timeout 请求超时中间件
+// 为请求上下文设置截止时间并在独立的goroutine中执行后续处理器，到达截止时间时立即向客户端返回504，
+// 处理器此后的写入被丢弃。处理器需通过c.Request.Context()将截止时间传递给数据库等调用，
+// 未感知上下文的操作（Redis、CPU计算等）仍会继续执行直到结束，其副作用不会回滚；
+// 为保证gin.Context在处理器结束前不被回收，中间件本身会等待处理器返回后才返回。
+// 响应在处理器结束前先写入缓冲区；处理器调用Flush（流式输出）或响应体超过timeoutBufferLimit时直接写出，
+// 此后超时只会取消上下文，无法再返回504。timeout<=0时不做限制
+func Timeout(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+		
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		
+		original := c.Writer
+		writer := newTimeoutWriter(original)
+		c.Writer = writer
+		
+		done := make(chan struct{})
+		var panicValue interface{}
+		go func() {
+			defer func() {
+				panicValue = recover()
+				close(done)
+			}()
+			c.Next()
+		}()
+		
+		select {
+		case <-done:
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				writer.timeout()
+			}
+			<-done
+		}
+		
+		c.Writer = original
+		// 处理器的panic交给外层的Recovery中间件处理
+		if panicValue != nil {
+			panic(panicValue)
+		}
+		writer.flush()
+	}
+}
+
+// timeoutWriter 在处理器结束前缓冲响应，所有方法都可被处理器goroutine和超时处理并发调用
+type timeoutWriter struct {
+	gin.ResponseWriter
+	
+	mu        sync.Mutex
+	header    http.Header
+	body      bytes.Buffer
+	status    int
+	committed bool // 响应已直接写出到原始writer
+	timedOut  bool // 已返回504，丢弃处理器后续的写入
+}
+
+func newTimeoutWriter(w gin.ResponseWriter) *timeoutWriter {
+	return &timeoutWriter{
+		ResponseWriter: w,
+		header:         w.Header().Clone(),
+	}
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	
+	if w.timedOut || w.status != 0 {
+		return
+	}
+	w.status = code
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	w.WriteHeader(http.StatusOK)
+}
+
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.committed {
+		return w.ResponseWriter.Write(data)
+	}
+	
+	n, err := w.body.Write(data)
+	if w.body.Len() > timeoutBufferLimit {
+		w.commitLocked()
+	}
+	return n, err
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush 流式输出时直接写出已缓冲的内容
+func (w *timeoutWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	
+	if w.timedOut {
+		return
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.commitLocked()
+	w.ResponseWriter.Flush()
+}
+
+func (w *timeoutWriter) Status() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *timeoutWriter) Size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	
+	if w.committed {
+		return w.ResponseWriter.Size()
+	}
+	if w.status == 0 {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *timeoutWriter) Written() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	
+	return w.status != 0
+}
+
+// commitLocked 将缓冲的响应头和响应体写出，之后的写入直接透传（调用方需持有w.mu）
+func (w *timeoutWriter) commitLocked() {
+	if w.committed {
+		return
+	}
+	w.committed = true
+	
+	dst := w.ResponseWriter.Header()
+	for key := range dst {
+		if _, ok := w.header[key]; !ok {
+			dst.Del(key)
+		}
+	}
+	for key, values := range w.header {
+		dst[key] = values
+	}
+	
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(w.body.Bytes())
+	w.body.Reset()
+}
+
+// timeout 响应尚未写出时立即返回504并发送给客户端
+func (w *timeoutWriter) timeout() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	
+	if w.committed || w.timedOut {
+		return
+	}
+	w.timedOut = true
+	w.body.Reset()
+	
+	// 设置Content-Length，客户端无需等待处理器结束即可读完响应
+	header := w.ResponseWriter.Header()
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	header.Set("Content-Length", strconv.Itoa(len(timeoutResponse)))
+	w.ResponseWriter.WriteHeader(http.StatusGatewayTimeout)
+	w.ResponseWriter.Write(timeoutResponse)
+	w.ResponseWriter.Flush()
+}
+
+// flush 处理器结束后写出缓冲的响应
+func (w *timeoutWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	
+	if w.timedOut || w.status == 0 {
+		return
+	}
+	w.commitLocked()
+}
@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestTimeoutReturnsAtDeadline(t *testing.T) {
+	var lateWrite atomic.Value
+	handlerDone := make(chan struct{})
+
+	router := gin.New()
+	router.Use(gin.Recovery(), Timeout(50*time.Millisecond))
+	router.GET("/slow", func(c *gin.Context) {
+		defer close(handlerDone)
+		// 不感知上下文的慢操作
+		time.Sleep(400 * time.Millisecond)
+		_, err := c.Writer.WriteString(`{"status":1}`)
+		lateWrite.Store(err)
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	start := time.Now()
+	resp, err := http.Get(server.URL + "/slow")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	elapsed := time.Since(start)
+
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Fatalf("status = %d, want 504", resp.StatusCode)
+	}
+	if elapsed > 300*time.Millisecond {
+		t.Errorf("response took %v, want it at the 50ms deadline rather than after the handler", elapsed)
+	}
+	if !strings.Contains(string(body), "timed out") {
+		t.Errorf("body = %q", body)
+	}
+
+	<-handlerDone
+	if err, _ := lateWrite.Load().(error); err != http.ErrHandlerTimeout {
+		t.Errorf("write after timeout returned %v, want ErrHandlerTimeout", err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    gin.HandlerFunc
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name: "fast handler keeps its response",
+			handler: func(c *gin.Context) {
+				c.Header("X-Handler", "yes")
+				c.JSON(http.StatusCreated, gin.H{"status": 1})
+			},
+			wantStatus: http.StatusCreated,
+			wantBody:   `{"status":1}`,
+		},
+		{
+			name: "context-aware handler is cancelled",
+			handler: func(c *gin.Context) {
+				<-c.Request.Context().Done()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "cancelled"})
+			},
+			wantStatus: http.StatusGatewayTimeout,
+			wantBody:   "timed out",
+		},
+		{
+			name: "streamed response is not replaced",
+			handler: func(c *gin.Context) {
+				c.Status(http.StatusOK)
+				c.Writer.WriteString("partial")
+				c.Writer.Flush()
+				time.Sleep(100 * time.Millisecond)
+				c.Writer.WriteString("-rest")
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   "partial-rest",
+		},
+		{
+			name: "panic reaches recovery",
+			handler: func(c *gin.Context) {
+				panic("boom")
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, _ interface{}) {
+				c.AbortWithStatus(http.StatusInternalServerError)
+			}), Timeout(30*time.Millisecond))
+			router.GET("/", tt.handler)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %q)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body = %q, want it to contain %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestTimeoutDisabled(t *testing.T) {
+	router := gin.New()
+	router.Use(Timeout(0))
+	router.GET("/", func(c *gin.Context) {
+		if _, ok := c.Request.Context().Deadline(); ok {
+			t.Error("deadline set although timeout is disabled")
+		}
+		c.String(http.StatusOK, "ok")
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+}
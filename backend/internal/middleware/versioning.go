@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+	
+	"github.com/gin-gonic/gin"
+	"iot-platform-backend/internal/config"
+)
+
+// APIVersions 提供的API版本，按从旧到新排列，最后一个为当前版本
+var APIVersions = []string{"v1", "v2"}
+
+// APIVersion 在响应头X-API-Version中标明处理请求的API版本
+func APIVersion(version string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("X-API-Version", version)
+		c.Next()
+	}
+}
+
+// deprecatedRoute 计划下线的路由
+type deprecatedRoute struct {
+	sunset time.Time // 下线时间，零值表示尚未确定
+}
+
+// Deprecation 为计划下线的路由添加Deprecation头，确定了下线时间的再添加Sunset头（RFC 8594），
+// 并通过Link头指向当前版本的同一地址（各版本共用路由，替换过的路由应在新版本中保持相同路径）。
+// routes的键为gin的完整路由模式，可带请求方法前缀（如"GET /api/v1/projects/:id"，不带方法时匹配所有方法），
+// 值为下线日期（2006-01-02或RFC 3339格式），为空表示只标记弃用；格式错误的项在配置校验时报告
+func Deprecation(routes map[string]string) gin.HandlerFunc {
+	deprecated := make(map[string]deprecatedRoute, len(routes))
+	for route, date := range routes {
+		sunset, _ := config.ParseSunset(date)
+		deprecated[route] = deprecatedRoute{sunset: sunset}
+	}
+	
+	return func(c *gin.Context) {
+		route, ok := deprecated[c.Request.Method+" "+c.FullPath()]
+		if !ok {
+			route, ok = deprecated[c.FullPath()]
+		}
+		if !ok {
+			c.Next()
+			return
+		}
+		
+		c.Header("Deprecation", "true")
+		if !route.sunset.IsZero() {
+			c.Header("Sunset", route.sunset.UTC().Format(http.TimeFormat))
+		}
+		if successor := successorPath(c.Request.URL.Path); successor != "" {
+			c.Header("Link", "<"+successor+`>; rel="successor-version"`)
+		}
+		c.Next()
+	}
+}
+
+// successorPath 将旧版本的请求地址替换为当前版本，请求已是当前版本时返回空字符串
+func successorPath(path string) string {
+	current := APIVersions[len(APIVersions)-1]
+	version, rest := splitAPIVersion(path)
+	if version == "" || version == current {
+		return ""
+	}
+	return "/api/" + current + rest
+}
+
+// RequestAPIVersion 请求所属的API版本，不在版本化路径下时返回最早的版本
+func RequestAPIVersion(c *gin.Context) string {
+	if version, _ := splitAPIVersion(c.Request.URL.Path); version != "" {
+		return version
+	}
+	return APIVersions[0]
+}
+
+// UnversionedRoute 去掉路由中的/api/<版本>前缀，用于在各版本间共享按路由计数的限流等状态
+func UnversionedRoute(route string) string {
+	if version, rest := splitAPIVersion(route); version != "" {
+		return rest
+	}
+	return route
+}
+
+// splitAPIVersion 拆分/api/<版本>前缀，路径不属于已知版本时返回空版本
+func splitAPIVersion(path string) (string, string) {
+	rest, ok := strings.CutPrefix(path, "/api/")
+	if !ok {
+		return "", path
+	}
+	version, tail, _ := strings.Cut(rest, "/")
+	for _, known := range APIVersions {
+		if version == known {
+			if tail == "" {
+				return version, ""
+			}
+			return version, "/" + tail
+		}
+	}
+	return "", path
+}
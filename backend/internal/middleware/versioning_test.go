@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDeprecation(t *testing.T) {
+	r := gin.New()
+	r.Use(Deprecation(map[string]string{
+		"DELETE /api/v1/items/:id": "2027-01-31T08:00:00+08:00",
+		"/api/v1/legacy":           "",
+	}))
+	ok := func(c *gin.Context) { c.Status(http.StatusNoContent) }
+	r.GET("/api/v1/items/:id", ok)
+	r.DELETE("/api/v1/items/:id", ok)
+	r.POST("/api/v1/legacy", ok)
+	r.GET("/api/v2/legacy", ok)
+
+	tests := []struct {
+		method, path                   string
+		deprecation, sunset, successor string
+	}{
+		{"DELETE", "/api/v1/items/7", "true", "Sun, 31 Jan 2027 00:00:00 GMT", `</api/v2/items/7>; rel="successor-version"`},
+		{"GET", "/api/v1/items/7", "", "", ""},
+		{"POST", "/api/v1/legacy", "true", "", `</api/v2/legacy>; rel="successor-version"`},
+		{"GET", "/api/v2/legacy", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			for header, want := range map[string]string{
+				"Deprecation": tt.deprecation,
+				"Sunset":      tt.sunset,
+				"Link":        tt.successor,
+			} {
+				if got := w.Header().Get(header); got != want {
+					t.Errorf("%s = %q, want %q", header, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitAPIVersion(t *testing.T) {
+	tests := []struct {
+		path, version, rest string
+	}{
+		{"/api/v1/devices/7", "v1", "/devices/7"},
+		{"/api/v2", "v2", ""},
+		{"/api/v9/devices", "", "/api/v9/devices"},
+		{"/healthz", "", "/healthz"},
+	}
+	for _, tt := range tests {
+		if version, rest := splitAPIVersion(tt.path); version != tt.version || rest != tt.rest {
+			t.Errorf("splitAPIVersion(%q) = %q, %q, want %q, %q", tt.path, version, rest, tt.version, tt.rest)
+		}
+	}
+	if got := UnversionedRoute("/api/v2/devices/:id"); got != "/devices/:id" {
+		t.Errorf("UnversionedRoute() = %q, want /devices/:id", got)
+	}
+}
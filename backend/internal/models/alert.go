@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+	
+	"github.com/lib/pq"
+)
+
+// 告警级别
+const (
+	AlertSeverityWarning  = "warning"  // 读数超出字段合理范围
+	AlertSeverityCritical = "critical" // 读数质量评估为bad（如故障哨兵值、类型错误）
+)
+
+// Alert 设备告警，读数超出字段合理范围时产生（与alert.fired事件的条件一致），维护中的设备不产生告警
+type Alert struct {
+	ID             uint           `json:"id" gorm:"primarykey"`
+	DeviceID       string         `json:"device_id" gorm:"not null;index:idx_alerts_device_time,priority:1"`
+	Severity       string         `json:"severity" gorm:"not null;index"`
+	Fields         pq.StringArray `json:"fields" gorm:"type:text[]"` // 超出范围的字段
+	Reading        JSONB          `json:"reading" gorm:"type:jsonb"`  // 触发告警的读数
+	ReadingAt      time.Time      `json:"reading_at"`
+	AcknowledgedBy *uint          `json:"acknowledged_by"`
+	AcknowledgedAt *time.Time     `json:"acknowledged_at" gorm:"index"` // 为空表示未确认
+	CreatedAt      time.Time      `json:"created_at" gorm:"index;index:idx_alerts_device_time,priority:2,sort:desc"`
+}
+
+// TableName 指定表名
+func (Alert) TableName() string {
+	return "alerts"
+}
+
+// IsAcknowledged 是否已确认
+func (a *Alert) IsAcknowledged() bool {
+	return a.AcknowledgedAt != nil
+}
+
+// NewReadingAlert 根据读数生成告警，设备维护中或读数未超出范围时返回nil
+func NewReadingAlert(device Device, reading SensorData) *Alert {
+	if device.InMaintenance(time.Now()) {
+		return nil
+	}
+	meta, ok := GetDeviceTypeMeta(device.Type)
+	if !ok {
+		return nil
+	}
+	violations := meta.Violations(reading.Data)
+	if len(violations) == 0 {
+		return nil
+	}
+	
+	fields := make([]string, len(violations))
+	for i, violation := range violations {
+		fields[i] = violation.Key
+	}
+	severity := AlertSeverityWarning
+	if reading.Quality == QualityBad {
+		severity = AlertSeverityCritical
+	}
+	return &Alert{
+		DeviceID:  device.DeviceID,
+		Severity:  severity,
+		Fields:    pq.StringArray(fields),
+		Reading:   reading.Data,
+		ReadingAt: reading.Timestamp,
+	}
+}
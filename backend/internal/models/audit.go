@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// AuditLog 操作审计记录
+type AuditLog struct {
+	ID         uint      `json:"id" gorm:"primarykey"`
+	ActorID    uint      `json:"actor_id" gorm:"index"` // 执行操作的用户ID
+	Action     string    `json:"action" gorm:"not null;index"` // account_delete等
+	Resource   string    `json:"resource" gorm:"index"`        // user, project, device
+	ResourceID uint      `json:"resource_id"`
+	Details    JSONB     `json:"details" gorm:"type:jsonb"`
+	IPAddress  string    `json:"ip_address"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+}
+
+// TableName 指定表名
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
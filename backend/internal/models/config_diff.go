@@ -0,0 +1,105 @@
+package models
+
+import (
+	"sort"
+	"strings"
+)
+
+// ConfigChange 配置差异中的一项，Path为RFC 6901 JSON Pointer
+type ConfigChange struct {
+	Path        string      `json:"path"`
+	Old         interface{} `json:"old,omitempty"`
+	New         interface{} `json:"new,omitempty"`
+	TypeChanged bool        `json:"type_changed,omitempty"` // 两侧类型不同（如对象与数组），无法逐键比较
+}
+
+// ConfigDiff 两份配置的结构化差异，各列表按路径排序
+type ConfigDiff struct {
+	Added   []ConfigChange `json:"added"`
+	Removed []ConfigChange `json:"removed"`
+	Changed []ConfigChange `json:"changed"`
+}
+
+// Empty 两份配置是否相同
+func (d ConfigDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffConfig 计算head相对base的差异：对象逐键递归比较，数组和标量按整体值比较
+func DiffConfig(base, head JSONB) ConfigDiff {
+	diff := ConfigDiff{
+		Added:   make([]ConfigChange, 0),
+		Removed: make([]ConfigChange, 0),
+		Changed: make([]ConfigChange, 0),
+	}
+	diffObjects("", map[string]interface{}(base), map[string]interface{}(head), &diff)
+	
+	for _, list := range [][]ConfigChange{diff.Added, diff.Removed, diff.Changed} {
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].Path < list[j].Path
+		})
+	}
+	return diff
+}
+
+// diffObjects 逐键比较两个对象
+func diffObjects(prefix string, base, head map[string]interface{}, diff *ConfigDiff) {
+	for key, headValue := range head {
+		path := prefix + "/" + escapePointerToken(key)
+		baseValue, ok := base[key]
+		if !ok {
+			diff.Added = append(diff.Added, ConfigChange{Path: path, New: headValue})
+			continue
+		}
+		diffValues(path, baseValue, headValue, diff)
+	}
+	for key, baseValue := range base {
+		if _, ok := head[key]; !ok {
+			diff.Removed = append(diff.Removed, ConfigChange{Path: prefix + "/" + escapePointerToken(key), Old: baseValue})
+		}
+	}
+}
+
+// diffValues 比较同一路径上的两个值
+func diffValues(path string, base, head interface{}, diff *ConfigDiff) {
+	baseObject, baseIsObject := asConfigMap(base)
+	headObject, headIsObject := asConfigMap(head)
+	if baseIsObject && headIsObject {
+		diffObjects(path, map[string]interface{}(baseObject), map[string]interface{}(headObject), diff)
+		return
+	}
+	
+	if patchValuesEqual(base, head) {
+		return
+	}
+	diff.Changed = append(diff.Changed, ConfigChange{
+		Path:        path,
+		Old:         base,
+		New:         head,
+		TypeChanged: configValueKind(base) != configValueKind(head),
+	})
+}
+
+// configValueKind 配置值的JSON类型
+func configValueKind(value interface{}) string {
+	if _, ok := asConfigMap(value); ok {
+		return "object"
+	}
+	switch value.(type) {
+	case nil:
+		return "null"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	default:
+		return "number"
+	}
+}
+
+// escapePointerToken 按RFC 6901转义JSON Pointer中的键
+func escapePointerToken(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
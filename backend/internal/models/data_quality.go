@@ -0,0 +1,104 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+)
+
+// 传感器读数质量标记
+const (
+	QualityGood    = "good"    // 所有已知字段都在合理范围内
+	QualitySuspect = "suspect" // 有字段略超出合理范围或枚举值未知，可能是漂移或新固件
+	QualityBad     = "bad"     // 有字段远超合理范围、为非数值或为常见的故障哨兵值（如-9999）
+)
+
+// qualityTolerance 超出合理范围不超过区间宽度的该比例时视为suspect，更远视为bad
+const qualityTolerance = 0.1
+
+// ValidQuality 是否为合法的质量标记
+func ValidQuality(quality string) bool {
+	return quality == QualityGood || quality == QualitySuspect || quality == QualityBad
+}
+
+// AssessQuality 根据设备类型的字段范围评估一条读数的质量，返回整体标记和异常字段（按键名排序）
+// 只检查元数据中定义的字段，未知字段和缺失字段不影响结果；读数本身不做修改
+func AssessQuality(deviceType DeviceType, data JSONB) (string, []string) {
+	quality := QualityGood
+	var issues []string
+	
+	for _, field := range DeviceTypeFields[deviceType] {
+		value, ok := data[field.Key]
+		if !ok || value == nil {
+			continue
+		}
+		
+		fieldQuality := assessField(field, value)
+		if fieldQuality == QualityGood {
+			continue
+		}
+		issues = append(issues, field.Key)
+		if fieldQuality == QualityBad || quality == QualityGood {
+			quality = fieldQuality
+		}
+	}
+	
+	sort.Strings(issues)
+	return quality, issues
+}
+
+// assessField 评估单个字段的取值
+func assessField(field FieldSpec, value interface{}) string {
+	if field.Type == "enum" {
+		text, ok := value.(string)
+		if !ok {
+			return QualityBad
+		}
+		for _, allowed := range field.Values {
+			if text == allowed {
+				return QualityGood
+			}
+		}
+		return QualitySuspect
+	}
+	
+	number, ok := qualityNumber(value)
+	if !ok || math.IsNaN(number) || math.IsInf(number, 0) {
+		return QualityBad
+	}
+	if field.Min == nil || field.Max == nil {
+		return QualityGood
+	}
+	
+	min, max := *field.Min, *field.Max
+	if number >= min && number <= max {
+		return QualityGood
+	}
+	
+	margin := (max - min) * qualityTolerance
+	if number >= min-margin && number <= max+margin {
+		return QualitySuspect
+	}
+	return QualityBad
+}
+
+// qualityNumber 将JSON、msgpack或CBOR解码得到的数值（以及从数据库读取的json.Number）统一为float64
+func qualityNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case json.Number:
+		number, err := v.Float64()
+		return number, err == nil
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
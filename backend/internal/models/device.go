@@ -2,9 +2,20 @@ package models
 
 import (
 	"time"
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
 	"database/sql/driver"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"sort"
+	"strings"
+	
+	"github.com/lib/pq"
+	"gorm.io/gorm"
 )
 
 // DeviceType 设备类型枚举
@@ -42,9 +53,34 @@ var DeviceTypeNames = map[DeviceType]string{
 	PlantGrowth:     "植物生长记录仪",
 }
 
+// ErrInvalidDeviceType 设备类型不在DeviceTypeNames中
+var ErrInvalidDeviceType = errors.New("invalid device type")
+
+// Valid 是否为已知的设备类型，DeviceTypeNames是合法类型的唯一来源
+func (t DeviceType) Valid() bool {
+	_, ok := DeviceTypeNames[t]
+	return ok
+}
+
+// ValidDeviceTypes 按ID排序的所有合法设备类型
+func ValidDeviceTypes() []DeviceType {
+	types := make([]DeviceType, 0, len(DeviceTypeNames))
+	for deviceType := range DeviceTypeNames {
+		types = append(types, deviceType)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return types[i] < types[j]
+	})
+	return types
+}
+
 // JSONB 自定义类型用于存储JSON数据
 type JSONB map[string]interface{}
 
+// JSONBUseNumber 从数据库读取JSONB时数值是否解码为json.Number（由DB_JSON_USE_NUMBER配置）
+// 开启后整数原样保留，大于2^53的int64（如设备序号、计数器）不会因转为float64而丢失精度，重新序列化后与写入时一致
+var JSONBUseNumber = true
+
 // Value 实现driver.Valuer接口
 func (j JSONB) Value() (driver.Value, error) {
 	return json.Marshal(j)
@@ -59,31 +95,51 @@ func (j *JSONB) Scan(value interface{}) error {
 	
 	switch v := value.(type) {
 	case []byte:
-		return json.Unmarshal(v, j)
+		return decodeJSONB(v, j)
 	case string:
-		return json.Unmarshal([]byte(v), j)
+		return decodeJSONB([]byte(v), j)
 	default:
 		return fmt.Errorf("cannot scan %T into JSONB", value)
 	}
 }
 
+// decodeJSONB 按JSONBUseNumber解码JSON对象
+func decodeJSONB(data []byte, j *JSONB) error {
+	if !JSONBUseNumber {
+		return json.Unmarshal(data, j)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(j)
+}
+
 // Device 设备模型
 type Device struct {
 	ID         uint       `json:"id" gorm:"primarykey"`
 	DeviceID   string     `json:"device_id" gorm:"unique;not null;index"` // 设备唯一标识
 	Name       string     `json:"name" gorm:"not null"`
-	Type       DeviceType `json:"type" gorm:"not null;index"`
+	Type       DeviceType `json:"type" gorm:"not null;index;index:idx_devices_owner_type,priority:2"`
 	TypeName   string     `json:"type_name" gorm:"-"` // 不存储在数据库中
 	Location   JSONB      `json:"location" gorm:"type:jsonb"` // 地理位置信息
 	Config     JSONB      `json:"config" gorm:"type:jsonb"`   // 设备配置
-	Status     string     `json:"status" gorm:"default:offline"` // online, offline, error
-	LastSeen   *time.Time `json:"last_seen"`
-	OwnerID    uint       `json:"owner_id" gorm:"index"`
+	ConfigSchemaVersion int `json:"schema_version" gorm:"not null;default:1"` // 配置结构版本，见LatestConfigSchemaVersion
+	Status     string     `json:"status" gorm:"default:offline"` // online, offline, error；维护中的设备在返回时为maintenance
+	Maintenance      bool       `json:"maintenance" gorm:"not null;default:false"` // 维护模式：不标记离线、不触发告警
+	MaintenanceUntil *time.Time `json:"maintenance_until"`                         // 维护结束时间，到期后自动退出维护模式，为空表示手动结束
+	FirmwareVersion  string     `json:"firmware_version" gorm:"size:64;not null;default:'';index"`      // 固件版本，由上报数据中的_firmware_version字段或手动设置更新
+	Tags       pq.StringArray `json:"tags" gorm:"type:text[];index:idx_devices_tags,type:gin"` // 设备标签（站点、作物、分区等）
+	DedupEnabled bool     `json:"dedup_enabled" gorm:"not null;default:false"` // 是否丢弃去重窗口内重复上报的相同数据
+	GroupID    *uint      `json:"group_id" gorm:"index"` // 所属设备分组，为空表示未分组
+	KeyHash    string     `json:"-" gorm:"size:64"` // 设备密钥的SHA-256摘要，为空表示未签发密钥
+	LastSeen   *time.Time `json:"last_seen" gorm:"index:idx_devices_owner_last_seen,priority:2"`
+	OwnerID    uint       `json:"owner_id" gorm:"index;index:idx_devices_owner_type,priority:1;index:idx_devices_owner_last_seen,priority:1"`
 	CreatedAt  time.Time  `json:"created_at"`
 	UpdatedAt  time.Time  `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"` // 软删除标记
+	DataPurgedAt *time.Time `json:"-"` // 删除后传感器数据清理完成的时间
 	
 	// 关联关系
-	Owner       User         `json:"owner,omitempty" gorm:"foreignKey:OwnerID"`
+	Owner       PublicUser   `json:"owner,omitempty" gorm:"foreignKey:OwnerID"`
 	SensorData  []SensorData `json:"sensor_data,omitempty" gorm:"foreignKey:DeviceID;references:DeviceID"`
 }
 
@@ -92,6 +148,42 @@ func (Device) TableName() string {
 	return "devices"
 }
 
+// BeforeCreate GORM钩子：拒绝未知的设备类型
+func (d *Device) BeforeCreate(tx *gorm.DB) error {
+	return validateDeviceType(d.Type)
+}
+
+// BeforeUpdate GORM钩子：拒绝将设备改为未知类型
+// 按列更新（如Model(&Device{}).Update("status", ...)）时模型中没有类型，只检查更新中给出的类型
+func (d *Device) BeforeUpdate(tx *gorm.DB) error {
+	if d.Type != 0 {
+		if err := validateDeviceType(d.Type); err != nil {
+			return err
+		}
+	}
+	if updates, ok := tx.Statement.Dest.(map[string]interface{}); ok {
+		if value, ok := updates["type"]; ok {
+			switch deviceType := value.(type) {
+			case DeviceType:
+				return validateDeviceType(deviceType)
+			case int:
+				return validateDeviceType(DeviceType(deviceType))
+			default:
+				return fmt.Errorf("%w: %v", ErrInvalidDeviceType, value)
+			}
+		}
+	}
+	return nil
+}
+
+// validateDeviceType 检查设备类型是否合法
+func validateDeviceType(deviceType DeviceType) error {
+	if !deviceType.Valid() {
+		return fmt.Errorf("%w: %d", ErrInvalidDeviceType, deviceType)
+	}
+	return nil
+}
+
 // AfterFind GORM钩子：查询后设置类型名称
 func (d *Device) AfterFind(tx *gorm.DB) error {
 	if name, exists := DeviceTypeNames[d.Type]; exists {
@@ -100,21 +192,138 @@ func (d *Device) AfterFind(tx *gorm.DB) error {
 	return nil
 }
 
+// HashDeviceKey 计算设备密钥的摘要，数据库只保存摘要
+func HashDeviceKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckKey 校验设备密钥，未签发密钥时总是返回false
+func (d *Device) CheckKey(key string) bool {
+	if d.KeyHash == "" || key == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(d.KeyHash), []byte(HashDeviceKey(key))) == 1
+}
+
 // IsOnline 检查设备是否在线
 func (d *Device) IsOnline() bool {
 	if d.LastSeen == nil {
 		return false
 	}
-	// 如果5分钟内有数据则认为在线
-	return time.Since(*d.LastSeen) < 5*time.Minute
+	return time.Since(*d.LastSeen) < OnlineThreshold
+}
+
+// InMaintenance 设备是否处于维护模式（已到结束时间的视为已退出，等待后台任务清除）
+func (d *Device) InMaintenance(now time.Time) bool {
+	return d.Maintenance && (d.MaintenanceUntil == nil || now.Before(*d.MaintenanceUntil))
 }
 
+// SetMaintenance 进入或退出维护模式，退出时清除结束时间；结束时间必须晚于当前时间
+func (d *Device) SetMaintenance(enabled bool, until *time.Time, now time.Time) error {
+	if !enabled {
+		d.Maintenance = false
+		d.MaintenanceUntil = nil
+		return nil
+	}
+	if until != nil && !until.After(now) {
+		return fmt.Errorf("maintenance_until must be in the future")
+	}
+	d.Maintenance = true
+	d.MaintenanceUntil = until
+	return nil
+}
+
+// DisplayStatus 返回给客户端的状态：维护中的设备为maintenance，其余按最后通信时间判断在线或离线
+func (d *Device) DisplayStatus(now time.Time) string {
+	if d.InMaintenance(now) {
+		return DeviceStatusMaintenance
+	}
+	if d.IsOnline() {
+		return "online"
+	}
+	return "offline"
+}
+
+// FirmwareVersionField 上报数据中携带固件版本的保留字段，入库前从读数中移除
+const FirmwareVersionField = "_firmware_version"
+
+// MaxFirmwareVersionLength 固件版本的最大长度
+const MaxFirmwareVersionLength = 64
+
+// ExtractFirmwareVersion 从上报数据中取出并移除固件版本字段，字段不存在或不是非空字符串时返回空字符串
+func ExtractFirmwareVersion(data JSONB) string {
+	raw, ok := data[FirmwareVersionField]
+	if !ok {
+		return ""
+	}
+	delete(data, FirmwareVersionField)
+	version, _ := raw.(string)
+	version = strings.TrimSpace(version)
+	if len(version) > MaxFirmwareVersionLength {
+		return ""
+	}
+	return version
+}
+
+// ReadingTimestampField 上报数据中携带采集时间（RFC3339字符串或Unix秒）的保留字段，入库前从读数中移除
+const ReadingTimestampField = "_timestamp"
+
+// MaxReadingClockSkew 设备采集时间允许超前服务器时间的最大值
+const MaxReadingClockSkew = 5 * time.Minute
+
+// ExtractReadingTimestamp 从上报数据中取出并移除采集时间字段，字段不存在时返回零值
+func ExtractReadingTimestamp(data JSONB, now time.Time) (time.Time, error) {
+	raw, ok := data[ReadingTimestampField]
+	if !ok {
+		return time.Time{}, nil
+	}
+	delete(data, ReadingTimestampField)
+	
+	var timestamp time.Time
+	switch v := raw.(type) {
+	case string:
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%s must be an RFC3339 time or Unix seconds", ReadingTimestampField)
+		}
+		timestamp = parsed
+	case float64:
+		seconds, fraction := math.Modf(v)
+		timestamp = time.Unix(int64(seconds), int64(fraction*1e9))
+	default:
+		return time.Time{}, fmt.Errorf("%s must be an RFC3339 time or Unix seconds", ReadingTimestampField)
+	}
+	if timestamp.After(now.Add(MaxReadingClockSkew)) {
+		return time.Time{}, fmt.Errorf("%s is in the future", ReadingTimestampField)
+	}
+	return timestamp, nil
+}
+
+// DeviceStatusMaintenance 维护中设备的展示状态，不写入数据库的status列
+const DeviceStatusMaintenance = "maintenance"
+
+// SecondsSinceLastSeen 距离最后通信的秒数，从未通信时返回nil
+func (d *Device) SecondsSinceLastSeen() *int64 {
+	if d.LastSeen == nil {
+		return nil
+	}
+	seconds := int64(time.Since(*d.LastSeen).Seconds())
+	return &seconds
+}
+
+// OnlineThreshold 最后通信时间在该时长内的设备视为在线
+const OnlineThreshold = 5 * time.Minute
+
 // SensorData 传感器数据模型
 type SensorData struct {
 	ID        uint      `json:"id" gorm:"primarykey"`
 	DeviceID  string    `json:"device_id" gorm:"not null;index"`
 	Data      JSONB     `json:"data" gorm:"type:jsonb"` // 传感器数据JSON
-	Timestamp time.Time `json:"timestamp" gorm:"index"`
+	Normalized JSONB    `json:"normalized,omitempty" gorm:"type:jsonb"` // 按设备类型转换后的数据，未配置转换时为空
+	Quality   string    `json:"quality" gorm:"not null;default:good;index"` // good, suspect, bad，见AssessQuality
+	QualityIssues pq.StringArray `json:"quality_issues,omitempty" gorm:"type:text[]"` // 超出合理范围的字段
+	Timestamp time.Time `json:"timestamp"` // 时间索引由迁移按DB_SENSOR_TIME_INDEX创建（btree或BRIN）
 	CreatedAt time.Time `json:"created_at"`
 	
 	// 关联关系
@@ -126,6 +335,12 @@ func (SensorData) TableName() string {
 	return "sensor_data"
 }
 
+// DeviceTagCount 设备标签统计
+type DeviceTagCount struct {
+	Tag   string `json:"tag"`
+	Count int64  `json:"count"`
+}
+
 // DeviceStatus 设备状态统计
 type DeviceStatus struct {
 	Type       DeviceType `json:"type"`
@@ -133,5 +348,6 @@ type DeviceStatus struct {
 	Total      int64      `json:"total"`
 	Online     int64      `json:"online"`
 	Offline    int64      `json:"offline"`
+	Maintenance int64     `json:"maintenance"` // 维护中的设备数，同时计入在线或离线
 	LastUpdate time.Time  `json:"last_update"`
 }
\ No newline at end of file
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// 下行命令状态
+const (
+	CommandPending   = "pending"   // 已创建，等待下发
+	CommandDelivered = "delivered" // 已送达设备，等待确认
+	CommandAcked     = "acked"     // 设备已确认执行
+	CommandFailed    = "failed"    // 下发失败或设备返回错误
+)
+
+// ValidCommandStatus 是否为合法的命令状态
+func ValidCommandStatus(status string) bool {
+	return status == CommandPending || status == CommandDelivered || status == CommandAcked || status == CommandFailed
+}
+
+// ErrInvalidCommandTransition 命令状态不能从当前状态转换到目标状态
+var ErrInvalidCommandTransition = errors.New("invalid command status transition")
+
+// DeviceCommand 下发给设备的命令及其投递、确认状态
+type DeviceCommand struct {
+	ID          uint       `json:"id" gorm:"primarykey"`
+	DeviceID    uint       `json:"device_id" gorm:"not null;index:idx_device_commands_device_time,priority:1"`
+	Command     string     `json:"command" gorm:"not null"`
+	Payload     JSONB      `json:"payload,omitempty" gorm:"type:jsonb"`
+	Status      string     `json:"status" gorm:"not null;default:pending"` // pending, delivered, acked, failed
+	Error       string     `json:"error,omitempty"`
+	IssuedBy    uint       `json:"issued_by" gorm:"not null"`
+	Issuer      PublicUser `json:"issuer,omitempty" gorm:"foreignKey:IssuedBy"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+	AckedAt     *time.Time `json:"acked_at,omitempty"`
+	FailedAt    *time.Time `json:"failed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"index:idx_device_commands_device_time,priority:2,sort:desc"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (DeviceCommand) TableName() string {
+	return "device_commands"
+}
+
+// Transition 将命令转换到新状态并记录时间
+// 状态只能向前推进：pending -> delivered -> acked，已确认或已失败的命令不再变化
+func (c *DeviceCommand) Transition(status string, at time.Time, errMsg string) error {
+	switch {
+	case status == CommandDelivered && c.Status == CommandPending:
+		c.DeliveredAt = &at
+	case status == CommandAcked && (c.Status == CommandPending || c.Status == CommandDelivered):
+		// 未收到送达回执但设备已确认时，视为同时送达
+		if c.DeliveredAt == nil {
+			c.DeliveredAt = &at
+		}
+		c.AckedAt = &at
+	case status == CommandFailed && (c.Status == CommandPending || c.Status == CommandDelivered):
+		c.FailedAt = &at
+		c.Error = errMsg
+	default:
+		return ErrInvalidCommandTransition
+	}
+	c.Status = status
+	return nil
+}
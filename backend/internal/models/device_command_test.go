@@ -0,0 +1,46 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDeviceCommandTransition(t *testing.T) {
+	at := time.Now()
+
+	command := DeviceCommand{Status: CommandPending}
+	if err := command.Transition(CommandDelivered, at, ""); err != nil || command.DeliveredAt == nil {
+		t.Fatalf("pending -> delivered = %v, delivered_at %v", err, command.DeliveredAt)
+	}
+	if err := command.Transition(CommandAcked, at.Add(time.Second), ""); err != nil || command.Status != CommandAcked {
+		t.Fatalf("delivered -> acked = %v, status %s", err, command.Status)
+	}
+	if !command.DeliveredAt.Equal(at) {
+		t.Errorf("delivered_at = %v, want it kept at %v", command.DeliveredAt, at)
+	}
+
+	// 直接确认时同时记录送达时间
+	direct := DeviceCommand{Status: CommandPending}
+	if err := direct.Transition(CommandAcked, at, ""); err != nil || direct.DeliveredAt == nil || direct.AckedAt == nil {
+		t.Errorf("pending -> acked = %v, delivered_at %v, acked_at %v", err, direct.DeliveredAt, direct.AckedAt)
+	}
+
+	failed := DeviceCommand{Status: CommandDelivered}
+	if err := failed.Transition(CommandFailed, at, "timeout"); err != nil || failed.Error != "timeout" || failed.FailedAt == nil {
+		t.Errorf("delivered -> failed = %v, error %q", err, failed.Error)
+	}
+
+	// 终态和回退都不允许
+	for _, tt := range []struct{ from, to string }{
+		{CommandAcked, CommandFailed},
+		{CommandFailed, CommandAcked},
+		{CommandDelivered, CommandPending},
+		{CommandDelivered, CommandDelivered},
+	} {
+		command := DeviceCommand{Status: tt.from}
+		if err := command.Transition(tt.to, at, ""); !errors.Is(err, ErrInvalidCommandTransition) || command.Status != tt.from {
+			t.Errorf("%s -> %s = %v (status %s), want ErrInvalidCommandTransition", tt.from, tt.to, err, command.Status)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+)
+
+// LatestConfigSchemaVersion 设备配置的最新结构版本
+// 新增迁移时在configMigrations末尾追加一项并递增该值
+const LatestConfigSchemaVersion = 2
+
+// ConfigMigration 设备配置从From版本升级到From+1版本的迁移
+type ConfigMigration struct {
+	From        int
+	Description string
+	Up          func(deviceType DeviceType, config JSONB) JSONB
+}
+
+// configMigrations 按版本顺序排列的配置迁移，configMigrations[i]将版本i+1升级到i+2
+var configMigrations = []ConfigMigration{
+	{
+		From:        1,
+		Description: "将平铺的<字段>_min/<字段>_max阈值归入thresholds对象",
+		Up:          groupFieldThresholds,
+	},
+}
+
+// MigrateConfig 将配置从version升级到最新版本，返回升级后的配置、版本号以及是否发生变化
+// 已是最新版本或版本未知（如由更新的服务写入）时原样返回，保证配置始终可读
+func MigrateConfig(deviceType DeviceType, version int, config JSONB) (JSONB, int, bool) {
+	if version < 1 || version >= LatestConfigSchemaVersion {
+		return config, version, false
+	}
+	
+	migrated := cloneConfig(config)
+	for version < LatestConfigSchemaVersion {
+		migrated = configMigrations[version-1].Up(deviceType, migrated)
+		version++
+	}
+	return migrated, version, true
+}
+
+// groupFieldThresholds v1→v2：设备类型已知字段的temperature_min、temperature_max等平铺键
+// 迁移为thresholds.temperature.min/max；thresholds中已有的值优先保留
+func groupFieldThresholds(deviceType DeviceType, config JSONB) JSONB {
+	thresholds, _ := asConfigMap(config["thresholds"])
+	if thresholds == nil {
+		thresholds = JSONB{}
+	}
+	
+	for _, field := range DeviceTypeFields[deviceType] {
+		for _, bound := range []string{"min", "max"} {
+			key := field.Key + "_" + bound
+			value, ok := config[key]
+			if !ok {
+				continue
+			}
+			delete(config, key)
+			
+			limits, _ := asConfigMap(thresholds[field.Key])
+			if limits == nil {
+				limits = JSONB{}
+			}
+			if _, exists := limits[bound]; !exists {
+				limits[bound] = value
+			}
+			thresholds[field.Key] = map[string]interface{}(limits)
+		}
+	}
+	
+	if len(thresholds) > 0 {
+		config["thresholds"] = map[string]interface{}(thresholds)
+	}
+	return config
+}
+
+// cloneConfig 深拷贝配置，迁移时修改嵌套对象不影响原配置（变更记录需要保留原值）
+func cloneConfig(config JSONB) JSONB {
+	cloned := make(JSONB, len(config))
+	for key, value := range config {
+		cloned[key] = cloneConfigValue(value)
+	}
+	return cloned
+}
+
+// cloneConfigValue 深拷贝单个配置值
+func cloneConfigValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case JSONB:
+		return map[string]interface{}(cloneConfig(v))
+	case map[string]interface{}:
+		return map[string]interface{}(cloneConfig(JSONB(v)))
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = cloneConfigValue(item)
+		}
+		return items
+	default:
+		return v
+	}
+}
+
+// ValidateDeviceConfig 按设备类型校验最新结构版本的配置，返回所有问题
+// sampling_interval须为正整数（秒）；thresholds中的键须为该设备类型的数值字段，min、max须为数值且min不大于max。
+// 其他键不做限制，便于设备固件扩展
+func ValidateDeviceConfig(deviceType DeviceType, config JSONB) []string {
+	var problems []string
+	
+	if value, ok := config["sampling_interval"]; ok {
+		interval, isNumber := qualityNumber(value)
+		if !isNumber || interval < 1 || interval != float64(int64(interval)) {
+			problems = append(problems, "sampling_interval must be a positive integer")
+		}
+	}
+	
+	value, ok := config["thresholds"]
+	if !ok {
+		return problems
+	}
+	thresholds, isMap := asConfigMap(value)
+	if !isMap {
+		return append(problems, "thresholds must be an object")
+	}
+	
+	meta, _ := GetDeviceTypeMeta(deviceType)
+	keys := make([]string, 0, len(thresholds))
+	for key := range thresholds {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if field, exists := meta.Field(key); !exists || field.Type != "number" {
+			problems = append(problems, fmt.Sprintf("thresholds.%s: not a numeric field of this device type", key))
+			continue
+		}
+		limits, isMap := asConfigMap(thresholds[key])
+		if !isMap {
+			problems = append(problems, fmt.Sprintf("thresholds.%s must be an object", key))
+			continue
+		}
+		
+		var bounds [2]*float64
+		for i, bound := range []string{"min", "max"} {
+			raw, exists := limits[bound]
+			if !exists {
+				continue
+			}
+			number, isNumber := qualityNumber(raw)
+			if !isNumber {
+				problems = append(problems, fmt.Sprintf("thresholds.%s.%s must be a number", key, bound))
+				continue
+			}
+			bounds[i] = &number
+		}
+		if bounds[0] != nil && bounds[1] != nil && *bounds[0] > *bounds[1] {
+			problems = append(problems, fmt.Sprintf("thresholds.%s: min must not be greater than max", key))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// DefaultSamplingInterval 设备默认的采样间隔（秒）
+const DefaultSamplingInterval = 60
+
+// deviceConfigOverrides 按设备类型覆盖的默认配置，与内置默认值深度合并
+var deviceConfigOverrides map[DeviceType]JSONB
+
+// InitDeviceConfigDefaults 解析默认配置覆盖项，格式为{"设备类型ID": {...}}，空字符串表示只使用内置默认值
+func InitDeviceConfigDefaults(raw string) error {
+	if raw == "" {
+		deviceConfigOverrides = nil
+		return nil
+	}
+	
+	var byType map[string]JSONB
+	if err := json.Unmarshal([]byte(raw), &byType); err != nil {
+		return fmt.Errorf("invalid device default config: %w", err)
+	}
+	
+	overrides := make(map[DeviceType]JSONB, len(byType))
+	for key, config := range byType {
+		id, err := strconv.Atoi(key)
+		if err != nil {
+			return fmt.Errorf("invalid device type %q in device default config", key)
+		}
+		deviceType := DeviceType(id)
+		if _, ok := DeviceTypeNames[deviceType]; !ok {
+			return fmt.Errorf("unknown device type %d in device default config", id)
+		}
+		overrides[deviceType] = config
+	}
+	deviceConfigOverrides = overrides
+	return nil
+}
+
+// DefaultDeviceConfig 设备类型的默认配置：采样间隔和按字段合理范围生成的阈值，再合并配置的覆盖项
+// 每次返回新的副本，调用方可以直接修改
+func DefaultDeviceConfig(deviceType DeviceType) JSONB {
+	thresholds := make(map[string]interface{})
+	for _, field := range DeviceTypeFields[deviceType] {
+		if field.Type != "number" || field.Min == nil || field.Max == nil {
+			continue
+		}
+		thresholds[field.Key] = map[string]interface{}{
+			"min": *field.Min,
+			"max": *field.Max,
+		}
+	}
+	
+	config := JSONB{"sampling_interval": DefaultSamplingInterval}
+	if len(thresholds) > 0 {
+		config["thresholds"] = thresholds
+	}
+	if override, ok := deviceConfigOverrides[deviceType]; ok {
+		config = MergeConfig(config, cloneConfig(override))
+	}
+	return config
+}
+
+// ApplyDeviceConfigDefaults 将提交的配置深度合并到设备类型的默认配置上，提交的值优先
+func ApplyDeviceConfigDefaults(deviceType DeviceType, config JSONB) JSONB {
+	return MergeConfig(DefaultDeviceConfig(deviceType), config)
+}
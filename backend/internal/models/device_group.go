@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// DeviceGroup 设备分组（站点、区域等），通过ParentID组成树形层级
+type DeviceGroup struct {
+	ID          uint      `json:"id" gorm:"primarykey"`
+	Name        string    `json:"name" gorm:"not null"`
+	Description string    `json:"description"`
+	ParentID    *uint     `json:"parent_id" gorm:"index"` // 为空表示顶层分组
+	OwnerID     uint      `json:"owner_id" gorm:"not null;index"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (DeviceGroup) TableName() string {
+	return "device_groups"
+}
+
+// DeviceGroupStats 分组（含子分组）的设备统计
+type DeviceGroupStats struct {
+	GroupID        uint             `json:"group_id"`
+	GroupCount     int              `json:"group_count"` // 统计范围内的分组数，包含自身
+	TotalDevices   int64            `json:"total_devices"`
+	OnlineDevices  int64            `json:"online_devices"`
+	OfflineDevices int64            `json:"offline_devices"`
+	ByType         map[string]int64 `json:"by_type"`   // 按设备类型名称统计
+	ByStatus       map[string]int64 `json:"by_status"` // 按设备记录的状态统计
+}
@@ -0,0 +1,183 @@
+package models
+
+import "sort"
+
+// FieldSpec 设备上报字段描述
+type FieldSpec struct {
+	Key     string   `json:"key"`
+	Label   string   `json:"label"`
+	Type    string   `json:"type"` // number, enum
+	Unit    string   `json:"unit,omitempty"`
+	Min     *float64 `json:"min,omitempty"`
+	Max     *float64 `json:"max,omitempty"`
+	Integer bool     `json:"integer,omitempty"` // 取值为整数（计数类字段）
+	Values  []string `json:"values,omitempty"`  // enum类型的可选值
+}
+
+// DeviceTypeMeta 设备类型元数据
+type DeviceTypeMeta struct {
+	ID            DeviceType  `json:"id"`
+	Name          string      `json:"name"`
+	Fields        []FieldSpec `json:"fields"`
+	DefaultConfig JSONB       `json:"default_config,omitempty"` // 创建设备时使用的默认配置，仅在设备类型列表中返回
+}
+
+// numberField 数值字段
+func numberField(key, label, unit string, min, max float64) FieldSpec {
+	return FieldSpec{Key: key, Label: label, Type: "number", Unit: unit, Min: &min, Max: &max}
+}
+
+// countField 整数计数字段
+func countField(key, label, unit string, min, max float64) FieldSpec {
+	field := numberField(key, label, unit, min, max)
+	field.Integer = true
+	return field
+}
+
+// enumField 枚举字段
+func enumField(key, label string, values ...string) FieldSpec {
+	return FieldSpec{Key: key, Label: label, Type: "enum", Values: values}
+}
+
+// DeviceTypeFields 各设备类型上报的字段定义，范围为物理上合理的取值区间
+var DeviceTypeFields = map[DeviceType][]FieldSpec{
+	WeatherStation: {
+		numberField("temperature", "温度", "°C", -50, 60),
+		numberField("humidity", "湿度", "%", 0, 100),
+		numberField("wind_speed", "风速", "m/s", 0, 75),
+		numberField("pressure", "气压", "hPa", 800, 1100),
+		numberField("rainfall", "降雨量", "mm", 0, 500),
+	},
+	SoilMoisture: {
+		numberField("soil_temp", "土壤温度", "°C", -30, 60),
+		numberField("soil_humidity", "土壤湿度", "%", 0, 100),
+		numberField("soil_ph", "土壤pH值", "pH", 0, 14),
+		numberField("ec", "电导率", "mS/cm", 0, 20),
+		numberField("n_content", "氮含量", "mg/kg", 0, 1000),
+	},
+	WaterQuality: {
+		numberField("ph", "pH值", "pH", 0, 14),
+		numberField("turbidity", "浊度", "NTU", 0, 4000),
+		numberField("dissolved_oxygen", "溶解氧", "mg/L", 0, 20),
+		numberField("water_temp", "水温", "°C", -5, 50),
+		numberField("conductivity", "电导率", "μS/cm", 0, 20000),
+	},
+	VideoMonitor: {
+		countField("online_status", "在线状态", "", 0, 1),
+		enumField("resolution", "分辨率", "1080P", "720P", "4K"),
+		numberField("storage_usage", "存储使用率", "%", 0, 100),
+	},
+	PowerCabinet: {
+		numberField("voltage", "电压", "V", 0, 450),
+		numberField("current", "电流", "A", 0, 200),
+		numberField("power", "功率", "kW", 0, 100),
+		numberField("frequency", "频率", "Hz", 45, 65),
+	},
+	PestMonitor: {
+		countField("pest_count", "害虫数量", "只", 0, 10000),
+		numberField("trap_temp", "诱捕器温度", "°C", -30, 70),
+		numberField("light_intensity", "灯光强度", "%", 0, 100),
+	},
+	SporeDetector: {
+		countField("spore_count", "孢子浓度", "个/m³", 0, 100000),
+		numberField("analysis_temp", "分析温度", "°C", -10, 60),
+		numberField("sample_volume", "采样体积", "L", 0, 1000),
+	},
+	EnvMonitor: {
+		numberField("ambient_temp", "环境温度", "°C", -50, 60),
+		numberField("ambient_humidity", "环境湿度", "%", 0, 100),
+		numberField("co2", "CO2浓度", "ppm", 0, 10000),
+		numberField("light_intensity", "光照强度", "lux", 0, 200000),
+	},
+	SmartIrrigation: {
+		numberField("flow_rate", "流量", "L/min", 0, 1000),
+		numberField("pressure", "水压", "MPa", 0, 2),
+		enumField("valve_status", "阀门状态", "开启", "关闭"),
+		numberField("water_level", "水位", "%", 0, 100),
+	},
+	InsectKiller: {
+		numberField("power_consumption", "功耗", "W", 0, 500),
+		numberField("working_hours", "工作时长", "h", 0, 24),
+		countField("killed_insects", "灭虫数量", "只", 0, 100000),
+	},
+	SluiceGate: {
+		numberField("gate_opening", "闸门开度", "%", 0, 100),
+		numberField("water_flow", "过水流量", "m³/h", 0, 100000),
+		numberField("upstream_level", "上游水位", "m", 0, 50),
+		numberField("downstream_level", "下游水位", "m", 0, 50),
+	},
+	WaterSensor: {
+		numberField("water_depth", "积水深度", "cm", 0, 500),
+		enumField("alert_level", "预警等级", "正常", "警告", "危险"),
+		enumField("drain_status", "排水状态", "畅通", "堵塞"),
+	},
+	PlantGrowth: {
+		numberField("plant_height", "植株高度", "cm", 0, 1000),
+		numberField("leaf_area", "叶面积", "cm²", 0, 10000),
+		numberField("growth_rate", "生长速率", "cm/day", 0, 50),
+		numberField("chlorophyll", "叶绿素含量", "SPAD", 0, 100),
+	},
+}
+
+// GetDeviceTypeMeta 获取指定设备类型的元数据
+func GetDeviceTypeMeta(deviceType DeviceType) (DeviceTypeMeta, bool) {
+	name, exists := DeviceTypeNames[deviceType]
+	if !exists {
+		return DeviceTypeMeta{}, false
+	}
+	return DeviceTypeMeta{
+		ID:     deviceType,
+		Name:   name,
+		Fields: DeviceTypeFields[deviceType],
+	}, true
+}
+
+// AllDeviceTypeMeta 按类型ID顺序返回所有设备类型元数据
+func AllDeviceTypeMeta() []DeviceTypeMeta {
+	metas := make([]DeviceTypeMeta, 0, len(DeviceTypeNames))
+	for deviceType := range DeviceTypeNames {
+		meta, _ := GetDeviceTypeMeta(deviceType)
+		meta.DefaultConfig = DefaultDeviceConfig(deviceType)
+		metas = append(metas, meta)
+	}
+	sort.Slice(metas, func(i, j int) bool {
+		return metas[i].ID < metas[j].ID
+	})
+	return metas
+}
+
+// Field 查找设备类型下的字段定义
+func (m DeviceTypeMeta) Field(key string) (FieldSpec, bool) {
+	for _, field := range m.Fields {
+		if field.Key == key {
+			return field, true
+		}
+	}
+	return FieldSpec{}, false
+}
+
+// FieldViolation 超出合理范围的读数
+type FieldViolation struct {
+	Key   string   `json:"key"`
+	Value float64  `json:"value"`
+	Min   *float64 `json:"min,omitempty"`
+	Max   *float64 `json:"max,omitempty"`
+}
+
+// Violations 返回数值字段中超出定义范围的读数，未定义或非数值的字段忽略
+func (m DeviceTypeMeta) Violations(data JSONB) []FieldViolation {
+	var violations []FieldViolation
+	for _, field := range m.Fields {
+		if field.Type != "number" {
+			continue
+		}
+		value, ok := qualityNumber(data[field.Key])
+		if !ok {
+			continue
+		}
+		if (field.Min != nil && value < *field.Min) || (field.Max != nil && value > *field.Max) {
+			violations = append(violations, FieldViolation{Key: field.Key, Value: value, Min: field.Min, Max: field.Max})
+		}
+	}
+	return violations
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+)
+
+// DeviceConfigTemplate 设备配置模板（可批量应用到多个设备）
+type DeviceConfigTemplate struct {
+	ID          uint        `json:"id" gorm:"primarykey"`
+	Name        string      `json:"name" gorm:"not null"`
+	Description string      `json:"description"`
+	DeviceType  *DeviceType `json:"device_type" gorm:"index"` // 适用的设备类型，为空表示不限
+	Config      JSONB       `json:"config" gorm:"type:jsonb"`
+	UseCount    int         `json:"use_count" gorm:"default:0"`
+	OwnerID     uint        `json:"owner_id" gorm:"not null;index"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+	
+	// 关联关系
+	Owner PublicUser `json:"owner,omitempty" gorm:"foreignKey:OwnerID"`
+}
+
+// TableName 指定表名
+func (DeviceConfigTemplate) TableName() string {
+	return "device_config_templates"
+}
+
+// DeviceConfigHistory 设备配置变更记录
+type DeviceConfigHistory struct {
+	ID             uint      `json:"id" gorm:"primarykey"`
+	DeviceID       uint      `json:"device_id" gorm:"not null;index"`
+	UserID         uint      `json:"user_id" gorm:"not null;index"`
+	TemplateID     *uint     `json:"template_id" gorm:"index"`
+	Action         string    `json:"action" gorm:"not null"` // apply_template, migrate_schema, import_config
+	PreviousConfig JSONB     `json:"previous_config" gorm:"type:jsonb"`
+	NewConfig      JSONB     `json:"new_config" gorm:"type:jsonb"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// TableName 指定表名
+func (DeviceConfigHistory) TableName() string {
+	return "device_config_history"
+}
+
+// MergeConfig 将overlay深度合并到base的副本中，嵌套对象逐键合并，其余值直接覆盖
+func MergeConfig(base, overlay JSONB) JSONB {
+	merged := make(JSONB, len(base)+len(overlay))
+	for key, value := range base {
+		merged[key] = value
+	}
+	
+	for key, value := range overlay {
+		overlayMap, overlayIsMap := asConfigMap(value)
+		baseMap, baseIsMap := asConfigMap(merged[key])
+		if overlayIsMap && baseIsMap {
+			merged[key] = map[string]interface{}(MergeConfig(baseMap, overlayMap))
+			continue
+		}
+		merged[key] = value
+	}
+	
+	return merged
+}
+
+// asConfigMap 将嵌套配置值转换为JSONB
+func asConfigMap(value interface{}) (JSONB, bool) {
+	switch v := value.(type) {
+	case JSONB:
+		return v, true
+	case map[string]interface{}:
+		return JSONB(v), true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,176 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestExtractReadingTimestamp(t *testing.T) {
+	now := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		value   interface{}
+		present bool
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "absent"},
+		{name: "rfc3339", value: "2024-05-01T07:59:00Z", present: true, want: now.Add(-time.Minute)},
+		{name: "rfc3339 with offset", value: "2024-05-01T15:59:00+08:00", present: true, want: now.Add(-time.Minute)},
+		{name: "unix seconds", value: float64(now.Unix() - 30), present: true, want: now.Add(-30 * time.Second)},
+		{name: "fractional seconds", value: float64(now.Unix()) - 0.5, present: true, want: now.Add(-500 * time.Millisecond)},
+		{name: "within clock skew", value: "2024-05-01T08:04:00Z", present: true, want: now.Add(4 * time.Minute)},
+		{name: "too far in the future", value: "2024-05-01T09:00:00Z", present: true, wantErr: true},
+		{name: "malformed", value: "yesterday", present: true, wantErr: true},
+		{name: "wrong type", value: true, present: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := JSONB{"t": 1}
+			if tt.present {
+				data[ReadingTimestampField] = tt.value
+			}
+			got, err := ExtractReadingTimestamp(data, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("timestamp = %v, want %v", got, tt.want)
+			}
+			if _, ok := data[ReadingTimestampField]; ok {
+				t.Error("timestamp field was not removed from the reading")
+			}
+		})
+	}
+}
+
+func TestDeviceMaintenance(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+	recent := now.Add(-time.Minute)
+
+	tests := []struct {
+		name   string
+		device Device
+		want   bool
+		status string
+	}{
+		{"not in maintenance", Device{LastSeen: &recent}, false, "online"},
+		{"manual maintenance", Device{Maintenance: true}, true, DeviceStatusMaintenance},
+		{"scheduled maintenance", Device{Maintenance: true, MaintenanceUntil: &future}, true, DeviceStatusMaintenance},
+		// 结束时间已过、尚未被后台任务清除的设备按最后通信时间展示
+		{"expired maintenance", Device{Maintenance: true, MaintenanceUntil: &past, LastSeen: &recent}, false, "online"},
+		{"expired maintenance of a silent device", Device{Maintenance: true, MaintenanceUntil: &past}, false, "offline"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.device.InMaintenance(now); got != tt.want {
+				t.Errorf("InMaintenance() = %v, want %v", got, tt.want)
+			}
+			if got := tt.device.DisplayStatus(now); got != tt.status {
+				t.Errorf("DisplayStatus() = %q, want %q", got, tt.status)
+			}
+		})
+	}
+
+	var device Device
+	if err := device.SetMaintenance(true, &past, now); err == nil {
+		t.Error("SetMaintenance() with a past end time succeeded, want an error")
+	}
+	if device.Maintenance {
+		t.Error("a rejected SetMaintenance() enabled maintenance")
+	}
+	if err := device.SetMaintenance(true, &future, now); err != nil || !device.Maintenance || device.MaintenanceUntil != &future {
+		t.Errorf("SetMaintenance(true, future) = %v, maintenance %v until %v", err, device.Maintenance, device.MaintenanceUntil)
+	}
+	if err := device.SetMaintenance(false, &future, now); err != nil || device.Maintenance || device.MaintenanceUntil != nil {
+		t.Errorf("SetMaintenance(false) = %v, maintenance %v until %v, want both cleared", err, device.Maintenance, device.MaintenanceUntil)
+	}
+}
+
+func TestNewReadingAlertSkipsMaintenance(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Minute)
+	reading := SensorData{DeviceID: "soil-1", Data: JSONB{"soil_humidity": 150.0, "soil_ph": 7.0}, Timestamp: time.Now()}
+
+	alert := NewReadingAlert(Device{DeviceID: "soil-1", Type: SoilMoisture}, reading)
+	if alert == nil || len(alert.Fields) != 1 || alert.Fields[0] != "soil_humidity" {
+		t.Fatalf("NewReadingAlert() = %+v, want an alert for soil_humidity", alert)
+	}
+	if alert := NewReadingAlert(Device{DeviceID: "soil-1", Type: SoilMoisture, Maintenance: true, MaintenanceUntil: &future}, reading); alert != nil {
+		t.Errorf("NewReadingAlert() = %+v for a device in maintenance, want nil", alert)
+	}
+	if alert := NewReadingAlert(Device{DeviceID: "soil-1", Type: SoilMoisture, Maintenance: true, MaintenanceUntil: &past}, reading); alert == nil {
+		t.Error("NewReadingAlert() = nil after maintenance ended, want an alert")
+	}
+}
+
+func TestDeviceTypeValid(t *testing.T) {
+	for deviceType, want := range map[DeviceType]bool{
+		0:            false,
+		-1:           false,
+		SoilMoisture: true,
+		PlantGrowth:  true,
+		99:           false,
+	} {
+		if got := deviceType.Valid(); got != want {
+			t.Errorf("DeviceType(%d).Valid() = %v, want %v", deviceType, got, want)
+		}
+	}
+
+	// 合法类型与设备类型元数据一致
+	types := ValidDeviceTypes()
+	if len(types) != len(DeviceTypeNames) || len(types) != len(AllDeviceTypeMeta()) {
+		t.Fatalf("%d valid type(s), want one per DeviceTypeNames entry and metadata", len(types))
+	}
+	for i, deviceType := range types {
+		if i > 0 && types[i-1] >= deviceType {
+			t.Errorf("ValidDeviceTypes() = %v, want ascending order", types)
+			break
+		}
+		if _, ok := GetDeviceTypeMeta(deviceType); !ok {
+			t.Errorf("type %d has no metadata", deviceType)
+		}
+	}
+}
+
+func TestDeviceTypeHooks(t *testing.T) {
+	if err := (&Device{Type: 99}).BeforeCreate(&gorm.DB{Statement: &gorm.Statement{}}); !errors.Is(err, ErrInvalidDeviceType) {
+		t.Errorf("BeforeCreate() with type 99 error = %v, want ErrInvalidDeviceType", err)
+	}
+	if err := (&Device{}).BeforeCreate(&gorm.DB{Statement: &gorm.Statement{}}); !errors.Is(err, ErrInvalidDeviceType) {
+		t.Errorf("BeforeCreate() without a type error = %v, want ErrInvalidDeviceType", err)
+	}
+	if err := (&Device{Type: SoilMoisture}).BeforeCreate(&gorm.DB{Statement: &gorm.Statement{}}); err != nil {
+		t.Errorf("BeforeCreate() with a valid type error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		device  Device
+		dest    interface{}
+		wantErr bool
+	}{
+		{"column update without type", Device{}, map[string]interface{}{"status": "offline"}, false},
+		{"valid type in updates", Device{}, map[string]interface{}{"type": WeatherStation}, false},
+		{"valid int type in updates", Device{}, map[string]interface{}{"type": 3}, false},
+		{"invalid type in updates", Device{}, map[string]interface{}{"type": 99}, true},
+		{"unexpected type value", Device{}, map[string]interface{}{"type": "soil"}, true},
+		{"saved model with an invalid type", Device{Type: 99}, nil, true},
+		{"saved model with a valid type", Device{Type: SoilMoisture}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.device.BeforeUpdate(&gorm.DB{Statement: &gorm.Statement{Dest: tt.dest}})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("BeforeUpdate() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidDeviceType) {
+				t.Errorf("BeforeUpdate() error = %v, want ErrInvalidDeviceType", err)
+			}
+		})
+	}
+}
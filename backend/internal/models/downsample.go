@@ -0,0 +1,98 @@
+package models
+
+import "math"
+
+// DefaultSeriesField 设备类型中第一个数值字段，作为降采样的默认依据；无数值字段时返回空字符串
+func DefaultSeriesField(deviceType DeviceType) string {
+	for _, field := range DeviceTypeFields[deviceType] {
+		if field.Type == "number" {
+			return field.Key
+		}
+	}
+	return ""
+}
+
+// DownsampleLTTB 按Largest-Triangle-Three-Buckets算法将读数降采样到最多threshold条
+// points须按时间升序；以field字段的数值为纵轴、时间为横轴，首尾两点总是保留，
+// 每个桶保留与相邻已选点构成最大三角形的点，因此尖峰和低谷等极值会被保留。
+// field不是数值的读数不参与降采样，不会出现在结果中
+func DownsampleLTTB(points []SensorData, field string, threshold int) []SensorData {
+	series := make([]SensorData, 0, len(points))
+	xs := make([]float64, 0, len(points))
+	ys := make([]float64, 0, len(points))
+	for _, point := range points {
+		value, ok := qualityNumber(point.Data[field])
+		if !ok || math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+		series = append(series, point)
+		xs = append(xs, float64(point.Timestamp.UnixNano())/1e9)
+		ys = append(ys, value)
+	}
+	if threshold >= len(series) {
+		return series
+	}
+	if threshold < 3 {
+		return DownsampleNth(series, threshold)
+	}
+	
+	sampled := make([]SensorData, 0, threshold)
+	sampled = append(sampled, series[0])
+	
+	// 除首尾外的点均分到threshold-2个桶中
+	bucketSize := float64(len(series)-2) / float64(threshold-2)
+	selected := 0
+	for bucket := 0; bucket < threshold-2; bucket++ {
+		start := int(float64(bucket)*bucketSize) + 1
+		end := int(float64(bucket+1)*bucketSize) + 1
+		
+		// 下一个桶的平均点作为三角形的第三个顶点，最后一个桶使用终点
+		nextStart, nextEnd := end, int(float64(bucket+2)*bucketSize)+1
+		if nextEnd > len(series) {
+			nextEnd = len(series)
+		}
+		if nextStart >= nextEnd {
+			nextStart, nextEnd = len(series)-1, len(series)
+		}
+		var avgX, avgY float64
+		for i := nextStart; i < nextEnd; i++ {
+			avgX += xs[i]
+			avgY += ys[i]
+		}
+		avgX /= float64(nextEnd - nextStart)
+		avgY /= float64(nextEnd - nextStart)
+		
+		best, bestArea := start, -1.0
+		for i := start; i < end; i++ {
+			area := math.Abs((xs[selected]-avgX)*(ys[i]-ys[selected]) - (xs[selected]-xs[i])*(avgY-ys[selected]))
+			if area > bestArea {
+				best, bestArea = i, area
+			}
+		}
+		sampled = append(sampled, series[best])
+		selected = best
+	}
+	
+	return append(sampled, series[len(series)-1])
+}
+
+// DownsampleNth 按等间隔取点将读数降采样到最多threshold条，保留首尾两点
+// 用于没有数值字段可作为依据的设备类型
+func DownsampleNth(points []SensorData, threshold int) []SensorData {
+	if threshold >= len(points) {
+		return points
+	}
+	if threshold <= 0 {
+		return []SensorData{}
+	}
+	if threshold == 1 {
+		return points[len(points)-1:]
+	}
+	
+	sampled := make([]SensorData, 0, threshold)
+	last := len(points) - 1
+	for i := 0; i < threshold; i++ {
+		sampled = append(sampled, points[i*last/(threshold-1)])
+	}
+	return sampled
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Invite 注册邀请码，仅在邀请注册模式下使用
+type Invite struct {
+	ID        uint       `json:"id" gorm:"primarykey"`
+	Code      string     `json:"code" gorm:"uniqueIndex;not null"`
+	MaxUses   int        `json:"max_uses" gorm:"not null"` // 1为一次性，0为不限次数
+	UseCount  int        `json:"use_count" gorm:"not null;default:0"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	Note      string     `json:"note,omitempty"`
+	CreatedBy uint       `json:"created_by" gorm:"index"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TableName 指定表名
+func (Invite) TableName() string {
+	return "invites"
+}
+
+// Expired 邀请码是否已过期
+func (i *Invite) Expired(now time.Time) bool {
+	return i.ExpiresAt != nil && !now.Before(*i.ExpiresAt)
+}
+
+// Exhausted 邀请码是否已用完
+func (i *Invite) Exhausted() bool {
+	return i.MaxUses > 0 && i.UseCount >= i.MaxUses
+}
@@ -0,0 +1,354 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ErrPatchTestFailed JSON Patch中的test操作不匹配
+var ErrPatchTestFailed = errors.New("test operation failed")
+
+// PatchOperation RFC 6902 JSON Patch操作
+type PatchOperation struct {
+	Op    string          `json:"op" binding:"required,oneof=add remove replace move copy test"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"` // 保留原始值以区分缺省和null
+}
+
+// PatchError 某个操作执行失败，Index为操作在补丁中的下标
+type PatchError struct {
+	Index int
+	Op    string
+	Path  string
+	Err   error
+}
+
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("operation %d (%s %s): %v", e.Index, e.Op, e.Path, e.Err)
+}
+
+func (e *PatchError) Unwrap() error {
+	return e.Err
+}
+
+// ApplyPatch 在配置副本上按顺序执行补丁，任一操作失败时返回错误且不修改原配置
+func ApplyPatch(config JSONB, ops []PatchOperation) (JSONB, error) {
+	var doc interface{} = map[string]interface{}(cloneConfig(config))
+	for i, op := range ops {
+		var err error
+		doc, err = applyPatchOperation(doc, op)
+		if err != nil {
+			return nil, &PatchError{Index: i, Op: op.Op, Path: op.Path, Err: err}
+		}
+	}
+	
+	result, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("config must remain a JSON object")
+	}
+	return JSONB(result), nil
+}
+
+// applyPatchOperation 执行单个操作并返回新的文档根
+func applyPatchOperation(doc interface{}, op PatchOperation) (interface{}, error) {
+	path, err := parseJSONPointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	
+	switch op.Op {
+	case "add", "replace", "test":
+		if len(op.Value) == 0 {
+			return nil, errors.New("missing value")
+		}
+		var value interface{}
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return nil, fmt.Errorf("invalid value: %w", err)
+		}
+		switch op.Op {
+		case "add":
+			return patchAdd(doc, path, value)
+		case "replace":
+			return patchReplace(doc, path, value)
+		default:
+			current, err := patchGet(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			// 直接比较原始值，避免大整数解码为float64后精度丢失
+			if !patchValuesEqual(current, op.Value) {
+				return nil, ErrPatchTestFailed
+			}
+			return doc, nil
+		}
+	case "remove":
+		return patchRemove(doc, path)
+	case "move", "copy":
+		from, err := parseJSONPointer(op.From)
+		if err != nil {
+			return nil, fmt.Errorf("invalid from: %w", err)
+		}
+		value, err := patchGet(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "copy" {
+			return patchAdd(doc, path, cloneConfigValue(value))
+		}
+		// 不能把节点移动到它自己的子节点下
+		if op.Path != op.From && strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, errors.New("cannot move a value into one of its children")
+		}
+		if doc, err = patchRemove(doc, from); err != nil {
+			return nil, err
+		}
+		return patchAdd(doc, path, value)
+	default:
+		return nil, fmt.Errorf("unsupported op %q", op.Op)
+	}
+}
+
+// parseJSONPointer 解析RFC 6901 JSON Pointer，空字符串表示整个文档
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+	
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// patchGet 读取path处的值
+func patchGet(doc interface{}, path []string) (interface{}, error) {
+	node := doc
+	for _, token := range path {
+		child, err := patchChild(node, token)
+		if err != nil {
+			return nil, err
+		}
+		node = child
+	}
+	return node, nil
+}
+
+// patchAdd 在path处添加值：对象成员存在时覆盖，数组在下标处插入（"-"表示末尾）
+func patchAdd(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return patchParent(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch container := parent.(type) {
+		case map[string]interface{}:
+			container[token] = value
+			return container, nil
+		case []interface{}:
+			index, err := patchIndex(token, len(container), true)
+			if err != nil {
+				return nil, err
+			}
+			container = append(container, nil)
+			copy(container[index+1:], container[index:])
+			container[index] = value
+			return container, nil
+		default:
+			return nil, fmt.Errorf("cannot add to a non-container value at %q", token)
+		}
+	})
+}
+
+// patchRemove 删除path处的值，值必须存在
+func patchRemove(doc interface{}, path []string) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, errors.New("cannot remove the whole document")
+	}
+	return patchParent(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch container := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := container[token]; !ok {
+				return nil, fmt.Errorf("path member %q not found", token)
+			}
+			delete(container, token)
+			return container, nil
+		case []interface{}:
+			index, err := patchIndex(token, len(container), false)
+			if err != nil {
+				return nil, err
+			}
+			return append(container[:index], container[index+1:]...), nil
+		default:
+			return nil, fmt.Errorf("cannot remove from a non-container value at %q", token)
+		}
+	})
+}
+
+// patchReplace 替换path处的值，值必须存在
+func patchReplace(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return patchParent(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch container := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := container[token]; !ok {
+				return nil, fmt.Errorf("path member %q not found", token)
+			}
+			container[token] = value
+			return container, nil
+		case []interface{}:
+			index, err := patchIndex(token, len(container), false)
+			if err != nil {
+				return nil, err
+			}
+			container[index] = value
+			return container, nil
+		default:
+			return nil, fmt.Errorf("cannot replace in a non-container value at %q", token)
+		}
+	})
+}
+
+// patchParent 定位path的父节点并调用fn修改，数组插入或删除后会重新挂回上一级
+func patchParent(node interface{}, path []string, fn func(parent interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(node, path[0])
+	}
+	
+	child, err := patchChild(node, path[0])
+	if err != nil {
+		return nil, err
+	}
+	updated, err := patchParent(child, path[1:], fn)
+	if err != nil {
+		return nil, err
+	}
+	
+	switch container := node.(type) {
+	case map[string]interface{}:
+		container[path[0]] = updated
+	case []interface{}:
+		index, _ := patchIndex(path[0], len(container), false)
+		container[index] = updated
+	}
+	return node, nil
+}
+
+// patchChild 读取容器中token对应的子节点
+func patchChild(node interface{}, token string) (interface{}, error) {
+	switch container := node.(type) {
+	case map[string]interface{}:
+		child, ok := container[token]
+		if !ok {
+			return nil, fmt.Errorf("path member %q not found", token)
+		}
+		return child, nil
+	case []interface{}:
+		index, err := patchIndex(token, len(container), false)
+		if err != nil {
+			return nil, err
+		}
+		return container[index], nil
+	default:
+		return nil, fmt.Errorf("cannot traverse a non-container value at %q", token)
+	}
+}
+
+// patchIndex 解析数组下标，allowEnd时允许"-"和等于长度的下标（用于add）
+func patchIndex(token string, length int, allowEnd bool) (int, error) {
+	if token == "-" && allowEnd {
+		return length, nil
+	}
+	// 只接受不带前导零的十进制数字
+	if token == "" || (len(token) > 1 && token[0] == '0') || strings.Trim(token, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	index, err := strconv.Atoi(token)
+	if err != nil {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if index > length || (index == length && !allowEnd) {
+		return 0, fmt.Errorf("array index %d out of range", index)
+	}
+	return index, nil
+}
+
+// patchValuesEqual 按JSON语义比较两个值（对象键顺序无关，数字按数值比较，1、1.0与1e0相等）
+func patchValuesEqual(a, b interface{}) bool {
+	left, err := normalizePatchValue(a)
+	if err != nil {
+		return false
+	}
+	right, err := normalizePatchValue(b)
+	if err != nil {
+		return false
+	}
+	return jsonValuesEqual(left, right)
+}
+
+// normalizePatchValue 将任意值经JSON编码后重新解码，数字保留为json.Number
+func normalizePatchValue(value interface{}) (interface{}, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var normalized interface{}
+	if err := decoder.Decode(&normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// jsonValuesEqual 递归比较normalizePatchValue的结果
+func jsonValuesEqual(a, b interface{}) bool {
+	switch left := a.(type) {
+	case json.Number:
+		right, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		x, _, errX := big.ParseFloat(string(left), 10, 256, big.ToNearestEven)
+		y, _, errY := big.ParseFloat(string(right), 10, 256, big.ToNearestEven)
+		if errX != nil || errY != nil {
+			return left == right
+		}
+		return x.Cmp(y) == 0
+	case map[string]interface{}:
+		right, ok := b.(map[string]interface{})
+		if !ok || len(left) != len(right) {
+			return false
+		}
+		for key, value := range left {
+			other, ok := right[key]
+			if !ok || !jsonValuesEqual(value, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		right, ok := b.([]interface{})
+		if !ok || len(left) != len(right) {
+			return false
+		}
+		for i := range left {
+			if !jsonValuesEqual(left[i], right[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestPatchValuesEqual(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b interface{}
+		want bool
+	}{
+		{"int and float", 1, 1.0, true},
+		{"int and raw decimal", 1, json.RawMessage(`1.0`), true},
+		{"exponent", json.RawMessage(`1e2`), 100, true},
+		{"json.Number", json.Number("2.50"), 2.5, true},
+		{"different numbers", 1, 2, false},
+		{"large integers differ", json.RawMessage(`9007199254740993`), json.RawMessage(`9007199254740992`), false},
+		{"number and string", 1, "1", false},
+		{"key order", map[string]interface{}{"a": 1, "b": 2}, json.RawMessage(`{"b":2.0,"a":1}`), true},
+		{"nested arrays", []interface{}{1, map[string]interface{}{"x": 1.5}}, json.RawMessage(`[1.0,{"x":15e-1}]`), true},
+		{"array order", []interface{}{1, 2}, []interface{}{2, 1}, false},
+		{"extra key", map[string]interface{}{"a": 1}, map[string]interface{}{"a": 1, "b": nil}, false},
+		{"null", nil, json.RawMessage(`null`), true},
+		{"bool", true, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := patchValuesEqual(tt.a, tt.b); got != tt.want {
+				t.Errorf("patchValuesEqual(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyPatchTestOperation(t *testing.T) {
+	config := JSONB{"interval": 60, "thresholds": map[string]interface{}{"min": 0.5}}
+	tests := []struct {
+		name    string
+		value   string
+		path    string
+		wantErr error
+	}{
+		{"integer matches decimal", `60.0`, "/interval", nil},
+		{"nested decimal", `5e-1`, "/thresholds/min", nil},
+		{"mismatch", `61`, "/interval", ErrPatchTestFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ApplyPatch(config, []PatchOperation{{Op: "test", Path: tt.path, Value: json.RawMessage(tt.value)}})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ApplyPatch error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
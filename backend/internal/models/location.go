@@ -0,0 +1,154 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 位置字段的常见别名
+var (
+	latitudeKeys  = []string{"lat", "latitude"}
+	longitudeKeys = []string{"lng", "lon", "long", "longitude"}
+	altitudeKeys  = []string{"altitude", "alt", "elevation"}
+	addressKeys   = []string{"address", "addr"}
+	nestedKeys    = []string{"coordinates", "position", "geo", "location"}
+)
+
+// NormalizeLocation 将设备位置规范化为 {lat, lng, altitude?, address?, meta?} 结构
+// 支持常见别名及嵌套写法，坐标超出范围时返回错误，未识别的字段统一放入meta
+func NormalizeLocation(raw JSONB) (JSONB, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	
+	source := flattenLocation(raw)
+	
+	lat, latKey, err := pickNumber(source, latitudeKeys)
+	if err != nil {
+		return nil, err
+	}
+	lng, lngKey, err := pickNumber(source, longitudeKeys)
+	if err != nil {
+		return nil, err
+	}
+	if latKey == "" || lngKey == "" {
+		return nil, fmt.Errorf("location must contain lat and lng")
+	}
+	// 取反比较同时拒绝NaN
+	if !(lat >= -90 && lat <= 90) {
+		return nil, fmt.Errorf("lat must be between -90 and 90")
+	}
+	if !(lng >= -180 && lng <= 180) {
+		return nil, fmt.Errorf("lng must be between -180 and 180")
+	}
+	
+	location := JSONB{
+		"lat": lat,
+		"lng": lng,
+	}
+	used := map[string]bool{latKey: true, lngKey: true}
+	
+	altitude, altKey, err := pickNumber(source, altitudeKeys)
+	if err != nil {
+		return nil, err
+	}
+	if altKey != "" {
+		location["altitude"] = altitude
+		used[altKey] = true
+	}
+	
+	for _, key := range addressKeys {
+		if value, ok := source[key].(string); ok && strings.TrimSpace(value) != "" {
+			location["address"] = strings.TrimSpace(value)
+			used[key] = true
+			break
+		}
+	}
+	
+	meta := make(JSONB)
+	if existing, ok := source["meta"].(map[string]interface{}); ok {
+		for key, value := range existing {
+			meta[key] = value
+		}
+	}
+	used["meta"] = true
+	
+	for key, value := range source {
+		if !used[key] {
+			meta[key] = value
+		}
+	}
+	if len(meta) > 0 {
+		location["meta"] = meta
+	}
+	
+	return location, nil
+}
+
+// flattenLocation 将嵌套的坐标对象展开到顶层，顶层字段优先
+func flattenLocation(raw JSONB) map[string]interface{} {
+	flat := make(map[string]interface{}, len(raw))
+	
+	for _, key := range nestedKeys {
+		if nested, ok := raw[key].(map[string]interface{}); ok {
+			for nestedKey, value := range nested {
+				flat[nestedKey] = value
+			}
+		}
+	}
+	
+	for key, value := range raw {
+		if _, isNested := raw[key].(map[string]interface{}); isNested && containsKey(nestedKeys, key) {
+			continue
+		}
+		flat[key] = value
+	}
+	
+	return flat
+}
+
+// pickNumber 按别名顺序读取数值字段，返回值、命中的键名
+func pickNumber(source map[string]interface{}, keys []string) (float64, string, error) {
+	for _, key := range keys {
+		value, exists := source[key]
+		if !exists || value == nil {
+			continue
+		}
+		
+		switch v := value.(type) {
+		case float64:
+			return v, key, nil
+		case json.Number:
+			number, err := v.Float64()
+			if err != nil {
+				return 0, "", fmt.Errorf("%s must be a number", key)
+			}
+			return number, key, nil
+		case int:
+			return float64(v), key, nil
+		case int64:
+			return float64(v), key, nil
+		case string:
+			number, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return 0, "", fmt.Errorf("%s must be a number", key)
+			}
+			return number, key, nil
+		default:
+			return 0, "", fmt.Errorf("%s must be a number", key)
+		}
+	}
+	return 0, "", nil
+}
+
+// containsKey 判断键是否在列表中
+func containsKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeLocation(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want JSONB
+	}{
+		{"empty", `{}`, nil},
+		{"canonical", `{"lat":31.82,"lng":117.22}`, JSONB{"lat": 31.82, "lng": 117.22}},
+		{"long key names", `{"latitude":31.82,"longitude":117.22}`, JSONB{"lat": 31.82, "lng": 117.22}},
+		{"lon alias and numeric strings", `{"lat":" 31.82 ","lon":"117.22"}`, JSONB{"lat": 31.82, "lng": 117.22}},
+		{
+			"nested coordinates with altitude and address",
+			`{"coordinates":{"latitude":31.82,"long":117.22},"elevation":35,"addr":" 合肥 "}`,
+			JSONB{"lat": 31.82, "lng": 117.22, "altitude": float64(35), "address": "合肥"},
+		},
+		{"top-level keys win over nested ones", `{"lat":10,"lng":20,"position":{"lat":30,"lng":40}}`, JSONB{"lat": float64(10), "lng": float64(20)}},
+		{
+			"unknown fields move under meta",
+			`{"lat":0,"lng":0,"field":"A3","meta":{"source":"gps"}}`,
+			JSONB{"lat": float64(0), "lng": float64(0), "meta": JSONB{"field": "A3", "source": "gps"}},
+		},
+		{"range boundaries", `{"lat":-90,"lng":180}`, JSONB{"lat": float64(-90), "lng": float64(180)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var raw JSONB
+			if err := json.Unmarshal([]byte(tt.raw), &raw); err != nil {
+				t.Fatalf("invalid fixture: %v", err)
+			}
+			got, err := NormalizeLocation(raw)
+			if err != nil {
+				t.Fatalf("NormalizeLocation() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NormalizeLocation() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeLocationRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     JSONB
+		wantErr string
+	}{
+		{"missing lng", JSONB{"lat": 31.8}, "must contain lat and lng"},
+		{"latitude above 90", JSONB{"lat": 90.5, "lng": 0.0}, "lat must be between"},
+		{"latitude below -90", JSONB{"latitude": -91.0, "longitude": 0.0}, "lat must be between"},
+		{"longitude out of range", JSONB{"lat": 0.0, "lng": 180.01}, "lng must be between"},
+		{"NaN latitude", JSONB{"lat": "NaN", "lng": 0.0}, "lat must be between"},
+		{"non-numeric string", JSONB{"lat": "north", "lng": 0.0}, "lat must be a number"},
+		{"wrong type", JSONB{"lat": 1.0, "lng": true}, "lng must be a number"},
+		{"non-numeric altitude", JSONB{"lat": 1.0, "lng": 1.0, "alt": "high"}, "alt must be a number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NormalizeLocation(tt.raw); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NormalizeLocation() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
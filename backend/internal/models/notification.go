@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+	"github.com/lib/pq"
+)
+
+// Announcement 管理员广播公告
+type Announcement struct {
+	ID         uint          `json:"id" gorm:"primarykey"`
+	Title      string        `json:"title" gorm:"not null"`
+	Message    string        `json:"message" gorm:"not null"`
+	Level      string        `json:"level" gorm:"default:info"` // info, warning, critical
+	Target     string        `json:"target" gorm:"not null"`    // all, users, role
+	TargetRole string        `json:"target_role,omitempty"`
+	TargetIDs  pq.Int64Array `json:"target_ids,omitempty" gorm:"type:bigint[]"`
+	CreatedBy  uint          `json:"created_by" gorm:"index"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+// TableName 指定表名
+func (Announcement) TableName() string {
+	return "announcements"
+}
+
+// Notification 用户站内通知（随WebSocket推送一并保存，离线用户上线后补发）
+type Notification struct {
+	ID          uint       `json:"id" gorm:"primarykey"`
+	UserID      uint       `json:"user_id" gorm:"not null;index;index:idx_notifications_user_time,priority:1"`
+	Type        string     `json:"type" gorm:"not null;index"` // device_created, announcement等
+	Payload     JSONB      `json:"payload" gorm:"type:jsonb"`
+	ReadAt      *time.Time `json:"read_at" gorm:"index"`
+	DeliveredAt *time.Time `json:"delivered_at"` // 通过WebSocket送达的时间，为空表示待补发
+	CreatedAt   time.Time  `json:"created_at" gorm:"index;index:idx_notifications_user_time,priority:2,sort:desc"`
+}
+
+// TableName 指定表名
+func (Notification) TableName() string {
+	return "notifications"
+}
+
+// IsRead 是否已读
+func (n *Notification) IsRead() bool {
+	return n.ReadAt != nil
+}
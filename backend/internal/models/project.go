@@ -1,6 +1,7 @@
 package models
 
 import (
+	"sort"
 	"time"
 	"github.com/lib/pq"
 )
@@ -10,21 +11,23 @@ type Project struct {
 	ID          uint           `json:"id" gorm:"primarykey"`
 	Name        string         `json:"name" gorm:"not null"`
 	Description string         `json:"description"`
-	OwnerID     uint           `json:"owner_id" gorm:"not null;index"`
+	OwnerID     uint           `json:"owner_id" gorm:"not null;index;index:idx_projects_owner_public,priority:1"`
 	ParentID    *uint          `json:"parent_id" gorm:"index"` // Fork来源项目ID
 	Config      JSONB          `json:"config" gorm:"type:jsonb"` // 项目配置JSON
-	Public      bool           `json:"public" gorm:"default:false"`
+	Public      bool           `json:"public" gorm:"default:false;index:idx_projects_owner_public,priority:2"`
 	Tags        pq.StringArray `json:"tags" gorm:"type:text[]"` // 项目标签
 	StarCount   int            `json:"star_count" gorm:"default:0"`
 	ForkCount   int            `json:"fork_count" gorm:"default:0"`
 	ViewCount   int            `json:"view_count" gorm:"default:0"`
+	Version     int            `json:"version" gorm:"not null;default:1"` // 乐观锁版本号，每次保存递增
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
+	ChildCount  *int64         `json:"child_count,omitempty" gorm:"-"` // 直接Fork自该项目的项目数，仅详情接口返回
 	
 	// 关联关系
-	Owner    User      `json:"owner,omitempty" gorm:"foreignKey:OwnerID"`
+	Owner    PublicUser `json:"owner,omitempty" gorm:"foreignKey:OwnerID"`
 	Parent   *Project  `json:"parent,omitempty" gorm:"foreignKey:ParentID"`
-	Children []Project `json:"children,omitempty" gorm:"foreignKey:ParentID"`
+	Children []Project `json:"children,omitempty" gorm:"foreignKey:ParentID"` // 热门项目的Fork可能很多，不要预加载，使用GET /projects/:id/forks分页获取
 	Stars    []ProjectStar `json:"stars,omitempty" gorm:"foreignKey:ProjectID"`
 	Forks    []Fork    `json:"forks,omitempty" gorm:"foreignKey:ProjectID"`
 	History  []ForkHistory `json:"history,omitempty" gorm:"foreignKey:ProjectID"`
@@ -58,18 +61,15 @@ func (p *Project) IncrementViewCount() {
 // Fork Fork记录模型
 type Fork struct {
 	ID        uint      `json:"id" gorm:"primarykey"`
-	ProjectID uint      `json:"project_id" gorm:"not null;index"`
-	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	ProjectID uint      `json:"project_id" gorm:"not null;index;uniqueIndex:idx_forks_user_project"`
+	UserID    uint      `json:"user_id" gorm:"not null;index;uniqueIndex:idx_forks_user_project"`
 	Config    JSONB     `json:"config" gorm:"type:jsonb"` // Fork时的配置快照
 	Message   string    `json:"message"` // Fork说明
 	CreatedAt time.Time `json:"created_at"`
 	
 	// 关联关系
 	Project Project `json:"project,omitempty" gorm:"foreignKey:ProjectID"`
-	User    User    `json:"user,omitempty" gorm:"foreignKey:UserID"`
-	
-	// 联合唯一索引
-	// gorm:"uniqueIndex:idx_user_project"
+	User    PublicUser `json:"user,omitempty" gorm:"foreignKey:UserID"`
 }
 
 // TableName 指定表名
@@ -80,18 +80,18 @@ func (Fork) TableName() string {
 // ForkHistory Fork历史记录模型
 type ForkHistory struct {
 	ID         uint      `json:"id" gorm:"primarykey"`
-	ProjectID  uint      `json:"project_id" gorm:"not null;index"`
+	ProjectID  uint      `json:"project_id" gorm:"not null;index;index:idx_fork_history_project_time,priority:1"`
 	UserID     uint      `json:"user_id" gorm:"not null;index"`
-	Action     string    `json:"action" gorm:"not null"` // create, update, merge, revert
+	Action     string    `json:"action" gorm:"not null"` // create, update, merge, revert, collaborator_add, collaborator_update, collaborator_remove
 	ConfigDiff JSONB     `json:"config_diff" gorm:"type:jsonb"` // 配置差异
 	Message    string    `json:"message"` // 操作说明
 	IPAddress  string    `json:"ip_address"`
 	UserAgent  string    `json:"user_agent"`
-	CreatedAt  time.Time `json:"created_at"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index:idx_fork_history_project_time,priority:2,sort:desc"`
 	
 	// 关联关系
 	Project Project `json:"project,omitempty" gorm:"foreignKey:ProjectID"`
-	User    User    `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	User    PublicUser `json:"user,omitempty" gorm:"foreignKey:UserID"`
 }
 
 // TableName 指定表名
@@ -102,16 +102,13 @@ func (ForkHistory) TableName() string {
 // ProjectStar 项目点赞模型
 type ProjectStar struct {
 	ID        uint      `json:"id" gorm:"primarykey"`
-	ProjectID uint      `json:"project_id" gorm:"not null;index"`
-	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	ProjectID uint      `json:"project_id" gorm:"not null;index;uniqueIndex:idx_project_stars_user_project"`
+	UserID    uint      `json:"user_id" gorm:"not null;index;uniqueIndex:idx_project_stars_user_project"` // 每个用户只能给同一个项目点一次赞
 	CreatedAt time.Time `json:"created_at"`
 	
 	// 关联关系
 	Project Project `json:"project,omitempty" gorm:"foreignKey:ProjectID"`
-	User    User    `json:"user,omitempty" gorm:"foreignKey:UserID"`
-	
-	// 联合唯一索引：每个用户只能给同一个项目点一次赞
-	// gorm:"uniqueIndex:idx_user_project_star"
+	User    PublicUser `json:"user,omitempty" gorm:"foreignKey:UserID"`
 }
 
 // TableName 指定表名
@@ -135,7 +132,7 @@ type PullRequest struct {
 	// 关联关系
 	Source Project `json:"source,omitempty" gorm:"foreignKey:SourceID"`
 	Target Project `json:"target,omitempty" gorm:"foreignKey:TargetID"`
-	User   User    `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	User   PublicUser `json:"user,omitempty" gorm:"foreignKey:UserID"`
 }
 
 // TableName 指定表名
@@ -158,10 +155,51 @@ type ProjectTemplate struct {
 	UpdatedAt   time.Time      `json:"updated_at"`
 	
 	// 关联关系
-	Creator User `json:"creator,omitempty" gorm:"foreignKey:CreatedBy"`
+	Creator PublicUser `json:"creator,omitempty" gorm:"foreignKey:CreatedBy"`
 }
 
 // TableName 指定表名
 func (ProjectTemplate) TableName() string {
 	return "project_templates"
-}
\ No newline at end of file
+}
+// 项目协作者角色
+const (
+	CollaboratorViewer = "viewer" // 可查看私有项目及其历史
+	CollaboratorEditor = "editor" // 另可修改项目配置
+)
+
+// ProjectCollaborator 项目协作者
+type ProjectCollaborator struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	ProjectID uint      `json:"project_id" gorm:"not null;uniqueIndex:idx_project_collaborators_project_user"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_project_collaborators_project_user;index"`
+	Role      string    `json:"role" gorm:"not null"` // viewer, editor
+	AddedBy   uint      `json:"added_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	
+	// 关联关系
+	User PublicUser `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+// TableName 指定表名
+func (ProjectCollaborator) TableName() string {
+	return "project_collaborators"
+}
+
+// ChangedConfigKeys 返回other相对base有变化（新增、删除或值不同）的顶层配置键，按字母排序
+func ChangedConfigKeys(base, other JSONB) []string {
+	changed := make([]string, 0)
+	for key, value := range other {
+		if baseValue, ok := base[key]; !ok || !patchValuesEqual(baseValue, value) {
+			changed = append(changed, key)
+		}
+	}
+	for key := range base {
+		if _, ok := other[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// projectSortColumns 项目列表可排序的字段
+var projectSortColumns = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+	"name":       true,
+	"star_count": true,
+	"fork_count": true,
+	"view_count": true,
+}
+
+// ProjectSort 项目列表排序方式
+type ProjectSort struct {
+	Column string
+	Desc   bool
+}
+
+// defaultProjectSort 未指定sort参数时的排序方式
+var defaultProjectSort = ProjectSort{Column: "created_at", Desc: true}
+
+// ParseProjectSort 解析排序参数，格式为字段名，前缀-表示降序，如-created_at、name
+func ParseProjectSort(raw string) (ProjectSort, error) {
+	column := strings.TrimPrefix(raw, "-")
+	if !projectSortColumns[column] {
+		return ProjectSort{}, fmt.Errorf("unsupported sort field %q", column)
+	}
+	return ProjectSort{Column: column, Desc: strings.HasPrefix(raw, "-")}, nil
+}
+
+// InitProjectDefaultSort 设置项目列表的默认排序
+func InitProjectDefaultSort(raw string) error {
+	sort, err := ParseProjectSort(raw)
+	if err != nil {
+		return fmt.Errorf("invalid default project sort: %w", err)
+	}
+	defaultProjectSort = sort
+	return nil
+}
+
+// DefaultProjectSort 项目列表的默认排序
+func DefaultProjectSort() ProjectSort {
+	return defaultProjectSort
+}
+
+// String 排序参数形式，用于缓存键
+func (s ProjectSort) String() string {
+	if s.Desc {
+		return "-" + s.Column
+	}
+	return s.Column
+}
+
+// OrderClause ORDER BY子句，以id作为同方向的第二排序键，
+// 排序字段相同（如同一事务中创建的项目）时顺序仍然确定，翻页不会重复或遗漏
+func (s ProjectSort) OrderClause() string {
+	direction := "ASC"
+	if s.Desc {
+		direction = "DESC"
+	}
+	return fmt.Sprintf("%s %s, id %s", s.Column, direction, direction)
+}
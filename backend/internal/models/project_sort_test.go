@@ -0,0 +1,62 @@
+package models
+
+import "testing"
+
+func TestParseProjectSort(t *testing.T) {
+	tests := []struct {
+		raw    string
+		want   ProjectSort
+		order  string
+		wantOK bool
+	}{
+		{"-created_at", ProjectSort{Column: "created_at", Desc: true}, "created_at DESC, id DESC", true},
+		{"name", ProjectSort{Column: "name"}, "name ASC, id ASC", true},
+		{"-star_count", ProjectSort{Column: "star_count", Desc: true}, "star_count DESC, id DESC", true},
+		{"", ProjectSort{}, "", false},
+		{"-", ProjectSort{}, "", false},
+		{"password", ProjectSort{}, "", false},
+		{"name; DROP TABLE projects", ProjectSort{}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := ParseProjectSort(tt.raw)
+			if (err == nil) != tt.wantOK {
+				t.Fatalf("ParseProjectSort(%q) error = %v, want ok = %v", tt.raw, err, tt.wantOK)
+			}
+			if !tt.wantOK {
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseProjectSort(%q) = %+v, want %+v", tt.raw, got, tt.want)
+			}
+			if got.String() != tt.raw {
+				t.Errorf("String() = %q, want %q", got.String(), tt.raw)
+			}
+			if got.OrderClause() != tt.order {
+				t.Errorf("OrderClause() = %q, want %q", got.OrderClause(), tt.order)
+			}
+		})
+	}
+}
+
+func TestInitProjectDefaultSort(t *testing.T) {
+	previous := DefaultProjectSort()
+	t.Cleanup(func() { defaultProjectSort = previous })
+
+	if previous != (ProjectSort{Column: "created_at", Desc: true}) {
+		t.Errorf("built-in default = %+v, want -created_at", previous)
+	}
+	if err := InitProjectDefaultSort("view_count"); err != nil {
+		t.Fatalf("InitProjectDefaultSort() error = %v", err)
+	}
+	if got := DefaultProjectSort(); got != (ProjectSort{Column: "view_count"}) {
+		t.Errorf("DefaultProjectSort() = %+v, want view_count ascending", got)
+	}
+	// 无效的配置不改变当前默认值
+	if err := InitProjectDefaultSort("-owner_id"); err == nil {
+		t.Error("InitProjectDefaultSort(-owner_id) succeeded, want an error")
+	}
+	if got := DefaultProjectSort(); got.Column != "view_count" {
+		t.Errorf("DefaultProjectSort() = %+v after an invalid value, want it unchanged", got)
+	}
+}
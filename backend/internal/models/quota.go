@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// UserQuota 单个用户的配额覆盖，字段为空时沿用全局默认值，0表示不限制
+type UserQuota struct {
+	UserID        uint      `json:"user_id" gorm:"primarykey"`
+	MaxDevices    *int      `json:"max_devices"`
+	MaxProjects   *int      `json:"max_projects"`
+	MaxSensorRows *int64    `json:"max_sensor_rows"`
+	UpdatedBy     uint      `json:"updated_by"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (UserQuota) TableName() string {
+	return "user_quotas"
+}
@@ -0,0 +1,44 @@
+package models
+
+// 用户角色
+const (
+	RoleAdmin     = "admin"     // 管理员，拥有全部权限
+	RoleModerator = "moderator" // 版主，可管理公开内容，不能管理用户和系统配置
+	RoleUser      = "user"
+)
+
+// Capability 角色权限项，接口按权限项而不是角色名做授权
+type Capability string
+
+const (
+	CapManageUsers      Capability = "users:manage"            // 查看和管理用户、配额
+	CapManageConfig     Capability = "config:manage"           // 系统配置
+	CapManageInvites    Capability = "invites:manage"          // 注册邀请码
+	CapBroadcast        Capability = "notifications:broadcast" // 通知广播
+	CapManageResources  Capability = "resources:manage"        // 管理（同租户）任意用户的设备、项目等资源
+	CapViewStats        Capability = "stats:view"              // 系统统计
+	CapModerateProjects Capability = "projects:moderate"       // 下架违规的公开项目
+)
+
+// roleCapabilities 非管理员角色拥有的权限项；管理员拥有全部权限，不在此列出
+var roleCapabilities = map[string][]Capability{
+	RoleModerator: {CapViewStats, CapModerateProjects},
+}
+
+// ValidRole 是否为合法的角色
+func ValidRole(role string) bool {
+	return role == RoleAdmin || role == RoleModerator || role == RoleUser
+}
+
+// RoleHasCapability 角色是否拥有指定权限项
+func RoleHasCapability(role string, capability Capability) bool {
+	if role == RoleAdmin {
+		return true
+	}
+	for _, granted := range roleCapabilities[role] {
+		if granted == capability {
+			return true
+		}
+	}
+	return false
+}
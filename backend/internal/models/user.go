@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 	"gorm.io/gorm"
 	"golang.org/x/crypto/bcrypt"
@@ -14,11 +15,14 @@ type User struct {
 	Phone     string    `json:"phone" gorm:"unique"`
 	Password  string    `json:"-" gorm:"not null"` // 不在JSON中序列化
 	Avatar    string    `json:"avatar"`
-	Role      string    `json:"role" gorm:"default:user"` // admin, user
+	Role      string    `json:"role" gorm:"default:user"` // admin, moderator, user
 	Active    bool      `json:"active" gorm:"default:true"`
+	TenantID  string    `json:"tenant_id,omitempty" gorm:"size:64;index"` // 所属租户，为空表示单租户部署或平台级账号
+	Ghost     bool      `json:"-" gorm:"not null;default:false"` // 是否为接收已注销用户公开项目的占位账号
 	LastLogin *time.Time `json:"last_login"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"` // 账号注销（软删除）
 	
 	// 关联关系
 	Projects []Project `json:"projects,omitempty" gorm:"foreignKey:OwnerID"`
@@ -30,6 +34,20 @@ func (User) TableName() string {
 	return "users"
 }
 
+// PublicUser 用户公开信息视图，嵌入到其他资源（项目、设备等）的响应中时使用，
+// 不包含邮箱、手机号等敏感字段；完整信息仅通过/auth/me返回给本人
+type PublicUser struct {
+	ID       uint   `json:"id" gorm:"primarykey"`
+	Username string `json:"username"`
+	Avatar   string `json:"avatar"`
+	Role     string `json:"role"`
+}
+
+// TableName 指定表名
+func (PublicUser) TableName() string {
+	return "users"
+}
+
 // BeforeCreate GORM钩子：创建前加密密码
 func (u *User) BeforeCreate(tx *gorm.DB) error {
 	if u.Password != "" {
@@ -48,6 +66,14 @@ func (u *User) CheckPassword(password string) bool {
 	return err == nil
 }
 
+// GhostUsername 占位账号的用户名，注册时保留不可使用；占位账号本身通过Ghost标记识别
+const GhostUsername = "ghost"
+
+// IsReservedUsername 用户名是否为系统保留（不区分大小写）
+func IsReservedUsername(username string) bool {
+	return strings.EqualFold(strings.TrimSpace(username), GhostUsername)
+}
+
 // UpdateLastLogin 更新最后登录时间
 func (u *User) UpdateLastLogin(tx *gorm.DB) error {
 	now := time.Now()
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+	"github.com/lib/pq"
+)
+
+// Webhook事件类型
+const (
+	WebhookEventDataReceived  = "device.data"    // 收到设备数据
+	WebhookEventDeviceOffline = "device.offline" // 设备超时未上报转为离线
+	WebhookEventAlertFired    = "alert.fired"    // 读数超出字段合理范围
+)
+
+// WebhookEvents 支持订阅的事件类型
+var WebhookEvents = []string{
+	WebhookEventDataReceived,
+	WebhookEventDeviceOffline,
+	WebhookEventAlertFired,
+}
+
+// Webhook投递状态
+const (
+	DeliveryPending   = "pending"   // 等待（重新）投递
+	DeliverySucceeded = "succeeded" // 目标返回2xx
+	DeliveryDead      = "dead"      // 达到最大尝试次数，不再重试
+)
+
+// Webhook 用户配置的事件推送地址
+type Webhook struct {
+	ID        uint           `json:"id" gorm:"primarykey"`
+	OwnerID   uint           `json:"owner_id" gorm:"not null;index"`
+	URL       string         `json:"url" gorm:"not null"`
+	Events    pq.StringArray `json:"events" gorm:"type:text[]"`
+	Secret    string         `json:"-" gorm:"not null"` // HMAC签名密钥，仅创建时返回一次
+	Enabled   bool           `json:"enabled" gorm:"default:true"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (Webhook) TableName() string {
+	return "webhooks"
+}
+
+// Subscribes 是否订阅了指定事件
+func (w *Webhook) Subscribes(event string) bool {
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery 单次事件投递记录，同时作为重试队列和投递日志
+type WebhookDelivery struct {
+	ID             uint       `json:"id" gorm:"primarykey"`
+	WebhookID      uint       `json:"webhook_id" gorm:"not null;index"`
+	Event          string     `json:"event" gorm:"not null"`
+	Payload        JSONB      `json:"payload" gorm:"type:jsonb"`
+	Status         string     `json:"status" gorm:"not null;default:pending"` // pending, succeeded, dead
+	Attempts       int        `json:"attempts" gorm:"default:0"`
+	ResponseStatus int        `json:"response_status,omitempty"`
+	Error          string     `json:"error,omitempty"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"index"`
+}
+
+// TableName 指定表名
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
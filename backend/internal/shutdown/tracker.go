@@ -0,0 +1,84 @@
+package shutdown
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Tracker 记录正在运行的后台任务，关闭时等待它们结束后再关闭数据库和Redis
+type Tracker struct {
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	running map[string]int // 按名称统计仍在运行的任务数
+}
+
+// NewTracker 创建任务记录器
+func NewTracker() *Tracker {
+	return &Tracker{running: make(map[string]int)}
+}
+
+// Default 全局任务记录器
+var Default = NewTracker()
+
+// Track 登记一个开始运行的任务，任务结束时调用返回的函数（多次调用只生效一次）
+func (t *Tracker) Track(name string) func() {
+	t.mu.Lock()
+	t.running[name]++
+	t.mu.Unlock()
+	t.wg.Add(1)
+	
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.mu.Lock()
+			if t.running[name]--; t.running[name] <= 0 {
+				delete(t.running, name)
+			}
+			t.mu.Unlock()
+			t.wg.Done()
+		})
+	}
+}
+
+// Go 在登记的goroutine中运行fn
+func (t *Tracker) Go(name string, fn func()) {
+	done := t.Track(name)
+	go func() {
+		defer done()
+		fn()
+	}()
+}
+
+// Wait 等待所有任务结束或ctx结束，返回超时时仍在运行的任务（如"ingest worker x2"），全部结束时返回nil
+func (t *Tracker) Wait(ctx context.Context) []string {
+	finished := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(finished)
+	}()
+	
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return t.Running()
+	}
+}
+
+// Running 按名称排序的仍在运行的任务
+func (t *Tracker) Running() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	
+	names := make([]string, 0, len(t.running))
+	for name, count := range t.running {
+		if count > 1 {
+			name = fmt.Sprintf("%s x%d", name, count)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,119 @@
+package shutdown
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTrackerWaitsForRunningTasks(t *testing.T) {
+	tracker := NewTracker()
+	release := make(chan struct{})
+	tracker.Go("reading webhooks", func() { <-release })
+	done := tracker.Track("import job")
+
+	waited := make(chan []string)
+	go func() { waited <- tracker.Wait(context.Background()) }()
+
+	select {
+	case <-waited:
+		t.Fatal("Wait() returned while tasks were still running")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	done()
+	done() // 重复调用不影响计数
+	select {
+	case unfinished := <-waited:
+		if unfinished != nil {
+			t.Errorf("Wait() = %v, want nil once all tasks finished", unfinished)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Wait() did not return after the tasks finished")
+	}
+	if running := tracker.Running(); len(running) != 0 {
+		t.Errorf("Running() = %v, want none", running)
+	}
+}
+
+func TestTrackerWaitTimeout(t *testing.T) {
+	tracker := NewTracker()
+	release := make(chan struct{})
+	defer close(release)
+	for _, name := range []string{"ingest worker", "ingest worker", "reading alerts"} {
+		tracker.Go(name, func() { <-release })
+	}
+	tracker.Go("finished", func() {})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	want := []string{"ingest worker x2", "reading alerts"}
+	if unfinished := tracker.Wait(ctx); !reflect.DeepEqual(unfinished, want) {
+		t.Errorf("Wait() = %v, want %v", unfinished, want)
+	}
+}
+
+// TestShutdownWaitsForInFlightRequests 按main中的顺序关闭：先等进行中的请求，再等请求启动的后台任务
+func TestShutdownWaitsForInFlightRequests(t *testing.T) {
+	tracker := NewTracker()
+	started := make(chan struct{})
+	releaseRequest := make(chan struct{})
+	releaseTask := make(chan struct{})
+	var taskFinished bool
+
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tracker.Go("reading webhooks", func() {
+			<-releaseTask
+			taskFinished = true
+		})
+		close(started)
+		<-releaseRequest
+		w.WriteHeader(http.StatusAccepted)
+	})}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	go srv.Serve(listener)
+
+	response := make(chan int, 1)
+	go func() {
+		resp, err := http.Post("http://"+listener.Addr().String()+"/api/v1/devices/d1/data", "application/json", nil)
+		if err != nil {
+			response <- 0
+			return
+		}
+		resp.Body.Close()
+		response <- resp.StatusCode
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	stopped := make(chan []string)
+	go func() {
+		if err := srv.Shutdown(ctx); err != nil {
+			t.Errorf("Shutdown() = %v", err)
+		}
+		stopped <- tracker.Wait(ctx)
+	}()
+
+	close(releaseRequest)
+	if status := <-response; status != http.StatusAccepted {
+		t.Fatalf("in-flight request got status %d, want %d", status, http.StatusAccepted)
+	}
+	select {
+	case <-stopped:
+		t.Fatal("shutdown finished before the background task")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(releaseTask)
+	if unfinished := <-stopped; unfinished != nil || !taskFinished {
+		t.Errorf("Wait() = %v (task finished: %v), want the task to complete", unfinished, taskFinished)
+	}
+}
@@ -0,0 +1,176 @@
+// Package testutil 测试辅助：加载默认配置、内存Redis和可选的PostgreSQL测试库
+package testutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"iot-platform-backend/internal/config"
+	"iot-platform-backend/internal/database"
+)
+
+// DatabaseDSNEnv 指定PostgreSQL测试库的环境变量，未设置时依赖数据库的测试会被跳过
+// 每个测试包使用独立的schema（test_<包名>），运行时会删除重建，不要指向业务数据库
+const DatabaseDSNEnv = "TEST_DATABASE_DSN"
+
+var (
+	setupOnce sync.Once
+	setupErr  error
+	schemaDSN string
+)
+
+// LoadConfig 加载默认配置（叠加当前环境变量）并设为config.AppConfig，测试结束后恢复
+func LoadConfig(t testing.TB) *config.Config {
+	t.Helper()
+	previous := config.AppConfig
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	t.Cleanup(func() { config.AppConfig = previous })
+	return cfg
+}
+
+// UseRedis 启动内存Redis并设为database.RedisClient，测试结束后恢复
+func UseRedis(t testing.TB) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	previous := database.RedisClient
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	database.RedisClient = client
+	t.Cleanup(func() {
+		client.Close()
+		database.RedisClient = previous
+	})
+	return mr
+}
+
+// UseDB 连接TEST_DATABASE_DSN指定的PostgreSQL并设为database.DB，首次调用时执行迁移，
+// 每个测试开始前清空所有表；未配置时跳过测试
+func UseDB(t testing.TB) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv(DatabaseDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s not set, skipping test that needs PostgreSQL", DatabaseDSNEnv)
+	}
+	
+	setupOnce.Do(func() {
+		schemaDSN, setupErr = setupSchema(dsn)
+	})
+	if setupErr != nil {
+		t.Fatalf("failed to prepare test database: %v", setupErr)
+	}
+	
+	db, err := OpenDB(schemaDSN)
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		database.DB = previous
+	})
+	
+	if err := truncateAll(db); err != nil {
+		t.Fatalf("failed to reset test database: %v", err)
+	}
+	return db
+}
+
+// OpenDB 按与database.Connect相同的GORM配置打开连接
+func OpenDB(dsn string) (*gorm.DB, error) {
+	return gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger:         logger.Default.LogMode(logger.Silent),
+		TranslateError: true,
+		NowFunc: func() time.Time {
+			return time.Now().UTC()
+		},
+	})
+}
+
+// WithSearchPath 为DSN（URL或key=value格式）追加search_path
+func WithSearchPath(dsn, schema string) string {
+	if strings.Contains(dsn, "://") {
+		separator := "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+		return dsn + separator + "search_path=" + schema
+	}
+	return dsn + " search_path=" + schema
+}
+
+// setupSchema 为当前测试包重建独立的schema并执行迁移，返回指向该schema的DSN
+// 不同的测试包并行运行时互不影响
+func setupSchema(dsn string) (string, error) {
+	schema := "test_" + strings.TrimSuffix(filepath.Base(os.Args[0]), ".test")
+	schema = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(schema))
+	
+	admin, err := OpenDB(dsn)
+	if err != nil {
+		return "", err
+	}
+	if sqlDB, err := admin.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+	for _, statement := range []string{
+		fmt.Sprintf("DROP SCHEMA IF EXISTS %q CASCADE", schema),
+		fmt.Sprintf("CREATE SCHEMA %q", schema),
+	} {
+		if err := admin.Exec(statement).Error; err != nil {
+			return "", err
+		}
+	}
+	
+	scoped := WithSearchPath(dsn, schema)
+	db, err := OpenDB(scoped)
+	if err != nil {
+		return "", err
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+	
+	previous := database.DB
+	database.DB = db
+	defer func() { database.DB = previous }()
+	if err := database.Migrate(); err != nil {
+		return "", err
+	}
+	return scoped, nil
+}
+
+// truncateAll 清空当前schema下除迁移指纹外的所有表
+func truncateAll(db *gorm.DB) error {
+	var tables []string
+	if err := db.Raw(`SELECT tablename FROM pg_tables
+		WHERE schemaname = current_schema() AND tablename <> 'schema_fingerprints'`).
+		Scan(&tables).Error; err != nil {
+		return err
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+	for i, table := range tables {
+		tables[i] = fmt.Sprintf("%q", table)
+	}
+	return db.Exec("TRUNCATE " + strings.Join(tables, ", ") + " RESTART IDENTITY CASCADE").Error
+}
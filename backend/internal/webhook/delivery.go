@@ -0,0 +1,236 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"iot-platform-backend/internal/config"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/models"
+)
+
+// 投递请求头
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature" // sha256=hex(HMAC-SHA256(secret, timestamp + "." + body))
+)
+
+// Dispatcher 从投递记录表中取出到期的记录并发送
+type Dispatcher struct {
+	client *http.Client
+	cfg    config.WebhookConfig
+}
+
+// Default 全局投递器
+var Default *Dispatcher
+
+// Init 初始化全局投递器
+func Init(cfg config.WebhookConfig) {
+	Default = NewDispatcher(cfg)
+}
+
+// NewDispatcher 创建投递器，HTTP客户端不跟随重定向且拒绝连接内网地址
+func NewDispatcher(cfg config.WebhookConfig) *Dispatcher {
+	transport := &http.Transport{
+		DialContext:           newSafeDialer(cfg.Timeout, cfg.AllowPrivate).DialContext,
+		TLSHandshakeTimeout:   cfg.Timeout,
+		ResponseHeaderTimeout: cfg.Timeout,
+		MaxIdleConnsPerHost:   2,
+		IdleConnTimeout:       90 * time.Second,
+	}
+	return &Dispatcher{
+		cfg: cfg,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   cfg.Timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Sign 计算投递签名
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff 第attempts次失败后的重试等待时间：base*2^(attempts-1)，不超过max
+func Backoff(base, max time.Duration, attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	delay := base
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if max > 0 && delay >= max {
+			return max
+		}
+	}
+	if max > 0 && delay > max {
+		return max
+	}
+	return delay
+}
+
+// ProcessDue 发送到期的待投递记录，每轮最多BatchSize条
+// 记录逐条以SKIP LOCKED方式领取并顺延下次投递时间，租期只需覆盖单次发送，
+// 多实例部署时不会重复发送，也不会因前面的记录发送缓慢而让后面的记录租期过期
+func (d *Dispatcher) ProcessDue(ctx context.Context) error {
+	batchSize := d.cfg.BatchSize
+	if batchSize < 1 {
+		batchSize = 50
+	}
+	
+	hooksByID := make(map[uint]*models.Webhook)
+	for i := 0; i < batchSize; i++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		
+		delivery, err := d.claimNext(ctx)
+		if err != nil || delivery == nil {
+			return err
+		}
+		
+		hook, ok := hooksByID[delivery.WebhookID]
+		if !ok {
+			var found models.Webhook
+			err := database.GetDBWithContext(ctx).First(&found, delivery.WebhookID).Error
+			switch {
+			case err == nil:
+				hook = &found
+			case !errors.Is(err, gorm.ErrRecordNotFound):
+				return err
+			}
+			hooksByID[delivery.WebhookID] = hook
+		}
+		d.deliver(ctx, hook, delivery)
+	}
+	return nil
+}
+
+// claimNext 领取一条到期记录并将下次投递时间顺延一个租期，没有到期记录时返回nil
+func (d *Dispatcher) claimNext(ctx context.Context) (*models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := database.TransactionWithContext(ctx, func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, time.Now()).
+			Order("next_attempt_at ASC").
+			Limit(1).
+			Find(&deliveries).Error; err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+		
+		lease := time.Now().Add(2 * d.cfg.Timeout)
+		return tx.Model(&deliveries[0]).Update("next_attempt_at", lease).Error
+	})
+	if err != nil || len(deliveries) == 0 {
+		return nil, err
+	}
+	return &deliveries[0], nil
+}
+
+// PurgeFinished 删除超过保留时长的已成功或已放弃的投递记录
+func PurgeFinished(ctx context.Context, retention time.Duration) (int64, error) {
+	result := database.GetDBWithContext(ctx).
+		Where("status IN ? AND created_at < ?", []string{models.DeliverySucceeded, models.DeliveryDead}, time.Now().Add(-retention)).
+		Delete(&models.WebhookDelivery{})
+	return result.RowsAffected, result.Error
+}
+
+// deliver 发送单条记录并保存结果，失败时按退避策略安排重试或标记为dead
+func (d *Dispatcher) deliver(ctx context.Context, hook *models.Webhook, delivery *models.WebhookDelivery) {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"last_attempt_at": now,
+	}
+	
+	if hook == nil || !hook.Enabled {
+		updates["status"] = models.DeliveryDead
+		updates["error"] = "webhook disabled or deleted"
+		database.GetDBWithContext(ctx).Model(delivery).Updates(updates)
+		return
+	}
+	
+	attempts := delivery.Attempts + 1
+	updates["attempts"] = attempts
+	
+	status, err := d.send(ctx, hook, delivery)
+	updates["response_status"] = status
+	switch {
+	case err == nil:
+		updates["status"] = models.DeliverySucceeded
+		updates["error"] = ""
+	case attempts >= d.cfg.MaxAttempts:
+		updates["status"] = models.DeliveryDead
+		updates["error"] = err.Error()
+		log.Printf("Webhook delivery %d to webhook %d dead after %d attempt(s): %v", delivery.ID, hook.ID, attempts, err)
+	default:
+		updates["error"] = err.Error()
+		updates["next_attempt_at"] = now.Add(Backoff(d.cfg.BackoffBase, d.cfg.BackoffMax, attempts))
+	}
+	
+	if err := database.GetDBWithContext(ctx).Model(delivery).Updates(updates).Error; err != nil {
+		log.Printf("Failed to record webhook delivery %d: %v", delivery.ID, err)
+	}
+}
+
+// send 发送签名后的事件，非2xx响应视为失败
+func (d *Dispatcher) send(ctx context.Context, hook *models.Webhook, delivery *models.WebhookDelivery) (int, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"id":         delivery.ID,
+		"event":      delivery.Event,
+		"created_at": delivery.CreatedAt,
+		"data":       delivery.Payload,
+	})
+	if err != nil {
+		return 0, err
+	}
+	
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "iot-platform-webhook/1.0")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, timestamp, body))
+	
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrBlockedAddress 目标地址属于内网、回环或链路本地地址
+var ErrBlockedAddress = errors.New("webhook target resolves to a blocked address")
+
+// carrierGradeNAT 运营商级NAT地址段（100.64.0.0/10），net.IP.IsPrivate不包含该段
+var carrierGradeNAT = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// isBlockedIP 是否为不允许投递的地址（防止SSRF访问内网服务和云元数据接口）
+func isBlockedIP(ip net.IP) bool {
+	return ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() ||
+		carrierGradeNAT.Contains(ip)
+}
+
+// ValidateURL 校验Webhook地址：仅允许http/https，且（除非allowPrivate）主机不能解析到内网地址
+func ValidateURL(ctx context.Context, raw string, allowPrivate bool) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("URL scheme must be http or https")
+	}
+	host := u.Hostname()
+	if host == "" {
+		return fmt.Errorf("URL host is required")
+	}
+	if u.User != nil {
+		return fmt.Errorf("URL must not contain credentials")
+	}
+	if allowPrivate {
+		return nil
+	}
+	
+	if ip := net.ParseIP(host); ip != nil {
+		if isBlockedIP(ip) {
+			return ErrBlockedAddress
+		}
+		return nil
+	}
+	
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("failed to resolve host %q: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if isBlockedIP(addr.IP) {
+			return ErrBlockedAddress
+		}
+	}
+	return nil
+}
+
+// newSafeDialer 创建在建立连接时再次校验目标IP的拨号器，防止DNS重绑定绕过创建时的校验
+func newSafeDialer(timeout time.Duration, allowPrivate bool) *net.Dialer {
+	dialer := &net.Dialer{Timeout: timeout}
+	if allowPrivate {
+		return dialer
+	}
+	dialer.Control = func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		ip := net.ParseIP(host)
+		if ip == nil || isBlockedIP(ip) {
+			return ErrBlockedAddress
+		}
+		return nil
+	}
+	return dialer
+}
@@ -0,0 +1,69 @@
+package webhook
+
+import (
+	"context"
+	"time"
+	
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/models"
+)
+
+// Emit 为订阅了该事件的用户Webhook创建待投递记录，由后台任务异步发送
+func Emit(ctx context.Context, ownerID uint, event string, data models.JSONB) error {
+	db := database.GetDBWithContext(ctx)
+	
+	var hooks []models.Webhook
+	if err := db.Where("owner_id = ? AND enabled = ? AND ? = ANY(events)", ownerID, true, event).
+		Find(&hooks).Error; err != nil {
+		return err
+	}
+	if len(hooks) == 0 {
+		return nil
+	}
+	
+	now := time.Now()
+	deliveries := make([]models.WebhookDelivery, 0, len(hooks))
+	for _, hook := range hooks {
+		deliveries = append(deliveries, models.WebhookDelivery{
+			WebhookID:     hook.ID,
+			Event:         event,
+			Payload:       data,
+			Status:        models.DeliveryPending,
+			NextAttemptAt: now,
+		})
+	}
+	return db.Create(&deliveries).Error
+}
+
+// EmitReading 触发设备数据事件，读数超出字段合理范围时同时触发告警事件（维护中的设备不告警）
+func EmitReading(ctx context.Context, device models.Device, reading models.SensorData) error {
+	data := models.JSONB{
+		"device_id": device.DeviceID,
+		"name":      device.Name,
+		"type":      device.Type,
+		"data":      reading.Data,
+		"timestamp": reading.Timestamp,
+	}
+	if err := Emit(ctx, device.OwnerID, models.WebhookEventDataReceived, data); err != nil {
+		return err
+	}
+	
+	if device.InMaintenance(time.Now()) {
+		return nil
+	}
+	meta, ok := models.GetDeviceTypeMeta(device.Type)
+	if !ok {
+		return nil
+	}
+	violations := meta.Violations(reading.Data)
+	if len(violations) == 0 {
+		return nil
+	}
+	return Emit(ctx, device.OwnerID, models.WebhookEventAlertFired, models.JSONB{
+		"device_id":  device.DeviceID,
+		"name":       device.Name,
+		"type":       device.Type,
+		"violations": violations,
+		"timestamp":  reading.Timestamp,
+	})
+}
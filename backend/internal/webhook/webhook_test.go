@@ -0,0 +1,49 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/testutil"
+)
+
+func TestEmitReadingMaintenance(t *testing.T) {
+	testutil.LoadConfig(t)
+	db := testutil.UseDB(t)
+
+	hook := models.Webhook{OwnerID: 1, URL: "https://example.com/hook", Secret: "s", Events: pq.StringArray{models.WebhookEventDataReceived, models.WebhookEventAlertFired}, Enabled: true}
+	if err := db.Create(&hook).Error; err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+	until := time.Now().Add(time.Hour)
+	reading := models.SensorData{Data: models.JSONB{"soil_humidity": 150.0}, Timestamp: time.Now()}
+
+	tests := []struct {
+		name   string
+		device models.Device
+		want   string
+	}{
+		{"normal device", models.Device{DeviceID: "normal", Type: models.SoilMoisture, OwnerID: 1}, "[alert.fired device.data]"},
+		// 维护中的设备仍推送数据事件，但不告警
+		{"device in maintenance", models.Device{DeviceID: "maintenance", Type: models.SoilMoisture, OwnerID: 1, Maintenance: true, MaintenanceUntil: &until}, "[device.data]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := EmitReading(context.Background(), tt.device, reading); err != nil {
+				t.Fatalf("EmitReading() error = %v", err)
+			}
+			var events []string
+			db.Model(&models.WebhookDelivery{}).
+				Where("payload->>'device_id' = ?", tt.device.DeviceID).
+				Order("event").
+				Pluck("event", &events)
+			if fmt.Sprint(events) != tt.want {
+				t.Errorf("events = %v, want %s", events, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,541 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+	
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/models"
+)
+
+// 聚合订阅的消息类型
+const (
+	TypeSubscribeAggregate   MessageType = "subscribe_aggregate"
+	TypeUnsubscribeAggregate MessageType = "unsubscribe_aggregate"
+	TypeAggregate            MessageType = "aggregate"
+)
+
+// 聚合订阅的限制
+const (
+	defaultAggregateInterval   = 10 * time.Second
+	minAggregateInterval       = time.Second
+	maxAggregateWindow         = time.Hour
+	maxAggregatesPerClient     = 10
+	maxAggregateDevices        = 500
+	maxAggregateReadingsPerDev = 1000 // 每台设备在窗口内缓存的最大读数条数
+)
+
+// 支持的聚合函数
+var aggregateFuncs = map[string]bool{"avg": true, "min": true, "max": true, "sum": true, "count": true}
+
+// AggregateSpec 聚合订阅参数
+// 每个周期先对每台设备窗口内的读数取平均，再在设备之间计算聚合函数，上报频率高的设备不会占更大权重
+type AggregateSpec struct {
+	ID        string   `json:"id"`                 // 订阅ID，用于取消订阅和区分推送，不指定时自动生成
+	GroupID   uint     `json:"group_id,omitempty"` // 设备分组（包含子孙分组中的设备）
+	DeviceIDs []string `json:"device_ids,omitempty"`
+	Field     string   `json:"field"`     // 数值字段，嵌套字段以点号连接
+	Functions []string `json:"functions"` // avg、min、max、sum、count，默认avg
+	Interval  string   `json:"interval"`  // 推送间隔，默认10s，最小1s
+	Window    string   `json:"window"`    // 参与聚合的读数时间窗口，默认与interval相同，最长1h
+}
+
+// AggregateResult 一次聚合推送的数据
+type AggregateResult struct {
+	ID         string              `json:"id"`
+	Field      string              `json:"field"`
+	WindowFrom time.Time           `json:"window_from"`
+	WindowTo   time.Time           `json:"window_to"`
+	Devices    int                 `json:"devices"`  // 窗口内有读数的设备数
+	Readings   int                 `json:"readings"` // 窗口内的读数条数
+	Values     map[string]*float64 `json:"values"`   // 没有读数时为null
+}
+
+// aggregatePoint 缓存的单条读数
+type aggregatePoint struct {
+	at    time.Time
+	value float64
+}
+
+// aggregateSub 一个聚合订阅，持有订阅设备在窗口内的读数
+type aggregateSub struct {
+	id        string
+	client    *Client
+	field     string
+	functions []string
+	interval  time.Duration
+	window    time.Duration
+	devices   []string
+	stop      chan struct{}
+	
+	mu     sync.Mutex
+	points map[string][]aggregatePoint
+}
+
+// aggregateRegistry 管理所有聚合订阅，按设备索引以便写入读数
+type aggregateRegistry struct {
+	mu       sync.RWMutex
+	byClient map[string]map[string]*aggregateSub // 客户端ID -> 订阅ID -> 订阅
+	byDevice map[string]map[*aggregateSub]bool
+}
+
+func newAggregateRegistry() *aggregateRegistry {
+	return &aggregateRegistry{
+		byClient: make(map[string]map[string]*aggregateSub),
+		byDevice: make(map[string]map[*aggregateSub]bool),
+	}
+}
+
+// add 注册订阅并启动定时推送；同一客户端的同ID订阅会被替换
+func (r *aggregateRegistry) add(sub *aggregateSub) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	
+	subs := r.byClient[sub.client.ID]
+	if existing, ok := subs[sub.id]; ok {
+		r.removeLocked(existing)
+		subs = r.byClient[sub.client.ID]
+	}
+	if len(subs) >= maxAggregatesPerClient {
+		return newCodedError(ErrCodeLimitExceeded, fmt.Sprintf("at most %d aggregate subscriptions per connection", maxAggregatesPerClient))
+	}
+	
+	if subs == nil {
+		subs = make(map[string]*aggregateSub)
+		r.byClient[sub.client.ID] = subs
+	}
+	subs[sub.id] = sub
+	for _, deviceID := range sub.devices {
+		if r.byDevice[deviceID] == nil {
+			r.byDevice[deviceID] = make(map[*aggregateSub]bool)
+		}
+		r.byDevice[deviceID][sub] = true
+	}
+	
+	go sub.run()
+	return nil
+}
+
+// remove 取消客户端的一个订阅，返回订阅是否存在
+func (r *aggregateRegistry) remove(clientID, id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	
+	sub, ok := r.byClient[clientID][id]
+	if ok {
+		r.removeLocked(sub)
+	}
+	return ok
+}
+
+// removeClient 取消客户端的所有订阅，连接断开时调用
+func (r *aggregateRegistry) removeClient(clientID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	
+	for _, sub := range r.byClient[clientID] {
+		r.removeLocked(sub)
+	}
+}
+
+// removeLocked 停止订阅的定时器并移除索引（调用方需持有写锁）
+func (r *aggregateRegistry) removeLocked(sub *aggregateSub) {
+	close(sub.stop)
+	if subs := r.byClient[sub.client.ID]; subs != nil {
+		delete(subs, sub.id)
+		if len(subs) == 0 {
+			delete(r.byClient, sub.client.ID)
+		}
+	}
+	for _, deviceID := range sub.devices {
+		if subs := r.byDevice[deviceID]; subs != nil {
+			delete(subs, sub)
+			if len(subs) == 0 {
+				delete(r.byDevice, deviceID)
+			}
+		}
+	}
+}
+
+// count 当前的订阅总数
+func (r *aggregateRegistry) count() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	
+	total := 0
+	for _, subs := range r.byClient {
+		total += len(subs)
+	}
+	return total
+}
+
+// clientCounts 各客户端的聚合订阅数
+func (r *aggregateRegistry) clientCounts() map[string]int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	
+	counts := make(map[string]int, len(r.byClient))
+	for clientID, subs := range r.byClient {
+		counts[clientID] = len(subs)
+	}
+	return counts
+}
+
+// observe 将设备读数写入订阅了该设备的聚合订阅
+func (r *aggregateRegistry) observe(deviceID string, message Message) {
+	r.mu.RLock()
+	subs := r.byDevice[deviceID]
+	if len(subs) == 0 {
+		r.mu.RUnlock()
+		return
+	}
+	targets := make([]*aggregateSub, 0, len(subs))
+	for sub := range subs {
+		targets = append(targets, sub)
+	}
+	r.mu.RUnlock()
+	
+	payload, ok := message.Data.(map[string]interface{})
+	if !ok {
+		return
+	}
+	data, ok := asObject(payload["data"])
+	if !ok {
+		return
+	}
+	at := message.Timestamp
+	if timestamp, ok := payload["timestamp"].(time.Time); ok && !timestamp.IsZero() {
+		at = timestamp
+	}
+	
+	for _, sub := range targets {
+		if value, ok := lookupFieldNumber(data, sub.field); ok {
+			sub.add(deviceID, aggregatePoint{at: at, value: value})
+		}
+	}
+}
+
+// add 缓存一条读数
+func (s *aggregateSub) add(deviceID string, point aggregatePoint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	points := append(s.points[deviceID], point)
+	if len(points) > maxAggregateReadingsPerDev {
+		points = points[len(points)-maxAggregateReadingsPerDev:]
+	}
+	s.points[deviceID] = points
+}
+
+// run 按间隔推送聚合结果，直到订阅被取消
+func (s *aggregateSub) run() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.client.trySend(Message{
+				Type:      TypeAggregate,
+				ID:        s.id,
+				Data:      s.compute(now),
+				Timestamp: now,
+			})
+		}
+	}
+}
+
+// compute 丢弃窗口之外的读数并计算聚合结果
+func (s *aggregateSub) compute(now time.Time) AggregateResult {
+	from := now.Add(-s.window)
+	result := AggregateResult{
+		ID:         s.id,
+		Field:      s.field,
+		WindowFrom: from,
+		WindowTo:   now,
+		Values:     make(map[string]*float64, len(s.functions)),
+	}
+	
+	var means []float64
+	s.mu.Lock()
+	for deviceID, points := range s.points {
+		kept := points[:0]
+		var sum float64
+		for _, point := range points {
+			if point.at.After(from) {
+				kept = append(kept, point)
+				sum += point.value
+			}
+		}
+		if len(kept) == 0 {
+			delete(s.points, deviceID)
+			continue
+		}
+		s.points[deviceID] = kept
+		means = append(means, sum/float64(len(kept)))
+		result.Readings += len(kept)
+	}
+	s.mu.Unlock()
+	
+	result.Devices = len(means)
+	for _, fn := range s.functions {
+		result.Values[fn] = aggregateValue(fn, means)
+	}
+	return result
+}
+
+// aggregateValue 在设备之间计算聚合函数，没有数据时返回nil（count返回0）
+func aggregateValue(fn string, values []float64) *float64 {
+	if fn == "count" {
+		count := float64(len(values))
+		return &count
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	
+	var result float64
+	switch fn {
+	case "min":
+		result = math.Inf(1)
+		for _, v := range values {
+			result = math.Min(result, v)
+		}
+	case "max":
+		result = math.Inf(-1)
+		for _, v := range values {
+			result = math.Max(result, v)
+		}
+	default:
+		for _, v := range values {
+			result += v
+		}
+		if fn == "avg" {
+			result /= float64(len(values))
+		}
+	}
+	return &result
+}
+
+// handleSubscribeAggregate 处理聚合订阅请求
+func (c *Client) handleSubscribeAggregate(msg Message) {
+	spec, err := decodeAggregateSpec(msg.Data)
+	if err != nil {
+		c.sendError(ErrCodeInvalidMessage, "Invalid aggregate subscription: "+err.Error())
+		return
+	}
+	if c.UserID == 0 {
+		c.sendError(ErrCodeUnauthorized, "Authentication required for aggregate subscriptions")
+		return
+	}
+	
+	sub, denied, err := c.newAggregateSub(spec)
+	if err != nil {
+		c.sendError(errorCodeOf(err, ErrCodeInvalidMessage), "Invalid aggregate subscription: "+err.Error())
+		return
+	}
+	if err := c.Manager.aggregates.add(sub); err != nil {
+		c.sendError(errorCodeOf(err, ErrCodeInternal), err.Error())
+		return
+	}
+	
+	log.Printf("Client %s subscribed to aggregate %s over %d device(s)", c.ID, sub.id, len(sub.devices))
+	c.sendNotification(map[string]interface{}{
+		"message":   "Aggregate subscribed",
+		"id":        sub.id,
+		"field":     sub.field,
+		"functions": sub.functions,
+		"interval":  sub.interval.String(),
+		"window":    sub.window.String(),
+		"devices":   sub.devices,
+		"denied":    denied,
+	})
+}
+
+// handleUnsubscribeAggregate 处理取消聚合订阅请求
+func (c *Client) handleUnsubscribeAggregate(msg Message) {
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		c.sendError(ErrCodeInvalidMessage, "Invalid aggregate unsubscribe message: data must be an object")
+		return
+	}
+	id, _ := data["id"].(string)
+	if id == "" || !c.Manager.aggregates.remove(c.ID, id) {
+		c.sendError(ErrCodeNotFound, "Aggregate subscription not found")
+		return
+	}
+	c.sendNotification(map[string]interface{}{
+		"message": "Aggregate unsubscribed",
+		"id":      id,
+	})
+}
+
+// decodeAggregateSpec 解析订阅参数
+func decodeAggregateSpec(data interface{}) (AggregateSpec, error) {
+	var spec AggregateSpec
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return spec, err
+	}
+	if err := json.Unmarshal(raw, &spec); err != nil {
+		return spec, fmt.Errorf("malformed request")
+	}
+	
+	spec.Field = strings.TrimSpace(spec.Field)
+	if spec.Field == "" {
+		return spec, fmt.Errorf("field is required")
+	}
+	if spec.GroupID == 0 && len(spec.DeviceIDs) == 0 {
+		return spec, fmt.Errorf("group_id or device_ids is required")
+	}
+	if len(spec.Functions) == 0 {
+		spec.Functions = []string{"avg"}
+	}
+	for _, fn := range spec.Functions {
+		if !aggregateFuncs[fn] {
+			return spec, fmt.Errorf("unknown function %q", fn)
+		}
+	}
+	return spec, nil
+}
+
+// newAggregateSub 校验时间参数和设备权限并创建订阅，返回无权访问的设备
+func (c *Client) newAggregateSub(spec AggregateSpec) (*aggregateSub, []string, error) {
+	interval := defaultAggregateInterval
+	if spec.Interval != "" {
+		parsed, err := time.ParseDuration(spec.Interval)
+		if err != nil || parsed < minAggregateInterval {
+			return nil, nil, fmt.Errorf("interval must be a duration of at least %s", minAggregateInterval)
+		}
+		interval = parsed
+	}
+	window := interval
+	if spec.Window != "" {
+		parsed, err := time.ParseDuration(spec.Window)
+		if err != nil || parsed <= 0 || parsed > maxAggregateWindow {
+			return nil, nil, fmt.Errorf("window must be a positive duration of at most %s", maxAggregateWindow)
+		}
+		window = parsed
+	}
+	
+	deviceIDs := spec.DeviceIDs
+	if spec.GroupID != 0 {
+		groupDevices, err := c.groupDeviceIDs(spec.GroupID)
+		if err != nil {
+			return nil, nil, err
+		}
+		deviceIDs = append(deviceIDs, groupDevices...)
+	}
+	deviceIDs = uniqueDeviceIDs(deviceIDs)
+	if len(deviceIDs) > maxAggregateDevices {
+		return nil, nil, fmt.Errorf("at most %d devices per aggregate subscription", maxAggregateDevices)
+	}
+	
+	allowed := c.authorizeDevices(deviceIDs)
+	var devices, denied []string
+	for _, deviceID := range deviceIDs {
+		if allowed[deviceID] {
+			devices = append(devices, deviceID)
+		} else {
+			denied = append(denied, deviceID)
+		}
+	}
+	if len(devices) == 0 {
+		return nil, denied, newCodedError(ErrCodeDeviceNotFound, "no accessible devices")
+	}
+	
+	id := spec.ID
+	if id == "" {
+		id = generateRandomString(12)
+	}
+	return &aggregateSub{
+		id:        id,
+		client:    c,
+		field:     spec.Field,
+		functions: spec.Functions,
+		interval:  interval,
+		window:    window,
+		devices:   devices,
+		stop:      make(chan struct{}),
+		points:    make(map[string][]aggregatePoint),
+	}, denied, nil
+}
+
+// groupDeviceIDs 返回分组及其子孙分组中的设备；分组须属于当前用户，管理员只能访问本租户用户的分组
+func (c *Client) groupDeviceIDs(groupID uint) ([]string, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, newCodedError(ErrCodeInternal, "device groups are unavailable")
+	}
+	
+	var found int64
+	if err := c.scopeOwned(db.Model(&models.DeviceGroup{}).Where("id = ?", groupID)).Count(&found).Error; err != nil || found == 0 {
+		return nil, newCodedError(ErrCodeNotFound, "device group not found")
+	}
+	
+	var deviceIDs []string
+	err := db.Raw(`WITH RECURSIVE subtree AS (
+			SELECT id FROM device_groups WHERE id = ?
+			UNION
+			SELECT g.id FROM device_groups g JOIN subtree s ON g.parent_id = s.id
+		)
+		SELECT device_id FROM devices WHERE group_id IN (SELECT id FROM subtree) AND deleted_at IS NULL`, groupID).
+		Scan(&deviceIDs).Error
+	if err != nil {
+		return nil, newCodedError(ErrCodeInternal, "failed to load device group")
+	}
+	return deviceIDs, nil
+}
+
+// uniqueDeviceIDs 去除空值和重复项，保持原有顺序
+func uniqueDeviceIDs(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	result := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// lookupFieldNumber 按点号分隔的路径查找数值字段
+func lookupFieldNumber(data map[string]interface{}, path string) (float64, bool) {
+	var value interface{} = data
+	for _, key := range strings.Split(path, ".") {
+		object, ok := asObject(value)
+		if !ok {
+			return 0, false
+		}
+		if value, ok = object[key]; !ok {
+			return 0, false
+		}
+	}
+	switch v := value.(type) {
+	case float64:
+		return v, !math.IsNaN(v) && !math.IsInf(v, 0)
+	case json.Number:
+		number, err := v.Float64()
+		return number, err == nil
+	}
+	return 0, false
+}
+
+// asObject 判断值是否为JSON对象
+func asObject(value interface{}) (map[string]interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v, true
+	case models.JSONB:
+		return map[string]interface{}(v), true
+	}
+	return nil, false
+}
@@ -0,0 +1,196 @@
+package websocket
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestAggregate 创建不经过权限校验的聚合订阅
+func newTestAggregate(client *Client, id string, interval, window time.Duration, devices ...string) *aggregateSub {
+	return &aggregateSub{
+		id:        id,
+		client:    client,
+		field:     "env.temperature",
+		functions: []string{"avg", "min", "max", "sum", "count"},
+		interval:  interval,
+		window:    window,
+		devices:   devices,
+		stop:      make(chan struct{}),
+		points:    make(map[string][]aggregatePoint),
+	}
+}
+
+// deviceReading 构造SendToDevice推送的设备数据消息
+func deviceReading(deviceID string, temperature interface{}, at time.Time) Message {
+	return Message{
+		Type: TypeDeviceData,
+		Data: map[string]interface{}{
+			"device_id": deviceID,
+			"data":      map[string]interface{}{"env": map[string]interface{}{"temperature": temperature}},
+			"timestamp": at,
+		},
+		Timestamp: at,
+	}
+}
+
+func TestAggregateCompute(t *testing.T) {
+	m := NewManager()
+	now := time.Now()
+	sub := newTestAggregate(newTestClient(m, 1, now), "temps", time.Minute, time.Minute, "a", "b", "c")
+	m.aggregates.byDevice["a"] = map[*aggregateSub]bool{sub: true}
+	m.aggregates.byDevice["b"] = map[*aggregateSub]bool{sub: true}
+	m.aggregates.byDevice["c"] = map[*aggregateSub]bool{sub: true}
+
+	// 设备a上报两次，按设备平均后与b同权；窗口外和非数值的读数不参与聚合
+	m.aggregates.observe("a", deviceReading("a", 10.0, now.Add(-10*time.Second)))
+	m.aggregates.observe("a", deviceReading("a", 20.0, now.Add(-5*time.Second)))
+	m.aggregates.observe("b", deviceReading("b", json.Number("30"), now.Add(-5*time.Second)))
+	m.aggregates.observe("b", deviceReading("b", 1000.0, now.Add(-2*time.Minute)))
+	m.aggregates.observe("c", deviceReading("c", 50.0, now.Add(-2*time.Minute)))
+	m.aggregates.observe("c", deviceReading("c", "n/a", now))
+	m.aggregates.observe("d", deviceReading("d", 99.0, now))
+
+	result := sub.compute(now)
+	if result.Devices != 2 || result.Readings != 3 {
+		t.Errorf("devices = %d, readings = %d, want 2 and 3", result.Devices, result.Readings)
+	}
+	want := map[string]float64{"avg": 22.5, "min": 15, "max": 30, "sum": 45, "count": 2}
+	for fn, value := range want {
+		if got := result.Values[fn]; got == nil || *got != value {
+			t.Errorf("%s = %v, want %v", fn, got, value)
+		}
+	}
+
+	// 过期读数在计算时被清理
+	if _, ok := sub.points["c"]; ok {
+		t.Error("device with only expired readings was kept")
+	}
+	if got := len(sub.points["b"]); got != 1 {
+		t.Errorf("device b keeps %d reading(s), want 1", got)
+	}
+
+	// 窗口内没有读数时除count外为null
+	empty := sub.compute(now.Add(time.Hour))
+	if empty.Values["avg"] != nil || empty.Values["count"] == nil || *empty.Values["count"] != 0 {
+		t.Errorf("empty window values = %v, want null aggregates and a zero count", empty.Values)
+	}
+}
+
+func TestAggregateEmission(t *testing.T) {
+	m := NewManager()
+	client := newTestClient(m, 1, time.Now())
+	m.registerClient(client)
+	drain(client)
+
+	sub := newTestAggregate(client, "temps", 20*time.Millisecond, time.Minute, "a")
+	if err := m.aggregates.add(sub); err != nil {
+		t.Fatalf("add() = %v", err)
+	}
+	m.SendToDevice("a", deviceReading("a", 21.5, time.Now()))
+
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case msg := <-client.Send:
+			if msg.Type != TypeAggregate {
+				continue
+			}
+			result := msg.Data.(AggregateResult)
+			if msg.ID != "temps" || result.Devices != 1 || result.Values["avg"] == nil || *result.Values["avg"] != 21.5 {
+				t.Fatalf("aggregate = %+v, want the average of device a", result)
+			}
+		case <-deadline:
+			t.Fatal("timed out waiting for an aggregate message")
+		}
+		break
+	}
+
+	// 断开连接时取消该客户端的全部聚合订阅
+	m.removeClient(client)
+	if count := m.aggregates.count(); count != 0 {
+		t.Errorf("%d aggregate subscription(s) left after disconnect, want 0", count)
+	}
+	if len(m.aggregates.byDevice) != 0 {
+		t.Errorf("device index = %v, want empty after disconnect", m.aggregates.byDevice)
+	}
+	select {
+	case <-sub.stop:
+	default:
+		t.Error("aggregate ticker was not stopped on disconnect")
+	}
+}
+
+func TestAggregateRegistry(t *testing.T) {
+	m := NewManager()
+	client := newTestClient(m, 1, time.Now())
+	registry := m.aggregates
+
+	first := newTestAggregate(client, "temps", time.Hour, time.Hour, "a", "b")
+	if err := registry.add(first); err != nil {
+		t.Fatalf("add() = %v", err)
+	}
+
+	// 同ID的订阅替换原订阅
+	replacement := newTestAggregate(client, "temps", time.Hour, time.Hour, "b")
+	if err := registry.add(replacement); err != nil {
+		t.Fatalf("add() = %v", err)
+	}
+	select {
+	case <-first.stop:
+	default:
+		t.Error("replaced subscription is still running")
+	}
+	if _, ok := registry.byDevice["a"]; ok || registry.count() != 1 {
+		t.Errorf("device index = %v with %d subscription(s), want only the replacement on b", registry.byDevice, registry.count())
+	}
+
+	for i := 1; i < maxAggregatesPerClient; i++ {
+		if err := registry.add(newTestAggregate(client, strings.Repeat("x", i), time.Hour, time.Hour, "a")); err != nil {
+			t.Fatalf("add(%d) = %v", i, err)
+		}
+	}
+	if err := registry.add(newTestAggregate(client, "over", time.Hour, time.Hour, "a")); err == nil {
+		t.Error("subscription over the per-connection cap was accepted")
+	}
+
+	if registry.remove(client.ID, "missing") {
+		t.Error("remove() of an unknown subscription = true")
+	}
+	if !registry.remove(client.ID, "temps") {
+		t.Error("remove() of an existing subscription = false")
+	}
+	registry.removeClient(client.ID)
+	if registry.count() != 0 || len(registry.byDevice) != 0 {
+		t.Errorf("registry not empty after removeClient: %d subscription(s), index %v", registry.count(), registry.byDevice)
+	}
+}
+
+func TestDecodeAggregateSpec(t *testing.T) {
+	spec, err := decodeAggregateSpec(map[string]interface{}{"device_ids": []string{"a"}, "field": " temperature "})
+	if err != nil {
+		t.Fatalf("decodeAggregateSpec() = %v", err)
+	}
+	if spec.Field != "temperature" || len(spec.Functions) != 1 || spec.Functions[0] != "avg" {
+		t.Errorf("spec = %+v, want the trimmed field and avg by default", spec)
+	}
+
+	tests := []struct {
+		name    string
+		data    map[string]interface{}
+		wantErr string
+	}{
+		{"missing field", map[string]interface{}{"device_ids": []string{"a"}}, "field is required"},
+		{"no devices", map[string]interface{}{"field": "temperature"}, "group_id or device_ids is required"},
+		{"unknown function", map[string]interface{}{"device_ids": []string{"a"}, "field": "temperature", "functions": []string{"median"}}, `unknown function "median"`},
+		{"malformed", map[string]interface{}{"device_ids": "a", "field": "temperature"}, "malformed request"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeAggregateSpec(tt.data); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("decodeAggregateSpec() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package websocket
+
+import (
+	"context"
+	"log"
+	"time"
+	
+	"iot-platform-backend/internal/database"
+)
+
+// PushAlertCount 向用户的在线连接推送未确认告警数，告警产生或被确认后调用
+func (m *Manager) PushAlertCount(ctx context.Context, userID uint) {
+	if m.GetUserClientCount(userID) == 0 || database.DB == nil {
+		return
+	}
+	count, err := database.UnacknowledgedAlertCount(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to count unacknowledged alerts for user %d: %v", userID, err)
+		return
+	}
+	m.SendToUser(userID, Message{
+		Type: TypeNotification,
+		Data: map[string]interface{}{
+			"action":         "alert_count",
+			"unacknowledged": count,
+		},
+		Timestamp: time.Now(),
+	})
+}
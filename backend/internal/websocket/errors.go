@@ -0,0 +1,43 @@
+package websocket
+
+import "errors"
+
+// ErrorCode error消息的错误码，供客户端按类型处理，Error字段为对应的说明文字
+type ErrorCode string
+
+const (
+	ErrCodeUnauthorized       ErrorCode = "unauthorized"         // 需要登录（匿名连接订阅设备或聚合）
+	ErrCodeDeviceNotFound     ErrorCode = "device_not_found"     // 设备不存在或无权访问
+	ErrCodeNotFound           ErrorCode = "not_found"            // 设备分组或聚合订阅不存在
+	ErrCodeInvalidMessage     ErrorCode = "invalid_message"      // 消息类型未知或参数格式错误
+	ErrCodeRateLimited        ErrorCode = "rate_limited"         // 超出上行消息速率，消息被丢弃
+	ErrCodeRateLimitExceeded  ErrorCode = "rate_limit_exceeded"  // 持续超出上行消息速率，连接即将断开
+	ErrCodeLimitExceeded      ErrorCode = "limit_exceeded"       // 超出每个连接的订阅数量限制
+	ErrCodeTooManyConnections ErrorCode = "too_many_connections" // 超出单用户连接数，新连接被拒绝
+	ErrCodeConnectionEvicted  ErrorCode = "connection_evicted"   // 超出单用户连接数，最早的连接被断开
+	ErrCodeInternal           ErrorCode = "internal_error"       // 服务端错误，可稍后重试
+)
+
+// codedError 带错误码的错误，发送给客户端时使用其错误码
+type codedError struct {
+	code    ErrorCode
+	message string
+}
+
+func (e *codedError) Error() string {
+	return e.message
+}
+
+// newCodedError 创建带错误码的错误
+func newCodedError(code ErrorCode, message string) error {
+	return &codedError{code: code, message: message}
+}
+
+// errorCodeOf 返回错误携带的错误码，没有时返回fallback
+func errorCodeOf(err error, fallback ErrorCode) ErrorCode {
+	var coded *codedError
+	if errors.As(err, &coded) {
+		return coded.code
+	}
+	return fallback
+}
@@ -5,12 +5,17 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 	
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
 	"iot-platform-backend/internal/config"
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/models"
 )
 
 // MessageType 消息类型
@@ -27,9 +32,11 @@ const (
 )
 
 // Message WebSocket消息结构
+// type为error时Code为错误码（取值见errors.go中的ErrCode*常量），Error为面向人的说明文字，客户端应按Code处理
 type Message struct {
 	Type      MessageType `json:"type"`
 	Data      interface{} `json:"data,omitempty"`
+	Code      ErrorCode   `json:"code,omitempty"`
 	Error     string      `json:"error,omitempty"`
 	Timestamp time.Time   `json:"timestamp"`
 	ID        string      `json:"id,omitempty"`
@@ -39,12 +46,22 @@ type Message struct {
 type Client struct {
 	ID       string
 	UserID   uint
+	Role     string
+	TenantID string // 多租户token所属租户，为空表示单租户token
 	Conn     *websocket.Conn
 	Send     chan Message
 	Manager  *Manager
+	ConnectedAt time.Time
+	RemoteAddr  string // 客户端地址（按受信任代理解析后的IP）
 	
 	// 订阅信息
 	Subscriptions map[string]bool // 订阅的设备ID
+	replayCursors map[string]int64 // 各设备已回放到的消息ID，用于去重
+	replayHeld    map[string][]Message // 正在回放的设备在回放完成前暂存的实时消息
+	closed       bool            // 发送队列是否已关闭
+	compress     bool            // 握手时是否协商了permessage-deflate
+	limiter      *tokenBucket    // 上行消息令牌桶，为nil时不限流
+	violations   int             // 令牌桶补满前累计被限流的消息数
 	mu           sync.RWMutex
 }
 
@@ -58,18 +75,88 @@ type Manager struct {
 	// 按用户分组的客户端
 	userClients map[uint]map[string]*Client
 	
+	// 单用户连接数限制
+	maxConnsPerUser int
+	limitPolicy     string
+	
+	metrics managerMetrics
+	
+	// 设备消息回放缓冲区（为nil时不启用）
+	replay *ReplayBuffer
+	
+	// 心跳与超时参数
+	keepalive Keepalive
+	
+	// 消息压缩参数与统计
+	compression      Compression
+	compressionStats compressionStats
+	
+	// 服务端聚合订阅
+	aggregates *aggregateRegistry
+	
+	// 客户端上行消息速率限制
+	rateLimit RateLimit
+	
 	mu sync.RWMutex
 }
 
+// Keepalive WebSocket心跳与超时参数
+type Keepalive struct {
+	PongWait     time.Duration
+	PingInterval time.Duration
+	WriteWait    time.Duration
+}
+
+// defaultKeepalive 未加载配置时使用的心跳参数
+var defaultKeepalive = Keepalive{
+	PongWait:     60 * time.Second,
+	PingInterval: 54 * time.Second,
+	WriteWait:    10 * time.Second,
+}
+
+// 超出单用户连接数限制时的处理策略
+const (
+	LimitPolicyReject      = "reject"
+	LimitPolicyEvictOldest = "evict_oldest"
+)
+
 // NewManager 创建新的WebSocket管理器
 func NewManager() *Manager {
-	return &Manager{
+	m := &Manager{
 		clients:     make(map[string]*Client),
 		register:    make(chan *Client),
 		unregister:  make(chan *Client),
 		broadcast:   make(chan Message),
 		userClients: make(map[uint]map[string]*Client),
+		limitPolicy: LimitPolicyEvictOldest,
+		keepalive:   defaultKeepalive,
+		aggregates:  newAggregateRegistry(),
+	}
+	
+	if config.AppConfig != nil {
+		m.maxConnsPerUser = config.AppConfig.WebSocket.MaxConnectionsPerUser
+		if config.AppConfig.WebSocket.ConnectionLimitPolicy == LimitPolicyReject {
+			m.limitPolicy = LimitPolicyReject
+		}
+		m.replay = NewReplayBuffer(config.AppConfig.WebSocket.ReplayBufferSize, config.AppConfig.WebSocket.ReplayTTL)
+		m.keepalive = Keepalive{
+			PongWait:     config.AppConfig.WebSocket.PongWait,
+			PingInterval: config.AppConfig.WebSocket.PingInterval,
+			WriteWait:    config.AppConfig.WebSocket.WriteWait,
+		}
+		m.compression = Compression{
+			Enabled:   config.AppConfig.WebSocket.EnableCompression,
+			Threshold: config.AppConfig.WebSocket.CompressionThreshold,
+			Level:     config.AppConfig.WebSocket.CompressionLevel,
+		}
+		m.rateLimit = RateLimit{
+			Rate:          config.AppConfig.WebSocket.MessageRate,
+			Burst:         config.AppConfig.WebSocket.MessageBurst,
+			MaxViolations: config.AppConfig.WebSocket.MaxRateViolations,
+		}
 	}
+	
+	return m
 }
 
 // Run 运行WebSocket管理器
@@ -91,7 +178,36 @@ func (m *Manager) registerClient(client *Client) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	
+	// 检查单用户连接数限制（匿名连接不受限制）
+	if client.UserID != 0 && m.maxConnsPerUser > 0 && len(m.userClients[client.UserID]) >= m.maxConnsPerUser {
+		if m.limitPolicy == LimitPolicyReject {
+			m.metrics.rejected.Add(1)
+			log.Printf("Client rejected: %s (User: %d) exceeds connection limit", client.ID, client.UserID)
+			client.trySend(Message{
+				Type:      TypeError,
+				Code:      ErrCodeTooManyConnections,
+				Error:     "Too many connections",
+				Timestamp: time.Now(),
+			})
+			client.closeSend()
+			return
+		}
+		
+		if oldest := oldestClient(m.userClients[client.UserID]); oldest != nil {
+			m.metrics.evicted.Add(1)
+			log.Printf("Client evicted: %s (User: %d) exceeds connection limit", oldest.ID, oldest.UserID)
+			oldest.trySend(Message{
+				Type:      TypeError,
+				Code:      ErrCodeConnectionEvicted,
+				Error:     "Connection evicted: too many connections",
+				Timestamp: time.Now(),
+			})
+			m.removeClient(oldest)
+		}
+	}
+	
 	m.clients[client.ID] = client
+	m.metrics.connects.Add(1)
 	
 	// 按用户分组
 	if m.userClients[client.UserID] == nil {
@@ -108,11 +224,7 @@ func (m *Manager) registerClient(client *Client) {
 		Timestamp: time.Now(),
 	}
 	
-	select {
-	case client.Send <- welcomeMsg:
-	default:
-		close(client.Send)
-	}
+	client.trySend(welcomeMsg)
 }
 
 // unregisterClient 注销客户端
@@ -120,6 +232,11 @@ func (m *Manager) unregisterClient(client *Client) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	
+	m.removeClient(client)
+}
+
+// removeClient 移除客户端并关闭其发送队列（调用方需持有写锁）
+func (m *Manager) removeClient(client *Client) {
 	if _, ok := m.clients[client.ID]; ok {
 		delete(m.clients, client.ID)
 		
@@ -131,23 +248,31 @@ func (m *Manager) unregisterClient(client *Client) {
 			}
 		}
 		
-		close(client.Send)
+		client.closeSend()
+		m.aggregates.removeClient(client.ID)
+		m.metrics.disconnects.Add(1)
 		log.Printf("Client unregistered: %s (User: %d)", client.ID, client.UserID)
 	}
 }
 
+// oldestClient 找出连接时间最早的客户端
+func oldestClient(clients map[string]*Client) *Client {
+	var oldest *Client
+	for _, client := range clients {
+		if oldest == nil || client.ConnectedAt.Before(oldest.ConnectedAt) {
+			oldest = client
+		}
+	}
+	return oldest
+}
+
 // broadcastMessage 广播消息
 func (m *Manager) broadcastMessage(message Message) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	
 	for _, client := range m.clients {
-		select {
-		case client.Send <- message:
-		default:
-			close(client.Send)
-			delete(m.clients, client.ID)
-		}
+		client.trySend(message)
 	}
 }
 
@@ -158,32 +283,28 @@ func (m *Manager) SendToUser(userID uint, message Message) {
 	
 	if userClients := m.userClients[userID]; userClients != nil {
 		for _, client := range userClients {
-			select {
-			case client.Send <- message:
-			default:
-				close(client.Send)
-			}
+			client.trySend(message)
 		}
 	}
 }
 
-// SendToDevice 发送设备数据给订阅该设备的客户端
+// SendToDevice 发送设备数据给订阅该设备的客户端，启用回放时先写入回放缓冲区，并计入相关的聚合订阅
 func (m *Manager) SendToDevice(deviceID string, message Message) {
+	if m.replay != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		if err := m.replay.Append(ctx, deviceID, &message); err != nil {
+			log.Printf("Failed to buffer message for device %s: %v", deviceID, err)
+		}
+		cancel()
+	}
+	seq := messageSeq(message)
+	m.aggregates.observe(deviceID, message)
+	
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	
 	for _, client := range m.clients {
-		client.mu.RLock()
-		subscribed := client.Subscriptions[deviceID]
-		client.mu.RUnlock()
-		
-		if subscribed {
-			select {
-			case client.Send <- message:
-			default:
-				close(client.Send)
-			}
-		}
+		client.deliverDeviceMessage(deviceID, seq, message)
 	}
 }
 
@@ -219,9 +340,10 @@ func (c *Client) readPump() {
 	
 	// 设置读取参数
 	c.Conn.SetReadLimit(config.AppConfig.WebSocket.MaxMessageSize)
-	c.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	pongWait := c.Manager.keepalive.PongWait
+	c.Conn.SetReadDeadline(time.Now().Add(pongWait))
 	c.Conn.SetPongHandler(func(string) error {
-		c.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		c.Conn.SetReadDeadline(time.Now().Add(pongWait))
 		return nil
 	})
 	
@@ -234,13 +356,27 @@ func (c *Client) readPump() {
 			break
 		}
 		
+		switch c.checkInboundRate(time.Now()) {
+		case messageThrottled:
+			c.Manager.metrics.throttled.Add(1)
+			c.sendError(ErrCodeRateLimited, "Message rate limit exceeded")
+			continue
+		case clientDisconnected:
+			c.Manager.metrics.throttled.Add(1)
+			c.Manager.metrics.rateLimited.Add(1)
+			log.Printf("Client disconnected: %s (User: %d) exceeds message rate limit", c.ID, c.UserID)
+			c.sendError(ErrCodeRateLimitExceeded, "Disconnected: message rate limit exceeded")
+			return
+		}
+		
 		c.handleMessage(msg)
 	}
 }
 
 // writePump 处理向客户端发送消息
 func (c *Client) writePump() {
-	ticker := time.NewTicker(54 * time.Second)
+	writeWait := c.Manager.keepalive.WriteWait
+	ticker := time.NewTicker(c.Manager.keepalive.PingInterval)
 	defer func() {
 		ticker.Stop()
 		c.Conn.Close()
@@ -249,19 +385,32 @@ func (c *Client) writePump() {
 	for {
 		select {
 		case message, ok := <-c.Send:
-			c.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
 				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
 				return
 			}
 			
-			if err := c.Conn.WriteJSON(message); err != nil {
+			payload, err := json.Marshal(message)
+			if err != nil {
+				log.Printf("WebSocket marshal error: %v", err)
+				continue
+			}
+			
+			// 只有大消息才压缩；未协商压缩的连接设置无效，始终发送普通帧
+			compress := c.Manager.shouldCompress(c, len(payload))
+			c.Conn.EnableWriteCompression(compress)
+			if err := c.Conn.WriteMessage(websocket.TextMessage, payload); err != nil {
 				log.Printf("WebSocket write error: %v", err)
 				return
 			}
+			c.Manager.metrics.sent.Add(1)
+			if compress {
+				c.Manager.recordCompressed(payload)
+			}
 			
 		case <-ticker.C:
-			c.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
@@ -276,56 +425,352 @@ func (c *Client) handleMessage(msg Message) {
 		c.handleSubscribe(msg)
 	case TypeUnsubscribe:
 		c.handleUnsubscribe(msg)
+	case TypeSubscribeAggregate:
+		c.handleSubscribeAggregate(msg)
+	case TypeUnsubscribeAggregate:
+		c.handleUnsubscribeAggregate(msg)
 	case TypeHeartbeat:
 		c.handleHeartbeat()
 	default:
 		log.Printf("Unknown message type: %s", msg.Type)
+		c.sendError(ErrCodeInvalidMessage, "Unknown message type: "+string(msg.Type))
 	}
 }
 
-// handleSubscribe 处理订阅消息
+// SubscriptionResult 单个设备的订阅结果
+type SubscriptionResult struct {
+	DeviceID string `json:"device_id"`
+	Status   string `json:"status"` // subscribed, unsubscribed, denied
+	Replayed int    `json:"replayed,omitempty"` // 回放的缓冲消息数量
+}
+
+// handleSubscribe 处理订阅消息，支持device_id单个订阅和device_ids批量订阅
 func (c *Client) handleSubscribe(msg Message) {
-	if data, ok := msg.Data.(map[string]interface{}); ok {
-		if deviceID, exists := data["device_id"]; exists {
-			if deviceIDStr, ok := deviceID.(string); ok {
-				c.mu.Lock()
-				c.Subscriptions[deviceIDStr] = true
-				c.mu.Unlock()
-				
-				log.Printf("Client %s subscribed to device %s", c.ID, deviceIDStr)
-				
-				// 发送确认消息
-				response := Message{
-					Type: TypeNotification,
-					Data: map[string]string{
-						"message":   "Subscribed successfully",
-						"device_id": deviceIDStr,
-					},
-					Timestamp: time.Now(),
-				}
-				
-				select {
-				case c.Send <- response:
-				default:
-				}
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		c.sendError(ErrCodeInvalidMessage, "Invalid subscribe message: data must be an object")
+		return
+	}
+	
+	deviceIDs, batch := extractDeviceIDs(data)
+	if len(deviceIDs) == 0 {
+		c.sendError(ErrCodeInvalidMessage, "Invalid subscribe message: device_id or device_ids is required")
+		return
+	}
+	
+	allowed := c.authorizeDevices(deviceIDs)
+	cursors := extractReplayCursors(data, deviceIDs, batch)
+	results := make([]SubscriptionResult, 0, len(deviceIDs))
+	
+	// 先登记订阅，需要回放的设备在回放完成前暂存实时消息，保证回放消息先于实时消息进入发送队列
+	replaying := c.Manager != nil && c.Manager.replay != nil
+	c.mu.Lock()
+	for _, deviceID := range deviceIDs {
+		result := SubscriptionResult{DeviceID: deviceID, Status: "denied"}
+		if allowed[deviceID] {
+			c.Subscriptions[deviceID] = true
+			result.Status = "subscribed"
+			if _, ok := cursors[deviceID]; ok && replaying {
+				c.replayHeld[deviceID] = []Message{}
 			}
 		}
+		results = append(results, result)
 	}
+	c.mu.Unlock()
+	
+	// 读取回放缓冲区不持有c.mu，避免Redis慢调用阻塞该连接的消息发送
+	for i, result := range results {
+		if afterID, ok := cursors[result.DeviceID]; ok && replaying && result.Status == "subscribed" {
+			results[i].Replayed = c.replay(result.DeviceID, afterID)
+		}
+	}
+	
+	log.Printf("Client %s subscription request processed for %d device(s)", c.ID, len(deviceIDs))
+	
+	// 单设备订阅保持原有的响应格式
+	if !batch {
+		result := results[0]
+		if result.Status == "denied" {
+			if c.UserID == 0 {
+				c.sendError(ErrCodeUnauthorized, "Authentication required to subscribe to devices")
+				return
+			}
+			c.sendError(ErrCodeDeviceNotFound, "Access denied for device "+result.DeviceID)
+			return
+		}
+		c.sendNotification(map[string]interface{}{
+			"message":   "Subscribed successfully",
+			"device_id": result.DeviceID,
+			"replayed":  result.Replayed,
+		})
+		return
+	}
+	
+	c.sendNotification(map[string]interface{}{
+		"message": "Subscription processed",
+		"results": results,
+	})
 }
 
-// handleUnsubscribe 处理取消订阅消息
+// handleUnsubscribe 处理取消订阅消息，支持device_id单个和device_ids批量取消
 func (c *Client) handleUnsubscribe(msg Message) {
-	if data, ok := msg.Data.(map[string]interface{}); ok {
-		if deviceID, exists := data["device_id"]; exists {
-			if deviceIDStr, ok := deviceID.(string); ok {
-				c.mu.Lock()
-				delete(c.Subscriptions, deviceIDStr)
-				c.mu.Unlock()
-				
-				log.Printf("Client %s unsubscribed from device %s", c.ID, deviceIDStr)
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		c.sendError(ErrCodeInvalidMessage, "Invalid unsubscribe message: data must be an object")
+		return
+	}
+	
+	deviceIDs, batch := extractDeviceIDs(data)
+	if len(deviceIDs) == 0 {
+		c.sendError(ErrCodeInvalidMessage, "Invalid unsubscribe message: device_id or device_ids is required")
+		return
+	}
+	
+	results := make([]SubscriptionResult, 0, len(deviceIDs))
+	
+	c.mu.Lock()
+	for _, deviceID := range deviceIDs {
+		delete(c.Subscriptions, deviceID)
+		delete(c.replayCursors, deviceID)
+		delete(c.replayHeld, deviceID)
+		results = append(results, SubscriptionResult{DeviceID: deviceID, Status: "unsubscribed"})
+	}
+	c.mu.Unlock()
+	
+	log.Printf("Client %s unsubscribed from %d device(s)", c.ID, len(deviceIDs))
+	
+	if batch {
+		c.sendNotification(map[string]interface{}{
+			"message": "Unsubscription processed",
+			"results": results,
+		})
+	}
+}
+
+// authorizeDevices 批量校验客户端是否有权订阅设备，返回有权限的设备集合
+func (c *Client) authorizeDevices(deviceIDs []string) map[string]bool {
+	allowed := make(map[string]bool, len(deviceIDs))
+	
+	// 匿名连接不允许订阅设备数据
+	if c.UserID == 0 {
+		return allowed
+	}
+	
+	db := database.GetDB()
+	if db == nil {
+		return allowed
+	}
+	
+	query := c.scopeOwned(db.Model(&models.Device{}).Where("device_id IN ?", deviceIDs))
+	
+	var owned []string
+	if err := query.Pluck("device_id", &owned).Error; err != nil {
+		log.Printf("Failed to authorize device subscription: %v", err)
+		return allowed
+	}
+	
+	for _, deviceID := range owned {
+		allowed[deviceID] = true
+	}
+	return allowed
+}
+
+// scopeOwned 将查询限制为客户端可访问的资源：普通用户只能访问自己的资源，
+// 管理员使用多租户token时只能访问本租户用户的资源，与HTTP接口的IsAdminOf一致
+func (c *Client) scopeOwned(query *gorm.DB) *gorm.DB {
+	if !models.RoleHasCapability(c.Role, models.CapManageResources) {
+		return query.Where("owner_id = ?", c.UserID)
+	}
+	if c.TenantID != "" {
+		return query.Where("owner_id IN (SELECT id FROM users WHERE tenant_id = ?)", c.TenantID)
+	}
+	return query
+}
+
+// extractDeviceIDs 从消息数据中提取设备ID列表，第二个返回值表示是否为批量请求
+func extractDeviceIDs(data map[string]interface{}) ([]string, bool) {
+	if rawIDs, exists := data["device_ids"]; exists {
+		list, ok := rawIDs.([]interface{})
+		if !ok {
+			return nil, true
+		}
+		
+		seen := make(map[string]bool, len(list))
+		deviceIDs := make([]string, 0, len(list))
+		for _, item := range list {
+			if deviceID, ok := item.(string); ok && deviceID != "" && !seen[deviceID] {
+				seen[deviceID] = true
+				deviceIDs = append(deviceIDs, deviceID)
+			}
+		}
+		return deviceIDs, true
+	}
+	
+	if deviceID, ok := data["device_id"].(string); ok && deviceID != "" {
+		return []string{deviceID}, false
+	}
+	return nil, false
+}
+
+// replay 从缓冲区读取ID大于afterID的消息并加入发送队列，随后按序发送回放期间暂存的实时消息，返回回放数量
+func (c *Client) replay(deviceID string, afterID int64) int {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	messages, err := c.Manager.replay.Since(ctx, deviceID, afterID)
+	cancel()
+	if err != nil {
+		log.Printf("Failed to replay messages for device %s: %v", deviceID, err)
+	}
+	
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	
+	held, pending := c.replayHeld[deviceID]
+	delete(c.replayHeld, deviceID)
+	// 回放期间已取消订阅
+	if !pending {
+		return 0
+	}
+	
+	replayed := 0
+	for _, message := range messages {
+		if c.sendLocked(message) {
+			replayed++
+			c.replayCursors[deviceID] = messageSeq(message)
+		}
+	}
+	
+	// 暂存的实时消息可能已包含在回放结果中，按游标去重
+	cursor := c.replayCursors[deviceID]
+	for _, message := range held {
+		if seq := messageSeq(message); seq == 0 || seq > cursor {
+			c.sendLocked(message)
+		}
+	}
+	return replayed
+}
+
+// deliverDeviceMessage 向已订阅设备的客户端投递实时消息，跳过已通过回放发送过的消息；
+// 设备正在回放时暂存消息，由replay在回放完成后发送
+func (c *Client) deliverDeviceMessage(deviceID string, seq int64, message Message) {
+	c.mu.RLock()
+	subscribed := c.Subscriptions[deviceID]
+	c.mu.RUnlock()
+	if !subscribed {
+		return
+	}
+	
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	
+	if !c.Subscriptions[deviceID] || (seq != 0 && seq <= c.replayCursors[deviceID]) {
+		return
+	}
+	if held, pending := c.replayHeld[deviceID]; pending {
+		if len(held) >= cap(c.Send) {
+			c.countDropped()
+			return
+		}
+		c.replayHeld[deviceID] = append(held, message)
+		return
+	}
+	c.sendLocked(message)
+}
+
+// extractReplayCursors 解析回放游标：单设备订阅使用last_id，批量订阅使用cursors映射
+func extractReplayCursors(data map[string]interface{}, deviceIDs []string, batch bool) map[string]int64 {
+	cursors := make(map[string]int64)
+	
+	if !batch {
+		if afterID, ok := parseCursor(data["last_id"]); ok && len(deviceIDs) == 1 {
+			cursors[deviceIDs[0]] = afterID
+		}
+		return cursors
+	}
+	
+	if raw, ok := data["cursors"].(map[string]interface{}); ok {
+		for deviceID, value := range raw {
+			if afterID, ok := parseCursor(value); ok {
+				cursors[deviceID] = afterID
 			}
 		}
 	}
+	return cursors
+}
+
+// parseCursor 将游标值（字符串或数字）解析为消息序号
+func parseCursor(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return 0, false
+		}
+		seq, err := strconv.ParseInt(v, 10, 64)
+		return seq, err == nil
+	case float64:
+		return int64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// sendNotification 向客户端发送通知消息
+func (c *Client) sendNotification(data interface{}) {
+	c.trySend(Message{
+		Type:      TypeNotification,
+		Data:      data,
+		Timestamp: time.Now(),
+	})
+}
+
+// sendError 向客户端发送错误消息
+func (c *Client) sendError(code ErrorCode, errMsg string) {
+	c.trySend(Message{
+		Type:      TypeError,
+		Code:      code,
+		Error:     errMsg,
+		Timestamp: time.Now(),
+	})
+}
+
+// trySend 非阻塞发送，发送队列已满时丢弃并计数；返回消息是否已进入发送队列
+func (c *Client) trySend(message Message) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	
+	return c.sendLocked(message)
+}
+
+// sendLocked 同trySend，调用方需持有c.mu（读锁或写锁）
+func (c *Client) sendLocked(message Message) bool {
+	if c.closed {
+		return false
+	}
+	
+	select {
+	case c.Send <- message:
+		return true
+	default:
+		c.countDropped()
+		return false
+	}
+}
+
+// countDropped 记录一条因发送队列已满而丢弃的消息
+func (c *Client) countDropped() {
+	if c.Manager != nil {
+		c.Manager.metrics.dropped.Add(1)
+	}
+}
+
+// closeSend 关闭发送队列，可重复调用
+func (c *Client) closeSend() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	
+	if !c.closed {
+		c.closed = true
+		close(c.Send)
+	}
 }
 
 // handleHeartbeat 处理心跳消息
@@ -336,10 +781,7 @@ func (c *Client) handleHeartbeat() {
 		Timestamp: time.Now(),
 	}
 	
-	select {
-	case c.Send <- response:
-	default:
-	}
+	c.trySend(response)
 }
 
 // 全局WebSocket管理器实例
@@ -351,14 +793,39 @@ func Init() {
 	go DefaultManager.Run()
 }
 
-// upgrader WebSocket升级器
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		// 在生产环境中应该检查Origin
+// newUpgrader 根据配置创建WebSocket升级器
+func newUpgrader(cfg *config.Config) *websocket.Upgrader {
+	return &websocket.Upgrader{
+		ReadBufferSize:   cfg.WebSocket.ReadBufferSize,
+		WriteBufferSize:  cfg.WebSocket.WriteBufferSize,
+		HandshakeTimeout: cfg.WebSocket.HandshakeTimeout,
+		EnableCompression: cfg.WebSocket.EnableCompression,
+		CheckOrigin: func(r *http.Request) bool {
+			return checkOrigin(cfg, r.Header.Get("Origin"))
+		},
+	}
+}
+
+// checkOrigin 校验Origin是否在允许列表中
+func checkOrigin(cfg *config.Config, origin string) bool {
+	// 开发环境或未开启检查时保持宽松
+	if !cfg.WebSocket.CheckOrigin || cfg.IsDevelopment() {
 		return true
-	},
+	}
+	
+	// 非浏览器客户端不携带Origin
+	if origin == "" {
+		return true
+	}
+	
+	for _, allowedOrigin := range cfg.Server.CORS.AllowedOrigins {
+		if strings.EqualFold(strings.TrimRight(origin, "/"), strings.TrimRight(allowedOrigin, "/")) {
+			return true
+		}
+	}
+	
+	log.Printf("WebSocket origin rejected: %s", origin)
+	return false
 }
 
 // HandleWebSocket 处理WebSocket连接
@@ -366,19 +833,35 @@ func HandleWebSocket(c *gin.Context) {
 	// 从查询参数或JWT token中获取用户ID
 	userID := getUserIDFromContext(c)
 	
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	conn, err := newUpgrader(config.AppConfig).Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade failed: %v", err)
 		return
 	}
 	
+	// 与gorilla的协商规则一致：服务端开启且客户端声明支持时才使用压缩
+	compress := DefaultManager.compression.Enabled && clientAcceptsDeflate(c.Request.Header)
+	if compress {
+		conn.SetCompressionLevel(DefaultManager.compression.Level)
+	}
+	
 	client := &Client{
 		ID:            generateClientID(),
 		UserID:        userID,
+		Role:          c.GetString("role"),
+		TenantID:      c.GetString("tenant_id"),
 		Conn:          conn,
 		Send:          make(chan Message, 256),
 		Manager:       DefaultManager,
+		ConnectedAt:   time.Now(),
+		RemoteAddr:    c.ClientIP(),
 		Subscriptions: make(map[string]bool),
+		replayCursors: make(map[string]int64),
+		replayHeld:    make(map[string][]Message),
+		compress:      compress,
+	}
+	if limit := DefaultManager.rateLimit; limit.Rate > 0 {
+		client.limiter = newTokenBucket(limit.Rate, limit.Burst, client.ConnectedAt)
 	}
 	
 	DefaultManager.register <- client
@@ -386,6 +869,9 @@ func HandleWebSocket(c *gin.Context) {
 	// 启动读写协程
 	go client.writePump()
 	go client.readPump()
+	
+	// 推送离线期间的通知
+	go client.deliverPendingNotifications()
 }
 
 // generateClientID 生成客户端ID
@@ -0,0 +1,91 @@
+package websocket
+
+import (
+	"strconv"
+	"sync/atomic"
+)
+
+// managerMetrics WebSocket管理器运行计数
+type managerMetrics struct {
+	connects    atomic.Int64
+	disconnects atomic.Int64
+	rejected    atomic.Int64
+	evicted     atomic.Int64
+	sent        atomic.Int64
+	dropped     atomic.Int64
+	throttled   atomic.Int64 // 因超出上行速率被丢弃的客户端消息
+	rateLimited atomic.Int64 // 因持续超出上行速率被断开的连接
+}
+
+// Metrics WebSocket连接与消息指标快照
+type Metrics struct {
+	TotalConnections   int            `json:"total_connections"`
+	ConnectedUsers     int            `json:"connected_users"`
+	MaxUserConnections int            `json:"max_user_connections"`
+	UserDistribution   map[string]int `json:"user_distribution"` // 连接数 -> 用户数
+	ConnectsTotal      int64          `json:"connects_total"`
+	DisconnectsTotal   int64          `json:"disconnects_total"`
+	RejectedTotal      int64          `json:"rejected_total"`
+	EvictedTotal       int64          `json:"evicted_total"`
+	MessagesSent       int64          `json:"messages_sent"`
+	MessagesDropped    int64          `json:"messages_dropped"`
+	Keepalive          map[string]string `json:"keepalive"` // 生效的心跳参数，便于排查代理断连
+	CompressedClients  int                `json:"compressed_clients"` // 协商了permessage-deflate的连接数
+	Compression        CompressionMetrics `json:"compression"`
+	AggregateSubscriptions int            `json:"aggregate_subscriptions"` // 活跃的聚合订阅数
+	MessagesThrottled      int64          `json:"messages_throttled"`      // 因超出上行速率被丢弃的客户端消息数
+	RateLimitDisconnects   int64          `json:"rate_limit_disconnects"`  // 因持续超出上行速率被断开的连接数
+}
+
+// CompressionMetrics 消息压缩指标
+type CompressionMetrics struct {
+	Enabled            bool    `json:"enabled"`
+	Threshold          int     `json:"threshold"`
+	Level              int     `json:"level"`
+	CompressedMessages int64   `json:"compressed_messages"`
+	CompressedRawBytes int64   `json:"compressed_raw_bytes"` // 压缩消息压缩前的总字节数
+	EstimatedRatio     float64 `json:"estimated_ratio"`      // 抽样估算的压缩后/压缩前字节比，无样本时为0
+}
+
+// Metrics 获取当前指标快照
+func (m *Manager) Metrics() Metrics {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	snapshot := Metrics{
+		TotalConnections: len(m.clients),
+		ConnectedUsers:   len(m.userClients),
+		UserDistribution: make(map[string]int),
+		ConnectsTotal:    m.metrics.connects.Load(),
+		DisconnectsTotal: m.metrics.disconnects.Load(),
+		RejectedTotal:    m.metrics.rejected.Load(),
+		EvictedTotal:     m.metrics.evicted.Load(),
+		MessagesSent:     m.metrics.sent.Load(),
+		MessagesDropped:  m.metrics.dropped.Load(),
+		Keepalive: map[string]string{
+			"pong_wait":     m.keepalive.PongWait.String(),
+			"ping_interval": m.keepalive.PingInterval.String(),
+			"write_wait":    m.keepalive.WriteWait.String(),
+		},
+		Compression: m.compressionMetrics(),
+		AggregateSubscriptions: m.aggregates.count(),
+		MessagesThrottled:      m.metrics.throttled.Load(),
+		RateLimitDisconnects:   m.metrics.rateLimited.Load(),
+	}
+	
+	for _, client := range m.clients {
+		if client.compress {
+			snapshot.CompressedClients++
+		}
+	}
+	
+	for _, userClients := range m.userClients {
+		count := len(userClients)
+		snapshot.UserDistribution[strconv.Itoa(count)]++
+		if count > snapshot.MaxUserConnections {
+			snapshot.MaxUserConnections = count
+		}
+	}
+	
+	return snapshot
+}
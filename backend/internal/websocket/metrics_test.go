@@ -0,0 +1,110 @@
+package websocket
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// newTestClient 创建不带底层连接的客户端，发送队列足够容纳欢迎和错误消息
+func newTestClient(m *Manager, userID uint, connectedAt time.Time) *Client {
+	return &Client{
+		ID:            fmt.Sprintf("client-%d-%d", userID, connectedAt.UnixNano()),
+		UserID:        userID,
+		Send:          make(chan Message, 8),
+		Manager:       m,
+		ConnectedAt:   connectedAt,
+		Subscriptions: make(map[string]bool),
+	}
+}
+
+// drain 读出发送队列中的全部消息，返回最后一条及队列是否已关闭
+func drain(client *Client) (last Message, closed bool) {
+	for {
+		select {
+		case msg, ok := <-client.Send:
+			if !ok {
+				return last, true
+			}
+			last = msg
+		default:
+			return last, false
+		}
+	}
+}
+
+func TestRegisterClientConnectionCap(t *testing.T) {
+	base := time.Now()
+
+	t.Run("reject", func(t *testing.T) {
+		m := NewManager()
+		m.maxConnsPerUser = 2
+		m.limitPolicy = LimitPolicyReject
+
+		first := newTestClient(m, 1, base)
+		second := newTestClient(m, 1, base.Add(time.Second))
+		third := newTestClient(m, 1, base.Add(2*time.Second))
+		for _, client := range []*Client{first, second, third} {
+			m.registerClient(client)
+		}
+
+		if _, ok := m.clients[third.ID]; ok {
+			t.Error("connection over the cap was registered")
+		}
+		if last, closed := drain(third); !closed || last.Type != TypeError {
+			t.Errorf("rejected client got %+v (closed=%v), want an error and a closed queue", last, closed)
+		}
+		if _, closed := drain(first); closed {
+			t.Error("existing connection was closed under the reject policy")
+		}
+
+		metrics := m.Metrics()
+		if metrics.TotalConnections != 2 || metrics.RejectedTotal != 1 || metrics.EvictedTotal != 0 {
+			t.Errorf("metrics = %+v, want 2 connections and 1 rejection", metrics)
+		}
+	})
+
+	t.Run("evict oldest", func(t *testing.T) {
+		m := NewManager()
+		m.maxConnsPerUser = 2
+		m.limitPolicy = LimitPolicyEvictOldest
+
+		first := newTestClient(m, 1, base)
+		second := newTestClient(m, 1, base.Add(time.Second))
+		third := newTestClient(m, 1, base.Add(2*time.Second))
+		other := newTestClient(m, 2, base)
+		for _, client := range []*Client{first, second, third, other} {
+			m.registerClient(client)
+		}
+
+		if _, ok := m.clients[first.ID]; ok {
+			t.Error("oldest connection was not evicted")
+		}
+		if last, closed := drain(first); !closed || last.Type != TypeError {
+			t.Errorf("evicted client got %+v (closed=%v), want an error and a closed queue", last, closed)
+		}
+		for _, client := range []*Client{second, third, other} {
+			if _, ok := m.clients[client.ID]; !ok {
+				t.Errorf("client %s was not registered", client.ID)
+			}
+		}
+
+		metrics := m.Metrics()
+		if metrics.TotalConnections != 3 || metrics.MaxUserConnections != 2 || metrics.EvictedTotal != 1 {
+			t.Errorf("metrics = %+v, want 3 connections, at most 2 per user and 1 eviction", metrics)
+		}
+	})
+
+	t.Run("anonymous connections are not capped", func(t *testing.T) {
+		m := NewManager()
+		m.maxConnsPerUser = 1
+		m.limitPolicy = LimitPolicyReject
+
+		for i := 0; i < 3; i++ {
+			m.registerClient(newTestClient(m, 0, base.Add(time.Duration(i)*time.Second)))
+		}
+		if got := len(m.clients); got != 3 {
+			t.Errorf("registered %d anonymous connections, want 3", got)
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package websocket
+
+import (
+	"log"
+	"time"
+	
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/models"
+)
+
+// 公告推送目标
+const (
+	NotifyTargetAll   = "all"
+	NotifyTargetUsers = "users"
+	NotifyTargetRole  = "role"
+)
+
+// Notify 保存用户通知并推送给其在线连接；用户离线时在下次连接时补发
+func (m *Manager) Notify(userID uint, notifType string, payload models.JSONB) (*models.Notification, error) {
+	notification := &models.Notification{
+		UserID:  userID,
+		Type:    notifType,
+		Payload: payload,
+	}
+	
+	online := m.GetUserClientCount(userID) > 0
+	if online {
+		now := time.Now()
+		notification.DeliveredAt = &now
+	}
+	
+	if database.DB != nil {
+		if err := database.GetDB().Create(notification).Error; err != nil {
+			return nil, err
+		}
+	}
+	
+	if online {
+		m.SendToUser(userID, NotificationMessage(*notification))
+	}
+	return notification, nil
+}
+
+// NotificationMessage 将通知转换为WebSocket消息，payload字段平铺到data中并以action标识类型
+func NotificationMessage(notification models.Notification) Message {
+	data := make(map[string]interface{}, len(notification.Payload)+2)
+	for key, value := range notification.Payload {
+		data[key] = value
+	}
+	data["action"] = notification.Type
+	if notification.ID != 0 {
+		data["notification_id"] = notification.ID
+	}
+	
+	return Message{
+		Type:      TypeNotification,
+		Data:      data,
+		Timestamp: time.Now(),
+	}
+}
+
+// AnnouncementPayload 公告通知内容
+func AnnouncementPayload(announcement models.Announcement) models.JSONB {
+	return models.JSONB{
+		"announcement_id": announcement.ID,
+		"title":           announcement.Title,
+		"message":         announcement.Message,
+		"level":           announcement.Level,
+	}
+}
+
+// SendToRole 发送消息给指定角色的所有在线连接
+func (m *Manager) SendToRole(role string, message Message) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	for _, client := range m.clients {
+		if client.Role == role {
+			client.trySend(message)
+		}
+	}
+}
+
+// OnlineUserIDs 获取当前在线的用户ID集合（不含匿名连接）
+func (m *Manager) OnlineUserIDs() map[uint]bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	online := make(map[uint]bool, len(m.userClients))
+	for userID := range m.userClients {
+		if userID != 0 {
+			online[userID] = true
+		}
+	}
+	return online
+}
+
+// deliverPendingNotifications 连接建立后补发离线期间的通知，并推送未读数量
+func (c *Client) deliverPendingNotifications() {
+	if c.UserID == 0 || database.DB == nil {
+		return
+	}
+	
+	db := database.GetDB()
+	var pending []models.Notification
+	if err := db.Where("user_id = ? AND delivered_at IS NULL", c.UserID).
+		Order("created_at ASC").
+		Find(&pending).Error; err != nil {
+		log.Printf("Failed to load pending notifications for user %d: %v", c.UserID, err)
+		return
+	}
+	
+	delivered := make([]uint, 0, len(pending))
+	for _, notification := range pending {
+		if !c.trySend(NotificationMessage(notification)) {
+			break
+		}
+		delivered = append(delivered, notification.ID)
+	}
+	
+	if len(delivered) > 0 {
+		db.Model(&models.Notification{}).
+			Where("id IN ?", delivered).
+			Update("delivered_at", time.Now())
+	}
+	
+	var unread int64
+	db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", c.UserID).Count(&unread)
+	c.sendNotification(map[string]interface{}{
+		"action": "unread_count",
+		"unread": unread,
+	})
+}
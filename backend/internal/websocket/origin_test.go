@@ -0,0 +1,38 @@
+package websocket
+
+import (
+	"testing"
+
+	"iot-platform-backend/internal/config"
+)
+
+func TestCheckOrigin(t *testing.T) {
+	newConfig := func(mode string, check bool) *config.Config {
+		cfg := &config.Config{}
+		cfg.Server.Mode = mode
+		cfg.Server.CORS.AllowedOrigins = []string{"https://app.example.com/"}
+		cfg.WebSocket.CheckOrigin = check
+		return cfg
+	}
+
+	tests := []struct {
+		name   string
+		cfg    *config.Config
+		origin string
+		want   bool
+	}{
+		{"allowed origin", newConfig("release", true), "https://app.example.com", true},
+		{"allowed origin case-insensitive", newConfig("release", true), "HTTPS://APP.EXAMPLE.COM/", true},
+		{"disallowed origin", newConfig("release", true), "https://evil.example.com", false},
+		{"missing origin", newConfig("release", true), "", true},
+		{"check disabled", newConfig("release", false), "https://evil.example.com", true},
+		{"development mode", newConfig("debug", true), "https://evil.example.com", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkOrigin(tt.cfg, tt.origin); got != tt.want {
+				t.Errorf("checkOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package websocket
+
+import (
+	"sort"
+	"time"
+)
+
+// ClientInfo 在线连接的快照信息
+type ClientInfo struct {
+	ID                     string    `json:"id"`
+	UserID                 uint      `json:"user_id"` // 0表示匿名连接
+	Role                   string    `json:"role,omitempty"`
+	ConnectedAt            time.Time `json:"connected_at"`
+	RemoteAddr             string    `json:"remote_addr"`
+	Subscriptions          int       `json:"subscriptions"`           // 订阅的设备数
+	AggregateSubscriptions int       `json:"aggregate_subscriptions"` // 聚合订阅数
+	Compressed             bool      `json:"compressed"`
+}
+
+// Snapshot 返回当前所有连接的快照，按连接时间从早到晚排序
+func (m *Manager) Snapshot() []ClientInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	return m.snapshotLocked(m.clients)
+}
+
+// UserSnapshot 返回指定用户的连接快照，userID为0时返回匿名连接
+func (m *Manager) UserSnapshot(userID uint) []ClientInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	return m.snapshotLocked(m.userClients[userID])
+}
+
+// snapshotLocked 在持有读锁时复制连接信息，返回的数据不再引用客户端对象
+func (m *Manager) snapshotLocked(clients map[string]*Client) []ClientInfo {
+	aggregateCounts := m.aggregates.clientCounts()
+	
+	infos := make([]ClientInfo, 0, len(clients))
+	for _, client := range clients {
+		client.mu.RLock()
+		subscriptions := len(client.Subscriptions)
+		client.mu.RUnlock()
+		
+		infos = append(infos, ClientInfo{
+			ID:                     client.ID,
+			UserID:                 client.UserID,
+			Role:                   client.Role,
+			ConnectedAt:            client.ConnectedAt,
+			RemoteAddr:             client.RemoteAddr,
+			Subscriptions:          subscriptions,
+			AggregateSubscriptions: aggregateCounts[client.ID],
+			Compressed:             client.compress,
+		})
+	}
+	
+	sort.Slice(infos, func(i, j int) bool {
+		if !infos[i].ConnectedAt.Equal(infos[j].ConnectedAt) {
+			return infos[i].ConnectedAt.Before(infos[j].ConnectedAt)
+		}
+		return infos[i].ID < infos[j].ID
+	})
+	return infos
+}
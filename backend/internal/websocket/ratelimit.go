@@ -0,0 +1,81 @@
+package websocket
+
+import "time"
+
+// RateLimit 客户端上行消息速率限制
+type RateLimit struct {
+	Rate          int // 每秒补充的令牌数，0表示不限制
+	Burst         int // 令牌桶容量，允许的突发消息数
+	MaxViolations int // 令牌桶未补满前累计被限流的消息数超过该值时断开连接
+}
+
+// tokenBucket 令牌桶，只在客户端的读协程中使用，无需加锁
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket 创建装满令牌的令牌桶
+func newTokenBucket(rate, burst int, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		rate:   float64(rate),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now,
+	}
+}
+
+// refill 按经过的时间补充令牌
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+}
+
+// allow 补充令牌后尝试取一个令牌，返回是否允许，以及取之前令牌桶是否已满
+func (b *tokenBucket) allow(now time.Time) (allowed, full bool) {
+	b.refill(now)
+	full = b.tokens >= b.burst
+	if b.tokens < 1 {
+		return false, full
+	}
+	b.tokens--
+	return true, full
+}
+
+// rateLimitResult 上行消息限流的处理结果
+type rateLimitResult int
+
+const (
+	messageAllowed    rateLimitResult = iota // 正常处理
+	messageThrottled                         // 丢弃并回复错误
+	clientDisconnected                       // 持续超限，断开连接
+)
+
+// checkInboundRate 检查客户端上行消息速率
+// 令牌桶补满（客户端已安静足够长时间）时清零违规计数，只有持续超限的客户端才会被断开
+func (c *Client) checkInboundRate(now time.Time) rateLimitResult {
+	if c.limiter == nil {
+		return messageAllowed
+	}
+	
+	allowed, full := c.limiter.allow(now)
+	if full {
+		c.violations = 0
+	}
+	if allowed {
+		return messageAllowed
+	}
+	
+	c.violations++
+	if limit := c.Manager.rateLimit.MaxViolations; limit > 0 && c.violations > limit {
+		return clientDisconnected
+	}
+	return messageThrottled
+}
@@ -0,0 +1,128 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"iot-platform-backend/internal/testutil"
+)
+
+func TestCheckInboundRate(t *testing.T) {
+	m := NewManager()
+	m.rateLimit = RateLimit{Rate: 2, Burst: 3, MaxViolations: 2}
+	now := time.Now()
+
+	t.Run("steady client", func(t *testing.T) {
+		client := newTestClient(m, 1, now)
+		client.limiter = newTokenBucket(m.rateLimit.Rate, m.rateLimit.Burst, now)
+		for i := 0; i < 50; i++ {
+			if result := client.checkInboundRate(now.Add(time.Duration(i) * 500 * time.Millisecond)); result != messageAllowed {
+				t.Fatalf("message %d at the configured rate = %v, want allowed", i, result)
+			}
+		}
+	})
+
+	t.Run("burst then quiet", func(t *testing.T) {
+		client := newTestClient(m, 1, now)
+		client.limiter = newTokenBucket(m.rateLimit.Rate, m.rateLimit.Burst, now)
+		for i := 0; i < 3; i++ {
+			if result := client.checkInboundRate(now); result != messageAllowed {
+				t.Fatalf("message %d within the burst = %v, want allowed", i, result)
+			}
+		}
+		for i := 0; i < 2; i++ {
+			if result := client.checkInboundRate(now); result != messageThrottled {
+				t.Fatalf("message %d over the burst = %v, want throttled", i, result)
+			}
+		}
+
+		// 安静到令牌桶补满后违规计数清零，再次突发不会被断开
+		later := now.Add(2 * time.Second)
+		for i := 0; i < 3; i++ {
+			client.checkInboundRate(later)
+		}
+		for i := 0; i < 2; i++ {
+			if result := client.checkInboundRate(later); result != messageThrottled {
+				t.Fatalf("message %d over the second burst = %v, want throttled", i, result)
+			}
+		}
+		if result := client.checkInboundRate(later); result != clientDisconnected {
+			t.Errorf("sustained flooding = %v, want disconnected", result)
+		}
+	})
+
+	t.Run("unlimited", func(t *testing.T) {
+		client := newTestClient(m, 1, now)
+		for i := 0; i < 100; i++ {
+			if result := client.checkInboundRate(now); result != messageAllowed {
+				t.Fatalf("message %d without a limiter = %v, want allowed", i, result)
+			}
+		}
+	})
+}
+
+func TestFloodingClientIsDisconnected(t *testing.T) {
+	testutil.LoadConfig(t)
+
+	m := NewManager()
+	m.rateLimit = RateLimit{Rate: 1, Burst: 5, MaxViolations: 10}
+	clients := make(chan *Client, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade failed: %v", err)
+			return
+		}
+		client := newTestClient(m, 1, time.Now())
+		client.Conn = conn
+		client.Send = make(chan Message, 100)
+		client.limiter = newTokenBucket(m.rateLimit.Rate, m.rateLimit.Burst, client.ConnectedAt)
+		clients <- client
+		client.readPump()
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+	client := <-clients
+
+	for i := 0; i < 100; i++ {
+		if err := conn.WriteJSON(Message{Type: TypeHeartbeat}); err != nil {
+			break // 服务端断开后写入失败
+		}
+	}
+
+	select {
+	case unregistered := <-m.unregister:
+		if unregistered != client {
+			t.Fatalf("unregistered %s, want the flooding client", unregistered.ID)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("flooding client was not disconnected")
+	}
+
+	metrics := m.Metrics()
+	if metrics.MessagesThrottled != 11 || metrics.RateLimitDisconnects != 1 {
+		t.Errorf("throttled = %d, disconnects = %d, want 11 and 1", metrics.MessagesThrottled, metrics.RateLimitDisconnects)
+	}
+	var throttled, disconnected int
+	for len(client.Send) > 0 {
+		msg := <-client.Send
+		switch msg.Error {
+		case "Message rate limit exceeded":
+			throttled++
+		case "Disconnected: message rate limit exceeded":
+			disconnected++
+		}
+	}
+	if throttled != 10 || disconnected != 1 {
+		t.Errorf("client got %d throttle and %d disconnect error(s), want 10 and 1", throttled, disconnected)
+	}
+}
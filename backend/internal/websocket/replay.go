@@ -0,0 +1,92 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+	
+	"iot-platform-backend/internal/database"
+)
+
+// ReplayBuffer 基于Redis有序集合的设备消息回放缓冲区
+// 每条设备消息分配一个按设备递增的序号作为消息ID，断线重连的客户端可凭最后收到的ID补齐缺失消息
+type ReplayBuffer struct {
+	maxSize int64
+	ttl     time.Duration
+}
+
+// NewReplayBuffer 创建回放缓冲区，maxSize<=0时返回nil表示不启用
+func NewReplayBuffer(maxSize int64, ttl time.Duration) *ReplayBuffer {
+	if maxSize <= 0 {
+		return nil
+	}
+	return &ReplayBuffer{maxSize: maxSize, ttl: ttl}
+}
+
+// Append 为消息分配ID并写入设备缓冲区，超出容量的旧消息会被裁剪
+func (b *ReplayBuffer) Append(ctx context.Context, deviceID string, message *Message) error {
+	if database.RedisClient == nil {
+		return nil
+	}
+	
+	cache := database.NewCache()
+	seq, err := cache.Incr(ctx, database.Keys.DeviceReplaySeq(deviceID))
+	if err != nil {
+		return err
+	}
+	message.ID = strconv.FormatInt(seq, 10)
+	
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	
+	key := database.Keys.DeviceReplay(deviceID)
+	if err := cache.ZAdd(ctx, key, float64(seq), string(payload)); err != nil {
+		return err
+	}
+	
+	// 只保留最近maxSize条
+	if err := cache.ZRemRangeByRank(ctx, key, 0, -(b.maxSize + 1)); err != nil {
+		return err
+	}
+	
+	if b.ttl > 0 {
+		return cache.Expire(ctx, key, b.ttl)
+	}
+	return nil
+}
+
+// Since 获取ID大于afterID的缓冲消息，按ID升序返回
+func (b *ReplayBuffer) Since(ctx context.Context, deviceID string, afterID int64) ([]Message, error) {
+	if database.RedisClient == nil {
+		return nil, nil
+	}
+	
+	cache := database.NewCache()
+	members, err := cache.ZRangeByScore(ctx, database.Keys.DeviceReplay(deviceID),
+		"("+strconv.FormatInt(afterID, 10), "+inf", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	
+	messages := make([]Message, 0, len(members))
+	for _, member := range members {
+		var message Message
+		if err := json.Unmarshal([]byte(member), &message); err != nil {
+			continue
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// messageSeq 解析消息ID中的序号，无法解析时返回0
+func messageSeq(message Message) int64 {
+	seq, err := strconv.ParseInt(message.ID, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return seq
+}
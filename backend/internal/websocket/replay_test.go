@@ -0,0 +1,128 @@
+package websocket
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"iot-platform-backend/internal/testutil"
+)
+
+// newRegisteredClient 创建未绑定网络连接的客户端并注册到管理器
+func newRegisteredClient(m *Manager, id string, userID uint) *Client {
+	client := &Client{
+		ID:            id,
+		UserID:        userID,
+		Send:          make(chan Message, 64),
+		Manager:       m,
+		ConnectedAt:   time.Now(),
+		Subscriptions: make(map[string]bool),
+		replayCursors: make(map[string]int64),
+		replayHeld:    make(map[string][]Message),
+	}
+	m.mu.Lock()
+	m.clients[client.ID] = client
+	if m.userClients[userID] == nil {
+		m.userClients[userID] = make(map[string]*Client)
+	}
+	m.userClients[userID][client.ID] = client
+	m.mu.Unlock()
+	return client
+}
+
+// drainMessages 取出发送队列中已有的全部消息
+func drainMessages(client *Client) []Message {
+	var messages []Message
+	for {
+		select {
+		case message := <-client.Send:
+			messages = append(messages, message)
+		default:
+			return messages
+		}
+	}
+}
+
+func deviceMessage(value int) Message {
+	return Message{Type: TypeDeviceData, Data: map[string]interface{}{"value": value}, Timestamp: time.Now()}
+}
+
+func messageIDs(messages []Message) []string {
+	ids := make([]string, len(messages))
+	for i, message := range messages {
+		ids[i] = message.ID
+	}
+	return ids
+}
+
+func TestReplayBufferCapsSize(t *testing.T) {
+	testutil.UseRedis(t)
+	buffer := NewReplayBuffer(3, time.Minute)
+
+	for i := 1; i <= 5; i++ {
+		message := deviceMessage(i)
+		if err := buffer.Append(context.Background(), "dev-1", &message); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	messages, err := buffer.Since(context.Background(), "dev-1", 0)
+	if err != nil {
+		t.Fatalf("Since: %v", err)
+	}
+	if got := messageIDs(messages); len(got) != 3 || got[0] != "3" || got[2] != "5" {
+		t.Fatalf("buffered IDs = %v, want [3 4 5]", got)
+	}
+
+	messages, _ = buffer.Since(context.Background(), "dev-1", 4)
+	if got := messageIDs(messages); len(got) != 1 || got[0] != "5" {
+		t.Fatalf("IDs after 4 = %v, want [5]", got)
+	}
+}
+
+func TestReplayOnReconnect(t *testing.T) {
+	testutil.UseRedis(t)
+	m := NewManager()
+	m.replay = NewReplayBuffer(100, time.Minute)
+
+	// 首次连接收到消息1和2后断线
+	first := newRegisteredClient(m, "first", 1)
+	first.Subscriptions["dev-1"] = true
+	m.SendToDevice("dev-1", deviceMessage(1))
+	m.SendToDevice("dev-1", deviceMessage(2))
+	received := drainMessages(first)
+	lastID := received[len(received)-1].ID
+	m.mu.Lock()
+	delete(m.clients, first.ID)
+	m.mu.Unlock()
+
+	// 断线期间写入的消息只进入缓冲区
+	m.SendToDevice("dev-1", deviceMessage(3))
+	m.SendToDevice("dev-1", deviceMessage(4))
+
+	// 重连后携带游标订阅：回放期间到达的实时消息需排在回放消息之后
+	second := newRegisteredClient(m, "second", 1)
+	second.Subscriptions["dev-1"] = true
+	second.replayHeld["dev-1"] = []Message{}
+	m.SendToDevice("dev-1", deviceMessage(5))
+	if queued := drainMessages(second); len(queued) != 0 {
+		t.Fatalf("live message sent before replay finished: %v", messageIDs(queued))
+	}
+
+	cursor, _ := parseCursor(lastID)
+	if replayed := second.replay("dev-1", cursor); replayed != 3 {
+		t.Fatalf("replayed = %d, want 3 (buffered 3, 4 and the held live 5)", replayed)
+	}
+	m.SendToDevice("dev-1", deviceMessage(6))
+
+	got := messageIDs(drainMessages(second))
+	want := []string{"3", "4", "5", "6"}
+	if len(got) != len(want) {
+		t.Fatalf("received IDs = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("received IDs = %v, want %v", got, want)
+		}
+	}
+}
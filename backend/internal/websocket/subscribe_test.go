@@ -0,0 +1,125 @@
+package websocket
+
+import (
+	"testing"
+
+	"iot-platform-backend/internal/database"
+	"iot-platform-backend/internal/models"
+	"iot-platform-backend/internal/testutil"
+)
+
+func TestHandleSubscribeBatchAuthorization(t *testing.T) {
+	testutil.LoadConfig(t)
+	testutil.UseDB(t)
+
+	owner := models.User{Username: "owner", Email: "owner@example.com", Phone: "1", Password: "secret123"}
+	other := models.User{Username: "other", Email: "other@example.com", Phone: "2", Password: "secret123"}
+	for _, user := range []*models.User{&owner, &other} {
+		if err := database.DB.Create(user).Error; err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+	}
+	for _, device := range []models.Device{
+		{DeviceID: "mine", Name: "mine", Type: models.SoilMoisture, OwnerID: owner.ID},
+		{DeviceID: "theirs", Name: "theirs", Type: models.SoilMoisture, OwnerID: other.ID},
+	} {
+		if err := database.DB.Create(&device).Error; err != nil {
+			t.Fatalf("failed to create device: %v", err)
+		}
+	}
+
+	client := &Client{
+		ID:            "client-1",
+		UserID:        owner.ID,
+		Role:          "user",
+		Send:          make(chan Message, 1),
+		Subscriptions: make(map[string]bool),
+	}
+	client.handleSubscribe(Message{
+		Type: TypeSubscribe,
+		Data: map[string]interface{}{
+			"device_ids": []interface{}{"mine", "theirs", "missing", "mine"},
+		},
+	})
+
+	if !client.Subscriptions["mine"] {
+		t.Error("owned device was not subscribed")
+	}
+	if client.Subscriptions["theirs"] || client.Subscriptions["missing"] {
+		t.Errorf("subscriptions = %v, want only the owned device", client.Subscriptions)
+	}
+
+	response := <-client.Send
+	if response.Type != TypeNotification {
+		t.Fatalf("response type = %s, want %s", response.Type, TypeNotification)
+	}
+	results := response.Data.(map[string]interface{})["results"].([]SubscriptionResult)
+	want := map[string]string{"mine": "subscribed", "theirs": "denied", "missing": "denied"}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d: %+v", len(results), len(want), results)
+	}
+	for _, result := range results {
+		if result.Status != want[result.DeviceID] {
+			t.Errorf("device %s status = %s, want %s", result.DeviceID, result.Status, want[result.DeviceID])
+		}
+	}
+}
+
+func TestAdminAccessIsTenantScoped(t *testing.T) {
+	testutil.LoadConfig(t)
+	testutil.UseDB(t)
+
+	home := models.User{Username: "home", Email: "home@example.com", Phone: "1", Password: "secret123", TenantID: "acme"}
+	foreign := models.User{Username: "foreign", Email: "foreign@example.com", Phone: "2", Password: "secret123", TenantID: "globex"}
+	for _, user := range []*models.User{&home, &foreign} {
+		if err := database.DB.Create(user).Error; err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+	}
+	groups := map[uint]*models.DeviceGroup{
+		home.ID:    {Name: "home", OwnerID: home.ID},
+		foreign.ID: {Name: "foreign", OwnerID: foreign.ID},
+	}
+	for ownerID, group := range groups {
+		if err := database.DB.Create(group).Error; err != nil {
+			t.Fatalf("failed to create group: %v", err)
+		}
+		device := models.Device{DeviceID: group.Name + "-device", Name: group.Name, Type: models.SoilMoisture, OwnerID: ownerID, GroupID: &group.ID}
+		if err := database.DB.Create(&device).Error; err != nil {
+			t.Fatalf("failed to create device: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		tenantID    string
+		wantDevices []string
+		wantForeign bool
+	}{
+		{"tenant admin", "acme", []string{"home-device"}, false},
+		{"platform admin", "", []string{"home-device", "foreign-device"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &Client{ID: "admin", UserID: home.ID, Role: models.RoleAdmin, TenantID: tt.tenantID}
+
+			allowed := client.authorizeDevices([]string{"home-device", "foreign-device"})
+			if len(allowed) != len(tt.wantDevices) {
+				t.Errorf("authorized %v, want %v", allowed, tt.wantDevices)
+			}
+			for _, deviceID := range tt.wantDevices {
+				if !allowed[deviceID] {
+					t.Errorf("device %s was not authorized", deviceID)
+				}
+			}
+
+			if _, err := client.groupDeviceIDs(groups[home.ID].ID); err != nil {
+				t.Errorf("groupDeviceIDs(own tenant) = %v", err)
+			}
+			_, err := client.groupDeviceIDs(groups[foreign.ID].ID)
+			if (err == nil) != tt.wantForeign {
+				t.Errorf("groupDeviceIDs(other tenant) error = %v, want access %v", err, tt.wantForeign)
+			}
+		})
+	}
+}